@@ -1,4 +1,5 @@
-// Package core registers the server and all plugins we support.
+// Package core registers the server and all plugins we support. It also provides Instance
+// to embed CoreDNS in another Go program.
 package core
 
 import (
@@ -0,0 +1,46 @@
+// Package corefile expands a Corefile before it is parsed: it replaces the imports of URLs with the
+// contents of these URLs, and the imports of parameterized snippets with their bodies. Both the
+// coredns binary and the embeddable Instance of the core package load their Corefiles with it.
+package corefile
+
+import (
+	"time"
+
+	"github.com/caddyserver/caddy"
+)
+
+// DefaultImportTTL is the time a Corefile import from a URL is used without checking for a new
+// version, when the Loader doesn't set one.
+const DefaultImportTTL = 5 * time.Minute
+
+// Loader expands Corefiles.
+type Loader struct {
+	// ImportCache is the directory imports from URLs are cached in, defaults to the user cache
+	// directory.
+	ImportCache string
+	// ImportTTL is the time an import from a URL is used without checking for a new version,
+	// defaults to DefaultImportTTL.
+	ImportTTL time.Duration
+	// ImportKey is the file with the base64 encoded ed25519 public key imports from URLs must be
+	// signed with. Imports don't need to be signed when it's empty.
+	ImportKey string
+}
+
+// Expand expands the imports of URLs and parameterized snippets in contents.
+func (l Loader) Expand(contents []byte) ([]byte, error) {
+	contents, err := l.expandRemoteImports(contents)
+	if err != nil {
+		return nil, err
+	}
+	return expandSnippets(contents)
+}
+
+// Input expands contents and returns it as the caddy.Input for serverType. The path is used to
+// resolve the relative imports of files.
+func (l Loader) Input(contents []byte, path, serverType string) (caddy.Input, error) {
+	contents, err := l.Expand(contents)
+	if err != nil {
+		return nil, err
+	}
+	return caddy.CaddyfileInput{Contents: contents, Filepath: path, ServerTypeName: serverType}, nil
+}
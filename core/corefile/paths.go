@@ -0,0 +1,26 @@
+package corefile
+
+import "github.com/caddyserver/caddy"
+
+// pathsInput is a caddy.Input that was read from one or more files and directories.
+type pathsInput struct {
+	caddy.Input
+	paths []string
+}
+
+// WithPaths returns in, recording that it was read from the files and directories in paths.
+func WithPaths(in caddy.Input, paths []string) caddy.Input {
+	return pathsInput{Input: in, paths: paths}
+}
+
+// Paths returns the files and directories the Corefile in was read from. This is in.Path() unless
+// the Corefile was put together from several paths with WithPaths.
+func Paths(in caddy.Input) []string {
+	if p, ok := in.(pathsInput); ok {
+		return p.paths
+	}
+	if in.Path() == "" {
+		return nil
+	}
+	return []string{in.Path()}
+}
@@ -0,0 +1,28 @@
+package corefile
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestPaths(t *testing.T) {
+	in := caddy.CaddyfileInput{Contents: []byte(". {\n}\n"), Filepath: "Corefile", ServerTypeName: "dns"}
+
+	if p := Paths(in); !reflect.DeepEqual(p, []string{"Corefile"}) {
+		t.Errorf("Expected paths [Corefile], got %v", p)
+	}
+	if p := Paths(caddy.CaddyfileInput{}); p != nil {
+		t.Errorf("Expected no paths, got %v", p)
+	}
+
+	paths := []string{"Corefile", "conf.d"}
+	with := WithPaths(in, paths)
+	if p := Paths(with); !reflect.DeepEqual(p, paths) {
+		t.Errorf("Expected paths %v, got %v", paths, p)
+	}
+	if with.Path() != "Corefile" || string(with.Body()) != string(in.Body()) || with.ServerType() != "dns" {
+		t.Errorf("Expected input to be unchanged, got %q %q %q", with.Path(), with.Body(), with.ServerType())
+	}
+}
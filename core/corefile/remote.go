@@ -0,0 +1,291 @@
+package corefile
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// maxImportDepth limits how deep imported URLs may import other URLs.
+const maxImportDepth = 10
+
+// importClient fetches the imports, the timeout keeps an unreachable config host from hanging the start.
+var importClient = &http.Client{Timeout: 10 * time.Second}
+
+// expandRemoteImports replaces each import of a http or https URL in the Corefile contents with the
+// contents of that URL:
+//
+//	import https://config.example.org/common.conf
+//	import https://config.example.org/zones.conf sha256:9f86d08...
+//
+// Fetched imports are cached in the ImportCache directory and used for ImportTTL before the URL is
+// checked again, using the ETag and Last-Modified of the cached copy. When fetching fails, the last
+// cached copy is used, so an outage of the config host doesn't stop us from (re)starting. An optional
+// sha256 digest pins the contents of an import. With ImportKey, each import must be signed: the
+// base64 encoded ed25519 signature of the contents is fetched from the URL with ".sig" appended.
+func (l Loader) expandRemoteImports(contents []byte) ([]byte, error) {
+	if !bytes.Contains(contents, []byte("://")) {
+		return contents, nil
+	}
+	r, err := l.newRemoteImporter()
+	if err != nil {
+		return nil, err
+	}
+	return r.expand(contents, 0)
+}
+
+// remoteImporter fetches and caches the URL imports.
+type remoteImporter struct {
+	dir string
+	ttl time.Duration
+	key ed25519.PublicKey
+}
+
+func (l Loader) newRemoteImporter() (*remoteImporter, error) {
+	r := &remoteImporter{dir: l.ImportCache, ttl: l.ImportTTL}
+	if r.dir == "" {
+		if dir, err := os.UserCacheDir(); err == nil {
+			r.dir = filepath.Join(dir, "coredns", "import")
+		}
+	}
+	if r.ttl == 0 {
+		r.ttl = DefaultImportTTL
+	}
+	if l.ImportKey != "" {
+		key, err := readImportKey(l.ImportKey)
+		if err != nil {
+			return nil, err
+		}
+		r.key = key
+	}
+	return r, nil
+}
+
+// readImportKey reads the base64 encoded ed25519 public key from file.
+func readImportKey(file string) (ed25519.PublicKey, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(buf)))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("import key %s is not a base64 encoded ed25519 public key", file)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func (r *remoteImporter) expand(contents []byte, level int) ([]byte, error) {
+	if level > maxImportDepth {
+		return nil, fmt.Errorf("imports from URLs nested deeper than %d levels", maxImportDepth)
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		l := scanner.Text()
+		toks := fields(l)
+		if len(toks) < 2 || toks[0] != "import" || !isURL(toks[1]) {
+			out.WriteString(l)
+			out.WriteByte('\n')
+			continue
+		}
+		if len(toks) > 3 || (len(toks) == 3 && !strings.HasPrefix(toks[2], "sha256:")) {
+			return nil, fmt.Errorf("import %s: expected an optional sha256:DIGEST, got %q", toks[1], strings.Join(toks[2:], " "))
+		}
+		sum := ""
+		if len(toks) == 3 {
+			sum = strings.TrimPrefix(toks[2], "sha256:")
+		}
+
+		body, err := r.fetch(toks[1], sum)
+		if err != nil {
+			return nil, fmt.Errorf("import %s: %s", toks[1], err)
+		}
+		body, err = r.expand(body, level+1)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(body)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// importMeta is stored next to each cached import.
+type importMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Fetched      time.Time `json:"fetched"`
+}
+
+// fetch returns the contents of url, from the cache if it is fresh, and verifies them.
+func (r *remoteImporter) fetch(url, sum string) ([]byte, error) {
+	if r.dir == "" {
+		return nil, fmt.Errorf("no directory to cache imports in")
+	}
+	name := sha256.Sum256([]byte(url))
+	base := filepath.Join(r.dir, hex.EncodeToString(name[:]))
+
+	// A cached copy is only used when it matches the digest, which may have changed since it was cached.
+	cached, meta := r.cached(base)
+	if cached != nil && checkSum(cached, sum) != nil {
+		cached = nil
+	}
+	if cached != nil && time.Since(meta.Fetched) < r.ttl {
+		return cached, nil
+	}
+
+	body, fresh, notModified, err := r.get(url, meta)
+	if err == nil && notModified {
+		body = cached
+	}
+	if err == nil {
+		err = r.verify(url, body, sum)
+	}
+	if err != nil {
+		if cached == nil {
+			return nil, err
+		}
+		clog.Warningf("Failed to fetch import %s, using the copy fetched at %s: %s", url, meta.Fetched.Format(time.RFC3339), err)
+		return cached, nil
+	}
+
+	fresh.URL = url
+	fresh.Fetched = time.Now()
+	if err := r.store(base, body, fresh, !notModified); err != nil {
+		clog.Warningf("Failed to cache import %s: %s", url, err)
+	}
+	return body, nil
+}
+
+// cached returns the cached contents and metadata in base, the contents are nil if there is no
+// cached copy.
+func (r *remoteImporter) cached(base string) ([]byte, importMeta) {
+	var meta importMeta
+	buf, err := ioutil.ReadFile(base + ".json")
+	if err != nil || json.Unmarshal(buf, &meta) != nil {
+		return nil, importMeta{}
+	}
+	body, err := ioutil.ReadFile(base)
+	if err != nil {
+		return nil, importMeta{}
+	}
+	return body, meta
+}
+
+// get fetches url, the request is conditional when meta holds an ETag or Last-Modified. It returns
+// the metadata for the response and true if the server says the cached copy is still current.
+func (r *remoteImporter) get(url string, meta importMeta) ([]byte, importMeta, bool, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, meta, false, err
+	}
+	if meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+	if meta.LastModified != "" {
+		req.Header.Set("If-Modified-Since", meta.LastModified)
+	}
+	resp, err := importClient.Do(req)
+	if err != nil {
+		return nil, meta, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && !meta.Fetched.IsZero() {
+		return nil, meta, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, meta, false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, meta, false, err
+	}
+	meta.ETag = resp.Header.Get("ETag")
+	meta.LastModified = resp.Header.Get("Last-Modified")
+	return body, meta, false, nil
+}
+
+// verify checks body against the sha256 digest sum, if given, and the signature of url when we have
+// an import key.
+func (r *remoteImporter) verify(url string, body []byte, sum string) error {
+	if err := checkSum(body, sum); err != nil {
+		return err
+	}
+	if r.key == nil {
+		return nil
+	}
+
+	sig, _, _, err := r.get(url+".sig", importMeta{})
+	if err != nil {
+		return fmt.Errorf("signature: %s", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("signature: %s", err)
+	}
+	if !ed25519.Verify(r.key, body, raw) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// checkSum checks body against the hex encoded sha256 digest sum, an empty sum always matches.
+func checkSum(body []byte, sum string) error {
+	if sum == "" {
+		return nil
+	}
+	got := sha256.Sum256(body)
+	if !strings.EqualFold(hex.EncodeToString(got[:]), sum) {
+		return fmt.Errorf("sha256 digest mismatch: got %x", got)
+	}
+	return nil
+}
+
+// store writes body, when changed, and meta to the cache. The files are written to a temporary file
+// first, so a crash never leaves a partial copy.
+func (r *remoteImporter) store(base string, body []byte, meta importMeta, changed bool) error {
+	if err := os.MkdirAll(r.dir, 0700); err != nil {
+		return err
+	}
+	if changed {
+		if err := writeFileAtomic(base, body); err != nil {
+			return err
+		}
+	}
+	buf, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(base+".json", buf)
+}
+
+func writeFileAtomic(path string, buf []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
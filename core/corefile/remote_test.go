@@ -0,0 +1,178 @@
+package corefile
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestExpandRemoteImports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const common = "    log\n    errors\n"
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, common)
+	}))
+	defer srv.Close()
+
+	r := &remoteImporter{dir: filepath.Join(dir, "a"), ttl: time.Hour}
+	input := ". {\n    import " + srv.URL + "/common # comment\n    whoami\n}\n"
+	expected := ". {\n" + common + "    whoami\n}\n"
+
+	out, err := r.expand([]byte(input), 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if string(out) != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+
+	// Within the TTL the cached copy is used.
+	if _, err := r.expand([]byte(input), 0); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("Expected 1 request, got %d", n)
+	}
+
+	// After the TTL the URL is checked with the ETag.
+	r.ttl = 0
+	out, err = r.expand([]byte(input), 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if string(out) != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("Expected 2 requests, got %d", n)
+	}
+
+	// Without the server the last fetched copy is used.
+	srv.Close()
+	out, err = r.expand([]byte(input), 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if string(out) != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+
+	// Without a cached copy, a failed fetch is an error.
+	r.dir = filepath.Join(dir, "b")
+	if _, err := r.expand([]byte(input), 0); err == nil {
+		t.Errorf("Expected an error without a cached copy")
+	}
+}
+
+func TestExpandRemoteImportsDigest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const body = "whoami\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, body) }))
+	defer srv.Close()
+
+	sum := sha256.Sum256([]byte(body))
+	r := &remoteImporter{dir: dir, ttl: time.Hour}
+
+	if _, err := r.expand([]byte(fmt.Sprintf("import %s sha256:%x\n", srv.URL, sum)), 0); err != nil {
+		t.Errorf("Expected no error, got %s", err)
+	}
+	// The cached copy doesn't match a changed digest, and neither does the fetched one.
+	if _, err := r.expand([]byte(fmt.Sprintf("import %s sha256:%064x\n", srv.URL, 0)), 0); err == nil {
+		t.Errorf("Expected an error for a digest mismatch")
+	}
+	if _, err := r.expand([]byte("import "+srv.URL+" md5:0000\n"), 0); err == nil {
+		t.Errorf("Expected an error for an unknown argument")
+	}
+}
+
+func TestExpandRemoteImportsSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const body = "whoami\n"
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(body)))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/good", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, body) })
+	mux.HandleFunc("/good.sig", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, sig) })
+	mux.HandleFunc("/bad", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, "log\n") })
+	mux.HandleFunc("/bad.sig", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, sig) })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	keyFile := filepath.Join(dir, "key")
+	if err := ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(pub)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := readImportKey(keyFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	r := &remoteImporter{dir: filepath.Join(dir, "cache"), ttl: time.Hour, key: key}
+	if _, err := r.expand([]byte("import "+srv.URL+"/good\n"), 0); err != nil {
+		t.Errorf("Expected no error, got %s", err)
+	}
+	if _, err := r.expand([]byte("import "+srv.URL+"/bad\n"), 0); err == nil || !strings.Contains(err.Error(), "invalid signature") {
+		t.Errorf("Expected an invalid signature error, got %v", err)
+	}
+	if _, err := r.expand([]byte("import "+srv.URL+"/none\n"), 0); err == nil {
+		t.Errorf("Expected an error for a missing signature")
+	}
+}
+
+func TestExpandRemoteImportsNoURL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := Loader{ImportCache: filepath.Join(dir, "unused")}
+	input := ". {\n    forward . tls://9.9.9.9\n    import common\n}\n"
+	out, err := l.expandRemoteImports([]byte(input))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if string(out) != input {
+		t.Errorf("Expected %q, got %q", input, out)
+	}
+	if _, err := os.Stat(l.ImportCache); !os.IsNotExist(err) {
+		t.Errorf("Expected no cache directory to be created")
+	}
+}
@@ -0,0 +1,176 @@
+package corefile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// argsPlaceholder matches the positional argument placeholders, {args[0]}, {args[1]}, etc.
+var argsPlaceholder = regexp.MustCompile(`{args\[(\d+)\]}`)
+
+// maxSnippetDepth limits how deep parameterized snippets may import other parameterized snippets.
+const maxSnippetDepth = 10
+
+// expandSnippets expands all imports of parameterized snippets in the Corefile contents. A snippet
+// is parameterized when its body contains one or more {args[N]} placeholders, these are replaced with
+// the positional arguments given to import:
+//
+//	(tenant) {
+//	    {args[0]} {
+//	        forward . {args[1]}
+//	    }
+//	}
+//	import tenant example.org 10.0.0.1
+//
+// Snippets without placeholders and the snippet definitions themselves are left untouched, those are
+// handled by the Corefile parser.
+func expandSnippets(contents []byte) ([]byte, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	snippets := parseSnippets(lines)
+	if len(snippets) == 0 {
+		return contents, nil
+	}
+
+	out, err := expandLines(lines, snippets, 0)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.Join(out, "\n") + "\n"), nil
+}
+
+// parseSnippets returns the bodies of all parameterized snippets defined at the top level.
+func parseSnippets(lines []string) map[string][]string {
+	snippets := make(map[string][]string)
+	depth := 0
+	name := ""
+	var body []string
+	for _, l := range lines {
+		toks := fields(l)
+		if depth == 0 && len(toks) == 2 && toks[1] == "{" && isSnippetName(toks[0]) {
+			name = toks[0][1 : len(toks[0])-1]
+			body = nil
+			depth = 1
+			continue
+		}
+
+		depth += braces(toks)
+		if name == "" {
+			continue
+		}
+		if depth <= 0 {
+			if argsPlaceholder.MatchString(strings.Join(body, "\n")) {
+				snippets[name] = body
+			}
+			name = ""
+			depth = 0
+			continue
+		}
+		body = append(body, l)
+	}
+	return snippets
+}
+
+// expandLines replaces each import of a parameterized snippet with the snippet's body.
+func expandLines(lines []string, snippets map[string][]string, level int) ([]string, error) {
+	if level > maxSnippetDepth {
+		return nil, fmt.Errorf("parameterized snippets nested deeper than %d levels", maxSnippetDepth)
+	}
+
+	out := make([]string, 0, len(lines))
+	depth := 0
+	for _, l := range lines {
+		toks := fields(l)
+		// Leave the snippet definitions alone, these are only expanded when imported.
+		if depth == 0 && len(toks) == 2 && toks[1] == "{" && isSnippetName(toks[0]) {
+			depth = 1
+			out = append(out, l)
+			continue
+		}
+		if depth > 0 {
+			depth += braces(toks)
+			out = append(out, l)
+			continue
+		}
+
+		if len(toks) < 2 || toks[0] != "import" {
+			out = append(out, l)
+			continue
+		}
+		body, ok := snippets[toks[1]]
+		if !ok {
+			out = append(out, l)
+			continue
+		}
+
+		args := toks[2:]
+		expanded := make([]string, len(body))
+		for i, b := range body {
+			var err error
+			expanded[i], err = replaceArgs(b, args)
+			if err != nil {
+				return nil, fmt.Errorf("import %s: %s", toks[1], err)
+			}
+		}
+		expanded, err := expandLines(expanded, snippets, level+1)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, expanded...)
+	}
+	return out, nil
+}
+
+// replaceArgs replaces the {args[N]} placeholders in s with the values from args.
+func replaceArgs(s string, args []string) (string, error) {
+	var err error
+	s = argsPlaceholder.ReplaceAllStringFunc(s, func(p string) string {
+		i, _ := strconv.Atoi(argsPlaceholder.FindStringSubmatch(p)[1])
+		if i >= len(args) {
+			err = fmt.Errorf("argument %d not given, have %d argument(s)", i, len(args))
+			return p
+		}
+		return args[i]
+	})
+	return s, err
+}
+
+// fields splits l in whitespace separated fields, ignoring everything after a comment.
+func fields(l string) []string {
+	f := strings.Fields(l)
+	for i := range f {
+		if strings.HasPrefix(f[i], "#") {
+			return f[:i]
+		}
+	}
+	return f
+}
+
+// braces returns the number of opened minus the number of closed blocks in fields.
+func braces(fields []string) int {
+	n := 0
+	for _, f := range fields {
+		switch f {
+		case "{":
+			n++
+		case "}":
+			n--
+		}
+	}
+	return n
+}
+
+func isSnippetName(s string) bool {
+	return len(s) > 2 && strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+}
@@ -0,0 +1,98 @@
+package corefile
+
+import "testing"
+
+func TestExpandSnippets(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  string
+		shouldErr bool
+	}{
+		// no parameterized snippets, returned as-is
+		{`(common) {
+    log
+}
+. {
+    import common
+}`, `(common) {
+    log
+}
+. {
+    import common
+}`, false},
+		// server block snippet
+		{`(tenant) {
+    {args[0]} {
+        forward . {args[1]}
+    }
+}
+import tenant example.org 10.0.0.1
+import tenant example.net 10.0.0.2 # comment
+`, `(tenant) {
+    {args[0]} {
+        forward . {args[1]}
+    }
+}
+    example.org {
+        forward . 10.0.0.1
+    }
+    example.net {
+        forward . 10.0.0.2
+    }
+`, false},
+		// snippet importing another parameterized snippet
+		{`(fwd) {
+    forward . {args[0]}
+}
+(tenant) {
+    {args[0]} {
+        import fwd {args[1]}
+    }
+}
+import tenant example.org 10.0.0.1
+`, `(fwd) {
+    forward . {args[0]}
+}
+(tenant) {
+    {args[0]} {
+        import fwd {args[1]}
+    }
+}
+    example.org {
+    forward . 10.0.0.1
+    }
+`, false},
+		// missing argument
+		{`(tenant) {
+    {args[0]} {
+        forward . {args[1]}
+    }
+}
+import tenant example.org
+`, "", true},
+		// recursive snippet
+		{`(loop) {
+    import loop {args[0]}
+}
+import loop a
+`, "", true},
+	}
+
+	for i, tc := range tests {
+		out, err := expandSnippets([]byte(tc.input))
+		if tc.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error, but there wasn't any", i)
+			continue
+		}
+		if !tc.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error, but there was one: %v", i, err)
+			continue
+		}
+		if tc.shouldErr {
+			continue
+		}
+		if string(out) != tc.expected {
+			t.Errorf("Test %d: Expected\n%s\nbut got\n%s", i, tc.expected, out)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package dnsserver
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// acmeChallenges are the TXT records of the pending ACME DNS-01 challenges, keyed by their lower
+// cased name. Every server answers them before the plugins, as the CA queries the authoritative
+// servers of the domain: us.
+var acmeChallenges = struct {
+	sync.RWMutex
+	m map[string][]string
+}{m: make(map[string][]string)}
+
+// acmeChallengeTTL is the TTL of the challenge records, they only live for the validation.
+const acmeChallengeTTL = 60
+
+// AddACMEChallenge adds the TXT record value under name, the _acme-challenge name of a domain, until
+// RemoveACMEChallenge.
+func AddACMEChallenge(name, value string) {
+	name = strings.ToLower(dns.Fqdn(name))
+
+	acmeChallenges.Lock()
+	defer acmeChallenges.Unlock()
+	acmeChallenges.m[name] = append(acmeChallenges.m[name], value)
+}
+
+// RemoveACMEChallenge removes the TXT record value under name.
+func RemoveACMEChallenge(name, value string) {
+	name = strings.ToLower(dns.Fqdn(name))
+
+	acmeChallenges.Lock()
+	defer acmeChallenges.Unlock()
+	values := acmeChallenges.m[name]
+	for i, v := range values {
+		if v == value {
+			values = append(values[:i:i], values[i+1:]...)
+			break
+		}
+	}
+	if len(values) == 0 {
+		delete(acmeChallenges.m, name)
+		return
+	}
+	acmeChallenges.m[name] = values
+}
+
+// serveACMEChallenge answers r when it asks for the TXT records of a pending challenge, and returns
+// true if it did.
+func serveACMEChallenge(w dns.ResponseWriter, r *dns.Msg) bool {
+	q := r.Question[0]
+	if q.Qtype != dns.TypeTXT {
+		return false
+	}
+	name := strings.ToLower(q.Name)
+
+	acmeChallenges.RLock()
+	values := acmeChallenges.m[name]
+	acmeChallenges.RUnlock()
+	if len(values) == 0 {
+		return false
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	for _, v := range values {
+		hdr := dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: acmeChallengeTTL}
+		m.Answer = append(m.Answer, &dns.TXT{Hdr: hdr, Txt: []string{v}})
+	}
+	w.WriteMsg(m)
+	return true
+}
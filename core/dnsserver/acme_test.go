@@ -0,0 +1,46 @@
+package dnsserver
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestServeACMEChallenge(t *testing.T) {
+	AddACMEChallenge("_acme-challenge.Example.org", "a")
+	AddACMEChallenge("_acme-challenge.example.org.", "b")
+	defer RemoveACMEChallenge("_acme-challenge.example.org.", "b")
+
+	m := new(dns.Msg)
+	m.SetQuestion("_acme-challenge.EXAMPLE.org.", dns.TypeTXT)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if !serveACMEChallenge(rec, m) {
+		t.Fatal("Expected the challenge to be answered")
+	}
+	if len(rec.Msg.Answer) != 2 || !rec.Msg.Authoritative {
+		t.Errorf("Expected 2 authoritative TXT records, got %v", rec.Msg)
+	}
+
+	RemoveACMEChallenge("_acme-challenge.example.org.", "a")
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	serveACMEChallenge(rec, m)
+	if len(rec.Msg.Answer) != 1 || rec.Msg.Answer[0].(*dns.TXT).Txt[0] != "b" {
+		t.Errorf("Expected the TXT record b, got %v", rec.Msg)
+	}
+
+	for _, q := range []struct {
+		name  string
+		qtype uint16
+	}{
+		{"_acme-challenge.example.org.", dns.TypeA},
+		{"_acme-challenge.example.net.", dns.TypeTXT},
+	} {
+		m.SetQuestion(q.name, q.qtype)
+		if serveACMEChallenge(dnstest.NewRecorder(&test.ResponseWriter{}), m) {
+			t.Errorf("Expected %s %d not to be answered", q.name, q.qtype)
+		}
+	}
+}
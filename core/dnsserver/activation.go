@@ -0,0 +1,137 @@
+package dnsserver
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+)
+
+// listenFdsStart is the first file descriptor passed with systemd socket activation.
+const listenFdsStart = 3
+
+// activatedSockets holds the sockets passed to us with socket activation. Each socket is handed out
+// once, to the first server that listens on its address.
+type activatedSockets struct {
+	mu        sync.Mutex
+	listeners []net.Listener
+	conns     []net.PacketConn
+}
+
+var (
+	activation     *activatedSockets
+	activationOnce sync.Once
+)
+
+// activated returns the sockets passed to us by systemd, see sd_listen_fds(3). The environment
+// variables are unset, so they are not inherited by child processes.
+func activated() *activatedSockets {
+	activationOnce.Do(func() {
+		activation = newActivatedSockets(listenFiles())
+	})
+	return activation
+}
+
+// listenFiles returns the files for the file descriptors in LISTEN_FDS, if LISTEN_PID is our pid.
+func listenFiles() []*os.File {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	files := make([]*os.File, n)
+	for i := range files {
+		name := "LISTEN_FD_" + strconv.Itoa(listenFdsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		files[i] = os.NewFile(uintptr(listenFdsStart+i), name)
+	}
+	return files
+}
+
+// newActivatedSockets returns the sockets for files and closes them, sockets of an unknown type are
+// ignored.
+func newActivatedSockets(files []*os.File) *activatedSockets {
+	a := &activatedSockets{}
+	for _, f := range files {
+		if l, err := net.FileListener(f); err == nil {
+			a.listeners = append(a.listeners, l)
+		} else if p, err := net.FilePacketConn(f); err == nil {
+			a.conns = append(a.conns, p)
+		} else {
+			log.Warningf("Ignoring socket %s passed with socket activation: %s", f.Name(), err)
+		}
+		f.Close()
+	}
+	return a
+}
+
+// listener returns the passed listener for addr and removes it from a.
+func (a *activatedSockets) listener(addr string) (net.Listener, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, l := range a.listeners {
+		if matchAddr(l.Addr(), addr) {
+			a.listeners = append(a.listeners[:i], a.listeners[i+1:]...)
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// packetConn returns the passed packet conn for addr and removes it from a.
+func (a *activatedSockets) packetConn(addr string) (net.PacketConn, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, p := range a.conns {
+		if matchAddr(p.LocalAddr(), addr) {
+			a.conns = append(a.conns[:i], a.conns[i+1:]...)
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// matchAddr returns true if the socket address sa can be used for listening on addr. An addr
+// without host matches any address with the same port.
+func matchAddr(sa net.Addr, addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	var ip net.IP
+	var p int
+	switch x := sa.(type) {
+	case *net.TCPAddr:
+		ip, p = x.IP, x.Port
+	case *net.UDPAddr:
+		ip, p = x.IP, x.Port
+	default:
+		return false
+	}
+	if strconv.Itoa(p) != port {
+		return false
+	}
+	return host == "" || ip.Equal(net.ParseIP(host))
+}
+
+// activatedListener returns the listener for addr passed with socket activation, if any.
+func activatedListener(addr string) (net.Listener, bool) { return activated().listener(addr) }
+
+// activatedPacketConn returns the packet conn for addr passed with socket activation, if any.
+func activatedPacketConn(addr string) (net.PacketConn, bool) { return activated().packetConn(addr) }
@@ -0,0 +1,70 @@
+package dnsserver
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+func TestActivatedSockets(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	p, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	lf, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf, err := p.(*net.UDPConn).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newActivatedSockets([]*os.File{lf, pf})
+
+	_, tcpPort, _ := net.SplitHostPort(l.Addr().String())
+	_, udpPort, _ := net.SplitHostPort(p.LocalAddr().String())
+
+	if _, ok := a.listener("127.0.0.2:" + tcpPort); ok {
+		t.Errorf("Expected no listener for another address")
+	}
+	al, ok := a.listener(":" + tcpPort)
+	if !ok {
+		t.Fatalf("Expected listener for port %s", tcpPort)
+	}
+	defer al.Close()
+	if _, ok := a.listener(":" + tcpPort); ok {
+		t.Errorf("Expected listener to be handed out once")
+	}
+	ap, ok := a.packetConn("127.0.0.1:" + udpPort)
+	if !ok {
+		t.Fatalf("Expected packet conn for port %s", udpPort)
+	}
+	defer ap.Close()
+}
+
+func TestMatchAddr(t *testing.T) {
+	tests := []struct {
+		sa       net.Addr
+		addr     string
+		expected bool
+	}{
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 53}, ":53", true},
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 53}, ":54", false},
+		{&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}, "127.0.0.1:53", true},
+		{&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}, "[::1]:53", false},
+		{&net.TCPAddr{IP: net.IPv6loopback, Port: 853}, "[::1]:853", true},
+		{&net.UnixAddr{Name: "/tmp/sock"}, ":53", false},
+	}
+	for i, tc := range tests {
+		if x := matchAddr(tc.sa, tc.addr); x != tc.expected {
+			t.Errorf("Test %d: Expected %t for %s and %s, got %t", i, tc.expected, tc.sa, tc.addr, x)
+		}
+	}
+}
@@ -51,6 +51,12 @@ func normalizeZone(str string) (zoneAddr, error) {
 			port = transport.HTTPSPort
 		case transport.HTTP:
 			port = transport.HTTPPort
+		case transport.QUIC:
+			port = transport.QUICPort
+		case transport.H3:
+			port = transport.H3Port
+		case transport.DTLS:
+			port = transport.DTLSPort
 		}
 	}
 
@@ -84,7 +90,17 @@ func newOverlapZone() *zoneOverlap {
 // registerAndCheck adds a new zoneAddr for validation, it returns information about existing or overlapping with already registered
 // we consider that an unbound address is overlapping all bound addresses for same zone, same port
 func (zo *zoneOverlap) registerAndCheck(z zoneAddr) (existingZone *zoneAddr, overlappingZone *zoneAddr) {
+	if existingZone, overlappingZone = zo.check(z); existingZone != nil || overlappingZone != nil {
+		return existingZone, overlappingZone
+	}
+	// there is no overlap, keep the current zoneAddr for future checks
+	zo.registeredAddr[z] = z
+	zo.unboundOverlap[zoneAddr{Zone: z.Zone, Address: "", Port: z.Port, Transport: z.Transport}] = z
+	return nil, nil
+}
 
+// check is registerAndCheck without registering z.
+func (zo *zoneOverlap) check(z zoneAddr) (existingZone *zoneAddr, overlappingZone *zoneAddr) {
 	if exist, ok := zo.registeredAddr[z]; ok {
 		// exact same zone already registered
 		return &exist, nil
@@ -100,8 +116,5 @@ func (zo *zoneOverlap) registerAndCheck(z zoneAddr) (existingZone *zoneAddr, ove
 			return nil, &uz
 		}
 	}
-	// there is no overlap, keep the current zoneAddr for future checks
-	zo.registeredAddr[z] = z
-	zo.unboundOverlap[uz] = z
 	return nil, nil
 }
@@ -0,0 +1,8 @@
+package dnsserver
+
+import (
+	"github.com/coredns/coredns/plugin/pkg/feature"
+)
+
+// udpBatchGate is the feature gate for reading and writing UDP datagrams in batches, see newBatchConn.
+var udpBatchGate = feature.Register("udp_batch", "Read and write UDP queries in batches with recvmmsg and sendmmsg on Linux.", false)
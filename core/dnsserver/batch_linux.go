@@ -0,0 +1,263 @@
+// +build linux
+
+package dnsserver
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// batchSize is the largest number of datagrams read or written with a single system call.
+const batchSize = 64
+
+// batchConn serves DNS over a UDP socket with batched system calls: the queries are read with
+// recvmmsg, up to batchSize at a time, handed over to a pool of workers, one per CPU, and their
+// responses are written with sendmmsg, as many as are waiting. This saves system calls under load,
+// at the cost of hand overs between goroutines.
+//
+// Responses larger than maxGSOSegmentSize are written right away instead, so dontFragWriter gets the
+// error when they are too large for the path to the client.
+type batchConn struct {
+	conn   *net.UDPConn
+	pc     batchPacketConn
+	source bool // set the source address of the responses, the socket is bound to the wildcard address
+
+	queries chan batchQuery
+	out     chan batchResponse
+
+	closed  int32 // 1 when shutting down, accessed atomically
+	workers sync.WaitGroup
+	writer  sync.WaitGroup
+}
+
+// batchPacketConn is implemented by ipv4.PacketConn and ipv6.PacketConn.
+type batchPacketConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// batchQuery is a query read from a batchConn.
+type batchQuery struct {
+	buf   *[]byte // the buffer from offloadBufs the query was read in
+	n     int
+	raddr *net.UDPAddr
+	oob   []byte // control message that sets the source address of the response
+}
+
+// batchResponse is a response waiting to be written.
+type batchResponse struct {
+	txMsg
+	raddr *net.UDPAddr
+	oob   []byte
+}
+
+// newBatchConn returns a batchConn for p when the udp_batch feature gate is enabled, otherwise it
+// returns nil.
+func newBatchConn(p net.PacketConn) *batchConn {
+	u, ok := p.(*net.UDPConn)
+	if !ok || !udpBatchGate.Enabled() {
+		return nil
+	}
+	c := &batchConn{
+		conn:    u,
+		source:  !boundToAddress(u),
+		queries: make(chan batchQuery, batchSize),
+		out:     make(chan batchResponse, batchSize),
+	}
+	if isIPv4(u) {
+		p4 := ipv4.NewPacketConn(u)
+		if c.source {
+			p4.SetControlMessage(ipv4.FlagDst|ipv4.FlagInterface, true)
+		}
+		c.pc = p4
+	} else {
+		p6 := ipv6.NewPacketConn(u)
+		if c.source {
+			// The wildcard address of both families: get the destination of the IPv4 queries too.
+			p6.SetControlMessage(ipv6.FlagDst|ipv6.FlagInterface, true)
+			ipv4.NewPacketConn(u).SetControlMessage(ipv4.FlagDst|ipv4.FlagInterface, true)
+		}
+		c.pc = p6
+	}
+	// Added here, shutdown may be called before serve runs.
+	c.writer.Add(1)
+	return c
+}
+
+// serve reads the queries from c and serves them with s. It blocks until c is shut down.
+func (c *batchConn) serve(s *Server) error {
+	ctx := context.WithValue(context.Background(), Key{}, s)
+	for i := 0; i < runtime.NumCPU(); i++ {
+		c.workers.Add(1)
+		go c.work(ctx, s)
+	}
+	go c.write()
+	defer func() {
+		close(c.queries)
+		c.workers.Wait()
+		close(c.out)
+		c.writer.Wait()
+	}()
+
+	ms := make([]ipv4.Message, batchSize)
+	bufs := make([]*[]byte, batchSize)
+	for i := range ms {
+		ms[i].OOB = make([]byte, 512)
+	}
+	for {
+		for i := range ms {
+			if bufs[i] == nil {
+				bufs[i] = offloadBufs.Get().(*[]byte)
+			}
+			ms[i].Buffers = [][]byte{*bufs[i]}
+			ms[i].OOB = ms[i].OOB[:cap(ms[i].OOB)]
+		}
+		n, err := c.pc.ReadBatch(ms, 0)
+		if err != nil {
+			if atomic.LoadInt32(&c.closed) == 1 {
+				for _, b := range bufs {
+					if b != nil {
+						offloadBufs.Put(b)
+					}
+				}
+				return nil
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				continue
+			}
+			return err
+		}
+		for i := 0; i < n; i++ {
+			raddr, ok := ms[i].Addr.(*net.UDPAddr)
+			if !ok {
+				continue
+			}
+			_, oob := parseOffloadOOB(ms[i].OOB[:ms[i].NN], c.source)
+			c.queries <- batchQuery{buf: bufs[i], n: ms[i].N, raddr: raddr, oob: oob}
+			bufs[i] = nil
+		}
+	}
+}
+
+// work serves the queries read from c.
+func (c *batchConn) work(ctx context.Context, s *Server) {
+	defer c.workers.Done()
+	for q := range c.queries {
+		r := new(dns.Msg)
+		if err := r.Unpack((*q.buf)[:q.n]); err == nil && !r.Response {
+			s.serveUDP(ctx, &batchWriter{c: c, raddr: q.raddr, oob: q.oob}, r)
+		}
+		offloadBufs.Put(q.buf)
+	}
+}
+
+// write writes the responses of c, in batches of those that are waiting.
+func (c *batchConn) write() {
+	defer c.writer.Done()
+	rs := make([]batchResponse, 0, batchSize)
+	ms := make([]ipv4.Message, batchSize)
+	for r := range c.out {
+		rs = append(rs[:0], r)
+	more:
+		for len(rs) < batchSize {
+			select {
+			case r, ok := <-c.out:
+				if !ok {
+					break more
+				}
+				rs = append(rs, r)
+			default:
+				break more
+			}
+		}
+
+		for i, r := range rs {
+			ms[i] = ipv4.Message{Buffers: [][]byte{r.b}, OOB: r.oob, Addr: r.raddr}
+		}
+		for sent := 0; sent < len(rs); {
+			n, err := c.pc.WriteBatch(ms[sent:len(rs)], 0)
+			if err != nil {
+				// The response that failed is dropped, as a failed write of dns.Server would.
+				log.Debugf("Failed to write the responses on %s: %s", c.conn.LocalAddr(), err)
+				n++
+			}
+			sent += n
+		}
+		for _, r := range rs {
+			r.release()
+		}
+	}
+}
+
+// shutdown stops reading from c, waits for the queries being handled and closes c.
+func (c *batchConn) shutdown() error {
+	atomic.StoreInt32(&c.closed, 1)
+	c.conn.SetReadDeadline(time.Unix(1, 0)) // unblock the read
+	c.writer.Wait()
+	return c.conn.Close()
+}
+
+// batchWriter is the dns.ResponseWriter for a query read from a batchConn.
+type batchWriter struct {
+	c     *batchConn
+	raddr *net.UDPAddr
+	oob   []byte
+}
+
+// LocalAddr implements the dns.ResponseWriter interface.
+func (w *batchWriter) LocalAddr() net.Addr { return w.c.conn.LocalAddr() }
+
+// RemoteAddr implements the dns.ResponseWriter interface.
+func (w *batchWriter) RemoteAddr() net.Addr { return w.raddr }
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *batchWriter) WriteMsg(m *dns.Msg) error {
+	buf, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *batchWriter) Write(m []byte) (int, error) {
+	if err := w.writeOwned(append([]byte(nil), m...), nil); err != nil {
+		return 0, err
+	}
+	return len(m), nil
+}
+
+// writeOwned implements the ownedWriter interface.
+func (w *batchWriter) writeOwned(m []byte, buf *[]byte) error {
+	if len(m) > maxGSOSegmentSize {
+		// The error is returned, for dontFragWriter.
+		_, _, err := w.c.conn.WriteMsgUDP(m, w.oob, w.raddr)
+		txMsg{buf: buf}.release()
+		return err
+	}
+	w.c.out <- batchResponse{txMsg: txMsg{b: m, buf: buf}, raddr: w.raddr, oob: w.oob}
+	return nil
+}
+
+// Close implements the dns.ResponseWriter interface.
+func (w *batchWriter) Close() error { return nil }
+
+// TsigStatus implements the dns.ResponseWriter interface. The TSIG of the queries is not verified.
+func (w *batchWriter) TsigStatus() error { return dns.ErrSecret }
+
+// TsigTimersOnly implements the dns.ResponseWriter interface.
+func (w *batchWriter) TsigTimersOnly(bool) {}
+
+// Hijack implements the dns.ResponseWriter interface.
+func (w *batchWriter) Hijack() {}
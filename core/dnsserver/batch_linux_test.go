@@ -0,0 +1,108 @@
+// +build linux
+
+package dnsserver
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/feature"
+
+	"github.com/miekg/dns"
+)
+
+func TestBatch(t *testing.T) {
+	if err := feature.Set(map[string]bool{"udp_batch": true}); err != nil {
+		t.Fatal(err)
+	}
+	defer feature.Set(nil)
+
+	// The handler answers large.example.org. with a response too large to be batched.
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.Question[0].Name == "large.example.org." {
+			m.Answer = append(m.Answer, &dns.TXT{
+				Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET},
+				Txt: []string{strings.Repeat("a", 255), strings.Repeat("b", 255), strings.Repeat("c", 255), strings.Repeat("d", 255), strings.Repeat("e", 255)},
+			})
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+
+	// Bound to the wildcard address, the responses must come from the address of the queries.
+	for _, laddr := range []string{"127.0.0.1:0", ":0"} {
+		c := testConfig("dns", h)
+		c.Zone = "."
+		s, err := NewServer("127.0.0.1:0", []*Config{c})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pc, err := net.ListenPacket("udp", laddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go s.ServePacket(pc)
+
+		client, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: pc.LocalAddr().(*net.UDPAddr).Port})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Send the queries at once, for the server to read them in batches.
+		const queries = 100
+		for id := uint16(1); id <= queries; id++ {
+			m := new(dns.Msg)
+			m.SetQuestion("example.org.", dns.TypeA)
+			if id == 1 {
+				m.SetQuestion("large.example.org.", dns.TypeTXT)
+				m.SetEdns0(4096, false)
+			}
+			m.Id = id
+			b, _ := m.Pack()
+			client.Write(b)
+		}
+
+		seen := map[uint16]bool{}
+		resp := make([]byte, dns.MaxMsgSize)
+		client.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for len(seen) < queries {
+			n, err := client.Read(resp)
+			if err != nil {
+				t.Fatalf("%s: expected %d responses, got %d: %s", laddr, queries, len(seen), err)
+			}
+			m := new(dns.Msg)
+			if err := m.Unpack(resp[:n]); err != nil {
+				t.Fatalf("%s: expected a response, got %s", laddr, err)
+			}
+			if m.Id == 1 && len(m.Answer) != 1 {
+				t.Errorf("%s: expected the large response, got %d answers", laddr, len(m.Answer))
+			}
+			seen[m.Id] = true
+		}
+		client.Close()
+
+		s.m.Lock()
+		batch := s.batch != nil
+		s.m.Unlock()
+		if !batch {
+			t.Errorf("%s: expected the queries to be read in batches", laddr)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			s.Stop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: expected the server to stop", laddr)
+		}
+	}
+}
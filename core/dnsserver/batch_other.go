@@ -0,0 +1,14 @@
+// +build !linux
+
+package dnsserver
+
+import "net"
+
+// batchConn is only implemented on Linux.
+type batchConn struct{}
+
+func newBatchConn(p net.PacketConn) *batchConn { return nil }
+
+func (c *batchConn) serve(s *Server) error { return nil }
+
+func (c *batchConn) shutdown() error { return nil }
@@ -0,0 +1,191 @@
+// +build linux
+
+package dnsserver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The bpf(2) commands and constants used, from linux/bpf.h.
+const (
+	bpfMapCreate     = 0
+	bpfMapLookupElem = 1
+	bpfMapUpdateElem = 2
+	bpfProgLoad      = 5
+	bpfLinkCreate    = 28
+
+	bpfProgTypeSocketFilter = 1
+	bpfProgTypeXDP          = 6
+	bpfXDP                  = 37 // attach type of BPF_LINK_CREATE
+
+	bpfMapTypeArray = 2
+
+	bpfFuncMapLookupElem = 1
+	bpfFuncRedirectMap   = 51
+)
+
+func bpf(cmd int, attr unsafe.Pointer, size uintptr) (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_BPF, uintptr(cmd), uintptr(attr), size)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// newBPFMap returns a map of the type mapType, with n entries of keySize and valueSize bytes.
+func newBPFMap(mapType, keySize, valueSize, n uint32) (int, error) {
+	attr := struct {
+		mapType, keySize, valueSize, maxEntries, flags uint32
+	}{mapType, keySize, valueSize, n, 0}
+	return bpf(bpfMapCreate, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+}
+
+// bpfMapElem does cmd, a lookup or an update, with the element of the map m at key.
+func bpfMapElem(cmd, m int, key, value unsafe.Pointer) error {
+	attr := struct {
+		mapFd uint32
+		_     uint32
+		key   uint64
+		value uint64
+		flags uint64
+	}{mapFd: uint32(m), key: uint64(uintptr(key)), value: uint64(uintptr(value))}
+	_, err := bpf(cmd, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return err
+}
+
+// loadBPFProgram loads the program insns of the type progType, and returns it. The verifier's log is
+// in the error when the program is refused.
+func loadBPFProgram(progType uint32, name string, insns []bpfInsn) (int, error) {
+	license := []byte("GPL\x00")
+	logBuf := make([]byte, 64*1024)
+	attr := struct {
+		progType, insnCnt uint32
+		insns, license    uint64
+		logLevel, logSize uint32
+		logBuf            uint64
+		kernVersion       uint32
+		progFlags         uint32
+		progName          [16]byte
+	}{
+		progType: progType,
+		insnCnt:  uint32(len(insns)),
+		insns:    uint64(uintptr(unsafe.Pointer(&insns[0]))),
+		license:  uint64(uintptr(unsafe.Pointer(&license[0]))),
+		logLevel: 1,
+		logSize:  uint32(len(logBuf)),
+		logBuf:   uint64(uintptr(unsafe.Pointer(&logBuf[0]))),
+	}
+	copy(attr.progName[:15], name)
+	fd, err := bpf(bpfProgLoad, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		if n := cstringLen(logBuf); n > 0 {
+			return -1, fmt.Errorf("%s: %s", err, logBuf[:n])
+		}
+		return -1, err
+	}
+	return fd, nil
+}
+
+func cstringLen(b []byte) int {
+	for i, c := range b {
+		if c == 0 {
+			return i
+		}
+	}
+	return len(b)
+}
+
+// bpfInsn is an eBPF instruction.
+type bpfInsn struct {
+	code uint8
+	regs uint8 // the destination register in the low 4 bits, the source register in the high ones
+	off  int16
+	imm  int32
+}
+
+// The eBPF instruction codes used, see Documentation/bpf/instruction-set.rst.
+const (
+	bpfLdxW    = 0x61 // dst = *(u32 *)(src + off)
+	bpfLdxH    = 0x69 // dst = *(u16 *)(src + off)
+	bpfLdxB    = 0x71 // dst = *(u8 *)(src + off)
+	bpfMovReg  = 0xbf // dst = src
+	bpfMovImm  = 0xb7 // dst = imm
+	bpfAddImm  = 0x07 // dst += imm
+	bpfAndImm  = 0x57 // dst &= imm
+	bpfJgtReg  = 0x2d // if dst > src goto pc + off
+	bpfJneImm  = 0x55 // if dst != imm goto pc + off
+	bpfJeqImm  = 0x15 // if dst == imm goto pc + off
+	bpfJne32   = 0x56 // if u32(dst) != u32(imm) goto pc + off
+	bpfJa      = 0x05 // goto pc + off
+	bpfLdImm64 = 0x18 // dst = imm, in two instructions
+	bpfCall    = 0x85
+	bpfExit    = 0x95
+
+	bpfPseudoMapFD = 1 // the source register of a bpfLdImm64 loading a map
+)
+
+// bpfAsm assembles an eBPF program, with jumps to labels.
+type bpfAsm struct {
+	insns  []bpfInsn
+	labels map[string]int
+	jumps  map[int]string // the label of the jump at an index
+}
+
+func (a *bpfAsm) op(code uint8, dst, src uint8, off int16, imm int32) {
+	a.insns = append(a.insns, bpfInsn{code: code, regs: src<<4 | dst, off: off, imm: imm})
+}
+
+// jump adds the jump code to label.
+func (a *bpfAsm) jump(code uint8, dst, src uint8, imm int32, label string) {
+	if a.jumps == nil {
+		a.jumps = map[int]string{}
+	}
+	a.jumps[len(a.insns)] = label
+	a.op(code, dst, src, 0, imm)
+}
+
+func (a *bpfAsm) label(name string) {
+	if a.labels == nil {
+		a.labels = map[string]int{}
+	}
+	a.labels[name] = len(a.insns)
+}
+
+// program returns the instructions, with the offsets of the jumps resolved.
+func (a *bpfAsm) program() []bpfInsn {
+	for i, l := range a.jumps {
+		a.insns[i].off = int16(a.labels[l] - i - 1)
+	}
+	return a.insns
+}
+
+// hostU16 returns the value of the network order bytes of v loaded in a register.
+func hostU16(v uint16) int32 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return int32(*(*uint16)(unsafe.Pointer(&b[0])))
+}
+
+// hostU32 returns the value of the network order bytes b loaded in a register.
+func hostU32(b []byte) int32 {
+	return *(*int32)(unsafe.Pointer(&b[0]))
+}
+
+// The registers.
+const (
+	bpfR0 = iota
+	bpfR1
+	bpfR2
+	bpfR3
+	bpfR4
+	bpfR5
+	bpfR6
+	bpfR7
+	bpfR8
+	bpfR9
+	bpfR10 // the frame pointer
+)
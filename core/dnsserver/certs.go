@@ -0,0 +1,116 @@
+package dnsserver
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+)
+
+// CertManager holds the certificate of the TLS servers (DNS-over-TLS, DNS-over-HTTPS and gRPC) loaded
+// from a cert and a key file, and reloads it when the files change. A rotated certificate is used by
+// the new connections without restarting the servers, the established ones are left alone. The
+// servers get the certificate with GetCertificate.
+type CertManager struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	cert atomic.Value // *tls.Certificate
+
+	mu      sync.Mutex
+	certPEM []byte // the contents of the files the certificate was loaded from
+	keyPEM  []byte
+	stop    chan struct{}
+}
+
+// NewCertManager returns a CertManager that loads the certificate from certFile and keyFile, and
+// checks them for changes every interval once started. A zero interval never reloads them.
+func NewCertManager(certFile, keyFile string, interval time.Duration) (*CertManager, error) {
+	m := &CertManager{certFile: certFile, keyFile: keyFile, interval: interval}
+	if _, err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetCertificate returns the current certificate, it is meant for tls.Config.GetCertificate.
+func (m *CertManager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return m.cert.Load().(*tls.Certificate), nil
+}
+
+// Reload loads the certificate again when the files changed, and returns true if it did. On error the
+// current certificate is kept.
+func (m *CertManager) Reload() (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	certPEM, err := ioutil.ReadFile(m.certFile)
+	if err != nil {
+		return false, fmt.Errorf("could not load TLS cert: %s", err)
+	}
+	keyPEM, err := ioutil.ReadFile(m.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("could not load TLS cert: %s", err)
+	}
+	if bytes.Equal(certPEM, m.certPEM) && bytes.Equal(keyPEM, m.keyPEM) {
+		return false, nil
+	}
+	// The files may be caught in the middle of a rotation, with a key that doesn't match the
+	// certificate yet: the pair is checked again on the next reload.
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("could not load TLS cert: %s", err)
+	}
+	m.cert.Store(&cert)
+	m.certPEM, m.keyPEM = certPEM, keyPEM
+	return true, nil
+}
+
+// Start checks the files for changes every interval, until Stop.
+func (m *CertManager) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.interval <= 0 || m.stop != nil {
+		return nil
+	}
+	m.stop = make(chan struct{})
+	go m.watch(m.stop)
+	return nil
+}
+
+// Stop stops checking the files for changes.
+func (m *CertManager) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	return nil
+}
+
+func (m *CertManager) watch(stop chan struct{}) {
+	tick := time.NewTicker(m.interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+			changed, err := m.Reload()
+			if err != nil {
+				log.Errorf("Failed to reload the certificate %s: %s", m.certFile, err)
+				continue
+			}
+			if changed {
+				log.Infof("Reloaded the certificate %s", m.certFile)
+			}
+		}
+	}
+}
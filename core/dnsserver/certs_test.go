@@ -0,0 +1,125 @@
+package dnsserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate for name and its key to the cert.pem and key.pem files
+// of dir.
+func writeCert(t *testing.T, dir, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "cert.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func certName(t *testing.T, m *CertManager) string {
+	cert, err := m.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.Subject.CommonName
+}
+
+func TestCertManagerReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	if _, err := NewCertManager(certFile, keyFile, 0); err == nil {
+		t.Fatal("Expected an error without cert files")
+	}
+
+	writeCert(t, dir, "a.example.org")
+	m, err := NewCertManager(certFile, keyFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := certName(t, m); name != "a.example.org" {
+		t.Errorf("Expected the certificate of a.example.org, got %s", name)
+	}
+	if changed, err := m.Reload(); changed || err != nil {
+		t.Errorf("Expected no reload of unchanged files, got %t %v", changed, err)
+	}
+
+	writeCert(t, dir, "b.example.org")
+	if changed, err := m.Reload(); !changed || err != nil {
+		t.Errorf("Expected a reload of the changed files, got %t %v", changed, err)
+	}
+	if name := certName(t, m); name != "b.example.org" {
+		t.Errorf("Expected the certificate of b.example.org, got %s", name)
+	}
+
+	// A key that doesn't match the certificate keeps the current one.
+	key, _ := ioutil.ReadFile(keyFile)
+	writeCert(t, dir, "c.example.org")
+	ioutil.WriteFile(keyFile, key, 0600)
+	if _, err := m.Reload(); err == nil {
+		t.Error("Expected an error with a key that doesn't match the certificate")
+	}
+	if name := certName(t, m); name != "b.example.org" {
+		t.Errorf("Expected the certificate of b.example.org, got %s", name)
+	}
+}
+
+func TestCertManagerWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeCert(t, dir, "a.example.org")
+	m, err := NewCertManager(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	defer m.Stop()
+
+	writeCert(t, dir, "b.example.org")
+	for i := 0; i < 200 && certName(t, m) != "b.example.org"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if name := certName(t, m); name != "b.example.org" {
+		t.Errorf("Expected the certificate of b.example.org to be reloaded, got %s", name)
+	}
+}
@@ -0,0 +1,47 @@
+package dnsserver
+
+import (
+	"sync/atomic"
+
+	"github.com/coredns/coredns/plugin/metrics/vars"
+
+	"github.com/miekg/dns"
+)
+
+// concurrencyLimit caps the queries a server answers at once: the queries over the limit are shed,
+// answered with REFUSED or dropped, before any work is done for them.
+type concurrencyLimit struct {
+	max  int64
+	drop bool
+
+	n int64 // the queries in flight, accessed atomically
+}
+
+// acquire returns true if the query may be answered, release must then be called once it is. It is
+// always true for a nil l.
+func (l *concurrencyLimit) acquire() bool {
+	if l == nil {
+		return true
+	}
+	if atomic.AddInt64(&l.n, 1) > l.max {
+		atomic.AddInt64(&l.n, -1)
+		return false
+	}
+	return true
+}
+
+func (l *concurrencyLimit) release() {
+	if l != nil {
+		atomic.AddInt64(&l.n, -1)
+	}
+}
+
+// shed sheds the query r, over the limit of the server.
+func (l *concurrencyLimit) shed(server string, w dns.ResponseWriter, r *dns.Msg) {
+	if l.drop {
+		vars.ConcurrencyShed.WithLabelValues(server, "drop").Inc()
+		return
+	}
+	vars.ConcurrencyShed.WithLabelValues(server, "refuse").Inc()
+	errorAndMetricsFunc(server, w, r, dns.RcodeRefused, nil)
+}
@@ -0,0 +1,77 @@
+package dnsserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics/vars"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestServeDNSMaxConcurrent(t *testing.T) {
+	var started, release chan struct{}
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		if r.Question[0].Name == "slow.example.com." {
+			close(started)
+			<-release
+		}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+
+	for _, drop := range []bool{false, true} {
+		started, release = make(chan struct{}), make(chan struct{})
+		c := testConfig("dns", h)
+		c.MaxConcurrent = 1
+		c.ConcurrencyDrop = drop
+		const server = "127.0.0.1:5302"
+		s, err := NewServer(server, []*Config{c})
+		if err != nil {
+			t.Fatalf("Expected no error for NewServer, got %s", err)
+		}
+		action := "refuse"
+		if drop {
+			action = "drop"
+		}
+		shed := vars.ConcurrencyShed.WithLabelValues(server, action)
+		before := testutil.ToFloat64(shed)
+
+		query := func(qname string) *dns.Msg {
+			m := new(dns.Msg)
+			m.SetQuestion(qname, dns.TypeA)
+			rec := dnstest.NewRecorder(&test.ResponseWriter{})
+			s.ServeDNS(context.TODO(), rec, m)
+			return rec.Msg
+		}
+
+		done := make(chan *dns.Msg)
+		go func() { done <- query("slow.example.com.") }()
+		<-started
+
+		m := query("fast.example.com.")
+		if drop && m != nil {
+			t.Errorf("Expected the query over the limit to be dropped, got %v", m)
+		}
+		if !drop && (m == nil || m.Rcode != dns.RcodeRefused) {
+			t.Errorf("Expected the query over the limit to be refused, got %v", m)
+		}
+		if n := testutil.ToFloat64(shed) - before; n != 1 {
+			t.Errorf("Expected 1 query shed with %s, got %f", action, n)
+		}
+
+		close(release)
+		if m := <-done; m == nil || m.Rcode != dns.RcodeSuccess {
+			t.Errorf("Expected an answer to the query in flight, got %v", m)
+		}
+		if m := query("fast.example.com."); m == nil || m.Rcode != dns.RcodeSuccess {
+			t.Errorf("Expected an answer once the query in flight is answered, got %v", m)
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package dnsserver
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
+	"net/http"
+	"time"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
 
 	"github.com/caddyserver/caddy"
 )
@@ -37,19 +42,131 @@ type Config struct {
 	// on a non-octet boundary, i.e. /17
 	FilterFunc func(string) bool
 
+	// Filters, when not empty, select the queries of the server block, those all of them return true
+	// for. They make a view: server blocks for the same zone and listener, each with its own filters,
+	// answer different clients. The server blocks with filters are tried first, in order, the server
+	// block without, if any, answers the queries none of them selects.
+	Filters []Filter
+
+	// ViewName is the name of the view of the server block, unique among the server blocks for the
+	// same zone and listener. It is set in the context of the queries with ViewKey.
+	ViewName string
+
 	// TLSConfig when listening for encrypted connections (gRPC, DNS-over-TLS).
 	TLSConfig *tls.Config
 
+	// QueryTimeout is the time we have to answer a query, it is set as the query's deadline in the
+	// context, see plugin.Deadline. When zero DefaultQueryTimeout is used.
+	QueryTimeout time.Duration
+
+	// MaxQueryTime is the longest the plugins may take to answer a query: past it the context of the
+	// query is canceled and the client answered with SERVFAIL. It also caps the deadline of the query.
+	// When zero it is not enforced. The server blocks sharing a listener use the shortest time.
+	MaxQueryTime time.Duration
+
+	// MaxUDPSize is the size of the largest response sent over UDP. When not zero, the server avoids
+	// fragmented UDP responses: larger responses are truncated and the UDP socket is set to never
+	// fragment. The server blocks sharing a listener use the smallest size.
+	MaxUDPSize int
+
+	// XDPInterface is the name of the network interface the UDP queries are read from with AF_XDP
+	// sockets, skipping the kernel network stack. The first server block sharing a listener that sets
+	// it is used.
+	XDPInterface string
+
+	// UDPFilter, when not nil, is attached to the UDP socket to drop unwanted packets in the kernel.
+	// The server blocks sharing a listener use the filters of all of them.
+	UDPFilter *UDPFilter
+
+	// NUMA, when not nil, shards the UDP queries by NUMA node. The first server block sharing a
+	// listener that sets it is used.
+	NUMA *NUMA
+
+	// HTTPSPaths are the URL paths DNS-over-HTTPS queries are accepted on, the first is the
+	// canonical one. When empty doh.Path is used. The first server block sharing a listener that
+	// sets them is used.
+	HTTPSPaths []string
+
+	// HTTPOptions tunes the HTTP server of DNS-over-HTTPS queries. The first server block sharing a
+	// listener that sets it is used.
+	HTTPOptions *HTTPOptions
+
+	// HTTPHandlers are served by the http:// and https:// servers next to the DNS-over-HTTPS
+	// queries, keyed by their URL path, e.g. the health checks. The server blocks sharing a listener
+	// serve the handlers of all of them, the first one registering a path wins.
+	HTTPHandlers map[string]http.Handler
+
+	// ProxyProtocol are the networks of the trusted proxies: the TCP connections from them may start
+	// with a PROXY protocol header, whose source address is the client address seen by the plugins.
+	// When empty the header isn't read. The first server block sharing a listener that sets them is
+	// used.
+	ProxyProtocol []*net.IPNet
+
+	// MaxConcurrent, when not zero, is the most queries answered at once: the queries over it are
+	// answered with REFUSED, or dropped when ConcurrencyDrop is true. The server blocks sharing a
+	// listener use the smallest limit.
+	MaxConcurrent   int
+	ConcurrencyDrop bool
+
+	// Cookies, when not nil, answers the DNS cookies of the clients. The first server block sharing a
+	// listener that sets it is used.
+	Cookies *Cookies
+
+	// ReusePort is the number of sockets a dns:// server opens for each of its listeners, in the
+	// SO_REUSEPORT group of the listener, each with its own accept or read loop. When zero or one,
+	// only the listener is used. The first server block sharing a listener that sets it is used.
+	ReusePort int
+
+	// TsigSecret holds the base64 encoded secrets of the TSIG keys, keyed by their fully qualified
+	// lower cased name. The dns:// and tls:// servers verify the TSIG signed queries with them, and
+	// sign their replies. The server blocks sharing a listener use the keys of all of them.
+	TsigSecret map[string]string
+
+	// Updates is set by the plugins that apply dynamic updates (RFC 2136) to the zones of the server
+	// block. Without it, the server answers the updates for these zones with NOTIMP.
+	Updates bool
+
+	// GraceTimeout is the longest a stopping server waits for the in-flight queries to finish before
+	// the connections are closed. When zero GracefulTimeout is used. The server blocks sharing a
+	// listener use the longest.
+	GraceTimeout time.Duration
+
 	// Plugin stack.
 	Plugin []plugin.Plugin
 
+	// pluginNames holds the name of the directive that added each plugin in Plugin.
+	pluginNames []string
+
+	// Order overrides the order of the plugins in Plugin, see Order.
+	Order []Order
+
+	// Features holds the state of the feature gates set in this server block. The gates are
+	// global, see applyFeatures.
+	Features map[string]bool
+
 	// Compiled plugin stack.
 	pluginChain plugin.Handler
 
+	// metaCollector collects the metadata of a query before Filters are called, so they can use it.
+	metaCollector MetadataCollector
+
 	// Plugin interested in announcing that they exist, so other plugin can call methods
 	// on them should register themselves here. The name should be the name as return by the
 	// Handler's Name method.
 	registry map[string]plugin.Handler
+
+	// failures holds the setup errors recorded with Failure, a server block with failures answers
+	// all queries with SERVFAIL.
+	failures []error
+}
+
+// Filter returns true if the query state is for the server block. The context holds the metadata of
+// the query, when the metadata plugin is used in the server block.
+type Filter func(ctx context.Context, state *request.Request) bool
+
+// MetadataCollector is implemented by the metadata plugin, it collects the metadata of a query.
+type MetadataCollector interface {
+	Collect(ctx context.Context, state request.Request) context.Context
 }
 
 // keyForConfig build a key for identifying the configs during setup time
@@ -57,6 +174,28 @@ func keyForConfig(blocIndex int, blocKeyIndex int) string {
 	return fmt.Sprintf("%d:%d", blocIndex, blocKeyIndex)
 }
 
+// AddHTTPHandler registers h to be served on the URL path p by the http:// and https:// servers,
+// see HTTPHandlers. It has no effect on the servers of other transports.
+func (c *Config) AddHTTPHandler(p string, h http.Handler) {
+	if c.HTTPHandlers == nil {
+		c.HTTPHandlers = make(map[string]http.Handler)
+	}
+	c.HTTPHandlers[p] = h
+}
+
+// AddTsigKey adds the TSIG key name with secret, see TsigSecret. It is an error to add a key
+// twice with different secrets.
+func (c *Config) AddTsigKey(name, secret string) error {
+	if c.TsigSecret == nil {
+		c.TsigSecret = make(map[string]string)
+	}
+	if s, ok := c.TsigSecret[name]; ok && s != secret {
+		return fmt.Errorf("key %q is defined with different secrets", name)
+	}
+	c.TsigSecret[name] = secret
+	return nil
+}
+
 // GetConfig gets the Config that corresponds to c.
 // If none exist nil is returned.
 func GetConfig(c *caddy.Controller) *Config {
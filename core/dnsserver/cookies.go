@@ -0,0 +1,204 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/metrics/vars"
+	"github.com/coredns/coredns/plugin/pkg/rcode"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Cookies answers the DNS cookies of the clients (RFC 7873): a client sending a cookie gets a server
+// cookie back, only valid for its address, which proves on its next queries that the address isn't
+// spoofed. The server cookies are the ones of RFC 9018, with a SipHash-2-4 hash keyed by a secret that
+// may be rotated: the cookies made with the previous secret stay valid.
+type Cookies struct {
+	// Require answers the queries over UDP of the clients sending a cookie without a valid server
+	// cookie with BADCOOKIE, and a new server cookie to retry with. The clients sending no cookie
+	// at all are answered as usual.
+	Require bool
+
+	rotate time.Duration // 0 never rotates the secret
+
+	mu      sync.Mutex
+	secrets [2][]byte // the current and the previous secret
+	rotated time.Time
+	now     func() time.Time
+}
+
+const (
+	cookieVersion  = 1
+	cookieLifetime = time.Hour       // how long a server cookie is valid, see section 4.3 of RFC 9018
+	cookieSkew     = 5 * time.Minute // how far in the future a server cookie may be
+)
+
+// NewCookies returns Cookies rotating its random secret every rotate, or using secret when not nil,
+// which the servers of an anycast address share. A shared secret is never rotated.
+func NewCookies(secret []byte, rotate time.Duration, require bool) *Cookies {
+	c := &Cookies{Require: require, now: time.Now}
+	if secret != nil {
+		c.secrets[0] = secret
+		return c
+	}
+	c.rotate = rotate
+	c.secrets[0] = newSecret()
+	c.rotated = c.now()
+	return c
+}
+
+func newSecret() []byte {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return b
+}
+
+// keys returns the current and the previous secret, rotating them when due.
+func (c *Cookies) keys() [2][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rotate > 0 && c.now().Sub(c.rotated) >= c.rotate {
+		c.secrets[1], c.secrets[0] = c.secrets[0], newSecret()
+		c.rotated = c.now()
+	}
+	return c.secrets
+}
+
+// server returns the server cookie of client for ip, made with secret at t.
+func (c *Cookies) server(secret, client []byte, ip net.IP, t time.Time) []byte {
+	b := make([]byte, 8, 16)
+	b[0] = cookieVersion
+	binary.BigEndian.PutUint32(b[4:], uint32(t.Unix()))
+
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	// Hash = SipHash-2-4(Client Cookie | Version | Reserved | Timestamp | Client-IP, Server Secret),
+	// see section 4.4 of RFC 9018.
+	msg := make([]byte, 0, len(client)+len(b)+len(ip))
+	msg = append(append(append(msg, client...), b...), ip...)
+	return binary.LittleEndian.AppendUint64(b, sipHash24(secret, msg))
+}
+
+// valid returns true if cookie, a client cookie followed by a server cookie, was made by us for ip,
+// and has not expired.
+func (c *Cookies) valid(cookie []byte, ip net.IP) bool {
+	if len(cookie) != 24 || cookie[8] != cookieVersion {
+		return false
+	}
+	t := time.Unix(int64(binary.BigEndian.Uint32(cookie[12:16])), 0)
+	now := c.now()
+	if t.Before(now.Add(-cookieLifetime)) || t.After(now.Add(cookieSkew)) {
+		return false
+	}
+	for _, secret := range c.keys() {
+		if secret != nil && subtle.ConstantTimeCompare(cookie[8:], c.server(secret, cookie[:8], ip, t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// cookie returns the cookie of the client for ip: its client cookie followed by a new server cookie.
+func (c *Cookies) cookie(client []byte, ip net.IP) string {
+	keys := c.keys()
+	return hex.EncodeToString(append(client[:8:8], c.server(keys[0], client[:8], ip, c.now())...))
+}
+
+// clientCookie returns the cookie of r, nil when it has none.
+func clientCookie(r *dns.Msg) []byte {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		if e, ok := o.(*dns.EDNS0_COOKIE); ok {
+			b, err := hex.DecodeString(e.Cookie)
+			if err != nil || len(b) < 8 {
+				return nil
+			}
+			return b
+		}
+	}
+	return nil
+}
+
+// check checks the cookie of r, which passed sanitize.Request. It returns the client cookie, nil when
+// r has none, and false when it answered r with BADCOOKIE.
+func (c *Cookies) check(server string, w dns.ResponseWriter, r *dns.Msg) ([]byte, bool) {
+	if c == nil {
+		return nil, true
+	}
+	cookie := clientCookie(r)
+	if cookie == nil {
+		return nil, true
+	}
+	state := request.Request{W: w, Req: r}
+	if !c.Require || state.Proto() != "udp" || c.valid(cookie, net.ParseIP(state.IP())) {
+		return cookie, true
+	}
+
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeBadCookie)
+	state.SizeAndDo(m)
+	c.setCookie(m, cookie, net.ParseIP(state.IP()))
+	vars.Report(server, state, vars.Dropped, rcode.ToString(dns.RcodeBadCookie), m.Len(), time.Now())
+	w.WriteMsg(m)
+	return cookie, false
+}
+
+// setCookie sets the cookie of the client for ip in the OPT record of m, adding one when m has none.
+func (c *Cookies) setCookie(m *dns.Msg, client []byte, ip net.IP) {
+	opt := m.IsEdns0()
+	if opt == nil {
+		opt = &dns.OPT{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT}}
+		opt.SetUDPSize(dns.MinMsgSize)
+		m.Extra = append(m.Extra, opt)
+	}
+	e := &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: c.cookie(client, ip)}
+	for i, o := range opt.Option {
+		if o.Option() == dns.EDNS0COOKIE {
+			opt.Option[i] = e
+			return
+		}
+	}
+	opt.Option = append(opt.Option, e)
+}
+
+// cookieWriter sets the cookie of the client in the responses.
+type cookieWriter struct {
+	dns.ResponseWriter
+	cookies *Cookies
+	client  []byte
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *cookieWriter) WriteMsg(m *dns.Msg) error {
+	w.setCookie(m)
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+func (w *cookieWriter) setCookie(m *dns.Msg) {
+	state := request.Request{W: w.ResponseWriter}
+	w.cookies.setCookie(m, w.client, net.ParseIP(state.IP()))
+}
+
+// cookieKey is the context key of the cookieWriter of a query.
+type cookieKey struct{}
+
+// SetCookie sets the server cookie in m, the response to the query of ctx, if the client sent a cookie.
+// The server sets it anyway when m is written, replacing the one set here by one of the same size; this
+// is for the plugins that need the response as it is sent, like padding.
+func SetCookie(ctx context.Context, m *dns.Msg) {
+	if w, ok := ctx.Value(cookieKey{}).(*cookieWriter); ok {
+		w.setCookie(m)
+	}
+}
@@ -0,0 +1,140 @@
+package dnsserver
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestCookiesValid(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	c := NewCookies(nil, time.Hour, true)
+	c.now = func() time.Time { return now }
+
+	client := []byte("\x01\x02\x03\x04\x05\x06\x07\x08")
+	ip := net.ParseIP("192.0.2.1")
+	cookie, _ := hex.DecodeString(c.cookie(client, ip))
+
+	if !c.valid(cookie, ip) {
+		t.Error("Expected the cookie to be valid")
+	}
+	if c.valid(cookie, net.ParseIP("192.0.2.2")) {
+		t.Error("Expected the cookie not to be valid for another address")
+	}
+	cookie[0]++
+	if c.valid(cookie, ip) {
+		t.Error("Expected the cookie not to be valid for another client cookie")
+	}
+	cookie[0]--
+
+	// The cookies made with the previous secret stay valid, until they expire.
+	now = now.Add(59 * time.Minute)
+	c.keys()
+	now = now.Add(time.Minute)
+	if !c.valid(cookie, ip) {
+		t.Error("Expected the cookie to be valid after a rotation")
+	}
+	now = now.Add(time.Second)
+	if c.valid(cookie, ip) {
+		t.Error("Expected the cookie to have expired")
+	}
+
+	// A shared secret is never rotated, so the servers sharing it make the same cookies.
+	secret := []byte("0123456789abcdef")
+	a, b := NewCookies(secret, time.Hour, true), NewCookies(secret, 0, true)
+	a.now, b.now = c.now, c.now
+	a.cookie(client, ip)
+	now = now.Add(2 * time.Hour)
+	cookie, _ = hex.DecodeString(a.cookie(client, ip))
+	if !b.valid(cookie, ip) {
+		t.Error("Expected the cookie of a server sharing the secret to be valid")
+	}
+}
+
+func TestCookiesRFC9018(t *testing.T) {
+	// The server cookie of the example in appendix A.1 of RFC 9018.
+	secret, _ := hex.DecodeString("e5e973e5a6b2a43f48e7dc849e37bfcf")
+	c := NewCookies(secret, 0, true)
+	c.now = func() time.Time { return time.Unix(1559731985, 0) }
+
+	client, _ := hex.DecodeString("2464c4abcf10c957")
+	expected := "2464c4abcf10c957010000005cf79f111f8130c3eee29480"
+	if x := c.cookie(client, net.ParseIP("198.51.100.100")); x != expected {
+		t.Errorf("Expected cookie %s, got %s", expected, x)
+	}
+}
+
+func TestServerCookies(t *testing.T) {
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	c := testConfig("dns", h)
+	c.Cookies = NewCookies(nil, 0, true)
+	s, err := NewServer("127.0.0.1:53", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := func(cookie string, tcp bool) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		m.SetEdns0(4096, false)
+		if cookie != "" {
+			opt := m.IsEdns0()
+			opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: cookie})
+		}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{TCP: tcp})
+		s.ServeDNS(context.TODO(), rec, m)
+		return rec.Msg
+	}
+	serverCookie := func(m *dns.Msg) string {
+		if opt := m.IsEdns0(); opt != nil {
+			for _, o := range opt.Option {
+				if e, ok := o.(*dns.EDNS0_COOKIE); ok {
+					return e.Cookie
+				}
+			}
+		}
+		return ""
+	}
+
+	if m := query("", false); m.Rcode != dns.RcodeSuccess || serverCookie(m) != "" {
+		t.Errorf("Expected an answer without cookie to a client sending none, got %v", m)
+	}
+
+	const client = "0102030405060708"
+	m := query(client, false)
+	if m.Rcode != dns.RcodeBadCookie {
+		t.Fatalf("Expected BADCOOKIE to a client cookie alone, got %s", dns.RcodeToString[m.Rcode])
+	}
+	cookie := serverCookie(m)
+	if len(cookie) != 48 || cookie[:16] != client {
+		t.Fatalf("Expected the client cookie followed by a server cookie, got %q", cookie)
+	}
+
+	m = query(cookie, false)
+	if m.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected an answer to a valid cookie, got %s", dns.RcodeToString[m.Rcode])
+	}
+	if c := serverCookie(m); len(c) != 48 || c[:16] != client {
+		t.Errorf("Expected a server cookie in the answer, got %q", c)
+	}
+
+	if m := query(client+"0100000000000000ffffffffffffffff", false); m.Rcode != dns.RcodeBadCookie {
+		t.Errorf("Expected BADCOOKIE to an invalid server cookie, got %s", dns.RcodeToString[m.Rcode])
+	}
+	if m := query(client, true); m.Rcode != dns.RcodeSuccess || serverCookie(m) == "" {
+		t.Errorf("Expected an answer with a server cookie over TCP, got %v", m)
+	}
+}
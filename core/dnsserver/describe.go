@@ -0,0 +1,65 @@
+package dnsserver
+
+import (
+	"sort"
+
+	"github.com/caddyserver/caddy"
+)
+
+// ServerInfo describes a server and the server blocks it serves.
+type ServerInfo struct {
+	Address  string      `json:"address"`             // i.e. dns://:53
+	DoHPaths []string    `json:"doh_paths,omitempty"` // the URL paths of DoH queries, for http:// and https:// servers
+	Blocks   []BlockInfo `json:"blocks"`
+}
+
+// BlockInfo describes a server block.
+type BlockInfo struct {
+	Key         string   `json:"key"` // i.e. dns://example.org.:53
+	Zone        string   `json:"zone"`
+	View        string   `json:"view,omitempty"` // the name of the view of the server block, if any
+	Transport   string   `json:"transport"`
+	Port        string   `json:"port"`
+	ListenHosts []string `json:"listen_hosts,omitempty"` // addresses set with the bind plugin
+	Plugins     []string `json:"plugins"`                // the plugin chain, in the order the plugins are called
+}
+
+// Describe returns a description of the servers of instance i, sorted by address.
+func Describe(i *caddy.Instance) []ServerInfo {
+	infos := []ServerInfo{}
+	for _, srv := range Servers(i) {
+		ds, ok := srv.(interface{ dnsServer() *Server })
+		if !ok {
+			continue
+		}
+		s := ds.dnsServer()
+		info := ServerInfo{Address: s.Addr, Blocks: []BlockInfo{}}
+		switch srv.(type) {
+		case *ServerHTTPS, *ServerHTTP:
+			info.DoHPaths = append([]string{}, s.dohPaths...)
+		}
+		for _, configs := range s.zones {
+			for _, c := range configs {
+				var hosts []string
+				for _, h := range c.ListenHosts {
+					if h != "" {
+						hosts = append(hosts, h)
+					}
+				}
+				info.Blocks = append(info.Blocks, BlockInfo{
+					Key:         c.Key(),
+					Zone:        c.Zone,
+					View:        c.ViewName,
+					Transport:   c.Transport,
+					Port:        c.Port,
+					ListenHosts: hosts,
+					Plugins:     append([]string{}, c.pluginNames...),
+				})
+			}
+		}
+		sort.Slice(info.Blocks, func(i, j int) bool { return info.Blocks[i].Key < info.Blocks[j].Key })
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Address < infos[j].Address })
+	return infos
+}
@@ -0,0 +1,36 @@
+package dnsserver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestDescribe(t *testing.T) {
+	c := &Config{Zone: "example.org.", Transport: "dns", Port: "1053", ListenHosts: []string{""}}
+	c.AddPlugin(func(next plugin.Handler) plugin.Handler { return chainHandler{name: "first", next: next} })
+	c.AddPlugin(func(next plugin.Handler) plugin.Handler { return chainHandler{name: "second", next: next} })
+	c.pluginNames = []string{"first", "second"}
+	s, err := NewServer("dns://:1053", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	i := &caddy.Instance{Storage: map[interface{}]interface{}{serversKey{}: []caddy.Server{s}}}
+
+	expected := []ServerInfo{{
+		Address: "dns://:1053",
+		Blocks: []BlockInfo{{
+			Key:       "dns://example.org.:1053",
+			Zone:      "example.org.",
+			Transport: "dns",
+			Port:      "1053",
+			Plugins:   []string{"first", "second"},
+		}},
+	}}
+	if x := Describe(i); !reflect.DeepEqual(x, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, x)
+	}
+}
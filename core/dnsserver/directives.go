@@ -0,0 +1,34 @@
+package dnsserver
+
+import "fmt"
+
+// RegisterDirective adds the directive name to Directives, this allows plugins that are not listed
+// in plugin.cfg to be used without regenerating zdirectives.go. The directive is inserted directly
+// before the directive before, making the plugin execute just before that plugin. If before is
+// empty the directive is added to the end of the list.
+//
+// RegisterDirective must be called from an init function, as the list of directives can not change
+// once the Corefile has been parsed. It panics if name is already registered or if before can not
+// be found.
+func RegisterDirective(name, before string) {
+	if name == "" {
+		panic("directive name cannot be empty")
+	}
+	for _, d := range Directives {
+		if d == name {
+			panic(fmt.Sprintf("directive %q already registered", name))
+		}
+	}
+
+	if before == "" {
+		Directives = append(Directives, name)
+		return
+	}
+	for i, d := range Directives {
+		if d == before {
+			Directives = append(Directives[:i], append([]string{name}, Directives[i:]...)...)
+			return
+		}
+	}
+	panic(fmt.Sprintf("directive %q not found, can not register %q before it", before, name))
+}
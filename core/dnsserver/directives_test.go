@@ -0,0 +1,38 @@
+package dnsserver
+
+import "testing"
+
+func TestRegisterDirective(t *testing.T) {
+	defer func(d []string) { Directives = d }(Directives)
+	Directives = []string{"log", "forward"}
+
+	RegisterDirective("example", "forward")
+	RegisterDirective("last", "")
+
+	expected := []string{"log", "example", "forward", "last"}
+	if len(Directives) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, Directives)
+	}
+	for i := range expected {
+		if Directives[i] != expected[i] {
+			t.Errorf("Expected %s at position %d, got %s", expected[i], i, Directives[i])
+		}
+	}
+
+	for i, test := range []struct {
+		name, before string
+	}{
+		{"", "forward"},
+		{"example", "forward"},
+		{"other", "nothere"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Test %d: Expected panic, got none", i)
+				}
+			}()
+			RegisterDirective(test.name, test.before)
+		}()
+	}
+}
@@ -0,0 +1,70 @@
+package dnsserver
+
+import (
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/miekg/dns"
+)
+
+// Fragmented UDP responses are easily lost and can be spoofed, see draft-ietf-dnsop-avoid-fragmentation.
+// When MaxUDPSize is set, the server avoids them: the UDP buffer size of the queries is clamped to
+// MaxUDPSize, so larger responses are truncated and the client retries over TCP, and the UDP socket
+// is set to never fragment. A response that is still too large for the path to the client isn't sent
+// fragmented, the client gets an empty truncated response instead, see dontFragWriter.
+
+// clampUDPSize lowers the UDP buffer size advertised in the OPT record of r to max. The response
+// gets the same buffer size in its OPT record, see request.SizeAndDo, and is truncated to it.
+func clampUDPSize(r *dns.Msg, max int) {
+	if o := r.IsEdns0(); o != nil && int(o.UDPSize()) > max {
+		o.SetUDPSize(uint16(max))
+	}
+}
+
+// setDontFragment sets or clears the don't fragment option of u, and logs when that fails.
+func setDontFragment(u *net.UDPConn, on bool) {
+	rc, err := u.SyscallConn()
+	if err == nil {
+		rc.Control(func(fd uintptr) { err = dontFragment(fd, isIPv4(u), on) })
+	}
+	if err != nil && on {
+		log.Warningf("Failed to set the don't fragment option on %s: %s", u.LocalAddr(), err)
+	}
+}
+
+func isIPv4(u *net.UDPConn) bool {
+	a, ok := u.LocalAddr().(*net.UDPAddr)
+	return ok && a.IP.To4() != nil
+}
+
+// dontFragWriter writes the responses over a UDP socket with the don't fragment option. When the
+// response is too large for the path to the client, it writes the response again truncated to the
+// minimum size, which is never fragmented, with the TC bit set.
+type dontFragWriter struct {
+	dns.ResponseWriter
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w dontFragWriter) WriteMsg(m *dns.Msg) error {
+	err := w.ResponseWriter.WriteMsg(m)
+	if !isMsgSize(err) {
+		return err
+	}
+	m.Truncate(dns.MinMsgSize)
+	m.Truncated = true
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+// isMsgSize returns true if err means the message is too large to send unfragmented.
+func isMsgSize(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+	return err == syscall.EMSGSIZE
+}
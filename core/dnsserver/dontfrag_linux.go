@@ -0,0 +1,20 @@
+// +build linux
+
+package dnsserver
+
+import "golang.org/x/sys/unix"
+
+// dontFragment sets or clears the don't fragment option on the socket fd. Linux has no IP_DONTFRAG,
+// IP_PMTUDISC_DO does the same: it sets the DF bit and makes sending a packet larger than the path
+// MTU fail with EMSGSIZE. An IPv6 socket may also carry IPv4 traffic, both options are set on it.
+func dontFragment(fd uintptr, ipv4, on bool) error {
+	pmtudisc, dontfrag := unix.IP_PMTUDISC_WANT, 0
+	if on {
+		pmtudisc, dontfrag = unix.IP_PMTUDISC_DO, 1
+	}
+	err := unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, pmtudisc)
+	if ipv4 {
+		return err
+	}
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_DONTFRAG, dontfrag)
+}
@@ -0,0 +1,31 @@
+// +build linux
+
+package dnsserver
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSetDontFragment(t *testing.T) {
+	u, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+
+	for _, on := range []bool{true, false} {
+		setDontFragment(u, on)
+		rc, _ := u.SyscallConn()
+		var v int
+		rc.Control(func(fd uintptr) { v, err = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := map[bool]int{true: unix.IP_PMTUDISC_DO, false: unix.IP_PMTUDISC_WANT}[on]; v != expected {
+			t.Errorf("Expected IP_MTU_DISCOVER %d for %t, got %d", expected, on, v)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+// +build !linux
+
+package dnsserver
+
+import "errors"
+
+// dontFragment is only implemented on Linux.
+func dontFragment(fd uintptr, ipv4, on bool) error {
+	if on {
+		return errors.New("not supported on this platform")
+	}
+	return nil
+}
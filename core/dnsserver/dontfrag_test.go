@@ -0,0 +1,126 @@
+package dnsserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestClampUDPSize(t *testing.T) {
+	tests := []struct {
+		size     uint16 // 0 is no OPT record
+		expected uint16
+	}{
+		{0, 0},
+		{512, 512},
+		{1232, 1232},
+		{4096, 1232},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		if tc.size > 0 {
+			m.SetEdns0(tc.size, false)
+		}
+		clampUDPSize(m, 1232)
+		size := uint16(0)
+		if o := m.IsEdns0(); o != nil {
+			size = o.UDPSize()
+		}
+		if size != tc.expected {
+			t.Errorf("Test %d: expected size %d, got %d", i, tc.expected, size)
+		}
+	}
+}
+
+// msgSizeWriter fails to write messages larger than size with EMSGSIZE.
+type msgSizeWriter struct {
+	test.ResponseWriter
+	size    int
+	written *dns.Msg
+}
+
+func (w *msgSizeWriter) WriteMsg(m *dns.Msg) error {
+	if m.Len() > w.size {
+		return &net.OpError{Op: "write", Net: "udp", Err: os.NewSyscallError("sendmsg", syscall.EMSGSIZE)}
+	}
+	w.written = m
+	return nil
+}
+
+func TestDontFragWriter(t *testing.T) {
+	tests := []struct {
+		answers   int
+		truncated bool
+	}{
+		{1, false},
+		{100, true},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		for j := 0; j < tc.answers; j++ {
+			m.Answer = append(m.Answer, test.A(fmt.Sprintf("example.org. 3600 IN A 127.0.0.%d", j)))
+		}
+		mw := &msgSizeWriter{size: 1400}
+		if err := (dontFragWriter{mw}).WriteMsg(m); err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if mw.written == nil || mw.written.Truncated != tc.truncated {
+			t.Errorf("Test %d: expected a response with truncated %t, got %v", i, tc.truncated, mw.written)
+			continue
+		}
+		if tc.truncated && mw.written.Len() > dns.MinMsgSize {
+			t.Errorf("Test %d: expected a response of at most %d bytes, got %d", i, dns.MinMsgSize, mw.written.Len())
+		}
+	}
+}
+
+func TestServePacketMaxUDPSize(t *testing.T) {
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		for i := 0; i < 100; i++ {
+			m.Answer = append(m.Answer, test.A(fmt.Sprintf("%s 3600 IN A 127.0.0.%d", r.Question[0].Name, i)))
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	c := testConfig("dns", h)
+	c.Zone = "."
+	c.MaxUDPSize = 1232
+	s, err := NewServer("127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServePacket(pc)
+	defer s.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.SetEdns0(4096, false)
+	client := &dns.Client{UDPSize: 4096}
+	resp, _, err := client.Exchange(m, pc.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Expected a response, got %s", err)
+	}
+	if !resp.Truncated {
+		t.Errorf("Expected a truncated response")
+	}
+	if o := resp.IsEdns0(); o == nil || o.UDPSize() != 1232 {
+		t.Errorf("Expected an OPT record with size 1232, got %v", o)
+	}
+}
@@ -0,0 +1,293 @@
+package dnsserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/changes"
+	"github.com/coredns/coredns/plugin/pkg/feature"
+
+	"github.com/miekg/dns"
+)
+
+// dsoGate is the feature gate for DNS Stateful Operations, see enableDSO.
+var dsoGate = feature.Register("dso", "Establish DNS Stateful Operations (RFC 8490) sessions on TCP and TLS connections.", false)
+
+// DNS Stateful Operations (DSO, RFC 8490) turn a TCP or TLS connection into a session: a client
+// establishes it with a Keepalive request, and the server then keeps the connection open for at
+// least the inactivity timeout it returns, instead of closing it after the idle timeout. This is the
+// foundation for long-lived operations like DNS push notifications, see push.go.
+//
+// A DSO message has the usual header with all counts zero, followed by TLVs: a 16 bit type, a 16 bit
+// length and the data. The first TLV is the primary TLV, it determines what the message is for.
+// dns.Msg has no room for TLVs, so the raw messages are taken from the connection, see dsoReader.
+
+const (
+	opcodeDSO = 6
+
+	// DSO TLV types, RFC 8490, section 10.3, and RFC 8765, section 6.
+	dsoKeepalive   = 1
+	dsoRetryDelay  = 2
+	dsoPadding     = 3
+	dsoSubscribe   = 0x40
+	dsoPush        = 0x41
+	dsoUnsubscribe = 0x42
+
+	// rcodeDSOTypeNI is the rcode for a DSO request with an unknown primary TLV.
+	rcodeDSOTypeNI = 11
+)
+
+// The timeouts the server returns in a Keepalive response, RFC 8490, section 7.1. A client closes
+// its session after the inactivity timeout without outstanding operations, and sends traffic at
+// least every keepalive interval.
+var (
+	dsoInactivityTimeout = 15 * time.Second
+	dsoKeepaliveInterval = time.Hour
+)
+
+// enableDSO makes srv, a TCP or TLS server of s, answer DSO messages when the dso feature gate is
+// enabled. The connections may then live long, so srv no longer closes them after a number of
+// queries. The returned function stops following the changes of the zones for push notifications.
+func enableDSO(srv *dns.Server, s *Server) (stop func()) {
+	if !dsoGate.Enabled() {
+		return func() {}
+	}
+	d := &dsoSessions{s: s, conns: map[string]*dsoConn{}, pending: map[string]bool{}}
+	next := srv.Handler
+	srv.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Opcode == opcodeDSO {
+			d.serve(w, r)
+			return
+		}
+		if c := d.conn(w.RemoteAddr().String()); c != nil {
+			// Push notifications are written to this connection as well.
+			w = &lockedWriter{ResponseWriter: w, mu: &c.wmu}
+		}
+		next.ServeDNS(w, r)
+	})
+	srv.MsgAcceptFunc = dsoAcceptFunc
+	srv.DecorateReader = func(r dns.Reader) dns.Reader { return dsoReader{r, d} }
+	srv.MaxTCPQueries = -1
+	return changes.Watch(d.changed)
+}
+
+// dsoAcceptFunc accepts the DSO requests without records, and leaves the other messages to
+// acceptFunc.
+func dsoAcceptFunc(dh dns.Header) dns.MsgAcceptAction {
+	if int(dh.Bits>>11)&0xF != opcodeDSO {
+		return acceptFunc(dh)
+	}
+	if dh.Bits&(1<<15) != 0 {
+		return dns.MsgIgnore // the server sends no requests, so it expects no responses
+	}
+	if dh.Qdcount != 0 || dh.Ancount != 0 || dh.Nscount != 0 || dh.Arcount != 0 {
+		return dns.MsgReject
+	}
+	return dns.MsgAccept
+}
+
+// dsoSessions holds the state of the connections of a server that sent DSO messages, keyed by the
+// remote address.
+type dsoSessions struct {
+	s *Server
+
+	mu      sync.Mutex
+	conns   map[string]*dsoConn
+	pending map[string]bool // zones that changed, see changed
+	pushing bool            // pushChanges is running
+}
+
+type dsoConn struct {
+	w           dns.ResponseWriter // the writer of the connection, for push notifications
+	msg         []byte             // the DSO message being handled
+	established bool               // a session is established
+	subs        map[uint16]*subscription
+
+	wmu sync.Mutex // serializes writing to the connection
+	pmu sync.Mutex // serializes the push notifications
+}
+
+func (d *dsoSessions) conn(key string) *dsoConn {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.conns[key]
+}
+
+// dsoReader reads the messages of a connection. It keeps the raw DSO messages for dsoSessions.serve,
+// and keeps a connection with a session open for longer than the idle timeout.
+type dsoReader struct {
+	dns.Reader
+	d *dsoSessions
+}
+
+// ReadTCP implements the dns.Reader interface.
+func (r dsoReader) ReadTCP(conn net.Conn, timeout time.Duration) ([]byte, error) {
+	key := conn.RemoteAddr().String()
+	r.d.mu.Lock()
+	if c, ok := r.d.conns[key]; ok && c.established {
+		// The client should close the session after the inactivity timeout, give it twice that,
+		// and at least 5 seconds, before closing it, see RFC 8490, section 6.2.
+		timeout = 2 * dsoInactivityTimeout
+		if timeout < 5*time.Second {
+			timeout = 5 * time.Second
+		}
+	}
+	r.d.mu.Unlock()
+
+	m, err := r.Reader.ReadTCP(conn, timeout)
+	if err != nil {
+		r.d.remove(key)
+		return m, err
+	}
+	if len(m) >= 12 && int(m[2]>>3)&0xF == opcodeDSO {
+		r.d.mu.Lock()
+		c, ok := r.d.conns[key]
+		if !ok {
+			c = &dsoConn{subs: map[uint16]*subscription{}}
+			r.d.conns[key] = c
+		}
+		c.msg = m
+		r.d.mu.Unlock()
+	}
+	return m, nil
+}
+
+func (d *dsoSessions) remove(key string) {
+	d.mu.Lock()
+	delete(d.conns, key)
+	d.mu.Unlock()
+}
+
+// serve handles the DSO request r.
+func (d *dsoSessions) serve(w dns.ResponseWriter, r *dns.Msg) {
+	key := w.RemoteAddr().String()
+	d.mu.Lock()
+	c, ok := d.conns[key]
+	var m []byte
+	if ok {
+		m, c.msg = c.msg, nil
+		c.w = w
+	}
+	d.mu.Unlock()
+	if m == nil {
+		return
+	}
+
+	tlvs, err := parseTLVs(m[12:])
+	if r.Id == 0 {
+		// A unidirectional message, the only one a client sends is Unsubscribe. Anything else is a
+		// fatal error that ends the connection, RFC 8490, section 5.4.5.
+		if err == nil && len(tlvs) > 0 && tlvs[0].typ == dsoUnsubscribe && len(tlvs[0].data) == 2 {
+			d.mu.Lock()
+			delete(c.subs, binary.BigEndian.Uint16(tlvs[0].data))
+			d.mu.Unlock()
+			return
+		}
+		d.remove(key)
+		w.Close()
+		return
+	}
+	if err != nil || len(tlvs) == 0 {
+		c.write(w, r.Id, dns.RcodeFormatError)
+		return
+	}
+
+	switch primary := tlvs[0]; primary.typ {
+	case dsoKeepalive:
+		if len(primary.data) != 8 {
+			c.write(w, r.Id, dns.RcodeFormatError)
+			return
+		}
+		d.mu.Lock()
+		c.established = true
+		d.mu.Unlock()
+		c.write(w, r.Id, dns.RcodeSuccess, keepaliveTLV(dsoInactivityTimeout, dsoKeepaliveInterval))
+	case dsoSubscribe:
+		d.subscribe(c, r.Id, primary.data)
+	case dsoRetryDelay, dsoPadding, dsoPush, dsoUnsubscribe:
+		// Retry Delay and Push are only sent by servers, Padding is never a primary TLV and
+		// Unsubscribe is unidirectional.
+		c.write(w, r.Id, dns.RcodeFormatError)
+	default:
+		c.write(w, r.Id, rcodeDSOTypeNI)
+	}
+}
+
+type tlv struct {
+	typ  uint16
+	data []byte
+}
+
+var errTLV = errors.New("dso: truncated TLV")
+
+// parseTLVs returns the TLVs in b.
+func parseTLVs(b []byte) ([]tlv, error) {
+	var tlvs []tlv
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, errTLV
+		}
+		typ, l := binary.BigEndian.Uint16(b), int(binary.BigEndian.Uint16(b[2:]))
+		if len(b) < 4+l {
+			return nil, errTLV
+		}
+		tlvs = append(tlvs, tlv{typ: typ, data: b[4 : 4+l]})
+		b = b[4+l:]
+	}
+	return tlvs, nil
+}
+
+// keepaliveTLV returns a Keepalive TLV with the inactivity timeout and keepalive interval in
+// milliseconds.
+func keepaliveTLV(inactivity, keepalive time.Duration) tlv {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data, uint32(inactivity/time.Millisecond))
+	binary.BigEndian.PutUint32(data[4:], uint32(keepalive/time.Millisecond))
+	return tlv{typ: dsoKeepalive, data: data}
+}
+
+// write writes a DSO response with the message ID id, the rcode rcode and the TLVs tlvs to w, the
+// writer of c.
+func (c *dsoConn) write(w dns.ResponseWriter, id uint16, rcode int, tlvs ...tlv) error {
+	b := packDSO(id, 1<<15|uint16(rcode&0xF), tlvs...)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := w.Write(b)
+	return err
+}
+
+// packDSO returns a DSO message with the message ID id, the flags flags, next to the opcode, and
+// the TLVs tlvs.
+func packDSO(id, flags uint16, tlvs ...tlv) []byte {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b, id)
+	binary.BigEndian.PutUint16(b[2:], flags|opcodeDSO<<11)
+	for _, t := range tlvs {
+		b = append(b, byte(t.typ>>8), byte(t.typ), byte(len(t.data)>>8), byte(len(t.data)))
+		b = append(b, t.data...)
+	}
+	return b
+}
+
+// lockedWriter writes the responses to a connection that push notifications are written to.
+type lockedWriter struct {
+	dns.ResponseWriter
+	mu *sync.Mutex
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *lockedWriter) WriteMsg(m *dns.Msg) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *lockedWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,183 @@
+package dnsserver
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/feature"
+
+	"github.com/miekg/dns"
+)
+
+// dsoRequest returns a DSO request with the message ID id and the TLVs tlvs.
+func dsoRequest(id uint16, tlvs ...tlv) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint16(b, id)
+	binary.BigEndian.PutUint16(b[2:], opcodeDSO<<11)
+	for _, t := range tlvs {
+		b = append(b, byte(t.typ>>8), byte(t.typ), byte(len(t.data)>>8), byte(len(t.data)))
+		b = append(b, t.data...)
+	}
+	return b
+}
+
+func TestParseTLVs(t *testing.T) {
+	tests := []struct {
+		b         []byte
+		expected  int
+		shouldErr bool
+	}{
+		{nil, 0, false},
+		{[]byte{0, 1, 0, 0}, 1, false},
+		{[]byte{0, 1, 0, 2, 1, 2, 0, 3, 0, 0}, 2, false},
+		{[]byte{0, 1, 0}, 0, true},
+		{[]byte{0, 1, 0, 2, 1}, 0, true},
+	}
+	for i, tc := range tests {
+		tlvs, err := parseTLVs(tc.b)
+		if (err != nil) != tc.shouldErr {
+			t.Errorf("Test %d: expected error %t, got %v", i, tc.shouldErr, err)
+		}
+		if len(tlvs) != tc.expected {
+			t.Errorf("Test %d: expected %d TLVs, got %d", i, tc.expected, len(tlvs))
+		}
+	}
+}
+
+func TestDSO(t *testing.T) {
+	tests := []struct {
+		enabled bool
+		req     []byte
+		rcode   int
+		tlvs    []tlv
+	}{
+		{true, dsoRequest(1, keepaliveTLV(time.Minute, time.Hour)), dns.RcodeSuccess, []tlv{keepaliveTLV(dsoInactivityTimeout, dsoKeepaliveInterval)}},
+		{true, dsoRequest(2, keepaliveTLV(time.Minute, time.Hour), tlv{typ: dsoPadding, data: make([]byte, 4)}), dns.RcodeSuccess, []tlv{keepaliveTLV(dsoInactivityTimeout, dsoKeepaliveInterval)}},
+		{true, dsoRequest(3, tlv{typ: dsoKeepalive, data: []byte{1}}), dns.RcodeFormatError, nil},
+		{true, dsoRequest(4, tlv{typ: dsoRetryDelay, data: make([]byte, 4)}), dns.RcodeFormatError, nil},
+		{true, dsoRequest(5, tlv{typ: 0xf000}), rcodeDSOTypeNI, nil},
+		{true, dsoRequest(6), dns.RcodeFormatError, nil},
+		{false, dsoRequest(7, keepaliveTLV(time.Minute, time.Hour)), dns.RcodeNotImplemented, nil},
+	}
+
+	for i, tc := range tests {
+		s, conn := startDSOServer(t, tc.enabled, reply)
+		if _, err := conn.Write(append([]byte{0, byte(len(tc.req))}, tc.req...)); err != nil {
+			t.Fatal(err)
+		}
+		res := readTCP(t, conn)
+		if id := binary.BigEndian.Uint16(res); id != binary.BigEndian.Uint16(tc.req) {
+			t.Errorf("Test %d: expected ID %d, got %d", i, binary.BigEndian.Uint16(tc.req), id)
+		}
+		flags := binary.BigEndian.Uint16(res[2:])
+		if flags&(1<<15) == 0 || int(flags>>11)&0xF != opcodeDSO {
+			t.Errorf("Test %d: expected a DSO response, got flags %x", i, flags)
+		}
+		if rcode := int(flags & 0xF); rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.rcode, rcode)
+		}
+		tlvs, err := parseTLVs(res[12:])
+		if err != nil || len(tlvs) != len(tc.tlvs) {
+			t.Errorf("Test %d: expected %d TLVs, got %v (%v)", i, len(tc.tlvs), tlvs, err)
+		}
+		for j := range tlvs {
+			if j < len(tc.tlvs) && (tlvs[j].typ != tc.tlvs[j].typ || string(tlvs[j].data) != string(tc.tlvs[j].data)) {
+				t.Errorf("Test %d: expected TLV %v, got %v", i, tc.tlvs[j], tlvs[j])
+			}
+		}
+
+		// The connection can still be used for queries.
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		if err := (&dns.Conn{Conn: conn}).WriteMsg(m); err != nil {
+			t.Fatal(err)
+		}
+		r := new(dns.Msg)
+		if err := r.Unpack(readTCP(t, conn)); err != nil || r.Id != m.Id {
+			t.Errorf("Test %d: expected a response to the query, got %v (%v)", i, r, err)
+		}
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestDSOUnidirectional(t *testing.T) {
+	s, conn := startDSOServer(t, true, reply)
+	defer s.Stop()
+	defer conn.Close()
+	req := dsoRequest(0, keepaliveTLV(time.Minute, time.Hour))
+	if _, err := conn.Write(append([]byte{0, byte(len(req))}, req...)); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 512)); err == nil {
+		t.Errorf("Expected the connection to be closed")
+	} else if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		t.Errorf("Expected the connection to be closed, got %s", err)
+	}
+}
+
+// reply is a handler that replies without records.
+var reply = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+})
+
+// startDSOServer starts a TCP server with DSO enabled or not, and returns it with a connection to it.
+func startDSOServer(t *testing.T, enabled bool, h plugin.Handler) (*Server, net.Conn) {
+	if err := feature.Set(map[string]bool{"dso": enabled}); err != nil {
+		t.Fatal(err)
+	}
+	defer feature.Set(nil)
+
+	c := testConfig("dns", h)
+	c.Zone = "."
+	s, err := NewServer("127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	// Wait for the server to read the feature gate.
+	for i := 0; i < 100; i++ {
+		s.m.Lock()
+		started := s.server[tcp] != nil
+		s.m.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, conn
+}
+
+func readTCP(t *testing.T, conn net.Conn) []byte {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	l := make([]byte, 2)
+	if _, err := conn.Read(l); err != nil {
+		t.Fatalf("Expected a response, got %s", err)
+	}
+	b := make([]byte, binary.BigEndian.Uint16(l))
+	n := 0
+	for n < len(b) {
+		m, err := conn.Read(b[n:])
+		if err != nil {
+			t.Fatalf("Expected a response, got %s", err)
+		}
+		n += m
+	}
+	return b
+}
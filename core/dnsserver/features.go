@@ -0,0 +1,24 @@
+package dnsserver
+
+import (
+	"fmt"
+
+	"github.com/coredns/coredns/plugin/pkg/feature"
+)
+
+// applyFeatures sets the feature gates to the states in the Features of configs. Feature gates
+// apply to the whole process, so a gate may not be enabled in one server block and disabled in
+// another. The gates not set in any server block are set to their default, this also resets the
+// gates removed from the Corefile on reload.
+func applyFeatures(configs []*Config) error {
+	states := map[string]bool{}
+	for _, c := range configs {
+		for name, enabled := range c.Features {
+			if s, ok := states[name]; ok && s != enabled {
+				return fmt.Errorf("feature gate %q is both enabled and disabled", name)
+			}
+			states[name] = enabled
+		}
+	}
+	return feature.Set(states)
+}
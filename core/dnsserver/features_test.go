@@ -0,0 +1,31 @@
+package dnsserver
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/feature"
+)
+
+var testGate = feature.Register("test_dnsserver", "Test gate.", false)
+
+func TestApplyFeatures(t *testing.T) {
+	configs := []*Config{{Features: map[string]bool{"test_dnsserver": true}}, {}}
+	if err := applyFeatures(configs); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if !testGate.Enabled() {
+		t.Errorf("Expected gate enabled")
+	}
+
+	configs = append(configs, &Config{Features: map[string]bool{"test_dnsserver": false}})
+	if err := applyFeatures(configs); err == nil {
+		t.Errorf("Expected error for a gate both enabled and disabled, got none")
+	}
+
+	if err := applyFeatures([]*Config{{}}); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if testGate.Enabled() {
+		t.Errorf("Expected gate reset to its default")
+	}
+}
@@ -0,0 +1,36 @@
+// +build fuzz
+
+package dnsserver
+
+import (
+	"context"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// fuzzServer answers every query with its question, as the plugins see it.
+var fuzzServer, _ = NewServer("dns://:53", []*Config{fuzzConfig(plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}))})
+
+// Fuzz fuzzes the server's handling of queries, up to the plugins.
+func Fuzz(data []byte) int {
+	r := new(dns.Msg)
+	if err := r.Unpack(data); err != nil {
+		return 0
+	}
+	fuzzServer.ServeDNS(context.TODO(), &test.ResponseWriter{}, r)
+	return 1
+}
+
+func fuzzConfig(h plugin.Handler) *Config {
+	c := &Config{Zone: ".", Transport: "dns", ListenHosts: []string{""}, Port: "53"}
+	c.AddPlugin(func(next plugin.Handler) plugin.Handler { return h })
+	return c
+}
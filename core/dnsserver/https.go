@@ -1,11 +1,279 @@
 package dnsserver
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
 	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/coredns/coredns/plugin/metrics/vars"
 	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/http2"
 )
 
+// HTTPOptions tunes the HTTP servers of DNS-over-HTTPS, a zero value keeps the default.
+type HTTPOptions struct {
+	ReadTimeout          time.Duration // the time to read a request, including its body
+	WriteTimeout         time.Duration // the time to write the response, from the end of the request
+	IdleTimeout          time.Duration // the time an idle connection is kept open
+	MaxHeaderBytes       int           // the maximum size of the request headers, including the URL
+	MaxConcurrentStreams uint32        // the maximum number of concurrent HTTP/2 requests of a connection
+
+	// TrustedProxies are the networks of the reverse proxies whose Forwarded and X-Forwarded-For
+	// headers tell the address of the client, see clientAddr.
+	TrustedProxies []*net.IPNet
+
+	// Auth, when not nil, are the credentials the requests must present in their Authorization
+	// header, see authorize.
+	Auth *HTTPAuth
+}
+
+// HTTPAuth are the credentials of the DNS-over-HTTPS clients: bearer tokens, and users of the basic
+// authentication. Only their SHA-256 hashes are kept.
+type HTTPAuth struct {
+	tokens map[[sha256.Size]byte]bool
+	users  map[string][sha256.Size]byte
+}
+
+// AddToken allows the bearer token.
+func (a *HTTPAuth) AddToken(token string) {
+	if a.tokens == nil {
+		a.tokens = make(map[[sha256.Size]byte]bool)
+	}
+	a.tokens[sha256.Sum256([]byte(token))] = true
+}
+
+// AddUser allows user with password, with the basic authentication.
+func (a *HTTPAuth) AddUser(user, password string) {
+	if a.users == nil {
+		a.users = make(map[string][sha256.Size]byte)
+	}
+	a.users[user] = sha256.Sum256([]byte(password))
+}
+
+// status returns the HTTP status refusing r: 401 without credentials, 403 with wrong ones. It
+// returns 200 when r is authorized.
+func (a *HTTPAuth) status(r *http.Request) int {
+	if user, password, ok := r.BasicAuth(); ok {
+		hash, found := a.users[user]
+		p := sha256.Sum256([]byte(password))
+		if found && subtle.ConstantTimeCompare(hash[:], p[:]) == 1 {
+			return http.StatusOK
+		}
+		return http.StatusForbidden
+	}
+
+	const bearer = "Bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(bearer) || !strings.EqualFold(h[:len(bearer)], bearer) {
+		return http.StatusUnauthorized
+	}
+	if a.tokens[sha256.Sum256([]byte(h[len(bearer):]))] {
+		return http.StatusOK
+	}
+	return http.StatusForbidden
+}
+
+// The default HTTPOptions. Without timeouts slow clients can hold connections forever.
+const (
+	defaultHTTPReadTimeout  = 5 * time.Second
+	defaultHTTPWriteTimeout = 5 * time.Second
+	defaultHTTPIdleTimeout  = 2 * time.Minute
+)
+
+// newHTTPServer returns an HTTP server handling the requests with h, tuned with o, which may be
+// nil. With h2 the server does HTTP/2 over TLS.
+func newHTTPServer(h http.Handler, o *HTTPOptions, h2 bool) (*http.Server, error) {
+	if o == nil {
+		o = new(HTTPOptions)
+	}
+	srv := &http.Server{
+		Handler:        h,
+		ReadTimeout:    defaultHTTPReadTimeout,
+		WriteTimeout:   defaultHTTPWriteTimeout,
+		IdleTimeout:    defaultHTTPIdleTimeout,
+		MaxHeaderBytes: o.MaxHeaderBytes,
+	}
+	if o.ReadTimeout > 0 {
+		srv.ReadTimeout = o.ReadTimeout
+	}
+	if o.WriteTimeout > 0 {
+		srv.WriteTimeout = o.WriteTimeout
+	}
+	if o.IdleTimeout > 0 {
+		srv.IdleTimeout = o.IdleTimeout
+	}
+	if !h2 {
+		return srv, nil
+	}
+	err := http2.ConfigureServer(srv, &http2.Server{MaxConcurrentStreams: o.MaxConcurrentStreams})
+	return srv, err
+}
+
+// authorize returns true when r is authorized, or when the server doesn't require credentials.
+// Otherwise it refuses r.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if s.httpOptions == nil || s.httpOptions.Auth == nil {
+		return true
+	}
+	a := s.httpOptions.Auth
+	status := a.status(r)
+	switch status {
+	case http.StatusOK:
+		return true
+	case http.StatusUnauthorized:
+		if len(a.users) > 0 {
+			w.Header().Add("WWW-Authenticate", `Basic realm="DNS"`)
+		}
+		if len(a.tokens) > 0 {
+			w.Header().Add("WWW-Authenticate", `Bearer realm="DNS"`)
+		}
+	}
+	http.Error(w, http.StatusText(status), status)
+	return false
+}
+
+// dohPath returns true when DNS-over-HTTPS queries are accepted on the URL path p.
+func (s *Server) dohPath(p string) bool {
+	for _, path := range s.dohPaths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// httpHandler returns the handler registered in Config.HTTPHandlers for the URL path p, or nil. The
+// DNS-over-HTTPS queries take precedence.
+func (s *Server) httpHandler(p string) http.Handler {
+	if s.httpHandlers == nil || s.dohPath(p) {
+		return nil
+	}
+	return s.httpHandlers[p]
+}
+
+// clientAddr returns the address of the client of r. When the request comes from a trusted proxy,
+// the address is read from the Forwarded header, or else the X-Forwarded-For header: it is the
+// last address added to the header that is not a trusted proxy, the addresses before it may be
+// made up by the client. The port is zero when the header has none.
+func (s *Server) clientAddr(r *http.Request) *net.TCPAddr {
+	h, p, _ := net.SplitHostPort(r.RemoteAddr)
+	port, _ := strconv.Atoi(p)
+	addr := &net.TCPAddr{IP: net.ParseIP(h), Port: port}
+	if s.httpOptions == nil || !trustedProxy(addr.IP, s.httpOptions.TrustedProxies) {
+		return addr
+	}
+
+	hops := forwardedFor(r.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		a := parseHop(hops[i])
+		if a == nil {
+			// An obfuscated or malformed hop, the addresses before it can't be trusted.
+			break
+		}
+		addr = a
+		if !trustedProxy(a.IP, s.httpOptions.TrustedProxies) {
+			break
+		}
+	}
+	return addr
+}
+
+func trustedProxy(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the hops of the Forwarded header (RFC 7239), the first being the client, or
+// else the ones of the X-Forwarded-For header.
+func forwardedFor(h http.Header) []string {
+	var hops []string
+	if fwd := h["Forwarded"]; len(fwd) > 0 {
+		for _, e := range strings.Split(strings.Join(fwd, ","), ",") {
+			hop := ""
+			for _, pair := range strings.Split(e, ";") {
+				pair = strings.TrimSpace(pair)
+				if len(pair) > 4 && strings.EqualFold(pair[:4], "for=") {
+					hop = strings.Trim(pair[4:], `"`)
+				}
+			}
+			hops = append(hops, hop)
+		}
+		return hops
+	}
+	for _, hop := range strings.Split(strings.Join(h["X-Forwarded-For"], ","), ",") {
+		if hop = strings.TrimSpace(hop); hop != "" {
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// parseHop parses an address of a Forwarded or X-Forwarded-For header, with an optional port:
+// "192.0.2.1", "192.0.2.1:4711", "2001:db8::1" or "[2001:db8::1]:4711". It returns nil when it's not
+// an address, like "unknown" or an obfuscated identifier.
+func parseHop(hop string) *net.TCPAddr {
+	if ip := net.ParseIP(strings.Trim(hop, "[]")); ip != nil {
+		return &net.TCPAddr{IP: ip}
+	}
+	h, p, err := net.SplitHostPort(hop)
+	if err != nil {
+		return nil
+	}
+	ip := net.ParseIP(h)
+	port, err := strconv.ParseUint(p, 10, 16)
+	if ip == nil || err != nil {
+		return nil
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}
+}
+
+// httpMetrics is an http.ResponseWriter that records the status code, the size of the body and the
+// time of the first byte of the response, for vars.ReportHTTPS.
+type httpMetrics struct {
+	http.ResponseWriter
+	status int
+	size   int
+	first  time.Time
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (w *httpMetrics) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.first = time.Now()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements the http.ResponseWriter interface.
+func (w *httpMetrics) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// report reports the request r, which started at start and whose DNS message is size bytes.
+func (w *httpMetrics) report(server string, r *http.Request, size int, start time.Time) {
+	ttfb := time.Duration(-1)
+	if !w.first.IsZero() {
+		ttfb = w.first.Sub(start)
+	}
+	vars.ReportHTTPS(server, r.Method, size, w.status, w.size, ttfb)
+}
+
 // DoHWriter is a nonwriter.Writer that adds more specific LocalAddr and RemoteAddr methods.
 type DoHWriter struct {
 	nonwriter.Writer
@@ -21,3 +289,6 @@ func (d *DoHWriter) RemoteAddr() net.Addr { return d.raddr }
 
 // LocalAddr returns the local address.
 func (d *DoHWriter) LocalAddr() net.Addr { return d.laddr }
+
+// TsigStatus implements the dns.ResponseWriter interface. The TSIG of the queries is not verified.
+func (d *DoHWriter) TsigStatus() error { return dns.ErrSecret }
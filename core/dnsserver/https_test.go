@@ -0,0 +1,69 @@
+package dnsserver
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPServer(t *testing.T) {
+	srv, err := newHTTPServer(nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.ReadTimeout != defaultHTTPReadTimeout || srv.WriteTimeout != defaultHTTPWriteTimeout || srv.IdleTimeout != defaultHTTPIdleTimeout {
+		t.Errorf("Expected the default timeouts, got %s %s %s", srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+
+	o := &HTTPOptions{IdleTimeout: time.Minute, MaxHeaderBytes: 4096, MaxConcurrentStreams: 10}
+	srv, err = newHTTPServer(nil, o, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.ReadTimeout != defaultHTTPReadTimeout || srv.IdleTimeout != time.Minute || srv.MaxHeaderBytes != 4096 {
+		t.Errorf("Expected the options to be applied, got %s %s %d", srv.ReadTimeout, srv.IdleTimeout, srv.MaxHeaderBytes)
+	}
+	if srv.TLSNextProto["h2"] == nil {
+		t.Error("Expected HTTP/2 to be configured")
+	}
+}
+
+func TestClientAddr(t *testing.T) {
+	_, n, _ := net.ParseCIDR("10.0.0.0/8")
+	s := &Server{httpOptions: &HTTPOptions{TrustedProxies: []*net.IPNet{n}}}
+
+	tests := []struct {
+		remote   string
+		header   http.Header
+		expected string
+	}{
+		{"192.0.2.1:4711", nil, "192.0.2.1:4711"},
+		// Not from a trusted proxy, the header is ignored.
+		{"192.0.2.1:4711", http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "192.0.2.1:4711"},
+		{"10.0.0.1:4711", nil, "10.0.0.1:4711"},
+		{"10.0.0.1:4711", http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "198.51.100.1:0"},
+		// The last address that is not a trusted proxy, the ones before may be made up.
+		{"10.0.0.1:4711", http.Header{"X-Forwarded-For": {"203.0.113.1, 198.51.100.1", "10.0.0.2"}}, "198.51.100.1:0"},
+		{"10.0.0.1:4711", http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3:0"},
+		{"10.0.0.1:4711", http.Header{"X-Forwarded-For": {"2001:db8::1"}}, "[2001:db8::1]:0"},
+		{"10.0.0.1:4711", http.Header{"X-Forwarded-For": {"unknown, 10.0.0.2"}}, "10.0.0.2:0"},
+		// Forwarded takes precedence.
+		{"10.0.0.1:4711", http.Header{"Forwarded": {`for=198.51.100.1;proto=https, For="[2001:db8::1]:4711"`}, "X-Forwarded-For": {"203.0.113.1"}}, "[2001:db8::1]:4711"},
+		{"10.0.0.1:4711", http.Header{"Forwarded": {"for=198.51.100.1:53;by=10.0.0.1"}}, "198.51.100.1:53"},
+		{"10.0.0.1:4711", http.Header{"Forwarded": {"for=198.51.100.1, for=_hidden"}}, "10.0.0.1:4711"},
+		{"10.0.0.1:4711", http.Header{"Forwarded": {"proto=https"}}, "10.0.0.1:4711"},
+	}
+	for i, tc := range tests {
+		r := &http.Request{RemoteAddr: tc.remote, Header: tc.header}
+		if a := s.clientAddr(r).String(); a != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, a)
+		}
+	}
+
+	s.httpOptions = nil
+	r := &http.Request{RemoteAddr: "10.0.0.1:4711", Header: http.Header{"X-Forwarded-For": {"198.51.100.1"}}}
+	if a := s.clientAddr(r).String(); a != "10.0.0.1:4711" {
+		t.Errorf("Expected no trusted proxy by default, got %s", a)
+	}
+}
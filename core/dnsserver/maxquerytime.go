@@ -0,0 +1,104 @@
+package dnsserver
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics/vars"
+	"github.com/coredns/coredns/plugin/pkg/ede"
+
+	"github.com/miekg/dns"
+)
+
+// serveTimed serves r like serve, but in its own goroutine: when the plugins are still running after
+// maxQueryTime, their context is canceled, the client answered with SERVFAIL at once, and their
+// response discarded. The timeout is counted for the plugin that was running, the last one called
+// that has not returned.
+func (s *Server) serveTimed(ctx context.Context, h *Config, w dns.ResponseWriter, r *dns.Msg) {
+	ctx, cancel := context.WithTimeout(ctx, s.maxQueryTime)
+	defer cancel()
+	t := &plugin.Timings{}
+	ctx = plugin.WithTimings(ctx, t)
+
+	tw := &timeoutWriter{ResponseWriter: w}
+	done := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			// A panic is raised again in the server goroutine, which recovers from it.
+			done <- recover()
+		}()
+		rcode, err := plugin.ServeTimed(ctx, h.pluginChain, tw, r)
+		if !plugin.ClientWrite(rcode) {
+			errorFunc(s.Addr, tw, r, rcode, err)
+		}
+	}()
+
+	select {
+	case rec := <-done:
+		if rec != nil {
+			panic(rec)
+		}
+	case <-ctx.Done():
+		if !tw.expire() {
+			// The plugins answered just in time.
+			return
+		}
+		vars.QueryTimeouts.WithLabelValues(s.Addr, h.Zone, runningPlugin(t.List())).Inc()
+		errorFunc(s.Addr, w, r, dns.RcodeServerFailure, ede.Wrap(ede.NoReachableAuthority, errQueryTimeout))
+	}
+}
+
+// runningPlugin returns the name of the last plugin called that has not returned.
+func runningPlugin(timings []plugin.Timing) string {
+	name := ""
+	for _, t := range timings {
+		if t.Duration == 0 {
+			name = t.Plugin
+		}
+	}
+	return name
+}
+
+// errQueryTimeout is returned to the plugins writing a response after the max query time.
+var errQueryTimeout = errors.New("max query time exceeded")
+
+// timeoutWriter discards the responses written after it expired.
+type timeoutWriter struct {
+	dns.ResponseWriter
+
+	mu      sync.Mutex
+	written bool
+	expired bool
+}
+
+// expire makes w discard the responses. It returns false when a response was already written.
+func (w *timeoutWriter) expire() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.expired = true
+	return !w.written
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *timeoutWriter) WriteMsg(m *dns.Msg) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired {
+		return errQueryTimeout
+	}
+	w.written = true
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *timeoutWriter) Write(buf []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired {
+		return 0, errQueryTimeout
+	}
+	w.written = true
+	return w.ResponseWriter.Write(buf)
+}
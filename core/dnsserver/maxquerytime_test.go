@@ -0,0 +1,122 @@
+package dnsserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics/vars"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestServeDNSMaxQueryTime(t *testing.T) {
+	release := make(chan struct{})
+	canceled := make(chan error, 1)
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		if r.Question[0].Name == "slow.example.com." {
+			// A stuck plugin, answering after the max query time.
+			<-release
+			canceled <- ctx.Err()
+		}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	c := testConfig("dns", h)
+	c.MaxQueryTime = 50 * time.Millisecond
+	const server = "127.0.0.1:5301"
+	s, err := NewServer(server, []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+
+	timeouts := vars.QueryTimeouts.WithLabelValues(server, "example.com.", "handlerfunc")
+	before := testutil.ToFloat64(timeouts)
+
+	tests := []struct {
+		qname string
+		rcode int
+	}{
+		{"fast.example.com.", dns.RcodeSuccess},
+		{"slow.example.com.", dns.RcodeServerFailure},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		s.ServeDNS(context.TODO(), rec, m)
+		if rec.Msg == nil || rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %v", i, tc.rcode, rec.Msg)
+		}
+	}
+
+	close(release)
+	if err := <-canceled; err != context.DeadlineExceeded {
+		t.Errorf("Expected the context of the slow query to be canceled, got %v", err)
+	}
+	if n := testutil.ToFloat64(timeouts) - before; n != 1 {
+		t.Errorf("Expected 1 timeout of handlerfunc, got %f", n)
+	}
+}
+
+func TestPrefetchMaxQueryTime(t *testing.T) {
+	served := make(chan struct{})
+	canceled := make(chan error, 2)
+	// A plugin answering, then prefetching in the background like cache does: the context of the
+	// query is canceled once it is answered, the prefetch has a context of its own.
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		go func(prefetch context.Context) {
+			<-served
+			canceled <- ctx.Err()
+			canceled <- prefetch.Err()
+		}(context.WithoutCancel(ctx))
+		return dns.RcodeSuccess, nil
+	})
+	c := testConfig("dns", h)
+	c.MaxQueryTime = time.Second
+	s, err := NewServer("127.0.0.1:53", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	s.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+	close(served)
+
+	if err := <-canceled; err != context.Canceled {
+		t.Errorf("Expected the context of the answered query to be canceled, got %v", err)
+	}
+	if err := <-canceled; err != nil {
+		t.Errorf("Expected the context of the prefetch not to be canceled, got %s", err)
+	}
+}
+
+func TestServeDNSMaxQueryTimePanic(t *testing.T) {
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		panic("test")
+	})
+	c := testConfig("dns", h)
+	c.MaxQueryTime = time.Second
+	s, err := NewServer("127.0.0.1:53", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	s.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg == nil || rec.Msg.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected SERVFAIL after a panic, got %v", rec.Msg)
+	}
+}
@@ -0,0 +1,47 @@
+package dnsserver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NUMA shards the UDP queries of a server by NUMA node: each node gets its own SO_REUSEPORT sockets,
+// read by goroutines whose threads are pinned to the CPUs of the node, so a query is received,
+// served and answered without crossing nodes. See the numa plugin.
+type NUMA struct {
+	// Nodes holds the NUMA nodes to shard on, all of them when empty.
+	Nodes []int
+	// Workers is the number of goroutines reading each socket, 1 when zero.
+	Workers int
+	// IncomingCPU sets SO_INCOMING_CPU on the sockets, one for every CPU of a node, so the kernel
+	// hands a packet to the socket of the CPU that received it.
+	IncomingCPU bool
+}
+
+// parseCPUList parses a list of CPUs in the format of the kernel, like "0-3,8,10-11".
+func parseCPUList(s string) ([]int, error) {
+	var cpus []int
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	for _, r := range strings.Split(s, ",") {
+		lo, hi := r, r
+		if i := strings.IndexByte(r, '-'); i >= 0 {
+			lo, hi = r[:i], r[i+1:]
+		}
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU list %q", s)
+		}
+		last, err := strconv.Atoi(hi)
+		if err != nil || first < 0 || last < first {
+			return nil, fmt.Errorf("invalid CPU list %q", s)
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
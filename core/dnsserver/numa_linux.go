@@ -0,0 +1,265 @@
+// +build go1.11
+// +build linux
+
+package dnsserver
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+// NUMANodes returns the CPUs of the NUMA nodes of the machine, keyed by node. The nodes without
+// CPUs are left out. A machine without NUMA is a single node 0 with all the CPUs online.
+func NUMANodes() (map[int][]int, error) {
+	paths, err := filepath.Glob("/sys/devices/system/node/node[0-9]*/cpulist")
+	if err != nil {
+		return nil, err
+	}
+	nodes := map[int][]int{}
+	for _, p := range paths {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(filepath.Dir(p)), "node"))
+		if err != nil {
+			continue
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		cpus, err := parseCPUList(string(b))
+		if err != nil {
+			return nil, err
+		}
+		if len(cpus) > 0 {
+			nodes[id] = cpus
+		}
+	}
+	if len(nodes) > 0 {
+		return nodes, nil
+	}
+
+	b, err := ioutil.ReadFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return nil, err
+	}
+	cpus, err := parseCPUList(string(b))
+	if err != nil {
+		return nil, err
+	}
+	return map[int][]int{0: cpus}, nil
+}
+
+// numaShards serves the UDP queries to an address besides the socket of the server: every NUMA node
+// has a socket per CPU in the SO_REUSEPORT group of the address, read by goroutines locked to
+// threads running on the CPUs of the node only. A query is served on the thread that read it, so its
+// buffers stay in the memory of the node.
+type numaShards struct {
+	conns    []*net.UDPConn
+	unfilter []func()
+	closed   int32 // 1 when shutting down, accessed atomically
+	wg       sync.WaitGroup
+}
+
+// startNUMAShards starts serving the UDP queries to addr with s, on the nodes of n.
+func startNUMAShards(s *Server, addr *net.UDPAddr, n *NUMA) (*numaShards, error) {
+	nodes, err := NUMANodes()
+	if err != nil {
+		return nil, err
+	}
+	ids := n.Nodes
+	if len(ids) == 0 {
+		for id := range nodes {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+	}
+	workers := n.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	wildcard := addr.IP == nil || addr.IP.IsUnspecified()
+
+	sh := &numaShards{}
+	for _, id := range ids {
+		cpus, ok := nodes[id]
+		if !ok {
+			sh.stop()
+			return nil, fmt.Errorf("no NUMA node %d with CPUs", id)
+		}
+		var set unix.CPUSet
+		for _, cpu := range cpus {
+			set.Set(cpu)
+		}
+		for _, cpu := range cpus {
+			incomingCPU := -1
+			if n.IncomingCPU {
+				incomingCPU = cpu
+			}
+			u, err := listenNUMAShard(addr, incomingCPU)
+			if err != nil {
+				sh.stop()
+				return nil, err
+			}
+			sh.conns = append(sh.conns, u)
+
+			setDontFragment(u, s.maxUDPSize > 0)
+			if unfilter, err := attachUDPFilter(u, s.Addr, s.udpFilter); err != nil {
+				log.Errorf("Failed to attach the socket filter to %s: %s", u.LocalAddr(), err)
+			} else if unfilter != nil {
+				sh.unfilter = append(sh.unfilter, unfilter)
+			}
+			if wildcard {
+				// The responses must come from the address the query was sent to.
+				ipv6.NewPacketConn(u).SetControlMessage(ipv6.FlagDst|ipv6.FlagInterface, true)
+				ipv4.NewPacketConn(u).SetControlMessage(ipv4.FlagDst|ipv4.FlagInterface, true)
+			}
+			for i := 0; i < workers; i++ {
+				sh.wg.Add(1)
+				go sh.serve(s, u, set, wildcard)
+			}
+		}
+	}
+	return sh, nil
+}
+
+// listenNUMAShard returns a socket in the SO_REUSEPORT group of addr. When incomingCPU is not -1,
+// the socket is set to get the packets received on that CPU.
+func listenNUMAShard(addr *net.UDPAddr, incomingCPU int) (*net.UDPConn, error) {
+	lc := net.ListenConfig{Control: func(network, address string, c syscall.RawConn) error {
+		var err error
+		if cerr := c.Control(func(fd uintptr) {
+			if err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1); err != nil {
+				return
+			}
+			if incomingCPU >= 0 {
+				err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_INCOMING_CPU, incomingCPU)
+			}
+		}); cerr != nil {
+			return cerr
+		}
+		return err
+	}}
+	p, err := lc.ListenPacket(context.Background(), "udp", addr.String())
+	if err != nil {
+		return nil, err
+	}
+	return p.(*net.UDPConn), nil
+}
+
+// serve reads the queries received on u and serves them, until sh is stopped. The goroutine runs on
+// a thread of its own, pinned to cpus.
+func (sh *numaShards) serve(s *Server, u *net.UDPConn, cpus unix.CPUSet, wildcard bool) {
+	defer sh.wg.Done()
+	// The thread is never unlocked: it exits with the goroutine, instead of going back to the
+	// scheduler with the affinity of the node.
+	runtime.LockOSThread()
+	if err := unix.SchedSetaffinity(0, &cpus); err != nil {
+		log.Warningf("Failed to pin the thread serving %s to a NUMA node: %s", u.LocalAddr(), err)
+	}
+
+	ctx := context.WithValue(context.Background(), Key{}, s)
+	b := make([]byte, dns.MaxMsgSize)
+	for {
+		w := &numaWriter{conn: u}
+		var (
+			n   int
+			err error
+		)
+		if wildcard {
+			n, w.session, err = dns.ReadFromSessionUDP(u, b)
+		} else {
+			n, w.raddr, err = u.ReadFromUDP(b)
+		}
+		if err != nil {
+			if atomic.LoadInt32(&sh.closed) == 1 {
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			log.Errorf("Failed to read from %s: %s", u.LocalAddr(), err)
+			return
+		}
+		r := new(dns.Msg)
+		if err := r.Unpack(b[:n]); err != nil || r.Response {
+			continue
+		}
+		s.serveUDP(ctx, w, r)
+	}
+}
+
+// stop closes the sockets, and waits for the queries being served.
+func (sh *numaShards) stop() {
+	atomic.StoreInt32(&sh.closed, 1)
+	for _, u := range sh.conns {
+		u.Close()
+	}
+	sh.wg.Wait()
+	for _, f := range sh.unfilter {
+		f()
+	}
+}
+
+// numaWriter writes the response to a query read by numaShards.
+type numaWriter struct {
+	conn    *net.UDPConn
+	session *dns.SessionUDP // when the socket is bound to the wildcard address
+	raddr   *net.UDPAddr
+}
+
+// LocalAddr implements the dns.ResponseWriter interface.
+func (w *numaWriter) LocalAddr() net.Addr { return w.conn.LocalAddr() }
+
+// RemoteAddr implements the dns.ResponseWriter interface.
+func (w *numaWriter) RemoteAddr() net.Addr {
+	if w.session != nil {
+		return w.session.RemoteAddr()
+	}
+	return w.raddr
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *numaWriter) WriteMsg(m *dns.Msg) error {
+	b, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *numaWriter) Write(b []byte) (int, error) {
+	if w.session != nil {
+		return dns.WriteToSessionUDP(w.conn, b, w.session)
+	}
+	return w.conn.WriteToUDP(b, w.raddr)
+}
+
+// Close implements the dns.ResponseWriter interface.
+func (w *numaWriter) Close() error { return nil }
+
+// TsigStatus implements the dns.ResponseWriter interface. The TSIG of the queries is not verified.
+func (w *numaWriter) TsigStatus() error { return dns.ErrSecret }
+
+// TsigTimersOnly implements the dns.ResponseWriter interface.
+func (w *numaWriter) TsigTimersOnly(bool) {}
+
+// Hijack implements the dns.ResponseWriter interface.
+func (w *numaWriter) Hijack() {}
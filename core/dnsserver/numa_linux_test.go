@@ -0,0 +1,85 @@
+// +build go1.11
+// +build linux
+
+package dnsserver
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/miekg/dns"
+	"golang.org/x/sys/unix"
+)
+
+func TestNUMAShards(t *testing.T) {
+	nodes, err := NUMANodes()
+	if err != nil {
+		t.Skipf("NUMA nodes are not available: %s", err)
+	}
+	var node int
+	for node = range nodes {
+		break
+	}
+
+	// The handler replies with the CPUs the thread serving the query can run on.
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		var set unix.CPUSet
+		unix.SchedGetaffinity(0, &set)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{&dns.TXT{Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET}, Txt: []string{strconv.Itoa(set.Count())}}}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+
+	for i, listen := range []string{"127.0.0.1:0", "[::]:0"} {
+		c := testConfig("dns", h)
+		c.Zone = "."
+		s, err := NewServer("dns://"+listen, []*Config{c})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The socket of the server isn't read, the queries must be served by the shards.
+		p, err := listenPacket("udp", listen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sh, err := startNUMAShards(s, p.LocalAddr().(*net.UDPAddr), &NUMA{Nodes: []int{node}, IncomingCPU: true})
+		if err != nil {
+			p.Close()
+			t.Fatalf("Test %d: %s", i, err)
+		}
+		port := p.LocalAddr().(*net.UDPAddr).Port
+		p.Close()
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		for j := 0; j < 10; j++ {
+			addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+			r, _, err := (&dns.Client{Timeout: time.Second}).Exchange(m, addr.String())
+			if err != nil {
+				t.Errorf("Test %d: expected a response, got %s", i, err)
+				break
+			}
+			if len(r.Answer) != 1 || r.Answer[0].(*dns.TXT).Txt[0] != strconv.Itoa(len(nodes[node])) {
+				t.Errorf("Test %d: expected the query to be served on the %d CPUs of node %d, got %v", i, len(nodes[node]), node, r.Answer)
+			}
+		}
+		sh.stop()
+	}
+}
+
+func TestNUMAShardsUnknownNode(t *testing.T) {
+	s, err := NewServer("dns://127.0.0.1:0", []*Config{testConfig("dns", reply)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := startNUMAShards(s, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, &NUMA{Nodes: []int{1 << 20}}); err == nil {
+		t.Error("Expected an error for an unknown node")
+	}
+}
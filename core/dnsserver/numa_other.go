@@ -0,0 +1,22 @@
+// +build !linux !go1.11
+
+package dnsserver
+
+import (
+	"errors"
+	"net"
+)
+
+// numaShards is only implemented on Linux.
+type numaShards struct{}
+
+// NUMANodes returns the CPUs of the NUMA nodes of the machine, keyed by node.
+func NUMANodes() (map[int][]int, error) {
+	return nil, errors.New("NUMA nodes are only supported on Linux")
+}
+
+func startNUMAShards(s *Server, addr *net.UDPAddr, n *NUMA) (*numaShards, error) {
+	return nil, errors.New("NUMA sharding is only supported on Linux")
+}
+
+func (sh *numaShards) stop() {}
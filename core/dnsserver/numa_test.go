@@ -0,0 +1,31 @@
+package dnsserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		list     string
+		expected []int
+		err      bool
+	}{
+		{"0\n", []int{0}, false},
+		{"0-3,8,10-11", []int{0, 1, 2, 3, 8, 10, 11}, false},
+		{"", nil, false},
+		{"3-1", nil, true},
+		{"a-b", nil, true},
+		{"0,", nil, true},
+	}
+	for i, tc := range tests {
+		cpus, err := parseCPUList(tc.list)
+		if (err != nil) != tc.err {
+			t.Errorf("Test %d: expected error %t, got %v", i, tc.err, err)
+			continue
+		}
+		if !reflect.DeepEqual(cpus, tc.expected) {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.expected, cpus)
+		}
+	}
+}
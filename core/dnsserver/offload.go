@@ -0,0 +1,9 @@
+package dnsserver
+
+import (
+	"github.com/coredns/coredns/plugin/pkg/feature"
+)
+
+// udpOffload is the feature gate for UDP generic receive and segmentation offload, see
+// newOffloadConn.
+var udpOffload = feature.Register("udp_offload", "Use UDP generic receive and segmentation offload (GRO and GSO) on Linux.", false)
@@ -0,0 +1,377 @@
+// +build linux
+
+package dnsserver
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	udpSegment = 103 // UDP_SEGMENT from linux/udp.h
+	udpGRO     = 104 // UDP_GRO from linux/udp.h
+
+	maxGSOSegments = 64    // UDP_MAX_SEGMENTS, the maximum number of segments in a single send
+	maxGSOBytes    = 65000 // the maximum number of bytes in a single send
+	// maxGSOSegmentSize is the largest response sent with GSO. A segment must fit in the MTU of the
+	// interface, the kernel refuses larger ones; 1232 bytes fit in the minimum IPv6 MTU.
+	maxGSOSegmentSize = 1232
+
+	// gsoFlushDelay is the longest a response waits for the other responses of its batch.
+	gsoFlushDelay = 500 * time.Microsecond
+)
+
+// offloadConn serves DNS over a UDP socket with generic receive offload (GRO) enabled. With GRO the
+// kernel hands over consecutive datagrams of the same client in a single buffer, together with the
+// size of each datagram, so a single read returns a batch of queries. With generic segmentation
+// offload (GSO) the responses of a batch that have the same size are sent in a single write, the
+// kernel splits them into datagrams.
+//
+// When GRO can't be enabled, newOffloadConn returns nil and the socket is served by dns.Server.
+// When a GSO write fails because the kernel or the network interface doesn't support it, GSO is
+// disabled and the responses are sent one by one.
+type offloadConn struct {
+	conn   *net.UDPConn
+	source bool  // set the source address of the responses, the socket is bound to the wildcard address
+	gso    int32 // 1 when GSO is used, accessed atomically
+	closed int32 // 1 when shutting down, accessed atomically
+	wg     sync.WaitGroup
+}
+
+var offloadBufs = sync.Pool{New: func() interface{} { b := make([]byte, dns.MaxMsgSize); return &b }}
+
+// newOffloadConn returns an offloadConn for p when the udp_offload feature gate is enabled and GRO
+// can be enabled on the socket, otherwise it returns nil.
+func newOffloadConn(p net.PacketConn) *offloadConn {
+	u, ok := p.(*net.UDPConn)
+	if !ok {
+		return nil
+	}
+	if !udpOffload.Enabled() {
+		// The socket is shared with the server from before a reload, which may have enabled GRO.
+		setsockoptUDP(u, udpGRO, 0)
+		return nil
+	}
+	if err := setsockoptUDP(u, udpGRO, 1); err != nil {
+		log.Warningf("Failed to enable UDP GRO on %s, not using UDP offload: %s", u.LocalAddr(), err)
+		return nil
+	}
+
+	o := &offloadConn{conn: u, source: !boundToAddress(u), gso: 1}
+	if o.source {
+		// Get the destination address of the queries to send the responses from, as dns.Server does.
+		ipv6.NewPacketConn(u).SetControlMessage(ipv6.FlagDst|ipv6.FlagInterface, true)
+		ipv4.NewPacketConn(u).SetControlMessage(ipv4.FlagDst|ipv4.FlagInterface, true)
+	}
+	return o
+}
+
+func setsockoptUDP(u *net.UDPConn, opt, value int) error {
+	rc, err := u.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_UDP, opt, value)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+// serve reads the queries from o and serves them with s. It blocks until o is shut down.
+func (o *offloadConn) serve(s *Server) error {
+	ctx := context.WithValue(context.Background(), Key{}, s)
+	oob := make([]byte, 512)
+	for {
+		buf := offloadBufs.Get().(*[]byte)
+		n, oobn, _, raddr, err := o.conn.ReadMsgUDP(*buf, oob)
+		if err != nil {
+			offloadBufs.Put(buf)
+			if atomic.LoadInt32(&o.closed) == 1 {
+				return nil
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				continue
+			}
+			return err
+		}
+
+		size, src := parseOffloadOOB(oob[:oobn], o.source)
+		segs := segments((*buf)[:n], size)
+		b := &udpBatch{o: o, raddr: raddr, oob: src, buf: buf, pending: len(segs)}
+		o.wg.Add(len(segs))
+		for _, seg := range segs {
+			go b.serve(ctx, s, seg)
+		}
+	}
+}
+
+// shutdown stops reading from o, waits for the queries being handled and closes o.
+func (o *offloadConn) shutdown() error {
+	atomic.StoreInt32(&o.closed, 1)
+	o.conn.SetReadDeadline(time.Unix(1, 0)) // unblock the read
+	o.wg.Wait()
+	return o.conn.Close()
+}
+
+// parseOffloadOOB returns the size of the datagrams from the GRO control message in oob, zero when
+// there is none, and, if source is true, the control message that sends the responses from the
+// destination address of the queries.
+func parseOffloadOOB(oob []byte, source bool) (int, []byte) {
+	size := 0
+	if msgs, err := unix.ParseSocketControlMessage(oob); err == nil {
+		for _, m := range msgs {
+			if m.Header.Level == unix.IPPROTO_UDP && m.Header.Type == udpGRO && len(m.Data) >= 4 {
+				size = int(*(*int32)(unsafe.Pointer(&m.Data[0])))
+			}
+		}
+	}
+	if !source {
+		return size, nil
+	}
+
+	cm6 := new(ipv6.ControlMessage)
+	if cm6.Parse(oob) == nil && cm6.Dst != nil && cm6.Dst.To4() == nil {
+		return size, (&ipv6.ControlMessage{Src: cm6.Dst}).Marshal()
+	}
+	cm4 := new(ipv4.ControlMessage)
+	if cm4.Parse(oob) == nil && cm4.Dst != nil {
+		return size, (&ipv4.ControlMessage{Src: cm4.Dst}).Marshal()
+	}
+	return size, nil
+}
+
+// segments splits buf in datagrams of size bytes, the last one may be shorter.
+func segments(buf []byte, size int) [][]byte {
+	if size <= 0 || size >= len(buf) {
+		return [][]byte{buf}
+	}
+	segs := make([][]byte, 0, (len(buf)+size-1)/size)
+	for len(buf) > size {
+		segs = append(segs, buf[:size])
+		buf = buf[size:]
+	}
+	return append(segs, buf)
+}
+
+// segmentOOB returns the control message that has the kernel split a write in datagrams of size bytes.
+func segmentOOB(size int) []byte {
+	b := make([]byte, unix.CmsgSpace(2))
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&b[0]))
+	h.Level = unix.IPPROTO_UDP
+	h.Type = udpSegment
+	h.SetLen(unix.CmsgLen(2))
+	*(*uint16)(unsafe.Pointer(&b[unix.CmsgLen(0)])) = uint16(size)
+	return b
+}
+
+// txMsg is a response waiting to be sent.
+type txMsg struct {
+	b   []byte
+	buf *[]byte // the pooled buffer b is in, nil if it isn't pooled
+}
+
+func (m txMsg) release() {
+	if m.buf != nil {
+		packBufs.Put(m.buf)
+	}
+}
+
+// send sends the responses in msgs to raddr. The responses of the same size are sent together with
+// GSO, as long as GSO works.
+func (o *offloadConn) send(msgs []txMsg, oob []byte, raddr *net.UDPAddr) {
+	var sizes []int
+	bySize := map[int][]txMsg{}
+	for _, m := range msgs {
+		if _, ok := bySize[len(m.b)]; !ok {
+			sizes = append(sizes, len(m.b))
+		}
+		bySize[len(m.b)] = append(bySize[len(m.b)], m)
+	}
+
+	for _, size := range sizes {
+		group := bySize[size]
+		for len(group) > 0 {
+			n := len(group)
+			if n > maxGSOSegments {
+				n = maxGSOSegments
+			}
+			if n > maxGSOBytes/size {
+				n = maxGSOBytes / size
+			}
+			if n < 2 || size > maxGSOSegmentSize || atomic.LoadInt32(&o.gso) == 0 {
+				o.conn.WriteMsgUDP(group[0].b, oob, raddr)
+				group[0].release()
+				group = group[1:]
+				continue
+			}
+
+			buf := offloadBufs.Get().(*[]byte)
+			b := (*buf)[:0]
+			for _, m := range group[:n] {
+				b = append(b, m.b...)
+			}
+			_, _, err := o.conn.WriteMsgUDP(b, append(oob[:len(oob):len(oob)], segmentOOB(size)...), raddr)
+			offloadBufs.Put(buf)
+			if err != nil && isGSOError(err) {
+				if atomic.CompareAndSwapInt32(&o.gso, 1, 0) {
+					log.Warningf("Failed to send with UDP GSO on %s, not using it: %s", o.conn.LocalAddr(), err)
+				}
+				continue // send this group again without GSO
+			}
+			for _, m := range group[:n] {
+				m.release()
+			}
+			group = group[n:]
+		}
+	}
+}
+
+// isGSOError returns true if err means GSO is not supported.
+func isGSOError(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+	switch err {
+	case syscall.EIO, syscall.EINVAL, syscall.EOPNOTSUPP, syscall.ENOPROTOOPT:
+		return true
+	}
+	return false
+}
+
+// udpBatch holds the queries of a single read, these all come from the same client. Their responses
+// are held back until all queries are handled, or until gsoFlushDelay has passed, and then sent
+// together.
+type udpBatch struct {
+	o     *offloadConn
+	raddr *net.UDPAddr
+	oob   []byte  // control message that sets the source address of the responses
+	buf   *[]byte // the buffer the queries were read in, returned to offloadBufs when done
+
+	mu      sync.Mutex
+	pending int      // number of queries being handled
+	out     []txMsg // responses waiting to be sent
+	timer   *time.Timer
+}
+
+func (b *udpBatch) serve(ctx context.Context, s *Server, seg []byte) {
+	defer b.o.wg.Done()
+	defer b.done()
+
+	r := new(dns.Msg)
+	if err := r.Unpack(seg); err != nil || r.Response {
+		return
+	}
+	s.serveUDP(ctx, &offloadWriter{b: b}, r)
+}
+
+// write sends m, or holds on to it to send it with the other responses of b. When m is held on to
+// and buf is not nil, buf is returned to packBufs after m is sent.
+func (b *udpBatch) write(m []byte, buf *[]byte) error {
+	b.mu.Lock()
+	if atomic.LoadInt32(&b.o.gso) == 0 || b.pending == 1 && len(b.out) == 0 || len(m) > maxGSOSegmentSize {
+		// Nothing to send it with, or too large to be sent with GSO. The error is returned, for
+		// dontFragWriter.
+		b.mu.Unlock()
+		_, _, err := b.o.conn.WriteMsgUDP(m, b.oob, b.raddr)
+		txMsg{buf: buf}.release()
+		return err
+	}
+	if buf == nil {
+		m = append([]byte(nil), m...)
+	}
+	b.out = append(b.out, txMsg{b: m, buf: buf})
+	if b.timer == nil {
+		b.timer = time.AfterFunc(gsoFlushDelay, b.flush)
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// done is called when a query of b has been handled.
+func (b *udpBatch) done() {
+	b.mu.Lock()
+	b.pending--
+	last := b.pending == 0
+	b.mu.Unlock()
+	if last {
+		b.flush()
+		offloadBufs.Put(b.buf)
+	}
+}
+
+func (b *udpBatch) flush() {
+	b.mu.Lock()
+	out := b.out
+	b.out = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+	if len(out) > 0 {
+		b.o.send(out, b.oob, b.raddr)
+	}
+}
+
+// offloadWriter is the dns.ResponseWriter for a query read from an offloadConn.
+type offloadWriter struct {
+	b *udpBatch
+}
+
+// LocalAddr implements the dns.ResponseWriter interface.
+func (w *offloadWriter) LocalAddr() net.Addr { return w.b.o.conn.LocalAddr() }
+
+// RemoteAddr implements the dns.ResponseWriter interface.
+func (w *offloadWriter) RemoteAddr() net.Addr { return w.b.raddr }
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *offloadWriter) WriteMsg(m *dns.Msg) error {
+	buf, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *offloadWriter) Write(m []byte) (int, error) {
+	if err := w.b.write(m, nil); err != nil {
+		return 0, err
+	}
+	return len(m), nil
+}
+
+// writeOwned implements the ownedWriter interface.
+func (w *offloadWriter) writeOwned(m []byte, buf *[]byte) error { return w.b.write(m, buf) }
+
+// Close implements the dns.ResponseWriter interface.
+func (w *offloadWriter) Close() error { return nil }
+
+// TsigStatus implements the dns.ResponseWriter interface. The TSIG of the queries is not verified.
+func (w *offloadWriter) TsigStatus() error { return dns.ErrSecret }
+
+// TsigTimersOnly implements the dns.ResponseWriter interface.
+func (w *offloadWriter) TsigTimersOnly(bool) {}
+
+// Hijack implements the dns.ResponseWriter interface.
+func (w *offloadWriter) Hijack() {}
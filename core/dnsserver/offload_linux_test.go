@@ -0,0 +1,133 @@
+// +build linux
+
+package dnsserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/feature"
+
+	"github.com/miekg/dns"
+	"golang.org/x/sys/unix"
+)
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		len, size int
+		expected  []int
+	}{
+		{10, 0, []int{10}},
+		{10, 10, []int{10}},
+		{10, 20, []int{10}},
+		{10, 5, []int{5, 5}},
+		{12, 5, []int{5, 5, 2}},
+	}
+	for i, tc := range tests {
+		segs := segments(make([]byte, tc.len), tc.size)
+		if len(segs) != len(tc.expected) {
+			t.Errorf("Test %d: expected %d segments, got %d", i, len(tc.expected), len(segs))
+			continue
+		}
+		for j := range segs {
+			if len(segs[j]) != tc.expected[j] {
+				t.Errorf("Test %d: expected segment %d of %d bytes, got %d", i, j, tc.expected[j], len(segs[j]))
+			}
+		}
+	}
+}
+
+func TestSegmentOOB(t *testing.T) {
+	msgs, err := unix.ParseSocketControlMessage(segmentOOB(512))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(msgs) != 1 || msgs[0].Header.Level != unix.IPPROTO_UDP || msgs[0].Header.Type != udpSegment {
+		t.Fatalf("Expected a single UDP_SEGMENT control message, got %v", msgs)
+	}
+	if size := int(msgs[0].Data[0]) | int(msgs[0].Data[1])<<8; size != 512 {
+		t.Errorf("Expected segment size 512, got %d", size)
+	}
+}
+
+func TestOffload(t *testing.T) {
+	if err := feature.Set(map[string]bool{"udp_offload": true}); err != nil {
+		t.Fatal(err)
+	}
+	defer feature.Set(nil)
+
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	c := testConfig("dns", h)
+	c.Zone = "."
+	s, err := NewServer("127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServePacket(pc)
+	defer s.Stop()
+
+	// Wait for the server to start and see whether it uses offload.
+	time.Sleep(50 * time.Millisecond)
+	s.m.Lock()
+	offload := s.offload != nil
+	s.m.Unlock()
+	if !offload {
+		t.Skip("UDP GRO is not supported")
+	}
+
+	client, err := net.DialUDP("udp", nil, pc.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// Send three queries of the same size in a single write, with GRO the server reads them at once.
+	var buf []byte
+	size := 0
+	for id := uint16(1); id <= 3; id++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		m.Id = id
+		b, _ := m.Pack()
+		size = len(b)
+		buf = append(buf, b...)
+	}
+	if _, _, err := client.WriteMsgUDP(buf, segmentOOB(size), nil); err != nil {
+		t.Logf("Client can't send with GSO, sending the queries one by one: %s", err)
+		for _, seg := range segments(buf, size) {
+			client.Write(seg)
+		}
+	}
+
+	seen := map[uint16]bool{}
+	resp := make([]byte, dns.MaxMsgSize)
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(seen) < 3 {
+		n, err := client.Read(resp)
+		if err != nil {
+			t.Fatalf("Expected 3 responses, got %d: %s", len(seen), err)
+		}
+		m := new(dns.Msg)
+		if err := m.Unpack(resp[:n]); err != nil {
+			t.Fatalf("Expected a valid response, got %s", err)
+		}
+		seen[m.Id] = true
+	}
+	for id := uint16(1); id <= 3; id++ {
+		if !seen[id] {
+			t.Errorf("Expected a response for query %d", id)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+// +build !linux
+
+package dnsserver
+
+import "net"
+
+// offloadConn is only implemented on Linux.
+type offloadConn struct{}
+
+func newOffloadConn(p net.PacketConn) *offloadConn { return nil }
+
+func (o *offloadConn) serve(s *Server) error { return nil }
+
+func (o *offloadConn) shutdown() error { return nil }
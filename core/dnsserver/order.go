@@ -0,0 +1,108 @@
+package dnsserver
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+// Order moves the plugin Name directly before or after the plugin Other in the plugin chain of a
+// server block, overriding the order of the plugins in Directives. Plugins are named after their
+// directive.
+type Order struct {
+	Name   string
+	Before bool
+	Other  string
+}
+
+var (
+	namesMu         sync.Mutex
+	namesRegistered = map[string]bool{}
+)
+
+// registerPluginNames registers a parsing callback for each directive that records the name of the
+// plugins the directive added to the configs. The callbacks are registered once, directives that are
+// registered later (see RegisterDirective) are picked up on the next call.
+func registerPluginNames() {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	for _, dir := range Directives {
+		if namesRegistered[dir] {
+			continue
+		}
+		namesRegistered[dir] = true
+		name := dir
+		caddy.RegisterParsingCallback(serverType, name, func(c caddy.Context) error {
+			if h, ok := c.(*dnsContext); ok {
+				h.recordPluginNames(name)
+			}
+			return nil
+		})
+	}
+}
+
+// recordPluginNames names the plugins added to the configs since the last call after dir.
+func (h *dnsContext) recordPluginNames(dir string) {
+	for _, c := range h.configs {
+		for len(c.pluginNames) < len(c.Plugin) {
+			c.pluginNames = append(c.pluginNames, dir)
+		}
+	}
+}
+
+// orderPlugins applies c.Order to the plugins of c.
+func (c *Config) orderPlugins() error {
+	if len(c.Order) == 0 {
+		return nil
+	}
+	if len(c.pluginNames) != len(c.Plugin) {
+		return fmt.Errorf("plugins can not be ordered: names of the plugins are unknown")
+	}
+
+	for _, o := range c.Order {
+		if o.Name == o.Other {
+			return fmt.Errorf("plugin %q can not be ordered relative to itself", o.Name)
+		}
+
+		var (
+			moved      []plugin.Plugin
+			rest       []plugin.Plugin
+			restNames  []string
+			movedNames []string
+		)
+		for i, p := range c.Plugin {
+			if c.pluginNames[i] == o.Name {
+				moved = append(moved, p)
+				movedNames = append(movedNames, o.Name)
+				continue
+			}
+			rest = append(rest, p)
+			restNames = append(restNames, c.pluginNames[i])
+		}
+		if len(moved) == 0 {
+			return fmt.Errorf("plugin %q is not used in server block %q", o.Name, c.Zone)
+		}
+
+		pos := -1
+		for i, n := range restNames {
+			if n != o.Other {
+				continue
+			}
+			pos = i + 1
+			if o.Before {
+				pos = i
+				break
+			}
+		}
+		if pos == -1 {
+			return fmt.Errorf("plugin %q is not used in server block %q", o.Other, c.Zone)
+		}
+
+		c.Plugin = append(append(append([]plugin.Plugin{}, rest[:pos]...), moved...), rest[pos:]...)
+		c.pluginNames = append(append(append([]string{}, restNames[:pos]...), movedNames...), restNames[pos:]...)
+	}
+	return nil
+}
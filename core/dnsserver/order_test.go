@@ -0,0 +1,74 @@
+package dnsserver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/miekg/dns"
+)
+
+// namedPlugin returns a plugin whose handler has the given name.
+func namedPlugin(name string) plugin.Plugin {
+	return func(next plugin.Handler) plugin.Handler { return namedHandler{name, next} }
+}
+
+type namedHandler struct {
+	name string
+	next plugin.Handler
+}
+
+func (n namedHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	return 0, nil
+}
+
+func (n namedHandler) Name() string { return n.name }
+
+func TestOrderPlugins(t *testing.T) {
+	tests := []struct {
+		order     []Order
+		expected  string
+		shouldErr bool
+	}{
+		{nil, "a,b,c,d", false},
+		{[]Order{{Name: "d", Before: true, Other: "a"}}, "d,a,b,c", false},
+		{[]Order{{Name: "a", Other: "d"}}, "b,c,d,a", false},
+		{[]Order{{Name: "a", Other: "b"}, {Name: "d", Before: true, Other: "c"}}, "b,a,d,c", false},
+		{[]Order{{Name: "a", Other: "x"}}, "", true},
+		{[]Order{{Name: "x", Other: "a"}}, "", true},
+		{[]Order{{Name: "a", Other: "a"}}, "", true},
+	}
+
+	for i, tc := range tests {
+		c := &Config{Zone: "example.org."}
+		for _, n := range []string{"a", "b", "c", "d"} {
+			c.AddPlugin(namedPlugin(n))
+			c.pluginNames = append(c.pluginNames, n)
+		}
+		c.Order = tc.order
+
+		err := c.orderPlugins()
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got %s", i, err)
+			continue
+		}
+		names := []string{}
+		for _, p := range c.Plugin {
+			names = append(names, p(nil).Name())
+		}
+		if x := strings.Join(names, ","); x != tc.expected {
+			t.Errorf("Test %d: Expected order %s, got %s", i, tc.expected, x)
+		}
+		if x := strings.Join(c.pluginNames, ","); x != tc.expected {
+			t.Errorf("Test %d: Expected names %s, got %s", i, tc.expected, x)
+		}
+	}
+}
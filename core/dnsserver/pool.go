@@ -0,0 +1,60 @@
+package dnsserver
+
+import (
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// packBufSize is the size of the buffers in packBufs, it holds nearly all responses over UDP.
+// Larger responses are packed in a buffer allocated by dns.Msg.PackBuffer.
+const packBufSize = 4096
+
+// packBufs holds the buffers responses are packed in before they are written.
+var packBufs = sync.Pool{New: func() interface{} { b := make([]byte, packBufSize); return &b }}
+
+// pooledWriter packs the responses written with WriteMsg in a buffer from packBufs, instead of the
+// buffer dns.Msg.Pack allocates for every response. The buffer is reused as soon as Write returns,
+// so pooledWriter must only wrap writers that are done with the buffer by then, like the UDP and TCP
+// writers of dns.Server and udpWriter, or writers that take the buffer over, see ownedWriter. The
+// writers of DoH and gRPC keep the buffer and are not wrapped.
+//
+// The queries are not pooled: plugins hold on to them after the response is written, e.g. to log
+// them or to send them to dnstap.
+type pooledWriter struct {
+	dns.ResponseWriter
+}
+
+// ownedWriter is implemented by writers that may hold on to the response after writeOwned returns.
+// Instead of copying the response, they take over buf: they return it to packBufs when they are
+// done with the response. buf is nil when the response isn't in a pooled buffer.
+type ownedWriter interface {
+	writeOwned(m []byte, buf *[]byte) error
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w pooledWriter) WriteMsg(m *dns.Msg) error {
+	if m.IsTsig() != nil {
+		// The writers of dns.Server sign these while packing them.
+		return w.ResponseWriter.WriteMsg(m)
+	}
+	buf := packBufs.Get().(*[]byte)
+	b, err := m.PackBuffer(*buf)
+	if err != nil {
+		packBufs.Put(buf)
+		return err
+	}
+
+	if ow, ok := w.ResponseWriter.(ownedWriter); ok {
+		if &b[0] != &(*buf)[0] {
+			// Too large, PackBuffer allocated a new buffer.
+			packBufs.Put(buf)
+			buf = nil
+		}
+		return ow.writeOwned(b, buf)
+	}
+
+	_, err = w.Write(b)
+	packBufs.Put(buf)
+	return err
+}
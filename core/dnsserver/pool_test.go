@@ -0,0 +1,80 @@
+package dnsserver
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// packetWriter records the bytes written, like a socket would.
+type packetWriter struct {
+	test.ResponseWriter
+	packets [][]byte
+}
+
+func (w *packetWriter) Write(b []byte) (int, error) {
+	w.packets = append(w.packets, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func TestPooledWriter(t *testing.T) {
+	pw := &packetWriter{}
+	w := pooledWriter{pw}
+
+	small := new(dns.Msg)
+	small.SetQuestion("example.org.", dns.TypeA)
+	small.Answer = []dns.RR{test.A("example.org. 3600 IN A 127.0.0.1")}
+
+	// Larger than packBufSize, so it can't be packed in a pooled buffer.
+	large := new(dns.Msg)
+	large.SetQuestion("example.org.", dns.TypeTXT)
+	for i := 0; i < 20; i++ {
+		txt := &dns.TXT{Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeTXT, Class: dns.ClassINET}}
+		for j := 0; j < 2; j++ {
+			txt.Txt = append(txt.Txt, string(make([]byte, 200)))
+		}
+		large.Answer = append(large.Answer, txt)
+	}
+
+	for _, m := range []*dns.Msg{small, large, small} {
+		if err := w.WriteMsg(m); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+	}
+	if len(pw.packets) != 3 {
+		t.Fatalf("Expected 3 packets, got %d", len(pw.packets))
+	}
+	if len(pw.packets[1]) <= packBufSize {
+		t.Errorf("Expected a response larger than %d bytes, got %d", packBufSize, len(pw.packets[1]))
+	}
+	for i, m := range []*dns.Msg{small, large, small} {
+		expected, _ := m.Pack()
+		if string(pw.packets[i]) != string(expected) {
+			t.Errorf("Test %d: expected the packed response", i)
+		}
+	}
+}
+
+func BenchmarkPack(b *testing.B) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Answer = []dns.RR{test.A("example.org. 3600 IN A 127.0.0.1")}
+
+	b.Run("Pack", func(b *testing.B) {
+		w := &test.ResponseWriter{}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf, _ := m.Pack()
+			w.Write(buf)
+		}
+	})
+	b.Run("pooledWriter", func(b *testing.B) {
+		w := pooledWriter{&test.ResponseWriter{}}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w.WriteMsg(m)
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package dnsserver
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+
+	"github.com/miekg/dns"
+)
+
+// DNS push notifications (RFC 8765) run on DSO sessions: a client subscribes to a name and type with
+// a Subscribe request, and the server pushes the records of that name and type, when subscribing and
+// then every time they change. The plugins tell when the data of a zone changes with changes.Notify,
+// and the subscriptions under that zone are then resolved again, through the plugins of the server,
+// to find what changed.
+
+// removed is the TTL of a pushed record that is removed, RFC 8765, section 6.3.1.
+const removed = 0xFFFFFFFF
+
+// subscription is a Subscribe request of a connection, keyed by its message ID in dsoConn.subs.
+type subscription struct {
+	q   dns.Question
+	rrs []dns.RR // the records last pushed
+}
+
+// subscribe handles the Subscribe request with the message ID id and the TLV data data.
+func (d *dsoSessions) subscribe(c *dsoConn, id uint16, data []byte) {
+	q, ok := parseSubscribe(data)
+	if !ok {
+		c.write(c.w, id, dns.RcodeFormatError)
+		return
+	}
+
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+
+	rrs, rcode := d.resolve(c.w, q)
+	if rcode != dns.RcodeSuccess && rcode != dns.RcodeNameError {
+		c.write(c.w, id, rcode)
+		return
+	}
+	d.mu.Lock()
+	c.established = true
+	c.subs[id] = &subscription{q: q, rrs: rrs}
+	d.mu.Unlock()
+
+	c.write(c.w, id, dns.RcodeSuccess)
+	c.push(rrs, nil)
+}
+
+// parseSubscribe returns the question of the data of a Subscribe TLV: an uncompressed name, a type
+// and a class.
+func parseSubscribe(data []byte) (dns.Question, bool) {
+	name, off, err := dns.UnpackDomainName(data, 0)
+	if err != nil || len(data) != off+4 {
+		return dns.Question{}, false
+	}
+	q := dns.Question{Name: name, Qtype: binary.BigEndian.Uint16(data[off:]), Qclass: binary.BigEndian.Uint16(data[off+2:])}
+	if q.Qclass != dns.ClassINET && q.Qclass != dns.ClassANY {
+		return dns.Question{}, false
+	}
+	return q, true
+}
+
+// resolve returns the records answering q, and the rcode of the response of the server. w is the
+// writer of the connection of the subscription, the plugins see its remote address.
+func (d *dsoSessions) resolve(w dns.ResponseWriter, q dns.Question) ([]dns.RR, int) {
+	r := new(dns.Msg)
+	r.SetQuestion(q.Name, q.Qtype)
+
+	nw := nonwriter.New(w)
+	ctx := context.WithValue(context.Background(), Key{}, d.s)
+	d.s.ServeDNS(ctx, nw, r)
+	if nw.Msg == nil {
+		return nil, dns.RcodeServerFailure
+	}
+
+	// Only the records of the name and type are pushed, not the CNAMEs followed to get them.
+	var rrs []dns.RR
+	for _, rr := range nw.Msg.Answer {
+		h := rr.Header()
+		if strings.EqualFold(h.Name, q.Name) && (h.Rrtype == q.Qtype || q.Qtype == dns.TypeANY) {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs, nw.Msg.Rcode
+}
+
+// push writes Push messages with the records added and the records removed to the connection.
+func (c *dsoConn) push(added, gone []dns.RR) {
+	var rrs []dns.RR
+	for _, rr := range gone {
+		rr = dns.Copy(rr)
+		rr.Header().Ttl = removed
+		rrs = append(rrs, rr)
+	}
+	rrs = append(rrs, added...)
+
+	data := make([]byte, 0, 512)
+	for _, rr := range rrs {
+		b := make([]byte, dns.Len(rr))
+		n, err := dns.PackRR(rr, b, 0, nil, false)
+		if err != nil {
+			continue
+		}
+		// A message holds at most 64KB, the rest goes in the next one.
+		if len(data)+n > dns.MaxMsgSize-16 {
+			c.writePush(data)
+			data = data[:0]
+		}
+		data = append(data, b[:n]...)
+	}
+	if len(data) > 0 {
+		c.writePush(data)
+	}
+}
+
+func (c *dsoConn) writePush(data []byte) {
+	b := packDSO(0, 0, tlv{typ: dsoPush, data: data})
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.w.Write(b)
+}
+
+// changed is called with the zone whose data changed. The subscriptions are resolved again in
+// another goroutine, and the changes of several zones made meanwhile are handled together.
+func (d *dsoSessions) changed(zone string) {
+	d.mu.Lock()
+	d.pending[dns.Fqdn(strings.ToLower(zone))] = true
+	run := !d.pushing
+	d.pushing = true
+	d.mu.Unlock()
+	if run {
+		go d.pushChanges()
+	}
+}
+
+// pushChanges pushes the changes of the subscriptions under the pending zones, until there are no
+// more pending zones.
+func (d *dsoSessions) pushChanges() {
+	type item struct {
+		c   *dsoConn
+		id  uint16
+		sub *subscription
+	}
+	for {
+		d.mu.Lock()
+		if len(d.pending) == 0 {
+			d.pushing = false
+			d.mu.Unlock()
+			return
+		}
+		zones := d.pending
+		d.pending = map[string]bool{}
+		var items []item
+		for _, c := range d.conns {
+			for id, sub := range c.subs {
+				for zone := range zones {
+					if dns.IsSubDomain(zone, sub.q.Name) {
+						items = append(items, item{c, id, sub})
+						break
+					}
+				}
+			}
+		}
+		d.mu.Unlock()
+
+		for _, it := range items {
+			d.refresh(it.c, it.id, it.sub)
+		}
+	}
+}
+
+// refresh resolves the subscription sub of c with the message ID id again, and pushes what changed.
+func (d *dsoSessions) refresh(c *dsoConn, id uint16, sub *subscription) {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+
+	rrs, rcode := d.resolve(c.w, sub.q)
+	if rcode != dns.RcodeSuccess && rcode != dns.RcodeNameError {
+		return // keep the records pushed until the name can be resolved again
+	}
+	added, gone := diff(sub.rrs, rrs)
+
+	d.mu.Lock()
+	current := c.subs[id] == sub
+	if current {
+		sub.rrs = rrs
+	}
+	d.mu.Unlock()
+	if current && (len(added) > 0 || len(gone) > 0) {
+		c.push(added, gone)
+	}
+}
+
+// diff returns the records of new that aren't in old, and the records of old that aren't in new.
+func diff(old, new []dns.RR) (added, gone []dns.RR) {
+	for _, rr := range new {
+		if !containsRR(old, rr) {
+			added = append(added, rr)
+		}
+	}
+	for _, rr := range old {
+		if !containsRR(new, rr) {
+			gone = append(gone, rr)
+		}
+	}
+	return added, gone
+}
+
+// containsRR returns true if rrs holds rr, the TTLs aside.
+func containsRR(rrs []dns.RR, rr dns.RR) bool {
+	for _, r := range rrs {
+		if dns.IsDuplicate(r, rr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package dnsserver
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/changes"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseSubscribe(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected bool
+	}{
+		{subscribeData("example.org.", dns.TypeA, dns.ClassINET), true},
+		{subscribeData("example.org.", dns.TypeANY, dns.ClassANY), true},
+		{subscribeData("example.org.", dns.TypeA, dns.ClassCHAOS), false},
+		{subscribeData("example.org.", dns.TypeA, dns.ClassINET)[:10], false},
+		{append(subscribeData("example.org.", dns.TypeA, dns.ClassINET), 0), false},
+	}
+	for i, tc := range tests {
+		if _, ok := parseSubscribe(tc.data); ok != tc.expected {
+			t.Errorf("Test %d: expected %t, got %t", i, tc.expected, ok)
+		}
+	}
+}
+
+func TestPush(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		addr = "192.0.2.1"
+	)
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.Question[0].Name == "push.example.org." {
+			mu.Lock()
+			m.Answer = []dns.RR{test.A("push.example.org. 300 IN A " + addr)}
+			mu.Unlock()
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	s, conn := startDSOServer(t, true, h)
+	defer s.Stop()
+	defer conn.Close()
+
+	writeTCP(t, conn, dsoRequest(1, tlv{typ: dsoSubscribe, data: subscribeData("push.example.org.", dns.TypeA, dns.ClassINET)}))
+	res := readTCP(t, conn)
+	if id, rcode := binary.BigEndian.Uint16(res), int(res[3]&0xF); id != 1 || rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected a successful response to the subscription, got ID %d and rcode %d", id, rcode)
+	}
+	expectPush(t, readTCP(t, conn), "push.example.org.\t300\tIN\tA\t192.0.2.1")
+
+	// A change of another zone is not pushed, and a change of the zone pushes the removed and the
+	// added records.
+	changes.Notify("example.net.")
+	mu.Lock()
+	addr = "192.0.2.2"
+	mu.Unlock()
+	changes.Notify("example.org.")
+	expectPush(t, readTCP(t, conn),
+		"push.example.org.\t4294967295\tIN\tA\t192.0.2.1",
+		"push.example.org.\t300\tIN\tA\t192.0.2.2",
+	)
+
+	// After unsubscribing the changes are not pushed anymore.
+	unsub := tlv{typ: dsoUnsubscribe, data: []byte{0, 1}}
+	writeTCP(t, conn, dsoRequest(0, unsub))
+	expectReply(t, conn)
+	mu.Lock()
+	addr = "192.0.2.3"
+	mu.Unlock()
+	changes.Notify("example.org.")
+	expectReply(t, conn)
+}
+
+func subscribeData(name string, qtype, qclass uint16) []byte {
+	b := make([]byte, 256)
+	n, _ := dns.PackDomainName(name, b, 0, nil, false)
+	b = b[:n+4]
+	binary.BigEndian.PutUint16(b[n:], qtype)
+	binary.BigEndian.PutUint16(b[n+2:], qclass)
+	return b
+}
+
+func writeTCP(t *testing.T, conn net.Conn, b []byte) {
+	if _, err := conn.Write(append([]byte{byte(len(b) >> 8), byte(len(b))}, b...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectPush checks that b is a Push message with the records rrs.
+func expectPush(t *testing.T, b []byte, rrs ...string) {
+	t.Helper()
+	tlvs, err := parseTLVs(b[12:])
+	if err != nil || len(tlvs) != 1 || tlvs[0].typ != dsoPush || binary.BigEndian.Uint16(b) != 0 {
+		t.Fatalf("Expected a Push message, got %v (%v)", tlvs, err)
+	}
+	var got []string
+	for off := 0; off < len(tlvs[0].data); {
+		rr, o, err := dns.UnpackRR(tlvs[0].data, off)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, rr.String())
+		off = o
+	}
+	if len(got) != len(rrs) {
+		t.Fatalf("Expected records %v, got %v", rrs, got)
+	}
+	for i := range rrs {
+		if got[i] != rrs[i] {
+			t.Errorf("Expected record %q, got %q", rrs[i], got[i])
+		}
+	}
+}
+
+// expectReply sends a query on conn, and checks the next message read is its response.
+func expectReply(t *testing.T, conn net.Conn) {
+	t.Helper()
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if err := (&dns.Conn{Conn: conn}).WriteMsg(m); err != nil {
+		t.Fatal(err)
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(readTCP(t, conn)); err != nil || r.Id != m.Id || !r.Response {
+		t.Errorf("Expected the response to the query, got %v (%v)", r, err)
+	}
+}
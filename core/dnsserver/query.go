@@ -0,0 +1,149 @@
+package dnsserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+// QueryResult is the result of Query.
+type QueryResult struct {
+	Key     string          // key of the server block that handled the query
+	Msg     *dns.Msg        // response written by the plugin chain, nil if none was written
+	Rcode   int             // rcode returned by the plugin chain
+	Timings []plugin.Timing // time spent in each plugin
+}
+
+// Key returns the key of the server block c belongs to, i.e. "dns://example.org.:53", followed by
+// the name of its view, if any, i.e. "dns://example.org.:53#internal".
+func (c *Config) Key() string {
+	key := c.Transport + "://" + c.Zone + ":" + c.Port
+	if c.ViewName != "" {
+		key += "#" + c.ViewName
+	}
+	return key
+}
+
+// dnsServer returns s, it is promoted to all server types that embed a *Server.
+func (s *Server) dnsServer() *Server { return s }
+
+// Blocks returns the keys of all server blocks of instance i, sorted.
+func Blocks(i *caddy.Instance) []string {
+	var keys []string
+	for _, b := range blocks(i) {
+		keys = append(keys, b.config.Key())
+	}
+	return keys
+}
+
+type block struct {
+	server *Server
+	config *Config
+}
+
+// blocks returns the server blocks of instance i sorted by key.
+func blocks(i *caddy.Instance) []block {
+	var bs []block
+	for _, srv := range Servers(i) {
+		ds, ok := srv.(interface{ dnsServer() *Server })
+		if !ok {
+			continue
+		}
+		s := ds.dnsServer()
+		for _, configs := range s.zones {
+			for _, c := range configs {
+				bs = append(bs, block{server: s, config: c})
+			}
+		}
+	}
+	sort.Slice(bs, func(i, j int) bool { return bs[i].config.Key() < bs[j].config.Key() })
+	return bs
+}
+
+// Query runs r through the plugin chain of the server block key of instance i and records the time
+// spent in each plugin. If key is empty, the server block with the most specific zone for the query
+// name is used. Unlike ServeDNS, no zone matching is done, the chain of the selected block is always
+// called.
+func Query(ctx context.Context, i *caddy.Instance, key string, r *dns.Msg) (*QueryResult, error) {
+	if r == nil || len(r.Question) == 0 {
+		return nil, fmt.Errorf("no question in query")
+	}
+
+	var b *block
+	zone := ""
+	for _, x := range blocks(i) {
+		x := x
+		if key != "" {
+			if x.config.Key() == key {
+				b = &x
+				break
+			}
+			continue
+		}
+		z := plugin.Zones([]string{x.config.Zone}).Matches(r.Question[0].Name)
+		if z != "" && len(z) > len(zone) {
+			b, zone = &x, z
+		}
+	}
+	if b == nil {
+		if key != "" {
+			return nil, fmt.Errorf("no server block %q", key)
+		}
+		return nil, fmt.Errorf("no server block for %q", r.Question[0].Name)
+	}
+	if b.config.pluginChain == nil {
+		return nil, fmt.Errorf("server block %q has no plugins", b.config.Key())
+	}
+
+	ctx = context.WithValue(ctx, Key{}, b.server)
+	ctx = plugin.WithDeadline(ctx, time.Now().Add(b.server.queryTimeout))
+	t := &plugin.Timings{}
+	ctx = plugin.WithTimings(ctx, t)
+
+	w := &queryWriter{}
+	rcode, err := serveQuery(ctx, b.config.pluginChain, w, r)
+	return &QueryResult{Key: b.config.Key(), Msg: w.msg, Rcode: rcode, Timings: t.List()}, err
+}
+
+// serveQuery calls h, a panic in h is returned as an error.
+func serveQuery(ctx context.Context, h plugin.Handler, w dns.ResponseWriter, r *dns.Msg) (rcode int, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			rcode, err = dns.RcodeServerFailure, fmt.Errorf("panic: %v", rec)
+		}
+	}()
+	return plugin.ServeTimed(ctx, h, w, r)
+}
+
+// queryWriter is the dns.ResponseWriter used by Query, it saves the response.
+type queryWriter struct {
+	msg *dns.Msg
+}
+
+var queryAddr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+func (w *queryWriter) LocalAddr() net.Addr  { return queryAddr }
+func (w *queryWriter) RemoteAddr() net.Addr { return queryAddr }
+func (w *queryWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+func (w *queryWriter) Write(buf []byte) (int, error) {
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return 0, err
+	}
+	w.msg = m
+	return len(buf), nil
+}
+func (w *queryWriter) Close() error        { return nil }
+func (w *queryWriter) TsigStatus() error   { return dns.ErrSecret }
+func (w *queryWriter) TsigTimersOnly(bool) {}
+func (w *queryWriter) Hijack()             {}
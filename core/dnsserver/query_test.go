@@ -0,0 +1,88 @@
+package dnsserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+type chainHandler struct {
+	name string
+	next plugin.Handler
+}
+
+func (n chainHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	if n.next != nil {
+		return plugin.NextOrFailure(n.name, n.next, ctx, w, r)
+	}
+	m := new(dns.Msg)
+	m.SetReply(r)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+func (n chainHandler) Name() string { return n.name }
+
+func TestQuery(t *testing.T) {
+	var configs []*Config
+	for _, zone := range []string{".", "example.org."} {
+		c := &Config{Zone: zone, Transport: "dns", Port: "1053"}
+		c.AddPlugin(func(next plugin.Handler) plugin.Handler { return chainHandler{name: "first", next: next} })
+		c.AddPlugin(func(next plugin.Handler) plugin.Handler { return chainHandler{name: "second", next: next} })
+		configs = append(configs, c)
+	}
+	s, err := NewServer("dns://:1053", configs)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	i := &caddy.Instance{Storage: map[interface{}]interface{}{serversKey{}: []caddy.Server{s}}}
+
+	if x := Blocks(i); len(x) != 2 || x[0] != "dns://.:1053" || x[1] != "dns://example.org.:1053" {
+		t.Errorf("Expected two blocks, got %v", x)
+	}
+
+	tests := []struct {
+		key       string
+		qname     string
+		expected  string
+		shouldErr bool
+	}{
+		{"", "www.example.org.", "dns://example.org.:1053", false},
+		{"", "example.net.", "dns://.:1053", false},
+		{"dns://.:1053", "www.example.org.", "dns://.:1053", false},
+		{"dns://example.net.:1053", "www.example.org.", "", true},
+	}
+	for j, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		res, err := Query(context.TODO(), i, tc.key, m)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, got none", j)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got %s", j, err)
+			continue
+		}
+		if res.Key != tc.expected {
+			t.Errorf("Test %d: Expected block %s, got %s", j, tc.expected, res.Key)
+		}
+		if res.Msg == nil || res.Msg.Question[0].Name != tc.qname {
+			t.Errorf("Test %d: Expected response for %s, got %v", j, tc.qname, res.Msg)
+		}
+		if len(res.Timings) != 2 {
+			t.Fatalf("Test %d: Expected 2 timings, got %d", j, len(res.Timings))
+		}
+		for k, name := range []string{"first", "second"} {
+			if x := res.Timings[k]; x.Plugin != name || x.Depth != k {
+				t.Errorf("Test %d: Expected timing for %s at depth %d, got %s at depth %d", j, name, k, x.Plugin, x.Depth)
+			}
+		}
+	}
+}
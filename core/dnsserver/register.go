@@ -37,12 +37,15 @@ func init() {
 }
 
 func newContext(i *caddy.Instance) caddy.Context {
-	return &dnsContext{keysToConfigs: make(map[string]*Config)}
+	return &dnsContext{keysToConfigs: make(map[string]*Config), instance: i}
 }
 
 type dnsContext struct {
 	keysToConfigs map[string]*Config
 
+	// instance is the caddy instance this context belongs to.
+	instance *caddy.Instance
+
 	// configs is the master list of all site configs.
 	configs []*Config
 }
@@ -56,6 +59,8 @@ func (h *dnsContext) saveConfig(key string, cfg *Config) {
 // executing directives and otherwise prepares the directives to
 // be parsed and executed.
 func (h *dnsContext) InspectServerBlocks(sourceFile string, serverBlocks []caddyfile.ServerBlock) ([]caddyfile.ServerBlock, error) {
+	registerPluginNames()
+
 	// Normalize and check all the zone names and check for duplicates
 	for ib, s := range serverBlocks {
 		for ik, k := range s.Keys {
@@ -104,6 +109,16 @@ func (h *dnsContext) MakeServers() ([]caddy.Server, error) {
 		return nil, errValid
 	}
 
+	for _, c := range h.configs {
+		if err := c.orderPlugins(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyFeatures(h.configs); err != nil {
+		return nil, err
+	}
+
 	// we must map (group) each config to a bind address
 	groups, err := groupConfigsByListenAddr(h.configs)
 	if err != nil {
@@ -148,13 +163,61 @@ func (h *dnsContext) MakeServers() ([]caddy.Server, error) {
 				return nil, err
 			}
 			servers = append(servers, s)
+
+		case transport.QUIC:
+			s, err := NewServerQUIC(addr, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, s)
+
+		case transport.H3:
+			s, err := NewServerH3(addr, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, s)
+
+		case transport.DTLS:
+			s, err := NewServerDTLS(addr, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, s)
 		}
 
 	}
 
+	h.saveServers(servers)
+
 	return servers, nil
 }
 
+// serversKey is the key under which the servers are stored in the instance's storage.
+type serversKey struct{}
+
+// saveServers stores servers in the storage of the instance, so they can be retrieved with Servers.
+func (h *dnsContext) saveServers(servers []caddy.Server) {
+	if h.instance == nil {
+		return
+	}
+	h.instance.StorageMu.Lock()
+	defer h.instance.StorageMu.Unlock()
+	if h.instance.Storage == nil {
+		h.instance.Storage = make(map[interface{}]interface{})
+	}
+	h.instance.Storage[serversKey{}] = servers
+}
+
+// Servers returns the servers created for instance i. Each server is one of *Server, *ServerTLS,
+// *ServergRPC, *ServerHTTPS or *ServerHTTP.
+func Servers(i *caddy.Instance) []caddy.Server {
+	i.StorageMu.RLock()
+	defer i.StorageMu.RUnlock()
+	servers, _ := i.Storage[serversKey{}].([]caddy.Server)
+	return servers
+}
+
 // AddPlugin adds a plugin to a site's plugin stack.
 func (c *Config) AddPlugin(m plugin.Plugin) {
 	c.Plugin = append(c.Plugin, m)
@@ -204,6 +267,9 @@ func (h *dnsContext) validateZonesAndListeningAddresses() error {
 	//Validate Zone and addresses
 	checker := newOverlapZone()
 	for _, conf := range h.configs {
+		if len(conf.Filters) > 0 {
+			continue
+		}
 		for _, h := range conf.ListenHosts {
 			// Validate the overlapping of ZoneAddr
 			akey := zoneAddr{Transport: conf.Transport, Zone: conf.Zone, Address: h, Port: conf.Port}
@@ -217,6 +283,27 @@ func (h *dnsContext) validateZonesAndListeningAddresses() error {
 
 		}
 	}
+	// The views share the zone with the server block without filters, they must share its listener
+	// too, and have distinct names.
+	views := make(map[zoneAddr]map[string]bool)
+	for _, conf := range h.configs {
+		if len(conf.Filters) == 0 {
+			continue
+		}
+		for _, h := range conf.ListenHosts {
+			akey := zoneAddr{Transport: conf.Transport, Zone: conf.Zone, Address: h, Port: conf.Port}
+			if _, overlapZone := checker.check(akey); overlapZone != nil {
+				return fmt.Errorf("cannot serve %s - zone overlap listener capacity with %v", akey.String(), overlapZone.String())
+			}
+			if views[akey] == nil {
+				views[akey] = make(map[string]bool)
+			}
+			if views[akey][conf.ViewName] {
+				return fmt.Errorf("cannot serve %s - view %s is already defined", akey.String(), conf.ViewName)
+			}
+			views[akey][conf.ViewName] = true
+		}
+	}
 	return nil
 
 }
@@ -252,7 +339,8 @@ var (
 	// Port is the port we listen on by default.
 	Port = DefaultPort
 
-	// GracefulTimeout is the maximum duration of a graceful shutdown.
+	// GracefulTimeout is the maximum duration of a graceful shutdown, the servers wait this long
+	// for the in-flight queries to finish. When zero 5 seconds is used.
 	GracefulTimeout time.Duration
 )
 
@@ -1,7 +1,10 @@
 package dnsserver
 
 import (
+	"context"
 	"testing"
+
+	"github.com/coredns/coredns/request"
 )
 
 func TestHandler(t *testing.T) {
@@ -119,3 +122,31 @@ func TestGroupingServers(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateViews(t *testing.T) {
+	view := func(name string) *Config {
+		return &Config{Transport: "dns", Zone: "example.org.", Port: "53", ListenHosts: []string{""}, ViewName: name,
+			Filters: []Filter{func(context.Context, *request.Request) bool { return true }}}
+	}
+	all := &Config{Transport: "dns", Zone: "example.org.", Port: "53", ListenHosts: []string{""}}
+
+	for i, test := range []struct {
+		configs []*Config
+		failing bool
+	}{
+		{configs: []*Config{view("a"), all}},
+		{configs: []*Config{view("a"), view("b")}},
+		{configs: []*Config{view("a"), view("a")}, failing: true},
+		{configs: []*Config{view("a"), {Transport: "dns", Zone: "example.org.", Port: "53", ListenHosts: []string{"127.0.0.1"}}}, failing: true},
+		{configs: []*Config{all, all}, failing: true},
+	} {
+		h := &dnsContext{configs: test.configs}
+		err := h.validateZonesAndListeningAddresses()
+		if test.failing && err == nil {
+			t.Errorf("Test %d: expected an error, got none", i)
+		}
+		if !test.failing && err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+		}
+	}
+}
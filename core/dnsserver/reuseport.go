@@ -0,0 +1,98 @@
+package dnsserver
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/miekg/dns"
+)
+
+// reusePortGroup holds the servers of the sockets a server opens besides its listeners.
+type reusePortGroup struct {
+	mu      sync.Mutex
+	servers []*dns.Server
+	stop    []func() // releases the DSO sessions and socket filters of the sockets
+	closed  bool     // true once shut down, the servers started later are stopped right away
+}
+
+// serveReusePort opens s.reusePort-1 more sockets of network in the SO_REUSEPORT group of addr, and
+// serves the queries received on them, each with its own accept (TCP) or read (UDP) loop. The kernel
+// spreads the connections and packets over the sockets, and so over more goroutines and cores. A
+// socket that can't be opened, e.g. because the listener was created without SO_REUSEPORT, is
+// logged and skipped.
+func (s *Server) serveReusePort(network string, addr net.Addr) {
+	for i := 1; i < s.reusePort; i++ {
+		srv := &dns.Server{Net: network, TsigSecret: s.tsigSecret, MsgAcceptFunc: acceptFunc}
+		var stop []func()
+		switch network {
+		case "tcp":
+			l, err := listen("tcp", addr.String())
+			if err != nil {
+				log.Errorf("Failed to open another socket for %s: %s", addr, err)
+				return
+			}
+			srv.Listener = s.proxyListener(l)
+			srv.Handler = s.tcpHandler()
+			stop = append(stop, enableDSO(srv, s))
+		case "udp":
+			p, err := listenPacket("udp", addr.String())
+			if err != nil {
+				log.Errorf("Failed to open another socket for %s: %s", addr, err)
+				return
+			}
+			if u, ok := p.(*net.UDPConn); ok {
+				setDontFragment(u, s.maxUDPSize > 0)
+				if unfilter, err := attachUDPFilter(u, s.Addr, s.udpFilter); err != nil {
+					log.Errorf("Failed to attach the socket filter to %s: %s", u.LocalAddr(), err)
+				} else if unfilter != nil {
+					stop = append(stop, unfilter)
+				}
+			}
+			srv.PacketConn = p
+			srv.Handler = s.udpHandler(p)
+		}
+
+		// Wait for the server to start, a server shut down before it started would serve forever.
+		started := make(chan error, 1)
+		srv.NotifyStartedFunc = func() { started <- nil }
+		go func() { started <- srv.ActivateAndServe() }()
+		if err := <-started; err != nil {
+			log.Errorf("Failed to serve another socket for %s: %s", addr, err)
+			for _, f := range stop {
+				f()
+			}
+			return
+		}
+
+		s.reuse.mu.Lock()
+		if s.reuse.closed {
+			s.reuse.mu.Unlock()
+			srv.Shutdown()
+			for _, f := range stop {
+				f()
+			}
+			return
+		}
+		s.reuse.servers = append(s.reuse.servers, srv)
+		s.reuse.stop = append(s.reuse.stop, stop...)
+		s.reuse.mu.Unlock()
+	}
+}
+
+// shutdown stops the servers of g, the TCP connections get until the deadline of ctx to answer the
+// queries they are handling.
+func (g *reusePortGroup) shutdown(ctx context.Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, srv := range g.servers {
+		srv.ShutdownContext(ctx)
+	}
+	for _, f := range g.stop {
+		f()
+	}
+	g.servers, g.stop = nil, nil
+	g.closed = true
+}
@@ -0,0 +1,74 @@
+// +build go1.11
+// +build linux
+
+package dnsserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/miekg/dns"
+)
+
+func TestServeReusePort(t *testing.T) {
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	c := testConfig("dns", h)
+	c.ReusePort = 4
+	s, err := NewServer("dns://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := listenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	go s.ServePacket(p)
+
+	// The sockets are opened before the listeners are served.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.reuse.mu.Lock()
+		n := len(s.reuse.servers)
+		s.reuse.mu.Unlock()
+		if n == 6 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 6 more sockets, got %d", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Whichever socket gets them, the queries are answered.
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	for i := 0; i < 20; i++ {
+		if _, err := dns.Exchange(m, p.LocalAddr().String()); err != nil {
+			t.Fatalf("Expected a UDP response, got %s", err)
+		}
+		client := &dns.Client{Net: "tcp"}
+		if _, _, err := client.Exchange(m, l.Addr().String()); err != nil {
+			t.Fatalf("Expected a TCP response, got %s", err)
+		}
+	}
+
+	s.Stop()
+	s.reuse.mu.Lock()
+	defer s.reuse.mu.Unlock()
+	if len(s.reuse.servers) != 0 {
+		t.Errorf("Expected the sockets to be closed, got %d servers", len(s.reuse.servers))
+	}
+}
@@ -0,0 +1,41 @@
+package dnsserver
+
+import (
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/sanitize"
+
+	"github.com/miekg/dns"
+)
+
+// sanitizeWriter checks the responses to r before writing them, see sanitize.Response. A response
+// failing the checks is a bug of a plugin, or of a server it got the response from: SERVFAIL is
+// written instead, and the error of the checks is returned.
+type sanitizeWriter struct {
+	dns.ResponseWriter
+	r *dns.Msg
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w sanitizeWriter) WriteMsg(m *dns.Msg) error {
+	if err := sanitize.Response(m); err != nil {
+		w.fail(err)
+		return err
+	}
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w sanitizeWriter) Write(b []byte) (int, error) {
+	if err := sanitize.Wire(b); err != nil {
+		w.fail(err)
+		return 0, err
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w sanitizeWriter) fail(err error) {
+	log.Errorf("Not writing the response to %s/%d: %s", w.r.Question[0].Name, w.r.Question[0].Qtype, err)
+	m := new(dns.Msg)
+	m.SetRcode(w.r, dns.RcodeServerFailure)
+	w.ResponseWriter.WriteMsg(m)
+}
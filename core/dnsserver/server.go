@@ -3,17 +3,24 @@ package dnsserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"runtime"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/metrics/vars"
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/ede"
 	"github.com/coredns/coredns/plugin/pkg/edns"
 	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/proxyproto"
 	"github.com/coredns/coredns/plugin/pkg/rcode"
+	"github.com/coredns/coredns/plugin/pkg/sanitize"
 	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/request"
@@ -30,15 +37,38 @@ import (
 type Server struct {
 	Addr string // Address we listen on
 
-	server [2]*dns.Server // 0 is a net.Listener, 1 is a net.PacketConn (a *UDPConn) in our case.
-	m      sync.Mutex     // protects the servers
-
-	zones        map[string]*Config // zones keyed by their address
-	dnsWg        sync.WaitGroup     // used to wait on outstanding connections
-	graceTimeout time.Duration      // the maximum duration of a graceful shutdown
-	trace        trace.Trace        // the trace plugin for the server
-	debug        bool               // disable recover()
-	classChaos   bool               // allow non-INET class queries
+	server   [2]*dns.Server // 0 is a net.Listener, 1 is a net.PacketConn (a *UDPConn) in our case.
+	offload  *offloadConn   // serves the net.PacketConn instead of server[1] with UDP offload
+	batch    *batchConn     // serves the net.PacketConn instead of server[1] with batched system calls
+	stopDSO  func()         // stops the push notifications of server[0], see enableDSO
+	xdp      *xdpConn       // serves the UDP queries received on xdpIface besides server[1]
+	unfilter func()         // releases the counters of udpFilter, see attachUDPFilter
+	shards   *numaShards    // serves the UDP queries on the NUMA nodes besides server[1]
+	m        sync.Mutex     // protects the servers
+	stopOnce sync.Once      // stops the servers once, see Stop
+	stopErr  error          // the error of stopping the servers
+
+	zones        map[string][]*Config    // the server blocks of each zone, the views first
+	dnsWg        sync.WaitGroup          // used to wait on outstanding connections
+	graceTimeout time.Duration           // the maximum duration of a graceful shutdown
+	queryTimeout time.Duration           // the time we have to answer a query
+	maxQueryTime time.Duration           // when not zero, enforces the query deadline, see serveTimed
+	maxUDPSize   int                     // when not zero, the largest UDP response, see clampUDPSize
+	xdpIface     string                  // the interface to read UDP queries from with AF_XDP, see xdpConn
+	udpFilter    *UDPFilter              // the filter of the UDP socket
+	numa         *NUMA                   // how the UDP queries are sharded by NUMA node, see numaShards
+	dohPaths     []string                // the URL paths of DoH queries, the first is the canonical one
+	httpOptions  *HTTPOptions            // tunes the HTTP server of DoH queries, see newHTTPServer
+	httpHandlers map[string]http.Handler // served next to the DoH queries, by URL path
+	proxyNets    []*net.IPNet            // the trusted proxies of the PROXY protocol, see proxyListener
+	cookies      *Cookies                // answers the DNS cookies of the clients, when not nil
+	concurrency  *concurrencyLimit       // caps the queries in flight, when not nil
+	reusePort    int                     // the number of sockets of each listener, see serveReusePort
+	reuse        reusePortGroup          // the servers of the sockets besides the listeners
+	tsigSecret   map[string]string       // the TSIG secrets of all server blocks, see Config.TsigSecret
+	trace        trace.Trace             // the trace plugin for the server
+	debug        bool                    // disable recover()
+	classChaos   bool                    // allow non-INET class queries
 }
 
 // NewServer returns a new CoreDNS server and compiles all plugins in to it. By default CH class
@@ -47,8 +77,8 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 
 	s := &Server{
 		Addr:         addr,
-		zones:        make(map[string]*Config),
-		graceTimeout: 5 * time.Second,
+		zones:        make(map[string][]*Config),
+		graceTimeout: GracefulTimeout,
 	}
 
 	// We have to bound our wg with one increment
@@ -65,7 +95,63 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 			log.D.Set()
 		}
 		// set the config per zone
-		s.zones[site.Zone] = site
+		s.zones[site.Zone] = append(s.zones[site.Zone], site)
+		// The zones share the listener, use the shortest timeout of all of them.
+		if site.QueryTimeout > 0 && (s.queryTimeout == 0 || site.QueryTimeout < s.queryTimeout) {
+			s.queryTimeout = site.QueryTimeout
+		}
+		if site.MaxQueryTime > 0 && (s.maxQueryTime == 0 || site.MaxQueryTime < s.maxQueryTime) {
+			s.maxQueryTime = site.MaxQueryTime
+		}
+		// The same goes for the UDP socket, use the smallest size.
+		if site.MaxUDPSize > 0 && (s.maxUDPSize == 0 || site.MaxUDPSize < s.maxUDPSize) {
+			s.maxUDPSize = site.MaxUDPSize
+		}
+		if s.xdpIface == "" {
+			s.xdpIface = site.XDPInterface
+		}
+		s.udpFilter = s.udpFilter.merge(site.UDPFilter)
+		if s.numa == nil {
+			s.numa = site.NUMA
+		}
+		if s.dohPaths == nil {
+			s.dohPaths = site.HTTPSPaths
+		}
+		if s.httpOptions == nil {
+			s.httpOptions = site.HTTPOptions
+		}
+		for p, h := range site.HTTPHandlers {
+			if s.httpHandlers == nil {
+				s.httpHandlers = make(map[string]http.Handler)
+			}
+			if _, ok := s.httpHandlers[p]; !ok {
+				s.httpHandlers[p] = h
+			}
+		}
+		if s.proxyNets == nil {
+			s.proxyNets = site.ProxyProtocol
+		}
+		if s.cookies == nil {
+			s.cookies = site.Cookies
+		}
+		if site.MaxConcurrent > 0 && (s.concurrency == nil || int64(site.MaxConcurrent) < s.concurrency.max) {
+			s.concurrency = &concurrencyLimit{max: int64(site.MaxConcurrent), drop: site.ConcurrencyDrop}
+		}
+		if s.reusePort == 0 {
+			s.reusePort = site.ReusePort
+		}
+		for name, secret := range site.TsigSecret {
+			if s.tsigSecret == nil {
+				s.tsigSecret = make(map[string]string)
+			}
+			if _, ok := s.tsigSecret[name]; !ok {
+				s.tsigSecret[name] = secret
+			}
+		}
+		// Draining for longer does not hurt the queries of the other zones.
+		if site.GraceTimeout > s.graceTimeout {
+			s.graceTimeout = site.GraceTimeout
+		}
 
 		// compile custom plugin for everything
 		var stack plugin.Handler
@@ -86,8 +172,24 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 			if _, ok := EnableChaos[stack.Name()]; ok {
 				s.classChaos = true
 			}
+			if mc, ok := stack.(MetadataCollector); ok {
+				site.metaCollector = mc
+			}
 		}
 		site.pluginChain = stack
+		if site.Failed() {
+			site.pluginChain = failedHandler{site.failures}
+		}
+	}
+	if s.dohPaths == nil {
+		s.dohPaths = []string{doh.Path}
+	}
+	if s.queryTimeout == 0 {
+		s.queryTimeout = DefaultQueryTimeout
+	}
+	// The server block without filters of a zone answers the queries none of its views selects.
+	for _, configs := range s.zones {
+		sort.SliceStable(configs, func(i, j int) bool { return len(configs[i].Filters) > 0 && len(configs[j].Filters) == 0 })
 	}
 
 	return s, nil
@@ -96,32 +198,118 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 // Serve starts the server with an existing listener. It blocks until the server stops.
 // This implements caddy.TCPServer interface.
 func (s *Server) Serve(l net.Listener) error {
+	l = s.proxyListener(l)
 	s.m.Lock()
-	s.server[tcp] = &dns.Server{Listener: l, Net: "tcp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
-		ctx := context.WithValue(context.Background(), Key{}, s)
-		s.ServeDNS(ctx, w, r)
-	})}
+	s.server[tcp] = &dns.Server{Listener: l, Net: "tcp", Handler: s.tcpHandler(), TsigSecret: s.tsigSecret, MsgAcceptFunc: acceptFunc}
+	s.stopDSO = enableDSO(s.server[tcp], s)
 	s.m.Unlock()
 
+	if s.reusePort > 1 {
+		s.serveReusePort("tcp", l.Addr())
+	}
+
 	return s.server[tcp].ActivateAndServe()
 }
 
+// tcpHandler returns the handler of the queries received over TCP.
+func (s *Server) tcpHandler() dns.Handler {
+	ctx := context.WithValue(context.Background(), Key{}, s)
+	return dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		s.ServeDNS(ctx, pooledWriter{w}, r)
+	})
+}
+
 // ServePacket starts the server with an existing packetconn. It blocks until the server stops.
 // This implements caddy.UDPServer interface.
 func (s *Server) ServePacket(p net.PacketConn) error {
+	u, direct := p.(*net.UDPConn)
+	if direct {
+		// Also clear the option, the socket may be inherited from a config that set it.
+		setDontFragment(u, s.maxUDPSize > 0)
+		// The same goes for the filter.
+		if unfilter, err := attachUDPFilter(u, s.Addr, s.udpFilter); err != nil {
+			log.Errorf("Failed to attach the socket filter to %s: %s", u.LocalAddr(), err)
+		} else {
+			s.m.Lock()
+			s.unfilter = unfilter
+			s.m.Unlock()
+		}
+	}
+
+	if direct && s.numa != nil {
+		if sh, err := startNUMAShards(s, u.LocalAddr().(*net.UDPAddr), s.numa); err != nil {
+			log.Errorf("Not sharding the queries to %s by NUMA node: %s", u.LocalAddr(), err)
+		} else {
+			s.m.Lock()
+			s.shards = sh
+			s.m.Unlock()
+		}
+	}
+
+	if laddr, ok := p.LocalAddr().(*net.UDPAddr); ok && s.xdpIface != "" {
+		if x, err := acquireXDPConn(s, s.xdpIface, laddr); err != nil {
+			log.Errorf("Not reading the queries to %s from %s with AF_XDP: %s", p.LocalAddr(), s.xdpIface, err)
+		} else {
+			s.m.Lock()
+			s.xdp = x
+			s.m.Unlock()
+		}
+	}
+
+	if o := newOffloadConn(p); o != nil {
+		s.m.Lock()
+		s.offload = o
+		s.m.Unlock()
+		return o.serve(s)
+	}
+	if b := newBatchConn(p); b != nil {
+		s.m.Lock()
+		s.batch = b
+		s.m.Unlock()
+		return b.serve(s)
+	}
+
 	s.m.Lock()
-	s.server[udp] = &dns.Server{PacketConn: p, Net: "udp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
-		ctx := context.WithValue(context.Background(), Key{}, s)
-		s.ServeDNS(ctx, w, r)
-	})}
+	s.server[udp] = &dns.Server{PacketConn: p, Net: "udp", Handler: s.udpHandler(p), TsigSecret: s.tsigSecret, MsgAcceptFunc: acceptFunc}
 	s.m.Unlock()
 
+	if s.reusePort > 1 {
+		s.serveReusePort("udp", p.LocalAddr())
+	}
+
 	return s.server[udp].ActivateAndServe()
 }
 
+// udpHandler returns the handler of the queries received on p.
+func (s *Server) udpHandler(p net.PacketConn) dns.Handler {
+	ctx := context.WithValue(context.Background(), Key{}, s)
+	u, direct := p.(*net.UDPConn)
+	direct = direct && boundToAddress(u)
+	return dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		if direct {
+			w = udpWriter{w, u}
+		}
+		s.serveUDP(ctx, w, r)
+	})
+}
+
+// serveUDP serves a query received over UDP.
+func (s *Server) serveUDP(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) {
+	if s.maxUDPSize == 0 {
+		s.ServeDNS(ctx, pooledWriter{w}, r)
+		return
+	}
+	clampUDPSize(r, s.maxUDPSize)
+	s.ServeDNS(ctx, dontFragWriter{pooledWriter{w}}, r)
+}
+
 // Listen implements caddy.TCPServer interface.
 func (s *Server) Listen() (net.Listener, error) {
-	l, err := listen("tcp", s.Addr[len(transport.DNS+"://"):])
+	addr := s.Addr[len(transport.DNS+"://"):]
+	if l, ok := activatedListener(addr); ok {
+		return l, nil
+	}
+	l, err := listen("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
@@ -135,7 +323,11 @@ func (s *Server) WrapListener(ln net.Listener) net.Listener {
 
 // ListenPacket implements caddy.UDPServer interface.
 func (s *Server) ListenPacket() (net.PacketConn, error) {
-	p, err := listenPacket("udp", s.Addr[len(transport.DNS+"://"):])
+	addr := s.Addr[len(transport.DNS+"://"):]
+	if p, ok := activatedPacketConn(addr); ok {
+		return p, nil
+	}
+	p, err := listenPacket("udp", addr)
 	if err != nil {
 		return nil, err
 	}
@@ -145,11 +337,19 @@ func (s *Server) ListenPacket() (net.PacketConn, error) {
 
 // Stop stops the server. It blocks until the server is
 // totally stopped. On POSIX systems, it will wait for
-// connections to close (up to a max timeout of a few
-// seconds); on Windows it will close the listener
+// connections to close (up to the grace timeout, see
+// drainContext); on Windows it will close the listener
 // immediately.
+// Stopping a stopped server does nothing and returns the error of the first Stop.
 // This implements Caddy.Stopper interface.
-func (s *Server) Stop() (err error) {
+func (s *Server) Stop() error {
+	s.stopOnce.Do(func() { s.stopErr = s.stop() })
+	return s.stopErr
+}
+
+func (s *Server) stop() (err error) {
+	ctx, cancel := s.drainContext()
+	defer cancel()
 
 	if runtime.GOOS != "windows" {
 		// force connections to close after timeout
@@ -163,23 +363,78 @@ func (s *Server) Stop() (err error) {
 		// Wait for remaining connections to finish or
 		// force them all to close after timeout
 		select {
-		case <-time.After(s.graceTimeout):
+		case <-ctx.Done():
 		case <-done:
 		}
+	} else {
+		cancel()
 	}
 
-	// Close the listener now; this stops the server without delay
+	// Close the listeners now, the TCP connections get until the deadline of ctx to answer the
+	// queries they are handling.
 	s.m.Lock()
 	for _, s1 := range s.server {
 		// We might not have started and initialized the full set of servers
-		if s1 != nil {
-			err = s1.Shutdown()
+		if s1 == nil {
+			continue
+		}
+		// An expired grace timeout is not an error of the server.
+		if e := s1.ShutdownContext(ctx); e != nil && e != context.DeadlineExceeded && e != context.Canceled {
+			err = e
+		}
+	}
+	s.reuse.shutdown(ctx)
+	if s.offload != nil {
+		if e := s.offload.shutdown(); e != nil {
+			err = e
+		}
+	}
+	if s.batch != nil {
+		if e := s.batch.shutdown(); e != nil {
+			err = e
 		}
 	}
+	if s.stopDSO != nil {
+		s.stopDSO()
+	}
+	if s.xdp != nil {
+		s.xdp.release()
+		s.xdp = nil
+	}
+	if s.unfilter != nil {
+		s.unfilter()
+		s.unfilter = nil
+	}
+	if s.shards != nil {
+		s.shards.stop()
+		s.shards = nil
+	}
 	s.m.Unlock()
 	return
 }
 
+// proxyListener returns l reading the PROXY protocol header of the connections from the trusted
+// proxies, or l itself when there are none.
+func (s *Server) proxyListener(l net.Listener) net.Listener {
+	if len(s.proxyNets) == 0 {
+		return l
+	}
+	return &proxyproto.Listener{Listener: l, Trusted: s.proxyNets}
+}
+
+// drainContext returns a context that is done when the grace timeout of a stopping server expires.
+// With a timeout of zero the default of 5 seconds is used.
+func (s *Server) drainContext() (context.Context, context.CancelFunc) {
+	d := s.graceTimeout
+	if d <= 0 {
+		d = defaultGraceTimeout
+	}
+	return context.WithTimeout(context.Background(), d)
+}
+
+// defaultGraceTimeout is the grace timeout when neither GracefulTimeout nor Config.GraceTimeout are set.
+const defaultGraceTimeout = 5 * time.Second
+
 // Address together with Stop() implement caddy.GracefulServer.
 func (s *Server) Address() string { return s.Addr }
 
@@ -191,9 +446,32 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 	// The default dns.Mux checks the question section size, but we have our
 	// own mux here. Check if we have a question section. If not drop them here.
 	if r == nil || len(r.Question) == 0 {
-		errorAndMetricsFunc(s.Addr, w, r, dns.RcodeServerFailure)
+		errorAndMetricsFunc(s.Addr, w, r, dns.RcodeServerFailure, nil)
 		return
 	}
+	// Under overload, shed the queries before doing any work for them.
+	if !s.concurrency.acquire() {
+		s.concurrency.shed(s.Addr, w, r)
+		return
+	}
+	defer s.concurrency.release()
+
+	// Nor do the plugins see hostile or broken queries, or write such responses.
+	if err := sanitize.Request(r); err != nil {
+		errorAndMetricsFunc(s.Addr, w, r, dns.RcodeFormatError, nil)
+		return
+	}
+	w = sanitizeWriter{w, r}
+
+	// Set the deadline for answering this query, unless the transport already set an earlier one.
+	timeout := s.queryTimeout
+	if s.maxQueryTime > 0 && s.maxQueryTime < timeout {
+		timeout = s.maxQueryTime
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := plugin.Deadline(ctx); !ok || d.After(deadline) {
+		ctx = plugin.WithDeadline(ctx, deadline)
+	}
 
 	if !s.debug {
 		defer func() {
@@ -202,13 +480,13 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 			if rec := recover(); rec != nil {
 				log.Errorf("Recovered from panic in server: %q", s.Addr)
 				vars.Panic.Inc()
-				errorAndMetricsFunc(s.Addr, w, r, dns.RcodeServerFailure)
+				errorAndMetricsFunc(s.Addr, w, r, dns.RcodeServerFailure, nil)
 			}
 		}()
 	}
 
 	if !s.classChaos && r.Question[0].Qclass != dns.ClassINET {
-		errorAndMetricsFunc(s.Addr, w, r, dns.RcodeRefused)
+		errorAndMetricsFunc(s.Addr, w, r, dns.RcodeRefused, errNotSupported)
 		return
 	}
 
@@ -217,15 +495,31 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		return
 	}
 
+	if serveACMEChallenge(w, r) {
+		return
+	}
+
+	cookie, ok := s.cookies.check(s.Addr, w, r)
+	if !ok {
+		return
+	}
+
 	q := r.Question[0].Name
 	b := make([]byte, len(q))
 	var off int
 	var end bool
 
 	var dshandler *Config
+	var dsctx context.Context
 
 	// Wrap the response writer in a ScrubWriter so we automatically make the reply fit in the client's buffer.
 	w = request.NewScrubWriter(r, w)
+	// The cookie is set before the response is scrubbed, to fit in the client's buffer too.
+	if cookie != nil {
+		cw := &cookieWriter{ResponseWriter: w, cookies: s.cookies, client: cookie}
+		ctx = context.WithValue(ctx, cookieKey{}, cw)
+		w = cw
+	}
 
 	for {
 		l := len(q[off:])
@@ -237,22 +531,16 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 			}
 		}
 
-		if h, ok := s.zones[string(b[:l])]; ok {
+		if h, hctx := selectConfig(ctx, s.zones[string(b[:l])], w, r); h != nil {
 			if r.Question[0].Qtype != dns.TypeDS {
 				if h.FilterFunc == nil {
-					rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
-					if !plugin.ClientWrite(rcode) {
-						errorFunc(s.Addr, w, r, rcode)
-					}
+					s.serve(hctx, h, w, r)
 					return
 				}
 				// FilterFunc is set, call it to see if we should use this handler.
 				// This is given to full query name.
 				if h.FilterFunc(q) {
-					rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
-					if !plugin.ClientWrite(rcode) {
-						errorFunc(s.Addr, w, r, rcode)
-					}
+					s.serve(hctx, h, w, r)
 					return
 				}
 			}
@@ -261,7 +549,7 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 			// queries to a possibly grand parent, but there is no way for us to know at this point
 			// if there is an actually delegation from grandparent -> parent -> zone.
 			// In all fairness: direct DS queries should not be needed.
-			dshandler = h
+			dshandler, dsctx = h, hctx
 		}
 		off, end = dns.NextLabel(q, off)
 		if end {
@@ -271,24 +559,63 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 
 	if r.Question[0].Qtype == dns.TypeDS && dshandler != nil && dshandler.pluginChain != nil {
 		// DS request, and we found a zone, use the handler for the query.
-		rcode, _ := dshandler.pluginChain.ServeDNS(ctx, w, r)
-		if !plugin.ClientWrite(rcode) {
-			errorFunc(s.Addr, w, r, rcode)
-		}
+		s.serve(dsctx, dshandler, w, r)
 		return
 	}
 
 	// Wildcard match, if we have found nothing try the root zone as a last resort.
-	if h, ok := s.zones["."]; ok && h.pluginChain != nil {
-		rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
-		if !plugin.ClientWrite(rcode) {
-			errorFunc(s.Addr, w, r, rcode)
-		}
+	if h, hctx := selectConfig(ctx, s.zones["."], w, r); h != nil && h.pluginChain != nil {
+		s.serve(hctx, h, w, r)
 		return
 	}
 
 	// Still here? Error out with REFUSED.
-	errorAndMetricsFunc(s.Addr, w, r, dns.RcodeRefused)
+	errorAndMetricsFunc(s.Addr, w, r, dns.RcodeRefused, errNotAuthoritative)
+}
+
+// serve serves r with the plugins of the server block h, and answers with the error they returned
+// when they didn't write a response.
+func (s *Server) serve(ctx context.Context, h *Config, w dns.ResponseWriter, r *dns.Msg) {
+	if r.Opcode == dns.OpcodeUpdate && !h.Updates {
+		errorAndMetricsFunc(s.Addr, w, r, dns.RcodeNotImplemented, nil)
+		return
+	}
+	if s.maxQueryTime > 0 {
+		s.serveTimed(ctx, h, w, r)
+		return
+	}
+	rcode, err := h.pluginChain.ServeDNS(ctx, w, r)
+	if !plugin.ClientWrite(rcode) {
+		errorFunc(s.Addr, w, r, rcode, err)
+	}
+}
+
+// selectConfig returns the first of the server blocks of a zone selecting the query r, the one
+// without filters selects all of them, and the context to serve r with. It returns nil when none
+// does.
+func selectConfig(ctx context.Context, configs []*Config, w dns.ResponseWriter, r *dns.Msg) (*Config, context.Context) {
+	for _, c := range configs {
+		if len(c.Filters) == 0 {
+			return c, ctx
+		}
+		state := &request.Request{W: w, Req: r}
+		fctx := ctx
+		if c.metaCollector != nil {
+			// The filters may use the metadata, collect it before the plugins run.
+			fctx = c.metaCollector.Collect(ctx, *state)
+		}
+		selected := true
+		for _, f := range c.Filters {
+			if !f(fctx, state) {
+				selected = false
+				break
+			}
+		}
+		if selected {
+			return c, context.WithValue(fctx, ViewKey{}, c.ViewName)
+		}
+	}
+	return nil, ctx
 }
 
 // OnStartupComplete lists the sites served by this server
@@ -314,37 +641,83 @@ func (s *Server) Tracer() ot.Tracer {
 	return s.trace.Tracer()
 }
 
-// errorFunc responds to an DNS request with an error.
-func errorFunc(server string, w dns.ResponseWriter, r *dns.Msg, rc int) {
+// errorFunc responds to an DNS request with an error. When err has an Extended DNS Error, it is
+// added to the response.
+func errorFunc(server string, w dns.ResponseWriter, r *dns.Msg, rc int, err error) {
 	state := request.Request{W: w, Req: r}
 
 	answer := new(dns.Msg)
 	answer.SetRcode(r, rc)
 	state.SizeAndDo(answer)
+	if e, ok := ede.FromError(err); ok {
+		ede.Set(r, answer, e.Code, e.Error())
+	}
 
 	w.WriteMsg(answer)
 }
 
-func errorAndMetricsFunc(server string, w dns.ResponseWriter, r *dns.Msg, rc int) {
+func errorAndMetricsFunc(server string, w dns.ResponseWriter, r *dns.Msg, rc int, err error) {
 	state := request.Request{W: w, Req: r}
 
 	answer := new(dns.Msg)
 	answer.SetRcode(r, rc)
 	state.SizeAndDo(answer)
+	if e, ok := ede.FromError(err); ok {
+		ede.Set(r, answer, e.Code, e.Error())
+	}
 
 	vars.Report(server, state, vars.Dropped, rcode.ToString(rc), answer.Len(), time.Now())
 
 	w.WriteMsg(answer)
 }
 
+var (
+	errNotAuthoritative = ede.Wrap(ede.NotAuthoritative, errors.New("no zone for the query"))
+	errNotSupported     = ede.Wrap(ede.NotSupported, errors.New("class not supported"))
+)
+
 const (
 	tcp = 0
 	udp = 1
 )
 
+// acceptFunc accepts the dynamic updates (RFC 2136), which have records in all sections, and leaves
+// the other messages to dns.DefaultMsgAcceptFunc.
+func acceptFunc(dh dns.Header) dns.MsgAcceptAction {
+	if int(dh.Bits>>11)&0xF != dns.OpcodeUpdate {
+		return dns.DefaultMsgAcceptFunc(dh)
+	}
+	if dh.Bits&(1<<15) != 0 {
+		return dns.MsgIgnore // a response
+	}
+	if dh.Qdcount != 1 {
+		return dns.MsgReject
+	}
+	return dns.MsgAccept
+}
+
+// DefaultQueryTimeout is the default time we have to answer a query.
+const DefaultQueryTimeout = 5 * time.Second
+
 // Key is the context key for the current server added to the context.
 type Key struct{}
 
+// ViewKey is the context key for the name of the view (string) of the server block answering a query,
+// see Config.ViewName.
+type ViewKey struct{}
+
+// HTTPRequestKey is the context key for the HTTP request of a DNS-over-HTTPS query.
+type HTTPRequestKey struct{}
+
+// ClientCertKey is the context key for the verified certificate (*x509.Certificate) of the client of
+// a query over TLS: DNS-over-TLS, DNS-over-HTTPS or DNS-over-gRPC. It is only set when the tls plugin
+// verifies the client certificates, see its client_auth option.
+type ClientCertKey struct{}
+
+// TLSConnectionStateKey is the context key for the connection state (*tls.ConnectionState) of a
+// DNS-over-TLS or DNS-over-QUIC query. The ResponseWriter handed to the plugins doesn't expose it.
+type TLSConnectionStateKey struct{}
+
 // EnableChaos is a map with plugin names for which we should open CH class queries as we block these by default.
 var EnableChaos = map[string]struct{}{
 	"chaos":   {},
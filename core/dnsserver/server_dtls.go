@@ -0,0 +1,428 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+	"github.com/pion/dtls/v2"
+	"github.com/pion/transport/v2/deadline"
+)
+
+const (
+	// dtlsHandshakeTimeout is the time a client has to complete the DTLS handshake.
+	dtlsHandshakeTimeout = 10 * time.Second
+	// dtlsIdleTimeout is the time after which a DTLS session without queries is closed.
+	dtlsIdleTimeout = 2 * time.Minute
+)
+
+// ServerDTLS represents an instance of a DNS-over-DTLS server, see RFC 8094.
+type ServerDTLS struct {
+	*Server
+	dtlsConfig *dtls.Config
+
+	mu       sync.Mutex
+	packet   net.PacketConn
+	listener *peerListener
+	conns    map[*dtls.Conn]struct{}
+	closing  bool
+	queries  sync.WaitGroup // the queries being answered
+}
+
+// NewServerDTLS returns a new CoreDNS DTLS server and compiles all plugins in to it.
+func NewServerDTLS(addr string, group []*Config) (*ServerDTLS, error) {
+	s, err := NewServer(addr, group)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := quicTLSConfig(s)
+	if tlsConfig == nil {
+		return nil, fmt.Errorf("%s: DNS-over-DTLS needs a certificate, set it with the tls plugin", addr)
+	}
+
+	return &ServerDTLS{Server: s, dtlsConfig: newDTLSConfig(tlsConfig)}, nil
+}
+
+// newDTLSConfig returns the DTLS config with the certificates and the client authentication of tc.
+func newDTLSConfig(tc *tls.Config) *dtls.Config {
+	c := &dtls.Config{
+		Certificates: tc.Certificates,
+		// The client authentication types of crypto/tls and pion/dtls are declared in the same order.
+		ClientAuth:           dtls.ClientAuthType(tc.ClientAuth),
+		ClientCAs:            tc.ClientCAs,
+		ExtendedMasterSecret: dtls.RequireExtendedMasterSecret,
+	}
+	if tc.GetCertificate != nil {
+		c.GetCertificate = func(h *dtls.ClientHelloInfo) (*tls.Certificate, error) {
+			return tc.GetCertificate(&tls.ClientHelloInfo{ServerName: h.ServerName})
+		}
+	}
+	return c
+}
+
+// Serve implements caddy.TCPServer interface.
+func (s *ServerDTLS) Serve(l net.Listener) error { return nil }
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerDTLS) ServePacket(p net.PacketConn) error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return nil
+	}
+	l := newPeerListener(p)
+	s.packet, s.listener = p, l
+	s.conns = make(map[*dtls.Conn]struct{})
+	s.mu.Unlock()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closing := s.closing
+			s.mu.Unlock()
+			if closing {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(c)
+	}
+}
+
+// serveConn completes the DTLS handshake of a client on c and answers its queries, until the session
+// is closed or idle.
+func (s *ServerDTLS) serveConn(c net.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), dtlsHandshakeTimeout)
+	conn, err := dtls.ServerWithContext(ctx, c, s.dtlsConfig)
+	cancel()
+	if err != nil {
+		c.Close()
+		return
+	}
+
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	ctx = context.WithValue(context.Background(), Key{}, s.Server)
+	if cert := dtlsClientCert(conn.ConnectionState(), s.dtlsConfig.ClientAuth); cert != nil {
+		ctx = context.WithValue(ctx, ClientCertKey{}, cert)
+	}
+
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		conn.SetReadDeadline(time.Now().Add(dtlsIdleTimeout))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		// Each DTLS record carries a single DNS message, without the length prefix of TCP.
+		m := new(dns.Msg)
+		if err := m.Unpack(buf[:n]); err != nil {
+			continue
+		}
+
+		s.mu.Lock()
+		if s.closing {
+			s.mu.Unlock()
+			return
+		}
+		s.queries.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.queries.Done()
+			s.ServeDNS(ctx, &dtlsWriter{conn: conn}, m)
+		}()
+	}
+}
+
+// dtlsClientCert returns the certificate of the client of a DTLS session, if it was verified.
+func dtlsClientCert(state dtls.State, auth dtls.ClientAuthType) *x509.Certificate {
+	if auth < dtls.VerifyClientCertIfGiven || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(state.PeerCertificates[0])
+	if err != nil {
+		return nil
+	}
+	return cert
+}
+
+// Listen implements caddy.TCPServer interface.
+func (s *ServerDTLS) Listen() (net.Listener, error) { return nil, nil }
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerDTLS) ListenPacket() (net.PacketConn, error) {
+	addr := s.Addr[len(transport.DTLS+"://"):]
+	if p, ok := activatedPacketConn(addr); ok {
+		return p, nil
+	}
+	return listenPacket("udp", addr)
+}
+
+// Stop stops the server. It stops accepting sessions and waits for the queries in flight to be
+// answered, up to the grace timeout, before it closes the sessions.
+func (s *ServerDTLS) Stop() error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closing = true
+	l := s.listener
+	s.mu.Unlock()
+	if l == nil {
+		return nil
+	}
+	l.Close()
+
+	ctx, cancel := s.drainContext()
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		s.queries.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
+
+	s.mu.Lock()
+	conns := make([]*dtls.Conn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	return s.packet.Close()
+}
+
+// OnStartupComplete lists the sites served by this server
+// and any relevant information, assuming Quiet is false.
+func (s *ServerDTLS) OnStartupComplete() {
+	if Quiet {
+		return
+	}
+
+	out := startUpZones(transport.DTLS+"://", s.Addr, s.zones)
+	if out != "" {
+		fmt.Print(out)
+	}
+}
+
+// dtlsWriter writes the responses to the queries of a DTLS session. Its remote address is a UDP
+// address: a response must fit in a datagram, the plugins truncate it to the UDP size of the client.
+type dtlsWriter struct {
+	conn *dtls.Conn
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *dtlsWriter) WriteMsg(m *dns.Msg) error {
+	buf, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *dtlsWriter) Write(buf []byte) (int, error) { return w.conn.Write(buf) }
+
+// Close implements the dns.ResponseWriter interface. The session is kept open for the next queries.
+func (w *dtlsWriter) Close() error { return nil }
+
+// LocalAddr implements the dns.ResponseWriter interface.
+func (w *dtlsWriter) LocalAddr() net.Addr { return w.conn.LocalAddr() }
+
+// RemoteAddr implements the dns.ResponseWriter interface.
+func (w *dtlsWriter) RemoteAddr() net.Addr { return w.conn.RemoteAddr() }
+
+// TsigStatus implements the dns.ResponseWriter interface. The TSIG of the queries is not verified.
+func (w *dtlsWriter) TsigStatus() error { return dns.ErrSecret }
+
+// TsigTimersOnly implements the dns.ResponseWriter interface.
+func (w *dtlsWriter) TsigTimersOnly(bool) {}
+
+// Hijack implements the dns.ResponseWriter interface.
+func (w *dtlsWriter) Hijack() {}
+
+// dtlsHandshake is the content type of the DTLS records of a handshake, see section 4.1 of RFC 6347.
+const dtlsHandshake = 22
+
+// peerListener is a net.Listener of the peers of a net.PacketConn. A peer is accepted when it sends a
+// DTLS handshake record, its datagrams are then read from its own net.Conn. Closing the listener stops
+// accepting peers, the peers accepted are served until the packet conn is closed.
+type peerListener struct {
+	p         net.PacketConn
+	accept    chan *peerConn
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu    sync.Mutex
+	peers map[string]*peerConn
+}
+
+func newPeerListener(p net.PacketConn) *peerListener {
+	l := &peerListener{
+		p:      p,
+		accept: make(chan *peerConn, 128),
+		done:   make(chan struct{}),
+		peers:  make(map[string]*peerConn),
+	}
+	go l.read()
+	return l
+}
+
+// read reads the datagrams of p and hands them to their peer, until p is closed.
+func (l *peerListener) read() {
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		n, addr, err := l.p.ReadFrom(buf)
+		if err != nil {
+			l.Close()
+			l.mu.Lock()
+			for _, c := range l.peers {
+				c.closeOnce.Do(func() { close(c.closed) })
+			}
+			l.mu.Unlock()
+			return
+		}
+
+		l.mu.Lock()
+		c, ok := l.peers[addr.String()]
+		if !ok {
+			select {
+			case <-l.done:
+				l.mu.Unlock()
+				continue
+			default:
+			}
+			if n == 0 || buf[0] != dtlsHandshake {
+				l.mu.Unlock()
+				continue
+			}
+			c = &peerConn{l: l, addr: addr, in: make(chan []byte, 64), closed: make(chan struct{}), readDeadline: deadline.New()}
+			select {
+			case l.accept <- c:
+				l.peers[addr.String()] = c
+			default:
+				// Too many peers waiting to be accepted, drop the datagram like a full socket buffer.
+				l.mu.Unlock()
+				continue
+			}
+		}
+		l.mu.Unlock()
+
+		b := make([]byte, n)
+		copy(b, buf)
+		select {
+		case c.in <- b:
+		default:
+		}
+	}
+}
+
+// Accept implements the net.Listener interface.
+func (l *peerListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.accept:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements the net.Listener interface. It does not close the packet conn.
+func (l *peerListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+// Addr implements the net.Listener interface.
+func (l *peerListener) Addr() net.Addr { return l.p.LocalAddr() }
+
+// peerConn is the net.Conn of a peer of a peerListener.
+type peerConn struct {
+	l            *peerListener
+	addr         net.Addr
+	in           chan []byte
+	closed       chan struct{}
+	closeOnce    sync.Once
+	readDeadline *deadline.Deadline
+}
+
+// Read implements the net.Conn interface, it reads a datagram.
+func (c *peerConn) Read(b []byte) (int, error) {
+	select {
+	case d := <-c.in:
+		return copy(b, d), nil
+	case <-c.closed:
+		return 0, net.ErrClosed
+	case <-c.readDeadline.Done():
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+// Write implements the net.Conn interface, it writes a datagram.
+func (c *peerConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	return c.l.p.WriteTo(b, c.addr)
+}
+
+// Close implements the net.Conn interface. The next handshake record of the peer is accepted again.
+func (c *peerConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	c.l.mu.Lock()
+	if c.l.peers[c.addr.String()] == c {
+		delete(c.l.peers, c.addr.String())
+	}
+	c.l.mu.Unlock()
+	return nil
+}
+
+// LocalAddr implements the net.Conn interface.
+func (c *peerConn) LocalAddr() net.Addr { return c.l.p.LocalAddr() }
+
+// RemoteAddr implements the net.Conn interface.
+func (c *peerConn) RemoteAddr() net.Addr { return c.addr }
+
+// SetDeadline implements the net.Conn interface.
+func (c *peerConn) SetDeadline(t time.Time) error { return c.SetReadDeadline(t) }
+
+// SetReadDeadline implements the net.Conn interface.
+func (c *peerConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Set(t)
+	return nil
+}
+
+// SetWriteDeadline implements the net.Conn interface. Writing a datagram does not block.
+func (c *peerConn) SetWriteDeadline(time.Time) error { return nil }
@@ -0,0 +1,115 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pion/dtls/v2"
+)
+
+// newTestDTLSServer returns a running DTLS server answering with certPlugin, its address and the
+// certificate authority of its certificate.
+func newTestDTLSServer(t *testing.T, clientAuth tls.ClientAuthType) (*ServerDTLS, *net.UDPAddr, tls.Certificate) {
+	ca := newTestCert(t, "ca", nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	c := testConfig("dtls", certPlugin{})
+	c.TLSConfig = &tls.Config{Certificates: []tls.Certificate{newTestCert(t, "server", &ca)}, ClientAuth: clientAuth, ClientCAs: pool}
+	s, err := NewServerDTLS("dtls://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServerDTLS, got %s", err)
+	}
+	p, err := listenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServePacket(p)
+
+	return s, p.LocalAddr().(*net.UDPAddr), ca
+}
+
+// exchangeDTLS sends m on conn and returns the response.
+func exchangeDTLS(t *testing.T, conn *dtls.Conn, m *dns.Msg) *dns.Msg {
+	buf, _ := m.Pack()
+	if _, err := conn.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp := make([]byte, dns.MaxMsgSize)
+	n, err := conn.Read(resp)
+	if err != nil {
+		t.Fatalf("Expected a response, got %s", err)
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(resp[:n]); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestServerDTLS(t *testing.T) {
+	s, addr, ca := newTestDTLSServer(t, tls.VerifyClientCertIfGiven)
+	defer s.Stop()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	tests := []struct {
+		certs    []tls.Certificate
+		expected string
+	}{
+		{nil, "none"},
+		{[]tls.Certificate{newTestCert(t, "client", &ca)}, "client"},
+	}
+	for i, tc := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		conn, err := dtls.DialWithContext(ctx, "udp", addr, &dtls.Config{RootCAs: pool, ServerName: "127.0.0.1", Certificates: tc.certs})
+		cancel()
+		if err != nil {
+			t.Fatalf("Test %d: expected no error dialing the server, got %s", i, err)
+		}
+
+		// Two queries in the same session.
+		for j := 0; j < 2; j++ {
+			m := new(dns.Msg)
+			m.SetQuestion("example.com.", dns.TypeTXT)
+			r := exchangeDTLS(t, conn, m)
+			if r.Id != m.Id {
+				t.Errorf("Test %d: expected the ID %d, got %d", i, m.Id, r.Id)
+			}
+			if len(r.Answer) != 1 || r.Answer[0].(*dns.TXT).Txt[0] != tc.expected {
+				t.Errorf("Test %d: expected the answer %q, got %v", i, tc.expected, r.Answer)
+			}
+		}
+		conn.Close()
+	}
+}
+
+func TestServerDTLSStop(t *testing.T) {
+	s, addr, ca := newTestDTLSServer(t, tls.NoClientCert)
+	if err := s.Stop(); err != nil {
+		t.Errorf("Expected no error for Stop, got %s", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Errorf("Expected no error for the second Stop, got %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if _, err := dtls.DialWithContext(ctx, "udp", addr, &dtls.Config{RootCAs: pool, ServerName: "127.0.0.1"}); err == nil {
+		t.Error("Expected an error dialing a stopped server, got none")
+	}
+}
+
+func TestNewServerDTLSNoCert(t *testing.T) {
+	if _, err := NewServerDTLS("dtls://127.0.0.1:853", []*Config{testConfig("dtls", testPlugin{})}); err == nil {
+		t.Error("Expected an error for a DTLS server without a certificate, got none")
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/miekg/dns"
 	"github.com/opentracing/opentracing-go"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/peer"
 )
 
@@ -34,9 +35,11 @@ func NewServergRPC(addr string, group []*Config) (*ServergRPC, error) {
 	// The *tls* plugin must make sure that multiple conflicting
 	// TLS configuration return an error: it can only be specified once.
 	var tlsConfig *tls.Config
-	for _, conf := range s.zones {
-		// Should we error if some configs *don't* have TLS?
-		tlsConfig = conf.TLSConfig
+	for _, configs := range s.zones {
+		for _, conf := range configs {
+			// Should we error if some configs *don't* have TLS?
+			tlsConfig = conf.TLSConfig
+		}
 	}
 
 	return &ServergRPC{Server: s, tlsConfig: tlsConfig}, nil
@@ -48,22 +51,23 @@ func (s *ServergRPC) Serve(l net.Listener) error {
 	s.listenAddr = l.Addr()
 	s.m.Unlock()
 
+	var opts []grpc.ServerOption
 	if s.Tracer() != nil {
 		onlyIfParent := func(parentSpanCtx opentracing.SpanContext, method string, req, resp interface{}) bool {
 			return parentSpanCtx != nil
 		}
 		intercept := otgrpc.OpenTracingServerInterceptor(s.Tracer(), otgrpc.IncludingSpans(onlyIfParent))
-		s.grpcServer = grpc.NewServer(grpc.UnaryInterceptor(intercept))
-	} else {
-		s.grpcServer = grpc.NewServer()
+		opts = append(opts, grpc.UnaryInterceptor(intercept))
+	}
+	// The gRPC credentials terminate TLS, to tell the queries the TLS state of their connection.
+	if s.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
 	}
+	s.grpcServer = grpc.NewServer(opts...)
 
 	pb.RegisterDnsServiceServer(s.grpcServer, s)
 
-	if s.tlsConfig != nil {
-		l = tls.NewListener(l, s.tlsConfig)
-	}
-	return s.grpcServer.Serve(l)
+	return s.grpcServer.Serve(s.proxyListener(l))
 }
 
 // ServePacket implements caddy.UDPServer interface.
@@ -71,8 +75,11 @@ func (s *ServergRPC) ServePacket(p net.PacketConn) error { return nil }
 
 // Listen implements caddy.TCPServer interface.
 func (s *ServergRPC) Listen() (net.Listener, error) {
-
-	l, err := net.Listen("tcp", s.Addr[len(transport.GRPC+"://"):])
+	addr := s.Addr[len(transport.GRPC+"://"):]
+	if l, ok := activatedListener(addr); ok {
+		return l, nil
+	}
+	l, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +109,20 @@ func (s *ServergRPC) Stop() (err error) {
 	s.m.Lock()
 	defer s.m.Unlock()
 	if s.grpcServer != nil {
-		s.grpcServer.GracefulStop()
+		ctx, cancel := s.drainContext()
+		defer cancel()
+		done := make(chan struct{})
+		go func() {
+			s.grpcServer.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			// The grace timeout expired, Stop also makes GracefulStop return.
+			s.grpcServer.Stop()
+			<-done
+		}
 	}
 	return
 }
@@ -130,6 +150,11 @@ func (s *ServergRPC) Query(ctx context.Context, in *pb.DnsPacket) (*pb.DnsPacket
 	w := &gRPCresponse{localAddr: s.listenAddr, remoteAddr: a, Msg: msg}
 
 	dnsCtx := context.WithValue(ctx, Key{}, s.Server)
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		if cert := verifiedClientCert(&info.State); cert != nil {
+			dnsCtx = context.WithValue(dnsCtx, ClientCertKey{}, cert)
+		}
+	}
 	s.ServeDNS(dnsCtx, w, msg)
 
 	packed, err := w.Msg.Pack()
@@ -164,7 +189,7 @@ func (r *gRPCresponse) Write(b []byte) (int, error) {
 
 // These methods implement the dns.ResponseWriter interface from Go DNS.
 func (r *gRPCresponse) Close() error              { return nil }
-func (r *gRPCresponse) TsigStatus() error         { return nil }
+func (r *gRPCresponse) TsigStatus() error         { return dns.ErrSecret }
 func (r *gRPCresponse) TsigTimersOnly(b bool)     { return }
 func (r *gRPCresponse) Hijack()                   { return }
 func (r *gRPCresponse) LocalAddr() net.Addr       { return r.localAddr }
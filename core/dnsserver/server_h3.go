@@ -0,0 +1,98 @@
+package dnsserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// ServerH3 represents an instance of a DNS-over-HTTPS server over HTTP/3. It answers the requests like
+// ServerHTTPS does over HTTP/2.
+type ServerH3 struct {
+	*ServerHTTPS
+	h3Server   *http3.Server
+	quicConfig *quic.Config
+	quic       quicListener
+}
+
+// NewServerH3 returns a new CoreDNS HTTP/3 server and compiles all plugins in to it.
+func NewServerH3(addr string, group []*Config) (*ServerH3, error) {
+	s, err := NewServer(addr, group)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := quicTLSConfig(s)
+	if tlsConfig == nil {
+		return nil, fmt.Errorf("%s: DNS-over-HTTP/3 needs a certificate, set it with the tls plugin", addr)
+	}
+	tlsConfig.NextProtos = []string{http3.NextProtoH3}
+
+	sh := &ServerH3{
+		ServerHTTPS: &ServerHTTPS{Server: s, tlsConfig: tlsConfig},
+		// 0-RTT data can be replayed, we don't accept it.
+		quicConfig: &quic.Config{Allow0RTT: false},
+		quic:       quicListener{noError: quic.ApplicationErrorCode(http3.ErrCodeNoError)},
+	}
+	sh.h3Server = &http3.Server{Handler: http.HandlerFunc(sh.serveHTTP), TLSConfig: tlsConfig, QuicConfig: sh.quicConfig}
+	return sh, nil
+}
+
+// Serve implements caddy.TCPServer interface.
+func (s *ServerH3) Serve(l net.Listener) error { return nil }
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerH3) ServePacket(p net.PacketConn) error {
+	s.m.Lock()
+	s.listenAddr = p.LocalAddr()
+	s.m.Unlock()
+
+	return s.quic.serve(p, s.tlsConfig, s.quicConfig, func(conn quic.Connection) { s.h3Server.ServeQUICConn(conn) })
+}
+
+// serveHTTP answers a request with ServeHTTP, it is refused when the server is stopping.
+func (s *ServerH3) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.quic.begin() {
+		http.Error(w, "", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.quic.done()
+	s.ServeHTTP(w, r)
+}
+
+// Listen implements caddy.TCPServer interface.
+func (s *ServerH3) Listen() (net.Listener, error) { return nil, nil }
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerH3) ListenPacket() (net.PacketConn, error) {
+	addr := s.Addr[len(transport.H3+"://"):]
+	if p, ok := activatedPacketConn(addr); ok {
+		return p, nil
+	}
+	return listenPacket("udp", addr)
+}
+
+// Stop stops the server. It stops accepting connections and waits for the requests in flight to be
+// answered, up to the grace timeout, before it closes the connections.
+func (s *ServerH3) Stop() error {
+	ctx, cancel := s.drainContext()
+	defer cancel()
+	return s.quic.stop(ctx)
+}
+
+// OnStartupComplete lists the sites served by this server
+// and any relevant information, assuming Quiet is false.
+func (s *ServerH3) OnStartupComplete() {
+	if Quiet {
+		return
+	}
+
+	out := startUpZones(transport.H3+"://", s.Addr, s.zones)
+	if out != "" {
+		fmt.Print(out)
+	}
+}
@@ -0,0 +1,70 @@
+package dnsserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	pkgtls "github.com/coredns/coredns/plugin/pkg/tls"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestServerH3(t *testing.T) {
+	dir, rm, err := test.WritePEMFiles("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+	tc, err := pkgtls.NewTLSConfigFromArgs(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := testConfig("h3", certPlugin{})
+	if _, err := NewServerH3("h3://127.0.0.1:0", []*Config{c}); err == nil {
+		t.Fatal("Expected an error without a TLS config")
+	}
+
+	c.TLSConfig = tc
+	s, err := NewServerH3("h3://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := listenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServePacket(p)
+	defer s.Stop()
+
+	rt := &http3.RoundTripper{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	defer rt.Close()
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeTXT)
+		req, err := doh.NewRequest(method, p.LocalAddr().String(), m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: expected a response, got %s", method, err)
+		}
+		if resp.ProtoMajor != 3 {
+			t.Errorf("%s: expected an HTTP/3 response, got %s", method, resp.Proto)
+		}
+		r, err := doh.ResponseToMsg(resp)
+		if err != nil {
+			t.Fatalf("%s: expected a DNS response, got %s", method, err)
+		}
+		if len(r.Answer) != 1 || r.Answer[0].(*dns.TXT).Txt[0] != "none" {
+			t.Errorf("%s: expected the answer of the plugin, got %v", method, r.Answer)
+		}
+	}
+}
@@ -28,8 +28,10 @@ func NewServerHTTP(addr string, group []*Config) (*ServerHTTP, error) {
 		return nil, err
 	}
 
-	sh := &ServerHTTP{Server: s, httpServer: new(http.Server)}
-	sh.httpServer.Handler = sh
+	sh := &ServerHTTP{Server: s}
+	if sh.httpServer, err = newHTTPServer(sh, s.httpOptions, false); err != nil {
+		return nil, err
+	}
 
 	return sh, nil
 }
@@ -40,7 +42,7 @@ func (s *ServerHTTP) Serve(l net.Listener) error {
 	s.listenAddr = l.Addr()
 	s.m.Unlock()
 
-	return s.httpServer.Serve(l)
+	return s.httpServer.Serve(s.proxyListener(l))
 }
 
 // ServePacket implements caddy.UDPServer interface.
@@ -48,7 +50,11 @@ func (s *ServerHTTP) ServePacket(p net.PacketConn) error { return nil }
 
 // Listen implements caddy.TCPServer interface.
 func (s *ServerHTTP) Listen() (net.Listener, error) {
-	l, err := net.Listen("tcp", s.Addr[len(transport.HTTP+"://"):])
+	addr := s.Addr[len(transport.HTTP+"://"):]
+	if l, ok := activatedListener(addr); ok {
+		return l, nil
+	}
+	l, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +83,12 @@ func (s *ServerHTTP) Stop() error {
 	s.m.Lock()
 	defer s.m.Unlock()
 	if s.httpServer != nil {
-		s.httpServer.Shutdown(context.Background())
+		ctx, cancel := s.drainContext()
+		defer cancel()
+		if err := s.httpServer.Shutdown(ctx); err != nil {
+			// The grace timeout expired, drop the requests still in flight.
+			return s.httpServer.Close()
+		}
 	}
 	return nil
 }
@@ -85,26 +96,44 @@ func (s *ServerHTTP) Stop() error {
 // ServeHTTP is the handler that gets the HTTP request and converts to the dns format, calls the plugin
 // chain, converts it back and write it to the client.
 func (s *ServerHTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h := s.httpHandler(r.URL.Path); h != nil {
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	mw := &httpMetrics{ResponseWriter: w}
+	w = mw
+	size := 0
+	defer func(start time.Time) { mw.report(s.Addr, r, size, start) }(time.Now())
 
-	if r.URL.Path != doh.Path {
+	if !s.dohPath(r.URL.Path) {
 		http.Error(w, "", http.StatusNotFound)
 		return
 	}
+	if !s.authorize(w, r) {
+		return
+	}
 
 	msg, err := doh.RequestToMsg(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if err == doh.ErrMethod {
+			w.Header().Set("Allow", "GET, POST")
+		}
+		http.Error(w, err.Error(), doh.Status(err))
 		return
 	}
+	size = msg.Len()
 
 	// Create a DoHWriter with the correct addresses in it.
-	h, p, _ := net.SplitHostPort(r.RemoteAddr)
-	port, _ := strconv.Atoi(p)
-	dw := &DoHWriter{laddr: s.listenAddr, raddr: &net.TCPAddr{IP: net.ParseIP(h), Port: port}}
+	dw := &DoHWriter{laddr: s.listenAddr, raddr: s.clientAddr(r)}
 
 	// We just call the normal chain handler - all error handling is done there.
 	// We should expect a packet to be returned that we can send to the client.
 	ctx := context.WithValue(context.Background(), Key{}, s.Server)
+	ctx = context.WithValue(ctx, HTTPRequestKey{}, r)
+	if cert := verifiedClientCert(r.TLS); cert != nil {
+		ctx = context.WithValue(ctx, ClientCertKey{}, cert)
+	}
 	s.ServeDNS(ctx, dw, msg)
 
 	// See section 4.2.1 of RFC 8484.
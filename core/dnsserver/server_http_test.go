@@ -0,0 +1,188 @@
+package dnsserver
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/metrics/vars"
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestServerHTTPGet(t *testing.T) {
+	s, err := NewServerHTTP("http://127.0.0.1:0", []*Config{testConfig("http", test.ErrorHandler())})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	buf, _ := m.Pack()
+
+	tests := []struct {
+		method string
+		query  string
+		status int
+	}{
+		{http.MethodGet, "?dns=" + base64.RawURLEncoding.EncodeToString(buf), http.StatusOK},
+		{http.MethodGet, "?dns=" + base64.URLEncoding.EncodeToString(buf), http.StatusBadRequest},
+		{http.MethodGet, "?dns=" + strings.Repeat("A", 90000), http.StatusRequestURITooLong},
+		{http.MethodDelete, "", http.StatusMethodNotAllowed},
+	}
+	for i, tc := range tests {
+		req := httptest.NewRequest(tc.method, "http://127.0.0.1"+doh.Path+tc.query, nil)
+		req.RemoteAddr = "127.0.0.1:34567"
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("Test %d: expected status %d, got %d", i, tc.status, rec.Code)
+		}
+		if rec.Code == http.StatusOK {
+			r := new(dns.Msg)
+			if err := r.Unpack(rec.Body.Bytes()); err != nil || r.Id != m.Id {
+				t.Errorf("Test %d: expected a response to the query, got %v", i, err)
+			}
+		}
+		if rec.Code == http.StatusMethodNotAllowed && rec.Header().Get("Allow") != "GET, POST" {
+			t.Errorf("Test %d: expected an Allow header, got %q", i, rec.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestServerHTTPPaths(t *testing.T) {
+	c := testConfig("http", test.ErrorHandler())
+	c.HTTPSPaths = []string{"/mydns", "/other"}
+	s, err := NewServerHTTP("http://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	buf, _ := m.Pack()
+	query := "?dns=" + base64.RawURLEncoding.EncodeToString(buf)
+
+	for path, status := range map[string]int{"/mydns": http.StatusOK, "/other": http.StatusOK, doh.Path: http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1"+path+query, nil)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("Expected status %d for %s, got %d", status, path, rec.Code)
+		}
+	}
+}
+
+func TestServerHTTPMetrics(t *testing.T) {
+	s, err := NewServerHTTP("http://127.0.0.1:5302", []*Config{testConfig("http", test.ErrorHandler())})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	buf, _ := m.Pack()
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "http://127.0.0.1"+doh.Path+"?dns="+base64.RawURLEncoding.EncodeToString(buf), nil),
+		httptest.NewRequest(http.MethodGet, "http://127.0.0.1/other", nil),
+		httptest.NewRequest(http.MethodPut, "http://127.0.0.1"+doh.Path, nil),
+	} {
+		req.RemoteAddr = "127.0.0.1:34567"
+		s.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	server := "http://127.0.0.1:5302"
+	if n := testutil.ToFloat64(vars.HTTPSRequestCount.WithLabelValues(server, "GET")); n != 2 {
+		t.Errorf("Expected 2 GET requests, got %f", n)
+	}
+	if n := testutil.ToFloat64(vars.HTTPSRequestCount.WithLabelValues(server, "other")); n != 1 {
+		t.Errorf("Expected 1 request with another method, got %f", n)
+	}
+	for status, expected := range map[string]float64{"200": 1, "404": 1, "405": 1} {
+		if n := testutil.ToFloat64(vars.HTTPSResponseCount.WithLabelValues(server, status)); n != expected {
+			t.Errorf("Expected %f responses with status %s, got %f", expected, status, n)
+		}
+	}
+}
+
+func TestServerHTTPHandlers(t *testing.T) {
+	c := testConfig("http", test.ErrorHandler())
+	c.AddHTTPHandler("/health", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	c.AddHTTPHandler(doh.Path, http.NotFoundHandler())
+	s, err := NewServerHTTP("http://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://127.0.0.1/health", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "OK" {
+		t.Errorf("Expected the handler of /health to be served, got %d %q", w.Code, w.Body.String())
+	}
+
+	// The DoH queries take precedence.
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	buf, _ := m.Pack()
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1"+doh.Path+"?dns="+base64.RawURLEncoding.EncodeToString(buf), nil)
+	req.RemoteAddr = "127.0.0.1:34567"
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != doh.MimeType {
+		t.Errorf("Expected a DoH response, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestServerHTTPAuth(t *testing.T) {
+	auth := new(HTTPAuth)
+	auth.AddToken("token-a")
+	auth.AddUser("alice", "secret")
+	c := testConfig("http", test.ErrorHandler())
+	c.HTTPOptions = &HTTPOptions{Auth: auth}
+	s, err := NewServerHTTP("http://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	buf, _ := m.Pack()
+	url := "http://127.0.0.1" + doh.Path + "?dns=" + base64.RawURLEncoding.EncodeToString(buf)
+
+	tests := []struct {
+		header   string
+		expected int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Digest username=alice", http.StatusUnauthorized},
+		{"Bearer token-a", http.StatusOK},
+		{"bearer token-a", http.StatusOK},
+		{"Bearer token-b", http.StatusForbidden},
+		{"Basic " + base64.StdEncoding.EncodeToString([]byte("alice:secret")), http.StatusOK},
+		{"Basic " + base64.StdEncoding.EncodeToString([]byte("alice:guess")), http.StatusForbidden},
+		{"Basic " + base64.StdEncoding.EncodeToString([]byte("bob:secret")), http.StatusForbidden},
+	}
+	for i, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.RemoteAddr = "127.0.0.1:34567"
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != tc.expected {
+			t.Errorf("Test %d: expected status %d, got %d", i, tc.expected, w.Code)
+		}
+		if tc.expected == http.StatusUnauthorized && len(w.Header()["Www-Authenticate"]) != 2 {
+			t.Errorf("Test %d: expected the Basic and Bearer challenges, got %v", i, w.Header()["Www-Authenticate"])
+		}
+	}
+}
@@ -32,13 +32,27 @@ func NewServerHTTPS(addr string, group []*Config) (*ServerHTTPS, error) {
 	// The *tls* plugin must make sure that multiple conflicting
 	// TLS configuration return an error: it can only be specified once.
 	var tlsConfig *tls.Config
-	for _, conf := range s.zones {
-		// Should we error if some configs *don't* have TLS?
-		tlsConfig = conf.TLSConfig
+	for _, configs := range s.zones {
+		for _, conf := range configs {
+			if conf.TLSConfig != nil {
+				tlsConfig = conf.TLSConfig
+			}
+		}
+	}
+	// Cleartext DoH is served by http:// servers.
+	if tlsConfig == nil {
+		return nil, fmt.Errorf("%s: DNS-over-HTTPS needs a certificate, set it with the tls plugin", addr)
+	}
+	// Offer HTTP/2 with ALPN, as RFC 8484 recommends, falling back to HTTP/1.1.
+	tlsConfig = tlsConfig.Clone()
+	if len(tlsConfig.NextProtos) == 0 {
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
 	}
 
-	sh := &ServerHTTPS{Server: s, tlsConfig: tlsConfig, httpsServer: new(http.Server)}
-	sh.httpsServer.Handler = sh
+	sh := &ServerHTTPS{Server: s, tlsConfig: tlsConfig}
+	if sh.httpsServer, err = newHTTPServer(sh, s.httpOptions, true); err != nil {
+		return nil, err
+	}
 
 	return sh, nil
 }
@@ -49,10 +63,7 @@ func (s *ServerHTTPS) Serve(l net.Listener) error {
 	s.listenAddr = l.Addr()
 	s.m.Unlock()
 
-	if s.tlsConfig != nil {
-		l = tls.NewListener(l, s.tlsConfig)
-	}
-	return s.httpsServer.Serve(l)
+	return s.httpsServer.Serve(tls.NewListener(s.proxyListener(l), s.tlsConfig))
 }
 
 // ServePacket implements caddy.UDPServer interface.
@@ -60,8 +71,11 @@ func (s *ServerHTTPS) ServePacket(p net.PacketConn) error { return nil }
 
 // Listen implements caddy.TCPServer interface.
 func (s *ServerHTTPS) Listen() (net.Listener, error) {
-
-	l, err := net.Listen("tcp", s.Addr[len(transport.HTTPS+"://"):])
+	addr := s.Addr[len(transport.HTTPS+"://"):]
+	if l, ok := activatedListener(addr); ok {
+		return l, nil
+	}
+	l, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +104,12 @@ func (s *ServerHTTPS) Stop() error {
 	s.m.Lock()
 	defer s.m.Unlock()
 	if s.httpsServer != nil {
-		s.httpsServer.Shutdown(context.Background())
+		ctx, cancel := s.drainContext()
+		defer cancel()
+		if err := s.httpsServer.Shutdown(ctx); err != nil {
+			// The grace timeout expired, drop the requests still in flight.
+			return s.httpsServer.Close()
+		}
 	}
 	return nil
 }
@@ -98,26 +117,44 @@ func (s *ServerHTTPS) Stop() error {
 // ServeHTTP is the handler that gets the HTTP request and converts to the dns format, calls the plugin
 // chain, converts it back and write it to the client.
 func (s *ServerHTTPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h := s.httpHandler(r.URL.Path); h != nil {
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	mw := &httpMetrics{ResponseWriter: w}
+	w = mw
+	size := 0
+	defer func(start time.Time) { mw.report(s.Addr, r, size, start) }(time.Now())
 
-	if r.URL.Path != doh.Path {
+	if !s.dohPath(r.URL.Path) {
 		http.Error(w, "", http.StatusNotFound)
 		return
 	}
+	if !s.authorize(w, r) {
+		return
+	}
 
 	msg, err := doh.RequestToMsg(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if err == doh.ErrMethod {
+			w.Header().Set("Allow", "GET, POST")
+		}
+		http.Error(w, err.Error(), doh.Status(err))
 		return
 	}
+	size = msg.Len()
 
 	// Create a DoHWriter with the correct addresses in it.
-	h, p, _ := net.SplitHostPort(r.RemoteAddr)
-	port, _ := strconv.Atoi(p)
-	dw := &DoHWriter{laddr: s.listenAddr, raddr: &net.TCPAddr{IP: net.ParseIP(h), Port: port}}
+	dw := &DoHWriter{laddr: s.listenAddr, raddr: s.clientAddr(r)}
 
 	// We just call the normal chain handler - all error handling is done there.
 	// We should expect a packet to be returned that we can send to the client.
 	ctx := context.WithValue(context.Background(), Key{}, s.Server)
+	ctx = context.WithValue(ctx, HTTPRequestKey{}, r)
+	if cert := verifiedClientCert(r.TLS); cert != nil {
+		ctx = context.WithValue(ctx, ClientCertKey{}, cert)
+	}
 	s.ServeDNS(ctx, dw, msg)
 
 	// See section 4.2.1 of RFC 8484.
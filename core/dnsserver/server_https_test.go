@@ -0,0 +1,64 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	pkgtls "github.com/coredns/coredns/plugin/pkg/tls"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestServerHTTPS(t *testing.T) {
+	dir, rm, err := test.WritePEMFiles("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+	tc, err := pkgtls.NewTLSConfigFromArgs(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := testConfig("https", test.ErrorHandler())
+	if _, err := NewServerHTTPS("https://127.0.0.1:0", []*Config{c}); err == nil {
+		t.Fatal("Expected an error without a TLS config")
+	}
+
+	c.TLSConfig = tc
+	s, err := NewServerHTTPS("https://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	defer s.Stop()
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2", "http/1.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := conn.ConnectionState().NegotiatedProtocol; p != "h2" {
+		t.Errorf("Expected protocol h2 to be negotiated, got %q", p)
+	}
+	conn.Close()
+
+	client, err := doh.NewClient("https://"+l.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	if _, err := client.Exchange(context.Background(), m); err != nil {
+		t.Errorf("Expected a response, got %s", err)
+	}
+}
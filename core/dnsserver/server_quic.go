@@ -0,0 +1,316 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doq"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// quicReadTimeout is the time a client has to send its query after opening a stream.
+const quicReadTimeout = 5 * time.Second
+
+// ServerQUIC represents an instance of a DNS-over-QUIC server, see RFC 9250.
+type ServerQUIC struct {
+	*Server
+	tlsConfig  *tls.Config
+	quicConfig *quic.Config
+	quic       quicListener
+}
+
+// NewServerQUIC returns a new CoreDNS QUIC server and compiles all plugins in to it.
+func NewServerQUIC(addr string, group []*Config) (*ServerQUIC, error) {
+	s, err := NewServer(addr, group)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := quicTLSConfig(s)
+	if tlsConfig == nil {
+		return nil, fmt.Errorf("%s: DNS-over-QUIC needs a certificate, set it with the tls plugin", addr)
+	}
+	tlsConfig.NextProtos = []string{doq.NextProto}
+
+	// 0-RTT data can be replayed, we don't accept it: a client sends its queries after the handshake.
+	quicConfig := &quic.Config{Allow0RTT: false}
+
+	return &ServerQUIC{Server: s, tlsConfig: tlsConfig, quicConfig: quicConfig, quic: quicListener{noError: doq.NoError}}, nil
+}
+
+// quicTLSConfig returns a copy of the TLS config of the server blocks of s, or nil if they have none.
+func quicTLSConfig(s *Server) *tls.Config {
+	// The *tls* plugin must make sure that multiple conflicting
+	// TLS configuration return an error: it can only be specified once.
+	var tlsConfig *tls.Config
+	for _, configs := range s.zones {
+		for _, conf := range configs {
+			if conf.TLSConfig != nil {
+				tlsConfig = conf.TLSConfig
+			}
+		}
+	}
+	if tlsConfig == nil {
+		return nil
+	}
+	return tlsConfig.Clone()
+}
+
+// Serve implements caddy.TCPServer interface.
+func (s *ServerQUIC) Serve(l net.Listener) error { return nil }
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerQUIC) ServePacket(p net.PacketConn) error {
+	return s.quic.serve(p, s.tlsConfig, s.quicConfig, s.serveConn)
+}
+
+// serveConn answers the queries on the streams of conn, until it is closed.
+func (s *ServerQUIC) serveConn(conn quic.Connection) {
+	for {
+		str, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		if !s.quic.begin() {
+			str.CancelRead(doq.RequestCancelled)
+			str.CancelWrite(doq.RequestCancelled)
+			continue
+		}
+
+		go func() {
+			defer s.quic.done()
+			s.serveStream(conn, str)
+		}()
+	}
+}
+
+// serveStream answers the query on str.
+func (s *ServerQUIC) serveStream(conn quic.Connection, str quic.Stream) {
+	str.SetReadDeadline(time.Now().Add(quicReadTimeout))
+	m, err := doq.ReadMsg(str)
+	if err != nil {
+		// A malformed query is a protocol error that closes the connection, see section 4.3.3 of RFC 9250.
+		conn.CloseWithError(doq.ProtocolError, err.Error())
+		return
+	}
+
+	w := &doqWriter{str: str, laddr: conn.LocalAddr(), raddr: streamAddr(conn.RemoteAddr())}
+	ctx := context.WithValue(context.Background(), Key{}, s.Server)
+	cs := conn.ConnectionState().TLS
+	ctx = context.WithValue(ctx, TLSConnectionStateKey{}, &cs)
+	if cert := verifiedClientCert(&cs); cert != nil {
+		ctx = context.WithValue(ctx, ClientCertKey{}, cert)
+	}
+	s.ServeDNS(ctx, w, m)
+
+	if !w.written {
+		// Nothing was written, tell the client no answer is coming.
+		str.CancelRead(doq.InternalError)
+		str.CancelWrite(doq.InternalError)
+	}
+}
+
+// Listen implements caddy.TCPServer interface.
+func (s *ServerQUIC) Listen() (net.Listener, error) { return nil, nil }
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerQUIC) ListenPacket() (net.PacketConn, error) {
+	addr := s.Addr[len(transport.QUIC+"://"):]
+	if p, ok := activatedPacketConn(addr); ok {
+		return p, nil
+	}
+	return listenPacket("udp", addr)
+}
+
+// Stop stops the server. It stops accepting connections and waits for the queries in flight to be
+// answered, up to the grace timeout, before it closes the connections.
+func (s *ServerQUIC) Stop() error {
+	ctx, cancel := s.drainContext()
+	defer cancel()
+	return s.quic.stop(ctx)
+}
+
+// OnStartupComplete lists the sites served by this server
+// and any relevant information, assuming Quiet is false.
+func (s *ServerQUIC) OnStartupComplete() {
+	if Quiet {
+		return
+	}
+
+	out := startUpZones(transport.QUIC+"://", s.Addr, s.zones)
+	if out != "" {
+		fmt.Print(out)
+	}
+}
+
+// streamAddr returns the UDP address a of a QUIC connection as a TCP address: QUIC streams, like TCP,
+// carry responses of any size, the plugins must not truncate them to the UDP size of the client.
+func streamAddr(a net.Addr) net.Addr {
+	if u, ok := a.(*net.UDPAddr); ok {
+		return &net.TCPAddr{IP: u.IP, Port: u.Port, Zone: u.Zone}
+	}
+	return a
+}
+
+// doqWriter writes the response to a DoQ query on its stream.
+type doqWriter struct {
+	str     quic.Stream
+	laddr   net.Addr
+	raddr   net.Addr
+	written bool
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *doqWriter) WriteMsg(m *dns.Msg) error {
+	buf, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// Write implements the dns.ResponseWriter interface. The stream is closed after the response, a stream
+// carries a single query and response.
+func (w *doqWriter) Write(buf []byte) (int, error) {
+	if w.written {
+		return 0, fmt.Errorf("doq: response already written")
+	}
+	w.written = true
+	if _, err := w.str.Write(doq.Frame(buf)); err != nil {
+		return 0, err
+	}
+	return len(buf), w.str.Close()
+}
+
+// Close implements the dns.ResponseWriter interface.
+func (w *doqWriter) Close() error { return w.str.Close() }
+
+// LocalAddr implements the dns.ResponseWriter interface.
+func (w *doqWriter) LocalAddr() net.Addr { return w.laddr }
+
+// RemoteAddr implements the dns.ResponseWriter interface.
+func (w *doqWriter) RemoteAddr() net.Addr { return w.raddr }
+
+// TsigStatus implements the dns.ResponseWriter interface. The TSIG of the queries is not verified.
+func (w *doqWriter) TsigStatus() error { return dns.ErrSecret }
+
+// TsigTimersOnly implements the dns.ResponseWriter interface.
+func (w *doqWriter) TsigTimersOnly(bool) {}
+
+// Hijack implements the dns.ResponseWriter interface.
+func (w *doqWriter) Hijack() {}
+
+// quicListener accepts the QUIC connections of a server, and drains and closes them when the server
+// stops.
+type quicListener struct {
+	noError quic.ApplicationErrorCode // closes the connections when stopping
+
+	mu        sync.Mutex
+	packet    net.PacketConn
+	transport *quic.Transport
+	listener  *quic.Listener
+	conns     map[quic.Connection]struct{}
+	closing   bool
+	queries   sync.WaitGroup // the queries being answered, see begin
+}
+
+// serve accepts the connections on p and calls handle for each of them in its own goroutine. It
+// returns when the listener is stopped.
+func (q *quicListener) serve(p net.PacketConn, tlsConfig *tls.Config, quicConfig *quic.Config, handle func(quic.Connection)) error {
+	q.mu.Lock()
+	if q.closing {
+		q.mu.Unlock()
+		return nil
+	}
+	tr := &quic.Transport{Conn: p}
+	l, err := tr.Listen(tlsConfig, quicConfig)
+	if err != nil {
+		q.mu.Unlock()
+		return err
+	}
+	q.packet, q.transport, q.listener = p, tr, l
+	q.conns = make(map[quic.Connection]struct{})
+	q.mu.Unlock()
+
+	for {
+		conn, err := l.Accept(context.Background())
+		if err != nil {
+			if err == quic.ErrServerClosed {
+				return nil
+			}
+			return err
+		}
+		q.mu.Lock()
+		if q.closing {
+			q.mu.Unlock()
+			conn.CloseWithError(q.noError, "")
+			continue
+		}
+		q.conns[conn] = struct{}{}
+		q.mu.Unlock()
+
+		go func() {
+			handle(conn)
+			q.mu.Lock()
+			delete(q.conns, conn)
+			q.mu.Unlock()
+		}()
+	}
+}
+
+// begin is called before answering a query. It returns false when the listener is stopping, the query
+// must then be refused. Otherwise done must be called after the query is answered.
+func (q *quicListener) begin() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closing {
+		return false
+	}
+	q.queries.Add(1)
+	return true
+}
+
+// done is called after a query is answered, see begin.
+func (q *quicListener) done() { q.queries.Done() }
+
+// stop stops accepting connections and waits for the queries in flight to be answered, until ctx is
+// done. It then closes the connections and the packet conn. Stopping a stopped listener does nothing.
+func (q *quicListener) stop(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closing {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closing = true
+	l := q.listener
+	q.mu.Unlock()
+	if l == nil {
+		return nil
+	}
+	l.Close()
+
+	done := make(chan struct{})
+	go func() {
+		q.queries.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
+
+	q.mu.Lock()
+	for conn := range q.conns {
+		conn.CloseWithError(q.noError, "")
+	}
+	q.mu.Unlock()
+	q.transport.Close()
+	return q.packet.Close()
+}
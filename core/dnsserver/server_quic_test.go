@@ -0,0 +1,124 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doq"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// newTestQUICServer returns a running DoQ server answering with certPlugin and the TLS config to use
+// with it.
+func newTestQUICServer(t *testing.T) (*ServerQUIC, string, *tls.Config) {
+	ca := newTestCert(t, "ca", nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	c := testConfig("quic", certPlugin{})
+	c.TLSConfig = &tls.Config{Certificates: []tls.Certificate{newTestCert(t, "server", &ca)}}
+	s, err := NewServerQUIC("quic://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServerQUIC, got %s", err)
+	}
+	p, err := listenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServePacket(p)
+
+	return s, p.LocalAddr().String(), &tls.Config{RootCAs: pool, NextProtos: []string{doq.NextProto}}
+}
+
+func TestServerQUIC(t *testing.T) {
+	s, addr, tlsConfig := newTestQUICServer(t)
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := quic.DialAddr(ctx, addr, tlsConfig, nil)
+	if err != nil {
+		t.Fatalf("Expected no error dialing the server, got %s", err)
+	}
+	defer conn.CloseWithError(doq.NoError, "")
+
+	// Two queries on their own streams of the same connection.
+	for i := 0; i < 2; i++ {
+		str, err := conn.OpenStreamSync(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeTXT)
+		m.Id = 0
+		buf, _ := doq.Pack(m)
+		str.Write(buf)
+		str.Close()
+
+		r, err := doq.ReadMsg(str)
+		if err != nil {
+			t.Fatalf("Test %d: expected a response, got %s", i, err)
+		}
+		if len(r.Answer) != 1 || r.Answer[0].(*dns.TXT).Txt[0] != "none" {
+			t.Errorf("Test %d: expected the answer of the plugin, got %v", i, r.Answer)
+		}
+	}
+}
+
+func TestServerQUICMessageID(t *testing.T) {
+	s, addr, tlsConfig := newTestQUICServer(t)
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := quic.DialAddr(ctx, addr, tlsConfig, nil)
+	if err != nil {
+		t.Fatalf("Expected no error dialing the server, got %s", err)
+	}
+
+	str, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeTXT)
+	m.Id = 42
+	buf, _ := doq.Pack(m)
+	str.Write(buf)
+	str.Close()
+
+	// A query with a Message ID that is not 0 closes the connection with a protocol error.
+	_, err = doq.ReadMsg(str)
+	var aerr *quic.ApplicationError
+	if !errors.As(err, &aerr) || aerr.ErrorCode != doq.ProtocolError {
+		t.Errorf("Expected the connection to be closed with a protocol error, got %v", err)
+	}
+}
+
+func TestServerQUICStop(t *testing.T) {
+	s, addr, tlsConfig := newTestQUICServer(t)
+	if err := s.Stop(); err != nil {
+		t.Errorf("Expected no error for Stop, got %s", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Errorf("Expected no error for the second Stop, got %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if _, err := quic.DialAddr(ctx, addr, tlsConfig, nil); err == nil {
+		t.Error("Expected an error dialing a stopped server, got none")
+	}
+}
+
+func TestNewServerQUICNoCert(t *testing.T) {
+	if _, err := NewServerQUIC("quic://127.0.0.1:853", []*Config{testConfig("quic", testPlugin{})}); err == nil {
+		t.Error("Expected an error for a DoQ server without a certificate, got none")
+	}
+}
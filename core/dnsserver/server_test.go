@@ -2,10 +2,16 @@ package dnsserver
 
 import (
 	"context"
+	"errors"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/ede"
 	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
 )
@@ -48,6 +54,296 @@ func TestNewServer(t *testing.T) {
 	}
 }
 
+func TestServeDNSDeadline(t *testing.T) {
+	var budget time.Duration
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		budget = plugin.Budget(ctx, time.Hour)
+		return 0, nil
+	})
+	c := testConfig("dns", h)
+	c.QueryTimeout = time.Second
+	s, err := NewServer("127.0.0.1:53", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("aaa.example.com.", dns.TypeTXT)
+	s.ServeDNS(context.TODO(), &test.ResponseWriter{}, m)
+	if budget <= 0 || budget > time.Second {
+		t.Errorf("Expected a budget of at most %s, got %s", time.Second, budget)
+	}
+
+	// A timeout longer than the default is used too.
+	c.QueryTimeout = time.Minute
+	if s, err = NewServer("127.0.0.1:53", []*Config{c}); err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+	s.ServeDNS(context.TODO(), &test.ResponseWriter{}, m)
+	if budget <= DefaultQueryTimeout || budget > time.Minute {
+		t.Errorf("Expected a budget of at most %s, got %s", time.Minute, budget)
+	}
+
+	// An earlier deadline set by the transport is kept.
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	s.ServeDNS(ctx, &test.ResponseWriter{}, m)
+	if budget <= 0 || budget > 100*time.Millisecond {
+		t.Errorf("Expected a budget of at most %s, got %s", 100*time.Millisecond, budget)
+	}
+}
+
+func TestServeDNSSanitize(t *testing.T) {
+	var called bool
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		called = true
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.Question[0].Name == "bad.example.com." {
+			m.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: "bad..example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET}}}
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	s, err := NewServer("127.0.0.1:53", []*Config{testConfig("dns", h)})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+
+	tests := []struct {
+		qnames []string
+		called bool
+		rcode  int
+	}{
+		{[]string{"aaa.example.com."}, true, dns.RcodeSuccess},
+		{[]string{"aaa.example.com.", "bbb.example.com."}, false, dns.RcodeFormatError},
+		{[]string{"bad.example.com."}, true, dns.RcodeServerFailure},
+	}
+	for i, tc := range tests {
+		called = false
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qnames[0], dns.TypeA)
+		for _, q := range tc.qnames[1:] {
+			m.Question = append(m.Question, dns.Question{Name: q, Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		s.ServeDNS(context.TODO(), rec, m)
+		if called != tc.called {
+			t.Errorf("Test %d: expected the plugin to be called %t, got %t", i, tc.called, called)
+		}
+		if rec.Msg == nil || rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %v", i, tc.rcode, rec.Msg)
+		}
+	}
+}
+
+func TestServeDNSExtendedError(t *testing.T) {
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		return dns.RcodeServerFailure, ede.Wrap(ede.NetworkError, errors.New("timeout"))
+	})
+	s, err := NewServer("127.0.0.1:53", []*Config{testConfig("dns", h)})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+
+	tests := []struct {
+		qname string
+		rcode int
+		code  uint16
+	}{
+		{"aaa.example.com.", dns.RcodeServerFailure, ede.NetworkError},
+		{"example.org.", dns.RcodeRefused, ede.NotAuthoritative},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		m.SetEdns0(4096, false)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		s.ServeDNS(context.TODO(), rec, m)
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.rcode, rec.Msg.Rcode)
+		}
+		if code, _, ok := ede.Get(rec.Msg); !ok || code != tc.code {
+			t.Errorf("Test %d: expected Extended DNS Error %d, got %d", i, tc.code, code)
+		}
+	}
+
+	// Without EDNS0 there is no Extended DNS Error.
+	m := new(dns.Msg)
+	m.SetQuestion("aaa.example.com.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	s.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg.IsEdns0() != nil {
+		t.Errorf("Expected no OPT record, got %s", rec.Msg.IsEdns0())
+	}
+}
+
+func TestServeDNSUpdate(t *testing.T) {
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+
+	for _, updates := range []bool{false, true} {
+		c := testConfig("dns", h)
+		c.Updates = updates
+		s, err := NewServer("127.0.0.1:53", []*Config{c})
+		if err != nil {
+			t.Fatalf("Expected no error for NewServer, got %s", err)
+		}
+
+		m := new(dns.Msg)
+		m.SetUpdate("example.com.")
+		m.Insert([]dns.RR{test.A("a.example.com. 300 IN A 127.0.0.1")})
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		s.ServeDNS(context.TODO(), rec, m)
+
+		rcode := dns.RcodeNotImplemented
+		if updates {
+			rcode = dns.RcodeSuccess
+		}
+		if rec.Msg.Rcode != rcode {
+			t.Errorf("Expected rcode %d with updates %t, got %d", rcode, updates, rec.Msg.Rcode)
+		}
+	}
+
+	// The dynamic updates get past the accept func, other messages with records in all sections don't.
+	dh := dns.Header{Bits: uint16(dns.OpcodeUpdate) << 11, Qdcount: 1, Ancount: 2, Nscount: 3, Arcount: 3}
+	if a := acceptFunc(dh); a != dns.MsgAccept {
+		t.Errorf("Expected an update to be accepted, got %d", a)
+	}
+	dh.Bits = 0
+	if a := acceptFunc(dh); a != dns.MsgReject {
+		t.Errorf("Expected a query with records in all sections to be rejected, got %d", a)
+	}
+}
+
+func TestServeDNSViews(t *testing.T) {
+	var served string
+	handler := func(name string) plugin.Handler {
+		return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			view, _ := ctx.Value(ViewKey{}).(string)
+			served = name + "/" + view
+			m := new(dns.Msg)
+			m.SetReply(r)
+			w.WriteMsg(m)
+			return dns.RcodeSuccess, nil
+		})
+	}
+	all := testConfig("dns", handler("all"))
+	tcp := testConfig("dns", handler("tcp"))
+	tcp.ViewName = "tcp"
+	tcp.Filters = []Filter{func(_ context.Context, state *request.Request) bool { return state.Proto() == "tcp" }}
+
+	// The server block without a view comes first, it still only gets the queries of no view.
+	s, err := NewServer("127.0.0.1:53", []*Config{all, tcp})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+
+	tests := []struct {
+		tcp      bool
+		expected string
+	}{
+		{false, "all/"},
+		{true, "tcp/tcp"},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("www.example.com.", dns.TypeA)
+		s.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{TCP: tc.tcp}), m)
+		if served != tc.expected {
+			t.Errorf("Test %d: expected the query served by %s, got %s", i, tc.expected, served)
+		}
+	}
+}
+
+// slowPlugin answers after delay, it signals started when it gets a query.
+type slowPlugin struct {
+	started chan struct{}
+	delay   time.Duration
+}
+
+func (sp slowPlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	close(sp.started)
+	time.Sleep(sp.delay)
+	m := new(dns.Msg)
+	m.SetReply(r)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+func (sp slowPlugin) Name() string { return "slowplugin" }
+
+func TestStopDrain(t *testing.T) {
+	tests := []struct {
+		grace    time.Duration
+		answered bool
+	}{
+		// The query in flight is answered before the connection is closed.
+		{2 * time.Second, true},
+		// The grace timeout expires first, Stop doesn't wait for the query.
+		{50 * time.Millisecond, false},
+	}
+	for i, tc := range tests {
+		sp := slowPlugin{started: make(chan struct{}), delay: 500 * time.Millisecond}
+		c := testConfig("dns", sp)
+		c.GraceTimeout = tc.grace
+		s, err := NewServer("dns://127.0.0.1:0", []*Config{c})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go s.Serve(l)
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		errc := make(chan error, 1)
+		go func() {
+			client := &dns.Client{Net: "tcp"}
+			_, _, err := client.Exchange(m, l.Addr().String())
+			errc <- err
+		}()
+		<-sp.started
+
+		start := time.Now()
+		s.Stop()
+		if d := time.Since(start); !tc.answered && d > sp.delay {
+			t.Errorf("Test %d: expected Stop to return after the grace timeout, took %s", i, d)
+		}
+		if tc.answered {
+			if err := <-errc; err != nil {
+				t.Errorf("Test %d: expected the query in flight to be answered, got %s", i, err)
+			}
+		}
+	}
+}
+
+func TestStopTwice(t *testing.T) {
+	s, err := NewServer("dns://127.0.0.1:0", []*Config{testConfig("dns", testPlugin{})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+
+	// A server stopped by a reload can be stopped again by the shutdown, this must not panic.
+	if err := s.Stop(); err != nil {
+		t.Errorf("Expected no error for Stop, got %s", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Errorf("Expected no error for the second Stop, got %s", err)
+	}
+}
+
 func BenchmarkCoreServeDNS(b *testing.B) {
 	s, err := NewServer("127.0.0.1:53", []*Config{testConfig("dns", testPlugin{})})
 	if err != nil {
@@ -65,3 +361,54 @@ func BenchmarkCoreServeDNS(b *testing.B) {
 		s.ServeDNS(ctx, w, m)
 	}
 }
+
+// addrPlugin answers with the address of the client in a TXT record.
+type addrPlugin struct{}
+
+func (addrPlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = append(m.Answer, &dns.TXT{Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET}, Txt: []string{w.RemoteAddr().String()}})
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+func (addrPlugin) Name() string { return "addrplugin" }
+
+func TestServeProxyProtocol(t *testing.T) {
+	c := testConfig("dns", addrPlugin{})
+	_, n, _ := net.ParseCIDR("127.0.0.0/8")
+	c.ProxyProtocol = []*net.IPNet{n}
+	s, err := NewServer("dns://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("PROXY TCP4 198.51.100.1 127.0.0.1 56324 53\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	dc := &dns.Conn{Conn: conn}
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeTXT)
+	if err := dc.WriteMsg(m); err != nil {
+		t.Fatal(err)
+	}
+	r, err := dc.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Answer) != 1 || r.Answer[0].(*dns.TXT).Txt[0] != "198.51.100.1:56324" {
+		t.Errorf("Expected the client address of the header, got %v", r.Answer)
+	}
+}
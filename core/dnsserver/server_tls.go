@@ -3,6 +3,7 @@ package dnsserver
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net"
 
@@ -26,9 +27,11 @@ func NewServerTLS(addr string, group []*Config) (*ServerTLS, error) {
 	// The *tls* plugin must make sure that multiple conflicting
 	// TLS configuration return an error: it can only be specified once.
 	var tlsConfig *tls.Config
-	for _, conf := range s.zones {
-		// Should we error if some configs *don't* have TLS?
-		tlsConfig = conf.TLSConfig
+	for _, configs := range s.zones {
+		for _, conf := range configs {
+			// Should we error if some configs *don't* have TLS?
+			tlsConfig = conf.TLSConfig
+		}
 	}
 
 	return &ServerTLS{Server: s, tlsConfig: tlsConfig}, nil
@@ -38,6 +41,7 @@ func NewServerTLS(addr string, group []*Config) (*ServerTLS, error) {
 func (s *ServerTLS) Serve(l net.Listener) error {
 	s.m.Lock()
 
+	l = s.proxyListener(l)
 	if s.tlsConfig != nil {
 		l = tls.NewListener(l, s.tlsConfig)
 	}
@@ -45,8 +49,18 @@ func (s *ServerTLS) Serve(l net.Listener) error {
 	// Only fill out the TCP server for this one.
 	s.server[tcp] = &dns.Server{Listener: l, Net: "tcp-tls", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
 		ctx := context.WithValue(context.Background(), Key{}, s.Server)
+		if c, ok := w.(dns.ConnectionStater); ok {
+			cs := c.ConnectionState()
+			if cs != nil {
+				ctx = context.WithValue(ctx, TLSConnectionStateKey{}, cs)
+			}
+			if cert := verifiedClientCert(cs); cert != nil {
+				ctx = context.WithValue(ctx, ClientCertKey{}, cert)
+			}
+		}
 		s.ServeDNS(ctx, w, r)
-	})}
+	}), TsigSecret: s.tsigSecret, MsgAcceptFunc: acceptFunc}
+	s.stopDSO = enableDSO(s.server[tcp], s.Server)
 	s.m.Unlock()
 
 	return s.server[tcp].ActivateAndServe()
@@ -57,7 +71,11 @@ func (s *ServerTLS) ServePacket(p net.PacketConn) error { return nil }
 
 // Listen implements caddy.TCPServer interface.
 func (s *ServerTLS) Listen() (net.Listener, error) {
-	l, err := net.Listen("tcp", s.Addr[len(transport.TLS+"://"):])
+	addr := s.Addr[len(transport.TLS+"://"):]
+	if l, ok := activatedListener(addr); ok {
+		return l, nil
+	}
+	l, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
@@ -80,3 +98,11 @@ func (s *ServerTLS) OnStartupComplete() {
 	}
 	return
 }
+
+// verifiedClientCert returns the certificate of the client of the connection cs, when it was verified.
+func verifiedClientCert(cs *tls.ConnectionState) *x509.Certificate {
+	if cs == nil || len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return cs.VerifiedChains[0][0]
+}
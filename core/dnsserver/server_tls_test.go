@@ -0,0 +1,144 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/pb"
+
+	"github.com/miekg/dns"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// certPlugin answers with the common name of the verified client certificate in a TXT record.
+type certPlugin struct{}
+
+func (certPlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	cn := "none"
+	if cert, ok := ctx.Value(ClientCertKey{}).(*x509.Certificate); ok {
+		cn = cert.Subject.CommonName
+	}
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = append(m.Answer, &dns.TXT{Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET}, Txt: []string{cn}})
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+func (certPlugin) Name() string { return "certplugin" }
+
+// newTestCert returns a certificate for name signed by parent, or self-signed when parent is nil.
+func newTestCert(t *testing.T, name string, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, interface{}(key)
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestClientCert(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	serverCert := newTestCert(t, "server", &ca)
+	clientCert := newTestCert(t, "tenant-a", &ca)
+
+	c := testConfig("tls", certPlugin{})
+	c.TLSConfig = &tls.Config{Certificates: []tls.Certificate{serverCert}, ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	clientConfig := &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeTXT)
+
+	// DNS-over-TLS
+	s, err := NewServerTLS("tls://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	defer s.Stop()
+
+	for _, tc := range []struct {
+		config *tls.Config
+		cn     string
+	}{
+		{clientConfig, "tenant-a"},
+		{&tls.Config{RootCAs: pool}, "none"},
+	} {
+		client := &dns.Client{Net: "tcp-tls", TLSConfig: tc.config}
+		r, _, err := client.Exchange(m, l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cn := r.Answer[0].(*dns.TXT).Txt[0]; cn != tc.cn {
+			t.Errorf("Expected the client %s over DNS-over-TLS, got %s", tc.cn, cn)
+		}
+	}
+
+	// DNS-over-gRPC
+	c.Transport = "grpc"
+	g, err := NewServergRPC("grpc://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go g.Serve(gl)
+	defer g.Stop()
+
+	conn, err := grpc.Dial(gl.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(clientConfig)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	buf, _ := m.Pack()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := pb.NewDnsServiceClient(conn).Query(ctx, &pb.DnsPacket{Msg: buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(res.Msg); err != nil {
+		t.Fatal(err)
+	}
+	if cn := r.Answer[0].(*dns.TXT).Txt[0]; cn != "tenant-a" {
+		t.Errorf("Expected the client tenant-a over DNS-over-gRPC, got %s", cn)
+	}
+}
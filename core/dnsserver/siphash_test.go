@@ -0,0 +1,18 @@
+package dnsserver
+
+import "testing"
+
+func TestSipHash24(t *testing.T) {
+	// The test vector of appendix A of the SipHash paper.
+	key := make([]byte, 16)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	msg := make([]byte, 15)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	if h := sipHash24(key, msg); h != 0xa129ca6149be45e5 {
+		t.Errorf("Expected hash a129ca6149be45e5, got %x", h)
+	}
+}
@@ -0,0 +1,62 @@
+package dnsserver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics/vars"
+	"github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+// SoftFail makes a plugin that fails to load its data, i.e. a broken zone file or an unreachable
+// backend, fail its server block only instead of the whole process, see Failure.
+var SoftFail bool
+
+// Failure is called by a plugin with the error that keeps it from setting up the server block of c.
+// Without SoftFail, err is returned and the plugin should return it from its setup function. With
+// SoftFail, err is logged and recorded and nil is returned; the plugin should then skip adding
+// itself. The server block answers all queries with SERVFAIL, and the reload plugin keeps reloading
+// the Corefile until the failure is gone.
+//
+// Failure should only be used for errors in the data a plugin loads, not for errors in its
+// configuration, those still abort the startup.
+func Failure(c *caddy.Controller, name string, err error) error {
+	if !SoftFail {
+		return err
+	}
+	config := GetConfig(c)
+	config.failures = append(config.failures, plugin.Error(name, err))
+	vars.SetupFailure.WithLabelValues(config.Zone, name).Inc()
+	log.Errorf("Server block %s failed, answering with SERVFAIL: %s", config.Key(), plugin.Error(name, err))
+	return nil
+}
+
+// Failed returns true if the server block of c has failed to set up, see Failure.
+func (c *Config) Failed() bool { return len(c.failures) > 0 }
+
+// Failed returns true if any server block of instance i has failed to set up.
+func Failed(i *caddy.Instance) bool {
+	for _, b := range blocks(i) {
+		if b.config.Failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// failedHandler is the plugin chain of a server block that failed to set up.
+type failedHandler struct {
+	failures []error
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (f failedHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	return dns.RcodeServerFailure, fmt.Errorf("server block failed to set up: %s", f.failures[0])
+}
+
+// Name implements the plugin.Handler interface.
+func (f failedHandler) Name() string { return "failed" }
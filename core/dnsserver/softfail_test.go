@@ -0,0 +1,51 @@
+package dnsserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestFailure(t *testing.T) {
+	defer func() { SoftFail = false }()
+
+	c := caddy.NewTestController("dns", "")
+	e := errors.New("broken zone")
+	if err := Failure(c, "file", e); err != e {
+		t.Errorf("Expected %s without soft failures, got %v", e, err)
+	}
+	if len(GetConfig(c).failures) != 0 {
+		t.Errorf("Expected no recorded failures")
+	}
+
+	SoftFail = true
+	if err := Failure(c, "file", e); err != nil {
+		t.Errorf("Expected no error with soft failures, got %s", err)
+	}
+	if len(GetConfig(c).failures) != 1 {
+		t.Errorf("Expected 1 recorded failure, got %d", len(GetConfig(c).failures))
+	}
+}
+
+func TestServeDNSFailed(t *testing.T) {
+	cfg := testConfig("dns", testPlugin{})
+	cfg.failures = []error{errors.New("broken zone")}
+	s, err := NewServer("127.0.0.1:53", []*Config{cfg})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	s.ServeDNS(context.TODO(), rec, m)
+	if rec.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected SERVFAIL, got %s", dns.RcodeToString[rec.Rcode])
+	}
+}
@@ -0,0 +1,32 @@
+package dnsserver
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// boundToAddress returns true if u is bound to a specific address instead of the wildcard address.
+// The responses sent on such a socket always come from the right address, there is no need to set
+// their source address with a control message.
+func boundToAddress(u *net.UDPConn) bool {
+	a, ok := u.LocalAddr().(*net.UDPAddr)
+	return ok && a.IP != nil && !a.IP.IsUnspecified()
+}
+
+// udpWriter writes the responses of dns.Server straight to a UDP socket bound to a specific address.
+// The writer of dns.Server always sends the response with a control message that sets its source
+// address, which it parses from the query's control message for every response.
+type udpWriter struct {
+	dns.ResponseWriter
+	conn *net.UDPConn
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w udpWriter) Write(m []byte) (int, error) {
+	raddr, ok := w.RemoteAddr().(*net.UDPAddr)
+	if !ok {
+		return w.ResponseWriter.Write(m)
+	}
+	return w.conn.WriteToUDP(m, raddr)
+}
@@ -0,0 +1,116 @@
+package dnsserver
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
+)
+
+func TestBoundToAddress(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{"127.0.0.1:0", true},
+		{"0.0.0.0:0", false},
+		{":0", false},
+	}
+	for i, tc := range tests {
+		a, _ := net.ResolveUDPAddr("udp", tc.addr)
+		u, err := net.ListenUDP("udp", a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := boundToAddress(u); got != tc.expected {
+			t.Errorf("Test %d: expected %t for %s, got %t", i, tc.expected, tc.addr, got)
+		}
+		u.Close()
+	}
+}
+
+func TestServePacketBound(t *testing.T) {
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A(r.Question[0].Name + " 3600 IN A 127.0.0.1")}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	c := testConfig("dns", h)
+	c.Zone = "."
+	s, err := NewServer("127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServePacket(pc)
+	defer s.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	resp, err := dns.Exchange(m, pc.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Expected a response, got %s", err)
+	}
+	if resp.Id != m.Id || len(resp.Answer) != 1 {
+		t.Errorf("Expected a response with 1 answer, got %v", resp)
+	}
+}
+
+// remoteWriter is a dns.ResponseWriter with a remote address.
+type remoteWriter struct {
+	test.ResponseWriter
+	raddr net.Addr
+}
+
+func (w *remoteWriter) RemoteAddr() net.Addr { return w.raddr }
+
+// BenchmarkUDPWrite compares packing and writing a response as dns.Server does, with packing it in
+// a pooled buffer and writing it without control message on a socket bound to an address.
+func BenchmarkUDPWrite(b *testing.B) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer server.Close()
+	ipv4.NewPacketConn(server).SetControlMessage(ipv4.FlagDst|ipv4.FlagInterface, true)
+
+	client, err := net.DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer client.Close()
+	client.Write([]byte{0})
+	_, session, err := dns.ReadFromSessionUDP(server, make([]byte, 512))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Answer = []dns.RR{test.A("example.org. 3600 IN A 127.0.0.1")}
+
+	b.Run("WriteToSessionUDP", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf, _ := m.Pack()
+			dns.WriteToSessionUDP(server, buf, session)
+		}
+	})
+	b.Run("udpWriter", func(b *testing.B) {
+		w := pooledWriter{udpWriter{&remoteWriter{raddr: session.RemoteAddr()}, server}}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w.WriteMsg(m)
+		}
+	})
+}
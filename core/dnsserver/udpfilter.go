@@ -0,0 +1,28 @@
+package dnsserver
+
+import "net"
+
+// UDPFilter is a filter on the UDP socket of a server, that drops the packets in the kernel before
+// they reach the server: the ones that are not DNS queries, and the ones from denied networks. See
+// the sockfilter plugin.
+type UDPFilter struct {
+	// Deny holds the networks whose packets are dropped.
+	Deny []*net.IPNet
+}
+
+// The reasons a packet is dropped by an UDPFilter, see UDPFilterDrops.
+const (
+	DropMalformed = "malformed" // the packet is too short for a DNS header, or it isn't a query
+	DropDenied    = "denied"    // the packet comes from a denied network
+)
+
+// merge returns the filter applying both f and g, either may be nil.
+func (f *UDPFilter) merge(g *UDPFilter) *UDPFilter {
+	if f == nil {
+		return g
+	}
+	if g == nil {
+		return f
+	}
+	return &UDPFilter{Deny: append(append([]*net.IPNet{}, f.Deny...), g.Deny...)}
+}
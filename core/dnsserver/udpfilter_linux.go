@@ -0,0 +1,201 @@
+// +build linux
+
+package dnsserver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The instructions of the socket filter, besides the ones in bpf_linux.go.
+const (
+	bpfLdAbsB   = 0x30 // r0 = *(u8 *)(skb->data + imm)
+	bpfLdAbsH   = 0x28 // r0 = ntohs(*(u16 *)(skb->data + imm))
+	bpfLdAbsW   = 0x20 // r0 = ntohl(*(u32 *)(skb->data + imm))
+	bpfAnd32Imm = 0x54 // u32(dst) &= imm
+	bpfJltImm   = 0xa5 // if dst < imm goto pc + off
+	bpfJgtImm   = 0x25 // if dst > imm goto pc + off
+	bpfJeq32    = 0x16 // if u32(dst) == u32(imm) goto pc + off
+	bpfStW      = 0x62 // *(u32 *)(dst + off) = imm
+	bpfXaddDW   = 0xdb // lock *(u64 *)(dst + off) += src
+
+	skfNetOff   = -0x100000 // SKF_NET_OFF, loads relative to the network header
+	soAttachBPF = 50        // SO_ATTACH_BPF
+)
+
+// The indexes of the counters in the map of a socket filter.
+var udpFilterReasons = []string{DropMalformed, DropDenied}
+
+// udpFilterCounters is the map counting the packets dropped by the filter of an UDP socket. It is
+// kept while a server of the address uses it, so the counters survive a restart.
+type udpFilterCounters struct {
+	m    int
+	refs int
+}
+
+var (
+	udpFiltersMu sync.Mutex
+	udpFilters   = map[string]*udpFilterCounters{} // keyed by the address of the server
+)
+
+// attachUDPFilter attaches f to the UDP socket u of the server with the address addr, and returns
+// the function to call when the server stops. When f is nil, it detaches the filter the socket may
+// have from before a restart.
+func attachUDPFilter(u *net.UDPConn, addr string, f *UDPFilter) (func(), error) {
+	rc, err := u.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		rc.Control(func(fd uintptr) { unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_DETACH_FILTER, 0) })
+		return nil, nil
+	}
+
+	udpFiltersMu.Lock()
+	defer udpFiltersMu.Unlock()
+	c, ok := udpFilters[addr]
+	if !ok {
+		m, err := newBPFMap(bpfMapTypeArray, 4, 8, uint32(len(udpFilterReasons)))
+		if err != nil {
+			return nil, err
+		}
+		c = &udpFilterCounters{m: m}
+	}
+	prog, err := loadBPFProgram(bpfProgTypeSocketFilter, "coredns_udp", udpFilterProgram(c.m, f))
+	if err != nil {
+		if !ok {
+			unix.Close(c.m)
+		}
+		return nil, err
+	}
+	defer unix.Close(prog) // the socket holds on to it
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, soAttachBPF, prog)
+	}); err != nil {
+		serr = err
+	}
+	if serr != nil {
+		if !ok {
+			unix.Close(c.m)
+		}
+		return nil, serr
+	}
+
+	udpFilters[addr] = c
+	c.refs++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			udpFiltersMu.Lock()
+			defer udpFiltersMu.Unlock()
+			c.refs--
+			if c.refs == 0 {
+				delete(udpFilters, addr)
+				unix.Close(c.m)
+			}
+		})
+	}, nil
+}
+
+// UDPFilterDrops calls f with the number of packets dropped for each reason by the filters of the
+// UDP sockets of the servers.
+func UDPFilterDrops(f func(server, reason string, n uint64)) {
+	udpFiltersMu.Lock()
+	defer udpFiltersMu.Unlock()
+	for addr, c := range udpFilters {
+		for i, reason := range udpFilterReasons {
+			key, n := uint32(i), uint64(0)
+			if err := bpfMapElem(bpfMapLookupElem, c.m, unsafe.Pointer(&key), unsafe.Pointer(&n)); err == nil {
+				f(addr, reason, n)
+			}
+		}
+	}
+}
+
+// udpFilterProgram returns the socket filter of f, that counts the packets dropped in the map m.
+// The packet starts with the UDP header when the filter runs.
+func udpFilterProgram(m int, f *UDPFilter) []bpfInsn {
+	const header = 8 + 12 // UDP and DNS headers
+
+	a := &bpfAsm{}
+	a.op(bpfMovReg, bpfR6, bpfR1, 0, 0) // the loads from the packet use the context in r6
+	a.op(bpfLdxW, bpfR0, bpfR6, 0, 0)   // __sk_buff.len
+	a.jump(bpfJltImm, bpfR0, 0, header, "malformed")
+	a.op(bpfLdAbsB, 0, 0, 0, 8+2) // QR, opcode, AA, TC and RD
+	a.op(bpfAndImm, bpfR0, 0, 0, 0x80)
+	a.jump(bpfJneImm, bpfR0, 0, 0, "malformed")
+	a.op(bpfLdAbsH, 0, 0, 0, 8+4) // QDCOUNT
+	a.jump(bpfJgtImm, bpfR0, 0, 1, "malformed")
+
+	var v4, v6 []*net.IPNet
+	for _, n := range f.Deny {
+		if n.IP.To4() != nil {
+			v4 = append(v4, n)
+		} else {
+			v6 = append(v6, n)
+		}
+	}
+	if len(v4)+len(v6) > 0 {
+		a.op(bpfLdxW, bpfR7, bpfR6, 16, 0) // __sk_buff.protocol
+		a.jump(bpfJeqImm, bpfR7, 0, hostU16(0x0800), "ipv4")
+		a.jump(bpfJeqImm, bpfR7, 0, hostU16(0x86dd), "ipv6")
+		a.jump(bpfJa, 0, 0, 0, "accept")
+
+		a.label("ipv4")
+		if len(v4) > 0 {
+			a.op(bpfLdAbsW, 0, 0, 0, skfNetOff+12) // source address
+			for _, n := range v4 {
+				a.op(bpfMovReg, bpfR1, bpfR0, 0, 0)
+				a.op(bpfAnd32Imm, bpfR1, 0, 0, int32(binary.BigEndian.Uint32(n.Mask[len(n.Mask)-4:])))
+				a.jump(bpfJeq32, bpfR1, 0, int32(binary.BigEndian.Uint32(n.IP.To4())), "denied")
+			}
+		}
+		a.jump(bpfJa, 0, 0, 0, "accept")
+
+		a.label("ipv6")
+		for i, n := range v6 {
+			next := fmt.Sprintf("ipv6-%d", i)
+			for w := 0; w < 16; w += 4 {
+				mask := binary.BigEndian.Uint32(n.Mask[w:])
+				if mask == 0 {
+					break
+				}
+				a.op(bpfLdAbsW, 0, 0, 0, int32(skfNetOff+8+w)) // source address
+				a.op(bpfAnd32Imm, bpfR0, 0, 0, int32(mask))
+				a.jump(bpfJne32, bpfR0, 0, int32(binary.BigEndian.Uint32(n.IP[w:])&mask), next)
+			}
+			a.jump(bpfJa, 0, 0, 0, "denied")
+			a.label(next)
+		}
+	}
+
+	a.label("accept")
+	a.op(bpfMovImm, bpfR0, 0, 0, -1) // keep the whole packet
+	a.op(bpfExit, 0, 0, 0, 0)
+
+	a.label("malformed")
+	a.op(bpfStW, bpfR10, 0, -4, 0)
+	a.jump(bpfJa, 0, 0, 0, "count")
+	a.label("denied")
+	a.op(bpfStW, bpfR10, 0, -4, 1)
+
+	a.label("count")
+	a.op(bpfLdImm64, bpfR1, bpfPseudoMapFD, 0, int32(m))
+	a.op(0, 0, 0, 0, 0)
+	a.op(bpfMovReg, bpfR2, bpfR10, 0, 0)
+	a.op(bpfAddImm, bpfR2, 0, 0, -4)
+	a.op(bpfCall, 0, 0, 0, bpfFuncMapLookupElem)
+	a.jump(bpfJeqImm, bpfR0, 0, 0, "drop")
+	a.op(bpfMovImm, bpfR1, 0, 0, 1)
+	a.op(bpfXaddDW, bpfR0, bpfR1, 0, 0)
+	a.label("drop")
+	a.op(bpfMovImm, bpfR0, 0, 0, 0)
+	a.op(bpfExit, 0, 0, 0, 0)
+	return a.program()
+}
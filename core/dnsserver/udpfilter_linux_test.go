@@ -0,0 +1,117 @@
+// +build linux
+
+package dnsserver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestUDPFilter(t *testing.T) {
+	query := func(qdcount int, response bool) []byte {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		for i := 1; i < qdcount; i++ {
+			m.Question = append(m.Question, m.Question[0])
+		}
+		m.Response = response
+		b, _ := m.Pack()
+		return b
+	}
+
+	tests := []struct {
+		listen    string
+		deny      string
+		packets   [][]byte
+		received  int
+		malformed uint64
+		denied    uint64
+	}{
+		{"127.0.0.1:0", "192.0.2.0/24", [][]byte{query(1, false), []byte("short"), query(1, true), query(2, false), query(1, false)}, 2, 3, 0},
+		{"127.0.0.1:0", "127.0.0.0/8", [][]byte{query(1, false), []byte("short")}, 0, 1, 1},
+		{"[::1]:0", "2001:db8::/32", [][]byte{query(1, false)}, 1, 0, 0},
+		{"[::1]:0", "::1/128", [][]byte{query(1, false), query(1, false)}, 0, 0, 2},
+	}
+
+	for i, tc := range tests {
+		p, err := net.ListenPacket("udp", tc.listen)
+		if err != nil {
+			t.Logf("Test %d: skipped, %s", i, err)
+			continue
+		}
+		u := p.(*net.UDPConn)
+		_, deny, _ := net.ParseCIDR(tc.deny)
+		release, err := attachUDPFilter(u, "test", &UDPFilter{Deny: []*net.IPNet{deny}})
+		if err != nil {
+			p.Close()
+			t.Skipf("Socket filters are not available: %s", err)
+		}
+
+		c, err := net.Dial("udp", u.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range tc.packets {
+			c.Write(b)
+		}
+		received := 0
+		buf := make([]byte, 512)
+		for {
+			u.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			if _, err := u.Read(buf); err != nil {
+				break
+			}
+			received++
+		}
+		if received != tc.received {
+			t.Errorf("Test %d: expected %d packets received, got %d", i, tc.received, received)
+		}
+
+		drops := map[string]uint64{}
+		UDPFilterDrops(func(server, reason string, n uint64) {
+			if server == "test" {
+				drops[reason] = n
+			}
+		})
+		if drops[DropMalformed] != tc.malformed || drops[DropDenied] != tc.denied {
+			t.Errorf("Test %d: expected %d malformed and %d denied packets dropped, got %v", i, tc.malformed, tc.denied, drops)
+		}
+
+		c.Close()
+		release()
+		p.Close()
+	}
+}
+
+func TestUDPFilterDetach(t *testing.T) {
+	p, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	u := p.(*net.UDPConn)
+	_, deny, _ := net.ParseCIDR("127.0.0.0/8")
+	release, err := attachUDPFilter(u, "test", &UDPFilter{Deny: []*net.IPNet{deny}})
+	if err != nil {
+		t.Skipf("Socket filters are not available: %s", err)
+	}
+	release()
+
+	// The server after a restart has no filter.
+	if _, err := attachUDPFilter(u, "test", nil); err != nil {
+		t.Fatal(err)
+	}
+	c, err := net.Dial("udp", u.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Write([]byte("short"))
+	u.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := u.Read(make([]byte, 512)); err != nil {
+		t.Errorf("Expected the packet to be received, got %s", err)
+	}
+}
@@ -0,0 +1,20 @@
+// +build !linux
+
+package dnsserver
+
+import (
+	"errors"
+	"net"
+)
+
+// attachUDPFilter is only implemented on Linux.
+func attachUDPFilter(u *net.UDPConn, addr string, f *UDPFilter) (func(), error) {
+	if f == nil {
+		return nil, nil
+	}
+	return nil, errors.New("socket filters are only supported on Linux")
+}
+
+// UDPFilterDrops calls f with the number of packets dropped for each reason by the filters of the
+// UDP sockets of the servers.
+func UDPFilterDrops(f func(server, reason string, n uint64)) {}
@@ -0,0 +1,131 @@
+// +build linux
+
+package dnsserver
+
+import (
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// xdpPass is the XDP action passing a packet to the network stack.
+const xdpPass = 2
+
+// newXSKMap returns a BPF_MAP_TYPE_XSKMAP with n entries, mapping the receive queues of an interface
+// to the AF_XDP sockets.
+func newXSKMap(n int) (int, error) {
+	return newBPFMap(unix.BPF_MAP_TYPE_XSKMAP, 4, 4, uint32(n))
+}
+
+// updateXSKMap sets the socket of the receive queue queue to fd.
+func updateXSKMap(m, queue, fd int) error {
+	key, value := uint32(queue), uint32(fd)
+	return bpfMapElem(bpfMapUpdateElem, m, unsafe.Pointer(&key), unsafe.Pointer(&value))
+}
+
+// loadXDPProgram loads the XDP program insns, and returns it.
+func loadXDPProgram(insns []bpfInsn) (int, error) {
+	return loadBPFProgram(bpfProgTypeXDP, "coredns_xdp", insns)
+}
+
+// attachXDP attaches the XDP program prog to the interface ifindex, in the driver if it supports XDP,
+// otherwise in the generic mode. Closing the returned link detaches the program.
+func attachXDP(prog, ifindex int) (int, error) {
+	var err error
+	for _, mode := range []uint32{unix.XDP_FLAGS_DRV_MODE, unix.XDP_FLAGS_SKB_MODE} {
+		attr := struct {
+			progFd, targetIfindex, attachType, flags uint32
+		}{uint32(prog), uint32(ifindex), bpfXDP, mode}
+		var link int
+		if link, err = bpf(bpfLinkCreate, unsafe.Pointer(&attr), unsafe.Sizeof(attr)); err == nil {
+			return link, nil
+		}
+	}
+	return -1, err
+}
+
+// xdpProgram returns the XDP program that redirects the UDP packets to the address addr to the
+// AF_XDP socket of their receive queue in the XSKMAP m. The other packets, and the ones received on
+// a queue without socket, go to the network stack. When the IP of addr is unspecified, the packets to
+// any address, on the port of addr, are redirected.
+//
+// Only the packets with the usual headers are redirected: an Ethernet header without VLAN tag, an
+// IPv4 header without options, or an IPv6 header without extension headers, and an UDP header.
+// IPv4 fragments are left to the network stack.
+func xdpProgram(m int, addr *net.UDPAddr) []bpfInsn {
+	const (
+		eth  = 14
+		ipv4 = eth + 20
+		ipv6 = eth + 40
+		udp  = 8
+	)
+	a := &bpfAsm{}
+	a.op(bpfMovReg, bpfR6, bpfR1, 0, 0)
+	a.op(bpfLdxW, bpfR2, bpfR1, 0, 0) // xdp_md.data
+	a.op(bpfLdxW, bpfR3, bpfR1, 4, 0) // xdp_md.data_end
+	a.op(bpfMovReg, bpfR4, bpfR2, 0, 0)
+	a.op(bpfAddImm, bpfR4, 0, 0, eth)
+	a.jump(bpfJgtReg, bpfR4, bpfR3, 0, "pass")
+	a.op(bpfLdxH, bpfR5, bpfR2, 12, 0) // EtherType
+	ip4, ip6 := addr.IP.To4(), addr.IP.To16()
+	unspecified := addr.IP == nil || addr.IP.IsUnspecified()
+	if unspecified || ip4 != nil {
+		a.jump(bpfJeqImm, bpfR5, 0, hostU16(0x0800), "ipv4")
+	}
+	if unspecified || ip4 == nil {
+		a.jump(bpfJeqImm, bpfR5, 0, hostU16(0x86dd), "ipv6")
+	}
+	a.jump(bpfJa, 0, 0, 0, "pass")
+
+	if unspecified || ip4 != nil {
+		a.label("ipv4")
+		a.op(bpfMovReg, bpfR4, bpfR2, 0, 0)
+		a.op(bpfAddImm, bpfR4, 0, 0, ipv4+udp)
+		a.jump(bpfJgtReg, bpfR4, bpfR3, 0, "pass")
+		a.op(bpfLdxB, bpfR5, bpfR2, eth, 0) // version and header length
+		a.jump(bpfJneImm, bpfR5, 0, 0x45, "pass")
+		a.op(bpfLdxB, bpfR5, bpfR2, eth+9, 0) // protocol
+		a.jump(bpfJneImm, bpfR5, 0, unix.IPPROTO_UDP, "pass")
+		a.op(bpfLdxH, bpfR5, bpfR2, eth+6, 0) // flags and fragment offset
+		a.op(bpfAndImm, bpfR5, 0, 0, hostU16(0x3fff))
+		a.jump(bpfJneImm, bpfR5, 0, 0, "pass")
+		if !unspecified {
+			a.op(bpfLdxW, bpfR5, bpfR2, eth+16, 0) // destination address
+			a.jump(bpfJne32, bpfR5, 0, hostU32(ip4), "pass")
+		}
+		a.op(bpfLdxH, bpfR5, bpfR2, ipv4+2, 0) // destination port
+		a.jump(bpfJneImm, bpfR5, 0, hostU16(uint16(addr.Port)), "pass")
+		a.jump(bpfJa, 0, 0, 0, "redirect")
+	}
+
+	if unspecified || ip4 == nil {
+		a.label("ipv6")
+		a.op(bpfMovReg, bpfR4, bpfR2, 0, 0)
+		a.op(bpfAddImm, bpfR4, 0, 0, ipv6+udp)
+		a.jump(bpfJgtReg, bpfR4, bpfR3, 0, "pass")
+		a.op(bpfLdxB, bpfR5, bpfR2, eth+6, 0) // next header
+		a.jump(bpfJneImm, bpfR5, 0, unix.IPPROTO_UDP, "pass")
+		if !unspecified {
+			for i := 0; i < 16; i += 4 {
+				a.op(bpfLdxW, bpfR5, bpfR2, int16(eth+24+i), 0) // destination address
+				a.jump(bpfJne32, bpfR5, 0, hostU32(ip6[i:]), "pass")
+			}
+		}
+		a.op(bpfLdxH, bpfR5, bpfR2, ipv6+2, 0) // destination port
+		a.jump(bpfJneImm, bpfR5, 0, hostU16(uint16(addr.Port)), "pass")
+	}
+
+	a.label("redirect")
+	a.op(bpfLdxW, bpfR2, bpfR6, 16, 0) // xdp_md.rx_queue_index
+	a.op(bpfLdImm64, bpfR1, bpfPseudoMapFD, 0, int32(m))
+	a.op(0, 0, 0, 0, 0)
+	a.op(bpfMovImm, bpfR3, 0, 0, xdpPass) // when the queue has no socket
+	a.op(bpfCall, 0, 0, 0, bpfFuncRedirectMap)
+	a.op(bpfExit, 0, 0, 0, 0)
+
+	a.label("pass")
+	a.op(bpfMovImm, bpfR0, 0, 0, xdpPass)
+	a.op(bpfExit, 0, 0, 0, 0)
+	return a.program()
+}
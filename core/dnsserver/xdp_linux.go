@@ -0,0 +1,588 @@
+// +build linux
+
+package dnsserver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/miekg/dns"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	xdpFrameSize = 4096            // the size of a frame of the UMEM, a packet
+	xdpRingSize  = 2048            // the number of entries of the rings of a socket
+	xdpFrames    = 2 * xdpRingSize // the number of frames of the UMEM of a socket
+
+	xdpEthLen = 14
+	xdpIPv4   = xdpEthLen + 20 // the length of the headers up to UDP
+	xdpIPv6   = xdpEthLen + 40
+	xdpUDPLen = 8
+)
+
+// xdpConn serves the UDP queries to an address through AF_XDP sockets, one for every receive queue of
+// a network interface. An XDP program redirects the queries to the sockets before the kernel
+// allocates anything for them, and the responses are written to the network interface directly, so
+// the queries skip the kernel network stack. The queries are served by the plugins as usual; with
+// the cache plugin the cache hits don't go through the kernel at all.
+//
+// xdpConn only handles Ethernet frames with the usual headers, the packets it doesn't handle go to
+// the network stack and are served by the UDP socket of the server.
+type xdpConn struct {
+	key    string
+	addr   *net.UDPAddr
+	mtu    int
+	server atomic.Value // *Server serving the queries
+	refs   int          // the servers using the xdpConn, protected by xdpConnsMu
+
+	xskMap int
+	prog   int
+	link   int
+	socks  []*xsk
+	closed int32 // 1 when shutting down, accessed atomically
+	wg     sync.WaitGroup
+}
+
+// The xdpConns in use, keyed by interface and address. An XDP program is attached to an interface
+// until it is detached, and only one can be: when the servers are restarted, the new server takes
+// over the xdpConn of the old one.
+var (
+	xdpConnsMu sync.Mutex
+	xdpConns   = map[string]*xdpConn{}
+)
+
+// acquireXDPConn returns the xdpConn serving the queries to addr on the interface iface with s.
+func acquireXDPConn(s *Server, iface string, addr *net.UDPAddr) (*xdpConn, error) {
+	xdpConnsMu.Lock()
+	defer xdpConnsMu.Unlock()
+
+	key := iface + "/" + addr.String()
+	x, ok := xdpConns[key]
+	if !ok {
+		var err error
+		if x, err = newXDPConn(iface, addr); err != nil {
+			return nil, err
+		}
+		x.key = key
+		xdpConns[key] = x
+		x.server.Store(s)
+		x.start()
+	}
+	x.server.Store(s) // a restarted server takes over
+	x.refs++
+	return x, nil
+}
+
+// release is called when a server stops using x. The xdpConn shuts down when no server uses it
+// anymore.
+func (x *xdpConn) release() error {
+	xdpConnsMu.Lock()
+	x.refs--
+	last := x.refs == 0
+	if last {
+		delete(xdpConns, x.key)
+	}
+	xdpConnsMu.Unlock()
+	if !last {
+		return nil
+	}
+	return x.shutdown()
+}
+
+// newXDPConn attaches the XDP program to the interface iface, and opens the AF_XDP sockets.
+func newXDPConn(iface string, addr *net.UDPAddr) (x *xdpConn, err error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+	queues, err := rxQueues(iface)
+	if err != nil {
+		return nil, err
+	}
+
+	x = &xdpConn{addr: addr, mtu: ifi.MTU, xskMap: -1, prog: -1, link: -1}
+	defer func() {
+		if err != nil {
+			x.close()
+		}
+	}()
+	if x.xskMap, err = newXSKMap(queues); err != nil {
+		return nil, fmt.Errorf("creating the XSKMAP: %s", err)
+	}
+	for q := 0; q < queues; q++ {
+		sk, err := newXSK(ifi.Index, q)
+		if err != nil {
+			return nil, fmt.Errorf("opening the AF_XDP socket of queue %d: %s", q, err)
+		}
+		x.socks = append(x.socks, sk)
+		if err := updateXSKMap(x.xskMap, q, sk.fd); err != nil {
+			return nil, fmt.Errorf("adding the AF_XDP socket of queue %d: %s", q, err)
+		}
+	}
+	if x.prog, err = loadXDPProgram(xdpProgram(x.xskMap, addr)); err != nil {
+		return nil, fmt.Errorf("loading the XDP program: %s", err)
+	}
+	if x.link, err = attachXDP(x.prog, ifi.Index); err != nil {
+		return nil, fmt.Errorf("attaching the XDP program to %s: %s", iface, err)
+	}
+	return x, nil
+}
+
+// rxQueues returns the number of receive queues of the interface iface.
+func rxQueues(iface string) (int, error) {
+	fis, err := ioutil.ReadDir("/sys/class/net/" + iface + "/queues")
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), "rx-") {
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("no receive queues on %s", iface)
+	}
+	return n, nil
+}
+
+// start starts serving the queries received on the sockets.
+func (x *xdpConn) start() {
+	for _, sk := range x.socks {
+		x.wg.Add(1)
+		go x.serve(sk)
+	}
+}
+
+// shutdown detaches the XDP program, waits for the queries being handled and closes the sockets.
+func (x *xdpConn) shutdown() error {
+	atomic.StoreInt32(&x.closed, 1)
+	if x.link >= 0 {
+		unix.Close(x.link)
+		x.link = -1
+	}
+	x.wg.Wait()
+	x.close()
+	return nil
+}
+
+func (x *xdpConn) close() {
+	for _, fd := range []int{x.link, x.prog, x.xskMap} {
+		if fd >= 0 {
+			unix.Close(fd)
+		}
+	}
+	for _, sk := range x.socks {
+		sk.close()
+	}
+}
+
+// serve reads the queries received on sk and serves them, until x is shut down.
+func (x *xdpConn) serve(sk *xsk) {
+	defer x.wg.Done()
+	fds := []unix.PollFd{{Fd: int32(sk.fd), Events: unix.POLLIN}}
+	for atomic.LoadInt32(&x.closed) == 0 {
+		if _, err := unix.Poll(fds, 100); err != nil && err != unix.EINTR {
+			log.Errorf("Failed to poll the AF_XDP socket of %s: %s", x.addr, err)
+			return
+		}
+		sk.receive(func(frame []byte) {
+			p, ok := parseXDPFrame(frame)
+			if !ok {
+				return
+			}
+			// The frame goes back to the kernel, copy the query.
+			r := new(dns.Msg)
+			if err := r.Unpack(append([]byte(nil), p.payload...)); err != nil || r.Response {
+				return
+			}
+			p.payload = nil
+			s := x.server.Load().(*Server)
+			w := &xdpWriter{x: x, sk: sk, p: p}
+			x.wg.Add(1)
+			go func() {
+				defer x.wg.Done()
+				s.serveUDP(context.WithValue(context.Background(), Key{}, s), w, r)
+			}()
+		})
+	}
+}
+
+// xdpFrame is the headers of a query received in an Ethernet frame, and its payload.
+type xdpFrame struct {
+	hdr     [xdpIPv6 + xdpUDPLen]byte
+	hdrLen  int
+	payload []byte
+}
+
+func (p *xdpFrame) v6() bool { return p.hdrLen == xdpIPv6+xdpUDPLen }
+
+// parseXDPFrame parses the frame b redirected by the XDP program, see xdpProgram.
+func parseXDPFrame(b []byte) (xdpFrame, bool) {
+	var p xdpFrame
+	if len(b) < xdpEthLen {
+		return p, false
+	}
+	var udp []byte
+	switch binary.BigEndian.Uint16(b[12:]) {
+	case 0x0800:
+		if len(b) < xdpIPv4+xdpUDPLen || b[xdpEthLen] != 0x45 {
+			return p, false
+		}
+		total := int(binary.BigEndian.Uint16(b[xdpEthLen+2:]))
+		if total < 20+xdpUDPLen || xdpEthLen+total > len(b) {
+			return p, false
+		}
+		b = b[:xdpEthLen+total] // without the Ethernet padding
+		udp = b[xdpIPv4:]
+	case 0x86dd:
+		if len(b) < xdpIPv6+xdpUDPLen {
+			return p, false
+		}
+		udp = b[xdpIPv6:]
+	default:
+		return p, false
+	}
+	l := int(binary.BigEndian.Uint16(udp[4:]))
+	if l < xdpUDPLen || l > len(udp) {
+		return p, false
+	}
+	p.hdrLen = copy(p.hdr[:], b[:len(b)-len(udp)+xdpUDPLen])
+	p.payload = udp[xdpUDPLen:l]
+	return p, true
+}
+
+// reply writes the frame of the response with the payload payload to the query p in b, and returns
+// its length.
+func (p *xdpFrame) reply(b, payload []byte) int {
+	h := b[:p.hdrLen]
+	copy(h, p.hdr[:p.hdrLen])
+	// Swap the addresses.
+	copy(h[0:6], p.hdr[6:12])
+	copy(h[6:12], p.hdr[0:6])
+	udp := h[p.hdrLen-xdpUDPLen:]
+	copy(udp[0:2], p.hdr[p.hdrLen-xdpUDPLen+2:])
+	copy(udp[2:4], p.hdr[p.hdrLen-xdpUDPLen:])
+	binary.BigEndian.PutUint16(udp[4:], uint16(xdpUDPLen+len(payload)))
+	udp[6], udp[7] = 0, 0
+	n := p.hdrLen + copy(b[p.hdrLen:], payload)
+
+	var sum uint32
+	if p.v6() {
+		ip := h[xdpEthLen:]
+		copy(ip[8:24], p.hdr[xdpEthLen+24:xdpEthLen+40])
+		copy(ip[24:40], p.hdr[xdpEthLen+8:xdpEthLen+24])
+		binary.BigEndian.PutUint16(ip[4:], uint16(xdpUDPLen+len(payload)))
+		ip[7] = 64 // hop limit
+		sum = checksum(ip[8:40], 0)
+	} else {
+		ip := h[xdpEthLen:]
+		copy(ip[12:16], p.hdr[xdpEthLen+16:xdpEthLen+20])
+		copy(ip[16:20], p.hdr[xdpEthLen+12:xdpEthLen+16])
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+xdpUDPLen+len(payload)))
+		ip[4], ip[5], ip[6], ip[7] = 0, 0, 0x40, 0 // no ID, don't fragment
+		ip[8] = 64                                 // TTL
+		ip[10], ip[11] = 0, 0
+		binary.BigEndian.PutUint16(ip[10:], ^fold(checksum(ip[:20], 0)))
+		sum = checksum(ip[12:20], 0)
+	}
+	// The pseudo header has the addresses, the protocol and the UDP length.
+	sum += unix.IPPROTO_UDP + uint32(xdpUDPLen+len(payload))
+	sum = checksum(b[p.hdrLen-xdpUDPLen:n], sum)
+	c := ^fold(sum)
+	if c == 0 {
+		c = 0xffff
+	}
+	binary.BigEndian.PutUint16(udp[6:], c)
+	return n
+}
+
+// checksum adds the 16 bit words of b to sum.
+func checksum(b []byte, sum uint32) uint32 {
+	for len(b) >= 2 {
+		sum += uint32(b[0])<<8 | uint32(b[1])
+		b = b[2:]
+	}
+	if len(b) == 1 {
+		sum += uint32(b[0]) << 8
+	}
+	return sum
+}
+
+func fold(sum uint32) uint16 {
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return uint16(sum)
+}
+
+// addrs returns the local and the remote address of the query p.
+func (p *xdpFrame) addrs() (local, remote *net.UDPAddr) {
+	udp := p.hdr[p.hdrLen-xdpUDPLen:]
+	local = &net.UDPAddr{Port: int(binary.BigEndian.Uint16(udp[2:]))}
+	remote = &net.UDPAddr{Port: int(binary.BigEndian.Uint16(udp[0:]))}
+	if p.v6() {
+		local.IP = net.IP(append([]byte(nil), p.hdr[xdpEthLen+24:xdpEthLen+40]...))
+		remote.IP = net.IP(append([]byte(nil), p.hdr[xdpEthLen+8:xdpEthLen+24]...))
+	} else {
+		local.IP = net.IPv4(p.hdr[xdpEthLen+16], p.hdr[xdpEthLen+17], p.hdr[xdpEthLen+18], p.hdr[xdpEthLen+19])
+		remote.IP = net.IPv4(p.hdr[xdpEthLen+12], p.hdr[xdpEthLen+13], p.hdr[xdpEthLen+14], p.hdr[xdpEthLen+15])
+	}
+	return local, remote
+}
+
+// xdpWriter is the dns.ResponseWriter for a query read from an AF_XDP socket.
+type xdpWriter struct {
+	x  *xdpConn
+	sk *xsk
+	p  xdpFrame
+}
+
+// LocalAddr implements the dns.ResponseWriter interface.
+func (w *xdpWriter) LocalAddr() net.Addr { l, _ := w.p.addrs(); return l }
+
+// RemoteAddr implements the dns.ResponseWriter interface.
+func (w *xdpWriter) RemoteAddr() net.Addr { _, r := w.p.addrs(); return r }
+
+// maxSize returns the size of the largest response that fits in the MTU, there is no fragmentation.
+func (w *xdpWriter) maxSize() int {
+	max := w.x.mtu - (w.p.hdrLen - xdpEthLen)
+	if l := xdpFrameSize - w.p.hdrLen; max > l {
+		max = l
+	}
+	return max
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *xdpWriter) WriteMsg(m *dns.Msg) error {
+	if max := w.maxSize(); m.Len() > max {
+		m.Truncate(max)
+	}
+	b, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *xdpWriter) Write(b []byte) (int, error) {
+	if len(b) > w.maxSize() {
+		m := new(dns.Msg)
+		if err := m.Unpack(b); err != nil {
+			return 0, err
+		}
+		return len(b), w.WriteMsg(m)
+	}
+	if err := w.sk.transmit(func(frame []byte) int { return w.p.reply(frame, b) }); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close implements the dns.ResponseWriter interface.
+func (w *xdpWriter) Close() error { return nil }
+
+// TsigStatus implements the dns.ResponseWriter interface. The TSIG of the queries is not verified.
+func (w *xdpWriter) TsigStatus() error { return dns.ErrSecret }
+
+// TsigTimersOnly implements the dns.ResponseWriter interface.
+func (w *xdpWriter) TsigTimersOnly(bool) {}
+
+// Hijack implements the dns.ResponseWriter interface.
+func (w *xdpWriter) Hijack() {}
+
+// xsk is an AF_XDP socket bound to a receive queue, with its UMEM: the memory holding the frames
+// shared with the kernel. The frames are passed with four rings: the fill ring gives the kernel the
+// frames to receive in, the rx ring returns them with the packets received, the tx ring gives the
+// kernel the frames to send, and the completion ring returns them once sent. The first half of the
+// frames is used to receive, the other half to send.
+type xsk struct {
+	fd   int
+	umem []byte
+	fill, comp, rx, tx xdpRing
+
+	txMu sync.Mutex
+	free []uint64 // the frames to send in
+}
+
+// xdpRing is a ring shared with the kernel, the producer and consumer indexes are accessed
+// atomically.
+type xdpRing struct {
+	mem      []byte
+	producer *uint32
+	consumer *uint32
+	descs    unsafe.Pointer
+	mask     uint32
+}
+
+// xdpDesc is an entry of the rx and tx rings, struct xdp_desc.
+type xdpDesc struct {
+	addr    uint64
+	len     uint32
+	options uint32
+}
+
+var errXDPBusy = errors.New("no room to send on the AF_XDP socket")
+
+func newXSK(ifindex, queue int) (sk *xsk, err error) {
+	fd, err := unix.Socket(unix.AF_XDP, unix.SOCK_RAW, 0)
+	if err != nil {
+		return nil, err
+	}
+	sk = &xsk{fd: fd}
+	defer func() {
+		if err != nil {
+			sk.close()
+		}
+	}()
+
+	sk.umem, err = unix.Mmap(-1, 0, xdpFrames*xdpFrameSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return nil, err
+	}
+	reg := struct {
+		addr, len                 uint64
+		chunkSize, headroom, flag uint32
+	}{uint64(uintptr(unsafe.Pointer(&sk.umem[0]))), uint64(len(sk.umem)), xdpFrameSize, 0, 0}
+	if err := setsockopt(fd, unix.XDP_UMEM_REG, unsafe.Pointer(&reg), unsafe.Sizeof(reg)); err != nil {
+		return nil, err
+	}
+	for _, opt := range []int{unix.XDP_UMEM_FILL_RING, unix.XDP_UMEM_COMPLETION_RING, unix.XDP_RX_RING, unix.XDP_TX_RING} {
+		if err := unix.SetsockoptInt(fd, unix.SOL_XDP, opt, xdpRingSize); err != nil {
+			return nil, err
+		}
+	}
+
+	// struct xdp_mmap_offsets: the offsets of producer, consumer, desc and flags of each ring.
+	var offs [4][4]uint64
+	size := uint32(unsafe.Sizeof(offs))
+	if _, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, uintptr(fd), unix.SOL_XDP, unix.XDP_MMAP_OFFSETS, uintptr(unsafe.Pointer(&offs)), uintptr(unsafe.Pointer(&size)), 0); errno != 0 {
+		return nil, errno
+	}
+	if size != uint32(unsafe.Sizeof(offs)) {
+		return nil, fmt.Errorf("unsupported kernel, AF_XDP ring offsets of %d bytes", size)
+	}
+	descSize := [4]uintptr{unsafe.Sizeof(xdpDesc{}), unsafe.Sizeof(xdpDesc{}), 8, 8}
+	pgoff := [4]int64{unix.XDP_PGOFF_RX_RING, unix.XDP_PGOFF_TX_RING, unix.XDP_UMEM_PGOFF_FILL_RING, unix.XDP_UMEM_PGOFF_COMPLETION_RING}
+	rings := [4]*xdpRing{&sk.rx, &sk.tx, &sk.fill, &sk.comp}
+	for i, r := range rings {
+		mem, err := unix.Mmap(fd, pgoff[i], int(offs[i][2]+uint64(xdpRingSize*descSize[i])), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+		if err != nil {
+			return nil, err
+		}
+		*r = xdpRing{
+			mem:      mem,
+			producer: (*uint32)(unsafe.Pointer(&mem[offs[i][0]])),
+			consumer: (*uint32)(unsafe.Pointer(&mem[offs[i][1]])),
+			descs:    unsafe.Pointer(&mem[offs[i][2]]),
+			mask:     xdpRingSize - 1,
+		}
+	}
+
+	// Give the kernel the first half of the frames to receive in.
+	for i := 0; i < xdpRingSize; i++ {
+		*sk.fill.addr(uint32(i)) = uint64(i * xdpFrameSize)
+	}
+	atomic.StoreUint32(sk.fill.producer, xdpRingSize)
+	for i := xdpRingSize; i < xdpFrames; i++ {
+		sk.free = append(sk.free, uint64(i*xdpFrameSize))
+	}
+
+	if err := unix.Bind(fd, &unix.SockaddrXDP{Ifindex: uint32(ifindex), QueueID: uint32(queue)}); err != nil {
+		return nil, err
+	}
+	return sk, nil
+}
+
+func setsockopt(fd, opt int, v unsafe.Pointer, size uintptr) error {
+	if _, _, errno := unix.Syscall6(unix.SYS_SETSOCKOPT, uintptr(fd), unix.SOL_XDP, uintptr(opt), uintptr(v), size, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (sk *xsk) close() {
+	for _, r := range []*xdpRing{&sk.rx, &sk.tx, &sk.fill, &sk.comp} {
+		if r.mem != nil {
+			unix.Munmap(r.mem)
+		}
+	}
+	unix.Close(sk.fd)
+	if sk.umem != nil {
+		unix.Munmap(sk.umem)
+	}
+}
+
+// addr returns the entry i of the fill or the completion ring.
+func (r *xdpRing) addr(i uint32) *uint64 {
+	return (*uint64)(unsafe.Pointer(uintptr(r.descs) + uintptr(i&r.mask)*8))
+}
+
+// desc returns the entry i of the rx or the tx ring.
+func (r *xdpRing) desc(i uint32) *xdpDesc {
+	return (*xdpDesc)(unsafe.Pointer(uintptr(r.descs) + uintptr(i&r.mask)*unsafe.Sizeof(xdpDesc{})))
+}
+
+// receive calls f with the frames received, and then gives the frames back to the kernel. f must
+// not keep the frame.
+func (sk *xsk) receive(f func(frame []byte)) {
+	cons := atomic.LoadUint32(sk.rx.consumer)
+	prod := atomic.LoadUint32(sk.rx.producer)
+	if cons == prod {
+		return
+	}
+	fill := atomic.LoadUint32(sk.fill.producer)
+	for ; cons != prod; cons++ {
+		d := sk.rx.desc(cons)
+		f(sk.umem[d.addr : d.addr+uint64(d.len)])
+		*sk.fill.addr(fill) = d.addr &^ (xdpFrameSize - 1)
+		fill++
+	}
+	atomic.StoreUint32(sk.rx.consumer, cons)
+	atomic.StoreUint32(sk.fill.producer, fill)
+}
+
+// transmit sends the frame written by f in a free frame, f returns its length.
+func (sk *xsk) transmit(f func(frame []byte) int) error {
+	sk.txMu.Lock()
+	defer sk.txMu.Unlock()
+
+	// Take back the frames sent.
+	cons := atomic.LoadUint32(sk.comp.consumer)
+	prod := atomic.LoadUint32(sk.comp.producer)
+	for ; cons != prod; cons++ {
+		sk.free = append(sk.free, *sk.comp.addr(cons))
+	}
+	atomic.StoreUint32(sk.comp.consumer, cons)
+
+	txProd := atomic.LoadUint32(sk.tx.producer)
+	if len(sk.free) == 0 || txProd-atomic.LoadUint32(sk.tx.consumer) == xdpRingSize {
+		return errXDPBusy
+	}
+	addr := sk.free[len(sk.free)-1]
+	sk.free = sk.free[:len(sk.free)-1]
+	n := f(sk.umem[addr : addr+xdpFrameSize])
+	*sk.tx.desc(txProd) = xdpDesc{addr: addr, len: uint32(n)}
+	atomic.StoreUint32(sk.tx.producer, txProd+1)
+
+	// Have the kernel send it.
+	_, _, errno := unix.Syscall6(unix.SYS_SENDTO, uintptr(sk.fd), 0, 0, unix.MSG_DONTWAIT, 0, 0)
+	switch errno {
+	case 0, unix.EAGAIN, unix.EBUSY, unix.ENOBUFS:
+		return nil
+	}
+	return errno
+}
@@ -0,0 +1,185 @@
+// +build linux
+
+package dnsserver
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/sys/unix"
+)
+
+// xdpQuery returns the Ethernet frame of an UDP packet with the payload payload from src to dst.
+func xdpQuery(src, dst *net.UDPAddr, payload []byte) []byte {
+	b := make([]byte, xdpEthLen, 128)
+	copy(b, []byte{1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 2})
+	udp := make([]byte, xdpUDPLen)
+	binary.BigEndian.PutUint16(udp, uint16(src.Port))
+	binary.BigEndian.PutUint16(udp[2:], uint16(dst.Port))
+	binary.BigEndian.PutUint16(udp[4:], uint16(xdpUDPLen+len(payload)))
+	if src.IP.To4() != nil {
+		binary.BigEndian.PutUint16(b[12:], 0x0800)
+		ip := make([]byte, 20)
+		ip[0], ip[8], ip[9] = 0x45, 64, 17
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+xdpUDPLen+len(payload)))
+		copy(ip[12:], src.IP.To4())
+		copy(ip[16:], dst.IP.To4())
+		b = append(b, ip...)
+	} else {
+		binary.BigEndian.PutUint16(b[12:], 0x86dd)
+		ip := make([]byte, 40)
+		ip[0], ip[6], ip[7] = 0x60, 17, 64
+		binary.BigEndian.PutUint16(ip[4:], uint16(xdpUDPLen+len(payload)))
+		copy(ip[8:], src.IP)
+		copy(ip[24:], dst.IP)
+		b = append(b, ip...)
+	}
+	b = append(b, udp...)
+	return append(b, payload...)
+}
+
+func TestXDPFrame(t *testing.T) {
+	client := []*net.UDPAddr{
+		{IP: net.ParseIP("192.0.2.1"), Port: 40000},
+		{IP: net.ParseIP("2001:db8::1"), Port: 40001},
+	}
+	server := []*net.UDPAddr{
+		{IP: net.ParseIP("192.0.2.53"), Port: 53},
+		{IP: net.ParseIP("2001:db8::53"), Port: 53},
+	}
+	for i := range client {
+		// With Ethernet padding after the query.
+		frame := append(xdpQuery(client[i], server[i], []byte("query")), 0, 0, 0)
+		p, ok := parseXDPFrame(frame)
+		if !ok {
+			t.Fatalf("Test %d: expected the frame to be parsed", i)
+		}
+		if string(p.payload) != "query" {
+			t.Errorf("Test %d: expected payload %q, got %q", i, "query", p.payload)
+		}
+		local, remote := p.addrs()
+		if local.String() != server[i].String() || remote.String() != client[i].String() {
+			t.Errorf("Test %d: expected addresses %s and %s, got %s and %s", i, server[i], client[i], local, remote)
+		}
+
+		b := make([]byte, xdpFrameSize)
+		n := p.reply(b, []byte("response"))
+		r, ok := parseXDPFrame(b[:n])
+		if !ok {
+			t.Fatalf("Test %d: expected the response to be parsed", i)
+		}
+		if string(r.payload) != "response" {
+			t.Errorf("Test %d: expected payload %q, got %q", i, "response", r.payload)
+		}
+		local, remote = r.addrs()
+		if local.String() != client[i].String() || remote.String() != server[i].String() {
+			t.Errorf("Test %d: expected addresses %s and %s, got %s and %s", i, client[i], server[i], local, remote)
+		}
+		if string(b[:6]) != string(frame[6:12]) {
+			t.Errorf("Test %d: expected the destination MAC address of the response to be the source of the query", i)
+		}
+
+		// Checksums including their checksum sum up to 0xffff.
+		udp := b[r.hdrLen-xdpUDPLen : n]
+		var sum uint32
+		if r.v6() {
+			sum = checksum(b[xdpEthLen+8:xdpEthLen+40], 0)
+		} else {
+			if c := fold(checksum(b[xdpEthLen:xdpIPv4], 0)); c != 0xffff {
+				t.Errorf("Test %d: expected a valid IPv4 checksum, got %x", i, c)
+			}
+			sum = checksum(b[xdpEthLen+12:xdpEthLen+20], 0)
+		}
+		sum += 17 + uint32(len(udp))
+		if c := fold(checksum(udp, sum)); c != 0xffff {
+			t.Errorf("Test %d: expected a valid UDP checksum, got %x", i, c)
+		}
+	}
+}
+
+func TestXDPFrameInvalid(t *testing.T) {
+	src, dst := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 40000}, &net.UDPAddr{IP: net.ParseIP("192.0.2.53"), Port: 53}
+	frame := xdpQuery(src, dst, []byte("query"))
+	tests := [][]byte{
+		frame[:xdpEthLen],
+		frame[:xdpIPv4+4],
+		frame[:len(frame)-1], // shorter than the IPv4 length
+		append([]byte(nil), frame...),
+	}
+	tests[3][12] = 0x81 // VLAN tag
+	for i, b := range tests {
+		if _, ok := parseXDPFrame(b); ok {
+			t.Errorf("Test %d: expected the frame not to be parsed", i)
+		}
+	}
+}
+
+func TestXDP(t *testing.T) {
+	h := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A("example.org. 300 IN A 192.0.2.1")}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	c := testConfig("dns", h)
+	c.Zone = "."
+	s, err := NewServer("127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A port nothing listens on, the queries are only answered through AF_XDP. The kernel drops the
+	// IPv4 loopback packets sent with AF_XDP as martians, so the test uses IPv6.
+	p, err := net.ListenPacket("udp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 is not available: %s", err)
+	}
+	addr := p.LocalAddr().(*net.UDPAddr)
+	p.Close()
+
+	x, err := acquireXDPConn(s, "lo", addr)
+	if err != nil {
+		t.Skipf("AF_XDP is not available: %s", err)
+	}
+	defer x.release()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	cl := &dns.Client{Timeout: 2 * time.Second}
+	r, _, err := cl.Exchange(m, addr.String())
+	if err != nil {
+		t.Fatalf("Expected a response, got %s", err)
+	}
+	if len(r.Answer) != 1 {
+		t.Errorf("Expected 1 answer, got %d", len(r.Answer))
+	}
+}
+
+func TestXDPProgram(t *testing.T) {
+	m, err := newXSKMap(1)
+	if err != nil {
+		t.Skipf("BPF is not available: %s", err)
+	}
+	defer unix.Close(m)
+	// The verifier accepts the programs for all kinds of addresses.
+	for _, addr := range []string{":53", "0.0.0.0:53", "192.0.2.53:53", "255.255.255.255:53", "[::]:53", "[2001:db8::53]:1053"} {
+		a, err := net.ResolveUDPAddr("udp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prog, err := loadXDPProgram(xdpProgram(m, a))
+		if err != nil {
+			t.Errorf("Expected the program for %s to be loaded, got %s", addr, err)
+			continue
+		}
+		unix.Close(prog)
+	}
+}
@@ -0,0 +1,17 @@
+// +build !linux
+
+package dnsserver
+
+import (
+	"errors"
+	"net"
+)
+
+// xdpConn is only implemented on Linux.
+type xdpConn struct{}
+
+func acquireXDPConn(s *Server, iface string, addr *net.UDPAddr) (*xdpConn, error) {
+	return nil, errors.New("AF_XDP is only supported on Linux")
+}
+
+func (x *xdpConn) release() error { return nil }
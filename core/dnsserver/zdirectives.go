@@ -13,27 +13,59 @@ var Directives = []string{
 	"metadata",
 	"cancel",
 	"tls",
+	"https_path",
+	"https_server",
+	"graceful_shutdown",
+	"max_query_time",
+	"query_timeout",
+	"max_concurrent",
+	"proxy_protocol",
 	"reload",
 	"nsid",
 	"root",
+	"order",
 	"bind",
+	"view",
 	"debug",
+	"features",
+	"nofrag",
+	"cookies",
+	"reuseport",
+	"xdp",
+	"sockfilter",
+	"numa",
 	"trace",
 	"ready",
+	"admin",
 	"health",
+	"buildinfo",
 	"pprof",
+	"mdns",
 	"prometheus",
+	"padding",
 	"errors",
 	"log",
 	"dnstap",
+	"tsig",
+	"ratelimit",
+	"rrl",
+	"shed",
 	"any",
 	"chaos",
 	"loadbalance",
+	"geosteer",
+	"gslb",
+	"compress",
 	"cache",
+	"validate",
 	"rewrite",
+	"ecs",
+	"wasm",
+	"lua",
 	"dnssec",
 	"autopath",
 	"template",
+	"autoptr",
 	"hosts",
 	"route53",
 	"federation",
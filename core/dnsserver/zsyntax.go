@@ -0,0 +1,79 @@
+// generated by directives_generate.go; DO NOT EDIT
+
+package dnsserver
+
+// DirectiveSyntax holds the forms in the Syntax section of the README of each directive,
+// keyed by directive name. External plugins are only included when known to the generator.
+var DirectiveSyntax = map[string]string{
+	"metadata":          "metadata [ZONES... ]",
+	"cancel":            "cancel [TIMEOUT]",
+	"tls":               "tls CERT KEY [CA]\n\ntls CERT KEY [CA] {\n    client_auth nocert|request|require|verify_if_given|require_and_verify\n    client_ca CA\n    reload DURATION\n}\n\ntls acme DOMAIN... {\n    email ADDRESS\n    ca URL\n    path DIR\n    client_auth nocert|request|require|verify_if_given|require_and_verify\n    client_ca CA\n}",
+	"https_path":        "https_path PATH...",
+	"https_server":      "https_server {\n    read_timeout DURATION\n    write_timeout DURATION\n    idle_timeout DURATION\n    max_header_bytes SIZE\n    max_concurrent_streams NUMBER\n    trusted_proxies NETWORK...\n    bearer_tokens TOKEN...\n    bearer_token_file FILE\n    basic_auth USER PASSWORD\n}",
+	"graceful_shutdown": "graceful_shutdown DURATION",
+	"max_query_time":    "max_query_time DURATION",
+	"query_timeout":     "query_timeout DURATION",
+	"max_concurrent":    "max_concurrent LIMIT [refuse|drop]",
+	"proxy_protocol":    "proxy_protocol NETWORK...",
+	"reload":            "reload [INTERVAL] [JITTER]",
+	"nsid":              "nsid [DATA]",
+	"root":              "root PATH",
+	"order":             "order PLUGIN before|after OTHER",
+	"bind":              "bind ADDRESS|INTERFACE  ...",
+	"view":              "view NAME {\n    expr EXPRESSION\n}",
+	"debug":             "debug",
+	"features":          "features NAME...\n\nfeatures [NAME...] {\n    enable NAME...\n    disable NAME...\n}",
+	"nofrag":            "nofrag [SIZE]",
+	"cookies":           "cookies {\n    require\n    secret SECRET\n    rotate DURATION\n}",
+	"reuseport":         "reuseport NUMBER",
+	"xdp":               "xdp INTERFACE",
+	"sockfilter":        "sockfilter [NETWORK...] {\n    deny NETWORK...\n}",
+	"numa":              "numa [NODE...] {\n    workers COUNT\n    incoming_cpu\n}",
+	"trace":             "trace [ENDPOINT-TYPE] [ENDPOINT]\n\ntrace [ENDPOINT-TYPE] [ENDPOINT] {\n\tevery AMOUNT\n\tservice NAME\n\tclient_server\n}",
+	"ready":             "ready [ADDRESS]\n\nready [ADDRESS] {\n    doh\n}",
+	"admin":             "admin [ADDRESS]",
+	"health":            "health [ADDRESS]\n\nhealth [ADDRESS] {\n    lameduck DURATION\n    doh\n}",
+	"buildinfo":         "buildinfo [ADDRESS] {\n    token TOKEN\n}",
+	"pprof":             "pprof [ADDRESS]\n\npprof [ADDRESS] {\n   block [RATE]\n}",
+	"mdns":              "mdns [INTERFACE...] {\n    host NAME [ADDRESS...]\n    service INSTANCE TYPE PORT HOST [TXT...]\n    reflect\n}",
+	"prometheus":        "prometheus [ADDRESS]",
+	"padding":           "padding [block SIZE] {\n    transports TRANSPORT...\n}",
+	"errors":            "errors\n\nerrors {\n\tconsolidate DURATION REGEXP\n}",
+	"log":               "log\n\nlog [NAMES...] [FORMAT]\n\nlog [NAMES...] [FORMAT] {\n    class CLASSES...\n}",
+	"dnstap":            "dnstap SOCKET [full]",
+	"tsig":              "tsig [ZONES...] {\n    secret NAME SECRET\n    secrets FILE\n    require [QTYPES...]\n}",
+	"ratelimit":         "ratelimit RATE {\n    window DURATION\n    burst BURST\n    prefix V4LEN [V6LEN]\n    action drop|refused|servfail\n    max_clients NUMBER\n    exempt NETWORK...\n}",
+	"rrl":               "rrl [ZONES...] {\n    responses_per_second RATE\n    nodata_per_second RATE\n    nxdomains_per_second RATE\n    referrals_per_second RATE\n    errors_per_second RATE\n    window SECONDS\n    slip NUMBER\n    ipv4_prefix_length LENGTH\n    ipv6_prefix_length LENGTH\n    max_table_size NUMBER\n    exempt NETWORK...\n    except ZONE...\n}",
+	"shed":              "shed [LIMIT] {\n    interval DURATION\n    class NAME PRIORITY [NETWORK...]\n}",
+	"any":               "any",
+	"chaos":             "chaos [VERSION] [AUTHORS...]",
+	"loadbalance":       "loadbalance [round_robin | weighted [WEIGHTFILE]] {\n    reload DURATION\n}",
+	"geosteer":          "geosteer [ZONES...] {\n    network CIDR LOCATION...\n    steer LOCATION|default ADDRESS...\n}",
+	"gslb":              "gslb [ZONES...] {\n    pool NAME ADDRESS...\n    check http|tcp PORT [PATH]\n    interval DURATION\n    timeout DURATION\n    rise COUNT\n    fall COUNT\n    ttl SECONDS\n}",
+	"compress":          "compress [SIZE]",
+	"cache":             "cache [TTL] [ZONES...]\n\ncache [TTL] [ZONES...] {\n    success CAPACITY [TTL] [MINTTL]\n    denial CAPACITY [TTL] [MINTTL]\n    prefetch AMOUNT [[DURATION] [PERCENTAGE%]]\n    prefetch top AMOUNT [[HALFLIFE] [PERCENTAGE%]]\n    nxdomain TTL\n    nodata TTL\n    servfail DURATION\n    nxdomain_cut\n    serve_stale [DURATION] [REFRESH_MODE]\n    stale_ttl TTL\n    persist FILE\n}",
+	"validate":          "validate [ZONES...] {\n    trust_anchor RR\n    trust_anchor_file FILE\n}",
+	"rewrite":           "rewrite [continue|stop] FIELD [FROM TO|FROM TTL]\n\nrewrite [continue|stop] name [exact|prefix|suffix|substring|regex] STRING STRING",
+	"ecs":               "ecs [IPV4_PREFIX [IPV6_PREFIX]] {\n    transports TRANSPORT...\n}",
+	"wasm":              "wasm FILE [ZONES...] {\n    instances NUMBER\n    timeout DURATION\n}",
+	"lua":               "lua FILE [ZONES...]",
+	"dnssec":            "dnssec [ZONES... ] {\n    key file KEY...\n    cache_capacity CAPACITY\n    cds\n    nsec3 [ITERATIONS [SALT]]\n    optout\n}\n\ndnssec [ZONE] {\n    key directory DIR\n    algorithm ALGORITHM\n    rollover zsk|ksk DURATION\n    cache_capacity CAPACITY\n    cds\n    nsec3 [ITERATIONS [SALT]]\n    optout\n}",
+	"autopath":          "autopath [ZONE...] RESOLV-CONF",
+	"template":          "template CLASS TYPE [ZONE...] {\n    match REGEX...\n    answer RR\n    additional RR\n    authority RR\n    rcode CODE\n    fallthrough [ZONE...]\n}",
+	"autoptr":           "autoptr [ZONES...] {\n    ttl SECONDS\n    reload DURATION\n}",
+	"hosts":             "hosts [FILE [ZONES...]] {\n    [INLINE]\n    ttl SECONDS\n    no_reverse\n    reload DURATION\n    fallthrough [ZONES...]\n}",
+	"route53":           "route53 [ZONE:HOSTED_ZONE_ID...] {\n    aws_access_key [AWS_ACCESS_KEY_ID AWS_SECRET_ACCESS_KEY]\n    credentials PROFILE [FILENAME]\n    fallthrough [ZONES...]\n    refresh DURATION\n}",
+	"federation":        "federation [ZONES...] {\n    NAME DOMAIN\n}",
+	"k8s_external":      "k8s_external [ZONE...]\n\nk8s_external [ZONE...] {\n    apex APEX\n    ttl TTL\n}",
+	"kubernetes":        "kubernetes [ZONES...]\n\nkubernetes [ZONES...] {\n    endpoint URL\n    tls CERT KEY CACERT\n    kubeconfig KUBECONFIG CONTEXT\n    namespaces NAMESPACE...\n    labels EXPRESSION\n    pods POD-MODE\n    endpoint_pod_names\n    ttl TTL\n    noendpoints\n    transfer to ADDRESS... [key NAME SECRET]\n    fallthrough [ZONES...]\n    ignore empty_service\n}",
+	"file":              "file DBFILE [ZONES...]\n\nfile DBFILE [ZONES... ] {\n    transfer to ADDRESS... [key NAME SECRET]\n    update ADDRESS... [key NAME SECRET]\n    reload DURATION\n    serial unixtime|date\n}",
+	"auto":              "auto [ZONES...] {\n    directory DIR [REGEXP ORIGIN_TEMPLATE]\n    transfer to ADDRESS... [key NAME SECRET]\n    reload DURATION\n    remove_after DURATION\n}",
+	"secondary":         "secondary [ZONES...]\n\nsecondary [zones...] {\n    transfer from ADDRESS [key NAME SECRET]\n    transfer to ADDRESS [key NAME SECRET]\n    catalog\n}",
+	"etcd":              "etcd [ZONES...]\n\netcd [ZONES...] {\n    fallthrough [ZONES...]\n    path PATH\n    endpoint ENDPOINT...\n    credentials USERNAME PASSWORD\n    tls CERT KEY CACERT\n}",
+	"loop":              "loop",
+	"forward":           "forward FROM TO...\n\nforward FROM TO... {\n    except IGNORED_NAMES...\n    force_tcp\n    prefer_udp\n    expire DURATION\n    max_fails INTEGER\n    tls CERT KEY CA\n    tls_servername NAME\n    policy random|round_robin|sequential|weighted WEIGHT...|latency\n    health_check DURATION\n    chase_alias\n    bootstrap ADDRESS...\n    policy_route CONDITION VALUE [CONDITION VALUE]... to TO...\n    stats ADDRESS\n}",
+	"grpc":              "grpc FROM TO...\n\ngrpc FROM TO... {\n    except IGNORED_NAMES...\n    tls CERT KEY CA\n    tls_servername NAME\n    policy random|round_robin|sequential\n}",
+	"erratic":           "erratic {\n    drop [AMOUNT]\n    truncate [AMOUNT]\n    delay [AMOUNT [DURATION]]\n}",
+	"whoami":            "whoami",
+	"on":                "on startup|shutdown|certrenew COMMAND [ARGS...]",
+}
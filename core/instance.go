@@ -0,0 +1,135 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/coredns/coredns/core/corefile"
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+	"github.com/caddyserver/caddy/caddyfile"
+)
+
+// Config is the configuration for an embedded CoreDNS instance.
+type Config struct {
+	// Corefile holds the contents of the Corefile to run.
+	Corefile []byte
+
+	// Path is the path of the Corefile, relative paths in the Corefile, i.e. for import, are
+	// resolved relative to this path. Defaults to "Corefile".
+	Path string
+
+	// Loader expands the imports of URLs and parameterized snippets in the Corefile, as the coredns
+	// binary does with its -import* flags.
+	Loader corefile.Loader
+}
+
+// Instance is a CoreDNS instance that can be embedded in another Go program. Plugins are not
+// registered by this package, import github.com/coredns/coredns/core/plugin to get all plugins
+// CoreDNS is compiled with, or import the ones needed.
+type Instance struct {
+	mu     sync.Mutex
+	path   string
+	loader corefile.Loader
+	in     caddy.Input
+	inst   *caddy.Instance
+}
+
+// New returns a new Instance for cfg. The Corefile is parsed, but no servers are started until
+// Start is called.
+func New(cfg Config) (*Instance, error) {
+	i := &Instance{path: cfg.Path, loader: cfg.Loader}
+	if i.path == "" {
+		i.path = caddy.DefaultConfigFile
+	}
+	in, err := i.input(cfg.Corefile)
+	if err != nil {
+		return nil, err
+	}
+	i.in = in
+	return i, nil
+}
+
+// Start starts all servers defined in the Corefile.
+func (i *Instance) Start() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.inst != nil {
+		return errors.New("instance already started")
+	}
+
+	inst, err := caddy.Start(i.in)
+	if err != nil {
+		if inst != nil {
+			inst.Stop()
+		}
+		return err
+	}
+	i.inst = inst
+	return nil
+}
+
+// Stop stops all servers and executes the shutdown callbacks of the plugins.
+func (i *Instance) Stop() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.inst == nil {
+		return errors.New("instance not started")
+	}
+
+	err := i.inst.Stop()
+	if errs := i.inst.ShutdownCallbacks(); len(errs) > 0 && err == nil {
+		err = errs[0]
+	}
+	i.inst = nil
+	return err
+}
+
+// Reload gracefully restarts the servers with a new Corefile. If the reload fails the instance
+// keeps running with the old configuration.
+func (i *Instance) Reload(corefile []byte) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.inst == nil {
+		return errors.New("instance not started")
+	}
+
+	in, err := i.input(corefile)
+	if err != nil {
+		return err
+	}
+	inst, err := i.inst.Restart(in)
+	if err != nil {
+		return err
+	}
+	i.in = in
+	i.inst = inst
+	return nil
+}
+
+// Servers returns the running servers. Each server is one of *dnsserver.Server,
+// *dnsserver.ServerTLS, *dnsserver.ServergRPC, *dnsserver.ServerHTTPS or *dnsserver.ServerHTTP.
+func (i *Instance) Servers() []caddy.Server {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.inst == nil {
+		return nil
+	}
+	return dnsserver.Servers(i.inst)
+}
+
+// input expands and parses contents and returns it as a caddy.Input.
+func (i *Instance) input(contents []byte) (caddy.Input, error) {
+	in, err := i.loader.Input(contents, i.path, serverType)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := caddyfile.Parse(in.Path(), bytes.NewReader(in.Body()), dnsserver.Directives); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+const serverType = "dns"
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	_ "github.com/coredns/coredns/plugin/whoami"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestInstance(t *testing.T) {
+	caddy.Quiet = true
+	dnsserver.Quiet = true
+
+	if _, err := New(Config{Corefile: []byte(".:0 {\nwhoami\n")}); err == nil {
+		t.Fatal("Expected error for invalid Corefile, got none")
+	}
+
+	i, err := New(Config{Corefile: []byte(".:0 {\nwhoami\n}\n")})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if err := i.Start(); err != nil {
+		t.Fatalf("Expected no error starting, got %s", err)
+	}
+
+	servers := i.Servers()
+	if len(servers) != 1 {
+		t.Fatalf("Expected 1 server, got %d", len(servers))
+	}
+	if _, ok := servers[0].(*dnsserver.Server); !ok {
+		t.Fatalf("Expected *dnsserver.Server, got %T", servers[0])
+	}
+
+	if err := i.Reload([]byte("example.org:0 {\nwhoami\n}\n")); err != nil {
+		t.Fatalf("Expected no error reloading, got %s", err)
+	}
+	if len(i.Servers()) != 1 {
+		t.Fatalf("Expected 1 server after reload, got %d", len(i.Servers()))
+	}
+
+	if err := i.Stop(); err != nil {
+		t.Fatalf("Expected no error stopping, got %s", err)
+	}
+	if err := i.Stop(); err == nil {
+		t.Fatal("Expected error stopping a stopped instance, got none")
+	}
+}
+
+func TestInstanceSnippets(t *testing.T) {
+	caddy.Quiet = true
+	dnsserver.Quiet = true
+
+	// The Corefile is expanded as the coredns binary does, so parameterized snippets work.
+	i, err := New(Config{Corefile: []byte("(zone) {\n{args[0]}:0 {\nwhoami\n}\n}\nimport zone example.org\n")})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if err := i.Start(); err != nil {
+		t.Fatalf("Expected no error starting, got %s", err)
+	}
+	defer i.Stop()
+
+	if len(i.Servers()) != 1 {
+		t.Fatalf("Expected 1 server, got %d", len(i.Servers()))
+	}
+	if body := string(i.in.Body()); !strings.Contains(body, "example.org:0 {") || strings.Contains(body, "import") {
+		t.Errorf("Expected the snippet to be expanded, got %q", body)
+	}
+}
@@ -0,0 +1,357 @@
+package coremain
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/parse"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+)
+
+func init() {
+	commands["bench"] = command{usage: "bench [-c N] [-d DURATION] [-n COUNT] [-rate QPS] [-name PATTERN] [-type TYPES] TARGET: send queries to TARGET and report the latencies", run: benchCommand}
+}
+
+// benchConfig is the load generated by the bench command.
+type benchConfig struct {
+	transport string // transport.DNS, TLS or HTTPS
+	tcp       bool   // DNS over TCP instead of UDP
+	addr      string // host:port, or the URL for HTTPS
+	insecure  bool   // don't verify the certificate of the target
+
+	names    []string // the patterns of the names queried, see benchName
+	types    []uint16
+	workers  int
+	duration time.Duration
+	count    int64 // when not zero, the number of queries sent
+	rate     int   // when not zero, the queries sent per second
+	timeout  time.Duration
+}
+
+// benchResult holds what the workers of the bench command measured.
+type benchResult struct {
+	elapsed   time.Duration
+	latencies []time.Duration // of the queries answered
+	rcodes    map[int]int
+	timeouts  int
+	errors    int
+}
+
+func (r *benchResult) merge(o *benchResult) {
+	r.latencies = append(r.latencies, o.latencies...)
+	for rc, n := range o.rcodes {
+		r.rcodes[rc] += n
+	}
+	r.timeouts += o.timeouts
+	r.errors += o.errors
+}
+
+// stringList is a flag that can be given several times.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(s string) error { *l = append(*l, s); return nil }
+
+func benchCommand(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	var names stringList
+	fs.Var(&names, "name", "Pattern of the names queried, {n} is replaced by a sequence number and {rand} by a random label; can be given several times (default example.org.)")
+	types := fs.String("type", "A", "Comma separated types of the queries, picked at random")
+	workers := fs.Int("c", 10, "Number of queries in flight")
+	duration := fs.Duration("d", 10*time.Second, "Duration of the run")
+	count := fs.Int64("n", 0, "Number of queries to send, the run ends after them or the duration")
+	rate := fs.Int("rate", 0, "Queries per second to send, 0 for as many as the target answers")
+	timeout := fs.Duration("timeout", 2*time.Second, "Timeout of a query")
+	tcp := fs.Bool("tcp", false, "Use TCP instead of UDP for dns:// targets")
+	insecure := fs.Bool("insecure", false, "Don't verify the certificate of tls:// and https:// targets")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: bench [-c N] [-d DURATION] [-n COUNT] [-rate QPS] [-name PATTERN] [-type TYPES] TARGET")
+	}
+
+	cfg, err := newBenchConfig(fs.Arg(0), names, *types)
+	if err != nil {
+		return err
+	}
+	if *workers < 1 || *duration <= 0 || *count < 0 || *rate < 0 || *timeout <= 0 {
+		return errors.New("-c, -d and -timeout must be positive, -n and -rate can't be negative")
+	}
+	cfg.workers, cfg.duration, cfg.count, cfg.rate, cfg.timeout = *workers, *duration, *count, *rate, *timeout
+	cfg.tcp, cfg.insecure = *tcp, *insecure
+
+	res, err := bench(cfg)
+	if err != nil {
+		return err
+	}
+	writeBench(os.Stdout, res)
+	return nil
+}
+
+// newBenchConfig returns the configuration to send queries to target, for the names matching the
+// patterns and the comma separated types.
+func newBenchConfig(target string, names []string, types string) (*benchConfig, error) {
+	cfg := &benchConfig{names: names}
+	if len(cfg.names) == 0 {
+		cfg.names = []string{"example.org."}
+	}
+	for i, n := range cfg.names {
+		cfg.names[i] = dns.Fqdn(n)
+	}
+	for _, t := range strings.Split(types, ",") {
+		qtype, ok := dns.StringToType[strings.ToUpper(strings.TrimSpace(t))]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %q", t)
+		}
+		cfg.types = append(cfg.types, qtype)
+	}
+
+	trans, addr := parse.Transport(target)
+	switch trans {
+	case transport.DNS, transport.TLS:
+		port := transport.Port
+		if trans == transport.TLS {
+			port = transport.TLSPort
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, port)
+		}
+	case transport.HTTPS:
+		if !strings.Contains(addr, "/") {
+			addr += doh.Path
+		}
+		addr = "https://" + addr
+	default:
+		return nil, fmt.Errorf("unsupported transport %q, use dns://, tls:// or https://", trans)
+	}
+	cfg.transport, cfg.addr = trans, addr
+	return cfg, nil
+}
+
+// benchName returns the name of pattern for the query number n.
+func benchName(pattern string, n int64, rnd *rand.Rand) string {
+	name := strings.Replace(pattern, "{n}", strconv.FormatInt(n, 10), -1)
+	for strings.Contains(name, "{rand}") {
+		label := make([]byte, 8)
+		for i := range label {
+			label[i] = 'a' + byte(rnd.Intn(26))
+		}
+		name = strings.Replace(name, "{rand}", string(label), 1)
+	}
+	return name
+}
+
+// benchExchanger sends a query and returns its response.
+type benchExchanger interface {
+	exchange(m *dns.Msg) (*dns.Msg, error)
+	close()
+}
+
+// bench sends the queries of cfg, and returns what was measured.
+func bench(cfg *benchConfig) (*benchResult, error) {
+	// Check the target is reachable before starting the workers.
+	x, err := newBenchExchanger(cfg)
+	if err != nil {
+		return nil, err
+	}
+	x.close()
+
+	var (
+		sent  int64 // the queries sent, accessed atomically
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total = &benchResult{rcodes: map[int]int{}}
+	)
+	start := time.Now()
+	end := start.Add(cfg.duration)
+	for i := 0; i < cfg.workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res := &benchResult{rcodes: map[int]int{}}
+			rnd := rand.New(rand.NewSource(start.UnixNano() + int64(i)))
+			var x benchExchanger
+			// With a rate, the workers take turns: worker i sends at start + (i + k*workers)/rate.
+			var interval time.Duration
+			next := start
+			if cfg.rate > 0 {
+				interval = time.Duration(float64(time.Second) * float64(cfg.workers) / float64(cfg.rate))
+				next = start.Add(time.Duration(float64(time.Second) * float64(i) / float64(cfg.rate)))
+			}
+			for {
+				if interval > 0 {
+					time.Sleep(time.Until(next))
+					next = next.Add(interval)
+				}
+				n := atomic.AddInt64(&sent, 1)
+				if !time.Now().Before(end) || (cfg.count > 0 && n > cfg.count) {
+					break
+				}
+				if x == nil {
+					var err error
+					if x, err = newBenchExchanger(cfg); err != nil {
+						res.errors++
+						continue
+					}
+				}
+
+				m := new(dns.Msg)
+				m.SetQuestion(benchName(cfg.names[rnd.Intn(len(cfg.names))], n, rnd), cfg.types[rnd.Intn(len(cfg.types))])
+				t := time.Now()
+				r, err := x.exchange(m)
+				switch {
+				case err == nil:
+					res.latencies = append(res.latencies, time.Since(t))
+					res.rcodes[r.Rcode]++
+					continue
+				case isTimeout(err):
+					res.timeouts++
+				default:
+					res.errors++
+				}
+				// Start over with a new connection, a late response must not be taken for the next one.
+				x.close()
+				x = nil
+			}
+			if x != nil {
+				x.close()
+			}
+			mu.Lock()
+			total.merge(res)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	total.elapsed = time.Since(start)
+	return total, nil
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+func newBenchExchanger(cfg *benchConfig) (benchExchanger, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.insecure}
+	switch cfg.transport {
+	case transport.HTTPS:
+		c, err := doh.NewClient(cfg.addr, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		c.SetTimeout(cfg.timeout)
+		c.SetRetries(0)
+		return &benchHTTPS{client: c}, nil
+	case transport.TLS:
+		c := &dns.Client{Net: "tcp-tls", Timeout: cfg.timeout, TLSConfig: tlsConfig}
+		conn, err := c.Dial(cfg.addr)
+		if err != nil {
+			return nil, err
+		}
+		return &benchConn{conn: conn, timeout: cfg.timeout}, nil
+	}
+	c := &dns.Client{Net: "udp", Timeout: cfg.timeout}
+	if cfg.tcp {
+		c.Net = "tcp"
+	}
+	conn, err := c.Dial(cfg.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &benchConn{conn: conn, timeout: cfg.timeout}, nil
+}
+
+// benchConn sends the queries on a connection, over UDP, TCP or TLS.
+type benchConn struct {
+	conn    *dns.Conn
+	timeout time.Duration
+}
+
+func (b *benchConn) exchange(m *dns.Msg) (*dns.Msg, error) {
+	b.conn.SetDeadline(time.Now().Add(b.timeout))
+	if err := b.conn.WriteMsg(m); err != nil {
+		return nil, err
+	}
+	for {
+		r, err := b.conn.ReadMsg()
+		if err != nil {
+			return nil, err
+		}
+		if r.Id == m.Id {
+			return r, nil
+		}
+	}
+}
+
+func (b *benchConn) close() { b.conn.Close() }
+
+// benchHTTPS sends the queries with DNS over HTTPS, on the connections of a doh.Client.
+type benchHTTPS struct {
+	client *doh.Client
+}
+
+func (b *benchHTTPS) exchange(m *dns.Msg) (*dns.Msg, error) {
+	return b.client.Exchange(context.Background(), m)
+}
+
+func (b *benchHTTPS) close() { b.client.Close() }
+
+// percentile returns the latency below which fall the fraction p of the sorted latencies.
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(latencies)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return latencies[i]
+}
+
+// writeBench writes the report of res to w.
+func writeBench(w io.Writer, res *benchResult) {
+	answered := len(res.latencies)
+	sent := answered + res.timeouts + res.errors
+	fmt.Fprintf(w, "Queries:  %d sent, %d answered, %d timed out, %d failed in %s (%.1f answered/s)\n",
+		sent, answered, res.timeouts, res.errors, res.elapsed.Round(time.Millisecond), float64(answered)/res.elapsed.Seconds())
+
+	rcodes := make([]int, 0, len(res.rcodes))
+	for rc := range res.rcodes {
+		rcodes = append(rcodes, rc)
+	}
+	sort.Ints(rcodes)
+	var s []string
+	for _, rc := range rcodes {
+		s = append(s, fmt.Sprintf("%s %d", dns.RcodeToString[rc], res.rcodes[rc]))
+	}
+	if len(s) == 0 {
+		s = []string{"none"}
+	}
+	fmt.Fprintf(w, "Rcodes:   %s\n", strings.Join(s, ", "))
+
+	if answered == 0 {
+		return
+	}
+	l := res.latencies
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	fmt.Fprintf(w, "Latency:  min %s, p50 %s, p90 %s, p99 %s, p99.9 %s, max %s\n",
+		l[0], percentile(l, 0.5), percentile(l, 0.9), percentile(l, 0.99), percentile(l, 0.999), l[len(l)-1])
+}
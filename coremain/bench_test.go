@@ -0,0 +1,181 @@
+package coremain
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+
+	"github.com/miekg/dns"
+)
+
+func TestNewBenchConfig(t *testing.T) {
+	tests := []struct {
+		target    string
+		types     string
+		shouldErr bool
+		transport string
+		addr      string
+	}{
+		{"192.0.2.1", "A", false, "dns", "192.0.2.1:53"},
+		{"dns://192.0.2.1:1053", "A,AAAA", false, "dns", "192.0.2.1:1053"},
+		{"[2001:db8::1]:53", "mx", false, "dns", "[2001:db8::1]:53"},
+		{"tls://192.0.2.1", "A", false, "tls", "192.0.2.1:853"},
+		{"https://dns.example.org", "A", false, "https", "https://dns.example.org/dns-query"},
+		{"https://dns.example.org/query", "A", false, "https", "https://dns.example.org/query"},
+		{"grpc://192.0.2.1", "A", true, "", ""},
+		{"192.0.2.1", "A,BOGUS", true, "", ""},
+	}
+	for i, tc := range tests {
+		cfg, err := newBenchConfig(tc.target, nil, tc.types)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if cfg.transport != tc.transport || cfg.addr != tc.addr {
+			t.Errorf("Test %d: expected %s %s, got %s %s", i, tc.transport, tc.addr, cfg.transport, cfg.addr)
+		}
+	}
+}
+
+func TestBenchName(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	if n := benchName("q{n}.example.org.", 42, rnd); n != "q42.example.org." {
+		t.Errorf("Expected q42.example.org., got %s", n)
+	}
+	n := benchName("{rand}.{rand}.example.org.", 1, rnd)
+	labels := dns.SplitDomainName(n)
+	if len(labels) != 4 || len(labels[0]) != 8 || labels[0] == labels[1] || strings.Contains(n, "{") {
+		t.Errorf("Expected two different random labels, got %s", n)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var l []time.Duration
+	for i := 1; i <= 1000; i++ {
+		l = append(l, time.Duration(i))
+	}
+	for p, expected := range map[float64]time.Duration{0.5: 500, 0.99: 990, 0.999: 999, 1: 1000, 0: 1} {
+		if d := percentile(l, p); d != expected {
+			t.Errorf("Expected percentile %v to be %d, got %d", p, expected, d)
+		}
+	}
+	if d := percentile(nil, 0.5); d != 0 {
+		t.Errorf("Expected 0 without latencies, got %d", d)
+	}
+}
+
+// benchHandler answers NXDOMAIN for the names under nx., and NOERROR for the others.
+var benchHandler = dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	if dns.IsSubDomain("nx.", r.Question[0].Name) {
+		m.Rcode = dns.RcodeNameError
+	}
+	w.WriteMsg(m)
+})
+
+func TestBench(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	udp := &dns.Server{PacketConn: pc, Handler: benchHandler}
+	tcp := &dns.Server{Listener: l, Handler: benchHandler}
+	go udp.ActivateAndServe()
+	go tcp.ActivateAndServe()
+	defer udp.Shutdown()
+	defer tcp.Shutdown()
+
+	https := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m, err := doh.RequestToMsg(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.Response = true
+		if dns.IsSubDomain("nx.", m.Question[0].Name) {
+			m.Rcode = dns.RcodeNameError
+		}
+		b, _ := m.Pack()
+		w.Header().Set("Content-Type", doh.MimeType)
+		w.Write(b)
+	}))
+	defer https.Close()
+
+	tests := []struct {
+		target string
+		tcp    bool
+	}{
+		{"dns://" + pc.LocalAddr().String(), false},
+		{"dns://" + l.Addr().String(), true},
+		{https.URL + "/dns-query", false},
+	}
+	for i, tc := range tests {
+		cfg, err := newBenchConfig(tc.target, []string{"{n}.example.org", "{rand}.nx."}, "A,AAAA")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.tcp, cfg.insecure = tc.tcp, true
+		cfg.workers, cfg.duration, cfg.count, cfg.timeout = 4, 10*time.Second, 200, time.Second
+
+		res, err := bench(cfg)
+		if err != nil {
+			t.Fatalf("Test %d: %s", i, err)
+		}
+		if len(res.latencies) != 200 || res.timeouts+res.errors != 0 {
+			t.Errorf("Test %d: expected 200 queries answered, got %d, with %d timeouts and %d errors", i, len(res.latencies), res.timeouts, res.errors)
+		}
+		if res.rcodes[dns.RcodeSuccess] == 0 || res.rcodes[dns.RcodeNameError] == 0 {
+			t.Errorf("Test %d: expected NOERROR and NXDOMAIN responses, got %v", i, res.rcodes)
+		}
+
+		out := &bytes.Buffer{}
+		writeBench(out, res)
+		for _, s := range []string{"200 sent, 200 answered", "NOERROR", "NXDOMAIN", "p99.9"} {
+			if !strings.Contains(out.String(), s) {
+				t.Errorf("Test %d: expected the report to contain %q, got:\n%s", i, s, out)
+			}
+		}
+	}
+}
+
+func TestBenchRate(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &dns.Server{PacketConn: pc, Handler: benchHandler}
+	go s.ActivateAndServe()
+	defer s.Shutdown()
+
+	cfg, err := newBenchConfig(pc.LocalAddr().String(), nil, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.workers, cfg.duration, cfg.rate, cfg.timeout = 2, 500*time.Millisecond, 100, time.Second
+	res, err := bench(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 100 queries per second for half a second.
+	if n := len(res.latencies); n < 40 || n > 60 {
+		t.Errorf("Expected about 50 queries answered, got %d", n)
+	}
+}
@@ -0,0 +1,40 @@
+package coremain
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// BuildInfo describes the CoreDNS binary.
+type BuildInfo struct {
+	Version   string            `json:"version"`
+	Commit    string            `json:"commit"`
+	GoVersion string            `json:"go_version"`
+	OS        string            `json:"os"`
+	Arch      string            `json:"arch"`
+	Settings  map[string]string `json:"settings,omitempty"` // build flags, like -tags and -ldflags
+	Plugins   []PluginInfo      `json:"plugins"`
+}
+
+// Build returns the BuildInfo of the running binary.
+func Build() BuildInfo {
+	b := BuildInfo{
+		Version:   CoreVersion,
+		Commit:    GitCommit,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		bi = nil
+	}
+	if bi != nil && len(bi.Settings) > 0 {
+		b.Settings = make(map[string]string, len(bi.Settings))
+		for _, s := range bi.Settings {
+			b.Settings[s.Key] = s.Value
+		}
+	}
+	b.Plugins = listPlugins(bi)
+	return b
+}
@@ -0,0 +1,47 @@
+package coremain
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// A command is a subcommand of coredns, run as "coredns NAME [ARGS...]" instead of starting the server.
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+// commands holds all subcommands keyed by name.
+var commands = map[string]command{}
+
+// runCommand runs the subcommand named in args[0], if any. It returns false when args does not start
+// with a subcommand.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return false
+	}
+	if err := cmd.run(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", args[0], err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// commandsUsage returns the usage of all subcommands, sorted by name.
+func commandsUsage() string {
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	s := "Commands:\n"
+	for _, n := range names {
+		s += fmt.Sprintf("  %s\n", commands[n].usage)
+	}
+	return s
+}
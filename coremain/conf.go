@@ -0,0 +1,82 @@
+package coremain
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// confList is the list of Corefiles given with -conf, the flag may be repeated.
+type confList []string
+
+// String implements the flag.Value interface.
+func (c *confList) String() string { return strings.Join(*c, ",") }
+
+// Set implements the flag.Value interface.
+func (c *confList) Set(s string) error {
+	*c = append(*c, s)
+	return nil
+}
+
+// readConf reads the Corefiles in paths and concatenates them. When a path is a directory, all files
+// in it are read in lexical order; files starting with a dot are skipped, as are subdirectories.
+func readConf(paths []string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, p := range paths {
+		files, err := confFiles(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			contents, err := ioutil.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(contents)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// confFiles returns p if it is a file, or the files in p if it is a directory.
+func confFiles(p string) ([]string, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{p}, nil
+	}
+
+	infos, err := ioutil.ReadDir(p)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, i := range infos {
+		if i.IsDir() || strings.HasPrefix(i.Name(), ".") {
+			continue
+		}
+		files = append(files, filepath.Join(p, i.Name()))
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Corefiles found in %s", p)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// confPath returns the path used to resolve relative imports in the Corefile read from paths. For a
+// directory this is a (non existing) file in that directory, so imports are relative to it.
+func confPath(paths []string) string {
+	p := paths[0]
+	if info, err := os.Stat(p); err == nil && info.IsDir() {
+		return filepath.Join(p, "Corefile")
+	}
+	return p
+}
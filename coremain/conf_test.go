@@ -0,0 +1,73 @@
+package coremain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadConf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	confd := filepath.Join(dir, "conf.d")
+	if err := os.Mkdir(confd, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(confd, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(dir, "Corefile"):     ". {\n    whoami\n}",
+		filepath.Join(confd, "20-example"): "example.net {\n    whoami\n}",
+		filepath.Join(confd, "10-example"): "example.org {\n    whoami\n}",
+		filepath.Join(confd, ".hidden"):    "ignored",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	empty := filepath.Join(dir, "empty")
+	if err := os.Mkdir(empty, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		paths     []string
+		expected  string
+		path      string
+		shouldErr bool
+	}{
+		{[]string{filepath.Join(dir, "Corefile")}, ". {\n    whoami\n}\n", filepath.Join(dir, "Corefile"), false},
+		{[]string{confd}, "example.org {\n    whoami\n}\nexample.net {\n    whoami\n}\n", filepath.Join(confd, "Corefile"), false},
+		{[]string{filepath.Join(dir, "Corefile"), confd}, ". {\n    whoami\n}\nexample.org {\n    whoami\n}\nexample.net {\n    whoami\n}\n", filepath.Join(dir, "Corefile"), false},
+		{[]string{filepath.Join(dir, "nonexistent")}, "", "", true},
+		{[]string{empty}, "", "", true},
+	}
+
+	for i, tc := range tests {
+		contents, err := readConf(tc.paths)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got %s", i, err)
+			continue
+		}
+		if string(contents) != tc.expected {
+			t.Errorf("Test %d: Expected %q, got %q", i, tc.expected, contents)
+		}
+		if p := confPath(tc.paths); p != tc.path {
+			t.Errorf("Test %d: Expected path %s, got %s", i, tc.path, p)
+		}
+	}
+}
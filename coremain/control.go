@@ -0,0 +1,222 @@
+package coremain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	flag.StringVar(&controlPath, "control", "", "Path of the control socket used by the q command, empty disables it")
+	commands["q"] = command{usage: "q -control PATH [-server KEY] NAME [TYPE]: query a server block through the control socket", run: queryCommand}
+
+	caddy.RegisterEventHook("control", func(event caddy.EventName, info interface{}) error {
+		if i, ok := info.(*caddy.Instance); ok && event == caddy.InstanceStartupEvent {
+			control.set(i)
+		}
+		return nil
+	})
+}
+
+// controlTimeout is the time we allow for a single request on the control socket.
+const controlTimeout = 10 * time.Second
+
+var controlPath string
+
+// control holds the running instance queried through the control socket, it is replaced on every reload.
+var control = &controlInstance{}
+
+type controlInstance struct {
+	sync.RWMutex
+	i *caddy.Instance
+}
+
+func (c *controlInstance) set(i *caddy.Instance) {
+	c.Lock()
+	defer c.Unlock()
+	c.i = i
+}
+
+func (c *controlInstance) get() *caddy.Instance {
+	c.RLock()
+	defer c.RUnlock()
+	return c.i
+}
+
+// controlRequest is sent by the q command to the control socket.
+type controlRequest struct {
+	Server string `json:"server,omitempty"`
+	Name   string `json:"name"`
+	Type   uint16 `json:"type"`
+}
+
+// controlResponse is the answer to a controlRequest.
+type controlResponse struct {
+	Server  string          `json:"server,omitempty"`
+	Msg     []byte          `json:"msg,omitempty"` // the response in wire format
+	Rcode   int             `json:"rcode"`
+	Timings []controlTiming `json:"timings,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+type controlTiming struct {
+	Plugin   string        `json:"plugin"`
+	Depth    int           `json:"depth"`
+	Duration time.Duration `json:"duration"`
+	Rcode    int           `json:"rcode"`
+}
+
+// listenControl listens on the unix socket path and answers the requests from the q command. A stale
+// socket left behind by a previous run is removed first.
+func listenControl(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveControl(conn)
+		}
+	}()
+	return l, nil
+}
+
+func serveControl(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	var req controlRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		clog.Warningf("Failed to read control request: %s", err)
+		return
+	}
+	if err := json.NewEncoder(conn).Encode(handleControl(req)); err != nil {
+		clog.Warningf("Failed to write control response: %s", err)
+	}
+}
+
+func handleControl(req controlRequest) controlResponse {
+	i := control.get()
+	if i == nil {
+		return controlResponse{Error: "server not started"}
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(req.Name), req.Type)
+	m.SetEdns0(4096, false)
+	res, err := dnsserver.Query(context.Background(), i, req.Server, m)
+	if res == nil {
+		return controlResponse{Error: err.Error()}
+	}
+
+	resp := controlResponse{Server: res.Key, Rcode: res.Rcode}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	if res.Msg != nil {
+		resp.Msg, _ = res.Msg.Pack()
+	}
+	for _, t := range res.Timings {
+		resp.Timings = append(resp.Timings, controlTiming{Plugin: t.Plugin, Depth: t.Depth, Duration: t.Duration, Rcode: t.Rcode})
+	}
+	return resp
+}
+
+func queryCommand(args []string) error {
+	fs := flag.NewFlagSet("q", flag.ContinueOnError)
+	path := fs.String("control", "", "Path of the control socket of the running server")
+	server := fs.String("server", "", "Key of the server block to query, i.e. dns://example.org.:53")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("no control socket given, use -control")
+	}
+
+	req := controlRequest{Server: *server, Type: dns.TypeA}
+	switch fs.NArg() {
+	case 2:
+		qtype, ok := dns.StringToType[strings.ToUpper(fs.Arg(1))]
+		if !ok {
+			return fmt.Errorf("unknown type %q", fs.Arg(1))
+		}
+		req.Type = qtype
+		fallthrough
+	case 1:
+		req.Name = fs.Arg(0)
+	default:
+		return errors.New("usage: q -control PATH [-server KEY] NAME [TYPE]")
+	}
+
+	resp, err := sendControl(*path, req)
+	if err != nil {
+		return err
+	}
+	return writeQuery(os.Stdout, resp)
+}
+
+// sendControl sends req to the control socket at path and returns the response.
+func sendControl(path string, req controlRequest) (controlResponse, error) {
+	var resp controlResponse
+	conn, err := net.DialTimeout("unix", path, controlTimeout)
+	if err != nil {
+		return resp, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return resp, err
+	}
+	err = json.NewDecoder(conn).Decode(&resp)
+	return resp, err
+}
+
+// writeQuery writes the response and the timing of each plugin to w.
+func writeQuery(w io.Writer, resp controlResponse) error {
+	if resp.Server == "" && resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	if len(resp.Msg) > 0 {
+		m := new(dns.Msg)
+		if err := m.Unpack(resp.Msg); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, m.String())
+	} else {
+		fmt.Fprintf(w, ";; no response written, rcode %s\n\n", dns.RcodeToString[resp.Rcode])
+	}
+	if resp.Error != "" {
+		fmt.Fprintf(w, ";; ERROR: %s\n", resp.Error)
+	}
+	fmt.Fprintf(w, ";; SERVER BLOCK: %s\n", resp.Server)
+	fmt.Fprintln(w, ";; PLUGIN TIMING:")
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, t := range resp.Timings {
+		fmt.Fprintf(tw, ";; %s%s\t%s\t%s\n", strings.Repeat("  ", t.Depth), t.Plugin, t.Duration, dns.RcodeToString[t.Rcode])
+	}
+	return tw.Flush()
+}
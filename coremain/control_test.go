@@ -0,0 +1,62 @@
+package coremain
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestControlNotStarted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-control")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "coredns.sock")
+	l, err := listenControl(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	defer l.Close()
+
+	resp, err := sendControl(path, controlRequest{Name: "example.org.", Type: dns.TypeA})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if err := writeQuery(ioutil.Discard, resp); err == nil || err.Error() != "server not started" {
+		t.Errorf("Expected server not started error, got %v", err)
+	}
+}
+
+func TestWriteQuery(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Rcode = dns.RcodeNameError
+	buf, _ := m.Pack()
+
+	resp := controlResponse{
+		Server: "dns://.:53",
+		Msg:    buf,
+		Rcode:  dns.RcodeNameError,
+		Timings: []controlTiming{
+			{Plugin: "cache", Depth: 0, Duration: 2 * time.Millisecond, Rcode: dns.RcodeNameError},
+			{Plugin: "forward", Depth: 1, Duration: time.Millisecond, Rcode: dns.RcodeNameError},
+		},
+	}
+	out := &bytes.Buffer{}
+	if err := writeQuery(out, resp); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	for _, s := range []string{"status: NXDOMAIN", ";; SERVER BLOCK: dns://.:53", ";; cache ", ";;   forward ", "1ms"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("Expected output to contain %q, got:\n%s", s, out)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package coremain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+	"github.com/caddyserver/caddy/caddyfile"
+)
+
+// Exit codes used when CoreDNS fails to start.
+const (
+	exitError  = 1 // any other error
+	exitConfig = 2 // the Corefile can not be read or parsed
+	exitSetup  = 3 // a plugin failed to set up
+	exitBind   = 4 // a listener could not be created
+)
+
+// startError is an error that stopped CoreDNS from starting, classified by what failed.
+type startError struct {
+	Class string `json:"class"`
+	Code  int    `json:"exit_code"`
+	Err   string `json:"error"`
+}
+
+func (e startError) Error() string { return fmt.Sprintf("%s error: %s", e.Class, e.Err) }
+
+// classify returns the startError for err, returned from caddy.Start with corefile.
+func classify(err error, corefile caddy.Input) startError {
+	var op *net.OpError
+	switch {
+	case errors.As(err, &op) && op.Op == "listen",
+		strings.HasPrefix(err.Error(), "Listen: "), strings.HasPrefix(err.Error(), "ListenPacket: "):
+		return startError{Class: "bind", Code: exitBind, Err: err.Error()}
+	case corefile != nil && !parses(corefile):
+		return startError{Class: "config", Code: exitConfig, Err: err.Error()}
+	case corefile != nil:
+		return startError{Class: "setup", Code: exitSetup, Err: err.Error()}
+	}
+	return startError{Class: "error", Code: exitError, Err: err.Error()}
+}
+
+// parses returns true if the Corefile in input can be parsed.
+func parses(input caddy.Input) bool {
+	_, err := caddyfile.Parse(input.Path(), bytes.NewReader(input.Body()), dnsserver.Directives)
+	return err == nil
+}
+
+// mustExit logs e and exits with its exit code. With -startup json the error is logged as a JSON
+// object, see mustLogFatal for where it is logged.
+func mustExit(e startError) {
+	if !caddy.IsUpgrade() && !inService {
+		log.SetOutput(os.Stderr)
+	}
+	if startupFormat == "json" {
+		buf, _ := json.Marshal(e)
+		log.Print(string(buf))
+	} else {
+		log.Printf("[FATAL] %s", e)
+	}
+	os.Exit(e.Code)
+}
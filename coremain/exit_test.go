@@ -0,0 +1,39 @@
+package coremain
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestClassify(t *testing.T) {
+	good := caddy.CaddyfileInput{Contents: []byte(". {\n    whoami\n}\n"), Filepath: "Corefile", ServerTypeName: "dns"}
+	bad := caddy.CaddyfileInput{Contents: []byte(". {\n    whoami\n"), Filepath: "Corefile", ServerTypeName: "dns"}
+	unknown := caddy.CaddyfileInput{Contents: []byte(". {\n    nosuchplugin\n}\n"), Filepath: "Corefile", ServerTypeName: "dns"}
+
+	tests := []struct {
+		err      error
+		corefile caddy.Input
+		class    string
+		code     int
+	}{
+		{errors.New("Listen: listen tcp :53: bind: permission denied"), good, "bind", exitBind},
+		{errors.New("ListenPacket: listen udp :53: bind: address already in use"), good, "bind", exitBind},
+		{&net.OpError{Op: "listen", Net: "tcp", Err: errors.New("address already in use")}, good, "bind", exitBind},
+		{errors.New("Corefile:3 - Error during parsing: Unexpected EOF"), bad, "config", exitConfig},
+		{errors.New("Corefile:2 - Error during parsing: Unknown directive 'nosuchplugin'"), unknown, "config", exitConfig},
+		{errors.New("plugin/whoami: boom"), good, "setup", exitSetup},
+		{errors.New("boom"), nil, "error", exitError},
+	}
+	for i, tc := range tests {
+		e := classify(tc.err, tc.corefile)
+		if e.Class != tc.class || e.Code != tc.code {
+			t.Errorf("Test %d: Expected %s (%d), got %s (%d)", i, tc.class, tc.code, e.Class, e.Code)
+		}
+		if e.Err != tc.err.Error() {
+			t.Errorf("Test %d: Expected error %q, got %q", i, tc.err, e.Err)
+		}
+	}
+}
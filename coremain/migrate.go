@@ -0,0 +1,173 @@
+package coremain
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+func init() {
+	commands["migrate"] = command{usage: "migrate [-from FORMAT] [-o FILE] CONFIG: convert the configuration of another DNS server to a Corefile", run: migrateCommand}
+}
+
+// converters holds the converters for the configuration formats migrate understands, keyed by format.
+var converters = map[string]func(path string) (*migration, error){
+	"bind":    migrateBind,
+	"dnsmasq": migrateDnsmasq,
+	"unbound": migrateUnbound,
+}
+
+// maxIncludeDepth limits how deep the included files of a configuration may include other files.
+const maxIncludeDepth = 10
+
+// migration is a Corefile converted from the configuration of another DNS server.
+type migration struct {
+	blocks  []*migrateBlock
+	skipped []string // the parts of the configuration that could not be converted
+}
+
+// migrateBlock is a server block in the converted Corefile.
+type migrateBlock struct {
+	key   string
+	lines []string // directives, nested lines are indented
+}
+
+// block returns the server block with key, adding it when it does not exist yet.
+func (m *migration) block(key string) *migrateBlock {
+	for _, b := range m.blocks {
+		if b.key == key {
+			return b
+		}
+	}
+	b := &migrateBlock{key: key}
+	m.blocks = append(m.blocks, b)
+	return b
+}
+
+// skip records a part of the configuration at file:line that could not be converted.
+func (m *migration) skip(file string, line int, format string, a ...interface{}) {
+	m.skipped = append(m.skipped, fmt.Sprintf("%s:%d: %s", file, line, fmt.Sprintf(format, a...)))
+}
+
+func (b *migrateBlock) add(lines ...string) { b.lines = append(b.lines, lines...) }
+
+// migrateTemplate returns a template directive answering queries of qtype in zone with answers, or with
+// rcode when there are no answers. When name is not empty only that name is matched. With
+// fall, the queries not matched go to the next template or plugin.
+func migrateTemplate(qtype, zone, name string, answers []string, rcode string, fall bool) []string {
+	lines := []string{fmt.Sprintf("template IN %s %s {", qtype, zone)}
+	if name != "" {
+		lines = append(lines, "    match ^"+regexp.QuoteMeta(strings.ToLower(name))+"$")
+	}
+	for _, a := range answers {
+		lines = append(lines, "    answer \""+strings.Replace(a, "\"", "\\\"", -1)+"\"")
+	}
+	if rcode != "" {
+		lines = append(lines, "    rcode "+rcode)
+	}
+	if fall {
+		lines = append(lines, "    fallthrough")
+	}
+	return append(lines, "}")
+}
+
+func migrateCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	from := fs.String("from", "", "Format of the configuration: "+strings.Join(converterFormats(), ", ")+"; detected from the file name when empty")
+	out := fs.String("o", "", "File to write the Corefile to, defaults to standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: migrate [-from FORMAT] [-o FILE] CONFIG")
+	}
+
+	path := fs.Arg(0)
+	format := *from
+	if format == "" {
+		format = detectFormat(path)
+		if format == "" {
+			return fmt.Errorf("can't tell the format of %s, use -from", path)
+		}
+	}
+	convert, ok := converters[format]
+	if !ok {
+		return fmt.Errorf("unknown format %q, must be one of: %s", format, strings.Join(converterFormats(), ", "))
+	}
+
+	m, err := convert(path)
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writeMigration(w, m); err != nil {
+		return err
+	}
+	for _, s := range m.skipped {
+		fmt.Fprintf(os.Stderr, "not converted: %s\n", s)
+	}
+	return nil
+}
+
+func converterFormats() []string {
+	formats := make([]string, 0, len(converters))
+	for f := range converters {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// detectFormat returns the format of the configuration in path based on its file name.
+func detectFormat(path string) string {
+	name := filepath.Base(path)
+	switch {
+	case strings.HasPrefix(name, "named"):
+		return "bind"
+	case strings.HasPrefix(name, "dnsmasq"):
+		return "dnsmasq"
+	case strings.HasPrefix(name, "unbound"):
+		return "unbound"
+	}
+	return ""
+}
+
+// writeMigration writes m to w as a Corefile. The parts that could not be converted are listed in a
+// comment at the top.
+func writeMigration(w io.Writer, m *migration) error {
+	var b strings.Builder
+	if len(m.skipped) > 0 {
+		b.WriteString("# Not converted:\n")
+		for _, s := range m.skipped {
+			fmt.Fprintf(&b, "#   %s\n", s)
+		}
+		b.WriteString("\n")
+	}
+	for i, blk := range m.blocks {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s {\n", blk.key)
+		for _, l := range blk.lines {
+			fmt.Fprintf(&b, "    %s\n", l)
+		}
+		b.WriteString("}\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
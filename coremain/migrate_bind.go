@@ -0,0 +1,496 @@
+package coremain
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+)
+
+// bindStatement is a statement in a named.conf: words ended by a semicolon, optionally followed by a
+// block of statements, i.e. zone "example.org" { type master; };
+type bindStatement struct {
+	file  string
+	line  int
+	args  []string
+	block []bindStatement
+}
+
+func (s bindStatement) name() string { return s.args[0] }
+
+// values returns the arguments of the statements in the block of s, i.e. the addresses of an
+// address match list.
+func (s bindStatement) values() [][]string {
+	v := make([][]string, 0, len(s.block))
+	for _, b := range s.block {
+		v = append(v, b.args)
+	}
+	return v
+}
+
+// parseBind parses the named.conf at path, include statements are followed.
+func parseBind(path string, depth int) ([]bindStatement, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("%s: includes nested deeper than %d levels", path, maxIncludeDepth)
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	toks, err := bindTokens(path, string(buf))
+	if err != nil {
+		return nil, err
+	}
+	stmts, rest, err := bindStatements(path, toks)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%s:%d: unexpected %q", path, rest[0].line, rest[0].text)
+	}
+
+	// Replace the includes with the statements of the included files.
+	var out []bindStatement
+	for _, s := range stmts {
+		if s.name() != "include" || len(s.args) != 2 {
+			out = append(out, s)
+			continue
+		}
+		inc := s.args[1]
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		included, err := parseBind(inc, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, included...)
+	}
+	return out, nil
+}
+
+type bindToken struct {
+	text   string
+	line   int
+	quoted bool
+}
+
+// bindTokens splits a named.conf in tokens, comments in C, C++ and shell style are removed.
+func bindTokens(file, s string) ([]bindToken, error) {
+	var toks []bindToken
+	line := 1
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(s[i:], "//"):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("%s:%d: unterminated comment", file, line)
+			}
+			line += strings.Count(s[i:i+2+end], "\n")
+			i += end + 4
+		case c == '{' || c == '}' || c == ';':
+			toks = append(toks, bindToken{text: string(c), line: line})
+			i++
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("%s:%d: unterminated string", file, line)
+			}
+			toks = append(toks, bindToken{text: s[i+1 : i+1+end], line: line, quoted: true})
+			line += strings.Count(s[i:i+2+end], "\n")
+			i += end + 2
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\r\n{};\"#", rune(s[j])) && !strings.HasPrefix(s[j:], "//") && !strings.HasPrefix(s[j:], "/*") {
+				j++
+			}
+			toks = append(toks, bindToken{text: s[i:j], line: line})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+// bindStatements parses the statements in toks up to the first unmatched closing brace, it returns
+// the tokens from that brace on.
+func bindStatements(file string, toks []bindToken) ([]bindStatement, []bindToken, error) {
+	var stmts []bindStatement
+	for len(toks) > 0 {
+		if toks[0].text == "}" && !toks[0].quoted {
+			return stmts, toks, nil
+		}
+		s := bindStatement{file: file, line: toks[0].line}
+		for {
+			if len(toks) == 0 {
+				return nil, nil, fmt.Errorf("%s:%d: missing ';'", file, s.line)
+			}
+			t := toks[0]
+			toks = toks[1:]
+			if t.quoted {
+				s.args = append(s.args, t.text)
+				continue
+			}
+			if t.text == ";" {
+				break
+			}
+			if t.text == "}" {
+				return nil, nil, fmt.Errorf("%s:%d: unexpected '}'", file, t.line)
+			}
+			if t.text != "{" {
+				s.args = append(s.args, t.text)
+				continue
+			}
+			block, rest, err := bindStatements(file, toks)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(rest) == 0 {
+				return nil, nil, fmt.Errorf("%s:%d: missing '}'", file, t.line)
+			}
+			// Only the first block is kept, the statements with more than one block are not converted.
+			if s.block == nil {
+				s.block = block
+				if s.block == nil {
+					s.block = []bindStatement{}
+				}
+			}
+			toks = rest[1:]
+		}
+		if len(s.args) == 0 && s.block == nil {
+			continue // empty statement
+		}
+		if len(s.args) == 0 {
+			s.args = []string{""}
+		}
+		stmts = append(stmts, s)
+	}
+	return stmts, nil, nil
+}
+
+// bindConverter converts a named.conf to a Corefile.
+type bindConverter struct {
+	m         *migration
+	directory string
+	port      string
+	listen    []string
+	acls      map[string][][]string
+	primaries map[string][][]string
+	zones     map[string]bool
+}
+
+// migrateBind converts the named.conf at path. Zones of type master, slave and forward, forwarders,
+// ACLs used for zone transfers, the listen addresses and port are converted. Views are flattened,
+// the zones of all views are served to every client.
+func migrateBind(path string) (*migration, error) {
+	stmts, err := parseBind(path, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &bindConverter{
+		m:         &migration{},
+		acls:      map[string][][]string{},
+		primaries: map[string][][]string{},
+		zones:     map[string]bool{},
+	}
+
+	// The options, ACLs and primaries are used by the zones, these are converted first.
+	var options *bindStatement
+	for i, s := range stmts {
+		switch s.name() {
+		case "options":
+			options = &stmts[i]
+			c.listenOptions(s)
+		case "acl":
+			if len(s.args) == 2 && s.block != nil {
+				c.acls[s.args[1]] = s.values()
+			}
+		case "masters", "primaries":
+			if len(s.args) >= 2 && s.block != nil {
+				c.primaries[s.args[1]] = s.values()
+			}
+		}
+	}
+	if options != nil {
+		c.options(*options)
+	}
+
+	for _, s := range stmts {
+		switch s.name() {
+		case "options", "acl", "masters", "primaries":
+		case "zone":
+			c.zone(s, "")
+		case "view":
+			c.view(s)
+		default:
+			c.m.skip(s.file, s.line, "%s statement", s.name())
+		}
+	}
+	return c.m, nil
+}
+
+// listenOptions picks up the options needed to create a server block: directory, listen-on and
+// listen-on-v6.
+func (c *bindConverter) listenOptions(s bindStatement) {
+	for _, o := range s.block {
+		switch o.name() {
+		case "directory":
+			if len(o.args) == 2 {
+				c.directory = o.args[1]
+			}
+		case "listen-on", "listen-on-v6":
+			args := o.args[1:]
+			if len(args) == 2 && args[0] == "port" {
+				c.port = args[1]
+			} else if len(args) > 0 {
+				c.m.skip(o.file, o.line, "%s %s", o.name(), strings.Join(args, " "))
+			}
+			for _, v := range o.values() {
+				switch {
+				case len(v) == 1 && (v[0] == "any" || v[0] == "none"):
+				case len(v) == 1 && net.ParseIP(v[0]) != nil:
+					c.listen = append(c.listen, v[0])
+				default:
+					c.m.skip(o.file, o.line, "%s address %s", o.name(), strings.Join(v, " "))
+				}
+			}
+		}
+	}
+}
+
+func (c *bindConverter) options(s bindStatement) {
+	forwarders := []string{}
+	recursion := true
+	for _, o := range s.block {
+		switch o.name() {
+		case "directory", "listen-on", "listen-on-v6":
+		case "forwarders":
+			forwarders = c.servers(o)
+		case "forward":
+			// "only" is what forward does, with "first" we don't resolve ourselves when the forwarders fail.
+			if len(o.args) == 2 && o.args[1] != "only" {
+				c.m.skip(o.file, o.line, "forward %s, queries are only forwarded", o.args[1])
+			}
+		case "recursion":
+			recursion = len(o.args) == 2 && o.args[1] == "yes"
+		default:
+			c.m.skip(o.file, o.line, "options %s", o.name())
+		}
+	}
+	if len(forwarders) > 0 {
+		b := c.block(".")
+		b.add("forward . " + strings.Join(forwarders, " "))
+		c.zones["."] = true
+		return
+	}
+	if recursion {
+		c.m.skip(s.file, s.line, "recursion without forwarders, CoreDNS doesn't resolve recursively")
+	}
+}
+
+func (c *bindConverter) view(s bindStatement) {
+	name := ""
+	if len(s.args) > 1 {
+		name = s.args[1]
+	}
+	for _, v := range s.block {
+		switch v.name() {
+		case "zone":
+			c.zone(v, name)
+		case "match-clients", "match-destinations":
+			c.m.skip(v.file, v.line, "view %s %s, the zones of all views are served to every client", name, v.name())
+		default:
+			c.m.skip(v.file, v.line, "view %s %s", name, v.name())
+		}
+	}
+}
+
+func (c *bindConverter) zone(s bindStatement, view string) {
+	if len(s.args) < 2 || s.block == nil {
+		c.m.skip(s.file, s.line, "zone without name or options")
+		return
+	}
+	name := s.args[1]
+	if len(s.args) > 2 && !strings.EqualFold(s.args[2], "in") {
+		c.m.skip(s.file, s.line, "zone %s in class %s", name, s.args[2])
+		return
+	}
+	typ, file := "", ""
+	var transferTo, transferFrom, forwarders []string
+	for _, o := range s.block {
+		switch o.name() {
+		case "type":
+			if len(o.args) == 2 {
+				typ = o.args[1]
+			}
+		case "file":
+			if len(o.args) == 2 {
+				file = o.args[1]
+			}
+		case "allow-transfer", "also-notify":
+			transferTo = append(transferTo, c.addresses(o)...)
+		case "masters", "primaries":
+			transferFrom = c.servers(o)
+		case "forwarders":
+			forwarders = c.servers(o)
+		case "forward":
+			if len(o.args) == 2 && o.args[1] != "only" {
+				c.m.skip(o.file, o.line, "zone %s forward %s, queries are only forwarded", name, o.args[1])
+			}
+		default:
+			c.m.skip(o.file, o.line, "zone %s %s", name, o.name())
+		}
+	}
+
+	if typ == "hint" {
+		c.m.skip(s.file, s.line, "zone %s of type hint, CoreDNS doesn't resolve recursively", name)
+		return
+	}
+	if c.zones[name] {
+		if view != "" {
+			c.m.skip(s.file, s.line, "zone %s in view %s, the zone is already defined", name, view)
+		} else {
+			c.m.skip(s.file, s.line, "zone %s, the zone is already defined", name)
+		}
+		return
+	}
+
+	var lines []string
+	switch typ {
+	case "master", "primary":
+		if file == "" {
+			c.m.skip(s.file, s.line, "zone %s without file", name)
+			return
+		}
+		if !filepath.IsAbs(file) && c.directory != "" {
+			file = filepath.Join(c.directory, file)
+		}
+		lines = withTransfer("file "+file, transferTo)
+	case "slave", "secondary":
+		if len(transferFrom) == 0 {
+			c.m.skip(s.file, s.line, "zone %s without masters", name)
+			return
+		}
+		lines = append(lines, "secondary {", "    transfer from "+strings.Join(transferFrom, " "))
+		if len(transferTo) > 0 {
+			lines = append(lines, "    transfer to "+strings.Join(transferTo, " "))
+		}
+		lines = append(lines, "}")
+		if file != "" {
+			c.m.skip(s.file, s.line, "zone %s file %s, secondary zones are kept in memory", name, file)
+		}
+	case "forward":
+		if len(forwarders) == 0 {
+			c.m.skip(s.file, s.line, "zone %s without forwarders", name)
+			return
+		}
+		lines = append(lines, "forward . "+strings.Join(forwarders, " "))
+	default:
+		c.m.skip(s.file, s.line, "zone %s of type %s", name, typ)
+		return
+	}
+
+	c.zones[name] = true
+	c.block(name).add(lines...)
+}
+
+// withTransfer returns the directive line, with a block holding a transfer to when to is not empty.
+func withTransfer(line string, to []string) []string {
+	if len(to) == 0 {
+		return []string{line}
+	}
+	return []string{line + " {", "    transfer to " + strings.Join(to, " "), "}"}
+}
+
+// block returns the server block for zone, listening on the port and addresses from the options.
+func (c *bindConverter) block(zone string) *migrateBlock {
+	key := zone
+	if c.port != "" {
+		key += ":" + c.port
+	}
+	b := c.m.block(key)
+	if len(b.lines) == 0 && len(c.listen) > 0 {
+		b.add("bind " + strings.Join(c.listen, " "))
+	}
+	return b
+}
+
+// servers returns the servers in the block of s, like the forwarders or the masters of a zone, as
+// address:port. Named lists of primaries are expanded.
+func (c *bindConverter) servers(s bindStatement) []string {
+	port := ""
+	if len(s.args) == 3 && s.args[1] == "port" {
+		port = s.args[2]
+	}
+	return c.serverList(s, s.values(), port, 0)
+}
+
+func (c *bindConverter) serverList(s bindStatement, values [][]string, port string, depth int) []string {
+	var servers []string
+	for _, v := range values {
+		if len(v) == 0 {
+			continue
+		}
+		if list, ok := c.primaries[v[0]]; ok && depth < maxIncludeDepth {
+			servers = append(servers, c.serverList(s, list, port, depth+1)...)
+			continue
+		}
+		if net.ParseIP(v[0]) == nil {
+			c.m.skip(s.file, s.line, "%s %s", s.name(), strings.Join(v, " "))
+			continue
+		}
+		p := port
+		rest := v[1:]
+		if len(rest) >= 2 && rest[0] == "port" {
+			p, rest = rest[1], rest[2:]
+		}
+		if len(rest) > 0 {
+			c.m.skip(s.file, s.line, "%s %s: %s", s.name(), v[0], strings.Join(rest, " "))
+		}
+		if p != "" {
+			servers = append(servers, net.JoinHostPort(v[0], p))
+		} else {
+			servers = append(servers, v[0])
+		}
+	}
+	return servers
+}
+
+// addresses returns the addresses in the address match list of s, ACLs are expanded. Only plain
+// addresses and any can be converted, the rest is skipped.
+func (c *bindConverter) addresses(s bindStatement) []string {
+	return c.addressList(s, s.values(), 0)
+}
+
+func (c *bindConverter) addressList(s bindStatement, values [][]string, depth int) []string {
+	var addrs []string
+	for _, v := range values {
+		switch {
+		case len(v) != 1:
+			c.m.skip(s.file, s.line, "%s %s", s.name(), strings.Join(v, " "))
+		case v[0] == "none":
+		case v[0] == "any":
+			addrs = append(addrs, "*")
+		case net.ParseIP(v[0]) != nil:
+			addrs = append(addrs, v[0])
+		default:
+			if list, ok := c.acls[v[0]]; ok && depth < maxIncludeDepth {
+				addrs = append(addrs, c.addressList(s, list, depth+1)...)
+				continue
+			}
+			c.m.skip(s.file, s.line, "%s %s", s.name(), v[0])
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,314 @@
+package coremain
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dnsmasqOption is an option in a dnsmasq.conf, a line holding "key=value" or just "key".
+type dnsmasqOption struct {
+	file  string
+	line  int
+	key   string
+	value string
+}
+
+// parseDnsmasq parses the dnsmasq.conf at path, conf-file options are followed.
+func parseDnsmasq(path string, depth int) ([]dnsmasqOption, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("%s: conf-file nested deeper than %d levels", path, maxIncludeDepth)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var opts []dnsmasqOption
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		l := strings.TrimSpace(scanner.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		o := dnsmasqOption{file: path, line: line, key: l}
+		if i := strings.IndexByte(l, '='); i >= 0 {
+			o.key, o.value = strings.TrimSpace(l[:i]), strings.TrimSpace(l[i+1:])
+		}
+		if o.key != "conf-file" {
+			opts = append(opts, o)
+			continue
+		}
+		inc := o.value
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		included, err := parseDnsmasq(inc, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, included...)
+	}
+	return opts, scanner.Err()
+}
+
+// migrateDnsmasq converts the dnsmasq.conf at path. The upstream servers, including those for
+// specific domains, address, local, host-record and cname records, the hosts files, the listen
+// addresses and port, caching and query logging are converted.
+func migrateDnsmasq(path string) (*migration, error) {
+	opts, err := parseDnsmasq(path, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &migration{}
+	var (
+		port, resolv     = "", "/etc/resolv.conf"
+		listen, upstream []string
+		hosts            = []dnsmasqOption{{key: "addn-hosts", value: "/etc/hosts"}} // dnsmasq reads /etc/hosts by default
+		templates        []string
+		local            []string // templates for the local domains, these go last
+		cache, logging   = true, false
+		strict           = false
+		domains          []string // domains with their own upstream servers, in order
+		domainServers    = map[string][]string{}
+	)
+
+	for _, o := range opts {
+		switch o.key {
+		case "port":
+			port = o.value
+		case "listen-address":
+			for _, a := range strings.Split(o.value, ",") {
+				listen = append(listen, strings.TrimSpace(a))
+			}
+		case "no-resolv":
+			resolv = ""
+		case "resolv-file":
+			resolv = o.value
+		case "no-hosts":
+			if len(hosts) > 0 && hosts[0].file == "" {
+				hosts = hosts[1:]
+			}
+		case "addn-hosts":
+			hosts = append(hosts, o)
+		case "cache-size":
+			cache = o.value != "0"
+		case "log-queries":
+			logging = true
+		case "strict-order":
+			strict = true
+		case "server", "local":
+			doms, addr, ok := dnsmasqDomains(o.value)
+			if o.key == "local" {
+				addr = ""
+			}
+			if !ok {
+				upstream = append(upstream, dnsmasqServer(m, o, addr)...)
+				continue
+			}
+			for _, d := range doms {
+				if addr == "" {
+					// Only answered from the local records.
+					local = append(local, migrateTemplate("ANY", d, "", nil, "NXDOMAIN", false)...)
+					continue
+				}
+				if d == "." {
+					// All domains, like a server without one.
+					upstream = append(upstream, dnsmasqServer(m, o, addr)...)
+					continue
+				}
+				if _, ok := domainServers[d]; !ok {
+					domains = append(domains, d)
+				}
+				domainServers[d] = append(domainServers[d], dnsmasqServer(m, o, addr)...)
+			}
+		case "address":
+			doms, addr, ok := dnsmasqDomains(o.value)
+			if !ok {
+				m.skip(o.file, o.line, "address=%s", o.value)
+				continue
+			}
+			for _, d := range doms {
+				templates = append(templates, dnsmasqAddress(d, addr)...)
+			}
+		case "host-record":
+			templates = append(templates, dnsmasqHostRecord(m, o)...)
+		case "cname":
+			f := strings.Split(o.value, ",")
+			if len(f) < 2 {
+				m.skip(o.file, o.line, "cname=%s", o.value)
+				continue
+			}
+			target := dnsFqdn(f[len(f)-1])
+			for _, alias := range f[:len(f)-1] {
+				alias = dnsFqdn(alias)
+				templates = append(templates, migrateTemplate("ANY", alias, alias, []string{alias + " 0 IN CNAME " + target}, "", true)...)
+			}
+		default:
+			if o.value != "" {
+				m.skip(o.file, o.line, "%s=%s", o.key, o.value)
+			} else {
+				m.skip(o.file, o.line, "%s", o.key)
+			}
+		}
+	}
+	if resolv != "" {
+		upstream = append(upstream, resolv)
+	}
+
+	key := func(zone string) string {
+		if port != "" {
+			return zone + ":" + port
+		}
+		return zone
+	}
+	newBlock := func(zone string) *migrateBlock {
+		b := m.block(key(zone))
+		if len(listen) > 0 {
+			b.add("bind " + strings.Join(listen, " "))
+		}
+		if logging {
+			b.add("log")
+		}
+		if cache {
+			b.add("cache")
+		}
+		return b
+	}
+
+	b := newBlock(".")
+	if len(hosts) > 0 {
+		b.add("hosts "+hosts[0].value+" {", "    fallthrough", "}")
+		for _, h := range hosts[1:] {
+			m.skip(h.file, h.line, "addn-hosts=%s, only the hosts file %s is converted", h.value, hosts[0].value)
+		}
+	}
+	b.add(templates...)
+	b.add(local...)
+	if len(upstream) > 0 {
+		b.add(dnsmasqForward(upstream, strict)...)
+	}
+
+	for _, d := range domains {
+		newBlock(d).add(dnsmasqForward(domainServers[d], strict)...)
+	}
+	return m, nil
+}
+
+// dnsmasqDomains splits the value of a server, local or address option of the form /DOMAIN/.../ADDR
+// in the domains and the address. It returns false when the value does not start with a domain.
+func dnsmasqDomains(v string) ([]string, string, bool) {
+	if !strings.HasPrefix(v, "/") {
+		return nil, v, false
+	}
+	f := strings.Split(v[1:], "/")
+	doms := make([]string, 0, len(f)-1)
+	for _, d := range f[:len(f)-1] {
+		if d == "" || d == "#" {
+			d = "."
+		}
+		doms = append(doms, dnsFqdn(d))
+	}
+	return doms, f[len(f)-1], true
+}
+
+// dnsmasqServer returns the upstream server in addr, of the form IP[#PORT][@SOURCE].
+func dnsmasqServer(m *migration, o dnsmasqOption, addr string) []string {
+	if i := strings.IndexByte(addr, '@'); i >= 0 {
+		m.skip(o.file, o.line, "%s=%s: source address or interface %s", o.key, o.value, addr[i+1:])
+		addr = addr[:i]
+	}
+	port := ""
+	if i := strings.IndexByte(addr, '#'); i >= 0 {
+		addr, port = addr[:i], addr[i+1:]
+	}
+	if net.ParseIP(addr) == nil {
+		m.skip(o.file, o.line, "%s=%s", o.key, o.value)
+		return nil
+	}
+	if port != "" {
+		return []string{net.JoinHostPort(addr, port)}
+	}
+	return []string{addr}
+}
+
+func dnsmasqForward(to []string, strict bool) []string {
+	fwd := "forward . " + strings.Join(to, " ")
+	if !strict {
+		return []string{fwd}
+	}
+	return []string{fwd + " {", "    policy sequential", "}"}
+}
+
+// dnsmasqAddress returns the templates for an address option: the domain and all names below it
+// resolve to addr. An empty addr returns NXDOMAIN, # returns the unspecified addresses.
+func dnsmasqAddress(domain, addr string) []string {
+	switch addr {
+	case "":
+		return migrateTemplate("ANY", domain, "", nil, "NXDOMAIN", false)
+	case "#":
+		return append(migrateTemplate("A", domain, "", []string{"{{ .Name }} 0 IN A 0.0.0.0"}, "", true),
+			migrateTemplate("AAAA", domain, "", []string{"{{ .Name }} 0 IN AAAA ::"}, "", true)...)
+	}
+	if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+		return migrateTemplate("AAAA", domain, "", []string{"{{ .Name }} 0 IN AAAA " + addr}, "", true)
+	}
+	return migrateTemplate("A", domain, "", []string{"{{ .Name }} 0 IN A " + addr}, "", true)
+}
+
+// dnsmasqHostRecord returns the templates for a host-record option: NAME[,NAME...],IP[,IP...][,TTL].
+func dnsmasqHostRecord(m *migration, o dnsmasqOption) []string {
+	var names, a, aaaa []string
+	ttl := "0"
+	for _, f := range strings.Split(o.value, ",") {
+		f = strings.TrimSpace(f)
+		ip := net.ParseIP(f)
+		switch {
+		case ip != nil && ip.To4() != nil:
+			a = append(a, f)
+		case ip != nil:
+			aaaa = append(aaaa, f)
+		case strings.Trim(f, "0123456789") == "" && f != "":
+			ttl = f
+		default:
+			names = append(names, dnsFqdn(f))
+		}
+	}
+	if len(names) == 0 || len(a)+len(aaaa) == 0 {
+		m.skip(o.file, o.line, "host-record=%s", o.value)
+		return nil
+	}
+	var lines []string
+	for _, n := range names {
+		for _, x := range []struct {
+			qtype string
+			ips   []string
+		}{{"A", a}, {"AAAA", aaaa}} {
+			if len(x.ips) == 0 {
+				continue
+			}
+			var answers []string
+			for _, ip := range x.ips {
+				answers = append(answers, fmt.Sprintf("%s %s IN %s %s", n, ttl, x.qtype, ip))
+			}
+			lines = append(lines, migrateTemplate(x.qtype, n, n, answers, "", true)...)
+		}
+	}
+	return lines
+}
+
+// dnsFqdn returns the lower cased, fully qualified name.
+func dnsFqdn(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if !strings.HasSuffix(s, ".") {
+		s += "."
+	}
+	return s
+}
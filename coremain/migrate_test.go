@@ -0,0 +1,418 @@
+package coremain
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// migrateTest writes files to a temporary directory and converts first with convert. It returns the
+// Corefile and the parts not converted.
+func migrateTest(t *testing.T, convert func(string) (*migration, error), files map[string]string, first string) (string, []string) {
+	dir, err := ioutil.TempDir("", "coredns-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := convert(filepath.Join(dir, first))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	// Strip the directory from the skipped parts.
+	for i := range m.skipped {
+		m.skipped[i] = strings.TrimPrefix(m.skipped[i], dir+string(filepath.Separator))
+	}
+	skipped := m.skipped
+	m.skipped = nil
+	buf := &bytes.Buffer{}
+	if err := writeMigration(buf, m); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String(), skipped
+}
+
+func TestMigrateBind(t *testing.T) {
+	files := map[string]string{
+		"named.conf": `// BIND configuration
+include "named.conf.options";
+
+acl "secondaries" { 10.0.0.2; 10.0.0.3; };
+masters "upstream" { 192.0.2.1 port 5353; };
+
+zone "example.org" IN {
+	type master;
+	file "db.example.org";
+	allow-transfer { secondaries; 10.0.0.0/8; };
+	allow-update { none; };
+};
+
+zone "example.net" {
+	type slave;
+	masters { upstream; 192.0.2.2; };
+	file "/var/cache/bind/db.example.net";
+};
+
+/* a forward zone
+   spanning lines */
+zone "corp.example" { type forward; forwarders { 10.1.1.1; }; };
+
+zone "." { type hint; file "root.hints"; };
+
+view "internal" {
+	match-clients { 10.0.0.0/8; };
+	zone "internal.example" { type master; file "/etc/bind/db.internal"; };
+	zone "example.org" { type master; file "/etc/bind/db.example.org.internal"; };
+};
+
+logging { channel default_log { file "/var/log/named.log"; }; };
+`,
+		"named.conf.options": `options {
+	directory "/etc/bind";
+	listen-on port 5300 { 127.0.0.1; };
+	forwarders { 8.8.8.8; 8.8.4.4 port 5353; };
+	forward only;
+	dnssec-validation auto; # not supported
+};
+`,
+	}
+
+	corefile, skipped := migrateTest(t, migrateBind, files, "named.conf")
+
+	expected := `.:5300 {
+    bind 127.0.0.1
+    forward . 8.8.8.8 8.8.4.4:5353
+}
+
+example.org:5300 {
+    bind 127.0.0.1
+    file /etc/bind/db.example.org {
+        transfer to 10.0.0.2 10.0.0.3
+    }
+}
+
+example.net:5300 {
+    bind 127.0.0.1
+    secondary {
+        transfer from 192.0.2.1:5353 192.0.2.2
+    }
+}
+
+corp.example:5300 {
+    bind 127.0.0.1
+    forward . 10.1.1.1
+}
+
+internal.example:5300 {
+    bind 127.0.0.1
+    file /etc/bind/db.internal
+}
+`
+	if corefile != expected {
+		t.Errorf("Expected Corefile:\n%s\ngot:\n%s", expected, corefile)
+	}
+
+	expectSkipped(t, skipped, []string{
+		"named.conf.options:6: options dnssec-validation",
+		"named.conf:10: allow-transfer 10.0.0.0/8",
+		"named.conf:11: zone example.org allow-update",
+		"named.conf:14: zone example.net file /var/cache/bind/db.example.net, secondary zones are kept in memory",
+		"named.conf:24: zone . of type hint, CoreDNS doesn't resolve recursively",
+		"named.conf:27: view internal match-clients, the zones of all views are served to every client",
+		"named.conf:29: zone example.org in view internal, the zone is already defined",
+		"named.conf:32: logging statement",
+	})
+}
+
+func TestMigrateBindErrors(t *testing.T) {
+	for _, conf := range []string{
+		`zone "example.org" { type master; `,
+		`zone "example.org { type master; };`,
+		`/* unterminated`,
+		`options { directory "/etc"; }; }`,
+	} {
+		dir, err := ioutil.TempDir("", "coredns-migrate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "named.conf")
+		if err := ioutil.WriteFile(path, []byte(conf), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := migrateBind(path); err == nil {
+			t.Errorf("Expected an error for %q", conf)
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	for path, format := range map[string]string{
+		"/etc/bind/named.conf": "bind",
+		"named.conf.local":     "bind",
+		"/etc/dnsmasq.conf":    "dnsmasq",
+		"unbound.conf":         "unbound",
+		"Corefile":             "",
+	} {
+		if got := detectFormat(path); got != format {
+			t.Errorf("Expected format %q for %s, got %q", format, path, got)
+		}
+	}
+}
+
+func TestMigrateDnsmasq(t *testing.T) {
+	files := map[string]string{
+		"dnsmasq.conf": `# dnsmasq configuration
+port=5300
+listen-address=127.0.0.1
+no-resolv
+server=8.8.8.8
+server=8.8.4.4#5353
+server=/corp.example/10.1.1.1
+local=/lan/
+address=/ads.example/0.0.0.0
+address=/blocked.example/
+host-record=router.lan,192.168.1.1
+cname=nas.lan,router.lan
+no-hosts
+addn-hosts=/etc/hosts.lan
+log-queries
+domain-needed
+conf-file=extra.conf
+`,
+		"extra.conf": "dhcp-range=192.168.1.50,192.168.1.150,12h\n",
+	}
+
+	corefile, skipped := migrateTest(t, migrateDnsmasq, files, "dnsmasq.conf")
+
+	expected := `.:5300 {
+    bind 127.0.0.1
+    log
+    cache
+    hosts /etc/hosts.lan {
+        fallthrough
+    }
+    template IN A ads.example. {
+        answer "{{ .Name }} 0 IN A 0.0.0.0"
+        fallthrough
+    }
+    template IN ANY blocked.example. {
+        rcode NXDOMAIN
+    }
+    template IN A router.lan. {
+        match ^router\.lan\.$
+        answer "router.lan. 0 IN A 192.168.1.1"
+        fallthrough
+    }
+    template IN ANY nas.lan. {
+        match ^nas\.lan\.$
+        answer "nas.lan. 0 IN CNAME router.lan."
+        fallthrough
+    }
+    template IN ANY lan. {
+        rcode NXDOMAIN
+    }
+    forward . 8.8.8.8 8.8.4.4:5353
+}
+
+corp.example.:5300 {
+    bind 127.0.0.1
+    log
+    cache
+    forward . 10.1.1.1
+}
+`
+	if corefile != expected {
+		t.Errorf("Expected Corefile:\n%s\ngot:\n%s", expected, corefile)
+	}
+	expectSkipped(t, skipped, []string{
+		"dnsmasq.conf:16: domain-needed",
+		"extra.conf:1: dhcp-range=192.168.1.50,192.168.1.150,12h",
+	})
+}
+
+func TestMigrateDnsmasqRootServer(t *testing.T) {
+	files := map[string]string{"dnsmasq.conf": "no-resolv\nno-hosts\nserver=/#/1.1.1.1\nserver=/corp.example/10.1.1.1\n"}
+	corefile, _ := migrateTest(t, migrateDnsmasq, files, "dnsmasq.conf")
+
+	expected := `. {
+    cache
+    forward . 1.1.1.1
+}
+
+corp.example. {
+    cache
+    forward . 10.1.1.1
+}
+`
+	if corefile != expected {
+		t.Errorf("Expected Corefile:\n%s\ngot:\n%s", expected, corefile)
+	}
+}
+
+func TestMigrateUnbound(t *testing.T) {
+	files := map[string]string{
+		"unbound.conf": `server:
+    interface: 127.0.0.1@5300
+    verbosity: 1
+    local-zone: "home.example." static
+    local-data: "nas.home.example. IN A 192.168.1.10"
+    local-data: 'nas.home.example. TXT "storage # box"'
+    local-data-ptr: "192.168.1.10 nas.home.example"
+    local-zone: "ads.example" refuse
+
+include: "forward.conf"
+
+auth-zone:
+    name: "example.org"
+    zonefile: "/etc/unbound/example.org.zone"
+
+remote-control:
+    control-enable: yes
+`,
+		"forward.conf": `forward-zone:
+    name: "."
+    forward-addr: 1.1.1.1@853#cloudflare-dns.com
+    forward-addr: 1.0.0.1@853#cloudflare-dns.com
+    forward-tls-upstream: yes
+`,
+	}
+
+	corefile, skipped := migrateTest(t, migrateUnbound, files, "unbound.conf")
+
+	expected := `.:5300 {
+    bind 127.0.0.1
+    cache
+    template IN A nas.home.example. {
+        match ^nas\.home\.example\.$
+        answer "nas.home.example. 3600 IN A 192.168.1.10"
+        fallthrough
+    }
+    template IN TXT nas.home.example. {
+        match ^nas\.home\.example\.$
+        answer "nas.home.example. 3600 IN TXT \"storage # box\""
+        fallthrough
+    }
+    template IN PTR 10.1.168.192.in-addr.arpa. {
+        match ^10\.1\.168\.192\.in-addr\.arpa\.$
+        answer "10.1.168.192.in-addr.arpa. 3600 IN PTR nas.home.example."
+        fallthrough
+    }
+    template IN ANY nas.home.example. {
+        match ^nas\.home\.example\.$
+        rcode NOERROR
+        fallthrough
+    }
+    template IN ANY 10.1.168.192.in-addr.arpa. {
+        match ^10\.1\.168\.192\.in-addr\.arpa\.$
+        rcode NOERROR
+        fallthrough
+    }
+    template IN ANY home.example. {
+        rcode NXDOMAIN
+    }
+    template IN ANY ads.example. {
+        rcode REFUSED
+    }
+    forward . tls://1.1.1.1:853 tls://1.0.0.1:853 {
+        tls_servername cloudflare-dns.com
+    }
+}
+
+example.org.:5300 {
+    bind 127.0.0.1
+    cache
+    file /etc/unbound/example.org.zone
+}
+`
+	if corefile != expected {
+		t.Errorf("Expected Corefile:\n%s\ngot:\n%s", expected, corefile)
+	}
+	expectSkipped(t, skipped, []string{
+		"unbound.conf:3: verbosity: 1",
+		"unbound.conf:16: remote-control clause",
+	})
+}
+
+func TestMigrateUnboundZoneData(t *testing.T) {
+	files := map[string]string{
+		"unbound.conf": `server:
+    local-data: "nas.home.lan. IN A 192.168.1.10"
+    local-data: "printer.lan. IN A 192.168.1.20"
+    local-zone: "ads.home.lan" refuse
+
+forward-zone:
+    name: "."
+    forward-addr: 1.1.1.1
+
+forward-zone:
+    name: "home.lan"
+    forward-addr: 192.168.1.1
+`,
+	}
+
+	corefile, _ := migrateTest(t, migrateUnbound, files, "unbound.conf")
+
+	expected := `. {
+    cache
+    template IN A printer.lan. {
+        match ^printer\.lan\.$
+        answer "printer.lan. 3600 IN A 192.168.1.20"
+        fallthrough
+    }
+    template IN ANY printer.lan. {
+        match ^printer\.lan\.$
+        rcode NOERROR
+        fallthrough
+    }
+    forward . 1.1.1.1
+}
+
+home.lan. {
+    cache
+    template IN A nas.home.lan. {
+        match ^nas\.home\.lan\.$
+        answer "nas.home.lan. 3600 IN A 192.168.1.10"
+        fallthrough
+    }
+    template IN ANY nas.home.lan. {
+        match ^nas\.home\.lan\.$
+        rcode NOERROR
+        fallthrough
+    }
+    template IN ANY ads.home.lan. {
+        rcode REFUSED
+    }
+    forward . 192.168.1.1
+}
+`
+	if corefile != expected {
+		t.Errorf("Expected Corefile:\n%s\ngot:\n%s", expected, corefile)
+	}
+}
+
+func TestMigrateUnboundRecursion(t *testing.T) {
+	corefile, skipped := migrateTest(t, migrateUnbound, map[string]string{"unbound.conf": "server:\n    port: 53\n"}, "unbound.conf")
+	if corefile != "" {
+		t.Errorf("Expected an empty Corefile, got:\n%s", corefile)
+	}
+	expectSkipped(t, skipped, []string{"unbound.conf:1: recursion without a forward-zone for ., CoreDNS doesn't resolve recursively"})
+}
+
+// expectSkipped checks that all of expected are in skipped.
+func expectSkipped(t *testing.T, skipped, expected []string) {
+	t.Helper()
+	for _, s := range expected {
+		if !contains(skipped, s) {
+			t.Errorf("Expected %q to be reported as not converted, got %q", s, skipped)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+package coremain
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// unboundClause is a clause in an unbound.conf, i.e. "server:" or "forward-zone:", with its options.
+type unboundClause struct {
+	file string
+	line int
+	name string
+	opts []unboundOption
+}
+
+// unboundOption is a "key: value" line in an unbound.conf clause.
+type unboundOption struct {
+	file  string
+	line  int
+	key   string
+	value string
+}
+
+// unboundParser parses an unbound.conf, included files continue the clause they are included in.
+type unboundParser struct {
+	clauses []*unboundClause
+}
+
+// parseUnbound parses the unbound.conf at path, include options are followed.
+func parseUnbound(path string) ([]*unboundClause, error) {
+	p := &unboundParser{}
+	if err := p.parse(path, 0); err != nil {
+		return nil, err
+	}
+	return p.clauses, nil
+}
+
+func (p *unboundParser) parse(path string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: includes nested deeper than %d levels", path, maxIncludeDepth)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		l := strings.TrimSpace(unboundComment(scanner.Text()))
+		if l == "" {
+			continue
+		}
+		i := strings.IndexByte(l, ':')
+		if i < 0 {
+			return fmt.Errorf("%s:%d: expected \"key: value\", got %q", path, line, l)
+		}
+		key, value := strings.TrimSpace(l[:i]), unquote(strings.TrimSpace(l[i+1:]))
+
+		if key == "include" || key == "include-toplevel" {
+			if !filepath.IsAbs(value) {
+				value = filepath.Join(filepath.Dir(path), value)
+			}
+			files, err := filepath.Glob(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %s", path, line, err)
+			}
+			for _, inc := range files {
+				if err := p.parse(inc, depth+1); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if value == "" {
+			p.clauses = append(p.clauses, &unboundClause{file: path, line: line, name: key})
+			continue
+		}
+		if len(p.clauses) == 0 {
+			return fmt.Errorf("%s:%d: option %s outside of a clause", path, line, key)
+		}
+		c := p.clauses[len(p.clauses)-1]
+		c.opts = append(c.opts, unboundOption{file: path, line: line, key: key, value: value})
+	}
+	return scanner.Err()
+}
+
+// unboundComment removes a comment from l. Like unbound, only a # at the start of a word starts a
+// comment, so "1.1.1.1@853#cloudflare-dns.com" is kept, as is a # in a quoted string.
+func unboundComment(l string) string {
+	quote := byte(0)
+	for i := 0; i < len(l); i++ {
+		switch {
+		case quote != 0 && l[i] == quote:
+			quote = 0
+		case quote == 0 && (l[i] == '"' || l[i] == '\''):
+			quote = l[i]
+		case quote == 0 && l[i] == '#' && (i == 0 || l[i-1] == ' ' || l[i-1] == '\t'):
+			return l[:i]
+		}
+	}
+	return l
+}
+
+// unquote removes the double or single quotes around s.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// unboundConverter converts an unbound.conf to a Corefile.
+type unboundConverter struct {
+	m      *migration
+	port   string
+	listen []string
+
+	zones   []unboundLocalZone
+	data    []dns.RR        // local data, in order
+	defined map[string]bool // zones with a server block
+}
+
+type unboundLocalZone struct {
+	o    unboundOption
+	name string
+	typ  string
+}
+
+// migrateUnbound converts the unbound.conf at path. The port and interfaces, local zones and local
+// data, forward, stub and auth zones are converted. As unbound caches, all server blocks cache.
+func migrateUnbound(path string) (*migration, error) {
+	clauses, err := parseUnbound(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &unboundConverter{m: &migration{}, defined: map[string]bool{}}
+
+	// The server clauses set the port and addresses for all server blocks, convert these first.
+	var server *unboundClause
+	for _, cl := range clauses {
+		if cl.name == "server" {
+			c.server(cl)
+			if server == nil {
+				server = cl
+			}
+		}
+	}
+
+	// The root zone comes first, it holds the local data outside of the other zones.
+	root := c.block(".")
+
+	for _, cl := range clauses {
+		switch cl.name {
+		case "server":
+		case "forward-zone", "stub-zone":
+			c.forwardZone(cl)
+		case "auth-zone":
+			c.authZone(cl)
+		default:
+			c.m.skip(cl.file, cl.line, "%s clause", cl.name)
+		}
+	}
+
+	// The local data goes in the most specific server block holding its names, the less specific ones
+	// never see the queries for them. It goes before the other directives of the block.
+	for zone, lines := range c.localData() {
+		b := c.block(zone)
+		n := c.preamble()
+		b.lines = append(b.lines[:n], append(lines, b.lines[n:]...)...)
+	}
+
+	if !c.defined["."] {
+		file, line := path, 1
+		if server != nil {
+			file, line = server.file, server.line
+		}
+		c.m.skip(file, line, "recursion without a forward-zone for ., CoreDNS doesn't resolve recursively")
+	}
+	// Drop the root server block when it doesn't do anything.
+	if len(root.lines) == c.preamble() {
+		c.m.blocks = c.m.blocks[1:]
+	}
+	return c.m, nil
+}
+
+func (c *unboundConverter) server(cl *unboundClause) {
+	for _, o := range cl.opts {
+		switch o.key {
+		case "port":
+			c.port = o.value
+		case "interface":
+			addr := o.value
+			if i := strings.IndexByte(addr, '@'); i >= 0 {
+				if c.port == "" {
+					c.port = addr[i+1:]
+				}
+				addr = addr[:i]
+			}
+			ip := net.ParseIP(addr)
+			switch {
+			case ip == nil:
+				c.m.skip(o.file, o.line, "interface: %s", o.value)
+			case !ip.IsUnspecified():
+				c.listen = append(c.listen, addr)
+			}
+		case "local-zone":
+			f := strings.Fields(o.value)
+			if len(f) != 2 {
+				c.m.skip(o.file, o.line, "local-zone: %s", o.value)
+				continue
+			}
+			c.zones = append(c.zones, unboundLocalZone{o: o, name: dnsFqdn(unquote(f[0])), typ: f[1]})
+		case "local-data":
+			rr, err := dns.NewRR(o.value)
+			if err != nil || rr == nil {
+				c.m.skip(o.file, o.line, "local-data: %s", o.value)
+				continue
+			}
+			c.data = append(c.data, rr)
+		case "local-data-ptr":
+			f := strings.Fields(o.value)
+			if len(f) != 2 {
+				c.m.skip(o.file, o.line, "local-data-ptr: %s", o.value)
+				continue
+			}
+			rev, err := dns.ReverseAddr(f[0])
+			if err != nil {
+				c.m.skip(o.file, o.line, "local-data-ptr: %s", o.value)
+				continue
+			}
+			c.data = append(c.data, &dns.PTR{Hdr: dns.RR_Header{Name: rev, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: 3600}, Ptr: dnsFqdn(f[1])})
+		default:
+			c.m.skip(o.file, o.line, "%s: %s", o.key, o.value)
+		}
+	}
+}
+
+// block returns the server block for zone, listening on the port and addresses from the server clause.
+func (c *unboundConverter) block(zone string) *migrateBlock {
+	key := zone
+	if c.port != "" {
+		key += ":" + c.port
+	}
+	b := c.m.block(key)
+	if len(b.lines) == 0 {
+		if len(c.listen) > 0 {
+			b.add("bind " + strings.Join(c.listen, " "))
+		}
+		b.add("cache")
+	}
+	return b
+}
+
+// preamble returns the number of lines block adds to a new server block.
+func (c *unboundConverter) preamble() int {
+	if len(c.listen) > 0 {
+		return 2
+	}
+	return 1
+}
+
+// localData returns the templates answering with the local data and for the local zones, keyed by
+// the zone of the server block they go in. Names that have local data return NODATA for the other
+// types, except in typetransparent zones.
+func (c *unboundConverter) localData() map[string][]string {
+	var (
+		lines = map[string][]string{}
+		names []string
+		rrs   = map[string][]dns.RR{} // keyed by name and type
+		keys  []string
+	)
+	for _, rr := range c.data {
+		name := strings.ToLower(rr.Header().Name)
+		k := name + " " + dns.TypeToString[rr.Header().Rrtype]
+		if _, ok := rrs[k]; !ok {
+			keys = append(keys, k)
+		}
+		rrs[k] = append(rrs[k], rr)
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, k := range keys {
+		f := strings.Fields(k)
+		var answers []string
+		for _, rr := range rrs[k] {
+			answers = append(answers, strings.Replace(rr.String(), "\t", " ", -1))
+		}
+		zone := c.blockZone(f[0])
+		lines[zone] = append(lines[zone], migrateTemplate(f[1], f[0], f[0], answers, "", true)...)
+	}
+	for _, n := range names {
+		if z := c.localZone(n); z != nil && z.typ == "typetransparent" {
+			continue
+		}
+		zone := c.blockZone(n)
+		lines[zone] = append(lines[zone], migrateTemplate("ANY", n, n, nil, "NOERROR", true)...)
+	}
+
+	for _, z := range c.zones {
+		zone := c.blockZone(z.name)
+		switch z.typ {
+		case "static", "always_nxdomain":
+			lines[zone] = append(lines[zone], migrateTemplate("ANY", z.name, "", nil, "NXDOMAIN", false)...)
+		case "refuse", "always_refuse":
+			lines[zone] = append(lines[zone], migrateTemplate("ANY", z.name, "", nil, "REFUSED", false)...)
+		case "always_nodata":
+			lines[zone] = append(lines[zone], migrateTemplate("ANY", z.name, "", nil, "NOERROR", false)...)
+		case "transparent", "typetransparent", "always_transparent", "nodefault":
+		default:
+			c.m.skip(z.o.file, z.o.line, "local-zone: %s of type %s", z.name, z.typ)
+		}
+	}
+	return lines
+}
+
+// blockZone returns the zone of the most specific server block name is in: a forward, stub or auth
+// zone, or the root zone.
+func (c *unboundConverter) blockZone(name string) string {
+	zone := "."
+	for z := range c.defined {
+		if dns.IsSubDomain(z, name) && dns.CountLabel(z) > dns.CountLabel(zone) {
+			zone = z
+		}
+	}
+	return zone
+}
+
+// localZone returns the most specific local zone name is in, or nil if there is none.
+func (c *unboundConverter) localZone(name string) *unboundLocalZone {
+	var zone *unboundLocalZone
+	for i, z := range c.zones {
+		if dns.IsSubDomain(z.name, name) && (zone == nil || dns.CountLabel(z.name) > dns.CountLabel(zone.name)) {
+			zone = &c.zones[i]
+		}
+	}
+	return zone
+}
+
+func (c *unboundConverter) forwardZone(cl *unboundClause) {
+	name, tls, servername := "", false, ""
+	var addrs []string
+	for _, o := range cl.opts {
+		switch o.key {
+		case "name":
+			name = dnsFqdn(o.value)
+		case "forward-addr", "stub-addr":
+			addr := o.value
+			if i := strings.IndexByte(addr, '#'); i >= 0 {
+				if servername != "" && servername != addr[i+1:] {
+					c.m.skip(o.file, o.line, "%s: %s, only one TLS server name is supported", o.key, o.value)
+				} else {
+					servername = addr[i+1:]
+				}
+				addr = addr[:i]
+			}
+			port := ""
+			if i := strings.IndexByte(addr, '@'); i >= 0 {
+				addr, port = addr[:i], addr[i+1:]
+			}
+			if net.ParseIP(addr) == nil {
+				c.m.skip(o.file, o.line, "%s: %s", o.key, o.value)
+				continue
+			}
+			if port != "" {
+				addr = net.JoinHostPort(addr, port)
+			}
+			addrs = append(addrs, addr)
+		case "forward-tls-upstream", "stub-tls-upstream":
+			tls = o.value == "yes"
+		default:
+			c.m.skip(o.file, o.line, "%s %s: %s", cl.name, o.key, o.value)
+		}
+	}
+	if name == "" || len(addrs) == 0 {
+		c.m.skip(cl.file, cl.line, "%s without name or addresses", cl.name)
+		return
+	}
+	if c.defined[name] {
+		c.m.skip(cl.file, cl.line, "%s %s, the zone is already defined", cl.name, name)
+		return
+	}
+	c.defined[name] = true
+
+	if tls {
+		for i := range addrs {
+			addrs[i] = "tls://" + addrs[i]
+		}
+	}
+	b := c.block(name)
+	if cl.name == "stub-zone" {
+		b.add("# stub-zone, the queries are forwarded to the stub servers")
+	}
+	fwd := "forward . " + strings.Join(addrs, " ")
+	if tls && servername != "" {
+		b.add(fwd+" {", "    tls_servername "+servername, "}")
+		return
+	}
+	b.add(fwd)
+}
+
+func (c *unboundConverter) authZone(cl *unboundClause) {
+	name, file := "", ""
+	var primaries []string
+	for _, o := range cl.opts {
+		switch o.key {
+		case "name":
+			name = dnsFqdn(o.value)
+		case "zonefile":
+			file = o.value
+		case "primary", "master":
+			addr := o.value
+			if i := strings.IndexByte(addr, '@'); i >= 0 {
+				addr = net.JoinHostPort(addr[:i], addr[i+1:])
+			}
+			primaries = append(primaries, addr)
+		default:
+			c.m.skip(o.file, o.line, "auth-zone %s: %s", o.key, o.value)
+		}
+	}
+	if name == "" || (file == "" && len(primaries) == 0) {
+		c.m.skip(cl.file, cl.line, "auth-zone without name, zonefile or primary")
+		return
+	}
+	if c.defined[name] {
+		c.m.skip(cl.file, cl.line, "auth-zone %s, the zone is already defined", name)
+		return
+	}
+	c.defined[name] = true
+
+	b := c.block(name)
+	if len(primaries) > 0 {
+		b.add("secondary {", "    transfer from "+strings.Join(primaries, " "), "}")
+		if file != "" {
+			c.m.skip(cl.file, cl.line, "auth-zone %s zonefile %s, secondary zones are kept in memory", name, file)
+		}
+		return
+	}
+	b.add("file " + file)
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
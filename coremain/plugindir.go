@@ -0,0 +1,25 @@
+// +build linux,cgo darwin,cgo freebsd,cgo
+
+package coremain
+
+import (
+	"fmt"
+	"path/filepath"
+	goplugin "plugin"
+)
+
+// loadPlugins opens all Go plugins (*.so files) found in dir. Opening a plugin runs its init
+// functions, these are expected to register the plugin with caddy.RegisterPlugin and its directive
+// with dnsserver.RegisterDirective.
+func loadPlugins(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if _, err := goplugin.Open(f); err != nil {
+			return nil, fmt.Errorf("failed to load plugin %s: %s", f, err)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,10 @@
+// +build !linux,!darwin,!freebsd !cgo
+
+package coremain
+
+import "fmt"
+
+// loadPlugins returns an error, Go plugins are not supported on this platform.
+func loadPlugins(dir string) ([]string, error) {
+	return nil, fmt.Errorf("loading plugins from %s: Go plugins are not supported on this platform", dir)
+}
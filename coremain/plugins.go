@@ -0,0 +1,120 @@
+package coremain
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	commands["plugins"] = command{usage: "plugins [-json] [-plugindir DIR]: list the plugins compiled in", run: pluginsCommand}
+}
+
+// mainModule is the module CoreDNS itself is built from, plugins from other modules are external.
+const mainModule = "github.com/coredns/coredns"
+
+// PluginInfo describes a plugin compiled into CoreDNS.
+type PluginInfo struct {
+	Name     string `json:"name"`
+	Package  string `json:"package"`
+	Module   string `json:"module"`
+	Version  string `json:"version"`
+	External bool   `json:"external"`
+}
+
+func pluginsCommand(args []string) error {
+	fs := flag.NewFlagSet("plugins", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Output JSON")
+	dir := fs.String("plugindir", "", "Directory to load Go plugins (*.so) from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir != "" {
+		if _, err := loadPlugins(*dir); err != nil {
+			return err
+		}
+	}
+
+	bi, _ := debug.ReadBuildInfo()
+	return writePlugins(os.Stdout, listPlugins(bi), *asJSON)
+}
+
+// listPlugins returns the plugins for all directives that have a plugin compiled in, in the order of
+// the directives. bi is used to look up the module versions and may be nil.
+func listPlugins(bi *debug.BuildInfo) []PluginInfo {
+	var infos []PluginInfo
+	for _, dir := range dnsserver.Directives {
+		setup, err := caddy.DirectiveAction(serverType, dir)
+		if err != nil {
+			continue
+		}
+		pkg := funcPackage(runtime.FuncForPC(reflect.ValueOf(setup).Pointer()).Name())
+		info := PluginInfo{Name: dir, Package: pkg, Module: mainModule, Version: coreVersion()}
+		if !inModule(pkg, mainModule) {
+			info.External = true
+			info.Module, info.Version = "", ""
+		}
+		if bi != nil && info.External {
+			for _, m := range bi.Deps {
+				if !inModule(pkg, m.Path) || len(m.Path) <= len(info.Module) {
+					continue
+				}
+				info.Module, info.Version = m.Path, m.Version
+				if m.Replace != nil {
+					info.Version = m.Replace.Path + " " + m.Replace.Version
+				}
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func writePlugins(w io.Writer, infos []PluginInfo, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPACKAGE\tVERSION\tEXTERNAL")
+	for _, i := range infos {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\n", i.Name, i.Package, i.Version, i.External)
+	}
+	return tw.Flush()
+}
+
+// coreVersion returns the version of CoreDNS, with the commit if known.
+func coreVersion() string {
+	if GitCommit == "" {
+		return CoreVersion
+	}
+	return CoreVersion + "+" + GitCommit
+}
+
+// funcPackage returns the package path of the fully qualified function name fn, i.e.
+// "github.com/coredns/coredns/plugin/cache.setup" returns "github.com/coredns/coredns/plugin/cache".
+func funcPackage(fn string) string {
+	slash := strings.LastIndex(fn, "/")
+	if dot := strings.Index(fn[slash+1:], "."); dot >= 0 {
+		return fn[:slash+1+dot]
+	}
+	return fn
+}
+
+// inModule returns true if the package pkg is part of module mod.
+func inModule(pkg, mod string) bool {
+	return pkg == mod || strings.HasPrefix(pkg, mod+"/")
+}
@@ -0,0 +1,54 @@
+package coremain
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestListPlugins(t *testing.T) {
+	caddy.RegisterPlugin("whoami", caddy.Plugin{
+		ServerType: "dns",
+		Action:     func(c *caddy.Controller) error { return nil },
+	})
+
+	infos := listPlugins(nil)
+	if len(infos) != 1 {
+		t.Fatalf("Expected 1 plugin, got %d", len(infos))
+	}
+	expected := PluginInfo{Name: "whoami", Package: "github.com/coredns/coredns/coremain", Module: mainModule, Version: coreVersion()}
+	if infos[0] != expected {
+		t.Errorf("Expected %v, got %v", expected, infos[0])
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writePlugins(buf, infos, true); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	var decoded []PluginInfo
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %s", err)
+	}
+	if len(decoded) != 1 || decoded[0] != expected {
+		t.Errorf("Expected %v, got %v", infos, decoded)
+	}
+}
+
+func TestFuncPackage(t *testing.T) {
+	tests := []struct {
+		fn       string
+		expected string
+	}{
+		{"github.com/coredns/coredns/plugin/cache.setup", "github.com/coredns/coredns/plugin/cache"},
+		{"github.com/example/plugin.v2/foo.setup.func1", "github.com/example/plugin.v2/foo"},
+		{"github.com/example/foo.(*Foo).setup", "github.com/example/foo"},
+		{"main.setup", "main"},
+	}
+	for i, tc := range tests {
+		if x := funcPackage(tc.fn); x != tc.expected {
+			t.Errorf("Test %d: Expected %s, got %s", i, tc.expected, x)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package coremain
+
+import (
+	"flag"
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+func init() {
+	flag.StringVar(&runUser, "user", "", "User (name or uid) to run as after binding the listeners")
+	flag.StringVar(&runGroup, "group", "", "Group (name or gid) to run as after binding the listeners, defaults to the group of -user")
+	flag.StringVar(&chrootDir, "chroot", "", "Directory to chroot to after binding the listeners")
+}
+
+var (
+	runUser   string
+	runGroup  string
+	chrootDir string
+)
+
+// dropPrivileges chroots to chrootDir and changes to runUser and runGroup, when set. It is called
+// once the servers listen, so privileged ports can be bound as root.
+func dropPrivileges() error {
+	if runUser == "" && runGroup == "" && chrootDir == "" {
+		return nil
+	}
+	uid, gid, err := lookupIDs(runUser, runGroup)
+	if err != nil {
+		return err
+	}
+	return setPrivileges(uid, gid, chrootDir)
+}
+
+// lookupIDs returns the uid and gid for name and group, both may be names or numeric ids. A value of
+// -1 means the id should not be changed. When group is empty, the primary group of name is used.
+func lookupIDs(name, group string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if name != "" {
+		u, err := user.Lookup(name)
+		if err != nil {
+			if u, err = user.LookupId(name); err != nil {
+				return -1, -1, fmt.Errorf("unknown user %q", name)
+			}
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return -1, -1, fmt.Errorf("user %q: non-numeric uid %q", name, u.Uid)
+		}
+		if gid, err = strconv.Atoi(u.Gid); err != nil {
+			return -1, -1, fmt.Errorf("user %q: non-numeric gid %q", name, u.Gid)
+		}
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return -1, -1, fmt.Errorf("unknown group %q", group)
+			}
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return -1, -1, fmt.Errorf("group %q: non-numeric gid %q", group, g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
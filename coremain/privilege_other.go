@@ -0,0 +1,10 @@
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package coremain
+
+import "fmt"
+
+// setPrivileges returns an error, dropping privileges is not supported on this platform.
+func setPrivileges(uid, gid int, dir string) error {
+	return fmt.Errorf("-user, -group and -chroot are not supported on this platform")
+}
@@ -0,0 +1,37 @@
+package coremain
+
+import "testing"
+
+func TestLookupIDs(t *testing.T) {
+	tests := []struct {
+		user      string
+		group     string
+		uid       int
+		gid       int
+		shouldErr bool
+	}{
+		{"", "", -1, -1, false},
+		{"root", "", 0, 0, false},
+		{"0", "", 0, 0, false},
+		{"root", "0", 0, 0, false},
+		{"", "root", -1, 0, false},
+		{"coredns-no-such-user", "", -1, -1, true},
+		{"", "coredns-no-such-group", -1, -1, true},
+	}
+	for i, tc := range tests {
+		uid, gid, err := lookupIDs(tc.user, tc.group)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got %s", i, err)
+			continue
+		}
+		if uid != tc.uid || gid != tc.gid {
+			t.Errorf("Test %d: Expected %d:%d, got %d:%d", i, tc.uid, tc.gid, uid, gid)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package coremain
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// setPrivileges chroots to dir and sets the gid and uid of the process, in that order. An empty dir or
+// an id of -1 is left alone.
+func setPrivileges(uid, gid int, dir string) error {
+	if dir != "" {
+		if err := syscall.Chroot(dir); err != nil {
+			return fmt.Errorf("chroot to %s: %s", dir, err)
+		}
+		if err := syscall.Chdir("/"); err != nil {
+			return err
+		}
+	}
+	if gid != -1 {
+		if err := syscall.Setgroups([]int{gid}); err != nil {
+			return fmt.Errorf("setgroups to %d: %s", gid, err)
+		}
+		if err := syscall.Setgid(gid); err != nil {
+			return fmt.Errorf("setgid to %d: %s", gid, err)
+		}
+	}
+	if uid != -1 {
+		if err := syscall.Setuid(uid); err != nil {
+			return fmt.Errorf("setuid to %d: %s", uid, err)
+		}
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/coredns/coredns/core/corefile"
 	"github.com/coredns/coredns/core/dnsserver"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 
@@ -21,11 +22,16 @@ func init() {
 	caddy.Quiet = true // don't show init stuff from caddy
 	setVersion()
 
-	flag.StringVar(&conf, "conf", "", "Corefile to load (default \""+caddy.DefaultConfigFile+"\")")
+	flag.Var(&conf, "conf", "Corefile or directory of Corefiles to load, may be repeated (default \""+caddy.DefaultConfigFile+"\")")
 	flag.BoolVar(&plugins, "plugins", false, "List installed plugins")
+	flag.StringVar(&pluginDir, "plugindir", "", "Directory to load Go plugins (*.so) from")
 	flag.StringVar(&caddy.PidFile, "pidfile", "", "Path to write pid file")
 	flag.BoolVar(&version, "version", false, "Show version")
 	flag.BoolVar(&dnsserver.Quiet, "quiet", false, "Quiet mode (no initialization output)")
+	flag.BoolVar(&dnsserver.SoftFail, "softfail", false, "Answer with SERVFAIL for a server block whose zone data or backend fails to load, instead of exiting")
+	flag.StringVar(&loader.ImportCache, "importcache", "", "Directory to cache Corefile imports from URLs in, defaults to the user cache directory")
+	flag.DurationVar(&loader.ImportTTL, "importttl", corefile.DefaultImportTTL, "Time a Corefile import from a URL is used without checking for a new version")
+	flag.StringVar(&loader.ImportKey, "importkey", "", "File with the base64 encoded ed25519 public key Corefile imports from URLs must be signed with")
 
 	caddy.RegisterCaddyfileLoader("flag", caddy.LoaderFunc(confLoader))
 	caddy.SetDefaultCaddyfileLoader("default", caddy.LoaderFunc(defaultLoader))
@@ -36,6 +42,10 @@ func init() {
 
 // Run is CoreDNS's main() function.
 func Run() {
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
 	caddy.TrapSignals()
 
 	// Reset flag.CommandLine to get rid of unwanted flags for instance from glog (used in kubernetes).
@@ -48,6 +58,11 @@ func Run() {
 	})
 
 	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprint(os.Stderr, commandsUsage())
+	}
 	for _, f := range flagsToKeep {
 		flag.Var(f.Value, f.Name, f.Usage)
 	}
@@ -61,6 +76,16 @@ func Run() {
 	log.SetOutput(os.Stdout)
 	log.SetFlags(0) // Set to 0 because we're doing our own time, with timezone
 
+	if pluginDir != "" {
+		loaded, err := loadPlugins(pluginDir)
+		if err != nil {
+			mustLogFatal(err)
+		}
+		for _, l := range loaded {
+			clog.Infof("Loaded plugin from %s", l)
+		}
+	}
+
 	if version {
 		showVersion()
 		os.Exit(0)
@@ -70,15 +95,37 @@ func Run() {
 		os.Exit(0)
 	}
 
+	if err := checkStartupFormat(); err != nil {
+		mustLogFatal(err)
+	}
+
+	if runService(serve) {
+		return
+	}
+	serve()
+}
+
+// serve loads the Corefile and starts the servers, it blocks until they are stopped.
+func serve() {
 	// Get Corefile input
 	corefile, err := caddy.LoadCaddyfile(serverType)
 	if err != nil {
-		mustLogFatal(err)
+		mustExit(startError{Class: "config", Code: exitConfig, Err: err.Error()})
 	}
 
 	// Start your engines
 	instance, err := caddy.Start(corefile)
 	if err != nil {
+		mustExit(classify(err, corefile))
+	}
+
+	if controlPath != "" {
+		if _, err := listenControl(controlPath); err != nil {
+			mustExit(classify(err, nil))
+		}
+	}
+
+	if err := dropPrivileges(); err != nil {
 		mustLogFatal(err)
 	}
 
@@ -86,11 +133,19 @@ func Run() {
 	if !dnsserver.Quiet {
 		showVersion()
 	}
+	if startupFormat == "json" {
+		if err := writeStartup(os.Stdout, instance); err != nil {
+			mustLogFatal(err)
+		}
+	}
 
 	// Twiddle your thumbs
 	instance.Wait()
 }
 
+// inService is true when running as a Windows service, there is no terminal to log to.
+var inService bool
+
 // mustLogFatal wraps log.Fatal() in a way that ensures the
 // output is always printed to stderr so the user can see it
 // if the user is still there, even if the process log was not
@@ -98,7 +153,7 @@ func Run() {
 // might not be there anymore, this just logs to the process
 // log and exits.
 func mustLogFatal(args ...interface{}) {
-	if !caddy.IsUpgrade() {
+	if !caddy.IsUpgrade() && !inService {
 		log.SetOutput(os.Stderr)
 	}
 	log.Fatal(args...)
@@ -106,23 +161,23 @@ func mustLogFatal(args ...interface{}) {
 
 // confLoader loads the Caddyfile using the -conf flag.
 func confLoader(serverType string) (caddy.Input, error) {
-	if conf == "" {
+	if len(conf) == 0 {
 		return nil, nil
 	}
 
-	if conf == "stdin" {
+	if len(conf) == 1 && conf[0] == "stdin" {
 		return caddy.CaddyfileFromPipe(os.Stdin, serverType)
 	}
 
-	contents, err := ioutil.ReadFile(conf)
+	contents, err := readConf(conf)
+	if err != nil {
+		return nil, err
+	}
+	in, err := loader.Input(contents, confPath(conf), serverType)
 	if err != nil {
 		return nil, err
 	}
-	return caddy.CaddyfileInput{
-		Contents:       contents,
-		Filepath:       conf,
-		ServerTypeName: serverType,
-	}, nil
+	return corefile.WithPaths(in, conf), nil
 }
 
 // defaultLoader loads the Corefile from the current working directory.
@@ -134,11 +189,7 @@ func defaultLoader(serverType string) (caddy.Input, error) {
 		}
 		return nil, err
 	}
-	return caddy.CaddyfileInput{
-		Contents:       contents,
-		Filepath:       caddy.DefaultConfigFile,
-		ServerTypeName: serverType,
-	}, nil
+	return loader.Input(contents, caddy.DefaultConfigFile, serverType)
 }
 
 // logVersion logs the version that is starting.
@@ -188,10 +239,12 @@ func setVersion() {
 
 // Flags that control program flow or startup
 var (
-	conf    string
-	logfile bool
-	version bool
-	plugins bool
+	conf      confList
+	logfile   bool
+	version   bool
+	plugins   bool
+	pluginDir string
+	loader    corefile.Loader // expands the Corefile, set with the -import* flags
 )
 
 // Build information obtained with the help of -ldflags
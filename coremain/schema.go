@@ -0,0 +1,177 @@
+package coremain
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+)
+
+func init() {
+	commands["schema"] = command{usage: "schema [-plugindir DIR]: print the syntax of all directives as JSON", run: schemaCommand}
+}
+
+// directiveSchema is the syntax of a directive.
+type directiveSchema struct {
+	Name       string       `json:"name"`
+	Documented bool         `json:"documented"` // false when there is no syntax for the directive
+	Forms      []syntaxForm `json:"forms"`
+}
+
+// syntaxForm is one of the ways a directive can be used.
+type syntaxForm struct {
+	Syntax string           `json:"syntax"` // the form as documented
+	Args   []syntaxArg      `json:"args"`
+	Block  []syntaxProperty `json:"block,omitempty"`
+}
+
+// syntaxProperty is a property in the block of a directive.
+type syntaxProperty struct {
+	Name string      `json:"name,omitempty"` // empty when the line has no fixed keyword
+	Args []syntaxArg `json:"args"`
+}
+
+// syntaxArg is an argument of a directive or property.
+type syntaxArg struct {
+	Name     string   `json:"name"`
+	Kind     string   `json:"kind"` // literal, value or choice
+	Choices  []string `json:"choices,omitempty"`
+	Optional bool     `json:"optional,omitempty"`
+	Repeated bool     `json:"repeated,omitempty"`
+}
+
+func schemaCommand(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	dir := fs.String("plugindir", "", "Directory to load Go plugins (*.so) from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir != "" {
+		if _, err := loadPlugins(*dir); err != nil {
+			return err
+		}
+	}
+	return writeSchema(os.Stdout, schema())
+}
+
+// schema returns the syntax of all directives with a plugin compiled in, in the order of the
+// directives. The syntax is taken from dnsserver.DirectiveSyntax.
+func schema() []directiveSchema {
+	var ds []directiveSchema
+	for _, p := range listPlugins(nil) {
+		forms := parseSyntax(p.Name, dnsserver.DirectiveSyntax[p.Name])
+		ds = append(ds, directiveSchema{Name: p.Name, Documented: len(forms) > 0, Forms: forms})
+	}
+	return ds
+}
+
+func writeSchema(w io.Writer, ds []directiveSchema) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Directives []directiveSchema `json:"directives"`
+	}{ds})
+}
+
+// parseSyntax parses the documented syntax s of directive name. Each line starting with name is a
+// form, a form ending in "{" holds the properties up to the closing "}". Lines that don't start with
+// name are ignored, as are the forms without any placeholders or optional arguments, as those are
+// examples.
+func parseSyntax(name, s string) []syntaxForm {
+	forms := []syntaxForm{}
+	lines := strings.Split(s, "\n")
+	for i := 0; i < len(lines); i++ {
+		toks := strings.Fields(lines[i])
+		if len(toks) == 0 || toks[0] != name || strings.TrimLeft(lines[i], " \t") != lines[i] {
+			continue
+		}
+
+		f := syntaxForm{Syntax: strings.Join(toks, " "), Args: []syntaxArg{}}
+		toks = toks[1:]
+		if len(toks) > 0 && toks[len(toks)-1] == "{" {
+			for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "}"; i++ {
+				p := strings.Fields(lines[i])
+				if len(p) == 0 {
+					continue
+				}
+				f.Syntax += "\n    " + strings.Join(p, " ")
+				prop := syntaxProperty{Args: parseArgs(p)}
+				if prop.Args[0].Kind == "literal" && !prop.Args[0].Optional {
+					prop.Name, prop.Args = prop.Args[0].Name, prop.Args[1:]
+				}
+				f.Block = append(f.Block, prop)
+			}
+			f.Syntax += "\n}"
+			toks = toks[:len(toks)-1]
+		}
+		f.Args = append(f.Args, parseArgs(toks)...)
+
+		if len(f.Args) == 0 && len(f.Block) == 0 || isSyntax(f) {
+			forms = append(forms, f)
+		}
+	}
+	return forms
+}
+
+// parseArgs parses the arguments in toks: an argument in brackets is optional, one ending in "..."
+// or followed by "..." may be repeated, an upper case argument is a value to fill in and lower case
+// alternatives separated by "|" are a choice.
+func parseArgs(toks []string) []syntaxArg {
+	args := []syntaxArg{}
+	depth := 0
+	for _, t := range toks {
+		open := len(t) - len(strings.TrimLeft(t, "["))
+		t = t[open:]
+		depth += open
+		close := len(t) - len(strings.TrimRight(t, "]"))
+		t = t[:len(t)-close]
+
+		if t == "..." {
+			if len(args) > 0 {
+				args[len(args)-1].Repeated = true
+			}
+			depth -= close
+			continue
+		}
+		if t == "" {
+			depth -= close
+			continue
+		}
+
+		a := syntaxArg{Name: strings.TrimSuffix(t, "..."), Optional: depth > 0, Repeated: strings.HasSuffix(t, "...")}
+		switch {
+		case isPlaceholder(a.Name):
+			a.Kind = "value"
+		case strings.Contains(a.Name, "|"):
+			a.Kind = "choice"
+			a.Choices = strings.Split(a.Name, "|")
+		default:
+			a.Kind = "literal"
+		}
+		args = append(args, a)
+		depth -= close
+	}
+	return args
+}
+
+// isPlaceholder returns true if s is a value to fill in, those are written in upper case.
+func isPlaceholder(s string) bool {
+	return strings.ToUpper(s) == s && strings.ToLower(s) != s
+}
+
+// isSyntax returns true if f has a placeholder or optional argument.
+func isSyntax(f syntaxForm) bool {
+	all := f.Args
+	for _, p := range f.Block {
+		all = append(all, p.Args...)
+	}
+	for _, a := range all {
+		if a.Optional || a.Kind == "value" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package coremain
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+)
+
+func TestParseSyntax(t *testing.T) {
+	syntax := `cache [TTL] [ZONES...]
+
+cache [TTL] [ZONES...] {
+    success CAPACITY [TTL] [MINTTL]
+    prefetch AMOUNT [[DURATION] [PERCENTAGE%]]
+    serve_stale
+}
+
+* **TTL** max TTL in seconds.
+
+cache 10 example.org`
+
+	forms := parseSyntax("cache", syntax)
+	if len(forms) != 2 {
+		t.Fatalf("Expected 2 forms, got %d: %v", len(forms), forms)
+	}
+
+	zones := syntaxArg{Name: "ZONES", Kind: "value", Optional: true, Repeated: true}
+	ttl := syntaxArg{Name: "TTL", Kind: "value", Optional: true}
+	if expected := []syntaxArg{ttl, zones}; !reflect.DeepEqual(forms[0].Args, expected) {
+		t.Errorf("Expected args %v, got %v", expected, forms[0].Args)
+	}
+	if forms[0].Block != nil {
+		t.Errorf("Expected no block, got %v", forms[0].Block)
+	}
+
+	expected := []syntaxProperty{
+		{Name: "success", Args: []syntaxArg{{Name: "CAPACITY", Kind: "value"}, ttl, {Name: "MINTTL", Kind: "value", Optional: true}}},
+		{Name: "prefetch", Args: []syntaxArg{{Name: "AMOUNT", Kind: "value"}, {Name: "DURATION", Kind: "value", Optional: true}, {Name: "PERCENTAGE%", Kind: "value", Optional: true}}},
+		{Name: "serve_stale", Args: []syntaxArg{}},
+	}
+	if !reflect.DeepEqual(forms[1].Block, expected) {
+		t.Errorf("Expected block %v, got %v", expected, forms[1].Block)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		toks     []string
+		expected []syntaxArg
+	}{
+		{[]string{"ADDRESS", "..."}, []syntaxArg{{Name: "ADDRESS", Kind: "value", Repeated: true}}},
+		{[]string{"PLUGIN", "before|after", "OTHER"}, []syntaxArg{
+			{Name: "PLUGIN", Kind: "value"},
+			{Name: "before|after", Kind: "choice", Choices: []string{"before", "after"}},
+			{Name: "OTHER", Kind: "value"},
+		}},
+		{[]string{"[FILE", "[ZONES...]]"}, []syntaxArg{
+			{Name: "FILE", Kind: "value", Optional: true},
+			{Name: "ZONES", Kind: "value", Optional: true, Repeated: true},
+		}},
+		{[]string{"SOCKET", "[full]"}, []syntaxArg{{Name: "SOCKET", Kind: "value"}, {Name: "full", Kind: "literal", Optional: true}}},
+	}
+	for i, tc := range tests {
+		if got := parseArgs(tc.toks); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.expected, got)
+		}
+	}
+}
+
+func TestParseSyntaxExamples(t *testing.T) {
+	// Forms without placeholders are examples, but a bare directive is not.
+	forms := parseSyntax("errors", "errors\n\nerrors {\n    consolidate DURATION REGEXP\n}\n\nerrors stdout")
+	if len(forms) != 2 {
+		t.Fatalf("Expected 2 forms, got %d: %v", len(forms), forms)
+	}
+	if forms[0].Syntax != "errors" {
+		t.Errorf("Expected first form %q, got %q", "errors", forms[0].Syntax)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	ds := schema()
+	if len(ds) != len(listPlugins(nil)) {
+		t.Fatalf("Expected %d directives, got %d", len(listPlugins(nil)), len(ds))
+	}
+	// The generator fails for a plugin in plugin.cfg without syntax.
+	directives := map[string]bool{}
+	for _, name := range dnsserver.Directives {
+		directives[name] = true
+	}
+	for _, d := range ds {
+		if directives[d.Name] && !d.Documented {
+			t.Errorf("Expected a form for directive %q", d.Name)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeSchema(buf, ds); err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Directives []directiveSchema `json:"directives"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("Expected valid JSON, got %s", err)
+	}
+	if !reflect.DeepEqual(out.Directives, ds) {
+		t.Errorf("Expected the schema to round trip through JSON")
+	}
+}
@@ -0,0 +1,6 @@
+// +build !windows
+
+package coremain
+
+// runService returns false, services are only supported on Windows.
+func runService(serve func()) bool { return false }
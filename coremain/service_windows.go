@@ -0,0 +1,211 @@
+// +build windows
+
+package coremain
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+func init() {
+	flag.StringVar(&serviceName, "service", defaultServiceName, "Name of the Windows service, used when started by the service manager")
+	commands["service"] = command{usage: "service [-name NAME] install|uninstall|start|stop [FLAGS...]: manage the Windows service", run: serviceCommand}
+}
+
+const defaultServiceName = "coredns"
+
+// serviceStopTimeout is the time we wait for the service to stop.
+const serviceStopTimeout = 30 * time.Second
+
+var serviceName string
+
+// runService runs serve under the service manager when we have been started by it, and returns true
+// when serve has returned. Logging goes to the event log.
+func runService(serve func()) bool {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil || interactive {
+		return false
+	}
+	inService = true
+
+	el, err := eventlog.Open(serviceName)
+	if err != nil {
+		mustLogFatal(err)
+	}
+	defer el.Close()
+	log.SetOutput(eventLogWriter{el})
+
+	if err := svc.Run(serviceName, &serviceHandler{serve: serve}); err != nil {
+		mustLogFatal(err)
+	}
+	return true
+}
+
+// serviceHandler implements svc.Handler.
+type serviceHandler struct {
+	serve func()
+}
+
+// Execute implements the svc.Handler interface. A stop or shutdown request from the service manager
+// runs the shutdown callbacks and gracefully stops the servers.
+func (h *serviceHandler) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	s <- svc.Status{State: svc.StartPending}
+
+	done := make(chan struct{})
+	go func() {
+		h.serve()
+		close(done)
+	}()
+	s <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case <-done:
+			return false, 0
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s <- svc.Status{State: svc.StopPending}
+				for _, i := range caddy.Instances() {
+					for _, err := range i.ShutdownCallbacks() {
+						log.Printf("[ERROR] Shutdown callback: %s", err)
+					}
+				}
+				if err := caddy.Stop(); err != nil {
+					log.Printf("[ERROR] Stopping servers: %s", err)
+				}
+				<-done
+				return false, 0
+			}
+		}
+	}
+}
+
+// eventLogWriter writes the log to the event log, the level of each line determines the event type.
+type eventLogWriter struct {
+	el *eventlog.Log
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	msg := string(p)
+	var err error
+	switch {
+	case strings.Contains(msg, "[ERROR] "), strings.Contains(msg, "[FATAL] "):
+		err = w.el.Error(1, msg)
+	case strings.Contains(msg, "[WARNING] "):
+		err = w.el.Warning(1, msg)
+	default:
+		err = w.el.Info(1, msg)
+	}
+	return len(p), err
+}
+
+func serviceCommand(args []string) error {
+	fs := flag.NewFlagSet("service", flag.ContinueOnError)
+	name := fs.String("name", defaultServiceName, "Name of the service")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: service [-name NAME] install|uninstall|start|stop [FLAGS...]")
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	action, rest := fs.Arg(0), fs.Args()[1:]
+	if action == "install" {
+		return installService(m, *name, rest)
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("extra arguments for %s: %s", action, rest)
+	}
+
+	s, err := m.OpenService(*name)
+	if err != nil {
+		return fmt.Errorf("service %s: %s", *name, err)
+	}
+	defer s.Close()
+
+	switch action {
+	case "uninstall":
+		if err := s.Delete(); err != nil {
+			return err
+		}
+		return eventlog.Remove(*name)
+	case "start":
+		return s.Start()
+	case "stop":
+		return stopService(s)
+	}
+	return fmt.Errorf("unknown action %q", action)
+}
+
+// installService installs the running executable as service name, flags are the command line flags
+// the service is started with, i.e. -conf C:\coredns\Corefile.
+func installService(m *mgr.Mgr, name string, flags []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	exe, err = filepath.Abs(exe)
+	if err != nil {
+		return err
+	}
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", name)
+	}
+
+	c := mgr.Config{
+		DisplayName: "CoreDNS",
+		Description: "CoreDNS DNS server",
+		StartType:   mgr.StartAutomatic,
+	}
+	s, err := m.CreateService(name, exe, c, append(flags, "-service", name)...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if err := eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return err
+	}
+	return nil
+}
+
+// stopService asks the service manager to stop s and waits until it has stopped.
+func stopService(s *mgr.Service) error {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+	timeout := time.Now().Add(serviceStopTimeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(timeout) {
+			return errors.New("timeout waiting for the service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
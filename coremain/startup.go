@@ -0,0 +1,70 @@
+package coremain
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	flag.StringVar(&startupFormat, "startup", "text", "Format of the startup output: text or json")
+}
+
+var startupFormat string
+
+// startupInfo is the startup output in JSON.
+type startupInfo struct {
+	Version   string                 `json:"version"`
+	Commit    string                 `json:"commit"`
+	GoVersion string                 `json:"go_version"`
+	OS        string                 `json:"os"`
+	Arch      string                 `json:"arch"`
+	Listeners []listenerInfo         `json:"listeners"`
+	Servers   []dnsserver.ServerInfo `json:"servers"`
+}
+
+// listenerInfo describes a socket we listen on.
+type listenerInfo struct {
+	Network string `json:"network"`
+	Address string `json:"address"`
+}
+
+// checkStartupFormat checks the -startup flag and silences the text output when JSON is requested.
+func checkStartupFormat() error {
+	switch startupFormat {
+	case "text":
+	case "json":
+		dnsserver.Quiet = true
+	default:
+		return fmt.Errorf("unknown startup format %q, must be text or json", startupFormat)
+	}
+	return nil
+}
+
+// writeStartup writes the JSON startup information for instance i to w as a single line.
+func writeStartup(w io.Writer, i *caddy.Instance) error {
+	info := startupInfo{
+		Version:   CoreVersion,
+		Commit:    GitCommit,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Listeners: []listenerInfo{},
+		Servers:   dnsserver.Describe(i),
+	}
+	for _, s := range i.Servers() {
+		for _, a := range []net.Addr{s.Addr(), s.LocalAddr()} {
+			if a != nil {
+				info.Listeners = append(info.Listeners, listenerInfo{Network: a.Network(), Address: a.String()})
+			}
+		}
+	}
+	return json.NewEncoder(w).Encode(info)
+}
@@ -0,0 +1,43 @@
+package coremain
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestWriteStartup(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := writeStartup(buf, &caddy.Instance{}); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if bytes.Count(buf.Bytes(), []byte("\n")) != 1 {
+		t.Errorf("Expected a single line, got %q", buf.String())
+	}
+	var info startupInfo
+	if err := json.Unmarshal(buf.Bytes(), &info); err != nil {
+		t.Fatalf("Expected valid JSON, got %s", err)
+	}
+	if info.Version != CoreVersion {
+		t.Errorf("Expected version %s, got %s", CoreVersion, info.Version)
+	}
+}
+
+func TestCheckStartupFormat(t *testing.T) {
+	defer func(f string, q bool) { startupFormat, dnsserver.Quiet = f, q }(startupFormat, dnsserver.Quiet)
+
+	for _, f := range []string{"text", "json"} {
+		startupFormat = f
+		if err := checkStartupFormat(); err != nil {
+			t.Errorf("Expected no error for %s, got %s", f, err)
+		}
+	}
+	startupFormat = "yaml"
+	if err := checkStartupFormat(); err == nil {
+		t.Errorf("Expected error for yaml, got none")
+	}
+}
@@ -8,12 +8,15 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 func main() {
 	mi := make(map[string]string, 0)
 	md := []string{}
+	ms := make(map[string]string, 0)
 
 	file, err := os.Open(pluginFile)
 	if err != nil {
@@ -45,11 +48,18 @@ func main() {
 
 		if _, err := os.Stat(pluginFSPath + repo); err != nil { // External package has been given
 			mi[name] = repo
+			ms[name] = externalSyntax[name]
+			continue
+		}
+		readme := filepath.Join(pluginFSPath+repo, "README.md")
+		if ms[name] = readSyntax(readme, name); ms[name] == "" {
+			log.Fatalf("No code block in the Syntax section of %s", readme)
 		}
 	}
 
 	genImports("core/plugin/zplugin.go", "plugin", mi)
 	genDirectives("core/dnsserver/zdirectives.go", "dnsserver", md)
+	genSyntax("core/dnsserver/zsyntax.go", "dnsserver", md, ms)
 }
 
 func genImports(file, pack string, mi map[string]string) {
@@ -95,6 +105,75 @@ var Directives = []string{
 	}
 }
 
+func genSyntax(file, pack string, md []string, ms map[string]string) {
+	outs := header + "package " + pack + "\n\n"
+	outs += `
+// DirectiveSyntax holds the forms in the Syntax section of the README of each directive,
+// keyed by directive name. External plugins are only included when known to the generator.
+var DirectiveSyntax = map[string]string{
+`
+
+	for _, name := range md {
+		if s := ms[name]; s != "" {
+			outs += strconv.Quote(name) + ": " + strconv.Quote(s) + ",\n"
+		}
+	}
+
+	outs += "}\n"
+
+	if err := formatAndWrite(file, outs); err != nil {
+		log.Fatalf("Failed to format and write: %q", err)
+	}
+}
+
+// readSyntax returns the code blocks in the Syntax section of the README of the directive name at
+// path: the first one, and those right after it that are forms of the directive too. The later ones
+// are examples, or the syntax of something else.
+func readSyntax(path, name string) string {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	var syntax, block []string
+	section, code := false, false
+	for _, line := range strings.Split(string(buf), "\n") {
+		switch {
+		case strings.HasPrefix(line, "## "):
+			if section {
+				return strings.TrimSpace(strings.Join(syntax, "\n"))
+			}
+			section = strings.TrimSpace(line[3:]) == "Syntax"
+		case !section:
+		case strings.HasPrefix(line, "~~~") || strings.HasPrefix(line, "```"):
+			if code = !code; code {
+				block = block[:0]
+				continue
+			}
+			if len(syntax) > 0 && !isForm(block, name) {
+				return strings.TrimSpace(strings.Join(syntax, "\n"))
+			}
+			syntax = append(append(syntax, block...), "")
+		case code:
+			block = append(block, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(syntax, "\n"))
+}
+
+// isForm returns true when the lines of a code block are a form of the directive name: they start with
+// name, and have a placeholder, in upper case, or an optional part. Examples have neither.
+func isForm(lines []string, name string) bool {
+	text := strings.Join(lines, " ")
+	f := strings.Fields(text)
+	return len(f) > 0 && f[0] == name && strings.ContainsAny(text, "[ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+// externalSyntax holds the syntax of the external plugins in plugin.cfg, which have no README here.
+var externalSyntax = map[string]string{
+	"on": "on startup|shutdown|certrenew COMMAND [ARGS...]",
+}
+
 func formatAndWrite(file string, data string) error {
 	res, err := format.Source([]byte(data))
 	if err != nil {
@@ -0,0 +1,59 @@
+// Package admin serves an HTTP API to administer a running CoreDNS, such as flushing the answers
+// cached by its plugins.
+package admin
+
+import (
+	"net"
+	"net/http"
+	"sync"
+
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/uniq"
+)
+
+var (
+	log      = clog.NewWithPlugin("admin")
+	flushers = &list{}
+	uniqAddr = uniq.New()
+)
+
+type admin struct {
+	Addr string
+
+	sync.Mutex
+	ln   net.Listener
+	done bool
+}
+
+func (a *admin) onStartup() error {
+	ln, err := net.Listen("tcp", a.Addr)
+	if err != nil {
+		return err
+	}
+
+	a.Lock()
+	a.ln = ln
+	a.done = true
+	a.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(flushPath, serveFlush)
+
+	go func() { http.Serve(ln, mux) }()
+
+	return nil
+}
+
+func (a *admin) onFinalShutdown() error {
+	a.Lock()
+	defer a.Unlock()
+	if !a.done {
+		return nil
+	}
+
+	uniqAddr.Unset(a.Addr)
+
+	a.ln.Close()
+	a.done = false
+	return nil
+}
@@ -0,0 +1,104 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// The Flusher interface needs to be implemented by each plugin caching answers that can be flushed.
+type Flusher interface {
+	// Flush removes the answers for name, and its subdomains when subtree is true, of type qtype,
+	// or of any type when qtype is dns.TypeNone. It returns how many were removed.
+	Flush(name string, subtree bool, qtype uint16) int
+}
+
+// flushPath is the URL path of the flushes.
+const flushPath = "/cache/flush"
+
+// list holds the flushers of the server blocks with admin.
+type list struct {
+	sync.RWMutex
+	fs    []Flusher
+	names []string
+}
+
+// Append adds a new flusher to l.
+func (l *list) Append(f Flusher, name string) {
+	l.Lock()
+	defer l.Unlock()
+	l.fs = append(l.fs, f)
+	l.names = append(l.names, name)
+}
+
+// Reset removes all the flushers from l.
+func (l *list) Reset() {
+	l.Lock()
+	defer l.Unlock()
+	l.fs, l.names = nil, nil
+}
+
+// Flush calls Flush of all the flushers in l, and returns how many answers were removed.
+func (l *list) Flush(name string, subtree bool, qtype uint16) int {
+	l.RLock()
+	defer l.RUnlock()
+	n := 0
+	for _, f := range l.fs {
+		n += f.Flush(name, subtree, qtype)
+	}
+	return n
+}
+
+// serveFlush flushes the answers selected by the query parameters name, subtree and type, all of
+// them when there is no name.
+func serveFlush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, subtree, qtype, err := parseFlush(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n := flushers.Flush(name, subtree, qtype)
+	log.Infof("Flushed %d answers for %s (subtree %t, type %s)", n, name, subtree, dns.Type(qtype))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Flushed int `json:"flushed"`
+	}{n})
+}
+
+func parseFlush(r *http.Request) (name string, subtree bool, qtype uint16, err error) {
+	q := r.URL.Query()
+	name = q.Get("name")
+	if name == "" {
+		// Everything.
+		name, subtree = ".", true
+	} else {
+		name = dns.Fqdn(strings.ToLower(name))
+		if _, ok := dns.IsDomainName(name); !ok {
+			return "", false, 0, fmt.Errorf("invalid name: %q", q.Get("name"))
+		}
+		if s := q.Get("subtree"); s != "" {
+			if subtree, err = strconv.ParseBool(s); err != nil {
+				return "", false, 0, fmt.Errorf("invalid subtree: %q", s)
+			}
+		}
+	}
+	if t := q.Get("type"); t != "" {
+		var ok bool
+		if qtype, ok = dns.StringToType[strings.ToUpper(t)]; !ok {
+			return "", false, 0, fmt.Errorf("invalid type: %q", t)
+		}
+	}
+	return name, subtree, qtype, nil
+}
@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+type flusher struct {
+	name    string
+	subtree bool
+	qtype   uint16
+}
+
+func (f *flusher) Flush(name string, subtree bool, qtype uint16) int {
+	f.name, f.subtree, f.qtype = name, subtree, qtype
+	return 2
+}
+
+func TestServeFlush(t *testing.T) {
+	f := new(flusher)
+	flushers.Append(f, "cache")
+	flushers.Append(f, "cache")
+	defer flushers.Reset()
+
+	tests := []struct {
+		method  string
+		query   string
+		code    int
+		name    string
+		subtree bool
+		qtype   uint16
+	}{
+		{http.MethodPost, "", http.StatusOK, ".", true, dns.TypeNone},
+		{http.MethodPost, "name=Example.com", http.StatusOK, "example.com.", false, dns.TypeNone},
+		{http.MethodPost, "name=example.com.&subtree=true", http.StatusOK, "example.com.", true, dns.TypeNone},
+		{http.MethodPost, "name=example.com&type=aaaa", http.StatusOK, "example.com.", false, dns.TypeAAAA},
+		{http.MethodPost, "type=MX", http.StatusOK, ".", true, dns.TypeMX},
+		{http.MethodPost, "name=example.com&subtree=maybe", http.StatusBadRequest, "", false, 0},
+		{http.MethodPost, "name=example.com&type=BLAH", http.StatusBadRequest, "", false, 0},
+		{http.MethodPost, "name=" + strings.Repeat("a", 64) + ".com", http.StatusBadRequest, "", false, 0},
+		{http.MethodGet, "", http.StatusMethodNotAllowed, "", false, 0},
+	}
+	for i, tc := range tests {
+		*f = flusher{}
+		rec := httptest.NewRecorder()
+		serveFlush(rec, httptest.NewRequest(tc.method, flushPath+"?"+tc.query, nil))
+
+		if rec.Code != tc.code {
+			t.Errorf("Test %d: expected status %d, got %d", i, tc.code, rec.Code)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		if body := strings.TrimSpace(rec.Body.String()); body != `{"flushed":4}` {
+			t.Errorf("Test %d: expected 4 answers flushed, got %s", i, body)
+		}
+		if f.name != tc.name || f.subtree != tc.subtree || f.qtype != tc.qtype {
+			t.Errorf("Test %d: expected a flush of %s %t %d, got %s %t %d", i, tc.name, tc.subtree, tc.qtype, f.name, f.subtree, f.qtype)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"net"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("admin", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	addr, err := parse(c)
+	if err != nil {
+		return plugin.Error("admin", err)
+	}
+	a := &admin{Addr: addr}
+
+	uniqAddr.Set(addr, a.onStartup)
+	c.OnStartup(func() error { uniqAddr.Set(addr, a.onStartup); return nil })
+	c.OnRestartFailed(func() error { uniqAddr.Set(addr, a.onStartup); return nil })
+
+	c.OnStartup(func() error { return uniqAddr.ForEach() })
+	c.OnRestartFailed(func() error { return uniqAddr.ForEach() })
+
+	c.OnRestart(a.onFinalShutdown)
+	c.OnFinalShutdown(a.onFinalShutdown)
+
+	// The flushers of the new instance replace those of the old one.
+	c.OnRestart(func() error { flushers.Reset(); return nil })
+
+	appendFlushers := func() error {
+		for _, p := range dnsserver.GetConfig(c).Handlers() {
+			if f, ok := p.(Flusher); ok {
+				flushers.Append(f, p.Name())
+			}
+		}
+		return nil
+	}
+	c.OnStartup(appendFlushers)
+	c.OnRestartFailed(appendFlushers)
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (string, error) {
+	addr := defaultAddr
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return "", plugin.ErrOnce
+		}
+		i++
+		args := c.RemainingArgs()
+
+		switch len(args) {
+		case 0:
+		case 1:
+			addr = args[0]
+			if _, _, e := net.SplitHostPort(addr); e != nil {
+				return "", e
+			}
+		default:
+			return "", c.ArgErr()
+		}
+
+		for c.NextBlock() {
+			return "", c.Errf("unknown property '%s'", c.Val())
+		}
+	}
+	return addr, nil
+}
+
+// defaultAddr only listens on the loopback interface, as the API is not authenticated.
+const defaultAddr = "localhost:8182"
@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetupAdmin(t *testing.T) {
+	tests := []struct {
+		input        string
+		shouldErr    bool
+		expectedAddr string
+	}{
+		{`admin`, false, defaultAddr},
+		{`admin localhost:1234`, false, "localhost:1234"},
+		{`admin localhost:1234 b`, true, ""},
+		{`admin bla`, true, ""},
+		{`admin {
+			dog
+		}`, true, ""},
+		{`admin
+		admin`, true, ""},
+	}
+
+	for i, test := range tests {
+		addr, err := parse(caddy.NewTestController("dns", test.input))
+		if err == nil && addr != test.expectedAddr {
+			t.Errorf("Test %d: Expected %q, got %q", i, test.expectedAddr, addr)
+		}
+
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found none for input %s", i, test.input)
+		}
+
+		if err != nil {
+			if !test.shouldErr {
+				t.Errorf("Test %d: Expected no error but found one for input %s. Error was: %v", i, test.input, err)
+			}
+		}
+	}
+}
@@ -26,15 +26,24 @@ type (
 	}
 
 	loader struct {
-		directory string
-		template  string
-		re        *regexp.Regexp
+		sources     []source
+		removeAfter time.Duration // how long a zone is kept after its zonefile is gone
 
 		// In the future this should be something like ZoneMeta that contains all this stuff.
 		transferTo     []string
+		transferKeys   map[string]string
+		tsigSecret     map[string]string
 		ReloadInterval time.Duration
 		upstream       *upstream.Upstream // Upstream for looking up names during the resolution process.
 	}
+
+	// source is a directory zones are loaded from. The zonefiles whose names match re are loaded; template is
+	// expanded to their origins.
+	source struct {
+		directory string
+		template  string
+		re        *regexp.Regexp
+	}
 )
 
 // ServeDNS implements the plugin.Handler interface.
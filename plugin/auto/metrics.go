@@ -0,0 +1,23 @@
+package auto
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	zonesLoaded = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "auto",
+		Name:      "zones_loaded",
+		Help:      "The number of zones loaded from a directory.",
+	}, []string{"directory"})
+
+	zonesFailed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "auto",
+		Name:      "zones_failed",
+		Help:      "The number of zonefiles in a directory that failed to load.",
+	}, []string{"directory"})
+)
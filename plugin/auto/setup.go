@@ -12,6 +12,7 @@ import (
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/coredns/coredns/plugin/pkg/parse"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/plugin/pkg/watch"
 
 	"github.com/caddyserver/caddy"
 )
@@ -32,6 +33,7 @@ func setup(c *caddy.Controller) error {
 	}
 
 	c.OnStartup(func() error {
+		metrics.MustRegister(c, zonesLoaded, zonesFailed)
 		m := dnsserver.GetConfig(c).Handler("prometheus")
 		if m == nil {
 			return nil
@@ -48,14 +50,38 @@ func setup(c *caddy.Controller) error {
 			return err
 		}
 
+		// Watch the directories, so new and removed zones are picked up right away. The ticker stays as
+		// a fallback and for zones in subdirectories.
+		changed := make(chan struct{}, 1)
+		stops := []func(){}
+		for _, s := range a.loader.sources {
+			stop, err := watch.Dir(s.directory, func() {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			})
+			if err != nil {
+				log.Warningf("Failed to watch %q, walking it every %s: %s", s.directory, a.loader.ReloadInterval, err)
+				continue
+			}
+			stops = append(stops, stop)
+		}
+
 		go func() {
 			ticker := time.NewTicker(a.loader.ReloadInterval)
 			for {
 				select {
 				case <-walkChan:
+					ticker.Stop()
+					for _, stop := range stops {
+						stop()
+					}
 					return
 				case <-ticker.C:
 					a.Walk()
+				case <-changed:
+					a.Walk()
 				}
 			}
 		}()
@@ -79,8 +105,6 @@ func autoParse(c *caddy.Controller) (Auto, error) {
 	nilInterval := -1 * time.Second
 	var a = Auto{
 		loader: loader{
-			template:       "${1}",
-			re:             regexp.MustCompile(`db\.(.*)`),
 			ReloadInterval: nilInterval,
 		},
 		Zones: &Zones{},
@@ -108,38 +132,39 @@ func autoParse(c *caddy.Controller) (Auto, error) {
 				if !c.NextArg() {
 					return a, c.ArgErr()
 				}
-				a.loader.directory = c.Val()
-				if !filepath.IsAbs(a.loader.directory) && config.Root != "" {
-					a.loader.directory = filepath.Join(config.Root, a.loader.directory)
+				s := source{directory: c.Val(), template: "${1}", re: regexp.MustCompile(`db\.(.*)`)}
+				if !filepath.IsAbs(s.directory) && config.Root != "" {
+					s.directory = filepath.Join(config.Root, s.directory)
 				}
-				_, err := os.Stat(a.loader.directory)
+				_, err := os.Stat(s.directory)
 				if err != nil {
 					if os.IsNotExist(err) {
-						log.Warningf("Directory does not exist: %s", a.loader.directory)
+						log.Warningf("Directory does not exist: %s", s.directory)
 					} else {
-						return a, c.Errf("Unable to access root path '%s': %v", a.loader.directory, err)
+						return a, c.Errf("Unable to access root path '%s': %v", s.directory, err)
 					}
 				}
 
 				// regexp template
 				if c.NextArg() {
-					a.loader.re, err = regexp.Compile(c.Val())
+					s.re, err = regexp.Compile(c.Val())
 					if err != nil {
 						return a, err
 					}
-					if a.loader.re.NumSubexp() == 0 {
+					if s.re.NumSubexp() == 0 {
 						return a, c.Errf("Need at least one sub expression")
 					}
 
 					if !c.NextArg() {
 						return a, c.ArgErr()
 					}
-					a.loader.template = rewriteToExpand(c.Val())
+					s.template = rewriteToExpand(c.Val())
 				}
 
 				if c.NextArg() {
 					return Auto{}, c.ArgErr()
 				}
+				a.loader.sources = append(a.loader.sources, s)
 
 			case "reload":
 				d, err := time.ParseDuration(c.RemainingArgs()[0])
@@ -148,18 +173,44 @@ func autoParse(c *caddy.Controller) (Auto, error) {
 				}
 				a.loader.ReloadInterval = d
 
+			case "remove_after":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return a, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return a, plugin.Error("auto", err)
+				}
+				a.loader.removeAfter = d
+
 			case "upstream":
 				// remove soon
 				c.RemainingArgs() // eat remaining args
 
 			case "transfer":
-				t, _, e := parse.Transfer(c, false)
+				t, _, key, e := parse.Transfer(c, false)
 				if e != nil {
 					return a, e
 				}
 				if t != nil {
 					a.loader.transferTo = append(a.loader.transferTo, t...)
 				}
+				if key != nil {
+					if err := dnsserver.GetConfig(c).AddTsigKey(key.Name, key.Secret); err != nil {
+						return a, err
+					}
+					if a.loader.transferKeys == nil {
+						a.loader.transferKeys = make(map[string]string)
+					}
+					for _, to := range t {
+						a.loader.transferKeys[to] = key.Name
+					}
+					if a.loader.tsigSecret == nil {
+						a.loader.tsigSecret = make(map[string]string)
+					}
+					a.loader.tsigSecret[key.Name] = key.Secret
+				}
 
 			default:
 				return Auto{}, c.Errf("unknown property '%s'", c.Val())
@@ -113,14 +113,14 @@ func TestAutoParse(t *testing.T) {
 		} else if err != nil && !test.shouldErr {
 			t.Fatalf("Test %d expected no errors, but got '%v'", i, err)
 		} else if !test.shouldErr {
-			if a.loader.directory != test.expectedDirectory {
-				t.Fatalf("Test %d expected %v, got %v", i, test.expectedDirectory, a.loader.directory)
+			if a.loader.sources[0].directory != test.expectedDirectory {
+				t.Fatalf("Test %d expected %v, got %v", i, test.expectedDirectory, a.loader.sources[0].directory)
 			}
-			if a.loader.template != test.expectedTempl {
-				t.Fatalf("Test %d expected %v, got %v", i, test.expectedTempl, a.loader.template)
+			if a.loader.sources[0].template != test.expectedTempl {
+				t.Fatalf("Test %d expected %v, got %v", i, test.expectedTempl, a.loader.sources[0].template)
 			}
-			if a.loader.re.String() != test.expectedRe {
-				t.Fatalf("Test %d expected %v, got %v", i, test.expectedRe, a.loader.re)
+			if a.loader.sources[0].re.String() != test.expectedRe {
+				t.Fatalf("Test %d expected %v, got %v", i, test.expectedRe, a.loader.sources[0].re)
 			}
 			if a.loader.ReloadInterval != test.expectedReloadInterval {
 				t.Fatalf("Test %d expected %v, got %v", i, test.expectedReloadInterval, a.loader.ReloadInterval)
@@ -135,3 +135,24 @@ func TestAutoParse(t *testing.T) {
 		}
 	}
 }
+
+func TestAutoParseDirectories(t *testing.T) {
+	c := caddy.NewTestController("dns", `auto {
+		directory /tmp/a
+		directory /tmp/b ([^/]+)/db\.(.*) {2}.{1}
+		remove_after 5m
+	}`)
+	a, err := autoParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(a.loader.sources) != 2 {
+		t.Fatalf("Expected 2 directories, got %d", len(a.loader.sources))
+	}
+	if s := a.loader.sources[1]; s.directory != "/tmp/b" || s.template != "${2}.${1}" || s.re.String() != `([^/]+)/db\.(.*)` {
+		t.Errorf("Expected the second directory with its own template, got %s %s %s", s.directory, s.re, s.template)
+	}
+	if a.loader.removeAfter != 5*time.Minute {
+		t.Errorf("Expected remove_after of 5m, got %s", a.loader.removeAfter)
+	}
+}
@@ -4,13 +4,16 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/coredns/coredns/plugin/file"
 
 	"github.com/miekg/dns"
 )
 
-// Walk will recursively walk of the file under l.directory and adds the one that match l.re.
+// Walk will walk of the file in the directories of l.sources and adds the one that match their regular
+// expression. The subdirectories are walked too when the regular expression has a slash in it.
 func (a Auto) Walk() error {
 
 	// TODO(miek): should add something so that we don't stomp on each other.
@@ -20,58 +23,89 @@ func (a Auto) Walk() error {
 		toDelete[n] = true
 	}
 
-	filepath.Walk(a.loader.directory, func(path string, info os.FileInfo, err error) error {
-		if info == nil || info.IsDir() {
-			return nil
-		}
+	for _, s := range a.loader.sources {
+		loaded, failed := 0, 0
+		filepath.Walk(s.directory, func(path string, info os.FileInfo, err error) error {
+			if info == nil {
+				return nil
+			}
+			if info.IsDir() {
+				if path != s.directory && !nested(s.re) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			rel, err := filepath.Rel(s.directory, path)
+			if err != nil {
+				return nil
+			}
+			match, origin := matches(s.re, rel, s.template)
+			if !match {
+				return nil
+			}
+			if ok, seen := toDelete[origin]; seen && !ok {
+				// found already, the first file wins
+				return nil
+			}
+
+			if z, ok := a.Zones.Z[origin]; ok {
+				// we already have this zone
+				toDelete[origin] = false
+				z.SetFile(path)
+				loaded++
+				return nil
+			}
+
+			reader, err := os.Open(path)
+			if err != nil {
+				log.Warningf("Opening %s failed: %s", path, err)
+				failed++
+				return nil
+			}
+			defer reader.Close()
+
+			// Serial for loading a zone is 0, because it is a new zone.
+			zo, err := file.Parse(reader, origin, path, 0)
+			if err != nil {
+				log.Warningf("Parse zone `%s': %v", origin, err)
+				failed++
+				return nil
+			}
+
+			zo.ReloadInterval = a.loader.ReloadInterval
+			zo.Upstream = a.loader.upstream
+			zo.TransferTo = a.loader.transferTo
+			zo.TransferKeys = a.loader.transferKeys
+			zo.TsigSecret = a.loader.tsigSecret
+
+			a.Zones.Add(zo, origin)
+
+			if a.metrics != nil {
+				a.metrics.AddZone(origin)
+			}
+
+			zo.Notify()
+
+			log.Infof("Inserting zone `%s' from: %s", origin, path)
 
-		match, origin := matches(a.loader.re, info.Name(), a.loader.template)
-		if !match {
-			return nil
-		}
-
-		if z, ok := a.Zones.Z[origin]; ok {
-			// we already have this zone
 			toDelete[origin] = false
-			z.SetFile(path)
-			return nil
-		}
+			loaded++
 
-		reader, err := os.Open(path)
-		if err != nil {
-			log.Warningf("Opening %s failed: %s", path, err)
 			return nil
-		}
-		defer reader.Close()
-
-		// Serial for loading a zone is 0, because it is a new zone.
-		zo, err := file.Parse(reader, origin, path, 0)
-		if err != nil {
-			log.Warningf("Parse zone `%s': %v", origin, err)
-			return nil
-		}
-
-		zo.ReloadInterval = a.loader.ReloadInterval
-		zo.Upstream = a.loader.upstream
-		zo.TransferTo = a.loader.transferTo
-
-		a.Zones.Add(zo, origin)
-
-		if a.metrics != nil {
-			a.metrics.AddZone(origin)
-		}
-
-		zo.Notify()
-
-		log.Infof("Inserting zone `%s' from: %s", origin, path)
-
-		toDelete[origin] = false
-
-		return nil
-	})
+		})
+		zonesLoaded.WithLabelValues(s.directory).Set(float64(loaded))
+		zonesFailed.WithLabelValues(s.directory).Set(float64(failed))
+	}
 
+	now := time.Now()
 	for origin, ok := range toDelete {
 		if !ok {
+			a.Zones.found(origin)
+			continue
+		}
+		// A zonefile that is renamed may be gone for a moment, delete the zone when it stays gone.
+		if since := a.Zones.missing(origin, now); now.Sub(since) < a.loader.removeAfter {
 			continue
 		}
 
@@ -89,8 +123,12 @@ func (a Auto) Walk() error {
 
 // matches re to filename, if it is a match, the subexpression will be used to expand
 // template to an origin. When match is true that origin is returned. Origin is fully qualified.
+// The base of filename is matched, or the slash separated filename when re has a slash in it.
 func matches(re *regexp.Regexp, filename, template string) (match bool, origin string) {
 	base := filepath.Base(filename)
+	if nested(re) {
+		base = filepath.ToSlash(filename)
+	}
 
 	matches := re.FindStringSubmatchIndex(base)
 	if matches == nil {
@@ -106,3 +144,7 @@ func matches(re *regexp.Regexp, filename, template string) (match bool, origin s
 
 	return true, origin
 }
+
+// nested returns true when re matches the paths of the files relative to their directory, so the files
+// in its subdirectories are loaded too.
+func nested(re *regexp.Regexp) bool { return strings.Contains(re.String(), "/") }
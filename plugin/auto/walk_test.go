@@ -5,7 +5,11 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 var dbFiles = []string{"db.example.org", "aa.example.org"}
@@ -29,9 +33,11 @@ func TestWalk(t *testing.T) {
 	defer os.RemoveAll(tempdir)
 
 	ldr := loader{
-		directory: tempdir,
-		re:        regexp.MustCompile(`db\.(.*)`),
-		template:  `${1}`,
+		sources: []source{{
+			directory: tempdir,
+			re:        regexp.MustCompile(`db\.(.*)`),
+			template:  `${1}`,
+		}},
 	}
 
 	a := Auto{
@@ -53,9 +59,11 @@ func TestWalkNonExistent(t *testing.T) {
 	nonExistingDir := "highly_unlikely_to_exist_dir"
 
 	ldr := loader{
-		directory: nonExistingDir,
-		re:        regexp.MustCompile(`db\.(.*)`),
-		template:  `${1}`,
+		sources: []source{{
+			directory: nonExistingDir,
+			re:        regexp.MustCompile(`db\.(.*)`),
+			template:  `${1}`,
+		}},
 	}
 
 	a := Auto{
@@ -87,3 +95,87 @@ func createFiles() (string, error) {
 
 	return dir, nil
 }
+
+func TestWalkNested(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "coredns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"tenant1/db.example.org", "tenant2/db.example.org", "tenant2/bad/db.example.org", "zones/db.example.net", "zones/old/db.example.com"} {
+		content := zoneContent
+		if strings.Contains(name, "bad") {
+			content = "bad"
+		}
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := Auto{
+		loader: loader{
+			sources: []source{
+				{directory: dir, re: regexp.MustCompile(`^(tenant[^/]*)/(.*/)?db\.(.*)`), template: `${1}.${3}`},
+				{directory: filepath.Join(dir, "zones"), re: regexp.MustCompile(`db\.(.*)`), template: `${1}`},
+			},
+		},
+		Zones: &Zones{},
+	}
+	a.Walk()
+
+	for _, name := range []string{"tenant1.example.org.", "tenant2.example.org.", "example.net."} {
+		if _, ok := a.Zones.Z[name]; !ok {
+			t.Errorf("%s should have been added", name)
+		}
+	}
+	// Without a slash in the regular expression, the subdirectories aren't walked.
+	if _, ok := a.Zones.Z["example.com."]; ok {
+		t.Errorf("example.com. should not have been added")
+	}
+	if x := len(a.Zones.Z); x != 3 {
+		t.Errorf("Expected 3 zones, got %d", x)
+	}
+	if x := testutil.ToFloat64(zonesLoaded.WithLabelValues(dir)); x != 2 {
+		t.Errorf("Expected 2 zones loaded from %s, got %f", dir, x)
+	}
+	if x := testutil.ToFloat64(zonesFailed.WithLabelValues(dir)); x != 1 {
+		t.Errorf("Expected 1 zone failed to load from %s, got %f", dir, x)
+	}
+}
+
+func TestWalkRemoveAfter(t *testing.T) {
+	tempdir, err := createFiles()
+	if err != nil {
+		if tempdir != "" {
+			os.RemoveAll(tempdir)
+		}
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	a := Auto{
+		loader: loader{
+			sources:     []source{{directory: tempdir, re: regexp.MustCompile(`db\.(.*)`), template: `${1}`}},
+			removeAfter: time.Minute,
+		},
+		Zones: &Zones{},
+	}
+	a.Walk()
+
+	if err := os.Remove(filepath.Join(tempdir, "db.example.com")); err != nil {
+		t.Fatal(err)
+	}
+	a.Walk()
+	if _, ok := a.Zones.Z["example.com."]; !ok {
+		t.Fatalf("Expected %q to be kept", "example.com.")
+	}
+
+	// Gone for longer than a minute.
+	a.Zones.gone["example.com."] = time.Now().Add(-2 * time.Minute)
+	a.Walk()
+	if _, ok := a.Zones.Z["example.com."]; ok {
+		t.Errorf("Expected %q to be gone", "example.com.")
+	}
+}
@@ -18,9 +18,11 @@ func TestWatcher(t *testing.T) {
 	defer os.RemoveAll(tempdir)
 
 	ldr := loader{
-		directory: tempdir,
-		re:        regexp.MustCompile(`db\.(.*)`),
-		template:  `${1}`,
+		sources: []source{{
+			directory: tempdir,
+			re:        regexp.MustCompile(`db\.(.*)`),
+			template:  `${1}`,
+		}},
 	}
 
 	a := Auto{
@@ -65,9 +67,11 @@ func TestSymlinks(t *testing.T) {
 	defer os.RemoveAll(tempdir)
 
 	ldr := loader{
-		directory: tempdir,
-		re:        regexp.MustCompile(`db\.(.*)`),
-		template:  `${1}`,
+		sources: []source{{
+			directory: tempdir,
+			re:        regexp.MustCompile(`db\.(.*)`),
+			template:  `${1}`,
+		}},
 	}
 
 	a := Auto{
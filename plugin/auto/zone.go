@@ -3,8 +3,10 @@ package auto
 
 import (
 	"sync"
+	"time"
 
 	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/changes"
 )
 
 // Zones maps zone names to a *Zone. This keep track of what we zones we have loaded at
@@ -15,6 +17,8 @@ type Zones struct {
 
 	origins []string // Any origins from the server block.
 
+	gone map[string]time.Time // when the zonefiles of zones were first found missing
+
 	sync.RWMutex
 }
 
@@ -54,6 +58,29 @@ func (z *Zones) Add(zo *file.Zone, name string) {
 	zo.Reload()
 
 	z.Unlock()
+	changes.Notify(name)
+}
+
+// missing records that the zonefile of the zone named name is missing at now, and returns since when
+// it is missing.
+func (z *Zones) missing(name string, now time.Time) time.Time {
+	z.Lock()
+	defer z.Unlock()
+	if z.gone == nil {
+		z.gone = make(map[string]time.Time)
+	}
+	if since, ok := z.gone[name]; ok {
+		return since
+	}
+	z.gone[name] = now
+	return now
+}
+
+// found records that the zonefile of the zone named name is there.
+func (z *Zones) found(name string) {
+	z.Lock()
+	delete(z.gone, name)
+	z.Unlock()
 }
 
 // Remove removes the zone named name from z. It also stop the zone's reload goroutine.
@@ -65,6 +92,7 @@ func (z *Zones) Remove(name string) {
 	}
 
 	delete(z.Z, name)
+	delete(z.gone, name)
 
 	// TODO(miek): just regenerate Names (might be bad if you have a lot of zones...)
 	z.names = []string{}
@@ -73,4 +101,5 @@ func (z *Zones) Remove(name string) {
 	}
 
 	z.Unlock()
+	changes.Notify(name)
 }
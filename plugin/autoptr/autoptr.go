@@ -0,0 +1,137 @@
+// Package autoptr implements a plugin that answers PTR queries for the addresses served by the other
+// plugins of its server block.
+package autoptr
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Addresser is implemented by the plugins whose addresses autoptr answers PTR queries for.
+type Addresser interface {
+	// Addresses calls f with the owner name and the address of every A and AAAA record served.
+	Addresses(f func(name string, ip net.IP))
+}
+
+// AutoPTR is a plugin that synthesizes the PTR records of the addresses of its Addressers. The names
+// are indexed by address, the index is rebuilt in the background when a change is notified through
+// the changes package, or when it is older than reload. The queries are answered from the last index
+// meanwhile.
+type AutoPTR struct {
+	Next  plugin.Handler
+	Zones []string
+
+	ttl        uint32
+	reload     time.Duration
+	debounce   time.Duration // the wait before a rebuild, so a burst of changes rebuilds the index once
+	addressers []Addresser
+
+	index   atomic.Pointer[index]
+	mu      sync.Mutex // serializes the builds
+	pending int32      // 1 when a rebuild is scheduled, accessed atomically
+}
+
+// index is the names of the addresses, and when they were indexed.
+type index struct {
+	names map[string][]string
+	built time.Time
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (a *AutoPTR) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if state.QType() != dns.TypePTR || plugin.Zones(a.Zones).Matches(state.Name()) == "" {
+		return plugin.NextOrFailure(a.Name(), a.Next, ctx, w, r)
+	}
+	addr := net.ParseIP(dnsutil.ExtractAddressFromReverse(state.Name()))
+	if addr == nil {
+		return plugin.NextOrFailure(a.Name(), a.Next, ctx, w, r)
+	}
+	names := a.lookup(addr.String())
+	if len(names) == 0 {
+		return plugin.NextOrFailure(a.Name(), a.Next, ctx, w, r)
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	for _, n := range names {
+		m.Answer = append(m.Answer, &dns.PTR{
+			Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: a.ttl},
+			Ptr: n,
+		})
+	}
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// Name implements the plugin.Handler interface.
+func (a *AutoPTR) Name() string { return "autoptr" }
+
+// lookup returns the names of addr. The index is built first when there is none yet, and a rebuild is
+// scheduled when it is too old.
+func (a *AutoPTR) lookup(addr string) []string {
+	idx := a.index.Load()
+	if idx == nil {
+		a.mu.Lock()
+		if idx = a.index.Load(); idx == nil {
+			idx = a.build()
+		}
+		a.mu.Unlock()
+	} else if time.Since(idx.built) > a.reload {
+		a.invalidate("")
+	}
+	return idx.names[addr]
+}
+
+// build indexes the names of the addresses of the Addressers. The caller must hold a.mu.
+func (a *AutoPTR) build() *index {
+	names := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, ad := range a.addressers {
+		ad.Addresses(func(name string, ip net.IP) {
+			// A wildcard has no single name to point to.
+			if strings.HasPrefix(name, "*.") {
+				return
+			}
+			name = strings.ToLower(dns.Fqdn(name))
+			addr := ip.String()
+			if seen[addr+" "+name] {
+				return
+			}
+			seen[addr+" "+name] = true
+			names[addr] = append(names[addr], name)
+		})
+	}
+	for _, n := range names {
+		sort.Strings(n)
+	}
+	idx := &index{names: names, built: time.Now()}
+	a.index.Store(idx)
+	return idx
+}
+
+// invalidate schedules a rebuild of the index, after a.debounce. The changes notified until the
+// rebuild starts are in it, those notified later schedule another one.
+func (a *AutoPTR) invalidate(string) {
+	if !atomic.CompareAndSwapInt32(&a.pending, 0, 1) {
+		return
+	}
+	time.AfterFunc(a.debounce, func() {
+		atomic.StoreInt32(&a.pending, 0)
+		a.mu.Lock()
+		a.build()
+		a.mu.Unlock()
+	})
+}
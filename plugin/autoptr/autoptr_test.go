@@ -0,0 +1,124 @@
+package autoptr
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/changes"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// addresses is an Addresser serving the names and addresses in the map.
+type addresses map[string][]string
+
+func (a addresses) Addresses(f func(name string, ip net.IP)) {
+	for name, ips := range a {
+		for _, ip := range ips {
+			f(name, net.ParseIP(ip))
+		}
+	}
+}
+
+func TestAutoPTR(t *testing.T) {
+	a := &AutoPTR{
+		Next:   test.NextHandler(dns.RcodeNameError, nil),
+		Zones:  []string{"in-addr.arpa.", "ip6.arpa."},
+		ttl:    60,
+		reload: time.Hour,
+		addressers: []Addresser{
+			addresses{"www.example.org.": {"192.0.2.1", "2001:db8::1"}, "*.example.org.": {"192.0.2.2"}},
+			addresses{"Example.org": {"192.0.2.1"}, "www.example.org.": {"192.0.2.1"}},
+		},
+	}
+
+	tests := []test.Case{
+		{
+			Qname: "1.2.0.192.in-addr.arpa.", Qtype: dns.TypePTR,
+			Answer: []dns.RR{
+				test.PTR("1.2.0.192.in-addr.arpa. 60 IN PTR example.org."),
+				test.PTR("1.2.0.192.in-addr.arpa. 60 IN PTR www.example.org."),
+			},
+		},
+		{
+			Qname: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", Qtype: dns.TypePTR,
+			Answer: []dns.RR{
+				test.PTR("1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa. 60 IN PTR www.example.org."),
+			},
+		},
+		// Wildcards are not indexed, unknown addresses and other types go to the next plugin.
+		{Qname: "2.2.0.192.in-addr.arpa.", Qtype: dns.TypePTR, Rcode: dns.RcodeNameError},
+		{Qname: "1.2.0.192.in-addr.arpa.", Qtype: dns.TypeTXT, Rcode: dns.RcodeNameError},
+		{Qname: "2.0.192.in-addr.arpa.", Qtype: dns.TypePTR, Rcode: dns.RcodeNameError},
+	}
+
+	for i, tc := range tests {
+		m := tc.Msg()
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		code, _ := a.ServeDNS(context.TODO(), rec, m)
+		if tc.Rcode == dns.RcodeNameError {
+			if code != dns.RcodeNameError || rec.Msg != nil {
+				t.Errorf("Test %d: expected the query to go to the next plugin", i)
+			}
+			continue
+		}
+		if err := test.SortAndCheck(rec.Msg, tc); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+}
+
+// counter is an Addresser counting the index builds.
+type counter struct {
+	addresses
+	builds int32
+}
+
+func (c *counter) Addresses(f func(name string, ip net.IP)) {
+	atomic.AddInt32(&c.builds, 1)
+	c.addresses.Addresses(f)
+}
+
+func TestAutoPTRChanges(t *testing.T) {
+	addrs := &counter{addresses: addresses{"www.example.org.": {"192.0.2.1"}}}
+	a := &AutoPTR{
+		Next:       test.NextHandler(dns.RcodeNameError, nil),
+		Zones:      []string{"."},
+		reload:     time.Hour,
+		debounce:   10 * time.Millisecond,
+		addressers: []Addresser{addrs},
+	}
+	stop := changes.Watch(a.invalidate)
+	defer stop()
+
+	if names := a.lookup("192.0.2.1"); len(names) != 1 {
+		t.Fatalf("Expected 1 name, got %v", names)
+	}
+	a.mu.Lock()
+	addrs.addresses["mail.example.org."] = []string{"192.0.2.1"}
+	a.mu.Unlock()
+	if names := a.lookup("192.0.2.1"); len(names) != 1 {
+		t.Fatalf("Expected the index not to be rebuilt before a change, got %v", names)
+	}
+
+	// A burst of changes rebuilds the index once, in the background.
+	for i := 0; i < 10; i++ {
+		changes.Notify("example.org.")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(a.lookup("192.0.2.1")) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 2 names after a change, got %v", a.lookup("192.0.2.1"))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if builds := atomic.LoadInt32(&addrs.builds); builds != 2 {
+		t.Errorf("Expected the index to be rebuilt once, built %d times", builds)
+	}
+}
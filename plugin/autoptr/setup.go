@@ -0,0 +1,103 @@
+package autoptr
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/changes"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("autoptr", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	a, err := parse(c)
+	if err != nil {
+		return plugin.Error("autoptr", err)
+	}
+
+	var stop func()
+	c.OnStartup(func() error {
+		for _, h := range dnsserver.GetConfig(c).Handlers() {
+			if ad, ok := h.(Addresser); ok {
+				a.addressers = append(a.addressers, ad)
+			}
+		}
+		stop = changes.Watch(a.invalidate)
+		return nil
+	})
+	c.OnShutdown(func() error {
+		if stop != nil {
+			stop()
+		}
+		return nil
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		a.Next = next
+		return a
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*AutoPTR, error) {
+	a := &AutoPTR{ttl: 3600, reload: 30 * time.Second, debounce: time.Second}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		a.Zones = make([]string, len(c.ServerBlockKeys))
+		copy(a.Zones, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			a.Zones = args
+		}
+		for j := range a.Zones {
+			a.Zones[j] = plugin.Host(a.Zones[j]).Normalize()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "ttl":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				ttl, err := strconv.Atoi(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid ttl '%s'", c.Val())
+				}
+				if ttl < 0 || ttl > 3600*24*7 {
+					return nil, c.Errf("ttl out of range: %d", ttl)
+				}
+				a.ttl = uint32(ttl)
+			case "reload":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid duration '%s'", c.Val())
+				}
+				if d <= 0 {
+					return nil, c.Errf("reload must be positive: %s", d)
+				}
+				a.reload = d
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return a, nil
+}
@@ -0,0 +1,59 @@
+package autoptr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		zones     []string
+		ttl       uint32
+		reload    time.Duration
+	}{
+		{`autoptr`, false, []string{"."}, 3600, 30 * time.Second},
+		{`autoptr 10.0.0.0/8 2001:db8::/32`, false, []string{"10.in-addr.arpa.", "8.b.d.0.1.0.0.2.ip6.arpa."}, 3600, 30 * time.Second},
+		{"autoptr {\nttl 60\nreload 5s\n}", false, []string{"."}, 60, 5 * time.Second},
+		{"autoptr {\nttl\n}", true, nil, 0, 0},
+		{"autoptr {\nttl -1\n}", true, nil, 0, 0},
+		{"autoptr {\nreload 0s\n}", true, nil, 0, 0},
+		{"autoptr {\nreload soon\n}", true, nil, 0, 0},
+		{"autoptr {\nblah\n}", true, nil, 0, 0},
+		{"autoptr\nautoptr", true, nil, 0, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		c.ServerBlockKeys = []string{"."}
+		a, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(a.Zones) != len(tc.zones) {
+			t.Errorf("Test %d: expected zones %v, got %v", i, tc.zones, a.Zones)
+		} else {
+			for j := range tc.zones {
+				if a.Zones[j] != tc.zones[j] {
+					t.Errorf("Test %d: expected zones %v, got %v", i, tc.zones, a.Zones)
+				}
+			}
+		}
+		if a.ttl != tc.ttl {
+			t.Errorf("Test %d: expected ttl %d, got %d", i, tc.ttl, a.ttl)
+		}
+		if a.reload != tc.reload {
+			t.Errorf("Test %d: expected reload %s, got %s", i, tc.reload, a.reload)
+		}
+	}
+}
@@ -8,4 +8,5 @@ func init() {
 		ServerType: "dns",
 		Action:     setup,
 	})
+	caddy.RegisterEventHook("bind", hook)
 }
@@ -15,18 +15,54 @@ func setup(c *caddy.Controller) error {
 
 	// addresses will be consolidated over all BIND directives available in that BlocServer
 	all := []string{}
+	ifaces := map[string][]string{}
 	for c.Next() {
-		addrs := c.RemainingArgs()
-		if len(addrs) == 0 {
+		args := c.RemainingArgs()
+		if len(args) == 0 {
 			return plugin.Error("bind", fmt.Errorf("at least one address is expected"))
 		}
-		for _, addr := range addrs {
-			if net.ParseIP(addr) == nil {
-				return plugin.Error("bind", fmt.Errorf("not a valid IP address: %s", addr))
+		for _, arg := range args {
+			if net.ParseIP(arg) != nil {
+				all = append(all, arg)
+				continue
 			}
+			addrs, err := interfaceAddrs(arg)
+			if err != nil {
+				return plugin.Error("bind", fmt.Errorf("not a valid IP address or interface: %s: %s", arg, err))
+			}
+			if len(addrs) == 0 {
+				return plugin.Error("bind", fmt.Errorf("interface has no addresses: %s", arg))
+			}
+			ifaces[arg] = addrs
+			all = append(all, addrs...)
 		}
-		all = append(all, addrs...)
 	}
 	config.ListenHosts = all
+
+	if len(ifaces) > 0 {
+		addWatch(c, ifaces)
+	}
 	return nil
 }
+
+// interfaceAddrs returns the addresses of the network interface name that can be bound to. IPv6
+// link-local addresses are left out, they are only usable with the interface as zone.
+var interfaceAddrs = func(name string) ([]string, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	ips := []string{}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.IsLinkLocalUnicast() && ipnet.IP.To4() == nil {
+			continue
+		}
+		ips = append(ips, ipnet.IP.String())
+	}
+	return ips, nil
+}
@@ -1,6 +1,7 @@
 package bind
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/coredns/coredns/core/dnsserver"
@@ -9,6 +10,14 @@ import (
 )
 
 func TestSetup(t *testing.T) {
+	defer func(f func(string) ([]string, error)) { interfaceAddrs = f }(interfaceAddrs)
+	interfaceAddrs = func(name string) ([]string, error) {
+		if name == "lo" {
+			return []string{"127.0.0.1"}, nil
+		}
+		return nil, fmt.Errorf("no such network interface")
+	}
+
 	for i, test := range []struct {
 		config   string
 		expected []string
@@ -20,6 +29,9 @@ func TestSetup(t *testing.T) {
 		{`bind 1.2.3.4 ::5`, []string{"1.2.3.4", "::5"}, false},
 		{`bind ::1 1.2.3.4 ::5 127.9.9.0`, []string{"::1", "1.2.3.4", "::5", "127.9.9.0"}, false},
 		{`bind ::1 1.2.3.4 ::5 127.9.9.0 noone`, nil, true},
+		{`bind lo`, []string{"127.0.0.1"}, false},
+		{`bind 1.2.3.4 lo`, []string{"1.2.3.4", "127.0.0.1"}, false},
+		{`bind nosuchinterface0`, nil, true},
 	} {
 		c := caddy.NewTestController("dns", test.config)
 		err := setup(c)
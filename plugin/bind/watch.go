@@ -0,0 +1,124 @@
+package bind
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/caddyserver/caddy"
+)
+
+var log = clog.NewWithPlugin("bind")
+
+// watch checks the addresses of the interfaces bound to by name. When they change, the instance is
+// restarted with the same Corefile, which binds to the new addresses. There is a watch per instance,
+// it is kept in the instance's storage under watchKey and started by hook once the instance runs.
+type watch struct {
+	ifaces map[string][]string // the sorted addresses of each interface bound to by name
+
+	mu   sync.Mutex
+	quit chan struct{}
+}
+
+type watchKey struct{}
+
+// addWatch adds the interfaces in ifaces to the watch of the instance c sets up.
+func addWatch(c *caddy.Controller, ifaces map[string][]string) {
+	w, ok := c.Get(watchKey{}).(*watch)
+	if !ok {
+		w = &watch{ifaces: map[string][]string{}}
+		c.Set(watchKey{}, w)
+		c.OnShutdown(w.stop)
+	}
+	for name, addrs := range ifaces {
+		sort.Strings(addrs)
+		w.ifaces[name] = addrs
+	}
+}
+
+// hook starts the watch of an instance once it runs, so a watch is never started for an instance
+// that fails to start.
+func hook(event caddy.EventName, info interface{}) error {
+	if event != caddy.InstanceStartupEvent {
+		return nil
+	}
+	i := info.(*caddy.Instance)
+	i.StorageMu.RLock()
+	w, ok := i.Storage[watchKey{}].(*watch)
+	i.StorageMu.RUnlock()
+	if ok {
+		w.start(i)
+	}
+	return nil
+}
+
+func (w *watch) start(i *caddy.Instance) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.quit != nil {
+		return
+	}
+	quit := make(chan struct{})
+	w.quit = quit
+	go func() {
+		tick := time.NewTicker(checkInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+			case <-quit:
+				return
+			}
+			name, ok := w.changed()
+			if !ok {
+				continue
+			}
+			log.Infof("Addresses of interface %s changed, restarting", name)
+			// On success the instance is shut down, which stops this watch. On failure, e.g. because
+			// a new address can't be bound to yet, the instance keeps running and the next check
+			// tries again.
+			if err := restart(i); err != nil {
+				log.Errorf("Addresses of interface %s changed but restart failed: %s", name, err)
+			}
+		}
+	}()
+}
+
+func (w *watch) stop() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.quit != nil {
+		close(w.quit)
+		w.quit = nil
+	}
+	return nil
+}
+
+// changed returns the name of an interface whose addresses differ from the ones bound to. An
+// interface that went away, or lost all its addresses, keeps the server bound to its old addresses
+// until it comes back.
+func (w *watch) changed() (string, bool) {
+	for name, bound := range w.ifaces {
+		addrs, err := interfaceAddrs(name)
+		if err != nil || len(addrs) == 0 {
+			continue
+		}
+		sort.Strings(addrs)
+		if !reflect.DeepEqual(addrs, bound) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// checkInterval is how often the addresses of the interfaces are checked.
+var checkInterval = 5 * time.Second
+
+// restart restarts the instance i with its Corefile.
+var restart = func(i *caddy.Instance) error {
+	_, err := i.Restart(i.Caddyfile())
+	return err
+}
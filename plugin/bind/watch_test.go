@@ -0,0 +1,72 @@
+package bind
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestInterfaceAddrs(t *testing.T) {
+	addrs, err := interfaceAddrs("lo")
+	if err != nil {
+		t.Skipf("No loopback interface: %s", err)
+	}
+	for _, a := range addrs {
+		if a == "127.0.0.1" {
+			return
+		}
+	}
+	t.Errorf("Expected 127.0.0.1 in the addresses of lo, got %v", addrs)
+}
+
+func TestWatch(t *testing.T) {
+	defer func(f func(string) ([]string, error)) { interfaceAddrs = f }(interfaceAddrs)
+	defer func(f func(*caddy.Instance) error) { restart = f }(restart)
+	defer func(d time.Duration) { checkInterval = d }(checkInterval)
+
+	addrs := make(chan []string, 1)
+	addrs <- []string{"10.0.0.2", "10.0.0.1"}
+	current := []string{"10.0.0.2", "10.0.0.1"}
+	interfaceAddrs = func(name string) ([]string, error) {
+		select {
+		case current = <-addrs:
+		default:
+		}
+		return append([]string(nil), current...), nil
+	}
+	restarted := make(chan *caddy.Instance, 1)
+	restart = func(i *caddy.Instance) error {
+		restarted <- i
+		return nil
+	}
+	checkInterval = 10 * time.Millisecond
+
+	c := caddy.NewTestController("dns", `bind eth0`)
+	if err := setup(c); err != nil {
+		t.Fatal(err)
+	}
+	w := c.Get(watchKey{}).(*watch)
+	defer w.stop()
+
+	i := &caddy.Instance{Storage: map[interface{}]interface{}{watchKey{}: w}}
+	hook(caddy.InstanceStartupEvent, i)
+
+	// The same addresses in a different order are not a change.
+	addrs <- []string{"10.0.0.1", "10.0.0.2"}
+	select {
+	case <-restarted:
+		t.Fatal("Expected no restart when the addresses didn't change")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	addrs <- []string{"10.0.0.3"}
+	select {
+	case r := <-restarted:
+		if r != i {
+			t.Errorf("Expected the instance to be restarted")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a restart when the addresses changed")
+	}
+}
@@ -0,0 +1,143 @@
+// Package buildinfo implements an HTTP endpoint that describes the running CoreDNS binary: its
+// version, the plugins compiled in and enabled, and how it was built.
+package buildinfo
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/coremain"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/uniq"
+
+	"github.com/caddyserver/caddy"
+	"github.com/caddyserver/caddy/caddyfile"
+)
+
+var (
+	log      = clog.NewWithPlugin("buildinfo")
+	uniqAddr = uniq.New()
+	enabled  = &enabledList{}
+)
+
+// response is the document served by the endpoint.
+type response struct {
+	coremain.BuildInfo
+	Enabled map[string][]string `json:"enabled"` // plugins enabled in each server block, keyed by the block's key
+}
+
+type buildinfo struct {
+	Addr  string
+	Token string
+
+	sync.Mutex
+	ln   net.Listener
+	done bool
+}
+
+func (b *buildinfo) onStartup() error {
+	ln, err := net.Listen("tcp", b.Addr)
+	if err != nil {
+		return err
+	}
+
+	b.Lock()
+	b.ln = ln
+	b.done = true
+	b.Unlock()
+
+	mux := http.NewServeMux()
+	mux.Handle("/buildinfo", b)
+	go func() { http.Serve(ln, mux) }()
+	return nil
+}
+
+func (b *buildinfo) onFinalShutdown() error {
+	b.Lock()
+	defer b.Unlock()
+	if !b.done {
+		return nil
+	}
+
+	uniqAddr.Unset(b.Addr)
+
+	b.ln.Close()
+	b.done = false
+	return nil
+}
+
+// enabledList holds the plugins enabled in the Corefile of the running instance.
+type enabledList struct {
+	sync.RWMutex
+	m map[string][]string
+}
+
+func (e *enabledList) set(input caddy.Input) {
+	m, err := enabledPlugins(input)
+	if err != nil {
+		log.Warningf("Failed to list enabled plugins: %s", err)
+		return
+	}
+	e.Lock()
+	defer e.Unlock()
+	e.m = m
+}
+
+func (e *enabledList) get() map[string][]string {
+	e.RLock()
+	defer e.RUnlock()
+	return e.m
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (b *buildinfo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if b.Token != "" && !validToken(r, b.Token) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="buildinfo"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	resp := response{BuildInfo: coremain.Build(), Enabled: enabled.get()}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(resp)
+}
+
+// validToken returns true if r carries token as a bearer token.
+func validToken(r *http.Request, token string) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len(prefix) || auth[:len(prefix)] != prefix {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(token)) == 1
+}
+
+// enabledPlugins returns the plugins used in each server block of the Corefile input, in the order
+// they are executed.
+func enabledPlugins(input caddy.Input) (map[string][]string, error) {
+	blocks, err := caddyfile.Parse(input.Path(), bytes.NewReader(input.Body()), nil)
+	if err != nil {
+		return nil, err
+	}
+	enabled := make(map[string][]string)
+	for _, sb := range blocks {
+		var plugins []string
+		for _, dir := range dnsserver.Directives {
+			if _, ok := sb.Tokens[dir]; ok {
+				plugins = append(plugins, dir)
+			}
+		}
+		for _, k := range sb.Keys {
+			enabled[k] = plugins
+		}
+	}
+	return enabled, nil
+}
@@ -0,0 +1,70 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestServeHTTP(t *testing.T) {
+	b := &buildinfo{Token: "secret"}
+
+	tests := []struct {
+		auth     string
+		expected int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Basic secret", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	}
+	for i, tc := range tests {
+		r := httptest.NewRequest("GET", "/buildinfo", nil)
+		if tc.auth != "" {
+			r.Header.Set("Authorization", tc.auth)
+		}
+		w := httptest.NewRecorder()
+		b.ServeHTTP(w, r)
+		if w.Code != tc.expected {
+			t.Errorf("Test %d: Expected status %d, got %d", i, tc.expected, w.Code)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var resp response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Errorf("Test %d: Expected valid JSON, got %s", i, err)
+		}
+		if resp.Version == "" || resp.GoVersion == "" {
+			t.Errorf("Test %d: Expected version and Go version, got %v", i, resp.BuildInfo)
+		}
+	}
+}
+
+func TestEnabledPlugins(t *testing.T) {
+	input := caddy.CaddyfileInput{Contents: []byte(`example.org example.net {
+    whoami
+    log
+}
+. {
+    forward . 127.0.0.1
+}`), Filepath: "Corefile", ServerTypeName: "dns"}
+
+	enabled, err := enabledPlugins(input)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	expected := map[string][]string{
+		"example.org": {"log", "whoami"},
+		"example.net": {"log", "whoami"},
+		".":           {"forward"},
+	}
+	if !reflect.DeepEqual(enabled, expected) {
+		t.Errorf("Expected %v, got %v", expected, enabled)
+	}
+}
@@ -0,0 +1,15 @@
+package buildinfo
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PluginInfo is a metric with a constant '1' value for each plugin compiled in.
+var PluginInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "buildinfo",
+	Name:      "plugin_info",
+	Help:      "A metric with a constant '1' value labeled by name, module and version of each plugin compiled in.",
+}, []string{"name", "module", "version"})
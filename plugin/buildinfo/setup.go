@@ -0,0 +1,98 @@
+package buildinfo
+
+import (
+	"net"
+	"sync"
+
+	"github.com/coredns/coredns/coremain"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("buildinfo", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+var once sync.Once
+
+func setup(c *caddy.Controller) error {
+	addr, token, err := parse(c)
+	if err != nil {
+		return plugin.Error("buildinfo", err)
+	}
+	b := &buildinfo{Addr: addr, Token: token}
+
+	once.Do(func() { caddy.RegisterEventHook("buildinfo", hook) })
+
+	uniqAddr.Set(addr, b.onStartup)
+	c.OnStartup(func() error { uniqAddr.Set(addr, b.onStartup); return nil })
+	c.OnRestartFailed(func() error { uniqAddr.Set(addr, b.onStartup); return nil })
+
+	c.OnStartup(func() error { return uniqAddr.ForEach() })
+	c.OnRestartFailed(func() error { return uniqAddr.ForEach() })
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, PluginInfo)
+		for _, p := range coremain.Build().Plugins {
+			PluginInfo.WithLabelValues(p.Name, p.Module, p.Version).Set(1)
+		}
+		return nil
+	})
+
+	c.OnRestart(b.onFinalShutdown)
+	c.OnFinalShutdown(b.onFinalShutdown)
+
+	return nil
+}
+
+func hook(event caddy.EventName, info interface{}) error {
+	if event != caddy.InstanceStartupEvent {
+		return nil
+	}
+	if i, ok := info.(*caddy.Instance); ok {
+		enabled.set(i.Caddyfile())
+	}
+	return nil
+}
+
+func parse(c *caddy.Controller) (addr, token string, err error) {
+	addr = "localhost:8182"
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return "", "", plugin.ErrOnce
+		}
+		i++
+		args := c.RemainingArgs()
+
+		switch len(args) {
+		case 0:
+		case 1:
+			addr = args[0]
+			if _, _, e := net.SplitHostPort(addr); e != nil {
+				return "", "", e
+			}
+		default:
+			return "", "", c.ArgErr()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "token":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return "", "", c.ArgErr()
+				}
+				token = args[0]
+			default:
+				return "", "", c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return addr, token, nil
+}
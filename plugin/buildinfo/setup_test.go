@@ -0,0 +1,48 @@
+package buildinfo
+
+import (
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetupBuildinfo(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		addr      string
+		token     string
+	}{
+		{`buildinfo`, false, "localhost:8182", ""},
+		{`buildinfo localhost:1234`, false, "localhost:1234", ""},
+		{`buildinfo :1234 {
+			token secret
+		}`, false, ":1234", "secret"},
+		{`buildinfo localhost:1234 b`, true, "", ""},
+		{`buildinfo bla`, true, "", ""},
+		{`buildinfo {
+			token
+		}`, true, "", ""},
+		{`buildinfo {
+			tokens secret
+		}`, true, "", ""},
+	}
+
+	for i, test := range tests {
+		addr, token, err := parse(caddy.NewTestController("dns", test.input))
+
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error but found one for input %s. Error was: %v", i, test.input, err)
+			continue
+		}
+		if addr != test.addr || token != test.token {
+			t.Errorf("Test %d: Expected %s and %q, got %s and %q", i, test.addr, test.token, addr, token)
+		}
+	}
+}
@@ -3,12 +3,14 @@ package cache
 
 import (
 	"hash/fnv"
+	"math"
 	"net"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/cache"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/plugin/pkg/pressure"
 	"github.com/coredns/coredns/plugin/pkg/response"
 	"github.com/coredns/coredns/request"
 
@@ -26,6 +28,14 @@ type Cache struct {
 	nttl    time.Duration
 	minnttl time.Duration
 
+	nxttl     time.Duration // overrides nttl for NXDOMAIN, when not 0
+	nodatattl time.Duration // overrides nttl for NODATA, when not 0
+	failttl   time.Duration // the TTL of SERVFAIL, 0 to not cache it
+
+	// NXDOMAIN cut, see RFC 8020: the names below a NXDOMAIN name don't exist either.
+	nxdomainCut bool
+	nxcache     *cache.Cache // the NXDOMAIN items, by name
+
 	pcache  *cache.Cache
 	pcap    int
 	pttl    time.Duration
@@ -36,6 +46,19 @@ type Cache struct {
 	duration   time.Duration
 	percentage int
 
+	// Prefetch of the most popular items, instead of those with prefetch hits.
+	prefetchTop int           // prefetch the prefetchTop most popular items
+	halflife    time.Duration // of the popularity of an item
+	top         uint64        // the popularity of the least popular item prefetched, a float64
+	ranked      int64         // when top was last computed, in Unix nanoseconds
+
+	// Serve stale, see RFC 8767.
+	staleUpTo   time.Duration // serve the items expired less than staleUpTo ago, 0 to not serve them
+	staleTTL    uint32        // the TTL of the stale answers
+	verifyStale bool          // only serve stale when the next plugin fails
+
+	persist string // the file the items are saved to on shutdown, and loaded from on startup
+
 	// Testing.
 	now func() time.Time
 }
@@ -53,9 +76,14 @@ func New() *Cache {
 		ncache:     cache.New(defaultCap),
 		nttl:       maxNTTL,
 		minnttl:    minNTTL,
+		failttl:    minTTL,
+		nxcache:    cache.New(defaultCap),
 		prefetch:   0,
 		duration:   1 * time.Minute,
 		percentage: 10,
+		halflife:   defaultHalflife,
+		top:        math.Float64bits(math.Inf(-1)),
+		staleTTL:   defaultStaleTTL,
 		now:        time.Now,
 	}
 }
@@ -105,6 +133,18 @@ func computeTTL(msgTTL, minTTL, maxTTL time.Duration) time.Duration {
 	return ttl
 }
 
+// shed shrinks the caches to half their capacity when the memory is under pressure, and restores
+// their capacity after.
+func (c *Cache) shed(s pressure.Stage) {
+	pcap, ncap := c.pcap, c.ncap
+	if s >= pressure.ShrinkCache {
+		pcap, ncap = pcap/2, ncap/2
+	}
+	c.pcache.Resize(pcap)
+	c.ncache.Resize(ncap)
+	c.nxcache.Resize(ncap)
+}
+
 // ResponseWriter is a response writer that caches the reply message.
 type ResponseWriter struct {
 	dns.ResponseWriter
@@ -162,10 +202,9 @@ func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
 	msgTTL := dnsutil.MinimalTTL(res, mt)
 	var duration time.Duration
 	if mt == response.NameError || mt == response.NoData {
-		duration = computeTTL(msgTTL, w.minnttl, w.nttl)
+		duration = computeTTL(msgTTL, w.minnttl, w.maxNegativeTTL(mt))
 	} else if mt == response.ServerError {
-		// use default ttl which is 5s
-		duration = minTTL
+		duration = w.failttl
 	} else {
 		duration = computeTTL(msgTTL, w.minpttl, w.pttl)
 	}
@@ -201,6 +240,17 @@ func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
 	return w.ResponseWriter.WriteMsg(res)
 }
 
+// maxNegativeTTL returns the maximum TTL of the denial of existence responses of type mt.
+func (c *Cache) maxNegativeTTL(mt response.Type) time.Duration {
+	if mt == response.NameError && c.nxttl > 0 {
+		return c.nxttl
+	}
+	if mt == response.NoData && c.nodatattl > 0 {
+		return c.nodatattl
+	}
+	return c.nttl
+}
+
 func (w *ResponseWriter) set(m *dns.Msg, key uint64, mt response.Type, duration time.Duration) {
 	// duration is expected > 0
 	// and key is valid
@@ -212,6 +262,9 @@ func (w *ResponseWriter) set(m *dns.Msg, key uint64, mt response.Type, duration
 	case response.NameError, response.NoData, response.ServerError:
 		i := newItem(m, w.now(), duration)
 		w.ncache.Add(key, i)
+		if w.nxdomainCut {
+			w.addCut(i, w.state.Do())
+		}
 
 	case response.OtherError:
 		// don't cache these
@@ -236,8 +289,19 @@ const (
 	maxNTTL = dnsutil.MaximumDefaulTTL / 2
 	minNTTL = dnsutil.MinimalDefaultTTL
 
+	maxFailTTL = 5 * time.Minute // the longest SERVFAIL can be cached, these should be short lived.
+
 	defaultCap = 10000 // default capacity of the cache.
 
+	defaultHalflife = 10 * time.Minute // default half-life of the popularity of an item.
+
+	defaultStaleUpTo = time.Hour // default serve_stale duration.
+	defaultStaleTTL  = 30        // default TTL of the stale answers, as recommended by RFC 8767.
+
+	// The refresh modes of serve_stale.
+	staleImmediate = "immediate"
+	staleVerify    = "verify"
+
 	// Success is the class for caching positive caching.
 	Success = "success"
 	// Denial is the class defined for negative caching.
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/pressure"
 	"github.com/coredns/coredns/plugin/pkg/response"
 	"github.com/coredns/coredns/plugin/test"
 	"github.com/coredns/coredns/request"
@@ -209,7 +210,7 @@ func TestCache(t *testing.T) {
 		}
 
 		if ok {
-			resp := i.toMsg(m, time.Now().UTC())
+			resp := i.toMsg(m, uint32(i.ttl(time.Now().UTC())))
 
 			if err := test.Header(tc.Case, resp); err != nil {
 				t.Error(err)
@@ -317,3 +318,27 @@ func TestComputeTTL(t *testing.T) {
 		}
 	}
 }
+
+func TestCacheShed(t *testing.T) {
+	c := New()
+	for i := 0; i < c.pcap; i++ {
+		c.pcache.Add(uint64(i), i)
+		c.ncache.Add(uint64(i), i)
+	}
+
+	c.shed(pressure.ShrinkCache)
+	if l := c.pcache.Len(); l > c.pcap/2 {
+		t.Errorf("Expected at most %d positive items under pressure, got %d", c.pcap/2, l)
+	}
+	if l := c.ncache.Len(); l > c.ncap/2 {
+		t.Errorf("Expected at most %d negative items under pressure, got %d", c.ncap/2, l)
+	}
+
+	c.shed(pressure.None)
+	for i := 0; i < c.pcap; i++ {
+		c.pcache.Add(uint64(i), i)
+	}
+	if l := c.pcache.Len(); l <= c.pcap/2 {
+		t.Errorf("Expected more than %d positive items without pressure, got %d", c.pcap/2, l)
+	}
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// addCut adds i to the NXDOMAIN items when it says its name doesn't exist. An answer section means
+// the NXDOMAIN is for the target of a CNAME, not for the name.
+func (c *Cache) addCut(i *item, do bool) {
+	if i.Rcode != dns.RcodeNameError || len(i.Answer) > 0 || i.Name == "" || i.Name == "." {
+		return
+	}
+	c.nxcache.Add(hash(i.Name, dns.TypeNone, do), i)
+}
+
+// cut returns the NXDOMAIN item of the name of state or of one of its parents in the zone of the
+// cache, as all the names below a name that doesn't exist don't exist either, see RFC 8020.
+func (c *Cache) cut(now time.Time, state request.Request) *item {
+	name := state.Name()
+	zone := plugin.Zones(c.Zones).Matches(name)
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		if !plugin.Name(zone).Matches(name[off:]) {
+			return nil
+		}
+		if i, ok := c.nxcache.Get(hash(name[off:], dns.TypeNone, state.Do())); ok && c.fresh(i.(*item), now) {
+			return i.(*item)
+		}
+	}
+	return nil
+}
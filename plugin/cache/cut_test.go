@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/ede"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// denialHandler answers NXDOMAIN for the names below nxdomain, NODATA for the AAAA queries, and counts
+// the queries in n.
+func denialHandler(nxdomain string, n *int) plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		*n++
+		m := new(dns.Msg)
+		m.SetReply(r)
+		qname := r.Question[0].Name
+		switch {
+		case qname == "alias.example.org.":
+			m.Rcode = dns.RcodeNameError
+			m.Answer = []dns.RR{test.CNAME("alias.example.org. 300 IN CNAME gone.example.net.")}
+		case dns.IsSubDomain(nxdomain, qname):
+			m.Rcode = dns.RcodeNameError
+		case r.Question[0].Qtype == dns.TypeAAAA:
+		default:
+			m.Answer = []dns.RR{test.A(qname + " 300 IN A 127.0.0.1")}
+		}
+		m.Ns = []dns.RR{test.SOA("example.org. 300 IN SOA ns.example.org. hostmaster.example.org. 1 2 3 4 3600")}
+		w.WriteMsg(m)
+		return m.Rcode, nil
+	})
+}
+
+func TestNXDomainCut(t *testing.T) {
+	tests := []struct {
+		qname   string
+		qtype   uint16
+		rcode   int
+		fetched bool
+	}{
+		{"nx.example.org.", dns.TypeA, dns.RcodeNameError, true},
+		// Below a cached NXDOMAIN name, for any type.
+		{"a.nx.example.org.", dns.TypeA, dns.RcodeNameError, false},
+		{"b.a.nx.example.org.", dns.TypeMX, dns.RcodeNameError, false},
+		{"nx.example.org.", dns.TypeTXT, dns.RcodeNameError, false},
+		// Not below it.
+		{"xnx.example.org.", dns.TypeA, dns.RcodeSuccess, true},
+		// The NXDOMAIN of a CNAME target says nothing of the names below the CNAME.
+		{"alias.example.org.", dns.TypeA, dns.RcodeNameError, true},
+		{"a.alias.example.org.", dns.TypeA, dns.RcodeSuccess, true},
+	}
+
+	n := 0
+	c := New()
+	c.nxdomainCut = true
+	c.Next = denialHandler("nx.example.org.", &n)
+
+	for i, tc := range tests {
+		before := n
+		req := new(dns.Msg)
+		req.SetQuestion(tc.qname, tc.qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), rec, req)
+
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rec.Msg.Rcode])
+		}
+		if fetched := n > before; fetched != tc.fetched {
+			t.Errorf("Test %d: expected fetched %t, got %t", i, tc.fetched, fetched)
+		}
+		if rec.Msg.Question[0].Name != tc.qname {
+			t.Errorf("Test %d: expected the question for %s, got %s", i, tc.qname, rec.Msg.Question[0].Name)
+		}
+	}
+}
+
+func TestNegativeTTL(t *testing.T) {
+	tests := []struct {
+		qname string
+		qtype uint16
+		ttl   uint32
+	}{
+		{"nx.example.org.", dns.TypeA, 60},
+		{"example.org.", dns.TypeAAAA, 120},
+	}
+
+	n := 0
+	c := New()
+	c.nxttl = 60 * time.Second
+	c.nodatattl = 120 * time.Second
+	c.Next = denialHandler("nx.example.org.", &n)
+
+	for i, tc := range tests {
+		req := new(dns.Msg)
+		req.SetQuestion(tc.qname, tc.qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), rec, req)
+
+		if ttl := rec.Msg.Ns[0].Header().Ttl; ttl != tc.ttl {
+			t.Errorf("Test %d: expected TTL %d, got %d", i, tc.ttl, ttl)
+		}
+	}
+}
+
+func TestServfailTTL(t *testing.T) {
+	for _, failttl := range []time.Duration{0, 30 * time.Second} {
+		c := New()
+		c.failttl = failttl
+		c.Next = test.ErrorHandler()
+
+		req := new(dns.Msg)
+		req.SetQuestion("example.org.", dns.TypeA)
+		c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+
+		if cached := c.ncache.Len() == 1; cached != (failttl > 0) {
+			t.Errorf("With a servfail TTL of %s, expected cached %t, got %t", failttl, failttl > 0, cached)
+		}
+
+		// The cached SERVFAIL says so.
+		req.SetEdns0(4096, false)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), rec, req)
+		if code, _, ok := ede.Get(rec.Msg); ok != (failttl > 0) || (ok && code != ede.CachedError) {
+			t.Errorf("With a servfail TTL of %s, expected Extended DNS Error %t, got %t with %d", failttl, failttl > 0, ok, code)
+		}
+	}
+}
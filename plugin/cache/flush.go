@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
+	"github.com/miekg/dns"
+)
+
+// Flush implements the admin.Flusher interface. It removes the items for name, and its subdomains
+// when subtree is true, of type qtype, or of any type when qtype is dns.TypeNone.
+func (c *Cache) Flush(name string, subtree bool, qtype uint16) int {
+	name = plugin.Name(name).Normalize()
+	match := func(i *item) bool {
+		if qtype != dns.TypeNone && i.Qtype != qtype {
+			return false
+		}
+		if subtree {
+			return plugin.Name(name).Matches(i.Name)
+		}
+		return i.Name == name
+	}
+	// The items of nxcache are also in ncache, don't count them twice.
+	flush(c.nxcache, match)
+	return flush(c.pcache, match) + flush(c.ncache, match)
+}
+
+// flush removes the items of ca that match, and returns how many.
+func flush(ca *cache.Cache, match func(*item) bool) int {
+	var keys []uint64
+	ca.Walk(func(key uint64, el interface{}) bool {
+		if match(el.(*item)) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	for _, k := range keys {
+		ca.Remove(k)
+	}
+	return len(keys)
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestFlush(t *testing.T) {
+	tests := []struct {
+		name    string
+		subtree bool
+		qtype   uint16
+		flushed int
+	}{
+		{".", true, dns.TypeNone, 4},
+		{"example.org.", false, dns.TypeNone, 2},
+		{"Example.org", false, dns.TypeA, 1},
+		{"example.org.", true, dns.TypeNone, 3},
+		{"example.org.", true, dns.TypeAAAA, 1},
+		{"org.", false, dns.TypeNone, 0},
+		{"example.net.", true, dns.TypeNone, 1},
+	}
+	for i, tc := range tests {
+		now := time.Now()
+		c := New()
+		for j, q := range []struct {
+			name  string
+			qtype uint16
+			deny  bool
+		}{
+			{"example.org.", dns.TypeA, false},
+			{"example.org.", dns.TypeAAAA, true},
+			{"a.example.org.", dns.TypeA, false},
+			{"example.net.", dns.TypeA, false},
+		} {
+			m := new(dns.Msg)
+			m.SetQuestion(q.name, q.qtype)
+			if q.deny {
+				m.Ns = []dns.RR{test.SOA("example.org. 300 IN SOA ns.example.org. hostmaster.example.org. 1 2 3 4 5")}
+				c.ncache.Add(uint64(j), newItem(m, now, 300*time.Second))
+				continue
+			}
+			m.Answer = []dns.RR{test.A(q.name + " 300 IN A 127.0.0.1")}
+			c.pcache.Add(uint64(j), newItem(m, now, 300*time.Second))
+		}
+
+		if n := c.Flush(tc.name, tc.subtree, tc.qtype); n != tc.flushed {
+			t.Errorf("Test %d: expected %d items flushed, got %d", i, tc.flushed, n)
+		}
+		if l := c.pcache.Len() + c.ncache.Len(); l != 4-tc.flushed {
+			t.Errorf("Test %d: expected %d items left, got %d", i, 4-tc.flushed, l)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package freq
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Decay tracks the popularity of a thing: its hits, each decaying exponentially with a half-life.
+// The popularity is kept as the log2 of the hits decayed back to the Unix epoch, so the popularity
+// of different things compares without decaying them to a common time, and never overflows.
+type Decay struct {
+	score float64 // -Inf without hits
+
+	sync.RWMutex
+}
+
+// NewDecay returns a new Decay without hits.
+func NewDecay() *Decay { return &Decay{score: math.Inf(-1)} }
+
+// Hit adds a hit at now, with the half-life h, and returns the new score.
+func (d *Decay) Hit(h time.Duration, now time.Time) float64 {
+	// A hit at now weighs 2^e at the epoch.
+	e := float64(now.UnixNano()) / float64(h)
+	d.Lock()
+	defer d.Unlock()
+	hi, lo := math.Max(d.score, e), math.Min(d.score, e)
+	d.score = hi + math.Log2(1+math.Exp2(lo-hi))
+	return d.score
+}
+
+// Score returns the score of d. A higher score is a more popular thing.
+func (d *Decay) Score() float64 {
+	d.RLock()
+	defer d.RUnlock()
+	return d.score
+}
+
+// Reset resets the score of d to score.
+func (d *Decay) Reset(score float64) {
+	d.Lock()
+	defer d.Unlock()
+	d.score = score
+}
@@ -0,0 +1,39 @@
+package freq
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDecay(t *testing.T) {
+	now := time.Now()
+	h := time.Minute
+
+	old, recent := NewDecay(), NewDecay()
+	if !math.IsInf(old.Score(), -1) {
+		t.Fatalf("Expected no score without hits, got %f", old.Score())
+	}
+
+	// 4 hits 3 half-lives ago weigh 0.5 now, less than a hit now.
+	for i := 0; i < 4; i++ {
+		old.Hit(h, now.Add(-3*h))
+	}
+	recent.Hit(h, now)
+	if old.Score() >= recent.Score() {
+		t.Errorf("Expected the old hits to weigh less than the recent one: %f >= %f", old.Score(), recent.Score())
+	}
+
+	// 3 more hits now make it 3.5 against 1.
+	for i := 0; i < 3; i++ {
+		old.Hit(h, now)
+	}
+	if diff := old.Score() - recent.Score(); math.Abs(diff-math.Log2(3.5)) > 1e-6 {
+		t.Errorf("Expected a score log2(3.5) higher, got %f", diff)
+	}
+
+	old.Reset(math.Inf(-1))
+	if !math.IsInf(old.Score(), -1) {
+		t.Errorf("Expected no score after the reset, got %f", old.Score())
+	}
+}
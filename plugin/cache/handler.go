@@ -3,10 +3,14 @@ package cache
 import (
 	"context"
 	"math"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/ede"
+	"github.com/coredns/coredns/plugin/pkg/pressure"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -28,18 +32,23 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 
 	i, found := c.get(now, state, server)
 	if i != nil && found {
-		resp := i.toMsg(r, now)
+		ttl := i.ttl(now)
+		if ttl <= 0 {
+			return c.serveStale(ctx, w, r, state, server, i)
+		}
+		resp := i.toMsg(r, uint32(ttl))
+		if resp.Rcode == dns.RcodeServerFailure {
+			ede.Set(r, resp, ede.CachedError, "")
+		}
 
 		w.WriteMsg(resp)
 
-		if c.prefetch > 0 {
-			ttl := i.ttl(now)
-			i.Freq.Update(c.duration, now)
-
-			threshold := int(math.Ceil(float64(c.percentage) / 100 * float64(i.origTTL)))
-			if i.Freq.Hits() >= c.prefetch && ttl <= threshold {
+		// Prefetching is the first thing to go when the memory is under pressure.
+		if (c.prefetch > 0 || c.prefetchTop > 0) && pressure.Current() < pressure.NoPrefetch {
+			if c.shouldPrefetch(i, ttl, now) {
 				cw := newPrefetchResponseWriter(server, state, c)
-				go func(w dns.ResponseWriter) {
+				// The prefetch outlives the query, whose context is canceled once it is answered.
+				go func(ctx context.Context, w dns.ResponseWriter) {
 					cachePrefetches.WithLabelValues(server).Inc()
 					plugin.NextOrFailure(c.Name(), c.Next, ctx, w, r)
 
@@ -48,8 +57,9 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 					// into the new item that was stored in the cache.
 					if i1 := c.exists(state); i1 != nil {
 						i1.Freq.Reset(now, i.Freq.Hits())
+						i1.popularity.Reset(i.popularity.Score())
 					}
-				}(cw)
+				}(context.WithoutCancel(ctx), cw)
 			}
 		}
 		return dns.RcodeSuccess, nil
@@ -59,25 +69,131 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 	return plugin.NextOrFailure(c.Name(), c.Next, ctx, crr, r)
 }
 
+// shouldPrefetch counts the hit of i with ttl left at now, and returns true when i should be
+// prefetched: it is about to expire, and has been hit prefetch times without gaps of duration, or
+// with prefetch top it is one of the prefetchTop most popular items.
+func (c *Cache) shouldPrefetch(i *item, ttl int, now time.Time) bool {
+	threshold := int(math.Ceil(float64(c.percentage) / 100 * float64(i.origTTL)))
+	if c.prefetchTop > 0 {
+		popularity := i.popularity.Hit(c.halflife, now)
+		c.rankAfter(now)
+		return ttl <= threshold && popularity >= math.Float64frombits(atomic.LoadUint64(&c.top))
+	}
+	i.Freq.Update(c.duration, now)
+	return i.Freq.Hits() >= c.prefetch && ttl <= threshold
+}
+
+// rankAfter ranks the items in the background, when they were last ranked a tenth of the half-life
+// before now or more.
+func (c *Cache) rankAfter(now time.Time) {
+	ranked := atomic.LoadInt64(&c.ranked)
+	if now.UnixNano()-ranked < int64(c.halflife/10) || !atomic.CompareAndSwapInt64(&c.ranked, ranked, now.UnixNano()) {
+		return
+	}
+	go c.rank()
+}
+
+// rank sets top to the popularity of the prefetchTop-th most popular item, or to -Inf when there
+// are not more items than that.
+func (c *Cache) rank() {
+	var popularity []float64
+	walk := func(_ uint64, el interface{}) bool {
+		popularity = append(popularity, el.(*item).popularity.Score())
+		return true
+	}
+	c.pcache.Walk(walk)
+	c.ncache.Walk(walk)
+
+	top := math.Inf(-1)
+	if len(popularity) > c.prefetchTop {
+		sort.Sort(sort.Reverse(sort.Float64Slice(popularity)))
+		top = popularity[c.prefetchTop-1]
+	}
+	atomic.StoreUint64(&c.top, math.Float64bits(top))
+}
+
+// serveStale answers r with the expired item i, see RFC 8767. In the verify mode the answer is only
+// served when the next plugin fails to answer r, otherwise i is refreshed in the background, once at a
+// time.
+func (c *Cache) serveStale(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, state request.Request, server string, i *item) (int, error) {
+	mode := staleImmediate
+	if c.verifyStale {
+		mode = staleVerify
+		crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server}
+		sw := &staleResponseWriter{ResponseWriter: crr}
+		rcode, err := plugin.NextOrFailure(c.Name(), c.Next, ctx, sw, r)
+		if sw.refreshed {
+			return rcode, err
+		}
+	} else if atomic.CompareAndSwapInt32(&i.refreshing, 0, 1) {
+		sw := &staleResponseWriter{ResponseWriter: newPrefetchResponseWriter(server, state, c)}
+		go func(ctx context.Context) {
+			plugin.NextOrFailure(c.Name(), c.Next, ctx, sw, r)
+			atomic.StoreInt32(&i.refreshing, 0)
+		}(context.WithoutCancel(ctx))
+	}
+
+	resp := i.toMsg(r, c.staleTTL)
+	if resp.Rcode == dns.RcodeNameError {
+		ede.Set(r, resp, ede.StaleNXDomainAnswer, "")
+	} else {
+		ede.Set(r, resp, ede.StaleAnswer, "")
+	}
+	w.WriteMsg(resp)
+	cacheServedStale.WithLabelValues(server, mode).Inc()
+	return dns.RcodeSuccess, nil
+}
+
+// staleResponseWriter refreshes a stale item with the responses that are not failures, which would
+// otherwise replace it in the cache.
+type staleResponseWriter struct {
+	dns.ResponseWriter
+	refreshed bool // a response was written
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *staleResponseWriter) WriteMsg(res *dns.Msg) error {
+	if res.Rcode != dns.RcodeSuccess && res.Rcode != dns.RcodeNameError {
+		return nil
+	}
+	w.refreshed = true
+	return w.ResponseWriter.WriteMsg(res)
+}
+
 // Name implements the Handler interface.
 func (c *Cache) Name() string { return "cache" }
 
+// get returns the item of state, or with serve_stale the item that expired less than staleUpTo ago.
 func (c *Cache) get(now time.Time, state request.Request, server string) (*item, bool) {
 	k := hash(state.Name(), state.QType(), state.Do())
 
-	if i, ok := c.ncache.Get(k); ok && i.(*item).ttl(now) > 0 {
+	if i, ok := c.ncache.Get(k); ok && c.fresh(i.(*item), now) {
 		cacheHits.WithLabelValues(server, Denial).Inc()
 		return i.(*item), true
 	}
 
-	if i, ok := c.pcache.Get(k); ok && i.(*item).ttl(now) > 0 {
+	if i, ok := c.pcache.Get(k); ok && c.fresh(i.(*item), now) {
 		cacheHits.WithLabelValues(server, Success).Inc()
 		return i.(*item), true
 	}
+
+	if c.nxdomainCut {
+		if i := c.cut(now, state); i != nil {
+			cacheHits.WithLabelValues(server, Denial).Inc()
+			cacheNXDomainCuts.WithLabelValues(server).Inc()
+			return i, true
+		}
+	}
 	cacheMisses.WithLabelValues(server).Inc()
 	return nil, false
 }
 
+// fresh returns true when i can be served at now, expired or not.
+func (c *Cache) fresh(i *item, now time.Time) bool {
+	ttl := i.ttl(now)
+	return ttl > 0 || time.Duration(-ttl)*time.Second < c.staleUpTo
+}
+
 func (c *Cache) exists(state request.Request) *item {
 	k := hash(state.Name(), state.QType(), state.Do())
 	if i, ok := c.ncache.Get(k); ok {
@@ -118,6 +234,20 @@ var (
 		Help:      "The number of time the cache has prefetched a cached item.",
 	}, []string{"server"})
 
+	cacheServedStale = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "served_stale_total",
+		Help:      "The number of expired items served, by the refresh mode.",
+	}, []string{"server", "mode"})
+
+	cacheNXDomainCuts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "nxdomain_cut_total",
+		Help:      "The number of NXDOMAIN answers for the names below a cached NXDOMAIN name.",
+	}, []string{"server"})
+
 	cacheDrops = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: plugin.Namespace,
 		Subsystem: "cache",
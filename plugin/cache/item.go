@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"strings"
 	"time"
 
 	"github.com/coredns/coredns/plugin/cache/freq"
@@ -8,6 +9,8 @@ import (
 )
 
 type item struct {
+	Name               string // lower cased
+	Qtype              uint16
 	Rcode              int
 	AuthenticatedData  bool
 	RecursionAvailable bool
@@ -18,11 +21,18 @@ type item struct {
 	origTTL uint32
 	stored  time.Time
 
+	refreshing int32 // set while a stale i is refreshed, see Cache.refreshStale
+
 	*freq.Freq
+	popularity *freq.Decay // see Cache.prefetchTop
 }
 
 func newItem(m *dns.Msg, now time.Time, d time.Duration) *item {
 	i := new(item)
+	if len(m.Question) > 0 {
+		i.Name = strings.ToLower(m.Question[0].Name)
+		i.Qtype = m.Question[0].Qtype
+	}
 	i.Rcode = m.Rcode
 	i.AuthenticatedData = m.AuthenticatedData
 	i.RecursionAvailable = m.RecursionAvailable
@@ -44,13 +54,14 @@ func newItem(m *dns.Msg, now time.Time, d time.Duration) *item {
 	i.stored = now.UTC()
 
 	i.Freq = new(freq.Freq)
+	i.popularity = freq.NewDecay()
 
 	return i
 }
 
-// toMsg turns i into a message, it tailors the reply to m.
+// toMsg turns i into a message with the TTL ttl, it tailors the reply to m.
 // The Authoritative bit is always set to 0, because the answer is from the cache.
-func (i *item) toMsg(m *dns.Msg, now time.Time) *dns.Msg {
+func (i *item) toMsg(m *dns.Msg, ttl uint32) *dns.Msg {
 	m1 := new(dns.Msg)
 	m1.SetReply(m)
 
@@ -66,7 +77,6 @@ func (i *item) toMsg(m *dns.Msg, now time.Time) *dns.Msg {
 	m1.Ns = make([]dns.RR, len(i.Ns))
 	m1.Extra = make([]dns.RR, len(i.Extra))
 
-	ttl := uint32(i.ttl(now))
 	for j, r := range i.Answer {
 		m1.Answer[j] = dns.Copy(r)
 		m1.Answer[j].Header().Ttl = ttl
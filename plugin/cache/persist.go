@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
+	"github.com/miekg/dns"
+)
+
+// The snapshot of a cache starts with persistMagic, followed by a record per item:
+//
+//	length  uint32, of the payload
+//	crc     uint32, the CRC-32 (IEEE) of the payload
+//	payload kind (1 byte, 0 success, 1 denial), key (8 bytes), stored (8 bytes, Unix nanoseconds),
+//	        origTTL (4 bytes), the item as a packed DNS message
+//
+// in big endian. A damaged record ends the snapshot: the items before it are still loaded.
+const persistMagic = "CoreDNS cache 1\n"
+
+const (
+	persistSuccess byte = iota
+	persistDenial
+
+	persistHeader = 1 + 8 + 8 + 4
+	persistMax    = persistHeader + dns.MaxMsgSize // the largest payload
+)
+
+// save writes the items of c that can still be served to the file c.persist, replacing it at once
+// so it is never left half written.
+func (c *Cache) save() error {
+	dir, base := filepath.Split(c.persist)
+	f, err := ioutil.TempFile(dir, base)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails after the rename
+
+	w := bufio.NewWriter(f)
+	n, err := c.writeSnapshot(w, c.now())
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), c.persist); err != nil {
+		return err
+	}
+	log.Infof("Saved %d items to %s", n, c.persist)
+	return nil
+}
+
+// load adds the items of the file c.persist that can still be served to c. A missing file is not
+// an error: there is nothing to load.
+func (c *Cache) load() error {
+	f, err := os.Open(c.persist)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := c.readSnapshot(bufio.NewReader(f), c.now())
+	log.Infof("Loaded %d items from %s", n, c.persist)
+	return err
+}
+
+// writeSnapshot writes the items of c that can be served at now to w, and returns how many.
+func (c *Cache) writeSnapshot(w io.Writer, now time.Time) (int, error) {
+	if _, err := io.WriteString(w, persistMagic); err != nil {
+		return 0, err
+	}
+	n := 0
+	var err error
+	walk := func(kind byte, ca *cache.Cache) {
+		ca.Walk(func(key uint64, el interface{}) bool {
+			i := el.(*item)
+			if !c.fresh(i, now) {
+				return true
+			}
+			if err = writeItem(w, kind, key, i); err != nil {
+				return false
+			}
+			n++
+			return true
+		})
+	}
+	walk(persistSuccess, c.pcache)
+	if err == nil {
+		walk(persistDenial, c.ncache)
+	}
+	return n, err
+}
+
+// readSnapshot adds the items read from r that can be served at now to c, and returns how many.
+func (c *Cache) readSnapshot(r io.Reader, now time.Time) (int, error) {
+	magic := make([]byte, len(persistMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != persistMagic {
+		return 0, errors.New("not a cache snapshot")
+	}
+	n := 0
+	for {
+		kind, key, i, err := readItem(r)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if !c.fresh(i, now) {
+			continue
+		}
+		if kind == persistDenial {
+			c.ncache.Add(key, i)
+			if c.nxdomainCut {
+				c.addCut(i, key == hash(i.Name, i.Qtype, true))
+			}
+		} else {
+			c.pcache.Add(key, i)
+		}
+		n++
+	}
+}
+
+func writeItem(w io.Writer, kind byte, key uint64, i *item) error {
+	m := &dns.Msg{Answer: i.Answer, Ns: i.Ns, Extra: i.Extra}
+	if i.Name != "" {
+		m.Question = []dns.Question{{Name: i.Name, Qtype: i.Qtype, Qclass: dns.ClassINET}}
+	}
+	m.Rcode = i.Rcode
+	m.AuthenticatedData = i.AuthenticatedData
+	m.RecursionAvailable = i.RecursionAvailable
+	msg, err := m.Pack()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 8+persistHeader, 8+persistHeader+len(msg))
+	buf[8] = kind
+	binary.BigEndian.PutUint64(buf[9:], key)
+	binary.BigEndian.PutUint64(buf[17:], uint64(i.stored.UnixNano()))
+	binary.BigEndian.PutUint32(buf[25:], i.origTTL)
+	buf = append(buf, msg...)
+
+	binary.BigEndian.PutUint32(buf, uint32(len(buf)-8))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(buf[8:]))
+	_, err = w.Write(buf)
+	return err
+}
+
+// readItem reads a record from r. It returns io.EOF at the end of r, and an error when the record
+// is damaged.
+func readItem(r io.Reader) (kind byte, key uint64, i *item, err error) {
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(r, head); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errors.New("truncated record")
+		}
+		return 0, 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(head)
+	if length < persistHeader || length > persistMax {
+		return 0, 0, nil, fmt.Errorf("bad record length: %d", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, nil, errors.New("truncated record")
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(head[4:]) {
+		return 0, 0, nil, errors.New("bad record checksum")
+	}
+
+	kind = payload[0]
+	if kind != persistSuccess && kind != persistDenial {
+		return 0, 0, nil, fmt.Errorf("bad record kind: %d", kind)
+	}
+	key = binary.BigEndian.Uint64(payload[1:])
+	stored := time.Unix(0, int64(binary.BigEndian.Uint64(payload[9:])))
+	origTTL := binary.BigEndian.Uint32(payload[17:])
+
+	m := new(dns.Msg)
+	if err := m.Unpack(payload[persistHeader:]); err != nil {
+		return 0, 0, nil, err
+	}
+	i = newItem(m, stored, time.Duration(origTTL)*time.Second)
+	return kind, key, i, nil
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t0, err := time.Parse(time.RFC3339, "2018-01-01T14:00:00+00:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := t0
+	c := New()
+	c.now = func() time.Time { return now }
+	c.persist = filepath.Join(dir, "cache")
+	c.Next = staleHandler("example.org.", false, make(chan struct{}, 1))
+
+	query := func(c *Cache, qname string, qtype uint16) *dns.Msg {
+		req := new(dns.Msg)
+		req.SetQuestion(qname, qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), rec, req)
+		return rec.Msg
+	}
+	query(c, "example.org.", dns.TypeA)
+	// The denial, with the 5s minimal TTL, has expired when the cache is saved.
+	c.Next = test.ErrorHandler()
+	query(c, "example.org.", dns.TypeAAAA)
+
+	now = t0.Add(10 * time.Second)
+	if err := c.save(); err != nil {
+		t.Fatalf("Failed to save the cache: %s", err)
+	}
+
+	c1 := New()
+	c1.now = func() time.Time { return t0.Add(20 * time.Second) }
+	c1.persist = c.persist
+	c1.Next = test.ErrorHandler()
+	if err := c1.load(); err != nil {
+		t.Fatalf("Failed to load the cache: %s", err)
+	}
+	if l := c1.pcache.Len() + c1.ncache.Len(); l != 1 {
+		t.Fatalf("Expected 1 item loaded, got %d", l)
+	}
+	m := query(c1, "example.org.", dns.TypeA)
+	if len(m.Answer) != 1 || m.Answer[0].String() != test.A("example.org. 10 IN A 127.0.0.1").String() {
+		t.Errorf("Expected the loaded answer with a TTL of 10, got %v", m.Answer)
+	}
+}
+
+func TestPersistMissing(t *testing.T) {
+	c := New()
+	c.persist = filepath.Join(os.TempDir(), "cache-persist-missing")
+	if err := c.load(); err != nil {
+		t.Errorf("Expected no error loading a missing file, got %s", err)
+	}
+}
+
+func TestPersistDamaged(t *testing.T) {
+	now := time.Now()
+	c := New()
+	for i, rr := range []string{"a.example.org. 300 IN A 127.0.0.1", "b.example.org. 300 IN A 127.0.0.2"} {
+		m := new(dns.Msg)
+		m.Answer = []dns.RR{test.A(rr)}
+		c.pcache.Add(uint64(i), newItem(m, now, 300*time.Second))
+	}
+	buf := new(bytes.Buffer)
+	if n, err := c.writeSnapshot(buf, now); n != 2 || err != nil {
+		t.Fatalf("Expected 2 items written, got %d: %v", n, err)
+	}
+	snapshot := buf.Bytes()
+
+	tests := []struct {
+		name   string
+		damage func([]byte) []byte
+		loaded int
+	}{
+		{"intact", func(b []byte) []byte { return b }, 2},
+		{"truncated", func(b []byte) []byte { return b[:len(b)-3] }, 1},
+		{"corrupted", func(b []byte) []byte { b[len(b)-3] ^= 0xff; return b }, 1},
+		{"not a snapshot", func(b []byte) []byte { return []byte("example.org. 300 IN A 127.0.0.1") }, 0},
+	}
+	for _, tc := range tests {
+		b := tc.damage(append([]byte(nil), snapshot...))
+		c1 := New()
+		n, err := c1.readSnapshot(bytes.NewReader(b), now)
+		if n != tc.loaded || c1.pcache.Len() != tc.loaded {
+			t.Errorf("Test %s: expected %d items loaded, got %d", tc.name, tc.loaded, n)
+		}
+		if (err == nil) != (tc.name == "intact") {
+			t.Errorf("Test %s: unexpected error: %v", tc.name, err)
+		}
+	}
+}
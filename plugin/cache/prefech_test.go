@@ -3,11 +3,13 @@ package cache
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/pressure"
 
 	"github.com/coredns/coredns/plugin/test"
 	"github.com/miekg/dns"
@@ -18,6 +20,7 @@ func TestPrefetch(t *testing.T) {
 		qname         string
 		ttl           int
 		prefetch      int
+		stage         pressure.Stage
 		verifications []verification
 	}{
 		{
@@ -91,6 +94,28 @@ func TestPrefetch(t *testing.T) {
 				},
 			},
 		},
+		{
+			qname:    "under.pressure.example.org.",
+			ttl:      30,
+			prefetch: 1,
+			stage:    pressure.NoPrefetch,
+			verifications: []verification{
+				{
+					after:  0 * time.Second,
+					answer: "under.pressure.example.org. 30 IN A 127.0.0.1",
+					fetch:  true,
+				},
+				{
+					after:  29 * time.Second,
+					answer: "under.pressure.example.org.  1 IN A 127.0.0.1",
+				},
+				{
+					after:  30 * time.Second,
+					answer: "under.pressure.example.org. 30 IN A 127.0.0.2",
+					fetch:  true,
+				},
+			},
+		},
 	}
 
 	t0, err := time.Parse(time.RFC3339, "2018-01-01T14:00:00+00:00")
@@ -100,6 +125,8 @@ func TestPrefetch(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.qname, func(t *testing.T) {
 			fetchc := make(chan struct{}, 1)
+			pressure.Set(tt.stage)
+			defer pressure.Set(pressure.None)
 
 			c := New()
 			c.prefetch = tt.prefetch
@@ -137,6 +164,48 @@ func TestPrefetch(t *testing.T) {
 	}
 }
 
+func TestPrefetchCanceledQuery(t *testing.T) {
+	served := make(chan struct{})
+	prefetched := make(chan error, 1)
+	var calls int32
+	c := New()
+	c.prefetch = 1
+	c.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			// The prefetch, which runs on after the query was answered.
+			<-served
+			defer func() { prefetched <- ctx.Err() }()
+		}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, test.A(r.Question[0].Name+" 80 IN A 127.0.0.1"))
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+
+	// The server cancels the context of a query once it is answered, with max_query_time.
+	ctx, cancel := context.WithCancel(context.TODO())
+	t0 := time.Now()
+	for _, after := range []time.Duration{0, 73 * time.Second} {
+		now := t0.Add(after)
+		c.now = func() time.Time { return now }
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		c.ServeDNS(ctx, dnstest.NewRecorder(&test.ResponseWriter{}), m)
+	}
+	cancel()
+
+	close(served)
+	select {
+	case err := <-prefetched:
+		if err != nil {
+			t.Errorf("Expected the context of the prefetch not to be canceled, got %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the second query to trigger a prefetch")
+	}
+}
+
 type verification struct {
 	after  time.Duration
 	answer string
@@ -161,3 +230,60 @@ func prefetchHandler(qname string, ttl int, fetchc chan struct{}) plugin.Handler
 		return dns.RcodeSuccess, nil
 	})
 }
+
+func TestPrefetchTop(t *testing.T) {
+	t0, err := time.Parse(time.RFC3339, "2018-01-01T14:00:00+00:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetched := make(chan string, 10)
+	c := New()
+	c.prefetchTop = 1
+	c.halflife = time.Minute
+	c.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A(r.Question[0].Name + " 300 IN A 127.0.0.1")}
+		w.WriteMsg(m)
+		fetched <- r.Question[0].Name
+		return dns.RcodeSuccess, nil
+	})
+	query := func(qname string, after time.Duration) {
+		c.now = func() time.Time { return t0.Add(after) }
+		req := new(dns.Msg)
+		req.SetQuestion(qname, dns.TypeA)
+		c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	}
+
+	// was.hot is popular first, then is.hot takes over.
+	for _, qname := range []string{"was.hot.example.org.", "is.hot.example.org."} {
+		query(qname, 0)
+		<-fetched
+	}
+	for i := 0; i < 20; i++ {
+		query("was.hot.example.org.", time.Duration(i)*time.Second)
+	}
+	for i := 0; i < 10; i++ {
+		query("is.hot.example.org.", 4*time.Minute+time.Duration(i)*time.Second)
+	}
+	c.rank()
+
+	// Both are about to expire, only the most popular now is prefetched.
+	for _, qname := range []string{"was.hot.example.org.", "is.hot.example.org."} {
+		query(qname, 275*time.Second)
+	}
+	select {
+	case qname := <-fetched:
+		if qname != "is.hot.example.org." {
+			t.Errorf("Expected is.hot.example.org. prefetched, got %s", qname)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected is.hot.example.org. prefetched")
+	}
+	select {
+	case qname := <-fetched:
+		t.Errorf("Expected only is.hot.example.org. prefetched, got %s too", qname)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
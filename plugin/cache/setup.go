@@ -9,6 +9,7 @@ import (
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/metrics"
 	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/pressure"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 
 	"github.com/caddyserver/caddy"
@@ -36,10 +37,40 @@ func setup(c *caddy.Controller) error {
 	c.OnStartup(func() error {
 		metrics.MustRegister(c,
 			cacheSize, cacheHits, cacheMisses,
-			cachePrefetches, cacheDrops)
+			cachePrefetches, cacheDrops, cacheServedStale, cacheNXDomainCuts)
 		return nil
 	})
 
+	var stop func()
+	c.OnStartup(func() error {
+		stop = pressure.Watch(ca.shed)
+		ca.shed(pressure.Current())
+		return nil
+	})
+	c.OnShutdown(func() error {
+		stop()
+		return nil
+	})
+
+	if ca.persist != "" {
+		c.OnStartup(func() error {
+			if err := ca.load(); err != nil {
+				log.Warningf("Failed to load the items from %s: %s", ca.persist, err)
+			}
+			return nil
+		})
+		// Saved before a reload too, as the new cache loads them on its startup, before the
+		// shutdown of this one.
+		save := func() error {
+			if err := ca.save(); err != nil {
+				log.Warningf("Failed to save the items to %s: %s", ca.persist, err)
+			}
+			return nil
+		}
+		c.OnRestart(save)
+		c.OnFinalShutdown(save)
+	}
+
 	return nil
 }
 
@@ -145,6 +176,10 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 				}
 			case "prefetch":
 				args := c.RemainingArgs()
+				top := len(args) > 0 && args[0] == "top"
+				if top {
+					args = args[1:]
+				}
 				if len(args) == 0 || len(args) > 3 {
 					return nil, c.ArgErr()
 				}
@@ -155,14 +190,28 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 				if amount < 0 {
 					return nil, fmt.Errorf("prefetch amount should be positive: %d", amount)
 				}
-				ca.prefetch = amount
+				if top {
+					if amount == 0 {
+						return nil, fmt.Errorf("prefetch top should be positive: %d", amount)
+					}
+					ca.prefetch, ca.prefetchTop = 0, amount
+				} else {
+					ca.prefetch, ca.prefetchTop = amount, 0
+				}
 
 				if len(args) > 1 {
 					dur, err := time.ParseDuration(args[1])
 					if err != nil {
 						return nil, err
 					}
-					ca.duration = dur
+					if top {
+						if dur <= 0 {
+							return nil, fmt.Errorf("prefetch half-life should be positive: %s", dur)
+						}
+						ca.halflife = dur
+					} else {
+						ca.duration = dur
+					}
 				}
 				if len(args) > 2 {
 					pct := args[2]
@@ -180,6 +229,88 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 					}
 					ca.percentage = num
 				}
+			case "serve_stale":
+				args := c.RemainingArgs()
+				if len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				ca.staleUpTo = defaultStaleUpTo
+				if len(args) > 0 {
+					d, err := time.ParseDuration(args[0])
+					if err != nil {
+						return nil, err
+					}
+					if d <= 0 {
+						return nil, fmt.Errorf("invalid serve_stale duration: %s", args[0])
+					}
+					ca.staleUpTo = d
+				}
+				if len(args) > 1 {
+					switch args[1] {
+					case staleImmediate:
+						ca.verifyStale = false
+					case staleVerify:
+						ca.verifyStale = true
+					default:
+						return nil, fmt.Errorf("invalid serve_stale refresh mode: %s", args[1])
+					}
+				}
+			case "stale_ttl":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				ttl, err := strconv.Atoi(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if ttl < 0 {
+					return nil, fmt.Errorf("stale TTL can not be negative: %d", ttl)
+				}
+				ca.staleTTL = uint32(ttl)
+			case "nxdomain", "nodata":
+				property := c.Val()
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				ttl, err := strconv.Atoi(args[0])
+				if err != nil {
+					return nil, err
+				}
+				// Reserve 0 (and smaller for future things)
+				if ttl <= 0 {
+					return nil, fmt.Errorf("cache TTL can not be zero or negative: %d", ttl)
+				}
+				if property == "nxdomain" {
+					ca.nxttl = time.Duration(ttl) * time.Second
+				} else {
+					ca.nodatattl = time.Duration(ttl) * time.Second
+				}
+			case "servfail":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if d < 0 || d > maxFailTTL {
+					return nil, fmt.Errorf("servfail TTL should fall in range [0, %s]: %s", maxFailTTL, d)
+				}
+				ca.failttl = d
+			case "nxdomain_cut":
+				if len(c.RemainingArgs()) != 0 {
+					return nil, c.ArgErr()
+				}
+				ca.nxdomainCut = true
+			case "persist":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				ca.persist = args[0]
 
 			default:
 				return nil, c.ArgErr()
@@ -193,6 +324,7 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 
 		ca.pcache = cache.New(ca.pcap)
 		ca.ncache = cache.New(ca.ncap)
+		ca.nxcache = cache.New(ca.ncap)
 	}
 
 	return ca, nil
@@ -113,3 +113,155 @@ func TestSetup(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupServeStale(t *testing.T) {
+	tests := []struct {
+		input       string
+		shouldErr   bool
+		staleUpTo   time.Duration
+		staleTTL    uint32
+		verifyStale bool
+	}{
+		{"serve_stale", false, defaultStaleUpTo, defaultStaleTTL, false},
+		{"serve_stale 20m", false, 20 * time.Minute, defaultStaleTTL, false},
+		{"serve_stale 1h immediate", false, time.Hour, defaultStaleTTL, false},
+		{"serve_stale 1h verify", false, time.Hour, defaultStaleTTL, true},
+		{"serve_stale\nstale_ttl 0", false, defaultStaleUpTo, 0, false},
+		{"serve_stale\nstale_ttl 60", false, defaultStaleUpTo, 60, false},
+		// fails
+		{"serve_stale 20", true, 0, 0, false},
+		{"serve_stale -20m", true, 0, 0, false},
+		{"serve_stale 1h later", true, 0, 0, false},
+		{"serve_stale 1h verify 30", true, 0, 0, false},
+		{"stale_ttl", true, 0, 0, false},
+		{"stale_ttl -1", true, 0, 0, false},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", "cache {\n"+test.input+"\n}")
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+
+		if ca.staleUpTo != test.staleUpTo {
+			t.Errorf("Test %v: Expected staleUpTo %v but found: %v", i, test.staleUpTo, ca.staleUpTo)
+		}
+		if ca.staleTTL != test.staleTTL {
+			t.Errorf("Test %v: Expected staleTTL %v but found: %v", i, test.staleTTL, ca.staleTTL)
+		}
+		if ca.verifyStale != test.verifyStale {
+			t.Errorf("Test %v: Expected verifyStale %v but found: %v", i, test.verifyStale, ca.verifyStale)
+		}
+	}
+}
+
+func TestSetupPersist(t *testing.T) {
+	c := caddy.NewTestController("dns", "cache {\npersist /var/lib/coredns/cache\n}")
+	ca, err := cacheParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error but found error: %v", err)
+	}
+	if ca.persist != "/var/lib/coredns/cache" {
+		t.Errorf("Expected persist /var/lib/coredns/cache but found: %v", ca.persist)
+	}
+
+	for _, input := range []string{"cache {\npersist\n}", "cache {\npersist a b\n}"} {
+		if _, err := cacheParse(caddy.NewTestController("dns", input)); err == nil {
+			t.Errorf("Expected error for %q but found nil", input)
+		}
+	}
+}
+
+func TestSetupNegative(t *testing.T) {
+	tests := []struct {
+		input       string
+		shouldErr   bool
+		nxttl       time.Duration
+		nodatattl   time.Duration
+		failttl     time.Duration
+		nxdomainCut bool
+	}{
+		{"", false, 0, 0, minTTL, false},
+		{"nxdomain 60", false, 60 * time.Second, 0, minTTL, false},
+		{"nodata 120", false, 0, 120 * time.Second, minTTL, false},
+		{"servfail 0", false, 0, 0, 0, false},
+		{"servfail 1m", false, 0, 0, time.Minute, false},
+		{"nxdomain_cut", false, 0, 0, minTTL, true},
+		// fails
+		{"nxdomain", true, 0, 0, 0, false},
+		{"nxdomain 0", true, 0, 0, 0, false},
+		{"nodata abc", true, 0, 0, 0, false},
+		{"servfail 10", true, 0, 0, 0, false},
+		{"servfail 6m", true, 0, 0, 0, false},
+		{"nxdomain_cut yes", true, 0, 0, 0, false},
+	}
+	for i, test := range tests {
+		ca, err := cacheParse(caddy.NewTestController("dns", "cache {\n"+test.input+"\n}"))
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+
+		if ca.nxttl != test.nxttl || ca.nodatattl != test.nodatattl || ca.failttl != test.failttl {
+			t.Errorf("Test %v: Expected TTLs %v %v %v but found: %v %v %v", i, test.nxttl, test.nodatattl, test.failttl, ca.nxttl, ca.nodatattl, ca.failttl)
+		}
+		if ca.nxdomainCut != test.nxdomainCut {
+			t.Errorf("Test %v: Expected nxdomainCut %v but found: %v", i, test.nxdomainCut, ca.nxdomainCut)
+		}
+	}
+}
+
+func TestSetupPrefetchTop(t *testing.T) {
+	tests := []struct {
+		input       string
+		shouldErr   bool
+		prefetch    int
+		prefetchTop int
+		halflife    time.Duration
+		percentage  int
+	}{
+		{"prefetch top 100", false, 0, 100, defaultHalflife, 10},
+		{"prefetch top 100 1h", false, 0, 100, time.Hour, 10},
+		{"prefetch top 100 1h 20%", false, 0, 100, time.Hour, 20},
+		{"prefetch 10\nprefetch top 100", false, 0, 100, defaultHalflife, 10},
+		{"prefetch top 100\nprefetch 10", false, 10, 0, defaultHalflife, 10},
+		// fails
+		{"prefetch top", true, 0, 0, 0, 0},
+		{"prefetch top 0", true, 0, 0, 0, 0},
+		{"prefetch top 100 0s", true, 0, 0, 0, 0},
+		{"prefetch top 100 1h 20% 1", true, 0, 0, 0, 0},
+	}
+	for i, test := range tests {
+		ca, err := cacheParse(caddy.NewTestController("dns", "cache {\n"+test.input+"\n}"))
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+
+		if ca.prefetch != test.prefetch || ca.prefetchTop != test.prefetchTop {
+			t.Errorf("Test %v: Expected prefetch %v and top %v but found: %v and %v", i, test.prefetch, test.prefetchTop, ca.prefetch, ca.prefetchTop)
+		}
+		if ca.halflife != test.halflife || ca.percentage != test.percentage {
+			t.Errorf("Test %v: Expected half-life %v and percentage %v but found: %v and %v", i, test.halflife, test.percentage, ca.halflife, ca.percentage)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/ede"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestServeStale(t *testing.T) {
+	tests := []struct {
+		name          string
+		verify        bool
+		fail          bool // the backend fails after the first query
+		verifications []verification
+	}{
+		{
+			name: "immediate.example.org.",
+			verifications: []verification{
+				{after: 0, answer: "immediate.example.org. 30 IN A 127.0.0.1", fetch: true},
+				// Stale, served at once and refreshed in the background.
+				{after: 40 * time.Second, answer: "immediate.example.org. 30 IN A 127.0.0.1", fetch: true},
+				{after: 41 * time.Second, answer: "immediate.example.org. 29 IN A 127.0.0.2"},
+				// Expired past serve_stale, not served.
+				{after: 2 * time.Hour, answer: "immediate.example.org. 30 IN A 127.0.0.3", fetch: true},
+			},
+		},
+		{
+			name:   "verify.example.org.",
+			verify: true,
+			verifications: []verification{
+				{after: 0, answer: "verify.example.org. 30 IN A 127.0.0.1", fetch: true},
+				// Stale, but the backend answers.
+				{after: 40 * time.Second, answer: "verify.example.org. 30 IN A 127.0.0.2", fetch: true},
+			},
+		},
+		{
+			name:   "failing.example.org.",
+			verify: true,
+			fail:   true,
+			verifications: []verification{
+				{after: 0, answer: "failing.example.org. 30 IN A 127.0.0.1", fetch: true},
+				// Stale, and the backend fails: served stale, and the failure is not cached.
+				{after: 40 * time.Second, answer: "failing.example.org. 30 IN A 127.0.0.1", fetch: true},
+				{after: 41 * time.Second, answer: "failing.example.org. 30 IN A 127.0.0.1", fetch: true},
+			},
+		},
+	}
+
+	t0, err := time.Parse(time.RFC3339, "2018-01-01T14:00:00+00:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetchc := make(chan struct{}, 1)
+
+			c := New()
+			c.staleUpTo = time.Hour
+			c.verifyStale = tt.verify
+			c.Next = staleHandler(tt.name, tt.fail, fetchc)
+
+			req := new(dns.Msg)
+			req.SetQuestion(tt.name, dns.TypeA)
+
+			for _, v := range tt.verifications {
+				c.now = func() time.Time { return t0.Add(v.after) }
+
+				rec := dnstest.NewRecorder(&test.ResponseWriter{})
+				c.ServeDNS(context.TODO(), rec, req)
+				if v.fetch {
+					select {
+					case <-fetchc:
+					case <-time.After(time.Second):
+						t.Fatalf("After %s: want request to trigger a fetch", v.after)
+					}
+				}
+				if want, got := dns.RcodeSuccess, rec.Rcode; want != got {
+					t.Errorf("After %s: want rcode %d, got %d", v.after, want, got)
+				}
+				if want, got := 1, len(rec.Msg.Answer); want != got {
+					t.Fatalf("After %s: want %d answer RR, got %d", v.after, want, got)
+				}
+				if want, got := test.A(v.answer).String(), rec.Msg.Answer[0].String(); want != got {
+					t.Errorf("After %s: want answer %s, got %s", v.after, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestServeStaleExtendedError(t *testing.T) {
+	t0 := time.Now()
+	fetchc := make(chan struct{}, 2)
+
+	c := New()
+	c.staleUpTo = time.Hour
+	c.Next = staleHandler("example.org.", false, fetchc)
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	req.SetEdns0(4096, false)
+
+	for _, after := range []time.Duration{0, 40 * time.Second} {
+		c.now = func() time.Time { return t0.Add(after) }
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), rec, req)
+		<-fetchc
+
+		code, _, ok := ede.Get(rec.Msg)
+		if stale := after > 0; ok != stale || (stale && code != ede.StaleAnswer) {
+			t.Errorf("After %s: expected Extended DNS Error %t, got %t with %d", after, stale, ok, code)
+		}
+	}
+}
+
+// staleHandler is a fake plugin implementation which returns a single A record with the given
+// qname and a TTL of 30. The returned IP address starts at 127.0.0.1 and is incremented on every
+// request. When fail is true it returns SERVFAIL after the first request.
+func staleHandler(qname string, fail bool, fetchc chan struct{}) plugin.Handler {
+	i := 0
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		i++
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if fail && i > 1 {
+			m.Rcode = dns.RcodeServerFailure
+		} else {
+			m.Answer = append(m.Answer, test.A(fmt.Sprintf("%s 30 IN A 127.0.0.%d", qname, i)))
+		}
+
+		w.WriteMsg(m)
+		fetchc <- struct{}{}
+		return m.Rcode, nil
+	})
+}
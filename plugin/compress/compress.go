@@ -0,0 +1,60 @@
+// Package compress implements a plugin that compresses the names in large responses.
+package compress
+
+import (
+	"context"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+
+	"github.com/miekg/dns"
+)
+
+// Compress is a plugin that enables name compression for the responses it makes smaller.
+type Compress struct {
+	Next plugin.Handler
+
+	// MinSize is the size of the smallest uncompressed response that is compressed.
+	MinSize int
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (c Compress) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	cw := &ResponseWriter{ResponseWriter: w, minSize: c.MinSize, server: metrics.WithServer(ctx)}
+	return plugin.NextOrFailure(c.Name(), c.Next, ctx, cw, r)
+}
+
+// Name implements the plugin.Handler interface.
+func (c Compress) Name() string { return "compress" }
+
+// ResponseWriter compresses the responses written with WriteMsg.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	minSize int
+	server  string
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	if !res.Compress {
+		w.compress(res)
+	}
+	return w.ResponseWriter.WriteMsg(res)
+}
+
+// compress enables compression for res if that makes it smaller.
+func (w *ResponseWriter) compress(res *dns.Msg) {
+	size := res.Len()
+	if size < w.minSize {
+		return
+	}
+	res.Compress = true
+	compressed := res.Len()
+	if compressed >= size {
+		res.Compress = false
+		return
+	}
+
+	compressedCount.WithLabelValues(w.server).Inc()
+	savedBytes.WithLabelValues(w.server).Add(float64(size - compressed))
+}
@@ -0,0 +1,67 @@
+package compress
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// answer returns a handler that answers with n MX records.
+func answer(n int, compress bool) plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Compress = compress
+		for i := 0; i < n; i++ {
+			m.Answer = append(m.Answer, test.MX(fmt.Sprintf("example.org. 3600 IN MX %d mail%d.example.org.", i, i)))
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func TestCompress(t *testing.T) {
+	tests := []struct {
+		answers  int
+		compress bool // response already compressed
+		minSize  int
+		expected bool // response compressed
+	}{
+		{answers: 20, minSize: defaultMinSize, expected: true},
+		{answers: 2, minSize: defaultMinSize},
+		{answers: 2, minSize: 0, expected: true},
+		{answers: 0, minSize: 0}, // compression doesn't make it smaller
+		{answers: 20, compress: true, minSize: defaultMinSize, expected: true},
+	}
+
+	for i, tc := range tests {
+		compressedCount.Reset()
+		savedBytes.Reset()
+
+		c := Compress{Next: answer(tc.answers, tc.compress), MinSize: tc.minSize}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		req := new(dns.Msg)
+		req.SetQuestion("example.org.", dns.TypeMX)
+		if _, err := c.ServeDNS(context.TODO(), rec, req); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+
+		if rec.Msg.Compress != tc.expected {
+			t.Errorf("Test %d: expected compress %t, got %t", i, tc.expected, rec.Msg.Compress)
+		}
+		count := testutil.ToFloat64(compressedCount.WithLabelValues(""))
+		if changed := tc.expected && !tc.compress; (count == 1) != changed {
+			t.Errorf("Test %d: expected %t for compressed responses, got %f", i, changed, count)
+		}
+		if saved := testutil.ToFloat64(savedBytes.WithLabelValues("")); count == 1 && saved <= 0 {
+			t.Errorf("Test %d: expected saved bytes, got %f", i, saved)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package compress
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	compressedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "compress",
+		Name:      "responses_total",
+		Help:      "The count of responses compressed.",
+	}, []string{"server"})
+
+	savedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "compress",
+		Name:      "saved_bytes_total",
+		Help:      "The number of bytes saved by compressing responses.",
+	}, []string{"server"})
+)
@@ -0,0 +1,68 @@
+package compress
+
+import (
+	"strconv"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("compress", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	min, err := parse(c)
+	if err != nil {
+		return plugin.Error("compress", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		return Compress{Next: next, MinSize: min}
+	})
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, compressedCount, savedBytes)
+		return nil
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (int, error) {
+	min := defaultMinSize
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return 0, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		switch len(args) {
+		case 0:
+		case 1:
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return 0, err
+			}
+			if n < 0 {
+				return 0, c.Errf("size must be positive: %d", n)
+			}
+			min = n
+		default:
+			return 0, c.ArgErr()
+		}
+	}
+	return min, nil
+}
+
+// defaultMinSize is the default size of the smallest response that is compressed, the size of a
+// response without EDNS0.
+const defaultMinSize = 512
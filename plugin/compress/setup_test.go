@@ -0,0 +1,41 @@
+package compress
+
+import (
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  int
+	}{
+		{`compress`, false, defaultMinSize},
+		{`compress 0`, false, 0},
+		{`compress 1232`, false, 1232},
+		{`compress -1`, true, 0},
+		{`compress big`, true, 0},
+		{`compress 512 1232`, true, 0},
+		{"compress\ncompress", true, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		min, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got %s", i, err)
+			continue
+		}
+		if min != tc.expected {
+			t.Errorf("Test %d: Expected min size %d, got %d", i, tc.expected, min)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package cookies implements a plugin that makes the server answer the DNS cookies of the clients.
+package cookies
+
+import (
+	"encoding/hex"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("cookies", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	cookies, err := parse(c)
+	if err != nil {
+		return plugin.Error("cookies", err)
+	}
+	dnsserver.GetConfig(c).Cookies = cookies
+	return nil
+}
+
+func parse(c *caddy.Controller) (*dnsserver.Cookies, error) {
+	var (
+		secret  []byte
+		rotate  = defaultRotate
+		require bool
+	)
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		if len(c.RemainingArgs()) != 0 {
+			return nil, c.ArgErr()
+		}
+		for c.NextBlock() {
+			switch c.Val() {
+			case "require":
+				if len(c.RemainingArgs()) != 0 {
+					return nil, c.ArgErr()
+				}
+				require = true
+			case "secret":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				b, err := hex.DecodeString(args[0])
+				if err != nil || len(b) != secretSize {
+					return nil, c.Errf("the secret must be %d bytes in hex: %s", secretSize, args[0])
+				}
+				secret = b
+			case "rotate":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil || d < 0 {
+					return nil, c.Errf("invalid rotate duration: %s", args[0])
+				}
+				rotate = d
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return dnsserver.NewCookies(secret, rotate, require), nil
+}
+
+const (
+	// defaultRotate is how often the secret is rotated by default.
+	defaultRotate = 24 * time.Hour
+	// secretSize is the size of the secret, as in section 4.4 of RFC 9018.
+	secretSize = 16
+)
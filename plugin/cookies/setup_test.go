@@ -0,0 +1,54 @@
+package cookies
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		require   bool
+	}{
+		{`cookies`, false, false},
+		{"cookies {\nrequire\n}", false, true},
+		{"cookies {\nsecret 000102030405060708090a0b0c0d0e0f\n}", false, false},
+		{"cookies {\nrotate 1h\nrequire\n}", false, true},
+		{"cookies {\nrotate 0s\n}", false, false},
+		{`cookies require`, true, false},
+		{"cookies {\nrequire yes\n}", true, false},
+		{"cookies {\nsecret 0001\n}", true, false},
+		{"cookies {\nsecret secret\n}", true, false},
+		{"cookies {\nrotate -1h\n}", true, false},
+		{"cookies {\nrotate daily\n}", true, false},
+		{"cookies {\nstrict\n}", true, false},
+		{"cookies\ncookies", true, false},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		cookies := dnsserver.GetConfig(c).Cookies
+		if cookies == nil {
+			t.Errorf("Test %d: expected cookies, got none", i)
+			continue
+		}
+		if cookies.Require != tc.require {
+			t.Errorf("Test %d: expected require %t, got %t", i, tc.require, cookies.Require)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package plugin
+
+import (
+	"context"
+	"time"
+)
+
+// deadlineKey is the context key for the deadline of a query.
+type deadlineKey struct{}
+
+// WithDeadline returns a copy of ctx that carries d as the deadline for answering the query. Unlike
+// context.WithDeadline the returned context is not canceled when d passes, work that outlives the
+// query, such as a cache prefetch, can still use it.
+func WithDeadline(ctx context.Context, d time.Time) context.Context {
+	return context.WithValue(ctx, deadlineKey{}, d)
+}
+
+// Deadline returns the deadline for answering the query in ctx. If no deadline is set with
+// WithDeadline, the deadline of ctx itself is returned.
+func Deadline(ctx context.Context) (time.Time, bool) {
+	if d, ok := ctx.Value(deadlineKey{}).(time.Time); ok {
+		return d, true
+	}
+	return ctx.Deadline()
+}
+
+// Budget returns the time left to answer the query in ctx, capped at max. If ctx carries no deadline,
+// max is returned. When the deadline has passed, Budget returns 0.
+func Budget(ctx context.Context, max time.Duration) time.Duration {
+	d, ok := Deadline(ctx)
+	if !ok {
+		return max
+	}
+	left := time.Until(d)
+	if left < 0 {
+		return 0
+	}
+	if left < max {
+		return left
+	}
+	return max
+}
@@ -0,0 +1,33 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	ctx := context.TODO()
+	if b := Budget(ctx, time.Second); b != time.Second {
+		t.Errorf("Expected budget of %s without deadline, got %s", time.Second, b)
+	}
+
+	ctx = WithDeadline(context.TODO(), time.Now().Add(time.Hour))
+	if b := Budget(ctx, time.Second); b != time.Second {
+		t.Errorf("Expected budget of %s, got %s", time.Second, b)
+	}
+	if b := Budget(ctx, 2*time.Hour); b > time.Hour || b < 59*time.Minute {
+		t.Errorf("Expected budget of about an hour, got %s", b)
+	}
+
+	ctx = WithDeadline(context.TODO(), time.Now().Add(-time.Second))
+	if b := Budget(ctx, time.Second); b != 0 {
+		t.Errorf("Expected no budget after the deadline, got %s", b)
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Minute)
+	defer cancel()
+	if _, ok := Deadline(ctx); !ok {
+		t.Errorf("Expected deadline of the context to be used")
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/rsa"
+	"encoding/binary"
 	"errors"
+	"hash/fnv"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/coredns/coredns/request"
@@ -19,6 +22,47 @@ type DNSKEY struct {
 	D   *dns.DS
 	s   crypto.Signer
 	tag uint16
+
+	timing timing // only set for the keys in a key directory
+	base   string // file name without the .key or .private extension
+}
+
+// keySet is the set of keys of a Dnssec. It is replaced as a whole when the keys roll over.
+type keySet struct {
+	dnskeys   []*DNSKEY // published in the DNSKEY RRset
+	signers   []*DNSKEY // used for signing
+	cds       []*DNSKEY // published as CDS and CDNSKEY
+	splitkeys bool
+	hash      uint64 // mixed into the cache keys of the signatures, zero when the keys never change
+}
+
+// newKeySet returns a keySet that publishes dnskeys and signs with signers. The signing KSKs that
+// don't have a successor are published as CDS and CDNSKEY, or all signers without split keys.
+func newKeySet(dnskeys, signers []*DNSKEY, splitkeys bool) *keySet {
+	ks := &keySet{dnskeys: dnskeys, signers: signers, splitkeys: splitkeys}
+	for _, k := range signers {
+		if (k.isKSK() || !splitkeys) && k.timing.inactive.IsZero() {
+			ks.cds = append(ks.cds, k)
+		}
+	}
+	return ks
+}
+
+// tagsHash returns a hash of the key tags of keys, in any order.
+func tagsHash(keys []*DNSKEY) uint64 {
+	tags := make([]int, len(keys))
+	for i, k := range keys {
+		tags[i] = int(k.tag)
+	}
+	sort.Ints(tags)
+
+	h := fnv.New64()
+	buf := make([]byte, 2)
+	for _, t := range tags {
+		binary.BigEndian.PutUint16(buf, uint16(t))
+		h.Write(buf)
+	}
+	return h.Sum64()
 }
 
 // ParseKeyFile read a DNSSEC keyfile as generated by dnssec-keygen or other
@@ -60,8 +104,9 @@ func ParseKeyFile(pubFile, privFile string) (*DNSKEY, error) {
 
 // getDNSKEY returns the correct DNSKEY to the client. Signatures are added when do is true.
 func (d Dnssec) getDNSKEY(state request.Request, zone string, do bool, server string) *dns.Msg {
-	keys := make([]dns.RR, len(d.keys))
-	for i, k := range d.keys {
+	dnskeys := d.keySet().dnskeys
+	keys := make([]dns.RR, len(dnskeys))
+	for i, k := range dnskeys {
 		keys[i] = dns.Copy(k.K)
 		keys[i].Header().Name = zone
 	}
@@ -79,6 +124,37 @@ func (d Dnssec) getDNSKEY(state request.Request, zone string, do bool, server st
 	return m
 }
 
+// getCDS returns the CDS or CDNSKEY records, depending on qtype, for the keys the parent should have
+// a DS record of. Signatures are added when do is true. It returns nil when there are no such keys.
+func (d Dnssec) getCDS(state request.Request, zone string, qtype uint16, do bool, server string) *dns.Msg {
+	cds := d.keySet().cds
+	if len(cds) == 0 {
+		return nil
+	}
+	keys := make([]dns.RR, len(cds))
+	for i, k := range cds {
+		dk := dns.Copy(k.K).(*dns.DNSKEY)
+		dk.Hdr.Name = zone // the digest of the DS is over the owner name
+		if qtype == dns.TypeCDS {
+			keys[i] = dk.ToDS(dns.SHA256).ToCDS()
+		} else {
+			keys[i] = dk.ToCDNSKEY()
+		}
+	}
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.Answer = keys
+	if !do {
+		return m
+	}
+
+	incep, expir := incepExpir(time.Now().UTC())
+	if sigs, err := d.sign(keys, zone, 3600, incep, expir, server); err == nil {
+		m.Answer = append(m.Answer, sigs...)
+	}
+	return m
+}
+
 // Return true iff this is a zone key with the SEP bit unset. This implies a ZSK (rfc4034 2.1.1).
 func (k DNSKEY) isZSK() bool {
 	return k.K.Flags&(1<<8) == (1<<8) && k.K.Flags&1 == 0
@@ -3,6 +3,7 @@
 package dnssec
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
@@ -18,81 +19,141 @@ import (
 type Dnssec struct {
 	Next plugin.Handler
 
-	zones     []string
-	keys      []*DNSKEY
-	splitkeys bool
-	inflight  *singleflight.Group
-	cache     *cache.Cache
+	zones    []string
+	keys     *atomic.Value // *keySet, replaced when the keys roll over
+	cds      bool          // answer the CDS and CDNSKEY queries for the zones
+	nsec3    *nsec3Param   // deny with NSEC3 instead of NSEC, when set
+	inflight *singleflight.Group
+	cache    *cache.Cache
 }
 
 // New returns a new Dnssec.
 func New(zones []string, keys []*DNSKEY, splitkeys bool, next plugin.Handler, c *cache.Cache) Dnssec {
-	return Dnssec{Next: next,
-		zones:     zones,
-		keys:      keys,
-		splitkeys: splitkeys,
-		cache:     c,
-		inflight:  new(singleflight.Group),
+	d := Dnssec{Next: next,
+		zones:    zones,
+		keys:     new(atomic.Value),
+		cache:    c,
+		inflight: new(singleflight.Group),
 	}
+	d.keys.Store(newKeySet(keys, keys, splitkeys))
+	return d
 }
 
+// keySet returns the keys d currently signs with.
+func (d Dnssec) keySet() *keySet { return d.keys.Load().(*keySet) }
+
 // Sign signs the message in state. it takes care of negative or nodata responses. It
 // uses NSEC black lies for authenticated denial of existence. For delegations it
 // will insert DS records and sign those.
 // Signatures will be cached for a short while. By default we sign for 8 days,
 // starting 3 hours ago.
 func (d Dnssec) Sign(state request.Request, now time.Time, server string) *dns.Msg {
+	req, _ := d.signMsg(state, now, server)
+	return req
+}
+
+// signMsg is Sign, it also returns the first error signing the records, which are then left
+// unsigned.
+func (d Dnssec) signMsg(state request.Request, now time.Time, server string) (*dns.Msg, error) {
 	req := state.Req
 
 	incep, expir := incepExpir(now)
 
 	mt, _ := response.Typify(req, time.Now().UTC()) // TODO(miek): need opt record here?
+
+	var failed error
+	fail := func(err error) {
+		if failed == nil {
+			failed = err
+		}
+	}
+
 	if mt == response.Delegation {
-		return req
+		if d.nsec3 == nil {
+			return req, nil
+		}
+		// Sign the DS records of the delegation, or prove there are none.
+		ds := false
+		for _, r := range rrSets(req.Ns) {
+			if r[0].Header().Rrtype != dns.TypeDS {
+				continue
+			}
+			ds = true
+			if sigs, err := d.sign(r, state.Zone, r[0].Header().Ttl, incep, expir, server); err == nil {
+				req.Ns = append(req.Ns, sigs...)
+			} else {
+				fail(err)
+			}
+		}
+		if !ds {
+			ns := req.Ns[0].Header()
+			if sigs, err := d.nsec3Delegation(ns.Name, state.Zone, ns.Ttl, incep, expir, server); err == nil {
+				req.Ns = append(req.Ns, sigs...)
+			} else {
+				fail(err)
+			}
+		}
+		return req, failed
 	}
 
 	if mt == response.NameError || mt == response.NoData {
 		if req.Ns[0].Header().Rrtype != dns.TypeSOA || len(req.Ns) > 1 {
-			return req
+			return req, nil
 		}
 
 		ttl := req.Ns[0].Header().Ttl
 
 		if sigs, err := d.sign(req.Ns, state.Zone, ttl, incep, expir, server); err == nil {
 			req.Ns = append(req.Ns, sigs...)
+		} else {
+			fail(err)
 		}
-		if sigs, err := d.nsec(state, mt, ttl, incep, expir, server); err == nil {
+		deny := d.nsec
+		if d.nsec3 != nil {
+			deny = d.nsec3Deny
+		}
+		if sigs, err := deny(state, mt, ttl, incep, expir, server); err == nil {
 			req.Ns = append(req.Ns, sigs...)
+		} else {
+			fail(err)
 		}
 		if len(req.Ns) > 1 { // actually added nsec and sigs, reset the rcode
 			req.Rcode = dns.RcodeSuccess
 		}
-		return req
+		return req, failed
 	}
 
 	for _, r := range rrSets(req.Answer) {
 		ttl := r[0].Header().Ttl
 		if sigs, err := d.sign(r, state.Zone, ttl, incep, expir, server); err == nil {
 			req.Answer = append(req.Answer, sigs...)
+		} else {
+			fail(err)
 		}
 	}
 	for _, r := range rrSets(req.Ns) {
 		ttl := r[0].Header().Ttl
 		if sigs, err := d.sign(r, state.Zone, ttl, incep, expir, server); err == nil {
 			req.Ns = append(req.Ns, sigs...)
+		} else {
+			fail(err)
 		}
 	}
 	for _, r := range rrSets(req.Extra) {
 		ttl := r[0].Header().Ttl
 		if sigs, err := d.sign(r, state.Zone, ttl, incep, expir, server); err == nil {
 			req.Extra = append(req.Extra, sigs...)
+		} else {
+			fail(err)
 		}
 	}
-	return req
+	return req, failed
 }
 
 func (d Dnssec) sign(rrs []dns.RR, signerName string, ttl, incep, expir uint32, server string) ([]dns.RR, error) {
-	k := hash(rrs)
+	ks := d.keySet()
+	// The signatures made with keys that rolled over are not used anymore.
+	k := hash(rrs) ^ ks.hash
 	sgs, ok := d.get(k, server)
 	if ok {
 		return sgs, nil
@@ -100,8 +161,8 @@ func (d Dnssec) sign(rrs []dns.RR, signerName string, ttl, incep, expir uint32,
 
 	sigs, err := d.inflight.Do(k, func() (interface{}, error) {
 		var sigs []dns.RR
-		for _, k := range d.keys {
-			if d.splitkeys {
+		for _, k := range ks.signers {
+			if ks.splitkeys {
 				if len(rrs) > 0 && rrs[0].Header().Rrtype == dns.TypeDNSKEY {
 					// We are signing a DNSKEY RRSet. With split keys, we need to use a KSK here.
 					if !k.isKSK() {
@@ -42,7 +42,8 @@ func TestZoneSigningDouble(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to parse key: %v\n", err)
 	}
-	d.keys = append(d.keys, key1)
+	ks := d.keySet()
+	d.keys.Store(newKeySet(append(ks.dnskeys, key1), append(ks.signers, key1), ks.splitkeys))
 
 	m := testMsg()
 	state := request.Request{Req: m, Zone: "miek.nl."}
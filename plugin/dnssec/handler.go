@@ -39,8 +39,33 @@ func (d Dnssec) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		}
 	}
 
+	// And the NSEC3PARAM queries, when we deny with NSEC3.
+	if d.nsec3 != nil && qtype == dns.TypeNSEC3PARAM {
+		for _, z := range d.zones {
+			if qname == z {
+				resp := d.getNSEC3PARAM(state, z, do, server)
+				resp.Authoritative = true
+				w.WriteMsg(resp)
+				return dns.RcodeSuccess, nil
+			}
+		}
+	}
+
+	// And the CDS and CDNSKEY queries, when we publish those.
+	if d.cds && (qtype == dns.TypeCDS || qtype == dns.TypeCDNSKEY) {
+		for _, z := range d.zones {
+			if qname == z {
+				if resp := d.getCDS(state, z, qtype, do, server); resp != nil {
+					resp.Authoritative = true
+					w.WriteMsg(resp)
+					return dns.RcodeSuccess, nil
+				}
+			}
+		}
+	}
+
 	if do {
-		drr := &ResponseWriter{w, d, server}
+		drr := &ResponseWriter{ResponseWriter: w, d: d, server: server, req: r}
 		return plugin.NextOrFailure(d.Name(), d.Next, ctx, drr, r)
 	}
 
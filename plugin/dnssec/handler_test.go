@@ -2,12 +2,16 @@ package dnssec
 
 import (
 	"context"
+	"crypto"
+	"errors"
+	"io"
 	"strings"
 	"testing"
 
 	"github.com/coredns/coredns/plugin/file"
 	"github.com/coredns/coredns/plugin/pkg/cache"
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/ede"
 	"github.com/coredns/coredns/plugin/test"
 
 	"github.com/miekg/dns"
@@ -122,6 +126,40 @@ func TestLookupZone(t *testing.T) {
 	}
 }
 
+func TestLookupZoneSignFailure(t *testing.T) {
+	zone, err := file.Parse(strings.NewReader(dbMiekNL), "miek.nl.", "stdin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm := file.File{Next: test.ErrorHandler(), Zones: file.Zones{Z: map[string]*file.Zone{"miek.nl.": zone}, Names: []string{"miek.nl."}}}
+	dnskey, rm1, rm2 := newKey(t)
+	defer rm1()
+	defer rm2()
+	dnskey.s = failingSigner{dnskey.s}
+	dh := New([]string{"miek.nl."}, []*DNSKEY{dnskey}, false, fm, cache.New(defaultCap))
+
+	m := new(dns.Msg)
+	m.SetQuestion("a.miek.nl.", dns.TypeAAAA)
+	m.SetEdns0(4096, true)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := dh.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(rec.Msg.Answer) != 1 {
+		t.Errorf("Expected the unsigned answer, got %v", rec.Msg.Answer)
+	}
+	if code, _, ok := ede.Get(rec.Msg); !ok || code != ede.Other {
+		t.Errorf("Expected Extended DNS Error %d for the signing failure, got %d", ede.Other, code)
+	}
+}
+
+// failingSigner fails to sign anything.
+type failingSigner struct{ crypto.Signer }
+
+func (failingSigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, errors.New("no signing")
+}
+
 func TestLookupDNSKEY(t *testing.T) {
 	dnskey, rm1, rm2 := newKey(t)
 	defer rm1()
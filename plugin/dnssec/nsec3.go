@@ -0,0 +1,117 @@
+package dnssec
+
+import (
+	"encoding/base32"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/response"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// nsec3Param holds the NSEC3 parameters of the signed zones.
+type nsec3Param struct {
+	iterations uint16
+	salt       string // hex, empty for no salt
+	optout     bool
+}
+
+// maxIterations is the maximum of extra hash iterations, above it validators may treat the zone as
+// insecure. RFC 9276 recommends 0.
+const maxIterations = 150
+
+// nsec3Deny is nsec for NSEC3: it returns an NSEC3 that matches the hash of the qname, useful for
+// NXDOMAIN responses. With these black lies every NXDOMAIN answer is a NODATA one, and the range
+// between the hash of the qname and its successor doesn't reveal any other names in the zone.
+func (d Dnssec) nsec3Deny(state request.Request, mt response.Type, ttl, incep, expir uint32, server string) ([]dns.RR, error) {
+	var bitmap []uint16
+	if state.Name() == state.Zone {
+		bitmap = append(withoutNSEC(filter18(state.QType(), apexBitmap, mt)), dns.TypeNSEC3PARAM)
+		sort.Slice(bitmap, func(i, j int) bool { return bitmap[i] < bitmap[j] })
+	} else {
+		bitmap = withoutNSEC(filter14(state.QType(), zoneBitmap, mt))
+	}
+	return d.signNSEC3(state.QName(), state.Zone, bitmap, ttl, incep, expir, server)
+}
+
+// nsec3Delegation returns an NSEC3 that proves there is no DS record for the delegation to name.
+func (d Dnssec) nsec3Delegation(name, zone string, ttl, incep, expir uint32, server string) ([]dns.RR, error) {
+	return d.signNSEC3(name, zone, []uint16{dns.TypeNS}, ttl, incep, expir, server)
+}
+
+// signNSEC3 returns the signed NSEC3 for name, with the types in bitmap.
+func (d Dnssec) signNSEC3(name, zone string, bitmap []uint16, ttl, incep, expir uint32, server string) ([]dns.RR, error) {
+	h := dns.HashName(name, dns.SHA1, d.nsec3.iterations, d.nsec3.salt)
+	nsec3 := &dns.NSEC3{
+		Hash:       dns.SHA1,
+		Iterations: d.nsec3.iterations,
+		SaltLength: uint8(len(d.nsec3.salt) / 2),
+		Salt:       d.nsec3.salt,
+		HashLength: 20,
+		NextDomain: nextHash(h),
+		TypeBitMap: bitmap,
+	}
+	nsec3.Hdr = dns.RR_Header{Name: strings.ToLower(h) + "." + zone, Ttl: ttl, Class: dns.ClassINET, Rrtype: dns.TypeNSEC3}
+	if d.nsec3.optout {
+		nsec3.Flags = 1
+	}
+
+	sigs, err := d.sign([]dns.RR{nsec3}, zone, ttl, incep, expir, server)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(sigs, nsec3), nil
+}
+
+// getNSEC3PARAM returns the NSEC3PARAM record of zone. Signatures are added when do is true.
+func (d Dnssec) getNSEC3PARAM(state request.Request, zone string, do bool, server string) *dns.Msg {
+	param := &dns.NSEC3PARAM{
+		Hdr:        dns.RR_Header{Name: zone, Rrtype: dns.TypeNSEC3PARAM, Class: dns.ClassINET, Ttl: 0},
+		Hash:       dns.SHA1,
+		Iterations: d.nsec3.iterations,
+		SaltLength: uint8(len(d.nsec3.salt) / 2),
+		Salt:       d.nsec3.salt,
+	}
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.Answer = []dns.RR{param}
+	if !do {
+		return m
+	}
+
+	incep, expir := incepExpir(time.Now().UTC())
+	if sigs, err := d.sign(m.Answer, zone, 0, incep, expir, server); err == nil {
+		m.Answer = append(m.Answer, sigs...)
+	}
+	return m
+}
+
+// nextHash returns the base32 hash that follows h.
+func nextHash(h string) string {
+	b, err := base32.HexEncoding.DecodeString(h)
+	if err != nil {
+		return h
+	}
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			break
+		}
+	}
+	return base32.HexEncoding.EncodeToString(b)
+}
+
+// withoutNSEC returns bitmap without the NSEC type, which isn't there with NSEC3.
+func withoutNSEC(bitmap []uint16) []uint16 {
+	b := make([]uint16, 0, len(bitmap))
+	for _, t := range bitmap {
+		if t != dns.TypeNSEC {
+			b = append(b, t)
+		}
+	}
+	return b
+}
@@ -0,0 +1,133 @@
+package dnssec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// nsec3Of returns the NSEC3 records in rrs.
+func nsec3Of(rrs []dns.RR) []*dns.NSEC3 {
+	n := []*dns.NSEC3{}
+	for _, rr := range rrs {
+		if x, ok := rr.(*dns.NSEC3); ok {
+			n = append(n, x)
+		}
+	}
+	return n
+}
+
+func TestSigningNSEC3(t *testing.T) {
+	d, rm1, rm2 := newDnssec(t, []string{"miek.nl."})
+	defer rm1()
+	defer rm2()
+	d.nsec3 = &nsec3Param{iterations: 1, salt: "AABBCCDD", optout: true}
+
+	m := testEmptyMsg()
+	m.SetQuestion("a.miek.nl.", dns.TypeA)
+	m.Rcode = dns.RcodeNameError
+	state := request.Request{Req: m, Zone: "miek.nl."}
+	m = d.Sign(state, time.Now().UTC(), server)
+	if !section(m.Ns, 2) {
+		t.Errorf("Authority section should have 2 RRSIGs")
+	}
+	if m.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected NXDOMAIN to be turned into NODATA, got %s", dns.RcodeToString[m.Rcode])
+	}
+
+	n := nsec3Of(m.Ns)
+	if len(n) != 1 {
+		t.Fatalf("Expected 1 NSEC3, got %d", len(n))
+	}
+	if !n[0].Match("a.miek.nl.") {
+		t.Errorf("Expected the NSEC3 to match a.miek.nl.")
+	}
+	if n[0].Flags != 1 || n[0].Iterations != 1 || n[0].Salt != "AABBCCDD" {
+		t.Errorf("Expected the NSEC3 parameters, got %s", n[0])
+	}
+	for _, typ := range n[0].TypeBitMap {
+		if typ == dns.TypeA || typ == dns.TypeNSEC {
+			t.Errorf("Expected no %s in the bitmap", dns.TypeToString[typ])
+		}
+	}
+	if _, err := m.Pack(); err != nil {
+		t.Errorf("Expected the message to pack, got %s", err)
+	}
+}
+
+func TestSigningNSEC3Delegation(t *testing.T) {
+	d, rm1, rm2 := newDnssec(t, []string{"nl."})
+	defer rm1()
+	defer rm2()
+	d.nsec3 = &nsec3Param{}
+
+	m := testDelegationMsg()
+	m.SetQuestion("a.miek.nl.", dns.TypeA)
+	state := request.Request{Req: m, Zone: "nl."}
+	m = d.Sign(state, time.Now().UTC(), server)
+	n := nsec3Of(m.Ns)
+	if len(n) != 1 || !n[0].Match("miek.nl.") {
+		t.Fatalf("Expected an NSEC3 for miek.nl.")
+	}
+	if len(n[0].TypeBitMap) != 1 || n[0].TypeBitMap[0] != dns.TypeNS {
+		t.Errorf("Expected only NS in the bitmap, got %v", n[0].TypeBitMap)
+	}
+	if !section(m.Ns, 1) {
+		t.Errorf("Authority section should have 1 RRSIG")
+	}
+
+	// With a DS record, the DS is signed.
+	m = testDelegationMsg()
+	m.SetQuestion("a.miek.nl.", dns.TypeA)
+	m.Ns = append(m.Ns, test.DS("miek.nl.	3600	IN	DS	18512 13 2 D4C0A8E9E13F5A6CD01CF08A4C5F0A1D0F0B0C6A6F5E1C2D3B4A5F6E7D8C9B0A"))
+	state = request.Request{Req: m, Zone: "nl."}
+	m = d.Sign(state, time.Now().UTC(), server)
+	if len(nsec3Of(m.Ns)) != 0 {
+		t.Errorf("Expected no NSEC3 with a DS record")
+	}
+	if !section(m.Ns, 1) {
+		t.Errorf("Authority section should have 1 RRSIG")
+	}
+}
+
+func TestLookupNSEC3PARAM(t *testing.T) {
+	d, rm1, rm2 := newDnssec(t, []string{"miek.nl."})
+	defer rm1()
+	defer rm2()
+	d.Next = test.ErrorHandler()
+	d.nsec3 = &nsec3Param{}
+
+	r := new(dns.Msg)
+	r.SetQuestion("miek.nl.", dns.TypeNSEC3PARAM)
+	r.SetEdns0(4096, true)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := d.ServeDNS(context.TODO(), rec, r); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(rec.Msg.Answer) != 2 {
+		t.Fatalf("Expected an NSEC3PARAM and its signature, got %d records", len(rec.Msg.Answer))
+	}
+	param, ok := rec.Msg.Answer[0].(*dns.NSEC3PARAM)
+	if !ok || param.Iterations != 0 || param.Flags != 0 {
+		t.Errorf("Expected an NSEC3PARAM without iterations, got %s", rec.Msg.Answer[0])
+	}
+}
+
+func TestNextHash(t *testing.T) {
+	tests := []struct{ in, out string }{
+		{"00000000000000000000000000000000", "00000000000000000000000000000001"},
+		{"0000000000000000000000000000000V", "00000000000000000000000000000010"},
+		{"VVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVV", "00000000000000000000000000000000"},
+	}
+	for i, tc := range tests {
+		if out := nextHash(tc.in); out != tc.out {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.out, out)
+		}
+	}
+}
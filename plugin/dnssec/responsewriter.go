@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/ede"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -13,7 +14,8 @@ import (
 type ResponseWriter struct {
 	dns.ResponseWriter
 	d      Dnssec
-	server string // server label for metrics.
+	server string   // server label for metrics.
+	req    *dns.Msg // the request, to explain the signing failures with an Extended DNS Error.
 }
 
 // WriteMsg implements the dns.ResponseWriter interface.
@@ -28,7 +30,11 @@ func (d *ResponseWriter) WriteMsg(res *dns.Msg) error {
 	}
 	state.Zone = zone
 
-	res = d.d.Sign(state, time.Now().UTC(), d.server)
+	res, err := d.d.signMsg(state, time.Now().UTC(), d.server)
+	if err != nil && d.req != nil {
+		log.Warningf("Failed to sign the answer for %s: %s", state.Name(), err)
+		ede.Set(d.req, res, ede.Other, "failed to sign: "+err.Error())
+	}
 	cacheSize.WithLabelValues(d.server, "signature").Set(float64(d.d.cache.Len()))
 	// No need for EDNS0 trickery, as that is handled by the server.
 
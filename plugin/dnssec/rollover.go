@@ -0,0 +1,335 @@
+package dnssec
+
+import (
+	"bufio"
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// timing holds the times of the life cycle of a key, as dnssec-keygen writes them to the private key
+// file. A zero time is not set: the key is published or active from the start, or stays so forever.
+type timing struct {
+	created  time.Time
+	publish  time.Time // added to the DNSKEY RRset
+	activate time.Time // used for signing
+	inactive time.Time // not used for signing anymore
+	delete   time.Time // removed from the DNSKEY RRset
+}
+
+const timingFormat = "20060102150405"
+
+// published returns true if a key with timing t is in the DNSKEY RRset at now.
+func (t timing) published(now time.Time) bool {
+	return !now.Before(t.publish) && (t.delete.IsZero() || now.Before(t.delete))
+}
+
+// active returns true if a key with timing t signs at now.
+func (t timing) active(now time.Time) bool {
+	return t.published(now) && !now.Before(t.activate) && (t.inactive.IsZero() || now.Before(t.inactive))
+}
+
+// String returns the lines of t for a private key file.
+func (t timing) String() string {
+	b := &strings.Builder{}
+	for _, l := range []struct {
+		name string
+		t    time.Time
+	}{
+		{"Created", t.created}, {"Publish", t.publish}, {"Activate", t.activate}, {"Inactive", t.inactive}, {"Delete", t.delete},
+	} {
+		if !l.t.IsZero() {
+			fmt.Fprintf(b, "%s: %s\n", l.name, l.t.UTC().Format(timingFormat))
+		}
+	}
+	return b.String()
+}
+
+// readTiming reads the timing lines from the private key file name.
+func readTiming(name string) (timing, error) {
+	t := timing{}
+	f, err := os.Open(name)
+	if err != nil {
+		return t, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l := strings.SplitN(scanner.Text(), ":", 2)
+		if len(l) != 2 {
+			continue
+		}
+		var p *time.Time
+		switch strings.ToLower(l[0]) {
+		case "created":
+			p = &t.created
+		case "publish":
+			p = &t.publish
+		case "activate":
+			p = &t.activate
+		case "inactive":
+			p = &t.inactive
+		case "delete":
+			p = &t.delete
+		default:
+			continue
+		}
+		if *p, err = time.Parse(timingFormat, strings.TrimSpace(l[1])); err != nil {
+			return t, fmt.Errorf("bad %s time in %q: %s", l[0], name, err)
+		}
+	}
+	return t, scanner.Err()
+}
+
+// policy is how the zones are signed: how the keys are managed when they live in a key directory,
+// and what is published next to them.
+type policy struct {
+	dir       string
+	algorithm uint8
+	zsk       time.Duration // lifetime of a ZSK, zero is forever
+	ksk       time.Duration // lifetime of a KSK, zero is forever
+	cds       bool
+	nsec3     *nsec3Param // nil for NSEC
+}
+
+const (
+	// prePublish is how long a new ZSK is published before it signs, so the resolvers that cache the
+	// DNSKEY RRset have seen it. This is longer than the TTL of the DNSKEY RRset.
+	prePublish = 2 * time.Hour
+	// zskRetire is how long a ZSK that is rolled over stays published. The signatures that are made
+	// with it are valid for 8 days, see incepExpir.
+	zskRetire = eightDays
+	// kskRetire is how long a KSK that is rolled over keeps signing the DNSKEY RRset next to its
+	// successor, in which time the DS record of the parent zone is to be replaced.
+	kskRetire = 14 * 24 * time.Hour
+
+	defaultZSKLifetime = 30 * 24 * time.Hour
+	minLifetime        = 24 * time.Hour
+	manageInterval     = time.Minute
+)
+
+// manager generates and rolls over the keys of zone in a key directory.
+type manager struct {
+	policy
+	zone string
+
+	keys *atomic.Value // *keySet
+	stop chan struct{}
+}
+
+func newManager(zone string, p policy) *manager {
+	return &manager{policy: p, zone: zone, keys: new(atomic.Value), stop: make(chan struct{})}
+}
+
+// Run runs manage every manageInterval until Stop is called.
+func (m *manager) Run() {
+	tick := time.NewTicker(manageInterval)
+	go func() {
+		for {
+			select {
+			case <-tick.C:
+				if err := m.manage(time.Now().UTC()); err != nil {
+					log.Errorf("Failed to manage the keys of %s in %q: %s", m.zone, m.dir, err)
+				}
+			case <-m.stop:
+				tick.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops Run.
+func (m *manager) Stop() { close(m.stop) }
+
+// manageMu serializes manage, also across the managers of the instances a reload leaves behind.
+var manageMu sync.Mutex
+
+// manage reads the keys of the zone from the key directory, and generates the keys that are missing or
+// due for a rollover at now. Keys that are deleted are removed from the directory. The keys that are
+// published and active at now are stored in m.keys.
+func (m *manager) manage(now time.Time) error {
+	manageMu.Lock()
+	defer manageMu.Unlock()
+
+	keys, err := m.read()
+	if err != nil {
+		return err
+	}
+
+	for _, ksk := range []bool{false, true} {
+		lifetime, role := m.zsk, "ZSK"
+		if ksk {
+			lifetime, role = m.ksk, "KSK"
+		}
+
+		live := []*DNSKEY{}
+		for _, k := range keys {
+			if k.isKSK() == ksk && k.timing.inactive.IsZero() {
+				live = append(live, k)
+			}
+		}
+		if len(live) == 0 {
+			k, err := m.generate(ksk, timing{created: now, publish: now, activate: now})
+			if err != nil {
+				return err
+			}
+			log.Infof("Generated %s %d for %s", role, k.tag, m.zone)
+			keys = append(keys, k)
+			continue
+		}
+
+		for _, k := range live {
+			if lifetime == 0 || now.Before(rollAt(k, lifetime)) {
+				continue
+			}
+
+			// A ZSK is rolled over with pre-publication, a KSK with a double signature (RFC 6781,
+			// section 4.1).
+			t := timing{created: now, publish: now, activate: now}
+			k.timing.inactive, k.timing.delete = now.Add(kskRetire), now.Add(kskRetire)
+			if !ksk {
+				t.activate = now.Add(prePublish)
+				k.timing.inactive, k.timing.delete = t.activate, t.activate.Add(zskRetire)
+			}
+			k1, err := m.generate(ksk, t)
+			if err != nil {
+				return err
+			}
+			if err := m.write(k); err != nil {
+				return err
+			}
+			log.Infof("Rolling over %s %d of %s to %d", role, k.tag, m.zone, k1.tag)
+			keys = append(keys, k1)
+		}
+	}
+
+	dnskeys, signers := []*DNSKEY{}, []*DNSKEY{}
+	for _, k := range keys {
+		if !k.timing.delete.IsZero() && !now.Before(k.timing.delete) {
+			log.Infof("Removing deleted key %d of %s", k.tag, m.zone)
+			os.Remove(k.base + ".key")
+			os.Remove(k.base + ".private")
+			continue
+		}
+		if k.timing.published(now) {
+			dnskeys = append(dnskeys, k)
+		}
+		if k.timing.active(now) {
+			signers = append(signers, k)
+		}
+	}
+	ks := newKeySet(dnskeys, signers, true)
+	ks.hash = tagsHash(signers)
+	m.keys.Store(ks)
+	return nil
+}
+
+// rollAt returns the time at which k is rolled over, after lifetime. A ZSK is rolled over prePublish
+// earlier, so its successor signs when the lifetime of k has passed.
+func rollAt(k *DNSKEY, lifetime time.Duration) time.Time {
+	start := k.timing.activate
+	if start.IsZero() {
+		start = k.timing.created
+	}
+	if k.isZSK() {
+		return start.Add(lifetime - prePublish)
+	}
+	return start.Add(lifetime)
+}
+
+// read reads the keys of the zone from the key directory.
+func (m *manager) read() ([]*DNSKEY, error) {
+	privs, err := filepath.Glob(filepath.Join(m.dir, "K"+m.zone+"+*.private"))
+	if err != nil {
+		return nil, err
+	}
+	keys := []*DNSKEY{}
+	for _, priv := range privs {
+		base := priv[:len(priv)-len(".private")]
+		k, err := ParseKeyFile(base+".key", priv)
+		if err != nil {
+			return nil, err
+		}
+		if k.timing, err = readTiming(priv); err != nil {
+			return nil, err
+		}
+		k.base = base
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// generate generates a ZSK, or a KSK when ksk is true, with timing t and writes it to the key
+// directory.
+func (m *manager) generate(ksk bool, t timing) (*DNSKEY, error) {
+	dk := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: m.zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE,
+		Protocol:  3,
+		Algorithm: m.algorithm,
+	}
+	if ksk {
+		dk.Flags |= dns.SEP
+	}
+	priv, err := dk.Generate(algorithmBits[m.algorithm])
+	if err != nil {
+		return nil, err
+	}
+	s, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("no signer for algorithm %s", dns.AlgorithmToString[m.algorithm])
+	}
+
+	k := &DNSKEY{K: dk, D: dk.ToDS(dns.SHA256), s: s, tag: dk.KeyTag(), timing: t}
+	k.base = filepath.Join(m.dir, fmt.Sprintf("K%s+%03d+%05d", m.zone, dk.Algorithm, k.tag))
+	if err := writeFile(k.base+".key", []byte(dk.String()+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	return k, m.write(k)
+}
+
+// write writes the private key file of k, with its timing.
+func (m *manager) write(k *DNSKEY) error {
+	return writeFile(k.base+".private", []byte(k.K.PrivateKeyString(k.s)+k.timing.String()), 0600)
+}
+
+// algorithmBits are the algorithms keys can be generated for, with their key sizes.
+var algorithmBits = map[uint8]int{
+	dns.RSASHA256:       2048,
+	dns.RSASHA512:       2048,
+	dns.ECDSAP256SHA256: 256,
+	dns.ECDSAP384SHA384: 384,
+}
+
+// writeFile writes data to the file name. It is written to a temporary file first, which is then
+// renamed, so a key file is never seen half written.
+func writeFile(name string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails after the rename
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
@@ -0,0 +1,199 @@
+package dnssec
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func newManagerDir(t *testing.T, p policy) (*manager, func()) {
+	dir, err := ioutil.TempDir("", "coredns-dnssec")
+	if err != nil {
+		t.Fatalf("Failed to create a temporary directory: %s", err)
+	}
+	p.dir = dir
+	p.algorithm = dns.ECDSAP256SHA256
+	return newManager("miek.nl.", p), func() { os.RemoveAll(dir) }
+}
+
+// tags returns the key tags of keys.
+func tags(keys []*DNSKEY) map[uint16]bool {
+	m := map[uint16]bool{}
+	for _, k := range keys {
+		m[k.tag] = true
+	}
+	return m
+}
+
+func TestManageZSKRollover(t *testing.T) {
+	m, rm := newManagerDir(t, policy{zsk: 10 * 24 * time.Hour})
+	defer rm()
+
+	now := time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)
+	if err := m.manage(now); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	ks := m.keys.Load().(*keySet)
+	if len(ks.dnskeys) != 2 || len(ks.signers) != 2 || !ks.splitkeys {
+		t.Fatalf("Expected a new ZSK and KSK, got %d keys and %d signers", len(ks.dnskeys), len(ks.signers))
+	}
+	zsk, ksk := ks.signers[0], ks.signers[1]
+	if !zsk.isZSK() || !ksk.isKSK() {
+		t.Fatalf("Expected a ZSK and a KSK")
+	}
+	if len(ks.cds) != 1 || ks.cds[0].tag != ksk.tag {
+		t.Errorf("Expected the KSK as CDS, got %d keys", len(ks.cds))
+	}
+
+	// Nothing changes until the ZSK is rolled over; the keys are read back from the directory.
+	if err := m.manage(now.Add(24 * time.Hour)); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if ks1 := m.keys.Load().(*keySet); len(ks1.dnskeys) != 2 || ks1.hash != ks.hash {
+		t.Errorf("Expected the same 2 keys, got %d", len(ks1.dnskeys))
+	}
+
+	// The successor of the ZSK is published, but doesn't sign yet.
+	roll := now.Add(10*24*time.Hour - prePublish)
+	if err := m.manage(roll); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	ks = m.keys.Load().(*keySet)
+	if len(ks.dnskeys) != 3 {
+		t.Fatalf("Expected 3 published keys, got %d", len(ks.dnskeys))
+	}
+	if s := tags(ks.signers); len(s) != 2 || !s[zsk.tag] || !s[ksk.tag] {
+		t.Errorf("Expected the old keys to sign, got %v", s)
+	}
+
+	// After pre-publication the successor signs, and the old ZSK stays published.
+	if err := m.manage(roll.Add(prePublish)); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	ks = m.keys.Load().(*keySet)
+	if s := tags(ks.signers); len(s) != 2 || s[zsk.tag] || !s[ksk.tag] {
+		t.Errorf("Expected the new ZSK and the KSK to sign, got %v", s)
+	}
+	if d := tags(ks.dnskeys); len(d) != 3 || !d[zsk.tag] {
+		t.Errorf("Expected the old ZSK to be published, got %v", d)
+	}
+
+	// And it's deleted when its signatures have expired.
+	if err := m.manage(roll.Add(prePublish + zskRetire)); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	ks = m.keys.Load().(*keySet)
+	if d := tags(ks.dnskeys); len(d) != 2 || d[zsk.tag] {
+		t.Errorf("Expected the old ZSK to be deleted, got %v", d)
+	}
+	privs, _ := filepath.Glob(filepath.Join(m.dir, "*.private"))
+	if len(privs) != 2 {
+		t.Errorf("Expected the key files of the old ZSK to be removed, got %d private keys", len(privs))
+	}
+}
+
+func TestManageKSKRollover(t *testing.T) {
+	m, rm := newManagerDir(t, policy{ksk: 365 * 24 * time.Hour})
+	defer rm()
+
+	now := time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)
+	if err := m.manage(now); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	ksk := m.keys.Load().(*keySet).cds[0]
+
+	// Both KSKs sign the DNSKEY RRset, the new one is the CDS.
+	roll := now.Add(365 * 24 * time.Hour)
+	if err := m.manage(roll); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	ks := m.keys.Load().(*keySet)
+	if len(ks.signers) != 3 || len(ks.dnskeys) != 3 {
+		t.Fatalf("Expected 3 signing keys, got %d", len(ks.signers))
+	}
+	if len(ks.cds) != 1 || ks.cds[0].tag == ksk.tag || !ks.cds[0].isKSK() {
+		t.Errorf("Expected the new KSK as CDS")
+	}
+
+	if err := m.manage(roll.Add(kskRetire)); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	ks = m.keys.Load().(*keySet)
+	if d := tags(ks.dnskeys); len(d) != 2 || d[ksk.tag] {
+		t.Errorf("Expected the old KSK to be deleted, got %v", d)
+	}
+}
+
+func TestTiming(t *testing.T) {
+	tm := timing{
+		created:  time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC),
+		publish:  time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC),
+		activate: time.Date(2019, 7, 1, 2, 0, 0, 0, time.UTC),
+		delete:   time.Date(2019, 8, 1, 2, 0, 0, 0, time.UTC),
+	}
+	f, rm, err := test.TempFile(".", privKey+tm.String())
+	if err != nil {
+		t.Fatalf("Failed to create a private key file: %s", err)
+	}
+	defer rm()
+
+	tm1, err := readTiming(f)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if tm1 != tm {
+		t.Errorf("Expected %v, got %v", tm, tm1)
+	}
+	if tm.active(tm.publish) || !tm.published(tm.publish) || !tm.active(tm.activate) || tm.published(tm.delete) {
+		t.Errorf("Expected the key to be published, then active and then deleted")
+	}
+}
+
+func TestLookupCDS(t *testing.T) {
+	m, rm := newManagerDir(t, policy{})
+	defer rm()
+	if err := m.manage(time.Now().UTC()); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	d := New([]string{"miek.nl."}, nil, false, test.ErrorHandler(), cache.New(defaultCap))
+	d.keys, d.cds = m.keys, true
+	ksk := m.keys.Load().(*keySet).cds[0]
+
+	for _, qtype := range []uint16{dns.TypeCDS, dns.TypeCDNSKEY} {
+		r := new(dns.Msg)
+		r.SetQuestion("miek.nl.", qtype)
+		r.SetEdns0(4096, true)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := d.ServeDNS(context.TODO(), rec, r); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		if len(rec.Msg.Answer) != 2 {
+			t.Fatalf("Expected a %s record and its signature, got %d records", dns.TypeToString[qtype], len(rec.Msg.Answer))
+		}
+		switch rr := rec.Msg.Answer[0].(type) {
+		case *dns.CDS:
+			if rr.KeyTag != ksk.tag {
+				t.Errorf("Expected the CDS of key %d, got %d", ksk.tag, rr.KeyTag)
+			}
+		case *dns.CDNSKEY:
+			if rr.KeyTag() != ksk.tag {
+				t.Errorf("Expected the CDNSKEY of key %d, got %d", ksk.tag, rr.KeyTag())
+			}
+		default:
+			t.Errorf("Expected a %s record, got %s", dns.TypeToString[qtype], rec.Msg.Answer[0])
+		}
+		if sig, ok := rec.Msg.Answer[1].(*dns.RRSIG); !ok || sig.TypeCovered != qtype {
+			t.Errorf("Expected a signature over the %s record", dns.TypeToString[qtype])
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package dnssec
 
 import (
+	"encoding/hex"
 	"fmt"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
@@ -13,6 +15,7 @@ import (
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 
 	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
 )
 
 var log = clog.NewWithPlugin("dnssec")
@@ -25,14 +28,29 @@ func init() {
 }
 
 func setup(c *caddy.Controller) error {
-	zones, keys, capacity, splitkeys, err := dnssecParse(c)
+	zones, keys, capacity, splitkeys, p, err := dnssecParse(c)
 	if err != nil {
 		return plugin.Error("dnssec", err)
 	}
 
+	var m *manager
+	if p.dir != "" {
+		m = newManager(zones[0], p)
+		if err := m.manage(time.Now().UTC()); err != nil {
+			return plugin.Error("dnssec", err)
+		}
+		c.OnStartup(func() error { m.Run(); return nil })
+		c.OnShutdown(func() error { m.Stop(); return nil })
+	}
+
 	ca := cache.New(capacity)
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
-		return New(zones, keys, splitkeys, next, ca)
+		d := New(zones, keys, splitkeys, next, ca)
+		d.cds, d.nsec3 = p.cds, p.nsec3
+		if m != nil {
+			d.keys = m.keys
+		}
+		return d
 	})
 
 	c.OnStartup(func() error {
@@ -43,17 +61,20 @@ func setup(c *caddy.Controller) error {
 	return nil
 }
 
-func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, bool, error) {
+func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, bool, policy, error) {
 	zones := []string{}
 
 	keys := []*DNSKEY{}
+	p := policy{algorithm: dns.ECDSAP256SHA256, zsk: defaultZSKLifetime}
+	managed := false // algorithm or rollover is set
+	optout := false
 
 	capacity := defaultCap
 
 	i := 0
 	for c.Next() {
 		if i > 0 {
-			return nil, nil, 0, false, plugin.ErrOnce
+			return nil, nil, 0, false, p, plugin.ErrOnce
 		}
 		i++
 
@@ -69,23 +90,103 @@ func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, bool, error) {
 
 			switch x := c.Val(); x {
 			case "key":
+				if !c.NextArg() {
+					return nil, nil, 0, false, p, c.ArgErr()
+				}
+				if c.Val() == "directory" {
+					if !c.NextArg() {
+						return nil, nil, 0, false, p, c.ArgErr()
+					}
+					p.dir = c.Val()
+					if !filepath.IsAbs(p.dir) && dnsserver.GetConfig(c).Root != "" {
+						p.dir = filepath.Join(dnsserver.GetConfig(c).Root, p.dir)
+					}
+					if c.NextArg() {
+						return nil, nil, 0, false, p, c.ArgErr()
+					}
+					continue
+				}
 				k, e := keyParse(c)
 				if e != nil {
-					return nil, nil, 0, false, e
+					return nil, nil, 0, false, p, e
 				}
 				keys = append(keys, k...)
+			case "algorithm":
+				if !c.NextArg() {
+					return nil, nil, 0, false, p, c.ArgErr()
+				}
+				alg, ok := dns.StringToAlgorithm[strings.ToUpper(c.Val())]
+				if _, ok1 := algorithmBits[alg]; !ok || !ok1 {
+					return nil, nil, 0, false, p, c.Errf("unsupported algorithm '%s'", c.Val())
+				}
+				p.algorithm = alg
+				managed = true
+			case "rollover":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, nil, 0, false, p, c.ArgErr()
+				}
+				lifetime, err := time.ParseDuration(args[1])
+				if err != nil {
+					return nil, nil, 0, false, p, err
+				}
+				if lifetime < minLifetime {
+					return nil, nil, 0, false, p, c.Errf("rollover of less than %s: %s", minLifetime, lifetime)
+				}
+				switch args[0] {
+				case "zsk":
+					p.zsk = lifetime
+				case "ksk":
+					p.ksk = lifetime
+				default:
+					return nil, nil, 0, false, p, c.Errf("unknown key type '%s'", args[0])
+				}
+				managed = true
+			case "cds":
+				if c.NextArg() {
+					return nil, nil, 0, false, p, c.ArgErr()
+				}
+				p.cds = true
+			case "nsec3":
+				args := c.RemainingArgs()
+				if len(args) > 2 {
+					return nil, nil, 0, false, p, c.ArgErr()
+				}
+				p.nsec3 = &nsec3Param{}
+				if len(args) > 0 {
+					iter, err := strconv.Atoi(args[0])
+					if err != nil {
+						return nil, nil, 0, false, p, err
+					}
+					if iter < 0 || iter > maxIterations {
+						return nil, nil, 0, false, p, c.Errf("iterations must be between 0 and %d: %d", maxIterations, iter)
+					}
+					p.nsec3.iterations = uint16(iter)
+				}
+				if len(args) > 1 && args[1] != "-" {
+					salt, err := hex.DecodeString(args[1])
+					if err != nil || len(salt) > 255 {
+						return nil, nil, 0, false, p, c.Errf("invalid salt '%s'", args[1])
+					}
+					p.nsec3.salt = strings.ToUpper(args[1])
+				}
+			case "optout":
+				if c.NextArg() {
+					return nil, nil, 0, false, p, c.ArgErr()
+				}
+				optout = true
 			case "cache_capacity":
 				if !c.NextArg() {
-					return nil, nil, 0, false, c.ArgErr()
+					return nil, nil, 0, false, p, c.ArgErr()
 				}
 				value := c.Val()
 				cacheCap, err := strconv.Atoi(value)
 				if err != nil {
-					return nil, nil, 0, false, err
+					return nil, nil, 0, false, p, err
 				}
 				capacity = cacheCap
 			default:
-				return nil, nil, 0, false, c.Errf("unknown property '%s'", x)
+				return nil, nil, 0, false, p, c.Errf("unknown property '%s'", x)
 			}
 
 		}
@@ -94,6 +195,23 @@ func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, bool, error) {
 		zones[i] = plugin.Host(zones[i]).Normalize()
 	}
 
+	if p.dir != "" {
+		if len(keys) > 0 {
+			return nil, nil, 0, false, p, fmt.Errorf("key file and key directory can not be combined")
+		}
+		if len(zones) != 1 {
+			return nil, nil, 0, false, p, fmt.Errorf("key directory needs exactly one zone, got %d", len(zones))
+		}
+	} else if managed {
+		return nil, nil, 0, false, p, fmt.Errorf("algorithm and rollover need a key directory")
+	}
+	if optout {
+		if p.nsec3 == nil {
+			return nil, nil, 0, false, p, fmt.Errorf("optout needs nsec3")
+		}
+		p.nsec3.optout = true
+	}
+
 	// Check if we have both KSKs and ZSKs.
 	zsk, ksk := 0, 0
 	for _, k := range keys {
@@ -116,20 +234,17 @@ func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, bool, error) {
 			}
 		}
 		if !ok {
-			return zones, keys, capacity, splitkeys, fmt.Errorf("key %s (keyid: %d) can not sign any of the zones", string(kname), k.tag)
+			return zones, keys, capacity, splitkeys, p, fmt.Errorf("key %s (keyid: %d) can not sign any of the zones", string(kname), k.tag)
 		}
 	}
 
-	return zones, keys, capacity, splitkeys, nil
+	return zones, keys, capacity, splitkeys, p, nil
 }
 
 func keyParse(c *caddy.Controller) ([]*DNSKEY, error) {
 	keys := []*DNSKEY{}
 	config := dnsserver.GetConfig(c)
 
-	if !c.NextArg() {
-		return nil, c.ArgErr()
-	}
 	value := c.Val()
 	if value == "file" {
 		ks := c.RemainingArgs()
@@ -5,8 +5,10 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
 )
 
 func TestSetupDnssec(t *testing.T) {
@@ -78,11 +80,75 @@ func TestSetupDnssec(t *testing.T) {
 				key file ksk_Kcluster.local
 			}`, false, []string{"cluster.local."}, nil, true, defaultCap, "",
 		},
+		{
+			`dnssec example.org {
+				key directory keys
+				algorithm rsasha256
+				rollover zsk 720h
+				rollover ksk 8760h
+				cds
+			}`, false, []string{"example.org."}, nil, false, defaultCap, "",
+		},
+		{
+			`dnssec cluster.local {
+				key file Kcluster.local
+				key directory keys
+			}`, true, nil, nil, false, defaultCap, "can not be combined",
+		},
+		{
+			`dnssec example.org example.net {
+				key directory keys
+			}`, true, nil, nil, false, defaultCap, "exactly one zone",
+		},
+		{
+			`dnssec example.org {
+				rollover zsk 720h
+			}`, true, nil, nil, false, defaultCap, "need a key directory",
+		},
+		{
+			`dnssec example.org {
+				key directory keys
+				algorithm ed25519
+			}`, true, nil, nil, false, defaultCap, "unsupported algorithm",
+		},
+		{
+			`dnssec example.org {
+				key directory keys
+				rollover zsk 1h
+			}`, true, nil, nil, false, defaultCap, "rollover of less than",
+		},
+		{
+			`dnssec example.org {
+				key directory keys
+				rollover csk 720h
+			}`, true, nil, nil, false, defaultCap, "unknown key type",
+		},
+		{
+			`dnssec example.org {
+				nsec3 0 -
+				optout
+			}`, false, []string{"example.org."}, nil, false, defaultCap, "",
+		},
+		{
+			`dnssec example.org {
+				nsec3 500
+			}`, true, nil, nil, false, defaultCap, "iterations must be",
+		},
+		{
+			`dnssec example.org {
+				nsec3 0 salty
+			}`, true, nil, nil, false, defaultCap, "invalid salt",
+		},
+		{
+			`dnssec example.org {
+				optout
+			}`, true, nil, nil, false, defaultCap, "optout needs nsec3",
+		},
 	}
 
 	for i, test := range tests {
 		c := caddy.NewTestController("dns", test.input)
-		zones, keys, capacity, splitkeys, err := dnssecParse(c)
+		zones, keys, capacity, splitkeys, _, err := dnssecParse(c)
 
 		if test.shouldErr && err == nil {
 			t.Errorf("Test %d: Expected error but found %s for input %s", i, err, test.input)
@@ -159,3 +225,34 @@ Created: 20170901060531
 Publish: 20170901060531
 Activate: 20170901060531
 `
+
+func TestSetupDnssecPolicy(t *testing.T) {
+	c := caddy.NewTestController("dns", `dnssec example.org {
+		key directory /etc/coredns/keys
+		rollover ksk 8760h
+		cds
+	}`)
+	_, _, _, _, p, err := dnssecParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	expected := policy{dir: "/etc/coredns/keys", algorithm: dns.ECDSAP256SHA256, zsk: defaultZSKLifetime, ksk: 8760 * time.Hour, cds: true}
+	if p != expected {
+		t.Errorf("Expected policy %v, got %v", expected, p)
+	}
+}
+
+func TestSetupDnssecNSEC3(t *testing.T) {
+	c := caddy.NewTestController("dns", `dnssec example.org {
+		optout
+		nsec3 5 aabbccdd
+	}`)
+	_, _, _, _, p, err := dnssecParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	expected := nsec3Param{iterations: 5, salt: "AABBCCDD", optout: true}
+	if p.nsec3 == nil || *p.nsec3 != expected {
+		t.Errorf("Expected NSEC3 parameters %v, got %v", expected, p.nsec3)
+	}
+}
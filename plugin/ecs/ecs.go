@@ -0,0 +1,134 @@
+// Package ecs implements a plugin that adds an EDNS0 Client Subnet option, derived from the address of
+// the client, to the queries that arrive over encrypted transports.
+package ecs
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// ECS is a plugin that adds the subnet of the client to the queries of Transports, for the
+// upstreams to answer for the location of the client instead of the location of the server. A query
+// that already has a Client Subnet option is left alone, the client chose what to reveal.
+type ECS struct {
+	Next       plugin.Handler
+	Transports []string
+
+	v4Prefix uint8
+	v6Prefix uint8
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (e *ECS) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	if !e.transport(ctx) {
+		return plugin.NextOrFailure(e.Name(), e.Next, ctx, w, r)
+	}
+	state := request.Request{W: w, Req: r}
+	subnet := e.subnet(state)
+	if subnet == nil {
+		return plugin.NextOrFailure(e.Name(), e.Next, ctx, w, r)
+	}
+
+	o := r.IsEdns0()
+	added := o == nil
+	if added {
+		r.SetEdns0(dns.MinMsgSize, false)
+		o = r.IsEdns0()
+	}
+	for _, opt := range o.Option {
+		if _, ok := opt.(*dns.EDNS0_SUBNET); ok {
+			return plugin.NextOrFailure(e.Name(), e.Next, ctx, w, r)
+		}
+	}
+	o.Option = append(o.Option, subnet)
+
+	rw := &ResponseWriter{ResponseWriter: w, removeOPT: added}
+	return plugin.NextOrFailure(e.Name(), e.Next, ctx, rw, r)
+}
+
+// Name implements the plugin.Handler interface.
+func (e *ECS) Name() string { return "ecs" }
+
+// transport returns true when the query in ctx arrived over one of the transports of e.
+func (e *ECS) transport(ctx context.Context) bool {
+	s, ok := ctx.Value(dnsserver.Key{}).(*dnsserver.Server)
+	if !ok {
+		return false
+	}
+	scheme := transport.DNS
+	if i := strings.Index(s.Addr, "://"); i > 0 {
+		scheme = s.Addr[:i]
+	}
+	for _, t := range e.Transports {
+		if t == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// subnet returns the Client Subnet option of the address of the client, nil when it has none.
+func (e *ECS) subnet(state request.Request) *dns.EDNS0_SUBNET {
+	ip := net.ParseIP(state.IP())
+	if ip == nil {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &dns.EDNS0_SUBNET{
+			Code:          dns.EDNS0SUBNET,
+			Family:        1,
+			SourceNetmask: e.v4Prefix,
+			Address:       ip4.Mask(net.CIDRMask(int(e.v4Prefix), 8*net.IPv4len)),
+		}
+	}
+	return &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        2,
+		SourceNetmask: e.v6Prefix,
+		Address:       ip.Mask(net.CIDRMask(int(e.v6Prefix), 8*net.IPv6len)),
+	}
+}
+
+// ResponseWriter removes the Client Subnet option from the response, the client didn't ask for it.
+// When the query had no OPT record, the OPT record of the response is removed as well.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	removeOPT bool
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	for i := 0; i < len(res.Extra); i++ {
+		o, ok := res.Extra[i].(*dns.OPT)
+		if !ok {
+			continue
+		}
+		if w.removeOPT {
+			res.Extra = append(res.Extra[:i], res.Extra[i+1:]...)
+			i--
+			continue
+		}
+		opts := o.Option[:0]
+		for _, opt := range o.Option {
+			if _, ok := opt.(*dns.EDNS0_SUBNET); !ok {
+				opts = append(opts, opt)
+			}
+		}
+		o.Option = opts
+	}
+	return w.ResponseWriter.WriteMsg(res)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) Write(buf []byte) (int, error) {
+	log.Warning("ResponseWriter called with Write: not removing the Client Subnet option")
+	return w.ResponseWriter.Write(buf)
+}
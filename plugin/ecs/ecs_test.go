@@ -0,0 +1,92 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func serverContext(t *testing.T, addr string) context.Context {
+	cfg := &dnsserver.Config{Zone: ".", ListenHosts: []string{"127.0.0.1"}}
+	s, err := dnsserver.NewServer(addr, []*dnsserver.Config{cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return context.WithValue(context.TODO(), dnsserver.Key{}, s)
+}
+
+func TestECS(t *testing.T) {
+	tests := []struct {
+		addr     string
+		w        dns.ResponseWriter
+		edns     bool
+		subnet   *dns.EDNS0_SUBNET // the option of the query
+		expected string            // the subnet the next plugin sees, empty for none
+	}{
+		{"tls://127.0.0.1:853", &test.ResponseWriter{TCP: true}, false, nil, "10.240.0.0/24"},
+		{"https://127.0.0.1:443", &test.ResponseWriter{TCP: true}, true, nil, "10.240.0.0/24"},
+		{"https://127.0.0.1:443", &test.ResponseWriter6{ResponseWriter: test.ResponseWriter{TCP: true}}, true, nil, "fe80::/56"},
+		// Not over one of the transports.
+		{"dns://127.0.0.1:53", &test.ResponseWriter{}, true, nil, ""},
+		// The client chose its own subnet.
+		{"tls://127.0.0.1:853", &test.ResponseWriter{TCP: true}, true,
+			&dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 0, Address: []byte{0, 0, 0, 0}}, "0.0.0.0/0"},
+	}
+
+	for i, tc := range tests {
+		var seen string
+		next := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			if o := r.IsEdns0(); o != nil {
+				for _, opt := range o.Option {
+					if s, ok := opt.(*dns.EDNS0_SUBNET); ok {
+						seen = fmt.Sprintf("%s/%d", net.IP(s.Address), s.SourceNetmask)
+					}
+				}
+			}
+			// Answer like an upstream that echoes the option.
+			m := new(dns.Msg)
+			m.SetReply(r)
+			if o := r.IsEdns0(); o != nil {
+				m.Extra = append(m.Extra, o)
+			}
+			w.WriteMsg(m)
+			return dns.RcodeSuccess, nil
+		})
+		e := &ECS{Next: next, Transports: []string{"tls", "https"}, v4Prefix: 24, v6Prefix: 56}
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		if tc.edns {
+			m.SetEdns0(4096, false)
+			if tc.subnet != nil {
+				o := m.IsEdns0()
+				o.Option = append(o.Option, tc.subnet)
+			}
+		}
+		rec := dnstest.NewRecorder(tc.w)
+		if _, err := e.ServeDNS(serverContext(t, tc.addr), rec, m); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+
+		if seen != tc.expected {
+			t.Errorf("Test %d: expected subnet %q, got %q", i, tc.expected, seen)
+		}
+
+		// The response keeps the client's own option only, and has an OPT record when the query had.
+		o := rec.Msg.IsEdns0()
+		if (o != nil) != tc.edns {
+			t.Errorf("Test %d: expected an OPT record %t, got %t", i, tc.edns, o != nil)
+		}
+		if o != nil && tc.subnet == nil && len(o.Option) > 0 {
+			t.Errorf("Test %d: expected no option in the response, got %v", i, o.Option)
+		}
+	}
+}
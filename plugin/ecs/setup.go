@@ -0,0 +1,101 @@
+package ecs
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/caddyserver/caddy"
+)
+
+var log = clog.NewWithPlugin("ecs")
+
+func init() {
+	caddy.RegisterPlugin("ecs", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	e, err := parse(c)
+	if err != nil {
+		return plugin.Error("ecs", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		e.Next = next
+		return e
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*ECS, error) {
+	e := &ECS{
+		Transports: []string{transport.TLS, transport.HTTPS, transport.HTTP},
+		v4Prefix:   24,
+		v6Prefix:   56,
+	}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) > 2 {
+			return nil, c.ArgErr()
+		}
+		if len(args) > 0 {
+			p, err := prefix(c, args[0], 8*net.IPv4len)
+			if err != nil {
+				return nil, err
+			}
+			e.v4Prefix = p
+		}
+		if len(args) > 1 {
+			p, err := prefix(c, args[1], 8*net.IPv6len)
+			if err != nil {
+				return nil, err
+			}
+			e.v6Prefix = p
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "transports":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, t := range args {
+					switch t {
+					case transport.DNS, transport.TLS, transport.GRPC, transport.HTTPS, transport.HTTP, transport.QUIC, transport.H3, transport.DTLS:
+					default:
+						return nil, c.Errf("unknown transport '%s'", t)
+					}
+				}
+				e.Transports = args
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return e, nil
+}
+
+// prefix parses the prefix length s of an address of bits.
+func prefix(c *caddy.Controller, s string, bits int) (uint8, error) {
+	p, err := strconv.Atoi(s)
+	if err != nil || p < 0 || p > bits {
+		return 0, c.Errf("invalid prefix length '%s', must be between 0 and %d", s, bits)
+	}
+	return uint8(p), nil
+}
@@ -0,0 +1,52 @@
+package ecs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input      string
+		shouldErr  bool
+		v4, v6     uint8
+		transports []string
+	}{
+		{`ecs`, false, 24, 56, []string{"tls", "https", "http"}},
+		{`ecs 20`, false, 20, 56, []string{"tls", "https", "http"}},
+		{`ecs 32 0`, false, 32, 0, []string{"tls", "https", "http"}},
+		{"ecs {\ntransports https\n}", false, 24, 56, []string{"https"}},
+		{"ecs 16 48 {\ntransports dns tls\n}", false, 16, 48, []string{"dns", "tls"}},
+		{`ecs 33`, true, 0, 0, nil},
+		{`ecs 24 129`, true, 0, 0, nil},
+		{`ecs -1`, true, 0, 0, nil},
+		{`ecs 24 56 64`, true, 0, 0, nil},
+		{"ecs {\ntransports\n}", true, 0, 0, nil},
+		{"ecs {\ntransports udp\n}", true, 0, 0, nil},
+		{"ecs {\nblah\n}", true, 0, 0, nil},
+		{"ecs\necs", true, 0, 0, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		e, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if e.v4Prefix != tc.v4 || e.v6Prefix != tc.v6 {
+			t.Errorf("Test %d: expected prefixes %d %d, got %d %d", i, tc.v4, tc.v6, e.v4Prefix, e.v6Prefix)
+		}
+		if !reflect.DeepEqual(e.Transports, tc.transports) {
+			t.Errorf("Test %d: expected transports %v, got %v", i, tc.transports, e.Transports)
+		}
+	}
+}
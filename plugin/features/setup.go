@@ -0,0 +1,77 @@
+// Package features implements a plugin that sets the feature gates.
+package features
+
+import (
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/feature"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("features", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	states, err := parse(c)
+	if err != nil {
+		return plugin.Error("features", err)
+	}
+	config := dnsserver.GetConfig(c)
+	if config.Features == nil {
+		config.Features = map[string]bool{}
+	}
+	for name, enabled := range states {
+		config.Features[name] = enabled
+	}
+	return nil
+}
+
+func parse(c *caddy.Controller) (map[string]bool, error) {
+	states := map[string]bool{}
+	set := func(names []string, enabled bool) error {
+		if len(names) == 0 {
+			return c.ArgErr()
+		}
+		for _, name := range names {
+			if feature.Lookup(name) == nil {
+				return c.Errf("unknown feature gate %q", name)
+			}
+			if s, ok := states[name]; ok && s != enabled {
+				return c.Errf("feature gate %q is both enabled and disabled", name)
+			}
+			states[name] = enabled
+		}
+		return nil
+	}
+
+	for c.Next() {
+		if args := c.RemainingArgs(); len(args) > 0 {
+			if err := set(args, true); err != nil {
+				return nil, err
+			}
+		}
+		for c.NextBlock() {
+			var err error
+			switch c.Val() {
+			case "enable":
+				err = set(c.RemainingArgs(), true)
+			case "disable":
+				err = set(c.RemainingArgs(), false)
+			default:
+				return nil, c.Errf("unknown property %q", c.Val())
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(states) == 0 {
+		return nil, c.ArgErr()
+	}
+	return states, nil
+}
@@ -0,0 +1,64 @@
+package features
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/feature"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	feature.Register("test_alpha", "A gate disabled by default.", false)
+	feature.Register("test_beta", "A gate enabled by default.", true)
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  map[string]bool
+	}{
+		{`features test_alpha`, false, map[string]bool{"test_alpha": true}},
+		{`features test_alpha test_beta`, false, map[string]bool{"test_alpha": true, "test_beta": true}},
+		{`features {
+			enable test_alpha
+			disable test_beta
+		}`, false, map[string]bool{"test_alpha": true, "test_beta": false}},
+		{`features test_alpha {
+			disable test_beta
+		}`, false, map[string]bool{"test_alpha": true, "test_beta": false}},
+		{"features test_alpha\nfeatures {\ndisable test_beta\n}", false, map[string]bool{"test_alpha": true, "test_beta": false}},
+		{`features`, true, nil},
+		{`features nosuchgate`, true, nil},
+		{`features {
+			enable
+		}`, true, nil},
+		{`features {
+			switch test_alpha
+		}`, true, nil},
+		{`features test_alpha {
+			disable test_alpha
+		}`, true, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got %s", i, err)
+			continue
+		}
+		if got := dnsserver.GetConfig(c).Features; !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Test %d: Expected features %v, got %v", i, tc.expected, got)
+		}
+	}
+}
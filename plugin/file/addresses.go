@@ -0,0 +1,21 @@
+package file
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// Addresses implements the autoptr.Addresser interface.
+func (f File) Addresses(fn func(name string, ip net.IP)) {
+	for _, z := range f.Zones.Z {
+		for _, rr := range z.All() {
+			switch x := rr.(type) {
+			case *dns.A:
+				fn(x.Hdr.Name, x.A)
+			case *dns.AAAA:
+				fn(x.Hdr.Name, x.AAAA)
+			}
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package file
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestAddresses(t *testing.T) {
+	zone, err := Parse(strings.NewReader(dbMiekNL), testzone, "stdin", 0)
+	if err != nil {
+		t.Fatalf("Expected no error when reading zone, got %q", err)
+	}
+	f := File{Zones: Zones{Z: map[string]*Zone{testzone: zone}, Names: []string{testzone}}}
+
+	got := map[string]bool{}
+	f.Addresses(func(name string, ip net.IP) { got[name+" "+ip.String()] = true })
+
+	for _, a := range []string{"miek.nl. 139.162.196.78", "a.miek.nl. 139.162.196.78", "a.miek.nl. 2a01:7e00::f03c:91ff:fef1:6735"} {
+		if !got[a] {
+			t.Errorf("Expected address %q, got %v", a, got)
+		}
+	}
+}
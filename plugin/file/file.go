@@ -69,6 +69,10 @@ func (f File) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (i
 		return dns.RcodeSuccess, nil
 	}
 
+	if r.Opcode == dns.OpcodeUpdate {
+		return z.serveUpdate(state)
+	}
+
 	z.RLock()
 	exp := z.Expired
 	z.RUnlock()
@@ -0,0 +1,230 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/file/tree"
+
+	"github.com/miekg/dns"
+)
+
+// delta is the difference between two versions of a zone, as sent in an IXFR (RFC 1995): the
+// records deleted from the version with SOA from, and the records added to get the version with SOA
+// to. The SOAs themselves are not part of del and add.
+type delta struct {
+	from, to *dns.SOA
+	del, add []dns.RR
+}
+
+// len returns the number of records d adds to an IXFR.
+func (d *delta) len() int { return len(d.del) + len(d.add) + 2 }
+
+// maxJournal is the largest number of deltas kept in the journal of a zone.
+const maxJournal = 100
+
+// journal adds d to the journal of z. It drops the oldest deltas when there are more than maxJournal
+// of them, or when they hold more records than size, the number of records in the zone: an AXFR
+// is cheaper then. A nil d, one that doesn't increase the serial, or one that doesn't continue from
+// the last delta empties the journal: the versions before it can't be reached anymore. The caller
+// must hold the write lock of z.
+func (z *Zone) journal(d *delta, size int) {
+	if d == nil || !less(d.from.Serial, d.to.Serial) {
+		z.deltas = nil
+		return
+	}
+	if len(z.deltas) > 0 && z.deltas[len(z.deltas)-1].to.Serial != d.from.Serial {
+		z.deltas = nil
+	}
+	z.deltas = append(z.deltas, d)
+	n := 0
+	for _, d := range z.deltas {
+		n += d.len()
+	}
+	for len(z.deltas) > maxJournal || (len(z.deltas) > 0 && n > size) {
+		n -= z.deltas[0].len()
+		z.deltas[0] = nil
+		z.deltas = z.deltas[1:]
+	}
+}
+
+// size returns the number of records in z, the SOA aside.
+func (z *Zone) size() int {
+	n := len(z.Apex.SIGSOA) + len(z.Apex.NS) + len(z.Apex.SIGNS)
+	z.Tree.Walk(func(_ *tree.Elem, rrs map[uint16][]dns.RR) error {
+		for _, s := range rrs {
+			n += len(s)
+		}
+		return nil
+	})
+	return n
+}
+
+// ixfr returns the records of an IXFR from the version with serial to the current version of z, or
+// nil when the journal doesn't reach back to serial.
+func (z *Zone) ixfr(serial uint32) []dns.RR {
+	z.RLock()
+	defer z.RUnlock()
+	if z.Apex.SOA == nil {
+		return nil
+	}
+	for i, d := range z.deltas {
+		if d.from.Serial != serial {
+			continue
+		}
+		records := []dns.RR{z.Apex.SOA}
+		for _, d := range z.deltas[i:] {
+			records = append(records, d.from)
+			records = append(records, d.del...)
+			records = append(records, d.to)
+			records = append(records, d.add...)
+		}
+		return append(records, z.Apex.SOA)
+	}
+	return nil
+}
+
+// rrKey groups the records by owner name and type, to find them back quickly.
+type rrKey struct {
+	name  string
+	rtype uint16
+}
+
+func keyOf(rr dns.RR) rrKey {
+	return rrKey{strings.ToLower(rr.Header().Name), rr.Header().Rrtype}
+}
+
+// newDelta returns the delta from the records old to the records new, both as returned by All. It
+// returns nil when one of them has no SOA.
+func newDelta(old, new []dns.RR) *delta {
+	if len(old) == 0 || len(new) == 0 {
+		return nil
+	}
+	from, ok1 := old[0].(*dns.SOA)
+	to, ok2 := new[0].(*dns.SOA)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	d := &delta{from: from, to: to}
+
+	rrs := make(map[rrKey][]dns.RR)
+	for _, rr := range old[1:] {
+		k := keyOf(rr)
+		rrs[k] = append(rrs[k], rr)
+	}
+Add:
+	for _, rr := range new[1:] {
+		k := keyOf(rr)
+		for i, o := range rrs[k] {
+			// A record that only changed its TTL is deleted and added again.
+			if o.Header().Ttl == rr.Header().Ttl && dns.IsDuplicate(o, rr) {
+				rrs[k] = append(rrs[k][:i], rrs[k][i+1:]...)
+				continue Add
+			}
+		}
+		d.add = append(d.add, rr)
+	}
+	for _, s := range rrs {
+		d.del = append(d.del, s...)
+	}
+	return d
+}
+
+var errIXFR = errors.New("malformed IXFR")
+
+// parseIXFR returns the deltas of the incremental transfer records, which start and end with the
+// SOA of the new version. It returns nil, nil when records holds a full transfer instead.
+func parseIXFR(records []dns.RR) ([]*delta, error) {
+	if len(records) < 3 {
+		return nil, nil
+	}
+	if _, ok := records[1].(*dns.SOA); !ok {
+		return nil, nil
+	}
+	last, ok := records[len(records)-1].(*dns.SOA)
+	if !ok {
+		return nil, errIXFR
+	}
+
+	var (
+		deltas []*delta
+		d      *delta
+	)
+	for _, rr := range records[1 : len(records)-1] {
+		soa, ok := rr.(*dns.SOA)
+		switch {
+		case ok && (d == nil || d.to != nil):
+			// The SOA of the version the next delta deletes from.
+			if d != nil && d.to.Serial != soa.Serial {
+				return nil, fmt.Errorf("%s: delta to serial %d followed by one from %d", errIXFR, d.to.Serial, soa.Serial)
+			}
+			d = &delta{from: soa}
+			deltas = append(deltas, d)
+		case ok:
+			d.to = soa
+		case d.to == nil:
+			d.del = append(d.del, rr)
+		default:
+			d.add = append(d.add, rr)
+		}
+	}
+	if d.to == nil || d.to.Serial != last.Serial {
+		return nil, fmt.Errorf("%s: deltas don't reach serial %d", errIXFR, last.Serial)
+	}
+	return deltas, nil
+}
+
+// apply returns a copy of z with the deltas applied, the first of which must start from the current
+// version of z.
+func (z *Zone) apply(deltas []*delta) (*Zone, error) {
+	old := z.All()
+	if len(old) == 0 {
+		return nil, fmt.Errorf("no zone to apply IXFR to")
+	}
+	soa, ok := old[0].(*dns.SOA)
+	if !ok {
+		return nil, fmt.Errorf("no SOA to apply IXFR to")
+	}
+	if deltas[0].from.Serial != soa.Serial {
+		return nil, fmt.Errorf("IXFR starts at serial %d, not at %d", deltas[0].from.Serial, soa.Serial)
+	}
+
+	rrs := make(map[rrKey][]dns.RR)
+	for _, rr := range old[1:] {
+		k := keyOf(rr)
+		rrs[k] = append(rrs[k], rr)
+	}
+	for _, d := range deltas {
+	Delete:
+		for _, rr := range d.del {
+			k := keyOf(rr)
+			for i, o := range rrs[k] {
+				if dns.IsDuplicate(o, rr) {
+					rrs[k] = append(rrs[k][:i], rrs[k][i+1:]...)
+					continue Delete
+				}
+			}
+			return nil, fmt.Errorf("IXFR to serial %d deletes the missing record: %s", d.to.Serial, rr)
+		}
+		for _, rr := range d.add {
+			normalize(rr)
+			k := keyOf(rr)
+			rrs[k] = append(rrs[k], rr)
+		}
+	}
+
+	// The records of z are normalized already, and shared with z: insert, instead of Insert, doesn't
+	// write to them.
+	z1 := z.CopyWithoutApex()
+	z1.Apex.SOA = deltas[len(deltas)-1].to
+	normalize(z1.Apex.SOA)
+	for _, s := range rrs {
+		for _, rr := range s {
+			if err := z1.insert(rr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return z1, nil
+}
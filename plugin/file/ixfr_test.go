@@ -0,0 +1,254 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+const ixfrZone1 = `$ORIGIN example.org.
+@	3600 IN	SOA ns.example.org. hostmaster.example.org. 1 7200 3600 1209600 3600
+	3600 IN	NS  ns.example.org.
+ns	3600 IN	A   127.0.0.1
+a	3600 IN	A   127.0.0.2
+a	3600 IN	A   127.0.0.3
+b	3600 IN	TXT "old"
+`
+
+const ixfrZone2 = `$ORIGIN example.org.
+@	3600 IN	SOA ns.example.org. hostmaster.example.org. 2 7200 3600 1209600 3600
+	3600 IN	NS  ns.example.org.
+ns	3600 IN	A   127.0.0.1
+a	3600 IN	A   127.0.0.2
+a	3600 IN	A   127.0.0.4
+b	3600 IN	TXT "new"
+c	300  IN	A   127.0.0.5
+`
+
+const ixfrZone3 = `$ORIGIN example.org.
+@	3600 IN	SOA ns.example.org. hostmaster.example.org. 3 7200 3600 1209600 3600
+	3600 IN	NS  ns.example.org.
+ns	3600 IN	A   127.0.0.1
+a	3600 IN	A   127.0.0.2
+a	3600 IN	A   127.0.0.4
+c	600  IN	A   127.0.0.5
+`
+
+// ixfrCommon is in all versions, so the journal holds fewer records than the zone.
+var ixfrCommon = func() string {
+	var sb strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&sb, "host%d 3600 IN A 127.0.1.%d\n", i, i)
+	}
+	return sb.String()
+}()
+
+func parseIXFRZone(t *testing.T, s string) *Zone {
+	z, err := Parse(strings.NewReader(s+ixfrCommon), "example.org.", "stdin", 0)
+	if err != nil {
+		t.Fatalf("Failed to parse zone: %s", err)
+	}
+	return z
+}
+
+// ixfrPrimary returns a zone that went from ixfrZone1 to ixfrZone3, and journaled the changes.
+func ixfrPrimary(t *testing.T) *Zone {
+	z := parseIXFRZone(t, ixfrZone1)
+	z.TransferTo = []string{"*"}
+	z.swap(parseIXFRZone(t, ixfrZone2), nil)
+	z.swap(parseIXFRZone(t, ixfrZone3), nil)
+	return z
+}
+
+func sortedStrings(rrs []dns.RR) []string {
+	s := make([]string, len(rrs))
+	for i := range rrs {
+		s[i] = rrs[i].String()
+	}
+	sort.Strings(s)
+	return s
+}
+
+func sameRecords(t *testing.T, got, want []dns.RR) {
+	g, w := sortedStrings(got), sortedStrings(want)
+	if strings.Join(g, "\n") != strings.Join(w, "\n") {
+		t.Errorf("Expected records:\n%s\ngot:\n%s", strings.Join(w, "\n"), strings.Join(g, "\n"))
+	}
+}
+
+func TestJournal(t *testing.T) {
+	z := ixfrPrimary(t)
+	if len(z.deltas) != 2 {
+		t.Fatalf("Expected 2 deltas, got %d", len(z.deltas))
+	}
+
+	d := z.deltas[0]
+	if d.from.Serial != 1 || d.to.Serial != 2 {
+		t.Errorf("Expected a delta from serial 1 to 2, got %d to %d", d.from.Serial, d.to.Serial)
+	}
+	sameRecords(t, d.del, []dns.RR{test.A("a.example.org. 3600 IN A 127.0.0.3"), test.TXT(`b.example.org. 3600 IN TXT "old"`)})
+	sameRecords(t, d.add, []dns.RR{test.A("a.example.org. 3600 IN A 127.0.0.4"), test.TXT(`b.example.org. 3600 IN TXT "new"`), test.A("c.example.org. 300 IN A 127.0.0.5")})
+
+	// A TTL change deletes and adds the record.
+	d = z.deltas[1]
+	sameRecords(t, d.del, []dns.RR{test.TXT(`b.example.org. 3600 IN TXT "new"`), test.A("c.example.org. 300 IN A 127.0.0.5")})
+	sameRecords(t, d.add, []dns.RR{test.A("c.example.org. 600 IN A 127.0.0.5")})
+
+	// A version that doesn't continue from the last one empties the journal.
+	z.swap(parseIXFRZone(t, ixfrZone1), nil)
+	if len(z.deltas) != 0 {
+		t.Errorf("Expected an empty journal after going back to serial 1, got %d deltas", len(z.deltas))
+	}
+}
+
+func TestJournalWithoutSecondaries(t *testing.T) {
+	z := parseIXFRZone(t, ixfrZone1)
+	z.swap(parseIXFRZone(t, ixfrZone2), nil)
+	if len(z.deltas) != 0 {
+		t.Errorf("Expected no journal for a zone without secondaries, got %d deltas", len(z.deltas))
+	}
+}
+
+func TestIXFRApply(t *testing.T) {
+	z := ixfrPrimary(t)
+
+	for _, serial := range []uint32{1, 2} {
+		records := z.ixfr(serial)
+		if records == nil {
+			t.Fatalf("Expected an IXFR from serial %d, got none", serial)
+		}
+		deltas, err := parseIXFR(records)
+		if err != nil {
+			t.Fatalf("Failed to parse the IXFR from serial %d: %s", serial, err)
+		}
+
+		secondary := parseIXFRZone(t, ixfrZone1)
+		if serial == 2 {
+			secondary = parseIXFRZone(t, ixfrZone2)
+		}
+		z1, err := secondary.apply(deltas)
+		if err != nil {
+			t.Fatalf("Failed to apply the IXFR from serial %d: %s", serial, err)
+		}
+		sameRecords(t, z1.All(), z.All())
+	}
+
+	if records := z.ixfr(0); records != nil {
+		t.Errorf("Expected no IXFR from serial 0, got %d records", len(records))
+	}
+
+	// The deltas must start from the version of the zone.
+	deltas, _ := parseIXFR(z.ixfr(2))
+	if _, err := parseIXFRZone(t, ixfrZone1).apply(deltas); err == nil {
+		t.Error("Expected an error applying an IXFR from serial 2 to serial 1, got none")
+	}
+}
+
+func TestParseIXFR(t *testing.T) {
+	soa := func(serial string) dns.RR {
+		return test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. " + serial + " 7200 3600 1209600 3600")
+	}
+	a := test.A("a.example.org. 3600 IN A 127.0.0.2")
+
+	// A full transfer.
+	if deltas, err := parseIXFR([]dns.RR{soa("2"), a, soa("2")}); deltas != nil || err != nil {
+		t.Errorf("Expected no deltas for a full transfer, got %v, %v", deltas, err)
+	}
+	// Deltas that don't reach the new serial.
+	if _, err := parseIXFR([]dns.RR{soa("3"), soa("1"), soa("2"), a, soa("3")}); err == nil {
+		t.Error("Expected an error for deltas stopping at serial 2, got none")
+	}
+	// Deltas that don't follow each other.
+	if _, err := parseIXFR([]dns.RR{soa("3"), soa("1"), soa("2"), soa("4"), soa("3"), soa("3")}); err == nil {
+		t.Error("Expected an error for a gap in the deltas, got none")
+	}
+}
+
+func TestXfrIXFR(t *testing.T) {
+	z := ixfrPrimary(t)
+	x := Xfr{z}
+
+	tests := []struct {
+		serial uint32
+		tcp    bool
+		answer int // the number of records in the answers
+		ixfr   bool
+	}{
+		{serial: 3, tcp: true, answer: 1},             // up to date
+		{serial: 1, tcp: false, answer: 1},            // UDP, retry over TCP
+		{serial: 2, tcp: true, answer: 7, ixfr: true}, // SOA, SOA 2, 2 deleted, SOA 3, 1 added, SOA
+		{serial: 0, tcp: true, answer: 27},            // not in the journal: AXFR
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetIxfr("example.org.", tc.serial, "ns.example.org.", "hostmaster.example.org.")
+		w := dnstest.NewMultiRecorder(&test.ResponseWriter{TCP: tc.tcp})
+		if _, err := x.ServeDNS(context.TODO(), w, m); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+
+		var answer []dns.RR
+		for _, m := range w.Msgs {
+			answer = append(answer, m.Answer...)
+		}
+		if len(answer) != tc.answer {
+			t.Errorf("Test %d: expected %d records, got %d", i, tc.answer, len(answer))
+			continue
+		}
+		if _, ok := answer[0].(*dns.SOA); !ok {
+			t.Errorf("Test %d: expected a SOA first, got %s", i, answer[0])
+		}
+		if len(answer) > 1 {
+			_, ok := answer[1].(*dns.SOA)
+			if ok != tc.ixfr {
+				t.Errorf("Test %d: expected an incremental transfer to be %t, got %t", i, tc.ixfr, ok)
+			}
+		}
+	}
+}
+
+func TestTransferInIXFR(t *testing.T) {
+	primary := ixfrPrimary(t)
+	var ixfrs int32
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Question[0].Qtype == dns.TypeIXFR {
+			atomic.AddInt32(&ixfrs, 1)
+		}
+		Xfr{primary}.ServeDNS(context.TODO(), w, r)
+	})
+	defer s.Close()
+
+	z := parseIXFRZone(t, ixfrZone2)
+	z.TransferFrom = []string{s.Addr}
+	z.TransferTo = []string{"*"}
+	if err := z.TransferIn(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if n := atomic.LoadInt32(&ixfrs); n != 1 {
+		t.Errorf("Expected an IXFR, got %d", n)
+	}
+	sameRecords(t, z.All(), primary.All())
+	// The deltas are journaled, for our own secondaries.
+	if len(z.deltas) != 1 || z.deltas[0].from.Serial != 2 {
+		t.Errorf("Expected the delta from serial 2 in the journal, got %d deltas", len(z.deltas))
+	}
+
+	// A zone with a version the primary doesn't have in its journal anymore gets a full transfer.
+	primary.Lock()
+	primary.deltas = primary.deltas[1:]
+	primary.Unlock()
+	z = parseIXFRZone(t, ixfrZone1)
+	z.TransferFrom = []string{s.Addr}
+	if err := z.TransferIn(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	sameRecords(t, z.All(), primary.All())
+}
@@ -3,46 +3,42 @@ package file
 import (
 	"os"
 	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/changes"
+	"github.com/coredns/coredns/plugin/pkg/watch"
 )
 
-// Reload reloads a zone when it is changed on disk. If z.NoReload is true, no reloading will be done.
+// Reload reloads a zone when it is changed on disk. The file is watched for changes and checked every
+// z.ReloadInterval. If z.ReloadInterval is zero, no reloading will be done.
 func (z *Zone) Reload() error {
 	if z.ReloadInterval == 0 {
 		return nil
 	}
 	tick := time.NewTicker(z.ReloadInterval)
 
+	// Watch the file as well, so changes are picked up right away. The ticker stays as a fallback.
+	changed := make(chan struct{}, 1)
+	stop, err := watch.File(z.File(), func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		log.Warningf("Failed to watch zone %q in %q, reloading every %s: %s", z.origin, z.File(), z.ReloadInterval, err)
+		stop = func() {}
+	}
+
 	go func() {
 		for {
 			select {
 			case <-tick.C:
-				zFile := z.File()
-				reader, err := os.Open(zFile)
-				if err != nil {
-					log.Errorf("Failed to open zone %q in %q: %v", z.origin, zFile, err)
-					continue
-				}
-
-				serial := z.SOASerialIfDefined()
-				zone, err := Parse(reader, z.origin, zFile, serial)
-				if err != nil {
-					if _, ok := err.(*serialErr); !ok {
-						log.Errorf("Parsing zone %q: %v", z.origin, err)
-					}
-					continue
-				}
-
-				// copy elements we need
-				z.Lock()
-				z.Apex = zone.Apex
-				z.Tree = zone.Tree
-				z.Unlock()
-
-				log.Infof("Successfully reloaded zone %q in %q with %d SOA serial", z.origin, zFile, z.Apex.SOA.Serial)
-				z.Notify()
-
+				z.reload()
+			case <-changed:
+				z.reload()
 			case <-z.reloadShutdown:
 				tick.Stop()
+				stop()
 				return
 			}
 		}
@@ -50,6 +46,56 @@ func (z *Zone) Reload() error {
 	return nil
 }
 
+// reload parses the zone file and replaces the zone's contents when the SOA serial has increased.
+func (z *Zone) reload() {
+	z.updateMu.Lock()
+	defer z.updateMu.Unlock()
+
+	zFile := z.File()
+	serial := z.SOASerialIfDefined()
+	var mtime time.Time
+	if z.AutoSerial != "" {
+		// A changed zone file is read whatever its serial is.
+		fi, err := os.Stat(zFile)
+		if err != nil {
+			log.Errorf("Failed to stat zone %q in %q: %v", z.origin, zFile, err)
+			return
+		}
+		if fi.ModTime().Equal(z.modTime) {
+			return
+		}
+		mtime = fi.ModTime()
+	}
+
+	reader, err := os.Open(zFile)
+	if err != nil {
+		log.Errorf("Failed to open zone %q in %q: %v", z.origin, zFile, err)
+		return
+	}
+	defer reader.Close()
+
+	parseSerial := serial
+	if z.AutoSerial != "" {
+		parseSerial = -1
+	}
+	zone, err := Parse(reader, z.origin, zFile, parseSerial)
+	if err != nil {
+		if _, ok := err.(*serialErr); !ok {
+			log.Errorf("Parsing zone %q: %v", z.origin, err)
+		}
+		return
+	}
+	if z.AutoSerial != "" && !z.bumpSerial(zone, mtime, serial) {
+		return
+	}
+
+	z.swap(zone, nil)
+
+	log.Infof("Successfully reloaded zone %q in %q with %d SOA serial", z.origin, zFile, z.Apex.SOA.Serial)
+	z.Notify()
+	changes.Notify(z.origin)
+}
+
 // SOASerialIfDefined returns the SOA's serial if the zone has a SOA record in the Apex, or -1 otherwise.
 func (z *Zone) SOASerialIfDefined() int64 {
 	z.RLock()
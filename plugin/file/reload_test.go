@@ -62,6 +62,36 @@ func TestZoneReload(t *testing.T) {
 	}
 }
 
+func TestZoneReloadWatch(t *testing.T) {
+	fileName, rm, err := test.TempFile(".", reloadZoneTest)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+	reader, err := os.Open(fileName)
+	if err != nil {
+		t.Fatalf("Failed to open zone: %s", err)
+	}
+	z, err := Parse(reader, "miek.nl", fileName, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse zone: %s", err)
+	}
+
+	// The interval is long enough to only see the change by watching the file.
+	z.ReloadInterval = time.Hour
+	z.Reload()
+	defer z.OnShutdown()
+
+	if err := ioutil.WriteFile(fileName, []byte(reloadZone2Test), 0644); err != nil {
+		t.Fatalf("Failed to write new zone data: %s", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	if len(z.All()) != 3 {
+		t.Fatalf("Expected 3 RRs, got %d", len(z.All()))
+	}
+}
+
 func TestZoneReloadSOAChange(t *testing.T) {
 	_, err := Parse(strings.NewReader(reloadZoneTest), "miek.nl.", "stdin", 1460175181)
 	if err == nil {
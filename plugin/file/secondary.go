@@ -4,60 +4,151 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/coredns/coredns/plugin/pkg/changes"
+	"github.com/coredns/coredns/plugin/pkg/transfer"
+
 	"github.com/miekg/dns"
 )
 
-// TransferIn retrieves the zone from the masters, parses it and sets it live.
+// TransferIn retrieves the zone from the masters, parses it and sets it live. When we have the zone
+// already, only the changes are retrieved with an IXFR, falling back to an AXFR when that fails.
 func (z *Zone) TransferIn() error {
 	if len(z.TransferFrom) == 0 {
 		return nil
 	}
-	m := new(dns.Msg)
-	m.SetAxfr(z.origin)
+	z.RLock()
+	soa := z.Apex.SOA
+	z.RUnlock()
 
-	z1 := z.CopyWithoutApex()
 	var (
 		Err error
 		tr  string
 	)
-
-Transfer:
 	for _, tr = range z.TransferFrom {
-		t := new(dns.Transfer)
-		c, err := t.In(m, tr)
-		if err != nil {
-			log.Errorf("Failed to setup transfer `%s' with `%q': %v", z.origin, tr, err)
-			Err = err
-			continue Transfer
-		}
-		for env := range c {
-			if env.Error != nil {
-				log.Errorf("Failed to transfer `%s' from %q: %v", z.origin, tr, env.Error)
-				Err = env.Error
-				continue Transfer
-			}
-			for _, rr := range env.RR {
-				if err := z1.Insert(rr); err != nil {
-					log.Errorf("Failed to parse transfer `%s' from: %q: %v", z.origin, tr, err)
-					Err = err
-					continue Transfer
-				}
+		if soa != nil {
+			if Err = z.ixfrIn(tr, soa); Err == nil {
+				break
 			}
+			log.Warningf("Failed to transfer `%s' incrementally from %q, trying a full transfer: %v", z.origin, tr, Err)
+		}
+		if Err = z.axfrIn(tr); Err == nil {
+			break
 		}
-		Err = nil
-		break
 	}
 	if Err != nil {
 		return Err
 	}
 
+	log.Infof("Transferred: %s from %s", z.origin, tr)
+	z.Notify()
+	changes.Notify(z.origin)
+	return nil
+}
+
+// axfrIn transfers the entire zone from tr.
+func (z *Zone) axfrIn(tr string) error {
+	m := new(dns.Msg)
+	m.SetAxfr(z.origin)
+	transfer.Sign(m, z.TransferKeys[tr])
+	records, err := transferIn(m, tr, z.TsigSecret)
+	if err != nil {
+		log.Errorf("Failed to transfer `%s' from %q: %v", z.origin, tr, err)
+		return err
+	}
+
+	z1 := z.CopyWithoutApex()
+	for _, rr := range records {
+		if err := z1.Insert(rr); err != nil {
+			log.Errorf("Failed to parse transfer `%s' from: %q: %v", z.origin, tr, err)
+			return err
+		}
+	}
+	z.swap(z1, nil)
+	return nil
+}
+
+// ixfrIn transfers the changes to the zone since soa from tr. A primary without the changes sends the
+// entire zone instead.
+func (z *Zone) ixfrIn(tr string, soa *dns.SOA) error {
+	m := new(dns.Msg)
+	m.SetIxfr(z.origin, soa.Serial, soa.Ns, soa.Mbox)
+	transfer.Sign(m, z.TransferKeys[tr])
+	records, err := transferIn(m, tr, z.TsigSecret)
+	if err != nil {
+		return err
+	}
+	if len(records) == 1 {
+		// Only the SOA: we are up to date.
+		z.Lock()
+		z.Expired = false
+		z.Unlock()
+		return nil
+	}
+
+	deltas, err := parseIXFR(records)
+	if err != nil {
+		return err
+	}
+	var z1 *Zone
+	if deltas != nil {
+		if z1, err = z.apply(deltas); err != nil {
+			return err
+		}
+	} else {
+		z1 = z.CopyWithoutApex()
+		for _, rr := range records {
+			if err := z1.Insert(rr); err != nil {
+				return err
+			}
+		}
+	}
+	z.swap(z1, deltas)
+	return nil
+}
+
+// transferIn returns the records of the transfer m from tr, secrets holds the secret of the key m
+// is signed with, if any.
+func transferIn(m *dns.Msg, tr string, secrets map[string]string) ([]dns.RR, error) {
+	t := &dns.Transfer{TsigSecret: secrets}
+	c, err := t.In(m, tr)
+	if err != nil {
+		return nil, err
+	}
+	var records []dns.RR
+	for env := range c {
+		if env.Error != nil {
+			// Drain c, so the goroutine of t exits.
+			for range c {
+			}
+			return nil, env.Error
+		}
+		records = append(records, env.RR...)
+	}
+	return records, nil
+}
+
+// swap replaces the contents of z with those of z1, and journals the changes: deltas when z1 is
+// the result of an IXFR, otherwise they are computed when the zone is transferred to secondaries.
+func (z *Zone) swap(z1 *Zone, deltas []*delta) {
+	if deltas == nil && len(z.TransferTo) > 0 {
+		if d := newDelta(z.All(), z1.All()); d != nil {
+			deltas = []*delta{d}
+		}
+	}
+
+	size := z1.size()
+
 	z.Lock()
 	z.Tree = z1.Tree
 	z.Apex = z1.Apex
 	z.Expired = false
+	if deltas == nil {
+		z.journal(nil, size)
+	}
+	for _, d := range deltas {
+		z.journal(d, size)
+	}
 	z.Unlock()
-	log.Infof("Transferred: %s from %s", z.origin, tr)
-	return nil
 }
 
 // shouldTransfer checks the primaries of zone, retrieves the SOA record, checks the current serial
@@ -65,8 +156,7 @@ Transfer:
 func (z *Zone) shouldTransfer() (bool, error) {
 	c := new(dns.Client)
 	c.Net = "tcp" // do this query over TCP to minimize spoofing
-	m := new(dns.Msg)
-	m.SetQuestion(z.origin, dns.TypeSOA)
+	c.TsigSecret = z.TsigSecret
 
 	var Err error
 	serial := -1
@@ -74,6 +164,9 @@ func (z *Zone) shouldTransfer() (bool, error) {
 Transfer:
 	for _, tr := range z.TransferFrom {
 		Err = nil
+		m := new(dns.Msg)
+		m.SetQuestion(z.origin, dns.TypeSOA)
+		transfer.Sign(m, z.TransferKeys[tr])
 		ret, _, err := c.Exchange(m, tr)
 		if err != nil || ret.Rcode != dns.RcodeSuccess {
 			Err = err
@@ -106,11 +199,15 @@ func less(a, b uint32) bool {
 // Update updates the secondary zone according to its SOA. It will run for the life time of the server
 // and uses the SOA parameters. Every refresh it will check for a new SOA number. If that fails (for all
 // server) it will retry every retry interval. If the zone failed to transfer before the expire, the zone
-// will be marked expired.
+// will be marked expired. It returns when StopUpdate is called.
 func (z *Zone) Update() error {
 	// If we don't have a SOA, we don't have a zone, wait for it to appear.
 	for z.Apex.SOA == nil {
-		time.Sleep(1 * time.Second)
+		select {
+		case <-z.updateShutdown:
+			return nil
+		case <-time.After(1 * time.Second):
+		}
 	}
 	retryActive := false
 
@@ -125,6 +222,12 @@ Restart:
 
 	for {
 		select {
+		case <-z.updateShutdown:
+			refreshTicker.Stop()
+			retryTicker.Stop()
+			expireTicker.Stop()
+			return nil
+
 		case <-expireTicker.C:
 			if !retryActive {
 				break
@@ -188,6 +291,11 @@ Restart:
 	}
 }
 
+// StopUpdate stops Update, it can be called more than once.
+func (z *Zone) StopUpdate() {
+	z.stopUpdate.Do(func() { close(z.updateShutdown) })
+}
+
 // jitter returns a random duration between [0,n) * time.Millisecond
 func jitter(n int) time.Duration {
 	r := rand.Intn(n)
@@ -0,0 +1,111 @@
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The modes of AutoSerial.
+const (
+	SerialUnixtime = "unixtime" // the modification time of the zone file
+	// SerialDate is YYYYMMDDnn, but nn is the quarter of the day the zone file was modified in, 0 to
+	// 95, not the usual revision counter of that day.
+	SerialDate = "date"
+)
+
+// autoSerial returns the SOA serial for a zone file with serial that was modified at mtime. That is the
+// serial of mode, derived from mtime, if it is larger. When old isn't -1, it is the serial we serve
+// and the zone file has changed: the serial returned is larger than old.
+func autoSerial(mode string, serial uint32, mtime time.Time, old int64) uint32 {
+	t := mtime.UTC()
+	var s uint32
+	switch mode {
+	case SerialUnixtime:
+		s = uint32(t.Unix())
+	case SerialDate:
+		s = uint32(t.Year()*1000000 + int(t.Month())*10000 + t.Day()*100 + (t.Hour()*60+t.Minute())/15)
+	}
+	if less(serial, s) {
+		serial = s
+	}
+	if old >= 0 && !less(uint32(old), serial) {
+		serial = uint32(old) + 1
+	}
+	return serial
+}
+
+// bumpSerial sets the SOA serial of z1, which is read from the zone file modified at mtime, according to
+// z.AutoSerial; old is the serial of z, or -1. The serial of a signed zone isn't changed, as that
+// invalidates the signature of the SOA. It returns false if z1 isn't to replace z.
+//
+// The serial served is saved next to the zone file, as the one derived from mtime is lower when the
+// zone file changed more than once in the same second or quarter-hour. When old is -1 the saved serial
+// is served again if the zone file hasn't changed since, and is the old serial otherwise.
+func (z *Zone) bumpSerial(z1 *Zone, mtime time.Time, old int64) bool {
+	z.modTime = mtime
+	if len(z1.Apex.SIGSOA) > 0 {
+		return old < 0 || less(uint32(old), z1.Apex.SOA.Serial)
+	}
+	name := z.File()
+	saved := int64(-1)
+	if old < 0 {
+		if s, t, err := loadSerial(name); err == nil {
+			if t.Equal(mtime) {
+				saved = int64(s)
+			} else {
+				old = int64(s)
+			}
+		}
+	}
+	z1.Apex.SOA.Serial = autoSerial(z.AutoSerial, z1.Apex.SOA.Serial, mtime, old)
+	if saved >= 0 && less(z1.Apex.SOA.Serial, uint32(saved)) {
+		z1.Apex.SOA.Serial = uint32(saved)
+	}
+	if err := saveSerial(name, z1.Apex.SOA.Serial, mtime); err != nil {
+		log.Warningf("Failed to save the serial of zone %q: %s", z.origin, err)
+	}
+	return true
+}
+
+// serialFile returns the name of the file the serial of the zone file name is saved in.
+func serialFile(name string) string {
+	return filepath.Join(filepath.Dir(name), "."+filepath.Base(name)+".serial")
+}
+
+// loadSerial returns the serial saved for the zone file name, and the modification time of the zone
+// file it was served for.
+func loadSerial(name string) (uint32, time.Time, error) {
+	buf, err := ioutil.ReadFile(serialFile(name))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	var (
+		serial uint32
+		nsec   int64
+	)
+	if _, err := fmt.Sscanf(string(buf), "%d %d", &serial, &nsec); err != nil {
+		return 0, time.Time{}, err
+	}
+	return serial, time.Unix(0, nsec), nil
+}
+
+// saveSerial saves serial, served for the zone file name modified at mtime.
+func saveSerial(name string, serial uint32, mtime time.Time) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails after the rename
+
+	if _, err := fmt.Fprintf(f, "%d %d\n", serial, mtime.UnixNano()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), serialFile(name))
+}
@@ -0,0 +1,144 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+)
+
+func TestAutoSerial(t *testing.T) {
+	mtime := time.Date(2019, 7, 1, 13, 50, 0, 0, time.UTC)
+	tests := []struct {
+		mode     string
+		serial   uint32
+		old      int64
+		expected uint32
+	}{
+		{SerialUnixtime, 1, -1, 1561989000},
+		{SerialUnixtime, 1561990000, -1, 1561990000},     // the file has a larger serial
+		{SerialUnixtime, 1, 1561989000, 1561989001},      // changed in the same second
+		{SerialDate, 1, -1, 2019070155},                  // 13:50 is in quarter 55
+		{SerialDate, 2019070160, 2019070160, 2019070161}, // the serial wasn't bumped
+		{SerialDate, 2019070170, 2019070160, 2019070170},
+	}
+	for i, tc := range tests {
+		if s := autoSerial(tc.mode, tc.serial, mtime, tc.old); s != tc.expected {
+			t.Errorf("Test %d: expected serial %d, got %d", i, tc.expected, s)
+		}
+	}
+}
+
+func TestZoneReloadAutoSerial(t *testing.T) {
+	fileName, rm, err := test.TempFile(".", reloadZoneTest)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+	defer os.Remove(serialFile(fileName))
+	mtime := time.Date(2019, 7, 1, 13, 50, 0, 0, time.UTC)
+	if err := os.Chtimes(fileName, mtime, mtime); err != nil {
+		t.Fatalf("Failed to set the modification time: %s", err)
+	}
+
+	reader, err := os.Open(fileName)
+	if err != nil {
+		t.Fatalf("Failed to open zone: %s", err)
+	}
+	z, err := Parse(reader, "miek.nl", fileName, 0)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("Failed to parse zone: %s", err)
+	}
+	z.AutoSerial = SerialUnixtime
+	z.bumpSerial(z, mtime, -1)
+	if z.Apex.SOA.Serial != 1561989000 {
+		t.Fatalf("Expected the serial to be the modification time, got %d", z.Apex.SOA.Serial)
+	}
+
+	// Nothing happens when the zone file doesn't change.
+	z.reload()
+	if z.Apex.SOA.Serial != 1561989000 {
+		t.Fatalf("Expected an unchanged serial, got %d", z.Apex.SOA.Serial)
+	}
+
+	// A record is added, but the serial isn't bumped.
+	zone := reloadZoneTest + "a.miek.nl.	1627	IN	A	127.0.0.1\n"
+	if err := ioutil.WriteFile(fileName, []byte(zone), 0644); err != nil {
+		t.Fatalf("Failed to write zone: %s", err)
+	}
+	if err := os.Chtimes(fileName, mtime, mtime.Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to set the modification time: %s", err)
+	}
+	z.reload()
+	if z.Apex.SOA.Serial != 1561989060 {
+		t.Errorf("Expected the serial to be bumped, got %d", z.Apex.SOA.Serial)
+	}
+	if records := strings.Join(sortedStrings(z.All()), "\n"); !strings.Contains(records, "a.miek.nl.") {
+		t.Errorf("Expected the zone to be reloaded, got:\n%s", records)
+	}
+}
+
+func TestAutoSerialRestart(t *testing.T) {
+	fileName, rm, err := test.TempFile(".", reloadZoneTest)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+	defer os.Remove(serialFile(fileName))
+
+	// start parses the zone file, as a (re)started server does.
+	start := func() *Zone {
+		reader, err := os.Open(fileName)
+		if err != nil {
+			t.Fatalf("Failed to open zone: %s", err)
+		}
+		defer reader.Close()
+		z, err := Parse(reader, "miek.nl", fileName, 0)
+		if err != nil {
+			t.Fatalf("Failed to parse zone: %s", err)
+		}
+		z.AutoSerial = SerialUnixtime
+		fi, _ := os.Stat(fileName)
+		z.bumpSerial(z, fi.ModTime(), -1)
+		return z
+	}
+	// edit adds a record to the zone file, modified at mtime.
+	edit := func(name string, mtime time.Time) {
+		zone := reloadZoneTest + name + ".miek.nl.	1627	IN	A	127.0.0.1\n"
+		if err := ioutil.WriteFile(fileName, []byte(zone), 0644); err != nil {
+			t.Fatalf("Failed to write zone: %s", err)
+		}
+		if err := os.Chtimes(fileName, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set the modification time: %s", err)
+		}
+	}
+
+	mtime := time.Date(2019, 7, 1, 13, 50, 0, 0, time.UTC)
+	edit("a", mtime)
+	z := start()
+	if z.Apex.SOA.Serial != 1561989000 {
+		t.Fatalf("Expected the serial to be the modification time, got %d", z.Apex.SOA.Serial)
+	}
+
+	// Changed in the same second: the serial is one more than the one derived from the modification time.
+	edit("b", mtime.Add(500*time.Millisecond))
+	z.reload()
+	if z.Apex.SOA.Serial != 1561989001 {
+		t.Fatalf("Expected the serial to be bumped, got %d", z.Apex.SOA.Serial)
+	}
+
+	// A restart serves the same serial, not the lower one of the modification time.
+	if s := start().Apex.SOA.Serial; s != 1561989001 {
+		t.Errorf("Expected the serial to survive a restart, got %d", s)
+	}
+
+	// Changed in the same second while the server was down: the serial still increases.
+	edit("c", mtime.Add(700*time.Millisecond))
+	if s := start().Apex.SOA.Serial; s != 1561989002 {
+		t.Errorf("Expected the serial to be bumped after a restart, got %d", s)
+	}
+}
@@ -91,7 +91,7 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 				zone, err := Parse(reader, origins[i], fileName, 0)
 				if err == nil {
 					z[origins[i]] = zone
-				} else {
+				} else if err := dnsserver.Failure(c, "file", err); err != nil {
 					return Zones{}, err
 				}
 			}
@@ -99,15 +99,52 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 		}
 
 		t := []string{}
+		serialMode := ""
+		keys := map[string]string{}
+		secrets := map[string]string{}
 		var e error
 
 		for c.NextBlock() {
 			switch c.Val() {
 			case "transfer":
-				t, _, e = parse.Transfer(c, false)
+				var key *parse.Key
+				t, _, key, e = parse.Transfer(c, false)
 				if e != nil {
 					return Zones{}, e
 				}
+				if key != nil {
+					if err := dnsserver.GetConfig(c).AddTsigKey(key.Name, key.Secret); err != nil {
+						return Zones{}, err
+					}
+					for _, to := range t {
+						keys[to] = key.Name
+					}
+					secrets[key.Name] = key.Secret
+				}
+
+			case "update":
+				u, key, e := parse.Update(c)
+				if e != nil {
+					return Zones{}, e
+				}
+				config.Updates = true
+				if key != nil {
+					if err := dnsserver.GetConfig(c).AddTsigKey(key.Name, key.Secret); err != nil {
+						return Zones{}, err
+					}
+				}
+				for _, origin := range origins {
+					z[origin].UpdateFrom = append(z[origin].UpdateFrom, u...)
+					if key == nil {
+						continue
+					}
+					if z[origin].UpdateKeys == nil {
+						z[origin].UpdateKeys = make(map[string]string)
+					}
+					for _, from := range u {
+						z[origin].UpdateKeys[from] = key.Name
+					}
+				}
 
 			case "reload":
 				d, err := time.ParseDuration(c.RemainingArgs()[0])
@@ -116,6 +153,16 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 				}
 				reload = d
 
+			case "serial":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return Zones{}, c.ArgErr()
+				}
+				if args[0] != SerialUnixtime && args[0] != SerialDate {
+					return Zones{}, c.Errf("unknown serial mode '%s'", args[0])
+				}
+				serialMode = args[0]
+
 			case "upstream":
 				// remove soon
 				c.RemainingArgs()
@@ -128,6 +175,26 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 				if t != nil {
 					z[origin].TransferTo = append(z[origin].TransferTo, t...)
 				}
+				for to, name := range keys {
+					if z[origin].TransferKeys == nil {
+						z[origin].TransferKeys = make(map[string]string)
+					}
+					z[origin].TransferKeys[to] = name
+				}
+				if len(secrets) > 0 {
+					z[origin].TsigSecret = secrets
+				}
+			}
+		}
+
+		if serialMode == "" {
+			continue
+		}
+		fi, err := os.Stat(fileName)
+		for _, origin := range origins {
+			z[origin].AutoSerial = serialMode
+			if err == nil && z[origin].Apex.SOA != nil {
+				z[origin].bumpSerial(z[origin], fi.ModTime(), -1)
 			}
 		}
 	}
@@ -140,7 +207,9 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 	if openErr != nil {
 		if reload == 0 {
 			// reload hasn't been set make this a fatal error
-			return Zones{}, plugin.Error("file", openErr)
+			if err := dnsserver.Failure(c, "file", openErr); err != nil {
+				return Zones{}, plugin.Error("file", err)
+			}
 		}
 		log.Warningf("Failed to open %q: trying again in %s", openErr, reload)
 
@@ -1,9 +1,12 @@
 package file
 
 import (
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin/test"
 
 	"github.com/caddyserver/caddy"
@@ -92,6 +95,41 @@ func TestFileParse(t *testing.T) {
 	}
 }
 
+func TestFileParseTransferKey(t *testing.T) {
+	zoneFileName, rm, err := test.TempFile(".", dbMiekNL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+
+	c := caddy.NewTestController("dns", `file `+zoneFileName+` miek.nl. {
+		transfer to 10.0.0.1 key xfr.miek.nl c2VjcmV0
+		transfer to 10.0.0.2
+	}`)
+	zones, err := fileParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %q", err)
+	}
+	z := zones.Z["miek.nl."]
+	if x := z.TransferKeys["10.0.0.1:53"]; x != "xfr.miek.nl." {
+		t.Errorf("Expected key %q for %q, got %q", "xfr.miek.nl.", "10.0.0.1:53", x)
+	}
+	if x, ok := z.TransferKeys["10.0.0.2:53"]; ok {
+		t.Errorf("Expected no key for %q, got %q", "10.0.0.2:53", x)
+	}
+	if x := dnsserver.GetConfig(c).TsigSecret["xfr.miek.nl."]; x != "c2VjcmV0" {
+		t.Errorf("Expected the secret of the key in the config, got %q", x)
+	}
+
+	c = caddy.NewTestController("dns", `file `+zoneFileName+` miek.nl. {
+		transfer to 10.0.0.1 key xfr.miek.nl c2VjcmV0
+		transfer to 10.0.0.2 key xfr.miek.nl b3RoZXI=
+	}`)
+	if _, err := fileParse(c); err == nil {
+		t.Error("Expected an error for a key with two secrets, got none")
+	}
+}
+
 func TestParseReload(t *testing.T) {
 	name, rm, err := test.TempFile(".", dbMiekNL)
 	if err != nil {
@@ -123,3 +161,93 @@ func TestParseReload(t *testing.T) {
 		}
 	}
 }
+
+func TestFileParseSoftFail(t *testing.T) {
+	zoneFileName, rm, err := test.TempFile(".", "miek.nl. IN SOA broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+
+	c := caddy.NewTestController("dns", `file `+zoneFileName+` miek.nl.`)
+	if _, err := fileParse(c); err == nil {
+		t.Fatalf("Expected an error for a broken zone file")
+	}
+
+	dnsserver.SoftFail = true
+	defer func() { dnsserver.SoftFail = false }()
+
+	c = caddy.NewTestController("dns", `file `+zoneFileName+` miek.nl.`)
+	if _, err := fileParse(c); err != nil {
+		t.Fatalf("Expected no error with soft failures, got %s", err)
+	}
+	if !dnsserver.GetConfig(c).Failed() {
+		t.Errorf("Expected the server block to have failed")
+	}
+}
+
+func TestFileParseUpdate(t *testing.T) {
+	zoneFileName, rm, err := test.TempFile(".", dbMiekNL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+
+	c := caddy.NewTestController("dns", `file `+zoneFileName+` miek.nl. {
+		update 10.0.0.1 key update.miek.nl c2VjcmV0
+		update 10.0.0.2
+	}`)
+	zones, err := fileParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %q", err)
+	}
+	z := zones.Z["miek.nl."]
+	if x := strings.Join(z.UpdateFrom, " "); x != "10.0.0.1:53 10.0.0.2:53" {
+		t.Errorf("Expected updates from %q, got %q", "10.0.0.1:53 10.0.0.2:53", x)
+	}
+	if x := z.UpdateKeys["10.0.0.1:53"]; x != "update.miek.nl." {
+		t.Errorf("Expected key %q for %q, got %q", "update.miek.nl.", "10.0.0.1:53", x)
+	}
+	if x, ok := z.UpdateKeys["10.0.0.2:53"]; ok {
+		t.Errorf("Expected no key for %q, got %q", "10.0.0.2:53", x)
+	}
+
+	c = caddy.NewTestController("dns", `file `+zoneFileName+` miek.nl. {
+		update
+	}`)
+	if _, err := fileParse(c); err == nil {
+		t.Error("Expected an error for an update without addresses, got none")
+	}
+}
+
+func TestFileParseSerial(t *testing.T) {
+	zoneFileName, rm, err := test.TempFile(".", dbMiekNL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+	defer os.Remove(serialFile(zoneFileName))
+
+	c := caddy.NewTestController("dns", `file `+zoneFileName+` miek.nl. {
+		serial unixtime
+	}`)
+	zones, err := fileParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %q", err)
+	}
+	z := zones.Z["miek.nl."]
+	if z.AutoSerial != SerialUnixtime {
+		t.Errorf("Expected serial mode %q, got %q", SerialUnixtime, z.AutoSerial)
+	}
+	fi, _ := os.Stat(zoneFileName)
+	if s := z.Apex.SOA.Serial; s != uint32(fi.ModTime().Unix()) {
+		t.Errorf("Expected the modification time as serial, got %d", s)
+	}
+
+	c = caddy.NewTestController("dns", `file `+zoneFileName+` miek.nl. {
+		serial increment
+	}`)
+	if _, err := fileParse(c); err == nil {
+		t.Errorf("Expected an error for an unknown serial mode")
+	}
+}
@@ -0,0 +1,323 @@
+package file
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/changes"
+	"github.com/coredns/coredns/plugin/pkg/transfer"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// serveUpdate answers the dynamic update (RFC 2136) in state. A signed update gets a signed response.
+func (z *Zone) serveUpdate(state request.Request) (int, error) {
+	m := new(dns.Msg)
+	m.SetRcode(state.Req, z.update(state))
+	if t := state.Req.IsTsig(); t != nil && state.W.TsigStatus() == nil {
+		m.SetTsig(t.Hdr.Name, t.Algorithm, t.Fudge, time.Now().Unix())
+	}
+	state.W.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// update applies the dynamic update in state to z, and returns the rcode for the response. The
+// updated zone is written to the zone file before it is set live; the SOA serial is increased,
+// unless the update did that itself.
+func (z *Zone) update(state request.Request) int {
+	r := state.Req
+	if len(z.TransferFrom) > 0 {
+		log.Infof("Refusing update from %s for secondary zone %s", state.IP(), z.origin)
+		return dns.RcodeRefused
+	}
+	if !transfer.Allowed(state, z.UpdateFrom, z.UpdateKeys) {
+		log.Infof("Refusing update from %s for zone %s", state.IP(), z.origin)
+		return dns.RcodeRefused
+	}
+	if len(r.Question) != 1 || r.Question[0].Qtype != dns.TypeSOA {
+		return dns.RcodeFormatError
+	}
+	if state.Name() != z.origin || r.Question[0].Qclass != dns.ClassINET {
+		return dns.RcodeNotAuth
+	}
+
+	z.updateMu.Lock()
+	defer z.updateMu.Unlock()
+
+	old := z.All()
+	if len(old) == 0 {
+		return dns.RcodeServerFailure
+	}
+	soa, ok := old[0].(*dns.SOA)
+	if !ok {
+		return dns.RcodeServerFailure
+	}
+	rrs := make(map[rrKey][]dns.RR)
+	for _, rr := range old[1:] {
+		k := keyOf(rr)
+		rrs[k] = append(rrs[k], rr)
+	}
+
+	if rcode := z.prerequisites(rrs, r.Answer); rcode != dns.RcodeSuccess {
+		return rcode
+	}
+	if rcode := z.prescan(r.Ns); rcode != dns.RcodeSuccess {
+		return rcode
+	}
+	soa1, changed := z.updates(rrs, soa, r.Ns)
+	if !changed {
+		return dns.RcodeSuccess
+	}
+	if soa1 == soa {
+		soa1 = dns.Copy(soa).(*dns.SOA)
+		soa1.Serial++
+	}
+
+	z1 := z.CopyWithoutApex()
+	z1.Apex.SOA = soa1
+	records := []dns.RR{soa1}
+	for _, s := range rrs {
+		for _, rr := range s {
+			if err := z1.insert(rr); err != nil {
+				log.Errorf("Failed to update zone %s: %s", z.origin, err)
+				return dns.RcodeServerFailure
+			}
+			records = append(records, rr)
+		}
+	}
+	if err := z.save(records); err != nil {
+		log.Errorf("Failed to write the update of zone %s to %q: %s", z.origin, z.File(), err)
+		return dns.RcodeServerFailure
+	}
+	z.swap(z1, nil)
+	if fi, err := os.Stat(z.File()); err == nil {
+		z.modTime = fi.ModTime() // we have bumped the serial already
+	}
+
+	log.Infof("Updated zone %s from %s, SOA serial is %d", z.origin, state.IP(), soa1.Serial)
+	z.Notify()
+	changes.Notify(z.origin)
+	return dns.RcodeSuccess
+}
+
+// inUse returns true if name has records in rrs, or is the origin of z, which has the SOA.
+func (z *Zone) inUse(rrs map[rrKey][]dns.RR, name string) bool {
+	if name == z.origin {
+		return true
+	}
+	for k, s := range rrs {
+		if k.name == name && len(s) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// prerequisites checks the prerequisites of an update against the records rrs, see RFC 2136,
+// section 3.2.
+func (z *Zone) prerequisites(rrs map[rrKey][]dns.RR, prereqs []dns.RR) int {
+	values := make(map[rrKey][]dns.RR)
+	for _, rr := range prereqs {
+		h := rr.Header()
+		if h.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		if !dns.IsSubDomain(z.origin, h.Name) {
+			return dns.RcodeNotZone
+		}
+		k := keyOf(rr)
+		switch h.Class {
+		case dns.ClassANY:
+			if h.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if h.Rrtype == dns.TypeANY {
+				if !z.inUse(rrs, k.name) {
+					return dns.RcodeNameError
+				}
+			} else if len(rrs[k]) == 0 && !(k.name == z.origin && h.Rrtype == dns.TypeSOA) {
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if h.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if h.Rrtype == dns.TypeANY {
+				if z.inUse(rrs, k.name) {
+					return dns.RcodeYXDomain
+				}
+			} else if len(rrs[k]) > 0 || (k.name == z.origin && h.Rrtype == dns.TypeSOA) {
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			values[k] = append(values[k], rr)
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	// The RRsets that must exist with exactly these records.
+	for k, s := range values {
+		if len(s) != len(rrs[k]) {
+			return dns.RcodeNXRrset
+		}
+		for _, rr := range s {
+			if indexOfRR(rrs[k], rr) < 0 {
+				return dns.RcodeNXRrset
+			}
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// prescan checks the records of the update section, see RFC 2136, section 3.4.1.
+func (z *Zone) prescan(updates []dns.RR) int {
+	for _, rr := range updates {
+		h := rr.Header()
+		if !dns.IsSubDomain(z.origin, h.Name) {
+			return dns.RcodeNotZone
+		}
+		switch h.Rrtype {
+		case dns.TypeAXFR, dns.TypeIXFR, dns.TypeMAILA, dns.TypeMAILB:
+			return dns.RcodeFormatError
+		}
+		switch h.Class {
+		case dns.ClassINET:
+			// A record without rdata is unpacked as just its header.
+			if _, ok := rr.(*dns.RR_Header); ok || h.Rrtype == dns.TypeANY {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassANY:
+			if h.Ttl != 0 || h.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassNONE:
+			if h.Ttl != 0 || h.Rrtype == dns.TypeANY {
+				return dns.RcodeFormatError
+			}
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// updates applies the records of the update section to rrs and soa, see RFC 2136, section 3.4.2.
+// It returns the new SOA, and whether anything changed. The SOA and the NS records of the apex can't
+// be deleted, and a CNAME can't be added next to other records, or the other way around: those
+// updates are ignored.
+func (z *Zone) updates(rrs map[rrKey][]dns.RR, soa *dns.SOA, updates []dns.RR) (*dns.SOA, bool) {
+	changed := false
+	for _, rr := range updates {
+		h := rr.Header()
+		k := keyOf(rr)
+		apex := k.name == z.origin
+
+		switch h.Class {
+		case dns.ClassINET:
+			normalize(rr)
+			if s, ok := rr.(*dns.SOA); ok {
+				if apex && less(soa.Serial, s.Serial) {
+					soa, changed = s, true
+				}
+				continue
+			}
+			if z.cnameConflict(rrs, k) {
+				continue
+			}
+			if i := indexOfRR(rrs[k], rr); i >= 0 {
+				if rrs[k][i].Header().Ttl != h.Ttl {
+					rrs[k][i], changed = rr, true
+				}
+				continue
+			}
+			rrs[k] = append(rrs[k], rr)
+			changed = true
+
+		case dns.ClassANY:
+			for k1 := range rrs {
+				if k1.name != k.name || (h.Rrtype != dns.TypeANY && k1.rtype != h.Rrtype) {
+					continue
+				}
+				if apex && (k1.rtype == dns.TypeNS || k1.rtype == dns.TypeSOA) {
+					continue
+				}
+				if len(rrs[k1]) > 0 {
+					changed = true
+				}
+				delete(rrs, k1)
+			}
+
+		case dns.ClassNONE:
+			if h.Rrtype == dns.TypeSOA || (apex && h.Rrtype == dns.TypeNS && len(rrs[k]) == 1) {
+				continue
+			}
+			rr = dns.Copy(rr)
+			rr.Header().Class = dns.ClassINET
+			if i := indexOfRR(rrs[k], rr); i >= 0 {
+				rrs[k] = append(rrs[k][:i], rrs[k][i+1:]...)
+				changed = true
+			}
+		}
+	}
+	return soa, changed
+}
+
+// cnameConflict returns true if adding a record with key k puts a CNAME next to other records.
+func (z *Zone) cnameConflict(rrs map[rrKey][]dns.RR, k rrKey) bool {
+	for k1, s := range rrs {
+		if k1.name != k.name || k1.rtype == k.rtype || len(s) == 0 {
+			continue
+		}
+		if k.rtype == dns.TypeCNAME || k1.rtype == dns.TypeCNAME {
+			return true
+		}
+	}
+	return false
+}
+
+// indexOfRR returns the index of the record in s that equals rr, the TTL aside, or -1.
+func indexOfRR(s []dns.RR, rr dns.RR) int {
+	for i := range s {
+		if dns.IsDuplicate(s[i], rr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes records to the file of z. They are written to a temporary file first, which is then
+// renamed to the zone file, so a reload never sees half a zone.
+func (z *Zone) save(records []dns.RR) error {
+	name := z.File()
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails after the rename
+
+	if fi, err := os.Stat(name); err == nil {
+		f.Chmod(fi.Mode())
+	}
+	w := bufio.NewWriter(f)
+	for _, rr := range records {
+		fmt.Fprintln(w, rr.String())
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
@@ -0,0 +1,193 @@
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+const updateZone = `$ORIGIN example.org.
+@	3600 IN	SOA ns.example.org. hostmaster.example.org. 1 7200 3600 1209600 3600
+	3600 IN	NS  ns.example.org.
+ns	3600 IN	A   127.0.0.1
+a	3600 IN	A   127.0.0.2
+a	3600 IN	A   127.0.0.3
+`
+
+// updateFile returns the zone updateZone, read from a file in a temporary directory.
+func updateFile(t *testing.T) (*Zone, func()) {
+	dir, err := ioutil.TempDir("", "coredns-update")
+	if err != nil {
+		t.Fatalf("Failed to create a temporary directory: %s", err)
+	}
+	name := filepath.Join(dir, "db.example.org")
+	if err := ioutil.WriteFile(name, []byte(updateZone), 0644); err != nil {
+		t.Fatalf("Failed to write the zone: %s", err)
+	}
+	z, err := Parse(strings.NewReader(updateZone), "example.org.", name, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse the zone: %s", err)
+	}
+	return z, func() { os.RemoveAll(dir) }
+}
+
+// sendUpdate sends m, over the wire, to the zone z and returns the rcode of the response.
+func sendUpdate(t *testing.T, z *Zone, m *dns.Msg) int {
+	buf, err := m.Pack()
+	if err != nil {
+		t.Fatalf("Failed to pack the update: %s", err)
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(buf); err != nil {
+		t.Fatalf("Failed to unpack the update: %s", err)
+	}
+
+	f := File{Zones: Zones{Z: map[string]*Zone{"example.org.": z}, Names: []string{"example.org."}}}
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := f.ServeDNS(context.TODO(), rec, r); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	return rec.Msg.Rcode
+}
+
+func TestUpdate(t *testing.T) {
+	z, rm := updateFile(t)
+	defer rm()
+	z.UpdateFrom = []string{"*"}
+
+	a := func(s string) dns.RR { return test.A(s) }
+	tests := []struct {
+		update  func(m *dns.Msg)
+		rcode   int
+		serial  uint32
+		present []string
+		absent  []string
+	}{
+		{
+			update: func(m *dns.Msg) { m.Insert([]dns.RR{a("new.example.org. 300 IN A 127.0.0.9")}) },
+			rcode:  dns.RcodeSuccess, serial: 2, present: []string{"new.example.org. 300 IN A 127.0.0.9"},
+		},
+		{
+			update: func(m *dns.Msg) { m.NameNotUsed([]dns.RR{a("new.example.org. 0 IN A 127.0.0.1")}) },
+			rcode:  dns.RcodeYXDomain, serial: 2,
+		},
+		{
+			update: func(m *dns.Msg) { m.NameUsed([]dns.RR{a("absent.example.org. 0 IN A 127.0.0.1")}) },
+			rcode:  dns.RcodeNameError, serial: 2,
+		},
+		{
+			update: func(m *dns.Msg) { m.RRsetNotUsed([]dns.RR{a("new.example.org. 0 IN A 127.0.0.1")}) },
+			rcode:  dns.RcodeYXRrset, serial: 2,
+		},
+		{
+			update: func(m *dns.Msg) { m.Used([]dns.RR{a("a.example.org. 0 IN A 127.0.0.2")}) },
+			rcode:  dns.RcodeNXRrset, serial: 2, // 127.0.0.3 is in the RRset as well
+		},
+		{
+			update: func(m *dns.Msg) { m.Insert([]dns.RR{a("x.example.net. 300 IN A 127.0.0.9")}) },
+			rcode:  dns.RcodeNotZone, serial: 2,
+		},
+		{
+			update: func(m *dns.Msg) {
+				m.Used([]dns.RR{a("a.example.org. 0 IN A 127.0.0.2"), a("a.example.org. 0 IN A 127.0.0.3")})
+				m.RemoveRRset([]dns.RR{a("a.example.org. 0 IN A 127.0.0.1")})
+			},
+			rcode: dns.RcodeSuccess, serial: 3, absent: []string{"a.example.org. 3600 IN A 127.0.0.2", "a.example.org. 3600 IN A 127.0.0.3"},
+		},
+		{
+			// A CNAME can't be added next to the A record.
+			update: func(m *dns.Msg) { m.Insert([]dns.RR{test.CNAME("new.example.org. 300 IN CNAME ns.example.org.")}) },
+			rcode:  dns.RcodeSuccess, serial: 3, present: []string{"new.example.org. 300 IN A 127.0.0.9"},
+		},
+		{
+			// The NS records of the apex stay.
+			update: func(m *dns.Msg) { m.RemoveName([]dns.RR{a("example.org. 0 IN A 127.0.0.1")}) },
+			rcode:  dns.RcodeSuccess, serial: 3, present: []string{"example.org. 3600 IN NS ns.example.org."},
+		},
+		{
+			update: func(m *dns.Msg) { m.Remove([]dns.RR{a("new.example.org. 300 IN A 127.0.0.9")}) },
+			rcode:  dns.RcodeSuccess, serial: 4, absent: []string{"new.example.org. 300 IN A 127.0.0.9"},
+		},
+		{
+			// The update sets the serial itself.
+			update: func(m *dns.Msg) {
+				m.Insert([]dns.RR{test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 10 7200 3600 1209600 3600")})
+			},
+			rcode: dns.RcodeSuccess, serial: 10,
+		},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetUpdate("example.org.")
+		tc.update(m)
+		if rcode := sendUpdate(t, z, m); rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rcode])
+		}
+		if serial := z.Apex.SOA.Serial; serial != tc.serial {
+			t.Errorf("Test %d: expected serial %d, got %d", i, tc.serial, serial)
+		}
+
+		records := strings.Join(sortedStrings(z.All()), "\n")
+		for _, p := range tc.present {
+			if !strings.Contains(records, strings.Replace(p, " ", "\t", -1)) {
+				t.Errorf("Test %d: expected %q in the zone, got:\n%s", i, p, records)
+			}
+		}
+		for _, a := range tc.absent {
+			if strings.Contains(records, strings.Replace(a, " ", "\t", -1)) {
+				t.Errorf("Test %d: expected %q to be deleted from the zone", i, a)
+			}
+		}
+	}
+
+	// The zone file has the updated zone.
+	f, err := os.Open(z.File())
+	if err != nil {
+		t.Fatalf("Failed to open the zone file: %s", err)
+	}
+	defer f.Close()
+	z1, err := Parse(f, "example.org.", z.File(), 0)
+	if err != nil {
+		t.Fatalf("Failed to parse the updated zone file: %s", err)
+	}
+	sameRecords(t, z1.All(), z.All())
+}
+
+func TestUpdateRefused(t *testing.T) {
+	z, rm := updateFile(t)
+	defer rm()
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Insert([]dns.RR{test.A("new.example.org. 300 IN A 127.0.0.9")})
+
+	for i, setup := range []func(){
+		func() {}, // no update
+		func() { z.UpdateFrom = []string{"10.240.0.2:53"} },
+		func() {
+			z.UpdateFrom = []string{"10.240.0.1:53"}
+			z.UpdateKeys = map[string]string{"10.240.0.1:53": "update.example.org."}
+		},
+		func() {
+			z.UpdateFrom, z.UpdateKeys = []string{"*"}, nil
+			z.TransferFrom = []string{"10.240.0.2:53"}
+		},
+	} {
+		setup()
+		if rcode := sendUpdate(t, z, m); rcode != dns.RcodeRefused {
+			t.Errorf("Test %d: expected REFUSED, got %s", i, dns.RcodeToString[rcode])
+		}
+	}
+	if serial := z.Apex.SOA.Serial; serial != 1 {
+		t.Errorf("Expected an unchanged zone, got serial %d", serial)
+	}
+}
@@ -11,7 +11,7 @@ import (
 	"github.com/miekg/dns"
 )
 
-// Xfr serves up an AXFR.
+// Xfr serves up an AXFR or IXFR.
 type Xfr struct {
 	*Zone
 }
@@ -26,23 +26,64 @@ func (x Xfr) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (in
 		return 0, plugin.Error(x.Name(), fmt.Errorf("xfr called with non transfer type: %d", state.QType()))
 	}
 
+	if state.QType() == dns.TypeIXFR {
+		if records, ok := x.ixfrOut(state); ok {
+			if len(records) == 0 {
+				return dns.RcodeServerFailure, nil
+			}
+			log.Infof("Outgoing incremental transfer of %d records of zone %s to %s started with %d SOA serial", len(records), x.origin, state.IP(), x.SOASerialIfDefined())
+			x.send(w, r, records)
+			return dns.RcodeSuccess, nil
+		}
+	}
+
 	records := x.All()
 	if len(records) == 0 {
 		return dns.RcodeServerFailure, nil
 	}
+	records = append(records, records[0]) // add closing SOA to the end
+	log.Infof("Outgoing transfer of %d records of zone %s to %s started with %d SOA serial", len(records), x.origin, state.IP(), x.SOASerialIfDefined())
+	x.send(w, r, records)
+	return dns.RcodeSuccess, nil
+}
+
+// ixfrOut returns the records of the IXFR for the serial in the authority section of the request. It
+// returns false when the journal doesn't cover that serial, and a full transfer must be sent.
+func (x Xfr) ixfrOut(state request.Request) ([]dns.RR, bool) {
+	if len(state.Req.Ns) == 0 {
+		return nil, false
+	}
+	soa, ok := state.Req.Ns[0].(*dns.SOA)
+	if !ok {
+		return nil, false
+	}
+
+	x.RLock()
+	current := x.Apex.SOA
+	x.RUnlock()
+	if current == nil {
+		return nil, true
+	}
+	// Up to date, or the changes don't fit in a UDP response (RFC 1995, section 2): send just our SOA.
+	if !less(soa.Serial, current.Serial) || state.Proto() == "udp" {
+		return []dns.RR{current}, true
+	}
+	records := x.ixfr(soa.Serial)
+	return records, records != nil
+}
 
+// send sends the records to the client, split over multiple messages.
+func (x Xfr) send(w dns.ResponseWriter, r *dns.Msg, records []dns.RR) {
 	ch := make(chan *dns.Envelope)
 	tr := new(dns.Transfer)
 	wg := new(sync.WaitGroup)
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		tr.Out(w, r, ch)
 		wg.Done()
 	}()
 
 	j, l := 0, 0
-	records = append(records, records[0]) // add closing SOA to the end
-	log.Infof("Outgoing transfer of %d records of zone %s to %s started with %d SOA serial", len(records), x.origin, state.IP(), x.SOASerialIfDefined())
 	for i, r := range records {
 		l += dns.Len(r)
 		if l > transferLength {
@@ -56,8 +97,6 @@ func (x Xfr) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (in
 	}
 	close(ch) // Even though we close the channel here, we still have
 	wg.Wait() // to wait before we can return and close the connection.
-
-	return dns.RcodeSuccess, nil
 }
 
 // Name implements the plugin.Handler interface.
@@ -2,13 +2,13 @@ package file
 
 import (
 	"fmt"
-	"net"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/coredns/coredns/plugin/file/tree"
+	"github.com/coredns/coredns/plugin/pkg/transfer"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
 	"github.com/coredns/coredns/request"
 
@@ -30,9 +30,23 @@ type Zone struct {
 	StartupOnce  sync.Once
 	TransferFrom []string
 
+	TransferKeys map[string]string // the TSIG key names of the TransferTo and TransferFrom addresses, if any
+	TsigSecret   map[string]string // the secrets of the keys in TransferKeys, to sign our messages with
+	deltas       []*delta          // the journal of the changes between the latest versions, see journal
+
+	UpdateFrom []string          // the addresses that may send dynamic updates
+	UpdateKeys map[string]string // the TSIG key names the UpdateFrom addresses must sign with, if any
+	updateMu   sync.Mutex        // serializes the dynamic updates and the reloads
+
 	ReloadInterval time.Duration
 	reloadShutdown chan bool
 
+	AutoSerial string    // SerialUnixtime or SerialDate to bump the SOA serial when the zone file changes
+	modTime    time.Time // of the zone file when it was last read, with AutoSerial
+
+	updateShutdown chan struct{} // closed to stop Update
+	stopUpdate     sync.Once
+
 	Upstream *upstream.Upstream // Upstream for looking up external names during the resolution process.
 }
 
@@ -52,6 +66,7 @@ func NewZone(name, file string) *Zone {
 		file:           filepath.Clean(file),
 		Tree:           &tree.Tree{},
 		reloadShutdown: make(chan bool),
+		updateShutdown: make(chan struct{}),
 	}
 }
 
@@ -60,6 +75,10 @@ func (z *Zone) Copy() *Zone {
 	z1 := NewZone(z.origin, z.file)
 	z1.TransferTo = z.TransferTo
 	z1.TransferFrom = z.TransferFrom
+	z1.TransferKeys = z.TransferKeys
+	z1.TsigSecret = z.TsigSecret
+	z1.UpdateFrom = z.UpdateFrom
+	z1.UpdateKeys = z.UpdateKeys
 	z1.Expired = z.Expired
 
 	z1.Apex = z.Apex
@@ -71,6 +90,10 @@ func (z *Zone) CopyWithoutApex() *Zone {
 	z1 := NewZone(z.origin, z.file)
 	z1.TransferTo = z.TransferTo
 	z1.TransferFrom = z.TransferFrom
+	z1.TransferKeys = z.TransferKeys
+	z1.TsigSecret = z.TsigSecret
+	z1.UpdateFrom = z.UpdateFrom
+	z1.UpdateKeys = z.UpdateKeys
 	z1.Expired = z.Expired
 
 	return z1
@@ -78,20 +101,38 @@ func (z *Zone) CopyWithoutApex() *Zone {
 
 // Insert inserts r into z.
 func (z *Zone) Insert(r dns.RR) error {
+	normalize(r)
+	return z.insert(r)
+}
+
+// normalize lower cases the names in r that are looked up.
+func normalize(r dns.RR) {
 	r.Header().Name = strings.ToLower(r.Header().Name)
 
+	switch x := r.(type) {
+	case *dns.NS:
+		x.Ns = strings.ToLower(x.Ns)
+	case *dns.SOA:
+		x.Ns = strings.ToLower(x.Ns)
+		x.Mbox = strings.ToLower(x.Mbox)
+	case *dns.CNAME:
+		x.Target = strings.ToLower(x.Target)
+	case *dns.MX:
+		x.Mx = strings.ToLower(x.Mx)
+	case *dns.SRV:
+		x.Target = strings.ToLower(x.Target)
+	}
+}
+
+// insert inserts the normalized r into z.
+func (z *Zone) insert(r dns.RR) error {
 	switch h := r.Header().Rrtype; h {
 	case dns.TypeNS:
-		r.(*dns.NS).Ns = strings.ToLower(r.(*dns.NS).Ns)
-
 		if r.Header().Name == z.origin {
 			z.Apex.NS = append(z.Apex.NS, r)
 			return nil
 		}
 	case dns.TypeSOA:
-		r.(*dns.SOA).Ns = strings.ToLower(r.(*dns.SOA).Ns)
-		r.(*dns.SOA).Mbox = strings.ToLower(r.(*dns.SOA).Mbox)
-
 		z.Apex.SOA = r.(*dns.SOA)
 		return nil
 	case dns.TypeNSEC3, dns.TypeNSEC3PARAM:
@@ -108,12 +149,6 @@ func (z *Zone) Insert(r dns.RR) error {
 				return nil
 			}
 		}
-	case dns.TypeCNAME:
-		r.(*dns.CNAME).Target = strings.ToLower(r.(*dns.CNAME).Target)
-	case dns.TypeMX:
-		r.(*dns.MX).Mx = strings.ToLower(r.(*dns.MX).Mx)
-	case dns.TypeSRV:
-		r.(*dns.SRV).Target = strings.ToLower(r.(*dns.SRV).Target)
 	}
 
 	z.Tree.Insert(r)
@@ -136,22 +171,7 @@ func (z *Zone) SetFile(path string) {
 
 // TransferAllowed checks if incoming request for transferring the zone is allowed according to the ACLs.
 func (z *Zone) TransferAllowed(state request.Request) bool {
-	for _, t := range z.TransferTo {
-		if t == "*" {
-			return true
-		}
-		// If remote IP matches we accept.
-		remote := state.IP()
-		to, _, err := net.SplitHostPort(t)
-		if err != nil {
-			continue
-		}
-		if to == remote {
-			return true
-		}
-	}
-	// TODO(miek): future matching against IP/CIDR notations
-	return false
+	return transfer.Allowed(state, z.TransferTo, z.TransferKeys)
 }
 
 // All returns all records from the zone, the first record will be the SOA record,
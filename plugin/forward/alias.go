@@ -0,0 +1,122 @@
+package forward
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// The SVCB and HTTPS types (RFC 9460) are not known to the dns package, their records are unpacked
+// as *dns.RFC3597.
+const (
+	typeSVCB  uint16 = 64
+	typeHTTPS uint16 = 65
+)
+
+// maxAliasDepth is the length of the chain of AliasMode records chased.
+const maxAliasDepth = 8
+
+// aliasDepthKey is the context key of the number of AliasMode records chased to get to a query.
+type aliasDepthKey struct{}
+
+// chaseAlias adds to ret, a response to a SVCB or HTTPS query, the records of the targets of its
+// AliasMode records, and the addresses of the targets of the ServiceMode records found there, so a
+// client does not need more queries. The targets are looked up through the whole server, the
+// lookups of a chain of aliases chase the next alias themselves.
+func (f *Forward) chaseAlias(ctx context.Context, state request.Request, ret *dns.Msg) {
+	qtype := state.QType()
+	if (qtype != typeSVCB && qtype != typeHTTPS) || ret.Rcode != dns.RcodeSuccess {
+		return
+	}
+	depth, _ := ctx.Value(aliasDepthKey{}).(int)
+	if depth >= maxAliasDepth {
+		return
+	}
+	ctx = context.WithValue(ctx, aliasDepthKey{}, depth+1)
+
+	seen := make(map[string]bool)
+	for _, rr := range ret.Answer {
+		seen[rr.String()] = true
+	}
+	for _, rr := range ret.Extra {
+		seen[rr.String()] = true
+	}
+	add := func(rrs []dns.RR) {
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			if s := rr.String(); !seen[s] {
+				seen[s] = true
+				ret.Extra = append(ret.Extra, rr)
+			}
+		}
+	}
+
+	for _, rr := range ret.Answer {
+		priority, target, ok := svcb(rr, qtype)
+		if !ok || priority != 0 || target == "." {
+			continue
+		}
+		resp, err := f.upstream.Lookup(ctx, state, target, qtype)
+		if err != nil || resp == nil || resp.Rcode != dns.RcodeSuccess {
+			log.Debugf("Failed to chase alias %s of %s", target, rr.Header().Name)
+			continue
+		}
+		add(resp.Answer)
+		add(resp.Extra)
+
+		found := false
+		for _, rr := range resp.Answer {
+			priority, t, ok := svcb(rr, qtype)
+			if !ok {
+				continue
+			}
+			found = true
+			if priority == 0 {
+				continue
+			}
+			if t == "." {
+				t = rr.Header().Name
+			}
+			f.addAddresses(ctx, state, t, add)
+		}
+		// Without SVCB records the client connects to the addresses of the alias target.
+		if !found {
+			f.addAddresses(ctx, state, target, add)
+		}
+	}
+}
+
+// addAddresses looks up the A and AAAA records of name, and passes the answers to add.
+func (f *Forward) addAddresses(ctx context.Context, state request.Request, name string, add func([]dns.RR)) {
+	for _, t := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		resp, err := f.upstream.Lookup(ctx, state, name, t)
+		if err != nil || resp == nil {
+			continue
+		}
+		add(resp.Answer)
+	}
+}
+
+// svcb returns the priority and the target name of rr when it is a record of type qtype, SVCB or
+// HTTPS. A priority of 0 is AliasMode.
+func svcb(rr dns.RR, qtype uint16) (uint16, string, bool) {
+	u, ok := rr.(*dns.RFC3597)
+	if !ok || u.Hdr.Rrtype != qtype {
+		return 0, "", false
+	}
+	b, err := hex.DecodeString(u.Rdata)
+	if err != nil || len(b) < 3 {
+		return 0, "", false
+	}
+	target, _, err := dns.UnpackDomainName(b, 2)
+	if err != nil {
+		return 0, "", false
+	}
+	return binary.BigEndian.Uint16(b), target, true
+}
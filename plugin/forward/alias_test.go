@@ -0,0 +1,122 @@
+package forward
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+// https returns an HTTPS record, without SvcParams.
+func https(name string, priority uint16, target string) dns.RR {
+	b := make([]byte, 2+len(target)+2)
+	b[0], b[1] = byte(priority>>8), byte(priority)
+	n, _ := dns.PackDomainName(target, b, 2, nil, false)
+	return &dns.RFC3597{
+		Hdr:   dns.RR_Header{Name: name, Rrtype: typeHTTPS, Class: dns.ClassINET, Ttl: 300},
+		Rdata: hex.EncodeToString(b[:n]),
+	}
+}
+
+func TestSVCB(t *testing.T) {
+	priority, target, ok := svcb(https("a.example.org.", 1, "svc.example.net."), typeHTTPS)
+	if !ok || priority != 1 || target != "svc.example.net." {
+		t.Errorf("expected 1 svc.example.net., got %d %s %t", priority, target, ok)
+	}
+	if _, _, ok := svcb(https("a.example.org.", 0, "."), typeSVCB); ok {
+		t.Error("expected an HTTPS record not to be a SVCB record")
+	}
+	if _, _, ok := svcb(test.A("a.example.org. IN A 127.0.0.1"), typeHTTPS); ok {
+		t.Error("expected an A record not to be an HTTPS record")
+	}
+}
+
+func TestChaseAlias(t *testing.T) {
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		q := r.Question[0]
+		switch {
+		case q.Qtype == typeHTTPS && q.Name == "a.example.org.":
+			ret.Answer = append(ret.Answer, https(q.Name, 0, "b.example.org."))
+		case q.Qtype == typeHTTPS && q.Name == "b.example.org.":
+			ret.Answer = append(ret.Answer, https(q.Name, 0, "c.example.org."))
+		case q.Qtype == typeHTTPS && q.Name == "c.example.org.":
+			ret.Answer = append(ret.Answer, https(q.Name, 1, "."))
+		case q.Qtype == typeHTTPS && q.Name == "d.example.org.":
+			ret.Answer = append(ret.Answer, https(q.Name, 0, "e.example.org."))
+		case q.Qtype == typeHTTPS && q.Name == "loop.example.org.":
+			ret.Answer = append(ret.Answer, https(q.Name, 0, "loop.example.org."))
+		case q.Qtype == dns.TypeA && q.Name == "c.example.org.":
+			ret.Answer = append(ret.Answer, test.A("c.example.org. IN A 192.0.2.1"))
+		case q.Qtype == dns.TypeAAAA && q.Name == "c.example.org.":
+			ret.Answer = append(ret.Answer, test.AAAA("c.example.org. IN AAAA 2001:db8::1"))
+		case q.Qtype == dns.TypeA && q.Name == "e.example.org.":
+			ret.Answer = append(ret.Answer, test.A("e.example.org. IN A 192.0.2.2"))
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	c := caddy.NewTestController("dns", "forward . "+s.Addr+" {\nchase_alias\n}\n")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	cfg := &dnsserver.Config{Zone: ".", Transport: "dns", ListenHosts: []string{"127.0.0.1"}, Port: "53"}
+	cfg.AddPlugin(func(next plugin.Handler) plugin.Handler { return f })
+	srv, err := dnsserver.NewServer("127.0.0.1:53", []*dnsserver.Config{cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.TODO(), dnsserver.Key{}, srv)
+
+	tests := []struct {
+		qname string
+		qtype uint16
+		extra []string
+	}{
+		// A chain of two aliases, to a service on the last alias target.
+		{"a.example.org.", typeHTTPS, []string{"b.example.org.\t300\tCLASS1\tTYPE65", "c.example.org.\t300\tCLASS1\tTYPE65", "192.0.2.1", "2001:db8::1"}},
+		// An alias target without HTTPS records: its addresses.
+		{"d.example.org.", typeHTTPS, []string{"192.0.2.2"}},
+		// A service record is not chased.
+		{"c.example.org.", typeHTTPS, nil},
+		{"a.example.org.", dns.TypeA, nil},
+		// An alias to itself is chased until the maximum depth only.
+		{"loop.example.org.", typeHTTPS, nil},
+	}
+	for _, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := f.ServeDNS(ctx, rec, m); err != nil {
+			t.Fatalf("%s: expected no error, got %s", tc.qname, err)
+		}
+		extra := make([]string, len(rec.Msg.Extra))
+		for i, rr := range rec.Msg.Extra {
+			extra[i] = rr.String()
+		}
+		all := strings.Join(extra, "\n")
+		if len(rec.Msg.Extra) != len(tc.extra) {
+			t.Errorf("%s: expected %d additional records, got %d:\n%s", tc.qname, len(tc.extra), len(rec.Msg.Extra), all)
+			continue
+		}
+		for _, e := range tc.extra {
+			if !strings.Contains(all, e) {
+				t.Errorf("%s: expected %q in the additional section:\n%s", tc.qname, e, all)
+			}
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -73,6 +74,13 @@ func (t *Transport) Dial(proto string) (*dns.Conn, bool, error) {
 func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options) (*dns.Msg, error) {
 	start := time.Now()
 
+	if p.doh != nil {
+		return p.connectDoH(ctx, state, start)
+	}
+	if p.doq != nil {
+		return p.connectDoQ(ctx, state, start)
+	}
+
 	proto := ""
 	switch {
 	case opts.forceTCP: // TCP flag has precedence over UDP flag
@@ -94,7 +102,7 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 		conn.UDPSize = 512
 	}
 
-	conn.SetWriteDeadline(time.Now().Add(maxTimeout))
+	conn.SetWriteDeadline(time.Now().Add(plugin.Budget(ctx, maxTimeout)))
 	if err := conn.WriteMsg(state.Req); err != nil {
 		conn.Close() // not giving it back
 		if err == io.EOF && cached {
@@ -104,7 +112,7 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 	}
 
 	var ret *dns.Msg
-	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetReadDeadline(time.Now().Add(plugin.Budget(ctx, readTimeout)))
 	for {
 		ret, err = conn.ReadMsg()
 		if err != nil {
@@ -122,6 +130,38 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 
 	p.transport.Yield(conn)
 
+	p.report(ret, start)
+	return ret, nil
+}
+
+// connectDoH sends the request to the DNS-over-HTTPS upstream and waits for a response. The
+// connections are reused, over HTTP/2 when the upstream supports it.
+func (p *Proxy) connectDoH(ctx context.Context, state request.Request, start time.Time) (*dns.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, plugin.Budget(ctx, defaultTimeout))
+	defer cancel()
+	ret, err := p.doh.Exchange(ctx, state.Req)
+	if err != nil {
+		return nil, err
+	}
+	p.report(ret, start)
+	return ret, nil
+}
+
+// connectDoQ sends the request to the DNS-over-QUIC upstream and waits for a response. The queries
+// share a connection, each is sent on its own stream.
+func (p *Proxy) connectDoQ(ctx context.Context, state request.Request, start time.Time) (*dns.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, plugin.Budget(ctx, defaultTimeout))
+	defer cancel()
+	ret, err := p.doq.Exchange(ctx, state.Req)
+	if err != nil {
+		return nil, err
+	}
+	p.report(ret, start)
+	return ret, nil
+}
+
+// report updates the metrics of p with the response ret, to a request sent at start.
+func (p *Proxy) report(ret *dns.Msg, start time.Time) {
 	rc, ok := dns.RcodeToString[ret.Rcode]
 	if !ok {
 		rc = strconv.Itoa(ret.Rcode)
@@ -130,8 +170,6 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 	RequestCount.WithLabelValues(p.addr).Add(1)
 	RcodeCount.WithLabelValues(rc, p.addr).Add(1)
 	RequestDuration.WithLabelValues(p.addr).Observe(time.Since(start).Seconds())
-
-	return ret, nil
 }
 
 const cumulativeAvgWeight = 4
@@ -2,10 +2,12 @@ package forward
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/coredns/coredns/plugin/dnstap"
 	"github.com/coredns/coredns/plugin/dnstap/msg"
+	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/request"
 
 	tap "github.com/dnstap/golang-dnstap"
@@ -18,10 +20,23 @@ func toDnstap(ctx context.Context, host string, f *Forward, state request.Reques
 		return nil
 	}
 	// Query
-	b := msg.New().Time(start).HostPort(host)
+	b := msg.New().Time(start)
+	// The address of a DNS-over-HTTPS upstream is only known to its HTTP transport.
+	doh := strings.HasPrefix(host, transport.HTTPS+"://")
+	doq := strings.HasPrefix(host, transport.QUIC+"://")
+	switch {
+	case doq:
+		b.HostPort(host[len(transport.QUIC+"://"):])
+	case !doh:
+		b.HostPort(host)
+	}
 	opts := f.opts
 	t := ""
 	switch {
+	case doh:
+		t = "tcp"
+	case doq: // QUIC runs over UDP
+		t = "udp"
 	case opts.forceTCP: // TCP flag has precedence over UDP flag
 		t = "tcp"
 	case opts.preferUDP:
@@ -0,0 +1,137 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+	"golang.org/x/net/http2"
+)
+
+// newDoHServer returns a DNS-over-HTTPS server over HTTP/2, answering with an A record, and a TLS
+// config trusting it. It counts the queries sent over HTTP/2 in h2.
+func newDoHServer(t *testing.T, h2 *int32) (*httptest.Server, *tls.Config) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 {
+			atomic.AddInt32(h2, 1)
+		}
+		req, err := doh.RequestToMsg(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, test.A(req.Question[0].Name+" IN A 192.0.2.1"))
+		buf, _ := m.Pack()
+		w.Header().Set("content-type", doh.MimeType)
+		w.Write(buf)
+	}))
+	if err := http2.ConfigureServer(srv.Config, nil); err != nil {
+		t.Fatal(err)
+	}
+	srv.TLS = srv.Config.TLSConfig
+	srv.StartTLS()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return srv, &tls.Config{RootCAs: pool}
+}
+
+func TestDoH(t *testing.T) {
+	var h2 int32
+	srv, tc := newDoHServer(t, &h2)
+	defer srv.Close()
+
+	p := NewProxy(strings.TrimPrefix(srv.URL, "https://")+doh.Path, transport.HTTPS)
+	p.SetTLSConfig(tc)
+	f := New()
+	f.SetProxy(p)
+	defer f.OnShutdown()
+
+	for i := 0; i < 3; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Expected to receive reply, got %s", err)
+		}
+		if rec.Msg.Id != m.Id {
+			t.Errorf("Expected the ID %d, got %d", m.Id, rec.Msg.Id)
+		}
+		if len(rec.Msg.Answer) != 1 {
+			t.Fatalf("Expected 1 answer, got %v", rec.Msg)
+		}
+	}
+	if n := atomic.LoadInt32(&h2); n != 3 {
+		t.Errorf("Expected 3 queries over HTTP/2, got %d", n)
+	}
+
+	if err := p.health.Check(p); err != nil {
+		t.Errorf("Expected the health check to succeed, got %s", err)
+	}
+	srv.Close()
+	if err := p.health.Check(p); err == nil {
+		t.Error("Expected the health check to fail")
+	}
+	if fails := atomic.LoadUint32(&p.fails); fails != 1 {
+		t.Errorf("Expected 1 fail, got %d", fails)
+	}
+}
+
+func TestSetupDoH(t *testing.T) {
+	tests := []struct {
+		input       string
+		shouldErr   bool
+		expectedTo  []string
+		expectedErr string
+	}{
+		// positive
+		{"forward . https://dns.example.org/dns-query", false, []string{"https://dns.example.org/dns-query"}, ""},
+		{"forward . 127.0.0.1 https://dns.example.org/dns-query {\nbootstrap 10.0.0.53\n}\n", false, []string{"127.0.0.1:53", "https://dns.example.org/dns-query"}, ""},
+		{"forward . https://192.0.2.1", false, []string{"https://192.0.2.1"}, ""},
+		// negative
+		{"forward . https:///dns-query", true, nil, "not a DNS-over-HTTPS URL"},
+		{"forward . https://dns.example.org {\nbootstrap\n}\n", true, nil, "Wrong argument count"},
+		{"forward . https://dns.example.org {\nbootstrap tls://10.0.0.53\n}\n", true, nil, "must be plain DNS"},
+		{"forward . https://dns.example.org {\nbootstrap dns.example.net\n}\n", true, nil, "not an IP address or file"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		f, err := parseForward(c)
+
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+		}
+		if err != nil {
+			if !test.shouldErr {
+				t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			}
+			if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v, input: %s", i, test.expectedErr, err, test.input)
+			}
+			continue
+		}
+		if len(f.proxies) != len(test.expectedTo) {
+			t.Fatalf("Test %d: expected %d proxies, got %d", i, len(test.expectedTo), len(f.proxies))
+		}
+		for j, p := range f.proxies {
+			if p.addr != test.expectedTo[j] {
+				t.Errorf("Test %d: expected %s, got %s", i, test.expectedTo[j], p.addr)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/doq"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// newDoQServer returns a DNS-over-QUIC server answering with an A record. The client must skip the
+// verification of its certificate.
+func newDoQServer(t *testing.T) *quic.Listener {
+	cert, err := tls.LoadX509KeyPair("../tls/test_cert.pem", "../tls/test_key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := quic.ListenAddr("127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}, NextProtos: []string{doq.NextProto}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go func() {
+				for {
+					str, err := conn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+					req, err := doq.ReadMsg(str)
+					if err != nil {
+						conn.CloseWithError(doq.ProtocolError, err.Error())
+						return
+					}
+					m := new(dns.Msg)
+					m.SetReply(req)
+					m.Answer = append(m.Answer, test.A(req.Question[0].Name+" IN A 192.0.2.1"))
+					buf, _ := doq.Pack(m)
+					str.Write(buf)
+					str.Close()
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+func TestDoQ(t *testing.T) {
+	l := newDoQServer(t)
+	defer l.Close()
+
+	p := NewProxy(l.Addr().String(), transport.QUIC)
+	p.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	f := New()
+	f.SetProxy(p)
+	defer f.OnShutdown()
+
+	for i := 0; i < 3; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Expected to receive reply, got %s", err)
+		}
+		if rec.Msg.Id != m.Id {
+			t.Errorf("Expected the ID %d, got %d", m.Id, rec.Msg.Id)
+		}
+		if len(rec.Msg.Answer) != 1 {
+			t.Fatalf("Expected 1 answer, got %v", rec.Msg)
+		}
+	}
+
+	if err := p.health.Check(p); err != nil {
+		t.Errorf("Expected the health check to succeed, got %s", err)
+	}
+}
+
+func TestSetupDoQ(t *testing.T) {
+	tests := []struct {
+		input      string
+		expectedTo []string
+	}{
+		{"forward . quic://192.0.2.1", []string{"quic://192.0.2.1:853"}},
+		{"forward . 127.0.0.1 quic://192.0.2.1:8853 {\ntls_servername dns.example.org\n}\n", []string{"127.0.0.1:53", "quic://192.0.2.1:8853"}},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		f, err := parseForward(c)
+		if err != nil {
+			t.Fatalf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+		}
+		if len(f.proxies) != len(test.expectedTo) {
+			t.Fatalf("Test %d: expected %d proxies, got %d", i, len(test.expectedTo), len(f.proxies))
+		}
+		for j, p := range f.proxies {
+			if p.addr != test.expectedTo[j] {
+				t.Errorf("Test %d: expected %s, got %s", i, test.expectedTo[j], p.addr)
+			}
+		}
+	}
+}
@@ -8,11 +8,14 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/debug"
+	"github.com/coredns/coredns/plugin/pkg/ede"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -30,14 +33,22 @@ type Forward struct {
 
 	from    string
 	ignored []string
+	routes  []*route // checked in order, before the proxies
 
 	tlsConfig     *tls.Config
 	tlsServerName string
-	maxfails      uint32
-	expire        time.Duration
+	bootstrap     []string // resolve the host names of the DNS-over-HTTPS upstreams
+
+	maxfails uint32
+	expire   time.Duration
+
+	serverBlock string // the key of the server block, see dnsserver.Config.Key
+	statsAddr   string // where the state of the forwarders is served, see Stats
 
 	opts options // also here for testing
 
+	upstream *upstream.Upstream // set to chase the AliasMode records of SVCB and HTTPS responses
+
 	Next plugin.Handler
 }
 
@@ -72,8 +83,9 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	var upstreamErr error
 	span = ot.SpanFromContext(ctx)
 	i := 0
-	list := f.List()
-	deadline := time.Now().Add(defaultTimeout)
+	proxies := f.route(state)
+	list := f.p.List(proxies)
+	deadline := time.Now().Add(plugin.Budget(ctx, defaultTimeout))
 	start := time.Now()
 	for time.Now().Before(deadline) {
 		if i >= len(list) {
@@ -86,13 +98,13 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 		i++
 		if proxy.Down(f.maxfails) {
 			fails++
-			if fails < len(f.proxies) {
+			if fails < len(proxies) {
 				continue
 			}
 			// All upstream proxies are dead, assume healtcheck is completely broken and randomly
 			// select an upstream to connect to.
 			r := new(random)
-			proxy = r.List(f.proxies)[0]
+			proxy = r.List(proxies)[0]
 
 			HealthcheckBrokenCount.Add(1)
 		}
@@ -107,6 +119,8 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 			err error
 		)
 		opts := f.opts
+		connStart := time.Now()
+		UpstreamInFlight.WithLabelValues(proxy.addr).Set(float64(atomic.AddInt64(&proxy.inflight, 1)))
 		for {
 			ret, err = proxy.Connect(ctx, state, opts)
 			if err == nil {
@@ -122,6 +136,9 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 			}
 			break
 		}
+		UpstreamInFlight.WithLabelValues(proxy.addr).Set(float64(atomic.AddInt64(&proxy.inflight, -1)))
+		// A failure counts as slow as it was: the time it took, up to the timeout.
+		proxy.observe(time.Since(connStart))
 
 		if child != nil {
 			child.Finish()
@@ -136,7 +153,7 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 				proxy.Healthcheck()
 			}
 
-			if fails < len(f.proxies) {
+			if fails < len(proxies) {
 				continue
 			}
 			break
@@ -152,15 +169,19 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 			return 0, taperr
 		}
 
+		if f.upstream != nil {
+			f.chaseAlias(ctx, state, ret)
+		}
+
 		w.WriteMsg(ret)
 		return 0, taperr
 	}
 
 	if upstreamErr != nil {
-		return dns.RcodeServerFailure, upstreamErr
+		return dns.RcodeServerFailure, ede.Wrap(ede.NetworkError, upstreamErr)
 	}
 
-	return dns.RcodeServerFailure, ErrNoHealthy
+	return dns.RcodeServerFailure, ede.Wrap(ede.NoReachableAuthority, ErrNoHealthy)
 }
 
 func (f *Forward) match(state request.Request) bool {
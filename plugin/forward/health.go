@@ -1,8 +1,8 @@
 package forward
 
 import (
+	"context"
 	"crypto/tls"
-	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin/pkg/transport"
@@ -29,6 +29,10 @@ func NewHealthChecker(trans string) HealthChecker {
 		c.WriteTimeout = 1 * time.Second
 
 		return &dnsHc{c: c}
+	case transport.HTTPS:
+		return dohHc{}
+	case transport.QUIC:
+		return doqHc{}
 	}
 
 	log.Warningf("No healthchecker for transport %q", trans)
@@ -47,12 +51,11 @@ func (h *dnsHc) SetTLSConfig(cfg *tls.Config) {
 func (h *dnsHc) Check(p *Proxy) error {
 	err := h.send(p.addr)
 	if err != nil {
-		HealthcheckFailureCount.WithLabelValues(p.addr).Add(1)
-		atomic.AddUint32(&p.fails, 1)
+		p.failed()
 		return err
 	}
 
-	atomic.StoreUint32(&p.fails, 0)
+	p.healthy()
 	return nil
 }
 
@@ -71,3 +74,45 @@ func (h *dnsHc) send(addr string) error {
 
 	return err
 }
+
+// dohHc is a health checker for a DNS-over-HTTPS endpoint: it sends the same query over HTTP, on the
+// connections of the proxy.
+type dohHc struct{}
+
+// SetTLSConfig does nothing: the TLS config is the proxy's.
+func (dohHc) SetTLSConfig(*tls.Config) {}
+
+// Check is used as the up.Func in the up.Probe.
+func (dohHc) Check(p *Proxy) error {
+	// Longer than for DNS: the first check may set up the TLS connection.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := p.doh.Check(ctx); err != nil {
+		p.failed()
+		return err
+	}
+
+	p.healthy()
+	return nil
+}
+
+// doqHc is a health checker for a DNS-over-QUIC endpoint: it sends the same query on the connection of
+// the proxy.
+type doqHc struct{}
+
+// SetTLSConfig does nothing: the TLS config is the proxy's.
+func (doqHc) SetTLSConfig(*tls.Config) {}
+
+// Check is used as the up.Func in the up.Probe.
+func (doqHc) Check(p *Proxy) error {
+	// Longer than for DNS: the first check may set up the QUIC connection.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := p.doq.Check(ctx); err != nil {
+		p.failed()
+		return err
+	}
+
+	p.healthy()
+	return nil
+}
@@ -39,6 +39,24 @@ var (
 		Name:      "healthcheck_broken_count_total",
 		Help:      "Counter of the number of complete failures of the healtchecks.",
 	})
+	UpstreamFails = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "upstream_fails",
+		Help:      "Gauge of the consecutive failed healthchecks per upstream.",
+	}, []string{"to"})
+	UpstreamInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "upstream_in_flight",
+		Help:      "Gauge of the requests waiting for a response per upstream.",
+	}, []string{"to"})
+	UpstreamRTT = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "upstream_rtt_seconds",
+		Help:      "Gauge of the moving average of the response time per upstream.",
+	}, []string{"to"})
 	SocketGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: plugin.Namespace,
 		Subsystem: "forward",
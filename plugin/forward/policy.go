@@ -2,7 +2,9 @@ package forward
 
 import (
 	"math/rand"
+	"sort"
 	"sync/atomic"
+	"time"
 )
 
 // Policy defines a policy we use for selecting upstreams.
@@ -62,3 +64,76 @@ func (r *sequential) String() string { return "sequential" }
 func (r *sequential) List(p []*Proxy) []*Proxy {
 	return p
 }
+
+// weighted is a policy that selects hosts randomly, in proportion to their weight.
+type weighted struct{}
+
+func (r *weighted) String() string { return "weighted" }
+
+func (r *weighted) List(p []*Proxy) []*Proxy {
+	if len(p) == 1 {
+		return p
+	}
+	// Weighted random sampling without replacement: sorting by an exponential variate divided by
+	// the weight picks each host first in proportion to its weight, and so on for the others.
+	keys := make([]float64, len(p))
+	for i, p1 := range p {
+		keys[i] = rand.ExpFloat64() / float64(p1.weight)
+	}
+	w := make([]*Proxy, len(p))
+	copy(w, p)
+	sort.Sort(byKey{w, keys})
+	return w
+}
+
+type byKey struct {
+	p    []*Proxy
+	keys []float64
+}
+
+func (b byKey) Len() int           { return len(b.p) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.p[i], b.p[j] = b.p[j], b.p[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+
+// latency is a policy that selects the hosts with the lowest average response time first. Every
+// latencyExplore lists are random instead, so the average of the slower hosts is kept up to date.
+type latency struct {
+	n uint32
+}
+
+func (r *latency) String() string { return "latency" }
+
+func (r *latency) List(p []*Proxy) []*Proxy {
+	if len(p) == 1 {
+		return p
+	}
+	if atomic.AddUint32(&r.n, 1)%latencyExplore == 0 {
+		return (&random{}).List(p)
+	}
+	keys := make([]float64, len(p))
+	for i, p1 := range p {
+		keys[i] = float64(p1.rtt())
+	}
+	l := make([]*Proxy, len(p))
+	copy(l, p)
+	sort.Stable(byKey{l, keys})
+	return l
+}
+
+const latencyExplore = 100
+
+// rtt returns the average response time of p, 0 before any response.
+func (p *Proxy) rtt() time.Duration { return time.Duration(atomic.LoadInt64(&p.avgRtt)) }
+
+// observe adds the response time d to the average of p, a moving average weighted as the dial
+// timeouts are, see averageTimeout.
+func (p *Proxy) observe(d time.Duration) {
+	p.rtts.add(d)
+	if !atomic.CompareAndSwapInt64(&p.avgRtt, 0, int64(d)) {
+		averageTimeout(&p.avgRtt, d, cumulativeAvgWeight)
+	}
+	UpstreamRTT.WithLabelValues(p.addr).Set(p.rtt().Seconds())
+}
@@ -0,0 +1,52 @@
+package forward
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+func TestWeighted(t *testing.T) {
+	heavy, light := NewProxy("127.0.0.1:53", transport.DNS), NewProxy("127.0.0.2:53", transport.DNS)
+	heavy.weight = 9
+	p := &weighted{}
+
+	first := 0
+	const n = 10000
+	for i := 0; i < n; i++ {
+		list := p.List([]*Proxy{light, heavy})
+		if len(list) != 2 {
+			t.Fatalf("Expected 2 hosts, got %d", len(list))
+		}
+		if list[0] == heavy {
+			first++
+		}
+	}
+	// The heavy host is first 90% of the time.
+	if first < 8700 || first > 9300 {
+		t.Errorf("Expected the heavy host first about %d times, got %d", 9*n/10, first)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	slow, fast, unknown := NewProxy("127.0.0.1:53", transport.DNS), NewProxy("127.0.0.2:53", transport.DNS), NewProxy("127.0.0.3:53", transport.DNS)
+	slow.observe(100 * time.Millisecond)
+	fast.observe(10 * time.Millisecond)
+	p := &latency{}
+
+	list := p.List([]*Proxy{slow, fast, unknown})
+	if list[0] != unknown || list[1] != fast || list[2] != slow {
+		t.Errorf("Expected the hosts ordered by response time, the unknown one first, got %v %v %v", list[0].addr, list[1].addr, list[2].addr)
+	}
+
+	// The fast host gets slow, the average follows.
+	for i := 0; i < 10; i++ {
+		fast.observe(time.Second)
+	}
+	unknown.observe(50 * time.Millisecond)
+	list = p.List([]*Proxy{slow, fast, unknown})
+	if list[0] != unknown || list[1] != slow || list[2] != fast {
+		t.Errorf("Expected the slow host before the one that got slower, got %v %v %v", list[0].addr, list[1].addr, list[2].addr)
+	}
+}
@@ -6,12 +6,20 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/doq"
+	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/plugin/pkg/up"
 )
 
 // Proxy defines an upstream host.
 type Proxy struct {
-	fails uint32
+	fails    uint32
+	avgRtt   int64 // the average response time, see observe
+	inflight int64 // the queries waiting for a response
+	rtts     rttWindow
+
+	weight int // the weight of the weighted policy
 
 	addr string
 
@@ -19,18 +27,33 @@ type Proxy struct {
 	expire    time.Duration
 	transport *Transport
 
+	doh *doh.Client // queries a DNS-over-HTTPS upstream instead of transport
+	doq *doq.Client // queries a DNS-over-QUIC upstream instead of transport
+
 	// health checking
 	probe  *up.Probe
 	health HealthChecker
 }
 
-// NewProxy returns a new proxy.
+// NewProxy returns a new proxy. For a DNS-over-HTTPS upstream, trans is transport.HTTPS and addr its
+// URL without the scheme, like "dns.example.org/dns-query". For a DNS-over-QUIC upstream, trans is
+// transport.QUIC.
 func NewProxy(addr, trans string) *Proxy {
 	p := &Proxy{
-		addr:      addr,
-		fails:     0,
-		probe:     up.New(),
-		transport: newTransport(addr),
+		addr:   addr,
+		fails:  0,
+		weight: 1,
+		probe:  up.New(),
+	}
+	switch trans {
+	case transport.HTTPS:
+		p.addr = transport.HTTPS + "://" + addr
+		p.doh, _ = doh.NewClient(p.addr, nil)
+	case transport.QUIC:
+		p.addr = transport.QUIC + "://" + addr
+		p.doq = doq.NewClient(addr, nil)
+	default:
+		p.transport = newTransport(addr)
 	}
 	p.health = NewHealthChecker(trans)
 	runtime.SetFinalizer(p, (*Proxy).finalizer)
@@ -39,12 +62,31 @@ func NewProxy(addr, trans string) *Proxy {
 
 // SetTLSConfig sets the TLS config in the lower p.transport and in the healthchecking client.
 func (p *Proxy) SetTLSConfig(cfg *tls.Config) {
+	if p.doh != nil {
+		p.doh, _ = doh.NewClient(p.addr, cfg)
+		return
+	}
+	if p.doq != nil {
+		p.doq = doq.NewClient(p.addr[len(transport.QUIC+"://"):], cfg)
+		return
+	}
 	p.transport.SetTLSConfig(cfg)
 	p.health.SetTLSConfig(cfg)
 }
 
 // SetExpire sets the expire duration in the lower p.transport.
-func (p *Proxy) SetExpire(expire time.Duration) { p.transport.SetExpire(expire) }
+func (p *Proxy) SetExpire(expire time.Duration) {
+	if p.transport != nil {
+		p.transport.SetExpire(expire)
+	}
+}
+
+// SetBootstrap sets the DNS servers resolving the host name of a DNS-over-HTTPS upstream.
+func (p *Proxy) SetBootstrap(addrs []string) {
+	if p.doh != nil {
+		p.doh.SetBootstrap(addrs)
+	}
+}
 
 // Healthcheck kicks of a round of health checks for this proxy.
 func (p *Proxy) Healthcheck() {
@@ -58,6 +100,18 @@ func (p *Proxy) Healthcheck() {
 	})
 }
 
+// failed counts a failed health check of p.
+func (p *Proxy) failed() {
+	HealthcheckFailureCount.WithLabelValues(p.addr).Add(1)
+	UpstreamFails.WithLabelValues(p.addr).Set(float64(atomic.AddUint32(&p.fails, 1)))
+}
+
+// healthy resets the failed health checks of p.
+func (p *Proxy) healthy() {
+	atomic.StoreUint32(&p.fails, 0)
+	UpstreamFails.WithLabelValues(p.addr).Set(0)
+}
+
 // Down returns true if this proxy is down, i.e. has *more* fails than maxfails.
 func (p *Proxy) Down(maxfails uint32) bool {
 	if maxfails == 0 {
@@ -69,13 +123,28 @@ func (p *Proxy) Down(maxfails uint32) bool {
 }
 
 // close stops the health checking goroutine.
-func (p *Proxy) close()     { p.probe.Stop() }
-func (p *Proxy) finalizer() { p.transport.Stop() }
+func (p *Proxy) close() {
+	p.probe.Stop()
+	if p.doh != nil {
+		p.doh.Close()
+	}
+	if p.doq != nil {
+		p.doq.Close()
+	}
+}
+
+func (p *Proxy) finalizer() {
+	if p.transport != nil {
+		p.transport.Stop()
+	}
+}
 
 // start starts the proxy's healthchecking.
 func (p *Proxy) start(duration time.Duration) {
 	p.probe.Start(duration)
-	p.transport.Start()
+	if p.transport != nil {
+		p.transport.Start()
+	}
 }
 
 const (
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/ede"
 	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/plugin/test"
 	"github.com/coredns/coredns/request"
@@ -90,9 +91,17 @@ func TestProxyTLSFail(t *testing.T) {
 	m.SetQuestion("example.org.", dns.TypeA)
 	rec := dnstest.NewRecorder(&test.ResponseWriter{})
 
-	if _, err := f.ServeDNS(context.TODO(), rec, m); err == nil {
+	rcode, err := f.ServeDNS(context.TODO(), rec, m)
+	if err == nil {
 		t.Fatal("Expected *not* to receive reply, but got one")
 	}
+	if rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected rcode SERVFAIL, got %d", rcode)
+	}
+	// The server explains the SERVFAIL with the Extended DNS Error of err.
+	if e, ok := ede.FromError(err); !ok || e.Code != ede.NetworkError {
+		t.Errorf("Expected Extended DNS Error %d, got %v", ede.NetworkError, err)
+	}
 }
 
 func TestProtocolSelection(t *testing.T) {
@@ -0,0 +1,116 @@
+package forward
+
+import (
+	"net"
+	"regexp"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/caddyserver/caddy/caddyfile"
+	"github.com/miekg/dns"
+)
+
+// route forwards the queries matching all its conditions to its own upstreams, instead of the
+// upstreams of the forward stanza.
+type route struct {
+	qtype  uint16         // 0 matches any type
+	name   *regexp.Regexp // nil matches any name
+	client *net.IPNet     // nil matches any client
+
+	proxies    []*Proxy
+	transports []string
+}
+
+func (r *route) match(state request.Request) bool {
+	if r.qtype != 0 && state.QType() != r.qtype {
+		return false
+	}
+	if r.name != nil && !r.name.MatchString(state.Name()) {
+		return false
+	}
+	if r.client != nil && !r.client.Contains(net.ParseIP(state.IP())) {
+		return false
+	}
+	return true
+}
+
+// route returns the upstreams of the first route matching state, the upstreams of f when none does.
+func (f *Forward) route(state request.Request) []*Proxy {
+	for _, r := range f.routes {
+		if r.match(state) {
+			return r.proxies
+		}
+	}
+	return f.proxies
+}
+
+// parseRoute parses the arguments of policy_route: the conditions, each a keyword and its value,
+// followed by to and the upstreams.
+func parseRoute(c *caddyfile.Dispenser) (*route, error) {
+	args := c.RemainingArgs()
+	r := &route{}
+	conditions := 0
+	i := 0
+	for ; i < len(args) && args[i] != "to"; i += 2 {
+		if i+1 >= len(args) || args[i+1] == "to" {
+			return nil, c.ArgErr()
+		}
+		value := args[i+1]
+		switch args[i] {
+		case "type":
+			qtype, ok := dns.StringToType[value]
+			if !ok {
+				return nil, c.Errf("invalid type '%s'", value)
+			}
+			r.qtype = qtype
+		case "name":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, c.Errf("invalid name regular expression '%s': %s", value, err)
+			}
+			r.name = re
+		case "client":
+			n, err := parseNetwork(value)
+			if err != nil {
+				return nil, c.Errf("invalid network '%s'", value)
+			}
+			r.client = n
+		default:
+			return nil, c.Errf("unknown condition '%s'", args[i])
+		}
+		conditions++
+	}
+	if conditions == 0 || i >= len(args) {
+		return nil, c.ArgErr()
+	}
+
+	to := args[i+1:]
+	if len(to) == 0 {
+		return nil, c.ArgErr()
+	}
+	if len(to) > max {
+		return nil, c.Errf("more than %d TOs configured: %d", max, len(to))
+	}
+	toHosts, err := parseTo(to)
+	if err != nil {
+		return nil, err
+	}
+	r.proxies, r.transports = newProxies(toHosts)
+	return r, nil
+}
+
+func parseNetwork(s string) (*net.IPNet, error) {
+	_, n, err := net.ParseCIDR(s)
+	if err == nil {
+		return n, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, err
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
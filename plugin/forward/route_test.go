@@ -0,0 +1,120 @@
+package forward
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestSetupRoute(t *testing.T) {
+	tests := []struct {
+		input       string
+		shouldErr   bool
+		routes      int
+		expectedErr string
+	}{
+		// positive
+		{"forward . 127.0.0.1 {\npolicy_route type AAAA to 127.0.0.2\n}\n", false, 1, ""},
+		{"forward . 127.0.0.1 {\npolicy_route name \\.internal\\.$ client 10.0.0.0/8 to 127.0.0.2 127.0.0.3\n}\n", false, 1, ""},
+		{"forward . 127.0.0.1 {\npolicy_route client 10.0.0.1 to 127.0.0.2\npolicy_route type MX to 127.0.0.3\n}\n", false, 2, ""},
+		// negative
+		{"forward . 127.0.0.1 {\npolicy_route to 127.0.0.2\n}\n", true, 0, "Wrong argument count"},
+		{"forward . 127.0.0.1 {\npolicy_route type AAAA\n}\n", true, 0, "Wrong argument count"},
+		{"forward . 127.0.0.1 {\npolicy_route type AAAA to\n}\n", true, 0, "Wrong argument count"},
+		{"forward . 127.0.0.1 {\npolicy_route type to 127.0.0.2\n}\n", true, 0, "Wrong argument count"},
+		{"forward . 127.0.0.1 {\npolicy_route type AAAAA to 127.0.0.2\n}\n", true, 0, "invalid type"},
+		{"forward . 127.0.0.1 {\npolicy_route name ( to 127.0.0.2\n}\n", true, 0, "invalid name"},
+		{"forward . 127.0.0.1 {\npolicy_route client 10.0.0.0/33 to 127.0.0.2\n}\n", true, 0, "invalid network"},
+		{"forward . 127.0.0.1 {\npolicy_route port 53 to 127.0.0.2\n}\n", true, 0, "unknown condition"},
+		{"forward . 127.0.0.1 {\npolicy_route type A to a27.0.0.1\n}\n", true, 0, "not an IP"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		f, err := parseForward(c)
+
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+		}
+		if err != nil {
+			if !test.shouldErr {
+				t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			}
+			if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v, input: %s", i, test.expectedErr, err, test.input)
+			}
+			continue
+		}
+		if len(f.routes) != test.routes {
+			t.Errorf("Test %d: expected %d routes, got %d", i, test.routes, len(f.routes))
+		}
+	}
+}
+
+func TestRoute(t *testing.T) {
+	// The upstreams answer with their own address: dnstest.Server shares the handler of all servers.
+	upstream := func(ip string) (string, func()) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			ret := new(dns.Msg)
+			ret.SetReply(r)
+			ret.Answer = append(ret.Answer, test.A(r.Question[0].Name+" IN A "+ip))
+			w.WriteMsg(ret)
+		})}
+		started := make(chan struct{})
+		s.NotifyStartedFunc = func() { close(started) }
+		go s.ActivateAndServe()
+		<-started
+		return pc.LocalAddr().String(), func() { s.Shutdown() }
+	}
+	addr1, stop1 := upstream("192.0.2.1")
+	defer stop1()
+	addr2, stop2 := upstream("192.0.2.2")
+	defer stop2()
+	addr3, stop3 := upstream("192.0.2.3")
+	defer stop3()
+
+	// test.ResponseWriter has the remote address 10.240.0.1.
+	c := caddy.NewTestController("dns", "forward . "+addr1+" {\n"+
+		"policy_route type MX to "+addr2+"\n"+
+		"policy_route name ^internal\\. client 10.240.0.0/16 to "+addr3+"\n"+
+		"}")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	tests := []struct {
+		qname    string
+		qtype    uint16
+		expected string
+	}{
+		{"example.org.", dns.TypeA, "192.0.2.1"},
+		{"example.org.", dns.TypeMX, "192.0.2.2"},
+		{"internal.example.org.", dns.TypeA, "192.0.2.3"},
+		{"internal.example.org.", dns.TypeMX, "192.0.2.2"},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Test %d: expected to receive reply, got %s", i, err)
+		}
+		if a := rec.Msg.Answer[0].(*dns.A).A.String(); a != tc.expected {
+			t.Errorf("Test %d: expected the answer of %s, got %s", i, tc.expected, a)
+		}
+	}
+}
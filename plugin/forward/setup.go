@@ -2,7 +2,10 @@ package forward
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
@@ -11,6 +14,7 @@ import (
 	"github.com/coredns/coredns/plugin/pkg/parse"
 	pkgtls "github.com/coredns/coredns/plugin/pkg/tls"
 	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
 
 	"github.com/caddyserver/caddy"
 	"github.com/caddyserver/caddy/caddyfile"
@@ -32,13 +36,16 @@ func setup(c *caddy.Controller) error {
 		return plugin.Error("forward", fmt.Errorf("more than %d TOs configured: %d", max, f.Len()))
 	}
 
+	f.serverBlock = dnsserver.GetConfig(c).Key()
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		f.Next = next
 		return f
 	})
 
 	c.OnStartup(func() error {
-		metrics.MustRegister(c, RequestCount, RcodeCount, RequestDuration, HealthcheckFailureCount, SocketGauge)
+		metrics.MustRegister(c, RequestCount, RcodeCount, RequestDuration, HealthcheckFailureCount, SocketGauge,
+			UpstreamFails, UpstreamInFlight, UpstreamRTT)
 		return f.OnStartup()
 	})
 
@@ -51,20 +58,30 @@ func setup(c *caddy.Controller) error {
 
 // OnStartup starts a goroutines for all proxies.
 func (f *Forward) OnStartup() (err error) {
-	for _, p := range f.proxies {
+	for _, p := range f.allProxies() {
 		p.start(f.hcInterval)
 	}
-	return nil
+	return f.startStats()
 }
 
 // OnShutdown stops all configured proxies.
 func (f *Forward) OnShutdown() error {
-	for _, p := range f.proxies {
+	for _, p := range f.allProxies() {
 		p.close()
 	}
+	f.stopStats()
 	return nil
 }
 
+// allProxies returns the proxies of f and of its routes.
+func (f *Forward) allProxies() []*Proxy {
+	proxies := f.proxies
+	for _, r := range f.routes {
+		proxies = append(proxies[:len(proxies):len(proxies)], r.proxies...)
+	}
+	return proxies
+}
+
 // Close is a synonym for OnShutdown().
 func (f *Forward) Close() { f.OnShutdown() }
 
@@ -101,18 +118,13 @@ func ParseForwardStanza(c *caddyfile.Dispenser) (*Forward, error) {
 		return f, c.ArgErr()
 	}
 
-	toHosts, err := parse.HostPortOrFile(to...)
+	toHosts, err := parseTo(to)
 	if err != nil {
 		return f, err
 	}
 
-	transports := make([]string, len(toHosts))
-	for i, host := range toHosts {
-		trans, h := parse.Transport(host)
-		p := NewProxy(h, trans)
-		f.proxies = append(f.proxies, p)
-		transports[i] = trans
-	}
+	var transports []string
+	f.proxies, transports = newProxies(toHosts)
 
 	for c.NextBlock() {
 		if err := parseBlock(c, f); err != nil {
@@ -123,14 +135,59 @@ func ParseForwardStanza(c *caddyfile.Dispenser) (*Forward, error) {
 	if f.tlsServerName != "" {
 		f.tlsConfig.ServerName = f.tlsServerName
 	}
-	for i := range f.proxies {
+	f.setProxies(f.proxies, transports)
+	for _, r := range f.routes {
+		f.setProxies(r.proxies, r.transports)
+	}
+	return f, nil
+}
+
+// parseTo parses the upstreams in to, like parse.HostPortOrFile, except the URLs of
+// DNS-over-HTTPS upstreams, which are kept as is.
+func parseTo(to []string) ([]string, error) {
+	var hosts []string
+	for _, h := range to {
+		if !strings.HasPrefix(h, transport.HTTPS+"://") {
+			hs, err := parse.HostPortOrFile(h)
+			if err != nil {
+				return nil, err
+			}
+			hosts = append(hosts, hs...)
+			continue
+		}
+		u, err := url.Parse(h)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("not a DNS-over-HTTPS URL: %q", h)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// newProxies returns the proxies of hosts, and their transports.
+func newProxies(hosts []string) ([]*Proxy, []string) {
+	proxies := make([]*Proxy, len(hosts))
+	transports := make([]string, len(hosts))
+	for i, host := range hosts {
+		trans, h := parse.Transport(host)
+		proxies[i] = NewProxy(h, trans)
+		transports[i] = trans
+	}
+	return proxies, transports
+}
+
+// setProxies sets the TLS config and the expire duration of f in proxies.
+func (f *Forward) setProxies(proxies []*Proxy, transports []string) {
+	for i := range proxies {
 		// Only set this for proxies that need it.
-		if transports[i] == transport.TLS {
-			f.proxies[i].SetTLSConfig(f.tlsConfig)
+		if transports[i] == transport.TLS || transports[i] == transport.HTTPS || transports[i] == transport.QUIC {
+			proxies[i].SetTLSConfig(f.tlsConfig)
+		}
+		if len(f.bootstrap) > 0 {
+			proxies[i].SetBootstrap(f.bootstrap)
 		}
-		f.proxies[i].SetExpire(f.expire)
+		proxies[i].SetExpire(f.expire)
 	}
-	return f, nil
 }
 
 func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
@@ -178,6 +235,11 @@ func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
 			return c.ArgErr()
 		}
 		f.opts.preferUDP = true
+	case "chase_alias":
+		if c.NextArg() {
+			return c.ArgErr()
+		}
+		f.upstream = upstream.New()
 	case "tls":
 		args := c.RemainingArgs()
 		if len(args) > 3 {
@@ -206,6 +268,35 @@ func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
 			return fmt.Errorf("expire can't be negative: %s", dur)
 		}
 		f.expire = dur
+	case "stats":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		if _, _, err := net.SplitHostPort(c.Val()); err != nil {
+			return err
+		}
+		f.statsAddr = c.Val()
+	case "bootstrap":
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return c.ArgErr()
+		}
+		for _, a := range args {
+			if trans, _ := parse.Transport(a); trans != transport.DNS {
+				return c.Errf("bootstrap servers must be plain DNS: %s", a)
+			}
+		}
+		hosts, err := parse.HostPortOrFile(args...)
+		if err != nil {
+			return err
+		}
+		f.bootstrap = hosts
+	case "policy_route":
+		r, err := parseRoute(c)
+		if err != nil {
+			return err
+		}
+		f.routes = append(f.routes, r)
 	case "policy":
 		if !c.NextArg() {
 			return c.ArgErr()
@@ -217,6 +308,21 @@ func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
 			f.p = &roundRobin{}
 		case "sequential":
 			f.p = &sequential{}
+		case "weighted":
+			weights := c.RemainingArgs()
+			if len(weights) != len(f.proxies) {
+				return c.Errf("weighted needs a weight for each of the %d upstreams, got %d", len(f.proxies), len(weights))
+			}
+			for i, w := range weights {
+				n, err := strconv.Atoi(w)
+				if err != nil || n <= 0 {
+					return c.Errf("invalid weight '%s'", w)
+				}
+				f.proxies[i].weight = n
+			}
+			f.p = &weighted{}
+		case "latency":
+			f.p = &latency{}
 		default:
 			return c.Errf("unknown policy '%s'", x)
 		}
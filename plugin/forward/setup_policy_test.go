@@ -18,8 +18,13 @@ func TestSetupPolicy(t *testing.T) {
 		{"forward . 127.0.0.1 {\npolicy random\n}\n", false, "random", ""},
 		{"forward . 127.0.0.1 {\npolicy round_robin\n}\n", false, "round_robin", ""},
 		{"forward . 127.0.0.1 {\npolicy sequential\n}\n", false, "sequential", ""},
+		{"forward . 127.0.0.1 127.0.0.2 {\npolicy weighted 5 1\n}\n", false, "weighted", ""},
+		{"forward . 127.0.0.1 {\npolicy latency\n}\n", false, "latency", ""},
 		// negative
 		{"forward . 127.0.0.1 {\npolicy random2\n}\n", true, "random", "unknown policy"},
+		{"forward . 127.0.0.1 127.0.0.2 {\npolicy weighted 5\n}\n", true, "", "needs a weight for each"},
+		{"forward . 127.0.0.1 {\npolicy weighted 0\n}\n", true, "", "invalid weight"},
+		{"forward . 127.0.0.1 {\npolicy weighted heavy\n}\n", true, "", "invalid weight"},
 	}
 
 	for i, test := range tests {
@@ -31,9 +31,14 @@ func TestSetup(t *testing.T) {
 		{"forward . 127.0.0.1:8080", false, ".", nil, 2, options{}, ""},
 		{"forward . [::1]:53", false, ".", nil, 2, options{}, ""},
 		{"forward . [2003::1]:53", false, ".", nil, 2, options{}, ""},
+		{"forward . 127.0.0.1 {\nchase_alias\n}\n", false, ".", nil, 2, options{}, ""},
+		{"forward . 127.0.0.1 {\nstats localhost:9154\n}\n", false, ".", nil, 2, options{}, ""},
 		// negative
 		{"forward . a27.0.0.1", true, "", nil, 0, options{}, "not an IP"},
 		{"forward . 127.0.0.1 {\nblaatl\n}\n", true, "", nil, 0, options{}, "unknown property"},
+		{"forward . 127.0.0.1 {\nchase_alias yes\n}\n", true, "", nil, 0, options{}, "Wrong argument count"},
+		{"forward . 127.0.0.1 {\nstats\n}\n", true, "", nil, 0, options{}, "Wrong argument count"},
+		{"forward . 127.0.0.1 {\nstats localhost\n}\n", true, "", nil, 0, options{}, "missing port"},
 		{`forward . ::1
 		forward com ::2`, true, "", nil, 0, options{}, "plugin"},
 	}
@@ -0,0 +1,159 @@
+package forward
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// statsPath is the URL path of the state of the forwarders, served on the stats address.
+const statsPath = "/debug/forward"
+
+// Stats is the state of a forwarder, as served in JSON on the stats address.
+type Stats struct {
+	ServerBlock string          `json:"server_block"`
+	From        string          `json:"from"`
+	Policy      string          `json:"policy"`
+	MaxFails    uint32          `json:"max_fails"`
+	Upstreams   []UpstreamStats `json:"upstreams"`
+}
+
+// UpstreamStats is the state of an upstream of a forwarder. The response times are in seconds.
+type UpstreamStats struct {
+	To       string  `json:"to"`
+	Healthy  bool    `json:"healthy"`
+	Fails    uint32  `json:"fails"` // the consecutive failed health checks
+	InFlight int64   `json:"in_flight"`
+	RTTAvg   float64 `json:"rtt_avg"` // the moving average the latency policy uses
+	RTTP50   float64 `json:"rtt_p50"`
+	RTTP90   float64 `json:"rtt_p90"`
+	RTTP99   float64 `json:"rtt_p99"`
+}
+
+// Stats returns the state of f.
+func (f *Forward) Stats() Stats {
+	s := Stats{ServerBlock: f.serverBlock, From: f.from, Policy: f.p.String(), MaxFails: f.maxfails}
+	for _, p := range f.allProxies() {
+		p50, p90, p99 := p.rtts.percentiles()
+		s.Upstreams = append(s.Upstreams, UpstreamStats{
+			To:       p.addr,
+			Healthy:  !p.Down(f.maxfails),
+			Fails:    atomic.LoadUint32(&p.fails),
+			InFlight: atomic.LoadInt64(&p.inflight),
+			RTTAvg:   p.rtt().Seconds(),
+			RTTP50:   p50.Seconds(),
+			RTTP90:   p90.Seconds(),
+			RTTP99:   p99.Seconds(),
+		})
+	}
+	return s
+}
+
+// rttWindow keeps the last response times of an upstream, for their percentiles.
+type rttWindow struct {
+	mu   sync.Mutex
+	rtts [rttWindowSize]time.Duration
+	n    int // the response times added, the window is full past rttWindowSize
+}
+
+const rttWindowSize = 256
+
+func (w *rttWindow) add(d time.Duration) {
+	w.mu.Lock()
+	w.rtts[w.n%rttWindowSize] = d
+	w.n++
+	w.mu.Unlock()
+}
+
+// percentiles returns the 50th, 90th and 99th percentiles of the response times in w, 0 when empty.
+func (w *rttWindow) percentiles() (p50, p90, p99 time.Duration) {
+	w.mu.Lock()
+	n := w.n
+	if n > rttWindowSize {
+		n = rttWindowSize
+	}
+	rtts := append([]time.Duration(nil), w.rtts[:n]...)
+	w.mu.Unlock()
+	if n == 0 {
+		return 0, 0, 0
+	}
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	at := func(p int) time.Duration { return rtts[(n-1)*p/100] }
+	return at(50), at(90), at(99)
+}
+
+// The forwarders started, and the stats servers serving their state. A stats server is shared by
+// the forwarders using its address, and stops with the last of them.
+var stats = struct {
+	sync.Mutex
+	forwards []*Forward
+	servers  map[string]*statsServer
+}{servers: make(map[string]*statsServer)}
+
+type statsServer struct {
+	ln   net.Listener
+	refs int
+}
+
+// startStats adds f to the forwarders served, and starts the stats server of f when it has one.
+func (f *Forward) startStats() error {
+	stats.Lock()
+	defer stats.Unlock()
+	if f.statsAddr != "" {
+		s := stats.servers[f.statsAddr]
+		if s == nil {
+			ln, err := net.Listen("tcp", f.statsAddr)
+			if err != nil {
+				return err
+			}
+			mux := http.NewServeMux()
+			mux.HandleFunc(statsPath, serveStats)
+			go http.Serve(ln, mux)
+			s = &statsServer{ln: ln}
+			stats.servers[f.statsAddr] = s
+		}
+		s.refs++
+	}
+	stats.forwards = append(stats.forwards, f)
+	return nil
+}
+
+// stopStats removes f from the forwarders served, and stops its stats server when f is the last one
+// using it.
+func (f *Forward) stopStats() {
+	stats.Lock()
+	defer stats.Unlock()
+	i := 0
+	for i < len(stats.forwards) && stats.forwards[i] != f {
+		i++
+	}
+	if i == len(stats.forwards) {
+		return
+	}
+	stats.forwards = append(stats.forwards[:i], stats.forwards[i+1:]...)
+	if s := stats.servers[f.statsAddr]; s != nil {
+		s.refs--
+		if s.refs == 0 {
+			s.ln.Close()
+			delete(stats.servers, f.statsAddr)
+		}
+	}
+}
+
+// serveStats serves the state of all forwarders in JSON.
+func serveStats(w http.ResponseWriter, r *http.Request) {
+	stats.Lock()
+	forwards := append([]*Forward(nil), stats.forwards...)
+	stats.Unlock()
+
+	all := make([]Stats, 0, len(forwards))
+	for _, f := range forwards {
+		all = append(all, f.Stats())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(all)
+}
@@ -0,0 +1,98 @@
+package forward
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+func TestRTTWindow(t *testing.T) {
+	w := new(rttWindow)
+	if p50, p90, p99 := w.percentiles(); p50 != 0 || p90 != 0 || p99 != 0 {
+		t.Errorf("Expected no percentiles of an empty window, got %v %v %v", p50, p90, p99)
+	}
+
+	// The first 100 response times are pushed out of the window by the next 256.
+	for i := 1; i <= 100; i++ {
+		w.add(time.Hour)
+	}
+	for i := 1; i <= rttWindowSize; i++ {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+	p50, p90, p99 := w.percentiles()
+	if p50 != 128*time.Millisecond || p90 != 230*time.Millisecond || p99 != 253*time.Millisecond {
+		t.Errorf("Expected percentiles 128ms 230ms 253ms, got %v %v %v", p50, p90, p99)
+	}
+}
+
+func TestStatsEndpoint(t *testing.T) {
+	p := NewProxy("10.0.0.1:53", transport.DNS)
+	p.observe(20 * time.Millisecond)
+	p.failed()
+
+	f := New()
+	f.SetProxy(p)
+	f.serverBlock = "dns://.:53"
+	f.statsAddr = "127.0.0.1:0"
+	if err := f.startStats(); err != nil {
+		t.Fatalf("Failed to start the stats server: %s", err)
+	}
+	defer f.stopStats()
+
+	stats.Lock()
+	addr := stats.servers[f.statsAddr].ln.Addr().String()
+	stats.Unlock()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s%s", addr, statsPath))
+	if err != nil {
+		t.Fatalf("Failed to get the stats: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var all []Stats
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+		t.Fatalf("Failed to decode the stats: %s", err)
+	}
+	if len(all) != 1 || len(all[0].Upstreams) != 1 {
+		t.Fatalf("Expected the stats of 1 forwarder with 1 upstream, got %+v", all)
+	}
+	if all[0].ServerBlock != "dns://.:53" || all[0].Policy != "random" {
+		t.Errorf("Expected server block dns://.:53 and policy random, got %+v", all[0])
+	}
+	u := all[0].Upstreams[0]
+	if u.To != "10.0.0.1:53" || !u.Healthy || u.Fails != 1 || u.RTTAvg != 0.02 || u.RTTP99 != 0.02 {
+		t.Errorf("Expected 10.0.0.1:53 healthy with 1 fail and an RTT of 0.02, got %+v", u)
+	}
+}
+
+func TestStopStats(t *testing.T) {
+	f, g := New(), New()
+	f.statsAddr, g.statsAddr = "127.0.0.1:0", "127.0.0.1:0"
+	if err := f.startStats(); err != nil {
+		t.Fatalf("Failed to start the stats server: %s", err)
+	}
+	if err := g.startStats(); err != nil {
+		t.Fatalf("Failed to start the stats server: %s", err)
+	}
+
+	f.stopStats()
+	f.stopStats() // not counted twice
+	stats.Lock()
+	s := stats.servers["127.0.0.1:0"]
+	stats.Unlock()
+	if s == nil || s.refs != 1 {
+		t.Fatalf("Expected the stats server still used by 1 forwarder, got %+v", s)
+	}
+
+	g.stopStats()
+	stats.Lock()
+	s = stats.servers["127.0.0.1:0"]
+	stats.Unlock()
+	if s != nil {
+		t.Errorf("Expected the stats server stopped, got %+v", s)
+	}
+}
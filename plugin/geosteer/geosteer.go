@@ -0,0 +1,115 @@
+// Package geosteer implements a plugin that steers the A and AAAA answers by the location of the client.
+package geosteer
+
+import (
+	"context"
+	"net"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Geosteer selects the addresses in the answers that are configured for the location of the client.
+type Geosteer struct {
+	Next  plugin.Handler
+	Zones []string
+
+	networks []network
+	// steers maps a location to the addresses for clients in it. The locations are tried in the order
+	// of levels, "default" is the last.
+	steers map[location]map[string]struct{}
+}
+
+// location is a level, such as "country", with a value, such as "NL".
+type location struct {
+	level string
+	value string
+}
+
+// network is a client network in a location.
+type network struct {
+	net       *net.IPNet
+	locations []location
+}
+
+// levels are the location levels, most specific first.
+var levels = []string{"asn", "country", "continent"}
+
+// labels are the metadata labels of the levels, as set by the geoip plugin.
+var labels = map[string]string{
+	"asn":       "geoip/asn/number",
+	"country":   "geoip/country/code",
+	"continent": "geoip/continent/code",
+}
+
+var defaultLocation = location{level: "default"}
+
+// ServeDNS implements the plugin.Handler interface.
+func (g Geosteer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if plugin.Zones(g.Zones).Matches(state.Name()) == "" {
+		return plugin.NextOrFailure(g.Name(), g.Next, ctx, w, r)
+	}
+	if qt := state.QType(); qt != dns.TypeA && qt != dns.TypeAAAA && qt != dns.TypeANY {
+		return plugin.NextOrFailure(g.Name(), g.Next, ctx, w, r)
+	}
+
+	sw := &ResponseWriter{ResponseWriter: w, locations: g.locate(ctx, state), steers: g.steers}
+	return plugin.NextOrFailure(g.Name(), g.Next, ctx, sw, r)
+}
+
+// locate returns the locations of the client, in the order they're tried. The geoip metadata is
+// used, and the networks for the levels it doesn't have.
+func (g Geosteer) locate(ctx context.Context, state request.Request) []location {
+	ip := clientIP(state)
+	locs := []location{}
+	for _, level := range levels {
+		if f := metadata.ValueFunc(ctx, labels[level]); f != nil {
+			if v := f(); v != "" {
+				locs = append(locs, location{level: level, value: v})
+				continue
+			}
+		}
+		if loc, ok := g.lookup(ip, level); ok {
+			locs = append(locs, loc)
+		}
+	}
+	return append(locs, defaultLocation)
+}
+
+// lookup returns the location at level of the first network that has ip.
+func (g Geosteer) lookup(ip net.IP, level string) (location, bool) {
+	if ip == nil {
+		return location{}, false
+	}
+	for _, n := range g.networks {
+		if !n.net.Contains(ip) {
+			continue
+		}
+		for _, loc := range n.locations {
+			if loc.level == level {
+				return loc, true
+			}
+		}
+	}
+	return location{}, false
+}
+
+// clientIP returns the address of the client: that of the Client Subnet option, when the query has
+// one, or the source address of the query.
+func clientIP(state request.Request) net.IP {
+	if o := state.Req.IsEdns0(); o != nil {
+		for _, e := range o.Option {
+			if s, ok := e.(*dns.EDNS0_SUBNET); ok && s.SourceNetmask > 0 {
+				return s.Address
+			}
+		}
+	}
+	return net.ParseIP(state.IP())
+}
+
+// Name implements the Handler interface.
+func (g Geosteer) Name() string { return "geosteer" }
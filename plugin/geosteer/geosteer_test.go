@@ -0,0 +1,126 @@
+package geosteer
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+// answer answers with a CNAME and the addresses of all locations.
+var answer = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = []dns.RR{
+		test.CNAME("www.example.org. 300 IN CNAME web.example.org."),
+		test.A("web.example.org. 300 IN A 192.0.2.1"),
+		test.A("web.example.org. 300 IN A 192.0.2.2"),
+		test.A("web.example.org. 300 IN A 192.0.2.3"),
+		test.A("web.example.org. 300 IN A 192.0.2.4"),
+	}
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+})
+
+func newGeosteer(t *testing.T) Geosteer {
+	c := caddy.NewTestController("dns", `geosteer example.org {
+		network 10.240.0.0/16 country NL continent EU
+		network 10.0.0.0/8 continent EU
+		network 198.51.100.0/24 asn 64500
+		steer country NL 192.0.2.1
+		steer continent EU 192.0.2.2
+		steer asn 64500 192.0.2.3
+		steer default 192.0.2.4
+	}`)
+	g, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	g.Next = answer
+	return g
+}
+
+// addresses returns the addresses in the answer of m.
+func addresses(m *dns.Msg) string {
+	a := []string{}
+	for _, rr := range m.Answer {
+		if x, ok := rr.(*dns.A); ok {
+			a = append(a, x.A.String())
+		}
+	}
+	sort.Strings(a)
+	return strings.Join(a, " ")
+}
+
+func TestGeosteer(t *testing.T) {
+	g := newGeosteer(t)
+
+	tests := []struct {
+		subnet   string // client subnet option, the client is 10.240.0.1 otherwise
+		meta     map[string]string
+		expected string
+	}{
+		{expected: "192.0.2.1"},
+		{subnet: "10.1.0.0", expected: "192.0.2.2"},
+		{subnet: "198.51.100.0", expected: "192.0.2.3"},
+		{subnet: "203.0.113.0", expected: "192.0.2.4"},
+		// the metadata has precedence over the networks
+		{meta: map[string]string{"geoip/country/code": "DE", "geoip/continent/code": "EU"}, expected: "192.0.2.2"},
+		{meta: map[string]string{"geoip/asn/number": "64500"}, expected: "192.0.2.3"},
+		{meta: map[string]string{"geoip/country/code": "US", "geoip/continent/code": "NA"}, subnet: "203.0.113.0", expected: "192.0.2.4"},
+	}
+
+	for i, tc := range tests {
+		r := new(dns.Msg)
+		r.SetQuestion("www.example.org.", dns.TypeA)
+		if tc.subnet != "" {
+			r.SetEdns0(4096, false)
+			r.IsEdns0().Option = append(r.IsEdns0().Option, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP(tc.subnet)})
+		}
+		ctx := metadata.ContextWithMetadata(context.TODO())
+		for k, v := range tc.meta {
+			v := v
+			metadata.SetValueFunc(ctx, k, func() string { return v })
+		}
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := g.ServeDNS(ctx, rec, r); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if a := addresses(rec.Msg); a != tc.expected {
+			t.Errorf("Test %d: expected %q, got %q", i, tc.expected, a)
+		}
+		if _, ok := rec.Msg.Answer[0].(*dns.CNAME); !ok {
+			t.Errorf("Test %d: expected the CNAME to be kept", i)
+		}
+	}
+}
+
+func TestGeosteerUnsteered(t *testing.T) {
+	g := newGeosteer(t)
+
+	// Not in the zones, and no steered addresses in the answer: the answers are left alone.
+	for _, name := range []string{"www.example.net.", "www.example.org."} {
+		r := new(dns.Msg)
+		r.SetQuestion(name, dns.TypeA)
+		if name == "www.example.org." {
+			g.steers = map[location]map[string]struct{}{defaultLocation: {"192.0.2.9": {}}}
+		}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := g.ServeDNS(context.TODO(), rec, r); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		if a := addresses(rec.Msg); a != "192.0.2.1 192.0.2.2 192.0.2.3 192.0.2.4" {
+			t.Errorf("Expected all addresses for %s, got %q", name, a)
+		}
+	}
+}
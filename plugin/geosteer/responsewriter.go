@@ -0,0 +1,68 @@
+package geosteer
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// ResponseWriter removes the addresses of other locations from the answer.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	locations []location
+	steers    map[location]map[string]struct{}
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (s *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	if res.Rcode == dns.RcodeSuccess {
+		res.Answer = s.steer(res.Answer)
+	}
+	return s.ResponseWriter.WriteMsg(res)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (s *ResponseWriter) Write(buf []byte) (int, error) {
+	log.Warning("Geosteer called with Write: not steering reply")
+	n, err := s.ResponseWriter.Write(buf)
+	return n, err
+}
+
+// steer returns the records of answer, with only the addresses of the first location of the client
+// that has any. When no location has any, answer is returned as is.
+func (s *ResponseWriter) steer(answer []dns.RR) []dns.RR {
+	for _, loc := range s.locations {
+		addrs, ok := s.steers[loc]
+		if !ok {
+			continue
+		}
+		steered := make([]dns.RR, 0, len(answer))
+		found := false
+		for _, rr := range answer {
+			ip := address(rr)
+			if ip == nil {
+				steered = append(steered, rr)
+				continue
+			}
+			if _, ok := addrs[ip.String()]; ok {
+				steered = append(steered, rr)
+				found = true
+			}
+		}
+		if found {
+			return steered
+		}
+	}
+	return answer
+}
+
+// address returns the address of an A or AAAA record, or nil.
+func address(rr dns.RR) net.IP {
+	switch x := rr.(type) {
+	case *dns.A:
+		return x.A
+	case *dns.AAAA:
+		return x.AAAA
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package geosteer
+
+import (
+	"net"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/caddyserver/caddy"
+)
+
+var log = clog.NewWithPlugin("geosteer")
+
+func init() {
+	caddy.RegisterPlugin("geosteer", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	g, err := parse(c)
+	if err != nil {
+		return plugin.Error("geosteer", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		g.Next = next
+		return g
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (Geosteer, error) {
+	g := Geosteer{steers: make(map[location]map[string]struct{})}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return g, plugin.ErrOnce
+		}
+		i++
+
+		g.Zones = make([]string, len(c.ServerBlockKeys))
+		copy(g.Zones, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			g.Zones = args
+		}
+		for i := range g.Zones {
+			g.Zones[i] = plugin.Host(g.Zones[i]).Normalize()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "network":
+				args := c.RemainingArgs()
+				if len(args) < 3 {
+					return g, c.ArgErr()
+				}
+				_, n, err := net.ParseCIDR(args[0])
+				if err != nil {
+					return g, c.Errf("invalid network '%s'", args[0])
+				}
+				locs, rest := parseLocations(args[1:])
+				if len(rest) > 0 {
+					return g, c.Errf("invalid location '%s'", strings.Join(rest, " "))
+				}
+				g.networks = append(g.networks, network{net: n, locations: locs})
+
+			case "steer":
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return g, c.ArgErr()
+				}
+				loc := defaultLocation
+				addrs := args[1:]
+				if args[0] != "default" {
+					locs, rest := parseLocations(args[:2])
+					if len(rest) > 0 {
+						return g, c.Errf("invalid location '%s'", strings.Join(rest, " "))
+					}
+					loc, addrs = locs[0], args[2:]
+				}
+				if len(addrs) == 0 {
+					return g, c.ArgErr()
+				}
+				if g.steers[loc] == nil {
+					g.steers[loc] = make(map[string]struct{})
+				}
+				for _, a := range addrs {
+					ip := net.ParseIP(a)
+					if ip == nil {
+						return g, c.Errf("invalid address '%s'", a)
+					}
+					g.steers[loc][ip.String()] = struct{}{}
+				}
+
+			default:
+				return g, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	if len(g.steers) == 0 {
+		return g, c.Err("no steer given")
+	}
+	return g, nil
+}
+
+// parseLocations parses the LEVEL VALUE pairs in args. It returns the locations and the rest of args
+// that aren't such a pair.
+func parseLocations(args []string) ([]location, []string) {
+	locs := []location{}
+	for len(args) >= 2 {
+		if _, ok := labels[args[0]]; !ok {
+			break
+		}
+		locs = append(locs, location{level: args[0], value: strings.ToUpper(args[1])})
+		args = args[2:]
+	}
+	return locs, args
+}
@@ -0,0 +1,79 @@
+package geosteer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetupGeosteer(t *testing.T) {
+	tests := []struct {
+		input      string
+		shouldErr  bool
+		errContent string
+		zones      []string
+		networks   int
+		steers     int
+	}{
+		{`geosteer {
+			steer default 192.0.2.1
+		}`, false, "", []string{"example.org."}, 0, 1},
+		{`geosteer example.net {
+			network 10.0.0.0/8 country nl continent EU
+			network 2001:db8::/32 asn 64500
+			steer country NL 192.0.2.1 2001:db8::1
+			steer continent EU 192.0.2.2
+			steer asn 64500 192.0.2.3
+		}`, false, "", []string{"example.net."}, 2, 3},
+		// fails
+		{`geosteer`, true, "no steer", nil, 0, 0},
+		{`geosteer {
+			steer country NL
+		}`, true, "argument count", nil, 0, 0},
+		{`geosteer {
+			steer city Amsterdam 192.0.2.1
+		}`, true, "invalid location", nil, 0, 0},
+		{`geosteer {
+			steer default 192.0.2
+		}`, true, "invalid address", nil, 0, 0},
+		{`geosteer {
+			network 10.0.0.0 country NL
+		}`, true, "invalid network", nil, 0, 0},
+		{`geosteer {
+			network 10.0.0.0/8 country NL continent
+		}`, true, "invalid location", nil, 0, 0},
+		{`geosteer {
+			colour blue
+		}`, true, "unknown property", nil, 0, 0},
+		{`geosteer
+		  geosteer`, true, "", nil, 0, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		c.ServerBlockKeys = []string{"example.org"}
+		g, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			} else if !strings.Contains(err.Error(), tc.errContent) {
+				t.Errorf("Test %d: expected error to contain %q, got %q", i, tc.errContent, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if strings.Join(g.Zones, ",") != strings.Join(tc.zones, ",") {
+			t.Errorf("Test %d: expected zones %v, got %v", i, tc.zones, g.Zones)
+		}
+		if len(g.networks) != tc.networks {
+			t.Errorf("Test %d: expected %d networks, got %d", i, tc.networks, len(g.networks))
+		}
+		if len(g.steers) != tc.steers {
+			t.Errorf("Test %d: expected %d steers, got %d", i, tc.steers, len(g.steers))
+		}
+	}
+}
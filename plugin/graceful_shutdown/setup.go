@@ -0,0 +1,53 @@
+// Package gracefulshutdown implements a plugin that sets how long a stopping server waits for the
+// in-flight queries.
+package gracefulshutdown
+
+import (
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("graceful_shutdown", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	d, err := parse(c)
+	if err != nil {
+		return plugin.Error("graceful_shutdown", err)
+	}
+	dnsserver.GetConfig(c).GraceTimeout = d
+	return nil
+}
+
+func parse(c *caddy.Controller) (time.Duration, error) {
+	var d time.Duration
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return 0, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return 0, c.ArgErr()
+		}
+		var err error
+		d, err = time.ParseDuration(args[0])
+		if err != nil {
+			return 0, c.Errf("invalid duration '%s'", args[0])
+		}
+		if d <= 0 {
+			return 0, c.Errf("duration must be positive: %s", d)
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,45 @@
+package gracefulshutdown
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  time.Duration
+	}{
+		{`graceful_shutdown 10s`, false, 10 * time.Second},
+		{`graceful_shutdown 1m30s`, false, 90 * time.Second},
+		{`graceful_shutdown`, true, 0},
+		{`graceful_shutdown 10s 20s`, true, 0},
+		{`graceful_shutdown 0s`, true, 0},
+		{`graceful_shutdown -5s`, true, 0},
+		{`graceful_shutdown 10`, true, 0},
+		{"graceful_shutdown 10s\ngraceful_shutdown 20s", true, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if d := dnsserver.GetConfig(c).GraceTimeout; d != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, d)
+		}
+	}
+}
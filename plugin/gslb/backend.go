@@ -0,0 +1,127 @@
+package gslb
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// check is how the backends are checked.
+type check struct {
+	protocol string // "http" or "tcp"
+	port     int
+	path     string // http only
+
+	interval time.Duration
+	timeout  time.Duration
+	rise     int // consecutive successful checks that make a backend healthy
+	fall     int // consecutive failed checks that make a backend unhealthy
+}
+
+const (
+	defaultInterval = 5 * time.Second
+	defaultTimeout  = 2 * time.Second
+	defaultRise     = 2
+	defaultFall     = 3
+	defaultTTL      = 30
+)
+
+// backend is an address that is health checked.
+type backend struct {
+	ip     net.IP
+	check  check
+	client *http.Client
+
+	healthy int32 // atomic, 1 when healthy
+	// The consecutive successful or failed checks, only used by the checking goroutine.
+	successes, fails int
+
+	stop chan struct{}
+}
+
+// newBackend returns a backend for ip. It is healthy until its checks say otherwise.
+func newBackend(ip net.IP, c check) *backend {
+	b := &backend{ip: ip, check: c, healthy: 1, stop: make(chan struct{})}
+	if c.protocol == "http" {
+		b.client = &http.Client{
+			Timeout: c.timeout,
+			// A redirect is an answer, it isn't followed.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+	healthyGauge.WithLabelValues(b.String()).Set(1)
+	return b
+}
+
+// Healthy returns true if the backend is healthy.
+func (b *backend) Healthy() bool { return atomic.LoadInt32(&b.healthy) == 1 }
+
+// String returns the address and port of the backend.
+func (b *backend) String() string { return net.JoinHostPort(b.ip.String(), strconv.Itoa(b.check.port)) }
+
+// Start checks the backend every interval until Stop is called.
+func (b *backend) Start() {
+	go func() {
+		tick := time.NewTicker(b.check.interval)
+		defer tick.Stop()
+		for {
+			b.Check()
+			select {
+			case <-tick.C:
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the checks of Start.
+func (b *backend) Stop() { close(b.stop) }
+
+// Check checks the backend once, and updates its health when the rise or fall threshold is reached.
+func (b *backend) Check() {
+	err := b.probe()
+	if err != nil {
+		checkFailureCount.WithLabelValues(b.String()).Inc()
+		b.successes = 0
+		b.fails++
+		if b.fails == b.check.fall && b.Healthy() {
+			log.Warningf("Backend %s is unhealthy: %s", b, err)
+			atomic.StoreInt32(&b.healthy, 0)
+			healthyGauge.WithLabelValues(b.String()).Set(0)
+		}
+		return
+	}
+
+	b.fails = 0
+	b.successes++
+	if b.successes == b.check.rise && !b.Healthy() {
+		log.Infof("Backend %s is healthy", b)
+		atomic.StoreInt32(&b.healthy, 1)
+		healthyGauge.WithLabelValues(b.String()).Set(1)
+	}
+}
+
+// probe connects to the backend. For http a response with a 2xx or 3xx status code is a success.
+func (b *backend) probe() error {
+	if b.check.protocol == "tcp" {
+		conn, err := net.DialTimeout("tcp", b.String(), b.check.timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	resp, err := b.client.Get("http://" + b.String() + b.check.path)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
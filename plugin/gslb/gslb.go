@@ -0,0 +1,92 @@
+// Package gslb implements a plugin that answers with the addresses of the backends that pass their
+// health checks.
+package gslb
+
+import (
+	"context"
+	"net"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Gslb answers the A and AAAA queries for the names of its pools.
+type Gslb struct {
+	Next  plugin.Handler
+	Zones []string
+
+	pools    map[string][]*backend // name -> backends
+	backends map[string]*backend   // address -> backend, a backend is checked once for all pools
+	ttl      uint32
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (g *Gslb) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	qname := state.Name()
+	if plugin.Zones(g.Zones).Matches(qname) == "" {
+		return plugin.NextOrFailure(g.Name(), g.Next, ctx, w, r)
+	}
+	pool, ok := g.pools[qname]
+	if !ok {
+		return plugin.NextOrFailure(g.Name(), g.Next, ctx, w, r)
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	switch state.QType() {
+	case dns.TypeA:
+		for _, ip := range healthy(pool, false) {
+			m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: g.ttl}, A: ip})
+		}
+	case dns.TypeAAAA:
+		for _, ip := range healthy(pool, true) {
+			m.Answer = append(m.Answer, &dns.AAAA{Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: g.ttl}, AAAA: ip})
+		}
+	}
+
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// healthy returns the IPv4, or IPv6 when v6 is true, addresses of the healthy backends in pool. If
+// none of them is healthy all addresses are returned: an answer that may work is better than none.
+func healthy(pool []*backend, v6 bool) []net.IP {
+	all, up := []net.IP{}, []net.IP{}
+	for _, b := range pool {
+		if (b.ip.To4() == nil) != v6 {
+			continue
+		}
+		all = append(all, b.ip)
+		if b.Healthy() {
+			up = append(up, b.ip)
+		}
+	}
+	if len(up) == 0 {
+		return all
+	}
+	return up
+}
+
+// OnStartup starts the health checks of all backends.
+func (g *Gslb) OnStartup() error {
+	for _, b := range g.backends {
+		b.Start()
+	}
+	return nil
+}
+
+// OnShutdown stops the health checks of all backends.
+func (g *Gslb) OnShutdown() error {
+	for _, b := range g.backends {
+		b.Stop()
+	}
+	return nil
+}
+
+// Name implements the plugin.Handler interface.
+func (g *Gslb) Name() string { return "gslb" }
@@ -0,0 +1,128 @@
+package gslb
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestBackendCheck(t *testing.T) {
+	status := http.StatusOK
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer s.Close()
+	host, p, _ := net.SplitHostPort(s.Listener.Addr().String())
+	port, _ := strconv.Atoi(p)
+
+	for _, protocol := range []string{"http", "tcp"} {
+		b := newBackend(net.ParseIP(host), check{protocol: protocol, port: port, path: "/healthz", timeout: defaultTimeout, rise: 2, fall: 2})
+		b.Check()
+		if !b.Healthy() || b.successes != 1 {
+			t.Errorf("Expected the %s check to succeed", protocol)
+		}
+	}
+
+	b := newBackend(net.ParseIP(host), check{protocol: "http", port: port, path: "/healthz", timeout: defaultTimeout, rise: 2, fall: 2})
+	status = http.StatusServiceUnavailable
+	b.Check()
+	if !b.Healthy() {
+		t.Errorf("Expected the backend to be healthy after 1 failed check")
+	}
+	b.Check()
+	if b.Healthy() {
+		t.Errorf("Expected the backend to be unhealthy after 2 failed checks")
+	}
+
+	status = http.StatusFound
+	b.Check()
+	if b.Healthy() {
+		t.Errorf("Expected the backend to be unhealthy after 1 successful check")
+	}
+	b.Check()
+	if !b.Healthy() {
+		t.Errorf("Expected the backend to be healthy after 2 successful checks")
+	}
+
+	// Nothing listens after the server is closed.
+	s.Close()
+	b = newBackend(net.ParseIP(host), check{protocol: "tcp", port: port, timeout: defaultTimeout, rise: 1, fall: 1})
+	b.Check()
+	if b.Healthy() {
+		t.Errorf("Expected the tcp check to fail")
+	}
+}
+
+func TestGslb(t *testing.T) {
+	c := check{protocol: "tcp", port: 80}
+	up, down, down6 := newBackend(net.ParseIP("192.0.2.1"), c), newBackend(net.ParseIP("192.0.2.2"), c), newBackend(net.ParseIP("2001:db8::1"), c)
+	down.healthy, down6.healthy = 0, 0
+
+	g := &Gslb{
+		Next:  test.NextHandler(dns.RcodeNameError, nil),
+		Zones: []string{"example.org."},
+		pools: map[string][]*backend{"www.example.org.": {up, down, down6}},
+		ttl:   defaultTTL,
+	}
+
+	tests := []struct {
+		qname  string
+		qtype  uint16
+		rcode  int
+		answer []string
+	}{
+		{"www.example.org.", dns.TypeA, dns.RcodeSuccess, []string{"192.0.2.1"}},
+		{"WWW.example.org.", dns.TypeA, dns.RcodeSuccess, []string{"192.0.2.1"}},
+		// none is healthy, so all are returned
+		{"www.example.org.", dns.TypeAAAA, dns.RcodeSuccess, []string{"2001:db8::1"}},
+		{"www.example.org.", dns.TypeMX, dns.RcodeSuccess, nil},
+		{"ftp.example.org.", dns.TypeA, dns.RcodeNameError, nil},
+		{"www.example.net.", dns.TypeA, dns.RcodeNameError, nil},
+	}
+
+	for i, tc := range tests {
+		r := new(dns.Msg)
+		r.SetQuestion(tc.qname, tc.qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rcode, err := g.ServeDNS(context.TODO(), rec, r)
+		if err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rcode])
+		}
+		if rcode != dns.RcodeSuccess {
+			continue
+		}
+		if !rec.Msg.Authoritative {
+			t.Errorf("Test %d: expected an authoritative answer", i)
+		}
+		if len(rec.Msg.Answer) != len(tc.answer) {
+			t.Fatalf("Test %d: expected %d answers, got %d", i, len(tc.answer), len(rec.Msg.Answer))
+		}
+		for j, rr := range rec.Msg.Answer {
+			var ip net.IP
+			switch x := rr.(type) {
+			case *dns.A:
+				ip = x.A
+			case *dns.AAAA:
+				ip = x.AAAA
+			}
+			if ip.String() != tc.answer[j] || rr.Header().Name != tc.qname || rr.Header().Ttl != defaultTTL {
+				t.Errorf("Test %d: expected %s for %s, got %s", i, tc.answer[j], tc.qname, rr)
+			}
+		}
+	}
+}
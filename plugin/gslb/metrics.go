@@ -0,0 +1,22 @@
+package gslb
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	healthyGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "gslb",
+		Name:      "backend_healthy",
+		Help:      "Gauge of the health of each backend, 1 when healthy and 0 when not.",
+	}, []string{"backend"})
+	checkFailureCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "gslb",
+		Name:      "healthcheck_failures_total",
+		Help:      "Counter of the failed health checks of each backend.",
+	}, []string{"backend"})
+)
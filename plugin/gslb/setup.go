@@ -0,0 +1,181 @@
+package gslb
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/caddyserver/caddy"
+)
+
+var log = clog.NewWithPlugin("gslb")
+
+func init() {
+	caddy.RegisterPlugin("gslb", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	g, err := parse(c)
+	if err != nil {
+		return plugin.Error("gslb", err)
+	}
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, healthyGauge, checkFailureCount)
+		return g.OnStartup()
+	})
+	c.OnShutdown(g.OnShutdown)
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		g.Next = next
+		return g
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Gslb, error) {
+	g := &Gslb{pools: make(map[string][]*backend), backends: make(map[string]*backend), ttl: defaultTTL}
+	chk := check{interval: defaultInterval, timeout: defaultTimeout, rise: defaultRise, fall: defaultFall}
+	pools := make(map[string][]net.IP)
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		g.Zones = make([]string, len(c.ServerBlockKeys))
+		copy(g.Zones, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			g.Zones = args
+		}
+		for i := range g.Zones {
+			g.Zones[i] = plugin.Host(g.Zones[i]).Normalize()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "pool":
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				name := plugin.Name(args[0]).Normalize()
+				if plugin.Zones(g.Zones).Matches(name) == "" {
+					return nil, c.Errf("pool '%s' is not in the zones", args[0])
+				}
+				for _, a := range args[1:] {
+					ip := net.ParseIP(a)
+					if ip == nil {
+						return nil, c.Errf("invalid address '%s'", a)
+					}
+					pools[name] = append(pools[name], ip)
+				}
+
+			case "check":
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				chk.protocol = args[0]
+				port, err := strconv.Atoi(args[1])
+				if err != nil || port <= 0 || port > 65535 {
+					return nil, c.Errf("invalid port '%s'", args[1])
+				}
+				chk.port = port
+				switch {
+				case chk.protocol == "tcp" && len(args) == 2:
+				case chk.protocol == "http" && len(args) == 2:
+					chk.path = "/"
+				case chk.protocol == "http" && len(args) == 3:
+					chk.path = args[2]
+					if !strings.HasPrefix(chk.path, "/") {
+						chk.path = "/" + chk.path
+					}
+				case chk.protocol != "tcp" && chk.protocol != "http":
+					return nil, c.Errf("unknown check '%s'", chk.protocol)
+				default:
+					return nil, c.ArgErr()
+				}
+
+			case "interval", "timeout":
+				prop := c.Val()
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, err
+				}
+				if d <= 0 {
+					return nil, c.Errf("%s can't be negative or zero: %s", prop, d)
+				}
+				if prop == "interval" {
+					chk.interval = d
+				} else {
+					chk.timeout = d
+				}
+
+			case "rise", "fall":
+				prop := c.Val()
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(c.Val())
+				if err != nil {
+					return nil, err
+				}
+				if n <= 0 {
+					return nil, c.Errf("%s must be positive: %d", prop, n)
+				}
+				if prop == "rise" {
+					chk.rise = n
+				} else {
+					chk.fall = n
+				}
+
+			case "ttl":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				ttl, err := strconv.ParseUint(c.Val(), 10, 32)
+				if err != nil {
+					return nil, c.Errf("invalid ttl '%s'", c.Val())
+				}
+				g.ttl = uint32(ttl)
+
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	if len(pools) == 0 {
+		return nil, c.Err("no pool given")
+	}
+	if chk.protocol == "" {
+		return nil, c.Err("no check given")
+	}
+
+	for name, ips := range pools {
+		for _, ip := range ips {
+			b, ok := g.backends[ip.String()]
+			if !ok {
+				b = newBackend(ip, chk)
+				g.backends[ip.String()] = b
+			}
+			g.pools[name] = append(g.pools[name], b)
+		}
+	}
+	return g, nil
+}
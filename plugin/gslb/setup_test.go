@@ -0,0 +1,135 @@
+package gslb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetupGslb(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expErr    string
+	}{
+		{`gslb example.org {
+			pool www.example.org 192.0.2.1 2001:db8::1
+			check tcp 80
+		}`, false, ""},
+		{`gslb example.org {
+			pool www.example.org 192.0.2.1
+			pool ftp.example.org 192.0.2.1 192.0.2.2
+			check http 8080 healthz
+			interval 1s
+			timeout 500ms
+			rise 1
+			fall 5
+			ttl 10
+		}`, false, ""},
+		// fails
+		{`gslb example.org`, true, "no pool given"},
+		{`gslb example.org {
+			pool www.example.org 192.0.2.1
+		}`, true, "no check given"},
+		{`gslb example.org {
+			pool www.example.net 192.0.2.1
+			check tcp 80
+		}`, true, "not in the zones"},
+		{`gslb example.org {
+			pool www.example.org 192.0.2
+			check tcp 80
+		}`, true, "invalid address"},
+		{`gslb example.org {
+			pool www.example.org
+		}`, true, "Wrong argument count"},
+		{`gslb example.org {
+			pool www.example.org 192.0.2.1
+			check udp 53
+		}`, true, "unknown check"},
+		{`gslb example.org {
+			pool www.example.org 192.0.2.1
+			check tcp 80 /healthz
+		}`, true, "Wrong argument count"},
+		{`gslb example.org {
+			pool www.example.org 192.0.2.1
+			check http 0
+		}`, true, "invalid port"},
+		{`gslb example.org {
+			pool www.example.org 192.0.2.1
+			check tcp 80
+			interval 0s
+		}`, true, "can't be negative or zero"},
+		{`gslb example.org {
+			pool www.example.org 192.0.2.1
+			check tcp 80
+			fall 0
+		}`, true, "must be positive"},
+		{`gslb example.org {
+			pool www.example.org 192.0.2.1
+			check tcp 80
+			ttl -1
+		}`, true, "invalid ttl"},
+		{`gslb example.org {
+			pool www.example.org 192.0.2.1
+			check tcp 80
+			fallthrough
+		}`, true, "unknown property"},
+		{`gslb example.org {
+			pool www.example.org 192.0.2.1
+			check tcp 80
+		}
+		gslb example.org {
+			pool www.example.org 192.0.2.1
+			check tcp 80
+		}`, true, "this plugin"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		_, err := parse(c)
+
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+		}
+		if err != nil {
+			if !test.shouldErr {
+				t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			}
+			if !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v, input: %s", i, test.expErr, err, test.input)
+			}
+		}
+	}
+}
+
+func TestSetupGslbCheck(t *testing.T) {
+	c := caddy.NewTestController("dns", `gslb example.org {
+		pool www.example.org 192.0.2.1
+		pool ftp.example.org 192.0.2.1 192.0.2.2
+		check http 8080 healthz
+		interval 1s
+		rise 1
+	}`)
+	g, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(g.backends) != 2 {
+		t.Errorf("Expected 2 backends, got %d", len(g.backends))
+	}
+	if g.pools["www.example.org."][0] != g.pools["ftp.example.org."][0] {
+		t.Errorf("Expected the backend to be shared by the pools")
+	}
+	chk := g.backends["192.0.2.1"].check
+	if chk.protocol != "http" || chk.port != 8080 || chk.path != "/healthz" {
+		t.Errorf("Expected a check of http://192.0.2.1:8080/healthz, got %v", chk)
+	}
+	if chk.interval != time.Second || chk.timeout != defaultTimeout || chk.rise != 1 || chk.fall != defaultFall {
+		t.Errorf("Expected the interval and rise to be set, got %v", chk)
+	}
+	if g.ttl != defaultTTL {
+		t.Errorf("Expected TTL %d, got %d", defaultTTL, g.ttl)
+	}
+}
@@ -16,6 +16,7 @@ var log = clog.NewWithPlugin("health")
 type health struct {
 	Addr     string
 	lameduck time.Duration
+	doh      bool // serve /health on the DNS-over-HTTPS servers, without a listener when Addr is empty
 
 	ln      net.Listener
 	nlSetup bool
@@ -25,10 +26,15 @@ type health struct {
 }
 
 func (h *health) OnStartup() error {
+	h.stop = make(chan bool)
 	if h.Addr == "" {
+		if h.doh {
+			// Only the lameduck duration is left to handle.
+			h.nlSetup = true
+			return nil
+		}
 		h.Addr = ":8080"
 	}
-	h.stop = make(chan bool)
 
 	ln, err := net.Listen("tcp", h.Addr)
 	if err != nil {
@@ -39,12 +45,7 @@ func (h *health) OnStartup() error {
 	h.mux = http.NewServeMux()
 	h.nlSetup = true
 
-	h.mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		// We're always healthy.
-		w.WriteHeader(http.StatusOK)
-		io.WriteString(w, "OK")
-		return
-	})
+	h.mux.HandleFunc("/health", serveHealth)
 
 	go func() { http.Serve(h.ln, h.mux) }()
 	go func() { h.overloaded() }()
@@ -62,9 +63,17 @@ func (h *health) OnFinalShutdown() error {
 		time.Sleep(h.lameduck)
 	}
 
-	h.ln.Close()
+	if h.ln != nil {
+		h.ln.Close()
+	}
 
 	h.nlSetup = false
 	close(h.stop)
 	return nil
 }
+
+// serveHealth responds to the health checks. We're always healthy.
+func serveHealth(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, "OK")
+}
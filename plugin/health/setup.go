@@ -3,8 +3,10 @@ package health
 import (
 	"fmt"
 	"net"
+	"net/http"
 	"time"
 
+	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/metrics"
 
@@ -19,12 +21,15 @@ func init() {
 }
 
 func setup(c *caddy.Controller) error {
-	addr, lame, err := parse(c)
+	addr, lame, doh, err := parse(c)
 	if err != nil {
 		return plugin.Error("health", err)
 	}
 
-	h := &health{Addr: addr, stop: make(chan bool), lameduck: lame}
+	h := &health{Addr: addr, stop: make(chan bool), lameduck: lame, doh: doh}
+	if doh {
+		dnsserver.GetConfig(c).AddHTTPHandler("/health", http.HandlerFunc(serveHealth))
+	}
 
 	c.OnStartup(func() error {
 		metrics.MustRegister(c, HealthDuration)
@@ -40,9 +45,10 @@ func setup(c *caddy.Controller) error {
 	return nil
 }
 
-func parse(c *caddy.Controller) (string, time.Duration, error) {
+func parse(c *caddy.Controller) (string, time.Duration, bool, error) {
 	addr := ""
 	dur := time.Duration(0)
+	doh := false
 	for c.Next() {
 		args := c.RemainingArgs()
 
@@ -51,10 +57,10 @@ func parse(c *caddy.Controller) (string, time.Duration, error) {
 		case 1:
 			addr = args[0]
 			if _, _, e := net.SplitHostPort(addr); e != nil {
-				return "", 0, e
+				return "", 0, false, e
 			}
 		default:
-			return "", 0, c.ArgErr()
+			return "", 0, false, c.ArgErr()
 		}
 
 		for c.NextBlock() {
@@ -62,17 +68,22 @@ func parse(c *caddy.Controller) (string, time.Duration, error) {
 			case "lameduck":
 				args := c.RemainingArgs()
 				if len(args) != 1 {
-					return "", 0, c.ArgErr()
+					return "", 0, false, c.ArgErr()
 				}
 				l, err := time.ParseDuration(args[0])
 				if err != nil {
-					return "", 0, fmt.Errorf("unable to parse lameduck duration value: '%v' : %v", args[0], err)
+					return "", 0, false, fmt.Errorf("unable to parse lameduck duration value: '%v' : %v", args[0], err)
 				}
 				dur = l
+			case "doh":
+				if len(c.RemainingArgs()) != 0 {
+					return "", 0, false, c.ArgErr()
+				}
+				doh = true
 			default:
-				return "", 0, c.ArgErr()
+				return "", 0, false, c.ArgErr()
 			}
 		}
 	}
-	return addr, dur, nil
+	return addr, dur, doh, nil
 }
@@ -17,6 +17,13 @@ func TestSetupHealth(t *testing.T) {
 			lameduck 4s
 }`, false},
 		{`health bla:a`, false},
+		{`health {
+			doh
+}`, false},
+		{`health localhost:1234 {
+			lameduck 4s
+			doh
+}`, false},
 
 		{`health bla`, true},
 		{`health bla bla`, true},
@@ -26,11 +33,14 @@ func TestSetupHealth(t *testing.T) {
 		{`health localhost:1234 {
 			lamedudk 4
 } `, true},
+		{`health {
+			doh yes
+}`, true},
 	}
 
 	for i, test := range tests {
 		c := caddy.NewTestController("dns", test.input)
-		_, _, err := parse(c)
+		_, _, _, err := parse(c)
 
 		if test.shouldErr && err == nil {
 			t.Errorf("Test %d: Expected error but found none for input %s", i, test.input)
@@ -0,0 +1,21 @@
+package hosts
+
+import "net"
+
+// Addresses implements the autoptr.Addresser interface.
+func (h *Hostsfile) Addresses(f func(name string, ip net.IP)) {
+	h.RLock()
+	defer h.RUnlock()
+	for _, m := range []*Map{h.inline, h.hmap} {
+		for name, ips := range m.name4 {
+			for _, ip := range ips {
+				f(name, ip)
+			}
+		}
+		for name, ips := range m.name6 {
+			for _, ip := range ips {
+				f(name, ip)
+			}
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package hosts
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAddresses(t *testing.T) {
+	h := testHostsfile(hosts)
+	h.initInline([]string{"192.0.2.1 inline.example.org"})
+
+	got := map[string]bool{}
+	h.Addresses(func(name string, ip net.IP) { got[name+" "+ip.String()] = true })
+
+	for _, a := range []string{"odin. 127.0.0.2", "odin. ::2", "ullrhost. 127.1.1.2", "inline.example.org. 192.0.2.1"} {
+		if !got[a] {
+			t.Errorf("Expected address %q, got %v", a, got)
+		}
+	}
+}
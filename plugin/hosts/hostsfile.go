@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/changes"
 )
 
 // parseIP calls discards any v6 zone info, before calling net.ParseIP.
@@ -136,6 +137,10 @@ func (h *Hostsfile) readHosts() {
 	h.size = stat.Size()
 
 	h.Unlock()
+
+	for _, origin := range h.Origins {
+		changes.Notify(origin)
+	}
 }
 
 func (h *Hostsfile) initInline(inline []string) {
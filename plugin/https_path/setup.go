@@ -0,0 +1,54 @@
+// Package httpspath implements a plugin that sets the URL paths of the DNS-over-HTTPS queries.
+package httpspath
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("https_path", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	paths, err := parse(c)
+	if err != nil {
+		return plugin.Error("https_path", err)
+	}
+	dnsserver.GetConfig(c).HTTPSPaths = paths
+	return nil
+}
+
+func parse(c *caddy.Controller) ([]string, error) {
+	var paths []string
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		paths = c.RemainingArgs()
+		if len(paths) == 0 {
+			return nil, c.ArgErr()
+		}
+		seen := make(map[string]bool)
+		for _, p := range paths {
+			if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, "?#") {
+				return nil, c.Errf("invalid path '%s'", p)
+			}
+			if seen[p] {
+				return nil, c.Errf("duplicate path '%s'", p)
+			}
+			seen[p] = true
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,44 @@
+package httpspath
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  []string
+	}{
+		{`https_path /mydns`, false, []string{"/mydns"}},
+		{`https_path /mydns /dns-query`, false, []string{"/mydns", "/dns-query"}},
+		{`https_path`, true, nil},
+		{`https_path mydns`, true, nil},
+		{`https_path /dns?x=1`, true, nil},
+		{`https_path /mydns /mydns`, true, nil},
+		{"https_path /a\nhttps_path /b", true, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if paths := dnsserver.GetConfig(c).HTTPSPaths; !reflect.DeepEqual(paths, tc.expected) {
+			t.Errorf("Test %d: expected paths %v, got %v", i, tc.expected, paths)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package httpsserver implements a plugin that tunes the HTTP server of the DNS-over-HTTPS queries.
+package httpsserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("https_server", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	o, err := parse(c)
+	if err != nil {
+		return plugin.Error("https_server", err)
+	}
+	dnsserver.GetConfig(c).HTTPOptions = o
+	return nil
+}
+
+func parse(c *caddy.Controller) (*dnsserver.HTTPOptions, error) {
+	o := new(dnsserver.HTTPOptions)
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		if len(c.RemainingArgs()) > 0 {
+			return nil, c.ArgErr()
+		}
+		for c.NextBlock() {
+			switch c.Val() {
+			case "read_timeout", "write_timeout", "idle_timeout":
+				name := c.Val()
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid duration '%s'", c.Val())
+				}
+				if d <= 0 {
+					return nil, c.Errf("%s must be positive: %s", name, d)
+				}
+				switch name {
+				case "read_timeout":
+					o.ReadTimeout = d
+				case "write_timeout":
+					o.WriteTimeout = d
+				case "idle_timeout":
+					o.IdleTimeout = d
+				}
+			case "max_header_bytes":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(c.Val())
+				if err != nil || n <= 0 {
+					return nil, c.Errf("invalid max_header_bytes '%s'", c.Val())
+				}
+				o.MaxHeaderBytes = n
+			case "max_concurrent_streams":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.ParseUint(c.Val(), 10, 32)
+				if err != nil || n == 0 {
+					return nil, c.Errf("invalid max_concurrent_streams '%s'", c.Val())
+				}
+				o.MaxConcurrentStreams = uint32(n)
+			case "trusted_proxies":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					n, err := network(a)
+					if err != nil {
+						return nil, c.Err(err.Error())
+					}
+					o.TrustedProxies = append(o.TrustedProxies, n)
+				}
+				continue
+			case "bearer_tokens":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				if o.Auth == nil {
+					o.Auth = new(dnsserver.HTTPAuth)
+				}
+				for _, t := range args {
+					o.Auth.AddToken(t)
+				}
+				continue
+			case "bearer_token_file":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				tokens, err := readTokens(c.Val())
+				if err != nil {
+					return nil, c.Err(err.Error())
+				}
+				if o.Auth == nil {
+					o.Auth = new(dnsserver.HTTPAuth)
+				}
+				for _, t := range tokens {
+					o.Auth.AddToken(t)
+				}
+			case "basic_auth":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				if o.Auth == nil {
+					o.Auth = new(dnsserver.HTTPAuth)
+				}
+				o.Auth.AddUser(args[0], args[1])
+				continue
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+			if c.NextArg() {
+				return nil, c.ArgErr()
+			}
+		}
+	}
+	return o, nil
+}
+
+// network parses a network in CIDR notation, or an address.
+func network(a string) (*net.IPNet, error) {
+	_, n, err := net.ParseCIDR(a)
+	if err == nil {
+		return n, nil
+	}
+	ip := net.ParseIP(a)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid network: %s", a)
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// readTokens reads the bearer tokens of file, one per line. Empty lines and lines starting with # are
+// skipped.
+func readTokens(file string) ([]string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []string
+	for _, l := range strings.Split(string(b), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		tokens = append(tokens, l)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no bearer tokens in %s", file)
+	}
+	return tokens, nil
+}
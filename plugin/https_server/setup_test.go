@@ -0,0 +1,116 @@
+package httpsserver
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  dnsserver.HTTPOptions
+	}{
+		{`https_server`, false, dnsserver.HTTPOptions{}},
+		{"https_server {\nread_timeout 2s\nwrite_timeout 3s\nidle_timeout 1m\n}", false,
+			dnsserver.HTTPOptions{ReadTimeout: 2 * time.Second, WriteTimeout: 3 * time.Second, IdleTimeout: time.Minute}},
+		{"https_server {\nmax_header_bytes 8192\nmax_concurrent_streams 100\n}", false,
+			dnsserver.HTTPOptions{MaxHeaderBytes: 8192, MaxConcurrentStreams: 100}},
+		{`https_server 5s`, true, dnsserver.HTTPOptions{}},
+		{"https_server {\nread_timeout\n}", true, dnsserver.HTTPOptions{}},
+		{"https_server {\nread_timeout 0s\n}", true, dnsserver.HTTPOptions{}},
+		{"https_server {\nidle_timeout soon\n}", true, dnsserver.HTTPOptions{}},
+		{"https_server {\nwrite_timeout 1s 2s\n}", true, dnsserver.HTTPOptions{}},
+		{"https_server {\nmax_header_bytes -1\n}", true, dnsserver.HTTPOptions{}},
+		{"https_server {\nmax_concurrent_streams 0\n}", true, dnsserver.HTTPOptions{}},
+		{"https_server {\nmax_concurrent_streams 4294967296\n}", true, dnsserver.HTTPOptions{}},
+		{"https_server {\ntrusted_proxies 10.0.0.0/8 192.0.2.1\n}", false,
+			dnsserver.HTTPOptions{TrustedProxies: []*net.IPNet{
+				{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+				{IP: net.IP{192, 0, 2, 1}, Mask: net.CIDRMask(32, 32)},
+			}}},
+		{"https_server {\ntrusted_proxies\n}", true, dnsserver.HTTPOptions{}},
+		{"https_server {\ntrusted_proxies 10.0.0.0/8 example.org\n}", true, dnsserver.HTTPOptions{}},
+		{"https_server {\nblah\n}", true, dnsserver.HTTPOptions{}},
+		{"https_server\nhttps_server", true, dnsserver.HTTPOptions{}},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if o := dnsserver.GetConfig(c).HTTPOptions; !reflect.DeepEqual(*o, tc.expected) {
+			t.Errorf("Test %d: expected %+v, got %+v", i, tc.expected, *o)
+		}
+	}
+}
+
+func TestSetupAuth(t *testing.T) {
+	f, err := ioutil.TempFile("", "tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("# customers\ntoken-c\n\n  token-d  \n")
+	f.Close()
+
+	auth := func(tokens []string, users ...string) *dnsserver.HTTPAuth {
+		a := new(dnsserver.HTTPAuth)
+		for _, t := range tokens {
+			a.AddToken(t)
+		}
+		for i := 0; i < len(users); i += 2 {
+			a.AddUser(users[i], users[i+1])
+		}
+		return a
+	}
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  *dnsserver.HTTPAuth
+	}{
+		{"https_server {\nbearer_tokens token-a token-b\n}", false, auth([]string{"token-a", "token-b"})},
+		{"https_server {\nbearer_token_file " + f.Name() + "\nbearer_tokens token-a\n}", false, auth([]string{"token-c", "token-d", "token-a"})},
+		{"https_server {\nbasic_auth alice secret\n}", false, auth(nil, "alice", "secret")},
+		{"https_server {\nbearer_tokens\n}", true, nil},
+		{"https_server {\nbearer_token_file\n}", true, nil},
+		{"https_server {\nbearer_token_file /does/not/exist\n}", true, nil},
+		{"https_server {\nbasic_auth alice\n}", true, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if a := dnsserver.GetConfig(c).HTTPOptions.Auth; !reflect.DeepEqual(a, tc.expected) {
+			t.Errorf("Test %d: expected %+v, got %+v", i, tc.expected, a)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package kubernetes
+
+import (
+	"net"
+	"strings"
+)
+
+// Addresses implements the autoptr.Addresser interface. It gives the cluster IPs of the services of
+// the exposed namespaces.
+func (k *Kubernetes) Addresses(f func(name string, ip net.IP)) {
+	for _, svc := range k.APIConn.ServiceList() {
+		if len(k.Namespaces) > 0 && !k.namespaceExposed(svc.Namespace) {
+			continue
+		}
+		ip := net.ParseIP(svc.ClusterIP)
+		if ip == nil {
+			continue
+		}
+		f(strings.Join([]string{svc.Name, svc.Namespace, Svc, k.primaryZone()}, "."), ip)
+	}
+}
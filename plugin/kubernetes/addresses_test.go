@@ -0,0 +1,25 @@
+package kubernetes
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAddresses(t *testing.T) {
+	k := New([]string{"cluster.local."})
+	k.APIConn = &APIConnServeTest{}
+	k.Namespaces = map[string]struct{}{"testns": {}}
+
+	got := map[string]bool{}
+	k.Addresses(func(name string, ip net.IP) { got[name+" "+ip.String()] = true })
+
+	expected := []string{"svc1.testns.svc.cluster.local. 10.0.0.1", "svc6.testns.svc.cluster.local. 1234:abcd::1"}
+	for _, a := range expected {
+		if !got[a] {
+			t.Errorf("Expected address %q, got %v", a, got)
+		}
+	}
+	if got["svc1.unexposedns.svc.cluster.local. 10.0.0.2"] {
+		t.Errorf("Expected no address for a service of an unexposed namespace")
+	}
+}
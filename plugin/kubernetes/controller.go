@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/coredns/plugin/pkg/changes"
 
 	api "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -509,6 +510,9 @@ func (dns *dnsControl) Modified() int64 {
 func (dns *dnsControl) updateModifed() {
 	unix := time.Now().Unix()
 	atomic.StoreInt64(&dns.modified, unix)
+	for _, z := range dns.zones {
+		changes.Notify(z)
+	}
 }
 
 var errObj = errors.New("obj was not of the correct type")
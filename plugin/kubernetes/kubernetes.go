@@ -48,6 +48,8 @@ type Kubernetes struct {
 	interfaceAddrsFunc func() net.IP
 	autoPathSearch     []string // Local search path from /etc/resolv.conf. Needed for autopath.
 	TransferTo         []string
+	TransferKeys       map[string]string // the TSIG key names the TransferTo addresses must sign with, if any
+	TsigSecret         map[string]string // the secrets of the keys in TransferKeys, to sign our notifies with
 }
 
 // New returns a initialized Kubernetes. It default interfaceAddrFunc to return 127.0.0.1. All other
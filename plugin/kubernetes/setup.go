@@ -51,11 +51,26 @@ func setup(c *caddy.Controller) error {
 
 	err = k.InitKubeCache()
 	if err != nil {
-		return plugin.Error("kubernetes", err)
+		if err := dnsserver.Failure(c, "kubernetes", err); err != nil {
+			return plugin.Error("kubernetes", err)
+		}
+		return nil
 	}
 
 	k.RegisterKubeCache(c)
 
+	if len(k.TransferTo) > 0 {
+		var stop func()
+		c.OnStartup(func() error {
+			stop = k.notify()
+			return nil
+		})
+		c.OnShutdown(func() error {
+			stop()
+			return nil
+		})
+	}
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		k.Next = next
 		return k
@@ -245,7 +260,7 @@ func ParseStanza(c *caddy.Controller) (*Kubernetes, error) {
 			}
 			k8s.ttl = uint32(t)
 		case "transfer":
-			tos, froms, err := parse.Transfer(c, false)
+			tos, froms, key, err := parse.Transfer(c, false)
 			if err != nil {
 				return nil, err
 			}
@@ -253,6 +268,17 @@ func ParseStanza(c *caddy.Controller) (*Kubernetes, error) {
 				return nil, c.Errf("transfer from is not supported with this plugin")
 			}
 			k8s.TransferTo = tos
+			k8s.TransferKeys, k8s.TsigSecret = nil, nil
+			if key != nil {
+				if err := dnsserver.GetConfig(c).AddTsigKey(key.Name, key.Secret); err != nil {
+					return nil, err
+				}
+				k8s.TsigSecret = map[string]string{key.Name: key.Secret}
+				k8s.TransferKeys = make(map[string]string)
+				for _, to := range tos {
+					k8s.TransferKeys[to] = key.Name
+				}
+			}
 		case "noendpoints":
 			if len(c.RemainingArgs()) != 0 {
 				return nil, c.ArgErr()
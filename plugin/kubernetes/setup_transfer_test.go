@@ -3,6 +3,8 @@ package kubernetes
 import (
 	"testing"
 
+	"github.com/coredns/coredns/core/dnsserver"
+
 	"github.com/caddyserver/caddy"
 )
 
@@ -45,3 +47,19 @@ func TestKubernetesParseTransfer(t *testing.T) {
 		}
 	}
 }
+
+func TestKubernetesParseTransferKey(t *testing.T) {
+	c := caddy.NewTestController("dns", `kubernetes cluster.local {
+		transfer to 1.2.3.4 key xfr.cluster.local c2VjcmV0
+	}`)
+	k, err := kubernetesParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %q", err)
+	}
+	if x := k.TransferKeys["1.2.3.4:53"]; x != "xfr.cluster.local." {
+		t.Errorf("Expected key %q for %q, got %q", "xfr.cluster.local.", "1.2.3.4:53", x)
+	}
+	if x := dnsserver.GetConfig(c).TsigSecret["xfr.cluster.local."]; x != "c2VjcmV0" {
+		t.Errorf("Expected the secret of the key in the config, got %q", x)
+	}
+}
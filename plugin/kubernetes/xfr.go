@@ -8,6 +8,8 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/pkg/changes"
+	"github.com/coredns/coredns/plugin/pkg/transfer"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -76,23 +78,41 @@ func (k *Kubernetes) Transfer(ctx context.Context, state request.Request) (int,
 }
 
 // transferAllowed checks if incoming request for transferring the zone is allowed according to the ACLs.
-// Note: This is copied from zone.transferAllowed, but should eventually be factored into a common transfer pkg.
 func (k *Kubernetes) transferAllowed(state request.Request) bool {
-	for _, t := range k.TransferTo {
-		if t == "*" {
-			return true
+	return transfer.Allowed(state, k.TransferTo, k.TransferKeys)
+}
+
+// notify sends notifies for our zones to the TransferTo addresses whenever the serial changes. Changes
+// that come in while the notifies are being sent are coalesced into a single next round. It returns a
+// function that stops the notifies.
+func (k *Kubernetes) notify() (stop func()) {
+	pending := make(chan struct{}, 1)
+	done := make(chan struct{})
+	unwatch := changes.Watch(func(zone string) {
+		if plugin.Zones(k.Zones).Matches(zone) == "" {
+			return
 		}
-		// If remote IP matches we accept.
-		remote := state.IP()
-		to, _, err := net.SplitHostPort(t)
-		if err != nil {
-			continue
+		select {
+		case pending <- struct{}{}:
+		default:
 		}
-		if to == remote {
-			return true
+	})
+	go func() {
+		for {
+			select {
+			case <-pending:
+				for _, z := range k.Zones {
+					transfer.Notify(z, k.TransferTo, k.TransferKeys, k.TsigSecret)
+				}
+			case <-done:
+				return
+			}
 		}
+	}()
+	return func() {
+		unwatch()
+		close(done)
 	}
-	return false
 }
 
 func (k *Kubernetes) transfer(c chan dns.RR, zone string) {
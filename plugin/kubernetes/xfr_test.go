@@ -4,8 +4,10 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/coredns/plugin/pkg/changes"
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
 	"github.com/coredns/coredns/plugin/test"
 
@@ -127,6 +129,36 @@ func TestKubernetesXFRNotAllowed(t *testing.T) {
 	}
 }
 
+func TestKubernetesNotify(t *testing.T) {
+	notified := make(chan string, 1)
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		if r.Opcode == dns.OpcodeNotify {
+			notified <- r.Question[0].Name
+		}
+	})
+	defer s.Close()
+
+	k := New([]string{"cluster.local."})
+	k.TransferTo = []string{s.Addr}
+	stop := k.notify()
+	defer stop()
+
+	changes.Notify("example.org.")
+	changes.Notify("cluster.local.")
+
+	select {
+	case zone := <-notified:
+		if zone != "cluster.local." {
+			t.Errorf("Expected a notify for %q, got %q", "cluster.local.", zone)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a notify, got none")
+	}
+}
+
 // difference shows what we're missing when comparing two RR slices
 func difference(testRRs []dns.RR, gotRRs []dns.RR) []dns.RR {
 	expectedRRs := map[string]struct{}{}
@@ -2,6 +2,8 @@ package loadbalance
 
 import (
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
@@ -20,11 +22,32 @@ func init() {
 }
 
 func setup(c *caddy.Controller) error {
-	err := parse(c)
+	policy, w, err := parse(c)
 	if err != nil {
 		return plugin.Error("loadbalance", err)
 	}
 
+	if policy == "weighted" {
+		if w.path != "" {
+			stop := w.periodicUpdate()
+			c.OnStartup(func() error {
+				if err := w.read(); err != nil {
+					return plugin.Error("loadbalance", err)
+				}
+				return nil
+			})
+			c.OnShutdown(func() error {
+				close(stop)
+				return nil
+			})
+		}
+
+		dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+			return Weighted{Next: next, weights: w}
+		})
+		return nil
+	}
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		return RoundRobin{Next: next}
 	})
@@ -32,19 +55,54 @@ func setup(c *caddy.Controller) error {
 	return nil
 }
 
-func parse(c *caddy.Controller) error {
+// parse returns the policy, and the weights for the weighted policy.
+func parse(c *caddy.Controller) (string, *weights, error) {
+	config := dnsserver.GetConfig(c)
+
 	for c.Next() {
 		args := c.RemainingArgs()
 		switch len(args) {
 		case 0:
-			return nil
-		case 1:
-			if args[0] != "round_robin" {
-				return fmt.Errorf("unknown policy: %s", args[0])
-
+			return "round_robin", nil, nil
+		case 1, 2:
+			switch {
+			case args[0] == "weighted":
+				w := newWeights("")
+				if len(args) > 1 {
+					w.path = args[1]
+					if !filepath.IsAbs(w.path) && config.Root != "" {
+						w.path = filepath.Join(config.Root, w.path)
+					}
+				}
+				for c.NextBlock() {
+					switch c.Val() {
+					case "reload":
+						if w.path == "" {
+							return "", nil, c.Err("reload needs a weight file")
+						}
+						if !c.NextArg() {
+							return "", nil, c.ArgErr()
+						}
+						reload, err := time.ParseDuration(c.Val())
+						if err != nil {
+							return "", nil, c.Errf("invalid duration for reload '%s'", c.Val())
+						}
+						if reload < 0 {
+							return "", nil, c.Errf("invalid negative duration for reload '%s'", c.Val())
+						}
+						w.reload = reload
+					default:
+						return "", nil, c.Errf("unknown property '%s'", c.Val())
+					}
+				}
+				return args[0], w, nil
+			case len(args) > 1:
+				return "", nil, c.ArgErr()
+			case args[0] != "round_robin":
+				return "", nil, fmt.Errorf("unknown policy: %s", args[0])
 			}
-			return nil
+			return args[0], nil, nil
 		}
 	}
-	return c.ArgErr()
+	return "", nil, c.ArgErr()
 }
@@ -17,14 +17,30 @@ func TestSetup(t *testing.T) {
 		// positive
 		{`loadbalance`, false, "round_robin", ""},
 		{`loadbalance round_robin`, false, "round_robin", ""},
+		{`loadbalance weighted`, false, "weighted", ""},
+		{`loadbalance weighted weights.txt`, false, "weighted", ""},
+		{`loadbalance weighted weights.txt {
+			reload 10s
+		}`, false, "weighted", ""},
 		// negative
 		{`loadbalance fleeb`, true, "", "unknown policy"},
 		{`loadbalance a b`, true, "", "argument count or unexpected line"},
+		{`loadbalance round_robin a`, true, "", "argument count or unexpected line"},
+		{`loadbalance weighted a b`, true, "", "argument count or unexpected line"},
+		{`loadbalance weighted {
+			reload 10s
+		}`, true, "", "reload needs a weight file"},
+		{`loadbalance weighted weights.txt {
+			reload -1s
+		}`, true, "", "invalid negative duration"},
+		{`loadbalance weighted weights.txt {
+			fleeb
+		}`, true, "", "unknown property"},
 	}
 
 	for i, test := range tests {
 		c := caddy.NewTestController("dns", test.input)
-		err := parse(c)
+		policy, _, err := parse(c)
 
 		if test.shouldErr && err == nil {
 			t.Errorf("Test %d: Expected error but found %s for input %s", i, err, test.input)
@@ -38,6 +54,10 @@ func TestSetup(t *testing.T) {
 			if !strings.Contains(err.Error(), test.expectedErrContent) {
 				t.Errorf("Test %d: Expected error to contain: %v, found error: %v, input: %s", i, test.expectedErrContent, err, test.input)
 			}
+			continue
+		}
+		if policy != test.expectedPolicy {
+			t.Errorf("Test %d: Expected policy %s, got %s", i, test.expectedPolicy, policy)
 		}
 	}
 }
@@ -0,0 +1,298 @@
+package loadbalance
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Weighted is plugin to rewrite responses for "load balancing" with weights: the address that is
+// put first is picked at random, in proportion to its weight.
+type Weighted struct {
+	Next    plugin.Handler
+	weights *weights
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (wr Weighted) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	ww := &WeightedResponseWriter{ResponseWriter: w, ctx: ctx, weights: wr.weights}
+	return plugin.NextOrFailure(wr.Name(), wr.Next, ctx, ww, r)
+}
+
+// Name implements the Handler interface.
+func (wr Weighted) Name() string { return "loadbalance" }
+
+// WeightedResponseWriter is a response writer that shuffles A, AAAA and MX records, and puts an
+// address picked by weight first.
+type WeightedResponseWriter struct {
+	dns.ResponseWriter
+	ctx     context.Context
+	weights *weights
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (r *WeightedResponseWriter) WriteMsg(res *dns.Msg) error {
+	if res.Rcode != dns.RcodeSuccess {
+		return r.ResponseWriter.WriteMsg(res)
+	}
+
+	if res.Question[0].Qtype == dns.TypeAXFR || res.Question[0].Qtype == dns.TypeIXFR {
+		return r.ResponseWriter.WriteMsg(res)
+	}
+
+	state := request.Request{W: r.ResponseWriter, Req: res}
+	res.Answer = r.weights.order(r.ctx, state, roundRobin(res.Answer))
+	res.Ns = roundRobin(res.Ns)
+	res.Extra = roundRobin(res.Extra)
+
+	return r.ResponseWriter.WriteMsg(res)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (r *WeightedResponseWriter) Write(buf []byte) (int, error) {
+	log.Warning("Weighted called with Write: not shuffling records")
+	return r.ResponseWriter.Write(buf)
+}
+
+// weights holds the weights of the addresses of the domain names. They are read from a weight file,
+// or when there is none, from the TXT records of _weight.<name>.
+type weights struct {
+	path   string
+	reload time.Duration
+
+	sync.RWMutex
+	domains map[string]map[string]uint8 // name -> address -> weight
+	mtime   time.Time
+	size    int64
+
+	lookup func(ctx context.Context, state request.Request, name string, typ uint16) (*dns.Msg, error)
+	random func(n int) int
+}
+
+// defaultWeight is the weight of an address the weights don't have.
+const defaultWeight = 1
+
+func newWeights(path string) *weights {
+	return &weights{path: path, reload: 30 * time.Second, domains: map[string]map[string]uint8{}, lookup: upstream.New().Lookup, random: rand.Intn}
+}
+
+// order puts the address that is picked by weight first in rrs, which is ordered by roundRobin. For
+// an answer with addresses of multiple names, the weights are those of the first name.
+func (w *weights) order(ctx context.Context, state request.Request, rrs []dns.RR) []dns.RR {
+	first := -1
+	for i, rr := range rrs {
+		if t := rr.Header().Rrtype; t == dns.TypeA || t == dns.TypeAAAA {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return rrs
+	}
+	name := strings.ToLower(rrs[first].Header().Name)
+	ws := w.get(ctx, state, name)
+	if ws == nil {
+		return rrs
+	}
+
+	addrs := rrs[first:]
+	weight := make([]int, 0, len(addrs))
+	total := 0
+	for _, rr := range addrs {
+		x := 0
+		if strings.ToLower(rr.Header().Name) == name {
+			x = defaultWeight
+			if wx, ok := ws[address(rr)]; ok {
+				x = int(wx)
+			}
+		}
+		weight = append(weight, x)
+		total += x
+	}
+	if total == 0 {
+		return rrs
+	}
+
+	n := w.random(total)
+	for i, x := range weight {
+		if n < x {
+			addrs[0], addrs[i] = addrs[i], addrs[0]
+			break
+		}
+		n -= x
+	}
+	return rrs
+}
+
+// get returns the weights of the addresses of name, or nil if there are none.
+func (w *weights) get(ctx context.Context, state request.Request, name string) map[string]uint8 {
+	if w.path != "" {
+		w.RLock()
+		defer w.RUnlock()
+		return w.domains[name]
+	}
+
+	m, err := w.lookup(ctx, state, "_weight."+name, dns.TypeTXT)
+	if err != nil || m == nil {
+		log.Debugf("Failed to look up the weights of %s: %v", name, err)
+		return nil
+	}
+	ws := map[string]uint8{}
+	for _, rr := range m.Answer {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		for _, s := range txt.Txt {
+			addr, weight, err := parseWeight(strings.Fields(s))
+			if err != nil {
+				log.Debugf("Ignoring the weight %q of %s: %s", s, name, err)
+				continue
+			}
+			ws[addr] = weight
+		}
+	}
+	if len(ws) == 0 {
+		return nil
+	}
+	return ws
+}
+
+// address returns the address of an A or AAAA record.
+func address(rr dns.RR) string {
+	switch x := rr.(type) {
+	case *dns.A:
+		return x.A.String()
+	case *dns.AAAA:
+		return x.AAAA.String()
+	}
+	return ""
+}
+
+// read reads the weight file, if it changed since it was last read.
+func (w *weights) read() error {
+	f, err := os.Open(w.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	w.RLock()
+	unchanged := w.mtime.Equal(stat.ModTime()) && w.size == stat.Size()
+	w.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	domains, err := parseWeights(f)
+	if err != nil {
+		return fmt.Errorf("weight file %q: %s", w.path, err)
+	}
+	log.Debugf("Parsed weight file into %d domains", len(domains))
+
+	w.Lock()
+	w.domains = domains
+	w.mtime = stat.ModTime()
+	w.size = stat.Size()
+	w.Unlock()
+	return nil
+}
+
+// periodicUpdate reads the weight file every reload, until the returned channel is closed.
+func (w *weights) periodicUpdate() chan bool {
+	stop := make(chan bool)
+	if w.path == "" || w.reload == 0 {
+		return stop
+	}
+
+	go func() {
+		ticker := time.NewTicker(w.reload)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := w.read(); err != nil {
+					log.Errorf("Failed to reload: %s", err)
+				}
+			}
+		}
+	}()
+	return stop
+}
+
+// parseWeights parses a weight file. A line with a domain name is followed by the lines with the
+// addresses of that name and their weights. Comments start with #.
+func parseWeights(r io.Reader) (map[string]map[string]uint8, error) {
+	domains := map[string]map[string]uint8{}
+	var ws map[string]uint8
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		l := scanner.Text()
+		if i := strings.IndexByte(l, '#'); i >= 0 {
+			l = l[:i]
+		}
+		fields := strings.Fields(l)
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			name := plugin.Name(fields[0]).Normalize()
+			if _, ok := dns.IsDomainName(name); !ok || net.ParseIP(fields[0]) != nil {
+				return nil, fmt.Errorf("invalid domain name %q on line %d", fields[0], line)
+			}
+			if ws = domains[name]; ws == nil {
+				ws = map[string]uint8{}
+				domains[name] = ws
+			}
+		default:
+			if ws == nil {
+				return nil, fmt.Errorf("address without a domain name on line %d", line)
+			}
+			addr, weight, err := parseWeight(fields)
+			if err != nil {
+				return nil, fmt.Errorf("%s on line %d", err, line)
+			}
+			ws[addr] = weight
+		}
+	}
+	return domains, scanner.Err()
+}
+
+// parseWeight parses an address and its weight, from 0 to 255.
+func parseWeight(fields []string) (string, uint8, error) {
+	if len(fields) != 2 {
+		return "", 0, fmt.Errorf("expected an address and a weight")
+	}
+	ip := net.ParseIP(fields[0])
+	if ip == nil {
+		return "", 0, fmt.Errorf("invalid address %q", fields[0])
+	}
+	weight, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid weight %q", fields[1])
+	}
+	return ip.String(), uint8(weight), nil
+}
@@ -0,0 +1,162 @@
+package loadbalance
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+const weightFile = `
+# www has a new server that is warming up
+www.example.org
+192.0.2.1 3
+192.0.2.2 1  # warming up
+192.0.2.3 0
+2001:DB8::1 2
+
+ftp.example.org.
+192.0.2.1 1
+`
+
+func TestParseWeights(t *testing.T) {
+	domains, err := parseWeights(strings.NewReader(weightFile))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(domains) != 2 || len(domains["www.example.org."]) != 4 || len(domains["ftp.example.org."]) != 1 {
+		t.Fatalf("Expected the weights of 2 domains, got %v", domains)
+	}
+	if w := domains["www.example.org."]["2001:db8::1"]; w != 2 {
+		t.Errorf("Expected weight 2 for 2001:db8::1, got %d", w)
+	}
+
+	for _, bad := range []string{
+		"192.0.2.1 1",
+		"www.example.org\n192.0.2.1",
+		"www.example.org\n192.0.2 1",
+		"www.example.org\n192.0.2.1 256",
+		"www.example.org\n192.0.2.1 -1",
+		"www.example.org\n192.0.2.1 1 2",
+		"www..example.org",
+	} {
+		if _, err := parseWeights(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+// firsts returns how often each address is put first when the random numbers go from 0 to total-1.
+func firsts(t *testing.T, w *weights, total int) map[string]int {
+	n := map[string]int{}
+	for i := 0; i < total; i++ {
+		w.random = func(int) int { return i }
+		in := []dns.RR{
+			test.A("www.example.org.	300	IN	A	192.0.2.1"),
+			test.A("www.example.org.	300	IN	A	192.0.2.2"),
+			test.A("www.example.org.	300	IN	A	192.0.2.3"),
+			test.A("www.example.org.	300	IN	A	192.0.2.4"),
+		}
+		r := new(dns.Msg)
+		r.SetQuestion("www.example.org.", dns.TypeA)
+		rrs := w.order(context.TODO(), request.Request{W: &test.ResponseWriter{}, Req: r}, in)
+		if len(rrs) != len(in) {
+			t.Fatalf("Expected %d records, got %d", len(in), len(rrs))
+		}
+		n[address(rrs[0])]++
+	}
+	return n
+}
+
+func TestWeightedOrder(t *testing.T) {
+	w := newWeights("weights")
+	domains, _ := parseWeights(strings.NewReader(weightFile))
+	w.domains = domains
+
+	// 192.0.2.4 isn't in the file, so it has the default weight.
+	n := firsts(t, w, 3+1+0+1)
+	if n["192.0.2.1"] != 3 || n["192.0.2.2"] != 1 || n["192.0.2.3"] != 0 || n["192.0.2.4"] != 1 {
+		t.Errorf("Expected the addresses to be first in proportion to their weights, got %v", n)
+	}
+}
+
+func TestWeightedTXT(t *testing.T) {
+	w := newWeights("")
+	w.lookup = func(ctx context.Context, state request.Request, name string, typ uint16) (*dns.Msg, error) {
+		if name != "_weight.www.example.org." || typ != dns.TypeTXT {
+			return nil, fmt.Errorf("unexpected lookup of %s", name)
+		}
+		m := new(dns.Msg)
+		m.Answer = []dns.RR{
+			test.TXT(`_weight.www.example.org.	300	IN	TXT	"192.0.2.1 0" "192.0.2.2 4"`),
+			test.TXT(`_weight.www.example.org.	300	IN	TXT	"192.0.2.3 1" "bad"`),
+		}
+		return m, nil
+	}
+
+	n := firsts(t, w, 0+4+1+1)
+	if n["192.0.2.1"] != 0 || n["192.0.2.2"] != 4 || n["192.0.2.3"] != 1 || n["192.0.2.4"] != 1 {
+		t.Errorf("Expected the addresses to be first in proportion to their weights, got %v", n)
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	f, rm, err := test.TempFile(".", "www.example.org\n192.0.2.1 0\n10.240.0.1 1\n")
+	if err != nil {
+		t.Fatalf("Failed to create a weight file: %s", err)
+	}
+	defer rm()
+
+	w := newWeights(f)
+	if err := w.read(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	wr := Weighted{Next: plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{
+			test.A("www.example.org.	300	IN	A	192.0.2.1"),
+			test.A("www.example.org.	300	IN	A	10.240.0.1"),
+			test.CNAME("example.org.	300	IN	CNAME	www.example.org."),
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}), weights: w}
+
+	for i := 0; i < 10; i++ {
+		r := new(dns.Msg)
+		r.SetQuestion("example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := wr.ServeDNS(context.TODO(), rec, r); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		if len(rec.Msg.Answer) != 3 {
+			t.Fatalf("Expected 3 records, got %d", len(rec.Msg.Answer))
+		}
+		if _, ok := rec.Msg.Answer[0].(*dns.CNAME); !ok {
+			t.Errorf("Expected the CNAME first, got %s", rec.Msg.Answer[0])
+		}
+		if a := address(rec.Msg.Answer[1]); a != "10.240.0.1" {
+			t.Errorf("Expected the drained 192.0.2.1 never to be first, got %s", a)
+		}
+	}
+
+	// A changed file is read again.
+	if err := ioutil.WriteFile(f, []byte("www.example.org\n192.0.2.1 1\n192.0.2.5 7\n"), 0644); err != nil {
+		t.Fatalf("Failed to write the weight file: %s", err)
+	}
+	if err := w.read(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if ws := w.get(context.TODO(), request.Request{}, "www.example.org."); ws["192.0.2.5"] != 7 {
+		t.Errorf("Expected the new weights, got %v", ws)
+	}
+}
@@ -0,0 +1,236 @@
+// Package lua implements a plugin that runs a Lua script for each query.
+package lua
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	glua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// serveFunc is the name of the function the script must define.
+const serveFunc = "serve"
+
+// queryType is the name of the Lua type of the query handed to serve.
+const queryType = "query"
+
+// Lua is a plugin that calls the serve function from a Lua script for each query.
+type Lua struct {
+	Next  plugin.Handler
+	Zones []string
+
+	proto *glua.FunctionProto
+	pool  sync.Pool
+}
+
+// newLua compiles script, name is used in error messages.
+func newLua(name, script string) (*Lua, error) {
+	chunk, err := parse.Parse(strings.NewReader(script), name)
+	if err != nil {
+		return nil, err
+	}
+	proto, err := glua.Compile(chunk, name)
+	if err != nil {
+		return nil, err
+	}
+	l := &Lua{proto: proto}
+
+	// Check the script runs and defines serve.
+	L, err := l.newState()
+	if err != nil {
+		return nil, err
+	}
+	l.pool.Put(L)
+	return l, nil
+}
+
+// newState returns a new Lua state with the script loaded.
+func (l *Lua) newState() (*glua.LState, error) {
+	L := glua.NewState()
+
+	mt := L.NewTypeMetatable(queryType)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), queryMethods))
+
+	L.Push(L.NewFunctionFromProto(l.proto))
+	if err := L.PCall(0, glua.MultRet, nil); err != nil {
+		L.Close()
+		return nil, err
+	}
+	if _, ok := L.GetGlobal(serveFunc).(*glua.LFunction); !ok {
+		L.Close()
+		return nil, fmt.Errorf("script does not define function %q", serveFunc)
+	}
+	return L, nil
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (l *Lua) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if plugin.Zones(l.Zones).Matches(state.Name()) == "" {
+		return plugin.NextOrFailure(l.Name(), l.Next, ctx, w, r)
+	}
+
+	L, ok := l.pool.Get().(*glua.LState)
+	if !ok {
+		var err error
+		if L, err = l.newState(); err != nil {
+			return dns.RcodeServerFailure, plugin.Error(l.Name(), err)
+		}
+	}
+
+	orig := r.Question[0]
+	q := &query{ctx: ctx, state: state}
+	ud := L.NewUserData()
+	ud.Value = q
+	L.SetMetatable(ud, L.GetTypeMetatable(queryType))
+
+	L.SetContext(ctx)
+	err := L.CallByParam(glua.P{Fn: L.GetGlobal(serveFunc), NRet: 0, Protect: true}, ud)
+	L.RemoveContext()
+	if err != nil {
+		// The state may be left in an inconsistent state, don't reuse it.
+		L.Close()
+		r.Question[0] = orig
+		return dns.RcodeServerFailure, plugin.Error(l.Name(), err)
+	}
+	l.pool.Put(L)
+
+	if r.Question[0] != orig {
+		w = &ResponseReverter{ResponseWriter: w, original: orig, rewritten: r.Question[0].Name}
+	}
+	if q.reply != nil {
+		w.WriteMsg(q.reply)
+		return dns.RcodeSuccess, nil
+	}
+	return plugin.NextOrFailure(l.Name(), l.Next, ctx, w, r)
+}
+
+// Name implements the Handler interface.
+func (l *Lua) Name() string { return "lua" }
+
+// query is the value handed to the serve function.
+type query struct {
+	ctx   context.Context
+	state request.Request
+	reply *dns.Msg // reply set by respond
+}
+
+var queryMethods = map[string]glua.LGFunction{
+	"name":     func(L *glua.LState) int { return pushString(L, checkQuery(L).state.Name()) },
+	"type":     func(L *glua.LState) int { return pushString(L, checkQuery(L).state.Type()) },
+	"class":    func(L *glua.LState) int { return pushString(L, checkQuery(L).state.Class()) },
+	"ip":       func(L *glua.LState) int { return pushString(L, checkQuery(L).state.IP()) },
+	"port":     func(L *glua.LState) int { return pushString(L, checkQuery(L).state.Port()) },
+	"proto":    func(L *glua.LState) int { return pushString(L, checkQuery(L).state.Proto()) },
+	"do":       func(L *glua.LState) int { L.Push(glua.LBool(checkQuery(L).state.Do())); return 1 },
+	"size":     func(L *glua.LState) int { L.Push(glua.LNumber(checkQuery(L).state.Size())); return 1 },
+	"meta":     queryMeta,
+	"set_name": querySetName,
+	"set_type": querySetType,
+	"respond":  queryRespond,
+}
+
+func pushString(L *glua.LState, s string) int {
+	L.Push(glua.LString(s))
+	return 1
+}
+
+func checkQuery(L *glua.LState) *query {
+	ud := L.CheckUserData(1)
+	q, ok := ud.Value.(*query)
+	if !ok {
+		L.ArgError(1, "query expected")
+	}
+	return q
+}
+
+// queryMeta returns the value of a metadata label, or nil if it does not exist.
+func queryMeta(L *glua.LState) int {
+	q := checkQuery(L)
+	f := metadata.ValueFunc(q.ctx, L.CheckString(2))
+	if f == nil {
+		L.Push(glua.LNil)
+		return 1
+	}
+	return pushString(L, f())
+}
+
+// querySetName rewrites the query name.
+func querySetName(L *glua.LState) int {
+	q := checkQuery(L)
+	name := dns.Fqdn(strings.ToLower(L.CheckString(2)))
+	if _, ok := dns.IsDomainName(name); !ok {
+		L.ArgError(2, "invalid domain name")
+	}
+	q.state.Req.Question[0].Name = name
+	q.state.Clear()
+	return 0
+}
+
+// querySetType rewrites the query type.
+func querySetType(L *glua.LState) int {
+	q := checkQuery(L)
+	qtype, ok := dns.StringToType[strings.ToUpper(L.CheckString(2))]
+	if !ok {
+		L.ArgError(2, "unknown type")
+	}
+	q.state.Req.Question[0].Qtype = qtype
+	q.state.Clear()
+	return 0
+}
+
+// queryRespond sets the reply with the given rcode and answer records, the reply is written once serve
+// returns.
+func queryRespond(L *glua.LState) int {
+	q := checkQuery(L)
+	rcode, ok := dns.StringToRcode[strings.ToUpper(L.CheckString(2))]
+	if !ok {
+		L.ArgError(2, "unknown rcode")
+	}
+
+	m := new(dns.Msg)
+	m.SetRcode(q.state.Req, rcode)
+	m.Authoritative = true
+	for i := 3; i <= L.GetTop(); i++ {
+		rr, err := dns.NewRR(L.CheckString(i))
+		if err != nil {
+			L.ArgError(i, err.Error())
+		}
+		m.Answer = append(m.Answer, rr)
+	}
+	q.reply = m
+	return 0
+}
+
+// ResponseReverter restores the original question in the response and renames the records owned by
+// the rewritten name back to the original name.
+type ResponseReverter struct {
+	dns.ResponseWriter
+	original  dns.Question
+	rewritten string
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (r *ResponseReverter) WriteMsg(res *dns.Msg) error {
+	res.Question[0] = r.original
+	for _, rr := range res.Answer {
+		if strings.EqualFold(rr.Header().Name, r.rewritten) {
+			rr.Header().Name = r.original.Name
+		}
+	}
+	return r.ResponseWriter.WriteMsg(res)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (r *ResponseReverter) Write(buf []byte) (int, error) {
+	log.Warning("ResponseReverter called with Write, not reverting the response")
+	return r.ResponseWriter.Write(buf)
+}
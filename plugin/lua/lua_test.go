@@ -0,0 +1,156 @@
+package lua
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+const script = `
+function serve(q)
+  if q:name() == "blocked.example.org." then
+    q:respond("NXDOMAIN")
+  elseif q:name() == "static.example.org." and q:type() == "A" then
+    q:respond("NOERROR", q:name() .. " 300 IN A 192.0.2.1", q:name() .. " 300 IN A 192.0.2.2")
+  elseif q:name() == "alias.example.org." then
+    q:set_name("www.example.org.")
+  elseif q:meta("test/tenant") == "a" then
+    q:respond("REFUSED")
+  elseif q:name() == "error.example.org." then
+    error("boom")
+  end
+end
+`
+
+type testProvider map[string]metadata.Func
+
+func (tp testProvider) Metadata(ctx context.Context, state request.Request) context.Context {
+	for k, v := range tp {
+		metadata.SetValueFunc(ctx, k, v)
+	}
+	return ctx
+}
+
+func TestLua(t *testing.T) {
+	l, err := newLua("test.lua", script)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	l.Zones = []string{"example.org."}
+	// The next handler answers with the name it got asked.
+	l.Next = test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A(r.Question[0].Name + " 300 IN A 192.0.2.53")}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+
+	tests := []struct {
+		qname     string
+		tenant    string
+		rcode     int
+		answers   int
+		answer    string
+		shouldErr bool
+	}{
+		{"blocked.example.org.", "", dns.RcodeNameError, 0, "", false},
+		{"static.example.org.", "", dns.RcodeSuccess, 2, "static.example.org.", false},
+		{"alias.example.org.", "", dns.RcodeSuccess, 1, "alias.example.org.", false},
+		{"other.example.org.", "a", dns.RcodeRefused, 0, "", false},
+		{"other.example.org.", "", dns.RcodeSuccess, 1, "other.example.org.", false},
+		{"error.example.org.", "", dns.RcodeServerFailure, 0, "", true},
+		{"example.net.", "a", dns.RcodeSuccess, 1, "example.net.", false}, // not in zone
+	}
+
+	for i, tc := range tests {
+		ctx := context.TODO()
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if tc.tenant != "" {
+			tp := testProvider{"test/tenant": func() string { return tc.tenant }}
+			ctx = metadata.ContextWithMetadata(ctx)
+			ctx = tp.Metadata(ctx, request.Request{W: rec, Req: m})
+		}
+
+		rcode, err := l.ServeDNS(ctx, rec, m)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got %s", i, err)
+			continue
+		}
+		if !plugin.ClientWrite(rcode) || rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: Expected rcode %d written to the client, got %d (returned %d)", i, tc.rcode, rec.Msg.Rcode, rcode)
+		}
+		if rec.Msg.Question[0].Name != tc.qname {
+			t.Errorf("Test %d: Expected question %s, got %s", i, tc.qname, rec.Msg.Question[0].Name)
+		}
+		if len(rec.Msg.Answer) != tc.answers {
+			t.Errorf("Test %d: Expected %d answers, got %d", i, tc.answers, len(rec.Msg.Answer))
+			continue
+		}
+		if tc.answers > 0 && rec.Msg.Answer[0].Header().Name != tc.answer {
+			t.Errorf("Test %d: Expected answer for %s, got %s", i, tc.answer, rec.Msg.Answer[0].Header().Name)
+		}
+	}
+}
+
+func TestLuaRespondServerFailure(t *testing.T) {
+	l, err := newLua("test.lua", `function serve(q) q:respond("SERVFAIL") end`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	l.Zones = []string{"."}
+	l.Next = test.ErrorHandler()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	w := &writeCounter{ResponseWriter: &test.ResponseWriter{}}
+	rcode, err := l.ServeDNS(context.TODO(), w, m)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	// The server writes a response of its own for the rcodes not written to the client.
+	if !plugin.ClientWrite(rcode) {
+		w.writes++
+	}
+	if w.writes != 1 {
+		t.Errorf("Expected the response to be written once, got %d writes", w.writes)
+	}
+}
+
+// writeCounter counts the messages written to it.
+type writeCounter struct {
+	dns.ResponseWriter
+	writes int
+}
+
+func (w *writeCounter) WriteMsg(m *dns.Msg) error {
+	w.writes++
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+func TestNewLuaInvalid(t *testing.T) {
+	for i, s := range []string{
+		`function serve(q`,       // syntax error
+		`function other(q) end`,  // no serve
+		`error("fails on load")`, // runtime error
+	} {
+		if _, err := newLua("test.lua", s); err == nil {
+			t.Errorf("Test %d: Expected error, got none", i)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package lua
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/caddyserver/caddy"
+)
+
+var log = clog.NewWithPlugin("lua")
+
+func init() {
+	caddy.RegisterPlugin("lua", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	l, err := luaParse(c)
+	if err != nil {
+		return plugin.Error("lua", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		l.Next = next
+		return l
+	})
+
+	return nil
+}
+
+func luaParse(c *caddy.Controller) (*Lua, error) {
+	config := dnsserver.GetConfig(c)
+
+	i := 0
+	var l *Lua
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return nil, c.ArgErr()
+		}
+		file := args[0]
+		if !filepath.IsAbs(file) && config.Root != "" {
+			file = filepath.Join(config.Root, file)
+		}
+		zones := make([]string, len(c.ServerBlockKeys))
+		copy(zones, c.ServerBlockKeys)
+		if len(args) > 1 {
+			zones = args[1:]
+		}
+		for i := range zones {
+			zones[i] = plugin.Host(zones[i]).Normalize()
+		}
+		if c.NextBlock() {
+			return nil, c.Errf("unknown property '%s'", c.Val())
+		}
+
+		script, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		l, err = newLua(file, string(script))
+		if err != nil {
+			return nil, err
+		}
+		l.Zones = zones
+	}
+	return l, nil
+}
@@ -0,0 +1,56 @@
+package lua
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestLuaParse(t *testing.T) {
+	file, rm, err := test.TempFile(".", script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+		zones     []string
+	}{
+		{`lua ` + file, false, []string{"."}},
+		{`lua ` + file + ` example.org example.net`, false, []string{"example.org.", "example.net."}},
+		// fails
+		{`lua`, true, nil},
+		{`lua /does/not/exist.lua`, true, nil},
+		{`lua ` + file + ` {
+			unknown
+		}`, true, nil},
+		{"lua " + file + "\nlua " + file, true, nil},
+	}
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		c.ServerBlockKeys = []string{"."}
+		l, err := luaParse(c)
+		if tc.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		} else if !tc.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if tc.shouldErr {
+			continue
+		}
+		if len(l.Zones) != len(tc.zones) {
+			t.Fatalf("Test %d: Expected zones %v, got %v", i, tc.zones, l.Zones)
+		}
+		for j := range tc.zones {
+			if l.Zones[j] != tc.zones[j] {
+				t.Errorf("Test %d: Expected zone %s, got %s", i, tc.zones[j], l.Zones[j])
+			}
+		}
+	}
+}
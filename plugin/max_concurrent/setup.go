@@ -0,0 +1,66 @@
+// Package maxconcurrent implements a plugin that caps the queries a server answers at once.
+package maxconcurrent
+
+import (
+	"strconv"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("max_concurrent", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	max, drop, err := parse(c)
+	if err != nil {
+		return plugin.Error("max_concurrent", err)
+	}
+	config := dnsserver.GetConfig(c)
+	config.MaxConcurrent = max
+	config.ConcurrencyDrop = drop
+	return nil
+}
+
+func parse(c *caddy.Controller) (int, bool, error) {
+	var (
+		max  int
+		drop bool
+	)
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return 0, false, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) < 1 || len(args) > 2 {
+			return 0, false, c.ArgErr()
+		}
+		var err error
+		max, err = strconv.Atoi(args[0])
+		if err != nil {
+			return 0, false, c.Errf("invalid limit '%s'", args[0])
+		}
+		if max <= 0 {
+			return 0, false, c.Errf("limit must be positive: %d", max)
+		}
+		if len(args) == 2 {
+			switch args[1] {
+			case "refuse":
+			case "drop":
+				drop = true
+			default:
+				return 0, false, c.Errf("unknown action '%s'", args[1])
+			}
+		}
+	}
+	return max, drop, nil
+}
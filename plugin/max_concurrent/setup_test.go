@@ -0,0 +1,48 @@
+package maxconcurrent
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		max       int
+		drop      bool
+	}{
+		{`max_concurrent 1000`, false, 1000, false},
+		{`max_concurrent 1000 refuse`, false, 1000, false},
+		{`max_concurrent 1000 drop`, false, 1000, true},
+		{`max_concurrent`, true, 0, false},
+		{`max_concurrent 0`, true, 0, false},
+		{`max_concurrent -1`, true, 0, false},
+		{`max_concurrent many`, true, 0, false},
+		{`max_concurrent 1000 servfail`, true, 0, false},
+		{`max_concurrent 1000 drop refuse`, true, 0, false},
+		{"max_concurrent 1000\nmax_concurrent 2000", true, 0, false},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		config := dnsserver.GetConfig(c)
+		if config.MaxConcurrent != tc.max || config.ConcurrencyDrop != tc.drop {
+			t.Errorf("Test %d: expected %d and drop %t, got %d and %t", i, tc.max, tc.drop, config.MaxConcurrent, config.ConcurrencyDrop)
+		}
+	}
+}
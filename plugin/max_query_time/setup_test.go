@@ -0,0 +1,45 @@
+package maxquerytime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  time.Duration
+	}{
+		{`max_query_time 2s`, false, 2 * time.Second},
+		{`max_query_time 500ms`, false, 500 * time.Millisecond},
+		{`max_query_time`, true, 0},
+		{`max_query_time 2s 20s`, true, 0},
+		{`max_query_time 0s`, true, 0},
+		{`max_query_time -5s`, true, 0},
+		{`max_query_time 10`, true, 0},
+		{"max_query_time 2s\nmax_query_time 20s", true, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if d := dnsserver.GetConfig(c).MaxQueryTime; d != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, d)
+		}
+	}
+}
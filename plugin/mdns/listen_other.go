@@ -0,0 +1,9 @@
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package mdns
+
+import "net"
+
+func listenPacket(network, addr string) (net.PacketConn, error) {
+	return net.ListenPacket(network, addr)
+}
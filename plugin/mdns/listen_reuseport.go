@@ -0,0 +1,29 @@
+// +build aix darwin dragonfly freebsd linux netbsd openbsd
+
+package mdns
+
+import (
+	"context"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reuseControl allows other multicast DNS responders on the host to bind to the same port.
+func reuseControl(network, address string, c syscall.RawConn) error {
+	c.Control(func(fd uintptr) {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+			log.Warningf("Failed to set SO_REUSEADDR on socket: %s", err)
+		}
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1); err != nil {
+			log.Warningf("Failed to set SO_REUSEPORT on socket: %s", err)
+		}
+	})
+	return nil
+}
+
+func listenPacket(network, addr string) (net.PacketConn, error) {
+	lc := net.ListenConfig{Control: reuseControl}
+	return lc.ListenPacket(context.Background(), network, addr)
+}
@@ -0,0 +1,276 @@
+// Package mdns implements a plugin that answers multicast DNS queries.
+package mdns
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// MDNS answers multicast DNS queries (RFC 6762) for host names and DNS-SD services (RFC 6763) on
+// a set of interfaces, and optionally reflects the multicast DNS traffic between them.
+type MDNS struct {
+	// Interfaces holds the names of the interfaces to answer on, all multicast interfaces when empty.
+	Interfaces []string
+	Hosts      []Host
+	Services   []Service
+	// Reflect makes the queries and responses received on one interface be sent on the others too.
+	Reflect bool
+
+	mu sync.Mutex
+	r  *responder // running responder, nil when stopped
+}
+
+// OnStartup starts answering queries.
+func (m *MDNS) OnStartup() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.r != nil {
+		return nil
+	}
+	r, err := newResponder(m)
+	if err != nil {
+		return err
+	}
+	r.start()
+	m.r = r
+	return nil
+}
+
+// OnShutdown stops answering queries.
+func (m *MDNS) OnShutdown() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.r != nil {
+		m.r.stop()
+		m.r = nil
+	}
+	return nil
+}
+
+// Host is a host name with its addresses.
+type Host struct {
+	Name  string   // fully qualified, in the local. domain
+	Addrs []net.IP // the addresses of the interface the query is received on when empty
+}
+
+// Service is a DNS-SD service instance.
+type Service struct {
+	Instance string // instance name, it may hold any character, e.g. "Printer 2.floor"
+	Type     string // service type, e.g. _ipp._tcp
+	Port     uint16
+	Host     string // fully qualified name of the host providing the service
+	TXT      []string
+}
+
+// The TTLs recommended by RFC 6762, section 10: records with a host name, or that depend on one,
+// get a short TTL, because the host may go away.
+const (
+	hostTTL  = 120
+	otherTTL = 4500
+
+	// legacyTTL is the highest TTL in responses to legacy unicast queries, see RFC 6762, section 6.7.
+	legacyTTL = 10
+
+	// servicesName is the name listing the service types, see RFC 6763, section 9.
+	servicesName = "_services._dns-sd._udp.local."
+)
+
+// cacheFlush is the bit of the class of a record that tells the receiver to replace the records of
+// the same name and type in its cache. It is set for the records that only this host has.
+const cacheFlush = 1 << 15
+
+// unicastResponse is the bit of the class of a question that asks for a unicast response.
+const unicastResponse = 1 << 15
+
+// records returns the records of m for an interface with the addresses addrs.
+func (m *MDNS) records(addrs []net.IP) []dns.RR {
+	var rrs []dns.RR
+	for _, h := range m.Hosts {
+		a := h.Addrs
+		if len(a) == 0 {
+			a = addrs
+		}
+		for _, ip := range a {
+			if ip4 := ip.To4(); ip4 != nil {
+				rrs = append(rrs, &dns.A{Hdr: header(h.Name, dns.TypeA, hostTTL), A: ip4})
+				continue
+			}
+			rrs = append(rrs, &dns.AAAA{Hdr: header(h.Name, dns.TypeAAAA, hostTTL), AAAA: ip})
+		}
+	}
+
+	types := map[string]bool{}
+	for _, s := range m.Services {
+		typ := s.Type + ".local."
+		name := escape(s.Instance) + "." + typ
+		if !types[typ] {
+			types[typ] = true
+			rrs = append(rrs, &dns.PTR{Hdr: header(servicesName, dns.TypePTR, otherTTL), Ptr: typ})
+		}
+		txt := s.TXT
+		if len(txt) == 0 {
+			txt = []string{""} // RFC 6763, section 6.1
+		}
+		rrs = append(rrs,
+			&dns.PTR{Hdr: header(typ, dns.TypePTR, otherTTL), Ptr: name},
+			&dns.SRV{Hdr: header(name, dns.TypeSRV, hostTTL), Port: s.Port, Target: s.Host},
+			&dns.TXT{Hdr: header(name, dns.TypeTXT, otherTTL), Txt: txt},
+		)
+	}
+	return rrs
+}
+
+func header(name string, typ uint16, ttl uint32) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: typ, Class: dns.ClassINET, Ttl: ttl}
+}
+
+// escape escapes the special characters in the label l, the way dns.Msg.Unpack does.
+func escape(l string) string {
+	var b strings.Builder
+	for i := 0; i < len(l); i++ {
+		switch c := l[i]; {
+		case c == '.' || c == '(' || c == ')' || c == ';' || c == ' ' || c == '@' || c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			b.WriteByte('\\')
+			b.WriteByte('0' + c/100)
+			b.WriteByte('0' + c/10%10)
+			b.WriteByte('0' + c%10)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// shared returns true if rr may be held by several hosts, and must not have the cache flush bit.
+func shared(rr dns.RR) bool { return rr.Header().Rrtype == dns.TypePTR }
+
+// response returns the response to the query req with the records in rrs, or nil when there is
+// nothing to answer. When legacy is true the query is a legacy unicast query: one sent from another
+// port than 5353 by a client that isn't a full multicast DNS querier. The response must be sent to
+// the querier only when legacy or unicast is true, unicast is true when all questions ask for a
+// unicast response.
+func response(req *dns.Msg, rrs []dns.RR, legacy bool) (res *dns.Msg, unicast bool) {
+	unicast = true
+	var answer []dns.RR
+	for _, q := range req.Question {
+		if q.Qclass&unicastResponse == 0 {
+			unicast = false
+		}
+		for _, rr := range rrs {
+			if match(q, rr) && !known(req.Answer, rr) && !contains(answer, rr) {
+				answer = append(answer, rr)
+			}
+		}
+	}
+	if len(answer) == 0 {
+		return nil, false
+	}
+
+	var extra []dns.RR
+	add := func(name string, types ...uint16) {
+		for _, rr := range rrs {
+			for _, t := range types {
+				if rr.Header().Rrtype == t && strings.EqualFold(rr.Header().Name, name) && !contains(answer, rr) && !contains(extra, rr) {
+					extra = append(extra, rr)
+				}
+			}
+		}
+	}
+	// RFC 6763, section 12: add the records the querier will need next, for the answers and for
+	// the records added.
+	follow := func(rr dns.RR) {
+		switch rr := rr.(type) {
+		case *dns.PTR:
+			add(rr.Ptr, dns.TypeSRV, dns.TypeTXT)
+		case *dns.SRV:
+			add(rr.Target, dns.TypeA, dns.TypeAAAA)
+		}
+	}
+	for _, rr := range answer {
+		follow(rr)
+	}
+	for i := 0; i < len(extra); i++ {
+		follow(extra[i])
+	}
+
+	res = new(dns.Msg)
+	res.Response = true
+	res.Authoritative = true
+	if legacy {
+		res.Id = req.Id
+		res.Question = req.Question
+	}
+	res.Answer = prepare(answer, legacy)
+	res.Extra = prepare(extra, legacy)
+	return res, unicast
+}
+
+// match returns true if rr answers the question q.
+func match(q dns.Question, rr dns.RR) bool {
+	h := rr.Header()
+	if q.Qclass&^unicastResponse != dns.ClassINET && q.Qclass&^unicastResponse != dns.ClassANY {
+		return false
+	}
+	return (q.Qtype == h.Rrtype || q.Qtype == dns.TypeANY) && strings.EqualFold(q.Name, h.Name)
+}
+
+// known returns true if rr is in the known answers of a query with at least half its TTL, the
+// querier already has it, see RFC 6762, section 7.1.
+func known(answers []dns.RR, rr dns.RR) bool {
+	for _, a := range answers {
+		if a.Header().Ttl >= rr.Header().Ttl/2 && dns.IsDuplicate(a, rr) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(rrs []dns.RR, rr dns.RR) bool {
+	for _, r := range rrs {
+		if r == rr {
+			return true
+		}
+	}
+	return false
+}
+
+// prepare returns copies of rrs to send: with the cache flush bit for the records that aren't
+// shared, or with a short TTL in responses to legacy unicast queries.
+func prepare(rrs []dns.RR, legacy bool) []dns.RR {
+	out := make([]dns.RR, len(rrs))
+	for i, rr := range rrs {
+		rr = dns.Copy(rr)
+		h := rr.Header()
+		switch {
+		case legacy:
+			if h.Ttl > legacyTTL {
+				h.Ttl = legacyTTL
+			}
+		case !shared(rr):
+			h.Class |= cacheFlush
+		}
+		out[i] = rr
+	}
+	return out
+}
+
+// announcement returns the unsolicited response announcing the records in rrs. When goodbye is
+// true the records have TTL 0, which says they are going away.
+func announcement(rrs []dns.RR, goodbye bool) *dns.Msg {
+	res := new(dns.Msg)
+	res.Response = true
+	res.Authoritative = true
+	res.Answer = prepare(rrs, false)
+	if goodbye {
+		for _, rr := range res.Answer {
+			rr.Header().Ttl = 0
+		}
+	}
+	return res
+}
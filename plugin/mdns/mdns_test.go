@@ -0,0 +1,183 @@
+package mdns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+var testMDNS = &MDNS{
+	Hosts: []Host{
+		{Name: "printer.local.", Addrs: []net.IP{net.ParseIP("192.168.1.10")}},
+		{Name: "nas.local."},
+	},
+	Services: []Service{
+		{Instance: "Printer 2.floor", Type: "_ipp._tcp", Port: 631, Host: "printer.local."},
+		{Instance: "Photos", Type: "_http._tcp", Port: 8080, Host: "nas.local.", TXT: []string{"path=/photos"}},
+	},
+}
+
+var testAddrs = []net.IP{net.ParseIP("192.168.1.20"), net.ParseIP("fe80::20")}
+
+func query(name string, qtype uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.Id = 0
+	m.RecursionDesired = false
+	return m
+}
+
+func TestResponse(t *testing.T) {
+	rrs := testMDNS.records(testAddrs)
+
+	tests := []struct {
+		req      *dns.Msg
+		legacy   bool
+		answer   []dns.RR
+		extra    []dns.RR
+		unicast  bool
+		noAnswer bool
+	}{
+		{
+			req:    query("printer.local.", dns.TypeA),
+			answer: []dns.RR{test.A("printer.local. 120 IN A 192.168.1.10")},
+		},
+		{
+			req:    query("NAS.local.", dns.TypeANY),
+			answer: []dns.RR{test.A("NAS.local. 120 IN A 192.168.1.20"), test.AAAA("nas.local. 120 IN AAAA fe80::20")},
+		},
+		{
+			req:    query("_ipp._tcp.local.", dns.TypePTR),
+			answer: []dns.RR{test.PTR(`_ipp._tcp.local. 4500 IN PTR Printer\ 2\.floor._ipp._tcp.local.`)},
+			extra: []dns.RR{
+				test.SRV(`Printer\ 2\.floor._ipp._tcp.local. 120 IN SRV 0 0 631 printer.local.`),
+				test.TXT(`Printer\ 2\.floor._ipp._tcp.local. 4500 IN TXT ""`),
+				test.A("printer.local. 120 IN A 192.168.1.10"),
+			},
+		},
+		{
+			req: query("_services._dns-sd._udp.local.", dns.TypePTR),
+			answer: []dns.RR{
+				test.PTR("_services._dns-sd._udp.local. 4500 IN PTR _ipp._tcp.local."),
+				test.PTR("_services._dns-sd._udp.local. 4500 IN PTR _http._tcp.local."),
+			},
+		},
+		{
+			req:      query("other.local.", dns.TypeA),
+			noAnswer: true,
+		},
+		{
+			// Known answer.
+			req: func() *dns.Msg {
+				m := query("printer.local.", dns.TypeA)
+				m.Answer = []dns.RR{test.A("printer.local. 100 IN A 192.168.1.10")}
+				return m
+			}(),
+			noAnswer: true,
+		},
+		{
+			// Known answer that is about to expire.
+			req: func() *dns.Msg {
+				m := query("printer.local.", dns.TypeA)
+				m.Answer = []dns.RR{test.A("printer.local. 30 IN A 192.168.1.10")}
+				return m
+			}(),
+			answer: []dns.RR{test.A("printer.local. 120 IN A 192.168.1.10")},
+		},
+		{
+			req: func() *dns.Msg {
+				m := query("printer.local.", dns.TypeA)
+				m.Question[0].Qclass |= unicastResponse
+				return m
+			}(),
+			answer:  []dns.RR{test.A("printer.local. 120 IN A 192.168.1.10")},
+			unicast: true,
+		},
+		{
+			req:    query("printer.local.", dns.TypeA),
+			legacy: true,
+			answer: []dns.RR{test.A("printer.local. 10 IN A 192.168.1.10")},
+		},
+	}
+
+	for i, tc := range tests {
+		res, unicast := response(tc.req, rrs, tc.legacy)
+		if tc.noAnswer {
+			if res != nil {
+				t.Errorf("Test %d: expected no response, got %v", i, res)
+			}
+			continue
+		}
+		if res == nil {
+			t.Errorf("Test %d: expected a response, got none", i)
+			continue
+		}
+		if unicast != tc.unicast {
+			t.Errorf("Test %d: expected unicast %t, got %t", i, tc.unicast, unicast)
+		}
+		if !res.Response || !res.Authoritative {
+			t.Errorf("Test %d: expected an authoritative response", i)
+		}
+		if tc.legacy && (res.Id != tc.req.Id || len(res.Question) != 1) {
+			t.Errorf("Test %d: expected the ID and question of the query in a legacy response", i)
+		}
+		if !tc.legacy && len(res.Question) != 0 {
+			t.Errorf("Test %d: expected no question in a multicast response", i)
+		}
+		checkSection(t, i, "answer", res.Answer, tc.answer, tc.legacy)
+		checkSection(t, i, "additional", res.Extra, tc.extra, tc.legacy)
+	}
+}
+
+func checkSection(t *testing.T, i int, section string, got, expected []dns.RR, legacy bool) {
+	if len(got) != len(expected) {
+		t.Errorf("Test %d: expected %d records in the %s section, got %v", i, len(expected), section, got)
+		return
+	}
+	for j, rr := range got {
+		class := rr.Header().Class
+		flush := class&cacheFlush != 0
+		if want := !legacy && !shared(rr); flush != want {
+			t.Errorf("Test %d: expected cache flush %t for %s, got %t", i, want, rr, flush)
+		}
+		rr.Header().Class &^= cacheFlush
+		if !dns.IsDuplicate(rr, expected[j]) || rr.Header().Ttl != expected[j].Header().Ttl {
+			t.Errorf("Test %d: expected %s, got %s", i, expected[j], rr)
+		}
+	}
+}
+
+func TestAnnouncement(t *testing.T) {
+	rrs := testMDNS.records(testAddrs)
+	for _, goodbye := range []bool{false, true} {
+		m := announcement(rrs, goodbye)
+		if len(m.Answer) != len(rrs) {
+			t.Fatalf("Expected %d records, got %d", len(rrs), len(m.Answer))
+		}
+		for _, rr := range m.Answer {
+			if goodbye && rr.Header().Ttl != 0 || !goodbye && rr.Header().Ttl == 0 {
+				t.Errorf("Expected goodbye %t, got %s", goodbye, rr)
+			}
+		}
+	}
+	if rrs[0].Header().Class != dns.ClassINET {
+		t.Errorf("Expected the records not to be modified")
+	}
+}
+
+func TestLocalName(t *testing.T) {
+	tests := map[string]string{
+		"printer":        "printer.local.",
+		"printer.local":  "printer.local.",
+		"printer.local.": "printer.local.",
+		"a.b":            "a.b.local.",
+	}
+	for name, expected := range tests {
+		if got := localName(name); got != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, name, got)
+		}
+	}
+}
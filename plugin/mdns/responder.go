@@ -0,0 +1,322 @@
+package mdns
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// port is the multicast DNS port, a variable for testing.
+var port = 5353
+
+var (
+	groupV4 = net.IPv4(224, 0, 0, 251)
+	groupV6 = net.ParseIP("ff02::fb")
+)
+
+// packetConn is a socket joined to the multicast DNS group of an address family.
+type packetConn interface {
+	// read reads a packet, and returns the index of the interface it was received on and its
+	// source, which is nil when it isn't an UDP address.
+	read(b []byte) (n, ifIndex int, src *net.UDPAddr, err error)
+	// write sends b to dst, from the interface ifi when dst is the multicast group.
+	write(b []byte, ifi *net.Interface, dst *net.UDPAddr) error
+	// group returns the address of the multicast group.
+	group() *net.UDPAddr
+	Close() error
+}
+
+// responder answers the queries received on a set of interfaces.
+type responder struct {
+	m      *MDNS
+	ifaces []net.Interface
+	conns  []packetConn
+	wg     sync.WaitGroup
+}
+
+// newResponder returns a responder for m, listening on the interfaces of m.
+func newResponder(m *MDNS) (*responder, error) {
+	ifaces, err := interfaces(m.Interfaces)
+	if err != nil {
+		return nil, err
+	}
+	r := &responder{m: m, ifaces: ifaces}
+
+	c4, err := listenV4(ifaces)
+	if err != nil {
+		return nil, err
+	}
+	r.conns = append(r.conns, c4)
+	if c6, err := listenV6(ifaces); err != nil {
+		log.Warningf("Not answering over IPv6: %s", err)
+	} else {
+		r.conns = append(r.conns, c6)
+	}
+	return r, nil
+}
+
+// interfaces returns the interfaces with the names in names, or all the multicast interfaces that
+// are up, except loopback ones, when names is empty.
+func interfaces(names []string) ([]net.Interface, error) {
+	if len(names) > 0 {
+		ifaces := make([]net.Interface, len(names))
+		for i, name := range names {
+			ifi, err := net.InterfaceByName(name)
+			if err != nil {
+				return nil, fmt.Errorf("interface %s: %s", name, err)
+			}
+			ifaces[i] = *ifi
+		}
+		return ifaces, nil
+	}
+
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var ifaces []net.Interface
+	for _, ifi := range all {
+		if ifi.Flags&net.FlagUp != 0 && ifi.Flags&net.FlagMulticast != 0 && ifi.Flags&net.FlagLoopback == 0 {
+			ifaces = append(ifaces, ifi)
+		}
+	}
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("no multicast interfaces")
+	}
+	return ifaces, nil
+}
+
+// start starts answering queries and announces the records.
+func (r *responder) start() {
+	for _, c := range r.conns {
+		r.wg.Add(1)
+		go r.serve(c)
+	}
+	r.announce(false)
+}
+
+// stop says goodbye, stops answering queries and closes the sockets.
+func (r *responder) stop() {
+	r.announce(true)
+	for _, c := range r.conns {
+		c.Close()
+	}
+	r.wg.Wait()
+}
+
+func (r *responder) serve(c packetConn) {
+	defer r.wg.Done()
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		n, ifIndex, src, err := c.read(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				continue
+			}
+			return
+		}
+		if ifi := r.iface(ifIndex); ifi != nil && src != nil {
+			r.handle(c, ifi, buf[:n], src)
+		}
+	}
+}
+
+// iface returns the interface with index i, or nil when it isn't one of the responder's interfaces.
+func (r *responder) iface(i int) *net.Interface {
+	for j := range r.ifaces {
+		if r.ifaces[j].Index == i {
+			return &r.ifaces[j]
+		}
+	}
+	return nil
+}
+
+// handle handles the packet b received on the interface ifi from src.
+func (r *responder) handle(c packetConn, ifi *net.Interface, b []byte, src *net.UDPAddr) {
+	req := new(dns.Msg)
+	if err := req.Unpack(b); err != nil {
+		return
+	}
+
+	// Legacy unicast queries are not reflected, their responses would come back to us.
+	legacy := src.Port != port
+	if r.m.Reflect && !legacy {
+		for i := range r.ifaces {
+			if r.ifaces[i].Index != ifi.Index {
+				c.write(b, &r.ifaces[i], c.group())
+			}
+		}
+	}
+
+	if req.Response || req.Opcode != dns.OpcodeQuery || len(req.Question) == 0 {
+		return
+	}
+	res, unicast := response(req, r.m.records(addrs(ifi)), legacy)
+	if res == nil {
+		return
+	}
+	out, err := res.Pack()
+	if err != nil {
+		log.Errorf("Failed to pack the response to %s: %s", src, err)
+		return
+	}
+	dst := c.group()
+	if legacy || unicast {
+		dst = src
+	}
+	if err := c.write(out, ifi, dst); err != nil {
+		log.Debugf("Failed to send the response to %s on %s: %s", src, ifi.Name, err)
+	}
+}
+
+// announce sends all records on all interfaces, or says goodbye when goodbye is true.
+func (r *responder) announce(goodbye bool) {
+	for i := range r.ifaces {
+		ifi := &r.ifaces[i]
+		rrs := r.m.records(addrs(ifi))
+		if len(rrs) == 0 {
+			continue
+		}
+		out, err := announcement(rrs, goodbye).Pack()
+		if err != nil {
+			log.Errorf("Failed to pack the announcement: %s", err)
+			return
+		}
+		for _, c := range r.conns {
+			c.write(out, ifi, c.group())
+		}
+	}
+}
+
+// addrs returns the addresses of the interface ifi.
+func addrs(ifi *net.Interface) []net.IP {
+	as, err := ifi.Addrs()
+	if err != nil {
+		return nil
+	}
+	ips := make([]net.IP, 0, len(as))
+	for _, a := range as {
+		if ipnet, ok := a.(*net.IPNet); ok {
+			ips = append(ips, ipnet.IP)
+		}
+	}
+	return ips
+}
+
+// v4Conn is the packetConn for IPv4.
+type v4Conn struct {
+	*ipv4.PacketConn
+	mu sync.Mutex // serializes setting the multicast interface and writing
+}
+
+func listenV4(ifaces []net.Interface) (*v4Conn, error) {
+	pc, err := listenPacket("udp4", fmt.Sprintf("0.0.0.0:%d", port))
+	if err != nil {
+		return nil, err
+	}
+	p := ipv4.NewPacketConn(pc)
+	joined := 0
+	for i := range ifaces {
+		if err := p.JoinGroup(&ifaces[i], &net.UDPAddr{IP: groupV4}); err != nil {
+			log.Warningf("Failed to join the IPv4 multicast group on %s: %s", ifaces[i].Name, err)
+			continue
+		}
+		joined++
+	}
+	if joined == 0 {
+		p.Close()
+		return nil, fmt.Errorf("failed to join the IPv4 multicast group on any interface")
+	}
+	p.SetControlMessage(ipv4.FlagInterface, true)
+	p.SetMulticastLoopback(false)
+	p.SetMulticastTTL(255)
+	return &v4Conn{PacketConn: p}, nil
+}
+
+func (c *v4Conn) read(b []byte) (int, int, *net.UDPAddr, error) {
+	n, cm, src, err := c.ReadFrom(b)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	ifIndex := 0
+	if cm != nil {
+		ifIndex = cm.IfIndex
+	}
+	u, _ := src.(*net.UDPAddr)
+	return n, ifIndex, u, nil
+}
+
+func (c *v4Conn) write(b []byte, ifi *net.Interface, dst *net.UDPAddr) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if dst.IP.IsMulticast() {
+		if err := c.SetMulticastInterface(ifi); err != nil {
+			return err
+		}
+	}
+	_, err := c.WriteTo(b, nil, dst)
+	return err
+}
+
+func (c *v4Conn) group() *net.UDPAddr { return &net.UDPAddr{IP: groupV4, Port: port} }
+
+// v6Conn is the packetConn for IPv6.
+type v6Conn struct {
+	*ipv6.PacketConn
+	mu sync.Mutex // serializes setting the multicast interface and writing
+}
+
+func listenV6(ifaces []net.Interface) (*v6Conn, error) {
+	pc, err := listenPacket("udp6", fmt.Sprintf("[::]:%d", port))
+	if err != nil {
+		return nil, err
+	}
+	p := ipv6.NewPacketConn(pc)
+	joined := 0
+	for i := range ifaces {
+		if err := p.JoinGroup(&ifaces[i], &net.UDPAddr{IP: groupV6}); err != nil {
+			log.Warningf("Failed to join the IPv6 multicast group on %s: %s", ifaces[i].Name, err)
+			continue
+		}
+		joined++
+	}
+	if joined == 0 {
+		p.Close()
+		return nil, fmt.Errorf("failed to join the IPv6 multicast group on any interface")
+	}
+	p.SetControlMessage(ipv6.FlagInterface, true)
+	p.SetMulticastLoopback(false)
+	p.SetMulticastHopLimit(255)
+	return &v6Conn{PacketConn: p}, nil
+}
+
+func (c *v6Conn) read(b []byte) (int, int, *net.UDPAddr, error) {
+	n, cm, src, err := c.ReadFrom(b)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	ifIndex := 0
+	if cm != nil {
+		ifIndex = cm.IfIndex
+	}
+	u, _ := src.(*net.UDPAddr)
+	return n, ifIndex, u, nil
+}
+
+func (c *v6Conn) write(b []byte, ifi *net.Interface, dst *net.UDPAddr) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if dst.IP.IsMulticast() {
+		if err := c.SetMulticastInterface(ifi); err != nil {
+			return err
+		}
+	}
+	_, err := c.WriteTo(b, nil, dst)
+	return err
+}
+
+func (c *v6Conn) group() *net.UDPAddr { return &net.UDPAddr{IP: groupV6, Port: port} }
@@ -0,0 +1,105 @@
+package mdns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+type written struct {
+	iface string
+	dst   string
+}
+
+// fakeConn records the packets written to it.
+type fakeConn struct {
+	writes []written
+	last   []byte
+}
+
+func (c *fakeConn) read(b []byte) (int, int, *net.UDPAddr, error) { return 0, 0, nil, nil }
+
+func (c *fakeConn) write(b []byte, ifi *net.Interface, dst *net.UDPAddr) error {
+	c.writes = append(c.writes, written{ifi.Name, dst.String()})
+	c.last = append([]byte(nil), b...)
+	return nil
+}
+
+func (c *fakeConn) group() *net.UDPAddr { return &net.UDPAddr{IP: groupV4, Port: port} }
+
+func (c *fakeConn) Close() error { return nil }
+
+func TestHandle(t *testing.T) {
+	m := &MDNS{Hosts: testMDNS.Hosts, Services: testMDNS.Services, Reflect: true}
+	r := &responder{m: m, ifaces: []net.Interface{{Index: 1001, Name: "eth0.10"}, {Index: 1002, Name: "eth0.20"}}}
+	group := (&fakeConn{}).group().String()
+	peer := &net.UDPAddr{IP: net.ParseIP("192.168.1.30"), Port: port}
+	legacy := &net.UDPAddr{IP: net.ParseIP("192.168.1.30"), Port: 40000}
+
+	resp := new(dns.Msg)
+	resp.Response = true
+
+	tests := []struct {
+		msg      *dns.Msg
+		src      *net.UDPAddr
+		expected []written
+		answers  int // in the last packet written
+	}{
+		// A query is reflected and answered.
+		{query(`Printer\ 2\.floor._ipp._tcp.local.`, dns.TypeSRV), peer, []written{{"eth0.20", group}, {"eth0.10", group}}, 1},
+		// A query we can't answer is only reflected.
+		{query("other.local.", dns.TypeA), peer, []written{{"eth0.20", group}}, 0},
+		// A response is only reflected.
+		{resp, peer, []written{{"eth0.20", group}}, 0},
+		// A legacy unicast query is answered to the querier, not reflected.
+		{query("printer.local.", dns.TypeA), legacy, []written{{"eth0.10", legacy.String()}}, 1},
+	}
+
+	for i, tc := range tests {
+		c := &fakeConn{}
+		b, err := tc.msg.Pack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.handle(c, &r.ifaces[0], b, tc.src)
+
+		if len(c.writes) != len(tc.expected) {
+			t.Errorf("Test %d: expected %d packets written, got %v", i, len(tc.expected), c.writes)
+			continue
+		}
+		for j := range c.writes {
+			if c.writes[j] != tc.expected[j] {
+				t.Errorf("Test %d: expected packet %d written %v, got %v", i, j, tc.expected[j], c.writes[j])
+			}
+		}
+		if tc.answers == 0 {
+			continue
+		}
+		res := new(dns.Msg)
+		if err := res.Unpack(c.last); err != nil {
+			t.Errorf("Test %d: expected a valid response, got %s", i, err)
+			continue
+		}
+		if len(res.Answer) != tc.answers {
+			t.Errorf("Test %d: expected %d answers, got %d", i, tc.answers, len(res.Answer))
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	// A name with escaped characters must match its form unpacked from the wire.
+	name := escape("Printer 2.floor \\ é") + "._ipp._tcp.local."
+	m := new(dns.Msg)
+	m.SetQuestion(name, dns.TypeSRV)
+	b, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Unpack(b); err != nil {
+		t.Fatal(err)
+	}
+	if m.Question[0].Name != name {
+		t.Errorf("Expected %s, got %s", name, m.Question[0].Name)
+	}
+}
@@ -0,0 +1,120 @@
+package mdns
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+var log = clog.NewWithPlugin("mdns")
+
+func init() {
+	caddy.RegisterPlugin("mdns", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	m, err := parse(c)
+	if err != nil {
+		return plugin.Error("mdns", err)
+	}
+
+	c.OnStartup(m.OnStartup)
+	c.OnRestart(m.OnShutdown)
+	c.OnFinalShutdown(m.OnShutdown)
+	c.OnRestartFailed(m.OnStartup)
+
+	// Don't do AddPlugin, mdns answers on its own sockets, not in the plugin chain.
+	return nil
+}
+
+func parse(c *caddy.Controller) (*MDNS, error) {
+	m := &MDNS{}
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		m.Interfaces = c.RemainingArgs()
+		for c.NextBlock() {
+			switch c.Val() {
+			case "host":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				h := Host{Name: localName(args[0])}
+				for _, a := range args[1:] {
+					ip := net.ParseIP(a)
+					if ip == nil {
+						return nil, fmt.Errorf("not a valid IP address: %s", a)
+					}
+					h.Addrs = append(h.Addrs, ip)
+				}
+				m.Hosts = append(m.Hosts, h)
+
+			case "service":
+				args := c.RemainingArgs()
+				if len(args) < 4 {
+					return nil, c.ArgErr()
+				}
+				if !serviceType(args[1]) {
+					return nil, fmt.Errorf("not a valid service type: %s", args[1])
+				}
+				port, err := strconv.ParseUint(args[2], 10, 16)
+				if err != nil || port == 0 {
+					return nil, fmt.Errorf("not a valid port: %s", args[2])
+				}
+				m.Services = append(m.Services, Service{
+					Instance: args[0],
+					Type:     args[1],
+					Port:     uint16(port),
+					Host:     localName(args[3]),
+					TXT:      args[4:],
+				})
+
+			case "reflect":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				m.Reflect = true
+
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	if len(m.Hosts) == 0 && len(m.Services) == 0 && !m.Reflect {
+		return nil, fmt.Errorf("no hosts or services to answer for, and not reflecting")
+	}
+	return m, nil
+}
+
+// localName returns name as a fully qualified name in the local. domain.
+func localName(name string) string {
+	name = dns.Fqdn(name)
+	if !dns.IsSubDomain("local.", name) {
+		name += "local."
+	}
+	return name
+}
+
+// serviceType returns true if t is a service type: the service name and the protocol, _tcp or _udp.
+func serviceType(t string) bool {
+	labels := strings.Split(t, ".")
+	if len(labels) != 2 || len(labels[0]) < 2 || labels[0][0] != '_' {
+		return false
+	}
+	return labels[1] == "_tcp" || labels[1] == "_udp"
+}
@@ -0,0 +1,78 @@
+package mdns
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  *MDNS
+	}{
+		{`mdns {
+			host printer 192.168.1.10
+		}`, false, &MDNS{Hosts: []Host{{Name: "printer.local.", Addrs: []net.IP{net.ParseIP("192.168.1.10")}}}}},
+		{`mdns eth0 eth1 {
+			host nas.local
+			service "Family Photos" _http._tcp 8080 nas path=/photos
+			reflect
+		}`, false, &MDNS{
+			Interfaces: []string{"eth0", "eth1"},
+			Hosts:      []Host{{Name: "nas.local."}},
+			Services:   []Service{{Instance: "Family Photos", Type: "_http._tcp", Port: 8080, Host: "nas.local.", TXT: []string{"path=/photos"}}},
+			Reflect:    true,
+		}},
+		{`mdns eth0.10 eth0.20 {
+			reflect
+		}`, false, &MDNS{Interfaces: []string{"eth0.10", "eth0.20"}, Reflect: true}},
+		{`mdns`, true, nil},
+		{`mdns {
+			host
+		}`, true, nil},
+		{`mdns {
+			host printer 192.168.1.invalid
+		}`, true, nil},
+		{`mdns {
+			service Printer _ipp._tcp 631
+		}`, true, nil},
+		{`mdns {
+			service Printer ipp 631 printer
+		}`, true, nil},
+		{`mdns {
+			service Printer _ipp._sctp 631 printer
+		}`, true, nil},
+		{`mdns {
+			service Printer _ipp._tcp 65536 printer
+		}`, true, nil},
+		{`mdns {
+			reflect always
+		}`, true, nil},
+		{`mdns {
+			announce
+		}`, true, nil},
+		{"mdns {\nreflect\n}\nmdns {\nreflect\n}", true, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		m, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(m, tc.expected) {
+			t.Errorf("Test %d: expected %+v, got %+v", i, tc.expected, m)
+		}
+	}
+}
@@ -27,18 +27,27 @@ func ContextWithMetadata(ctx context.Context) context.Context {
 
 // ServeDNS implements the plugin.Handler interface.
 func (m *Metadata) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	// The server already collected the metadata when the server block is a view, to select it.
+	if ValueFuncs(ctx) == nil {
+		ctx = m.Collect(ctx, request.Request{W: w, Req: r})
+	}
+
+	rcode, err := plugin.NextOrFailure(m.Name(), m.Next, ctx, w, r)
+
+	return rcode, err
+}
 
+// Collect implements the dnsserver.MetadataCollector interface, it returns a context holding the
+// metadata of the query state.
+func (m *Metadata) Collect(ctx context.Context, state request.Request) context.Context {
 	ctx = ContextWithMetadata(ctx)
 
-	state := request.Request{W: w, Req: r}
 	if plugin.Zones(m.Zones).Matches(state.Name()) != "" {
+		setTransportMetadata(ctx, state)
 		// Go through all Providers and collect metadata.
 		for _, p := range m.Providers {
 			ctx = p.Metadata(ctx, state)
 		}
 	}
-
-	rcode, err := plugin.NextOrFailure(m.Name(), m.Next, ctx, w, r)
-
-	return rcode, err
+	return ctx
 }
@@ -0,0 +1,74 @@
+package metadata
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/request"
+)
+
+// setTransportMetadata adds the metadata describing how the query arrived: the transport, the
+// listener, and the TLS and HTTP attributes when the query used those.
+func setTransportMetadata(ctx context.Context, state request.Request) {
+	trans := state.Proto()
+	listener := ""
+	if s, ok := ctx.Value(dnsserver.Key{}).(*dnsserver.Server); ok {
+		scheme, addr := transport.DNS, s.Addr
+		if i := strings.Index(s.Addr, "://"); i > 0 {
+			scheme, addr = s.Addr[:i], s.Addr[i+3:]
+		}
+		if scheme != transport.DNS {
+			trans = scheme
+		}
+		listener = addr
+	}
+	SetValueFunc(ctx, "metadata/transport", func() string { return trans })
+	SetValueFunc(ctx, "metadata/listener", func() string { return listener })
+
+	cs, _ := ctx.Value(dnsserver.TLSConnectionStateKey{}).(*tls.ConnectionState)
+
+	if r, ok := ctx.Value(dnsserver.HTTPRequestKey{}).(*http.Request); ok {
+		cs = r.TLS
+		SetValueFunc(ctx, "metadata/http_path", func() string { return r.URL.Path })
+		SetValueFunc(ctx, "metadata/http_user_agent", r.UserAgent)
+	}
+
+	if cs != nil {
+		SetValueFunc(ctx, "metadata/tls_sni", func() string { return cs.ServerName })
+		SetValueFunc(ctx, "metadata/tls_version", func() string { return tlsVersion(cs.Version) })
+	}
+
+	if cert, ok := ctx.Value(dnsserver.ClientCertKey{}).(*x509.Certificate); ok {
+		SetValueFunc(ctx, "metadata/tls_client_cn", func() string { return cert.Subject.CommonName })
+		SetValueFunc(ctx, "metadata/tls_client_san", func() string { return subjectAltNames(cert) })
+	}
+}
+
+// subjectAltNames returns the DNS names, email addresses and URIs of cert, separated by commas.
+func subjectAltNames(cert *x509.Certificate) string {
+	names := append(append([]string{}, cert.DNSNames...), cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	return strings.Join(names, ",")
+}
+
+// tlsVersion returns the TLS version v as a string, i.e. "1.2".
+func tlsVersion(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "1.0"
+	case tls.VersionTLS11:
+		return "1.1"
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	}
+	return ""
+}
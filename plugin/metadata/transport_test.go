@@ -0,0 +1,160 @@
+package metadata
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func TestSetTransportMetadata(t *testing.T) {
+	httpReq := &http.Request{
+		URL:    &url.URL{Path: "/dns-query"},
+		Header: http.Header{"User-Agent": []string{"test-agent"}},
+		TLS:    &tls.ConnectionState{ServerName: "dns.example.org", Version: tls.VersionTLS12},
+	}
+
+	spiffe, _ := url.Parse("spiffe://example.org/tenant-a")
+	clientCert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "tenant-a"},
+		DNSNames:       []string{"tenant-a.example.org"},
+		EmailAddresses: []string{"ops@tenant-a.example.org"},
+		URIs:           []*url.URL{spiffe},
+	}
+
+	tests := []struct {
+		addr       string
+		httpReq    *http.Request
+		clientCert *x509.Certificate
+		expected   map[string]string
+	}{
+		{"dns://:53", nil, nil, map[string]string{
+			"metadata/transport": "udp",
+			"metadata/listener":  ":53",
+		}},
+		{"tls://:853", nil, nil, map[string]string{
+			"metadata/transport": "tls",
+			"metadata/listener":  ":853",
+		}},
+		{"tls://:853", nil, clientCert, map[string]string{
+			"metadata/transport":      "tls",
+			"metadata/listener":       ":853",
+			"metadata/tls_client_cn":  "tenant-a",
+			"metadata/tls_client_san": "tenant-a.example.org,ops@tenant-a.example.org,spiffe://example.org/tenant-a",
+		}},
+		{"https://127.0.0.1:443", httpReq, nil, map[string]string{
+			"metadata/transport":       "https",
+			"metadata/listener":        "127.0.0.1:443",
+			"metadata/http_path":       "/dns-query",
+			"metadata/http_user_agent": "test-agent",
+			"metadata/tls_sni":         "dns.example.org",
+			"metadata/tls_version":     "1.2",
+		}},
+	}
+
+	for i, tc := range tests {
+		ctx := ContextWithMetadata(context.TODO())
+		ctx = context.WithValue(ctx, dnsserver.Key{}, &dnsserver.Server{Addr: tc.addr})
+		if tc.httpReq != nil {
+			ctx = context.WithValue(ctx, dnsserver.HTTPRequestKey{}, tc.httpReq)
+		}
+		if tc.clientCert != nil {
+			ctx = context.WithValue(ctx, dnsserver.ClientCertKey{}, tc.clientCert)
+		}
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		setTransportMetadata(ctx, request.Request{W: &test.ResponseWriter{}, Req: m})
+
+		for label, expected := range tc.expected {
+			f := ValueFunc(ctx, label)
+			if f == nil {
+				t.Errorf("Test %d: Expected label %s to be set", i, label)
+				continue
+			}
+			if f() != expected {
+				t.Errorf("Test %d: Expected %q for %s, got %q", i, expected, label, f())
+			}
+		}
+		if len(Labels(ctx)) != len(tc.expected) {
+			t.Errorf("Test %d: Expected %d labels, got %v", i, len(tc.expected), Labels(ctx))
+		}
+	}
+}
+
+func TestSetTransportMetadataTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dns.example.org"},
+		DNSNames:     []string{"dns.example.org"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The metadata plugin, and a plugin answering with the TLS labels in a TXT record.
+	c := &dnsserver.Config{Zone: ".", Transport: "tls", ListenHosts: []string{"127.0.0.1"}, Port: "853"}
+	c.TLSConfig = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	c.AddPlugin(func(next plugin.Handler) plugin.Handler { return &Metadata{Zones: []string{"."}, Next: next} })
+	c.AddPlugin(func(next plugin.Handler) plugin.Handler {
+		return test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			txt := &dns.TXT{Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET}}
+			for _, label := range []string{"metadata/tls_sni", "metadata/tls_version"} {
+				v := "unset"
+				if f := ValueFunc(ctx, label); f != nil {
+					v = f()
+				}
+				txt.Txt = append(txt.Txt, v)
+			}
+			m.Answer = append(m.Answer, txt)
+			w.WriteMsg(m)
+			return dns.RcodeSuccess, nil
+		})
+	})
+
+	s, err := dnsserver.NewServerTLS("tls://127.0.0.1:0", []*dnsserver.Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	defer s.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeTXT)
+	client := &dns.Client{Net: "tcp-tls", TLSConfig: &tls.Config{ServerName: "dns.example.org", InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12}}
+	r, _, err := client.Exchange(m, l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txt := r.Answer[0].(*dns.TXT).Txt; txt[0] != "dns.example.org" || txt[1] != "1.2" {
+		t.Errorf("Expected the SNI dns.example.org and TLS version 1.2, got %v", txt)
+	}
+}
@@ -26,7 +26,9 @@ func (m *Metrics) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	rw := dnstest.NewRecorder(w)
 	status, err := plugin.NextOrFailure(m.Name(), m.Next, ctx, rw, r)
 
-	vars.Report(WithServer(ctx), state, zone, rcode.ToString(rw.Rcode), rw.Len, rw.Start)
+	server := WithServer(ctx)
+	vars.Report(server, state, zone, rcode.ToString(rw.Rcode), rw.Len, rw.Start)
+	vars.ReportTruncation(server, state, zone, rw.Msg)
 
 	return status, err
 }
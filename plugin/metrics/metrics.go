@@ -53,7 +53,18 @@ func New(addr string) *Metrics {
 	met.MustRegister(vars.RequestType)
 	met.MustRegister(vars.ResponseSize)
 	met.MustRegister(vars.ResponseRcode)
+	met.MustRegister(vars.Truncated)
+	met.MustRegister(vars.TCPRetry)
+	met.MustRegister(vars.QueryTimeouts)
+	met.MustRegister(vars.ConcurrencyShed)
 	met.MustRegister(vars.PluginEnabled)
+	met.MustRegister(vars.SetupFailure)
+	met.MustRegister(vars.FeatureGate)
+	met.MustRegister(vars.HTTPSRequestCount)
+	met.MustRegister(vars.HTTPSResponseCount)
+	met.MustRegister(vars.HTTPSRequestSize)
+	met.MustRegister(vars.HTTPSResponseSize)
+	met.MustRegister(vars.HTTPSFirstByteDuration)
 
 	return met
 }
@@ -0,0 +1,77 @@
+package vars
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTPS* count the requests of the DNS-over-HTTPS servers, https:// and http://, by method and
+// status code, and measure the size of their DNS messages and the time to the first byte of the
+// response.
+var (
+	HTTPSRequestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: httpsSubsystem,
+		Name:      "requests_total",
+		Help:      "Counter of DNS-over-HTTPS requests per method.",
+	}, []string{"server", "method"})
+
+	HTTPSResponseCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: httpsSubsystem,
+		Name:      "responses_total",
+		Help:      "Counter of DNS-over-HTTPS responses per status code.",
+	}, []string{"server", "status"})
+
+	HTTPSRequestSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: httpsSubsystem,
+		Name:      "request_size_bytes",
+		Help:      "Size of the DNS message of the DNS-over-HTTPS requests in bytes, per method.",
+		Buckets:   []float64{0, 100, 200, 300, 400, 511, 1023, 2047, 4095, 8291, 16e3, 32e3, 48e3, 64e3},
+	}, []string{"server", "method"})
+
+	HTTPSResponseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: httpsSubsystem,
+		Name:      "response_size_bytes",
+		Help:      "Size of the body of the DNS-over-HTTPS responses in bytes.",
+		Buckets:   []float64{0, 100, 200, 300, 400, 511, 1023, 2047, 4095, 8291, 16e3, 32e3, 48e3, 64e3},
+	}, []string{"server"})
+
+	HTTPSFirstByteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: httpsSubsystem,
+		Name:      "time_to_first_byte_seconds",
+		Buckets:   plugin.TimeBuckets,
+		Help:      "Histogram of the time (in seconds) from a DNS-over-HTTPS request to the first byte of its response.",
+	}, []string{"server"})
+)
+
+const httpsSubsystem = "https"
+
+// ReportHTTPS reports a DNS-over-HTTPS request of server with method, whose DNS message is size bytes,
+// or zero when it could not be read, and its response with status and a body of respSize bytes,
+// whose first byte was written ttfb after the request.
+func ReportHTTPS(server, method string, size, status, respSize int, ttfb time.Duration) {
+	switch method {
+	case http.MethodGet, http.MethodPost:
+	default:
+		// The other methods are refused, don't let them add labels.
+		method = "other"
+	}
+	HTTPSRequestCount.WithLabelValues(server, method).Inc()
+	HTTPSResponseCount.WithLabelValues(server, strconv.Itoa(status)).Inc()
+	if size > 0 {
+		HTTPSRequestSize.WithLabelValues(server, method).Observe(float64(size))
+	}
+	HTTPSResponseSize.WithLabelValues(server).Observe(float64(respSize))
+	if ttfb >= 0 {
+		HTTPSFirstByteDuration.WithLabelValues(server).Observe(ttfb.Seconds())
+	}
+}
@@ -0,0 +1,282 @@
+package vars
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The counters and histograms updated for every request are sharded: each label set has one shard
+// per CPU and an update only touches the shard of the CPU it runs on, so concurrent requests don't
+// contend on the same atomic values. The shards are added up when the metrics are collected, i.e.
+// on every scrape.
+
+// numShards is the number of shards of each label set.
+var numShards = runtime.GOMAXPROCS(0)
+
+// shardIDs hands out shard numbers. sync.Pool keeps a free list per P, so a goroutine mostly gets
+// the number last used on the P it runs on, without touching memory shared with other Ps.
+var (
+	shardIDs  = sync.Pool{New: func() interface{} { i := int(atomic.AddUint32(&nextShard, 1)); return &i }}
+	nextShard uint32
+)
+
+func shard() int {
+	p := shardIDs.Get().(*int)
+	i := *p
+	shardIDs.Put(p)
+	return i % numShards
+}
+
+// cacheLine is the size we pad shards to, to keep them on separate cache lines.
+const cacheLine = 64
+
+// labelSets holds the label sets of a sharded metric vector, keyed by the hash of the label values.
+type labelSets struct {
+	desc   *prometheus.Desc
+	labels int // number of labels
+
+	mu   sync.Mutex // serializes adding label sets
+	sets sync.Map   // hash of the label values -> []*labelSet
+}
+
+type labelSet struct {
+	values []string
+	metric interface{} // *ShardedCounter or *ShardedHistogram
+}
+
+// get returns the metric for the label values lvs, new creates it when there is none yet.
+func (l *labelSets) get(lvs []string, new func() interface{}) interface{} {
+	if len(lvs) != l.labels {
+		panic(fmt.Sprintf("%s: expected %d label values, got %d", l.desc, l.labels, len(lvs)))
+	}
+	h := hashLabels(lvs)
+	if s, ok := l.sets.Load(h); ok {
+		if m := find(s.([]*labelSet), lvs); m != nil {
+			return m
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var sets []*labelSet
+	if s, ok := l.sets.Load(h); ok {
+		sets = s.([]*labelSet)
+		if m := find(sets, lvs); m != nil {
+			return m
+		}
+	}
+	ls := &labelSet{values: append([]string(nil), lvs...), metric: new()}
+	l.sets.Store(h, append(sets[:len(sets):len(sets)], ls))
+	return ls.metric
+}
+
+// each calls f for all label sets.
+func (l *labelSets) each(f func(*labelSet)) {
+	l.sets.Range(func(_, s interface{}) bool {
+		for _, ls := range s.([]*labelSet) {
+			f(ls)
+		}
+		return true
+	})
+}
+
+func find(sets []*labelSet, lvs []string) interface{} {
+	for _, ls := range sets {
+		if equal(ls.values, lvs) {
+			return ls.metric
+		}
+	}
+	return nil
+}
+
+func equal(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hashLabels returns the FNV-1a hash of lvs.
+func hashLabels(lvs []string) uint64 {
+	h := uint64(14695981039346656037)
+	for _, v := range lvs {
+		for i := 0; i < len(v); i++ {
+			h ^= uint64(v[i])
+			h *= 1099511628211
+		}
+		h ^= 0xff // separator
+		h *= 1099511628211
+	}
+	return h
+}
+
+// ShardedCounterVec is a prometheus.CounterVec that is sharded per CPU.
+type ShardedCounterVec struct {
+	labelSets
+}
+
+// NewShardedCounterVec returns a new ShardedCounterVec.
+func NewShardedCounterVec(opts prometheus.CounterOpts, labels []string) *ShardedCounterVec {
+	desc := prometheus.NewDesc(prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name), opts.Help, labels, opts.ConstLabels)
+	return &ShardedCounterVec{labelSets{desc: desc, labels: len(labels)}}
+}
+
+// WithLabelValues returns the counter for the label values lvs. It panics when the number of label
+// values is wrong.
+func (v *ShardedCounterVec) WithLabelValues(lvs ...string) *ShardedCounter {
+	return v.get(lvs, func() interface{} { return &ShardedCounter{shards: make([]counterShard, numShards)} }).(*ShardedCounter)
+}
+
+// Describe implements the prometheus.Collector interface.
+func (v *ShardedCounterVec) Describe(ch chan<- *prometheus.Desc) { ch <- v.desc }
+
+// Collect implements the prometheus.Collector interface.
+func (v *ShardedCounterVec) Collect(ch chan<- prometheus.Metric) {
+	v.each(func(ls *labelSet) {
+		ch <- prometheus.MustNewConstMetric(v.desc, prometheus.CounterValue, ls.metric.(*ShardedCounter).value(), ls.values...)
+	})
+}
+
+// ShardedCounter is a counter that is sharded per CPU.
+type ShardedCounter struct {
+	shards []counterShard
+}
+
+type counterShard struct {
+	n uint64 // whole increments, accessed atomically
+	f uint64 // float64 bits of the fractional additions, accessed atomically
+	_ [cacheLine - 16]byte
+}
+
+// Inc increments c by 1.
+func (c *ShardedCounter) Inc() { atomic.AddUint64(&c.shards[shard()].n, 1) }
+
+// Add adds v to c. It panics when v is negative.
+func (c *ShardedCounter) Add(v float64) {
+	if v < 0 {
+		panic("counter cannot decrease in value")
+	}
+	s := &c.shards[shard()]
+	if u := uint64(v); float64(u) == v {
+		atomic.AddUint64(&s.n, u)
+		return
+	}
+	addFloat(&s.f, v)
+}
+
+func (c *ShardedCounter) value() float64 {
+	v := 0.0
+	for i := range c.shards {
+		v += float64(atomic.LoadUint64(&c.shards[i].n)) + math.Float64frombits(atomic.LoadUint64(&c.shards[i].f))
+	}
+	return v
+}
+
+// addFloat atomically adds v to the float64 stored as bits in f.
+func addFloat(f *uint64, v float64) {
+	for {
+		old := atomic.LoadUint64(f)
+		if atomic.CompareAndSwapUint64(f, old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// ShardedHistogramVec is a prometheus.HistogramVec that is sharded per CPU.
+type ShardedHistogramVec struct {
+	labelSets
+	buckets []float64
+}
+
+// NewShardedHistogramVec returns a new ShardedHistogramVec.
+func NewShardedHistogramVec(opts prometheus.HistogramOpts, labels []string) *ShardedHistogramVec {
+	desc := prometheus.NewDesc(prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name), opts.Help, labels, opts.ConstLabels)
+	buckets := opts.Buckets
+	if buckets == nil {
+		buckets = prometheus.DefBuckets
+	}
+	if len(buckets) > 0 && math.IsInf(buckets[len(buckets)-1], +1) {
+		buckets = buckets[:len(buckets)-1] // the +Inf bucket is implicit
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("%s: histogram buckets must be in increasing order", desc))
+	}
+	return &ShardedHistogramVec{labelSets: labelSets{desc: desc, labels: len(labels)}, buckets: buckets}
+}
+
+// WithLabelValues returns the histogram for the label values lvs. It panics when the number of
+// label values is wrong.
+func (v *ShardedHistogramVec) WithLabelValues(lvs ...string) *ShardedHistogram {
+	return v.get(lvs, func() interface{} {
+		h := &ShardedHistogram{buckets: v.buckets, shards: make([]histogramShard, numShards)}
+		for i := range h.shards {
+			h.shards[i].counts = make([]uint64, len(v.buckets))
+		}
+		return h
+	}).(*ShardedHistogram)
+}
+
+// Describe implements the prometheus.Collector interface.
+func (v *ShardedHistogramVec) Describe(ch chan<- *prometheus.Desc) { ch <- v.desc }
+
+// Collect implements the prometheus.Collector interface.
+func (v *ShardedHistogramVec) Collect(ch chan<- prometheus.Metric) {
+	v.each(func(ls *labelSet) {
+		count, sum, buckets := ls.metric.(*ShardedHistogram).values()
+		ch <- prometheus.MustNewConstHistogram(v.desc, count, sum, buckets, ls.values...)
+	})
+}
+
+// ShardedHistogram is a histogram that is sharded per CPU.
+type ShardedHistogram struct {
+	buckets []float64
+	shards  []histogramShard
+}
+
+type histogramShard struct {
+	count  uint64   // accessed atomically
+	sum    uint64   // float64 bits, accessed atomically
+	counts []uint64 // observations per bucket, not cumulative, accessed atomically
+	_      [cacheLine - 40]byte
+}
+
+// Observe adds an observation of v to h.
+func (h *ShardedHistogram) Observe(v float64) {
+	s := &h.shards[shard()]
+	// The count goes first and is read last by values, so it is never lower than the buckets.
+	atomic.AddUint64(&s.count, 1)
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		atomic.AddUint64(&s.counts[i], 1)
+	}
+	addFloat(&s.sum, v)
+}
+
+// values returns the count, the sum and the cumulative bucket counts of h.
+func (h *ShardedHistogram) values() (uint64, float64, map[float64]uint64) {
+	var count uint64
+	sum := 0.0
+	counts := make([]uint64, len(h.buckets))
+	for i := range h.shards {
+		s := &h.shards[i]
+		for j := range s.counts {
+			counts[j] += atomic.LoadUint64(&s.counts[j])
+		}
+		sum += math.Float64frombits(atomic.LoadUint64(&s.sum))
+		count += atomic.LoadUint64(&s.count)
+	}
+	buckets := make(map[float64]uint64, len(h.buckets))
+	var cum uint64
+	for i, b := range h.buckets {
+		cum += counts[i]
+		buckets[b] = cum
+	}
+	return count, sum, buckets
+}
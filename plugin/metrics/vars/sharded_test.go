@@ -0,0 +1,112 @@
+package vars
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gather returns the text of the metrics collected from c.
+func gather(t *testing.T, c prometheus.Collector) string {
+	r := prometheus.NewRegistry()
+	if err := r.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	mfs, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ""
+	for _, mf := range mfs {
+		s += mf.String() + "\n"
+	}
+	return s
+}
+
+func TestShardedCounterVec(t *testing.T) {
+	opts := prometheus.CounterOpts{Namespace: "coredns", Name: "test_total", Help: "Test."}
+	sharded := NewShardedCounterVec(opts, []string{"a", "b"})
+	expected := prometheus.NewCounterVec(opts, []string{"a", "b"})
+
+	for i := 0; i < 100; i++ {
+		sharded.WithLabelValues("x", "y").Inc()
+		expected.WithLabelValues("x", "y").Inc()
+	}
+	sharded.WithLabelValues("x", "z").Add(2.5)
+	expected.WithLabelValues("x", "z").Add(2.5)
+	// Same values concatenated, a different label set.
+	sharded.WithLabelValues("xy", "").Inc()
+	expected.WithLabelValues("xy", "").Inc()
+
+	if got, exp := gather(t, sharded), gather(t, expected); got != exp {
+		t.Errorf("Expected\n%s\ngot\n%s", exp, got)
+	}
+}
+
+func TestShardedHistogramVec(t *testing.T) {
+	opts := prometheus.HistogramOpts{Namespace: "coredns", Name: "test_seconds", Help: "Test.", Buckets: []float64{1, 2, 5}}
+	sharded := NewShardedHistogramVec(opts, []string{"a"})
+	expected := prometheus.NewHistogramVec(opts, []string{"a"})
+
+	for _, v := range []float64{0.5, 1, 1.5, 3, 10, 2} {
+		sharded.WithLabelValues("x").Observe(v)
+		expected.WithLabelValues("x").Observe(v)
+	}
+
+	if got, exp := gather(t, sharded), gather(t, expected); got != exp {
+		t.Errorf("Expected\n%s\ngot\n%s", exp, got)
+	}
+}
+
+func TestShardedLabelCount(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic for the wrong number of label values")
+		}
+	}()
+	NewShardedCounterVec(prometheus.CounterOpts{Name: "test_total", Help: "Test."}, []string{"a"}).WithLabelValues("x", "y")
+}
+
+func BenchmarkCounterVec(b *testing.B) {
+	opts := prometheus.CounterOpts{Namespace: "coredns", Name: "test_total", Help: "Test."}
+	labels := []string{"server", "zone", "proto", "family"}
+
+	b.Run("prometheus", func(b *testing.B) {
+		v := prometheus.NewCounterVec(opts, labels)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				v.WithLabelValues("dns://:53", "example.org.", "udp", "1").Inc()
+			}
+		})
+	})
+	b.Run("sharded", func(b *testing.B) {
+		v := NewShardedCounterVec(opts, labels)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				v.WithLabelValues("dns://:53", "example.org.", "udp", "1").Inc()
+			}
+		})
+	})
+}
+
+func BenchmarkHistogramVec(b *testing.B) {
+	opts := prometheus.HistogramOpts{Namespace: "coredns", Name: "test_seconds", Help: "Test.", Buckets: prometheus.DefBuckets}
+	labels := []string{"server", "zone"}
+
+	b.Run("prometheus", func(b *testing.B) {
+		v := prometheus.NewHistogramVec(opts, labels)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				v.WithLabelValues("dns://:53", "example.org.").Observe(0.001)
+			}
+		})
+	})
+	b.Run("sharded", func(b *testing.B) {
+		v := NewShardedHistogramVec(opts, labels)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				v.WithLabelValues("dns://:53", "example.org.").Observe(0.001)
+			}
+		})
+	})
+}
@@ -0,0 +1,97 @@
+package vars
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Truncated and TCPRetry count the truncated UDP responses and the queries retried over TCP after a
+// truncated response. Both are labeled with the buffer size of the UDP query, see bufsizeBucket, so
+// the number of retries can be compared to the number of truncated responses per buffer size.
+var (
+	Truncated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: subsystem,
+		Name:      "truncated_responses_total",
+		Help:      "Counter of truncated UDP responses per zone and buffer size of the query.",
+	}, []string{"server", "zone", "bufsize"})
+
+	TCPRetry = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: subsystem,
+		Name:      "tcp_retries_total",
+		Help:      "Counter of queries retried over TCP after a truncated UDP response, per zone and buffer size of the UDP query.",
+	}, []string{"server", "zone", "bufsize"})
+)
+
+// truncatedQueries remembers the queries that got a truncated response, to recognize their retry
+// over TCP.
+var truncatedQueries = cache.New(10000)
+
+// retryWindow is the time a client has to retry a query over TCP after a truncated response.
+const retryWindow = 5 * time.Second
+
+type truncatedQuery struct {
+	bufsize string
+	at      time.Time
+}
+
+// ReportTruncation reports res, the response to req, when it is a truncated UDP response, and req
+// when it is a TCP query for which a truncated response was sent over UDP less than retryWindow
+// ago. res may be nil.
+func ReportTruncation(server string, req request.Request, zone string, res *dns.Msg) {
+	switch req.Proto() {
+	case "udp":
+		if res == nil || !res.Truncated {
+			return
+		}
+		bufsize := bufsizeBucket(req.Size())
+		Truncated.WithLabelValues(server, zone, bufsize).Inc()
+		truncatedQueries.Add(truncatedKey(req), truncatedQuery{bufsize: bufsize, at: time.Now()})
+
+	case "tcp":
+		if truncatedQueries.Len() == 0 {
+			return
+		}
+		key := truncatedKey(req)
+		q, ok := truncatedQueries.Get(key)
+		if !ok {
+			return
+		}
+		truncatedQueries.Remove(key)
+		if t := q.(truncatedQuery); time.Since(t.at) <= retryWindow {
+			TCPRetry.WithLabelValues(server, zone, t.bufsize).Inc()
+		}
+	}
+}
+
+// truncatedKey returns the key of the query in truncatedQueries: the client's address and the
+// question.
+func truncatedKey(req request.Request) uint64 {
+	return cache.Hash([]byte(req.IP() + " " + strings.ToLower(req.QName()) + " " + strconv.Itoa(int(req.QType()))))
+}
+
+// bufsizeBucket returns the label for the UDP buffer size size: the upper bound of the range it is
+// in. The ranges end at the common buffer sizes: 512 (no EDNS0), 1232 (fits the IPv6 minimum MTU),
+// 1472 (fits an Ethernet MTU over IPv4) and 4096.
+func bufsizeBucket(size int) string {
+	switch {
+	case size <= 512:
+		return "512"
+	case size <= 1232:
+		return "1232"
+	case size <= 1472:
+		return "1472"
+	case size <= 4096:
+		return "4096"
+	}
+	return "65535"
+}
@@ -0,0 +1,66 @@
+package vars
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestReportTruncation(t *testing.T) {
+	server := "dns://:5301"
+	query := func(name string, tcp bool) request.Request {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeTXT)
+		m.SetEdns0(1232, false)
+		return request.Request{W: &test.ResponseWriter{TCP: tcp}, Req: m}
+	}
+	truncated := func(req request.Request) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetReply(req.Req)
+		m.Truncated = true
+		return m
+	}
+
+	// A truncated response followed by a retry over TCP.
+	req := query("example.org.", false)
+	ReportTruncation(server, req, "example.org.", truncated(req))
+	ReportTruncation(server, query("Example.org.", true), "example.org.", nil)
+
+	// A response that isn't truncated, and a TCP query without a truncated response before it.
+	req = query("a.example.org.", false)
+	ReportTruncation(server, req, "example.org.", new(dns.Msg).SetReply(req.Req))
+	ReportTruncation(server, query("b.example.org.", true), "example.org.", nil)
+
+	// A second TCP query for the same question isn't a retry.
+	ReportTruncation(server, query("example.org.", true), "example.org.", nil)
+
+	if x := testutil.ToFloat64(Truncated.WithLabelValues(server, "example.org.", "1232")); x != 1 {
+		t.Errorf("Expected 1 truncated response, got %f", x)
+	}
+	if x := testutil.ToFloat64(TCPRetry.WithLabelValues(server, "example.org.", "1232")); x != 1 {
+		t.Errorf("Expected 1 TCP retry, got %f", x)
+	}
+}
+
+func TestBufsizeBucket(t *testing.T) {
+	tests := []struct {
+		size     int
+		expected string
+	}{
+		{512, "512"},
+		{1024, "1232"},
+		{1232, "1232"},
+		{1400, "1472"},
+		{4096, "4096"},
+		{8192, "65535"},
+	}
+	for i, tc := range tests {
+		if x := bufsizeBucket(tc.size); x != tc.expected {
+			t.Errorf("Test %d: expected bucket %s for %d, got %s", i, tc.expected, tc.size, x)
+		}
+	}
+}
@@ -6,16 +6,17 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-// Request* and Response* are the prometheus counters and gauges we are using for exporting metrics.
+// Request* and Response* are the prometheus counters and gauges we are using for exporting metrics. They
+// are updated for every request and are sharded, see ShardedCounterVec and ShardedHistogramVec.
 var (
-	RequestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	RequestCount = NewShardedCounterVec(prometheus.CounterOpts{
 		Namespace: plugin.Namespace,
 		Subsystem: subsystem,
 		Name:      "request_count_total",
 		Help:      "Counter of DNS requests made per zone, protocol and family.",
 	}, []string{"server", "zone", "proto", "family"})
 
-	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	RequestDuration = NewShardedHistogramVec(prometheus.HistogramOpts{
 		Namespace: plugin.Namespace,
 		Subsystem: subsystem,
 		Name:      "request_duration_seconds",
@@ -23,7 +24,7 @@ var (
 		Help:      "Histogram of the time (in seconds) each request took.",
 	}, []string{"server", "zone"})
 
-	RequestSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	RequestSize = NewShardedHistogramVec(prometheus.HistogramOpts{
 		Namespace: plugin.Namespace,
 		Subsystem: subsystem,
 		Name:      "request_size_bytes",
@@ -31,21 +32,21 @@ var (
 		Buckets:   []float64{0, 100, 200, 300, 400, 511, 1023, 2047, 4095, 8291, 16e3, 32e3, 48e3, 64e3},
 	}, []string{"server", "zone", "proto"})
 
-	RequestDo = prometheus.NewCounterVec(prometheus.CounterOpts{
+	RequestDo = NewShardedCounterVec(prometheus.CounterOpts{
 		Namespace: plugin.Namespace,
 		Subsystem: subsystem,
 		Name:      "request_do_count_total",
 		Help:      "Counter of DNS requests with DO bit set per zone.",
 	}, []string{"server", "zone"})
 
-	RequestType = prometheus.NewCounterVec(prometheus.CounterOpts{
+	RequestType = NewShardedCounterVec(prometheus.CounterOpts{
 		Namespace: plugin.Namespace,
 		Subsystem: subsystem,
 		Name:      "request_type_count_total",
 		Help:      "Counter of DNS requests per type, per zone.",
 	}, []string{"server", "zone", "type"})
 
-	ResponseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	ResponseSize = NewShardedHistogramVec(prometheus.HistogramOpts{
 		Namespace: plugin.Namespace,
 		Subsystem: subsystem,
 		Name:      "response_size_bytes",
@@ -53,7 +54,7 @@ var (
 		Buckets:   []float64{0, 100, 200, 300, 400, 511, 1023, 2047, 4095, 8291, 16e3, 32e3, 48e3, 64e3},
 	}, []string{"server", "zone", "proto"})
 
-	ResponseRcode = prometheus.NewCounterVec(prometheus.CounterOpts{
+	ResponseRcode = NewShardedCounterVec(prometheus.CounterOpts{
 		Namespace: plugin.Namespace,
 		Subsystem: subsystem,
 		Name:      "response_rcode_count_total",
@@ -66,11 +67,37 @@ var (
 		Help:      "A metrics that counts the number of panics.",
 	})
 
+	QueryTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: subsystem,
+		Name:      "query_timeouts_total",
+		Help:      "Counter of queries answered with SERVFAIL after the max query time, per zone and plugin that was running.",
+	}, []string{"server", "zone", "plugin"})
+
+	ConcurrencyShed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: subsystem,
+		Name:      "concurrency_shed_requests_total",
+		Help:      "Counter of queries shed over the max concurrent queries, per action.",
+	}, []string{"server", "action"})
+
 	PluginEnabled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: plugin.Namespace,
 		Name:      "plugin_enabled",
 		Help:      "A metric that indicates whether a plugin is enabled on per server and zone basis.",
 	}, []string{"server", "zone", "name"})
+
+	SetupFailure = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Name:      "plugin_setup_failures_total",
+		Help:      "Counter of plugins that failed to set up with soft failures enabled, per zone.",
+	}, []string{"zone", "name"})
+
+	FeatureGate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Name:      "feature_gate_enabled",
+		Help:      "A metric that indicates whether a feature gate is enabled.",
+	}, []string{"name"})
 )
 
 const (
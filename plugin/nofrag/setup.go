@@ -0,0 +1,60 @@
+// Package nofrag implements a plugin that makes the server avoid fragmented UDP responses.
+package nofrag
+
+import (
+	"strconv"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	caddy.RegisterPlugin("nofrag", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	size, err := parse(c)
+	if err != nil {
+		return plugin.Error("nofrag", err)
+	}
+	dnsserver.GetConfig(c).MaxUDPSize = size
+	return nil
+}
+
+func parse(c *caddy.Controller) (int, error) {
+	size := defaultSize
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return 0, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		switch len(args) {
+		case 0:
+		case 1:
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return 0, err
+			}
+			if n < dns.MinMsgSize || n > dns.MaxMsgSize {
+				return 0, c.Errf("size must be between %d and %d: %d", dns.MinMsgSize, dns.MaxMsgSize, n)
+			}
+			size = n
+		default:
+			return 0, c.ArgErr()
+		}
+	}
+	return size, nil
+}
+
+// defaultSize is the default size of the largest UDP response. A response of this size fits in the
+// minimum IPv6 MTU of 1280 bytes, with the IPv6 and UDP headers.
+const defaultSize = 1232
@@ -0,0 +1,44 @@
+package nofrag
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  int
+	}{
+		{`nofrag`, false, 1232},
+		{`nofrag 1400`, false, 1400},
+		{`nofrag 512`, false, 512},
+		{`nofrag 511`, true, 0},
+		{`nofrag 65536`, true, 0},
+		{`nofrag large`, true, 0},
+		{`nofrag 1232 1400`, true, 0},
+		{"nofrag\nnofrag", true, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if size := dnsserver.GetConfig(c).MaxUDPSize; size != tc.expected {
+			t.Errorf("Test %d: expected size %d, got %d", i, tc.expected, size)
+		}
+	}
+}
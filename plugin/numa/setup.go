@@ -0,0 +1,75 @@
+// Package numa implements a plugin that shards the UDP queries by NUMA node.
+package numa
+
+import (
+	"strconv"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("numa", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	n, err := parse(c)
+	if err != nil {
+		return plugin.Error("numa", err)
+	}
+	dnsserver.GetConfig(c).NUMA = n
+	return nil
+}
+
+func parse(c *caddy.Controller) (*dnsserver.NUMA, error) {
+	n := &dnsserver.NUMA{}
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		// The nodes are only checked where they can be found, elsewhere the server logs the error.
+		nodes, nerr := dnsserver.NUMANodes()
+		for _, a := range c.RemainingArgs() {
+			id, err := strconv.Atoi(a)
+			if err != nil || id < 0 {
+				return nil, c.Errf("invalid NUMA node: %s", a)
+			}
+			if _, ok := nodes[id]; nerr == nil && !ok {
+				return nil, c.Errf("no NUMA node %d with CPUs", id)
+			}
+			n.Nodes = append(n.Nodes, id)
+		}
+		for c.NextBlock() {
+			switch c.Val() {
+			case "workers":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				w, err := strconv.Atoi(c.Val())
+				if err != nil || w < 1 {
+					return nil, c.Errf("invalid number of workers: %s", c.Val())
+				}
+				n.Workers = w
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+			case "incoming_cpu":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				n.IncomingCPU = true
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,64 @@
+package numa
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  dnsserver.NUMA
+	}{
+		{`numa`, false, dnsserver.NUMA{}},
+		{"numa {\nworkers 4\nincoming_cpu\n}", false, dnsserver.NUMA{Workers: 4, IncomingCPU: true}},
+		{`numa -1`, true, dnsserver.NUMA{}},
+		{`numa node0`, true, dnsserver.NUMA{}},
+		{`numa 1048576`, true, dnsserver.NUMA{}},
+		{"numa {\nworkers 0\n}", true, dnsserver.NUMA{}},
+		{"numa {\nworkers\n}", true, dnsserver.NUMA{}},
+		{"numa {\nincoming_cpu 1\n}", true, dnsserver.NUMA{}},
+		{"numa {\ncpus 1\n}", true, dnsserver.NUMA{}},
+		{"numa\nnuma", true, dnsserver.NUMA{}},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if n := dnsserver.GetConfig(c).NUMA; !reflect.DeepEqual(*n, tc.expected) {
+			t.Errorf("Test %d: expected %+v, got %+v", i, tc.expected, *n)
+		}
+	}
+}
+
+func TestSetupNodes(t *testing.T) {
+	nodes, err := dnsserver.NUMANodes()
+	if err != nil {
+		t.Skipf("NUMA nodes are not available: %s", err)
+	}
+	for id := range nodes {
+		c := caddy.NewTestController("dns", "numa "+strconv.Itoa(id))
+		if err := setup(c); err != nil {
+			t.Fatalf("Expected no error for node %d, got %s", id, err)
+		}
+		if n := dnsserver.GetConfig(c).NUMA; len(n.Nodes) != 1 || n.Nodes[0] != id {
+			t.Errorf("Expected node %d, got %v", id, n.Nodes)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Package order implements a plugin that changes the order of the plugins in a server block.
+package order
+
+import (
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("order", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	orders, err := parse(c)
+	if err != nil {
+		return plugin.Error("order", err)
+	}
+	config := dnsserver.GetConfig(c)
+	config.Order = append(config.Order, orders...)
+	return nil
+}
+
+func parse(c *caddy.Controller) ([]dnsserver.Order, error) {
+	var orders []dnsserver.Order
+	for c.Next() {
+		args := c.RemainingArgs()
+		if len(args) != 3 {
+			return nil, c.ArgErr()
+		}
+		o := dnsserver.Order{Name: args[0], Other: args[2]}
+		switch args[1] {
+		case "before":
+			o.Before = true
+		case "after":
+		default:
+			return nil, c.Errf("expected 'before' or 'after', got %q", args[1])
+		}
+		for _, name := range []string{o.Name, o.Other} {
+			if !isDirective(name) {
+				return nil, c.Errf("unknown plugin %q", name)
+			}
+		}
+		if o.Name == o.Other {
+			return nil, c.Errf("plugin %q can not be ordered relative to itself", o.Name)
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+func isDirective(name string) bool {
+	for _, d := range dnsserver.Directives {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
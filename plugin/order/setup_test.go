@@ -0,0 +1,54 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  []dnsserver.Order
+	}{
+		{`order cache before rewrite`, false, []dnsserver.Order{{Name: "cache", Before: true, Other: "rewrite"}}},
+		{`order log after forward`, false, []dnsserver.Order{{Name: "log", Other: "forward"}}},
+		{"order log after forward\norder cache before log", false, []dnsserver.Order{
+			{Name: "log", Other: "forward"},
+			{Name: "cache", Before: true, Other: "log"},
+		}},
+		{`order`, true, nil},
+		{`order cache rewrite`, true, nil},
+		{`order cache above rewrite`, true, nil},
+		{`order cache before nosuchplugin`, true, nil},
+		{`order cache before cache`, true, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got %s", i, err)
+			continue
+		}
+		order := dnsserver.GetConfig(c).Order
+		if len(order) != len(tc.expected) {
+			t.Errorf("Test %d: Expected %d orders, got %d", i, len(tc.expected), len(order))
+			continue
+		}
+		for j := range order {
+			if order[j] != tc.expected[j] {
+				t.Errorf("Test %d: Expected %v, got %v", i, tc.expected[j], order[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Package padding implements a plugin that pads the responses sent over encrypted transports.
+package padding
+
+import (
+	"context"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+)
+
+// Padding is a plugin that pads the responses to the queries of Transports with the EDNS0 Padding
+// option (RFC 7830), to a multiple of the block size, as the Block-Length Padding strategy of RFC
+// 8467 does. As RFC 7830 requires, only the queries with a Padding option get padded responses.
+type Padding struct {
+	Next       plugin.Handler
+	Transports []string
+
+	block int
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (p *Padding) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	if !p.transport(ctx) || !padded(r) {
+		return plugin.NextOrFailure(p.Name(), p.Next, ctx, w, r)
+	}
+	return plugin.NextOrFailure(p.Name(), p.Next, ctx, &ResponseWriter{ResponseWriter: w, block: p.block, ctx: ctx}, r)
+}
+
+// Name implements the plugin.Handler interface.
+func (p *Padding) Name() string { return "padding" }
+
+// transport returns true when the query in ctx arrived over one of the transports of p.
+func (p *Padding) transport(ctx context.Context) bool {
+	s, ok := ctx.Value(dnsserver.Key{}).(*dnsserver.Server)
+	if !ok {
+		return false
+	}
+	scheme := transport.DNS
+	if i := strings.Index(s.Addr, "://"); i > 0 {
+		scheme = s.Addr[:i]
+	}
+	for _, t := range p.Transports {
+		if t == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// padded returns true if r has a Padding option.
+func padded(r *dns.Msg) bool {
+	o := r.IsEdns0()
+	if o == nil {
+		return false
+	}
+	for _, opt := range o.Option {
+		if _, ok := opt.(*dns.EDNS0_PADDING); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ResponseWriter pads the responses to a multiple of block bytes.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	block int
+	ctx   context.Context
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	// The server adds its cookie after the plugins, so it is set first, not to spoil the padding.
+	dnsserver.SetCookie(w.ctx, res)
+	pad(res, w.block)
+	return w.ResponseWriter.WriteMsg(res)
+}
+
+// pad sets the Padding option of res so that it packs to a multiple of block bytes. A response
+// without OPT record, or signed with TSIG, is left alone, as is a response that would not fit in a
+// message once padded.
+func pad(res *dns.Msg, block int) {
+	o := res.IsEdns0()
+	if o == nil || res.IsTsig() != nil {
+		return
+	}
+	opts := o.Option[:0]
+	for _, opt := range o.Option {
+		if _, ok := opt.(*dns.EDNS0_PADDING); !ok {
+			opts = append(opts, opt)
+		}
+	}
+	o.Option = opts
+
+	n := res.Len() + 4 // the option code and length
+	size := (n + block - 1) / block * block
+	if size > dns.MaxMsgSize {
+		return
+	}
+	o.Option = append(o.Option, &dns.EDNS0_PADDING{Padding: make([]byte, size-n)})
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) Write(buf []byte) (int, error) {
+	log.Warning("ResponseWriter called with Write: not padding the response")
+	return w.ResponseWriter.Write(buf)
+}
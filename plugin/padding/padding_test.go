@@ -0,0 +1,137 @@
+package padding
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestPad(t *testing.T) {
+	for _, block := range []int{1, 128, 468} {
+		for _, answers := range []int{0, 1, 10, 50} {
+			m := new(dns.Msg)
+			m.SetQuestion("example.org.", dns.TypeA)
+			m.Response = true
+			m.Compress = true
+			for i := 0; i < answers; i++ {
+				m.Answer = append(m.Answer, test.A("example.org. IN A 192.0.2.1"))
+			}
+			m.SetEdns0(4096, false)
+			// A padding option from an earlier plugin is replaced.
+			o := m.IsEdns0()
+			o.Option = append(o.Option, &dns.EDNS0_PADDING{Padding: make([]byte, 3)})
+
+			pad(m, block)
+			b, err := m.Pack()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(b)%block != 0 {
+				t.Errorf("block %d, %d answers: expected a multiple of the block size, got %d bytes", block, answers, len(b))
+			}
+			if len(m.IsEdns0().Option) != 1 {
+				t.Errorf("block %d, %d answers: expected a single padding option, got %d", block, answers, len(m.IsEdns0().Option))
+			}
+		}
+	}
+
+	// Without an OPT record the response is left alone.
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	pad(m, 468)
+	if len(m.Extra) != 0 {
+		t.Errorf("Expected no OPT record, got %v", m.Extra)
+	}
+}
+
+func TestPadding(t *testing.T) {
+	next := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.SetEdns0(4096, false)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	p := &Padding{Next: next, Transports: []string{"tls"}, block: 468}
+
+	tests := []struct {
+		addr     string
+		padQuery bool
+		padded   bool
+	}{
+		{"tls://127.0.0.1:853", true, true},
+		{"tls://127.0.0.1:853", false, false},
+		{"dns://127.0.0.1:53", true, false},
+	}
+	for i, tc := range tests {
+		cfg := &dnsserver.Config{Zone: ".", ListenHosts: []string{"127.0.0.1"}}
+		s, err := dnsserver.NewServer(tc.addr, []*dnsserver.Config{cfg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.WithValue(context.TODO(), dnsserver.Key{}, s)
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		m.SetEdns0(4096, false)
+		if tc.padQuery {
+			o := m.IsEdns0()
+			o.Option = append(o.Option, &dns.EDNS0_PADDING{})
+		}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{TCP: true})
+		if _, err := p.ServeDNS(ctx, rec, m); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if padded := rec.Msg.Len()%468 == 0; padded != tc.padded {
+			t.Errorf("Test %d: expected padded %t, got a response of %d bytes", i, tc.padded, rec.Msg.Len())
+		}
+	}
+}
+
+func TestPaddingCookies(t *testing.T) {
+	cfg := &dnsserver.Config{Zone: ".", ListenHosts: []string{"127.0.0.1"}, Port: "53"}
+	cfg.Cookies = dnsserver.NewCookies(nil, 0, false)
+	cfg.AddPlugin(func(next plugin.Handler) plugin.Handler {
+		return &Padding{Next: next, Transports: []string{"dns"}, block: 468}
+	})
+	cfg.AddPlugin(func(next plugin.Handler) plugin.Handler {
+		return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.SetEdns0(4096, false)
+			w.WriteMsg(m)
+			return dns.RcodeSuccess, nil
+		})
+	})
+	s, err := dnsserver.NewServer("dns://127.0.0.1:53", []*dnsserver.Config{cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.SetEdns0(4096, false)
+	o := m.IsEdns0()
+	o.Option = append(o.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0102030405060708"}, &dns.EDNS0_PADDING{})
+	rec := dnstest.NewRecorder(&test.ResponseWriter{TCP: true})
+	s.ServeDNS(context.WithValue(context.TODO(), dnsserver.Key{}, s), rec, m)
+
+	cookie := false
+	for _, opt := range rec.Msg.IsEdns0().Option {
+		if _, ok := opt.(*dns.EDNS0_COOKIE); ok {
+			cookie = true
+		}
+	}
+	if !cookie {
+		t.Fatalf("Expected a server cookie in the response, got %v", rec.Msg)
+	}
+	if rec.Msg.Len()%468 != 0 {
+		t.Errorf("Expected the response with its cookie padded, got %d bytes", rec.Msg.Len())
+	}
+}
@@ -0,0 +1,94 @@
+package padding
+
+import (
+	"strconv"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/caddyserver/caddy"
+)
+
+var log = clog.NewWithPlugin("padding")
+
+func init() {
+	caddy.RegisterPlugin("padding", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	p, err := parse(c)
+	if err != nil {
+		return plugin.Error("padding", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		p.Next = next
+		return p
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Padding, error) {
+	p := &Padding{
+		Transports: []string{transport.TLS, transport.HTTPS, transport.GRPC},
+		block:      defaultBlock,
+	}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		switch len(args) {
+		case 0:
+		case 2:
+			if args[0] != "block" {
+				return nil, c.Errf("unknown property '%s'", args[0])
+			}
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 || n > maxBlock {
+				return nil, c.Errf("block size must be between 1 and %d: %s", maxBlock, args[1])
+			}
+			p.block = n
+		default:
+			return nil, c.ArgErr()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "transports":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, t := range args {
+					switch t {
+					case transport.DNS, transport.TLS, transport.GRPC, transport.HTTPS, transport.HTTP, transport.QUIC, transport.H3, transport.DTLS:
+					default:
+						return nil, c.Errf("unknown transport '%s'", t)
+					}
+				}
+				p.Transports = args
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return p, nil
+}
+
+const (
+	// defaultBlock is the block size RFC 8467 recommends for the responses.
+	defaultBlock = 468
+	// maxBlock is the largest block size.
+	maxBlock = 4096
+)
@@ -0,0 +1,52 @@
+package padding
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input      string
+		shouldErr  bool
+		block      int
+		transports []string
+	}{
+		{`padding`, false, 468, []string{"tls", "https", "grpc"}},
+		{`padding block 128`, false, 128, []string{"tls", "https", "grpc"}},
+		{"padding {\ntransports tls\n}", false, 468, []string{"tls"}},
+		{"padding block 256 {\ntransports https http\n}", false, 256, []string{"https", "http"}},
+		{`padding block`, true, 0, nil},
+		{`padding block 0`, true, 0, nil},
+		{`padding block 4097`, true, 0, nil},
+		{`padding size 128`, true, 0, nil},
+		{`padding block 128 256`, true, 0, nil},
+		{"padding {\ntransports\n}", true, 0, nil},
+		{"padding {\ntransports udp\n}", true, 0, nil},
+		{"padding {\nblah\n}", true, 0, nil},
+		{"padding\npadding", true, 0, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		p, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if p.block != tc.block {
+			t.Errorf("Test %d: expected block %d, got %d", i, tc.block, p.block)
+		}
+		if !reflect.DeepEqual(p.Transports, tc.transports) {
+			t.Errorf("Test %d: expected transports %v, got %v", i, tc.transports, p.Transports)
+		}
+	}
+}
@@ -1,11 +1,14 @@
-// Package cache implements a cache. The cache hold 256 shards, each shard
-// holds a cache: a map with a mutex. There is no fancy expunge algorithm, it
-// just randomly evicts elements when it gets full.
+// Package cache implements a cache. The cache holds a power of two number of shards, up to 256 and
+// more on machines with many CPUs, each shard holds a cache: a hash table that is read without locks
+// and a mutex for the writes. There is no fancy expunge algorithm, it just randomly evicts elements
+// when it gets full.
 package cache
 
 import (
 	"hash/fnv"
+	"runtime"
 	"sync"
+	"sync/atomic"
 )
 
 // Hash returns the FNV hash of what.
@@ -17,49 +20,52 @@ func Hash(what []byte) uint64 {
 
 // Cache is cache.
 type Cache struct {
-	shards [shardSize]*shard
+	shards []*shard
+	mask   uint64 // len(shards)-1, to pick the shard of a key
 }
 
-// shard is a cache with random eviction.
-type shard struct {
-	items map[uint64]interface{}
-	size  int
-
-	sync.RWMutex
-}
-
-// New returns a new cache.
+// New returns a new cache holding at most size elements.
 func New(size int) *Cache {
-	ssize := size / shardSize
-	if ssize < 4 {
-		ssize = 4
-	}
-
-	c := &Cache{}
+	n := shardCount(runtime.GOMAXPROCS(0), size)
+	c := &Cache{shards: make([]*shard, n), mask: uint64(n - 1)}
 
 	// Initialize all the shards
-	for i := 0; i < shardSize; i++ {
-		c.shards[i] = newShard(ssize)
+	for i := range c.shards {
+		c.shards[i] = newShard(shardCap(size, n, i))
 	}
 	return c
 }
 
 // Add adds a new element to the cache. If the element already exists it is overwritten.
 func (c *Cache) Add(key uint64, el interface{}) {
-	shard := key & (shardSize - 1)
-	c.shards[shard].Add(key, el)
+	c.shards[key&c.mask].Add(key, el)
 }
 
 // Get looks up element index under key.
 func (c *Cache) Get(key uint64) (interface{}, bool) {
-	shard := key & (shardSize - 1)
-	return c.shards[shard].Get(key)
+	return c.shards[key&c.mask].Get(key)
 }
 
 // Remove removes the element indexed with key.
 func (c *Cache) Remove(key uint64) {
-	shard := key & (shardSize - 1)
-	c.shards[shard].Remove(key)
+	c.shards[key&c.mask].Remove(key)
+}
+
+// Resize changes the size of the cache, randomly evicting the elements that no longer fit.
+func (c *Cache) Resize(size int) {
+	for i, s := range c.shards {
+		s.Resize(shardCap(size, len(c.shards), i))
+	}
+}
+
+// Walk calls f for the elements in the cache, until f returns false. The elements added or removed
+// during the walk may or may not be seen.
+func (c *Cache) Walk(f func(key uint64, el interface{}) bool) {
+	for _, s := range c.shards {
+		if !s.Walk(f) {
+			return
+		}
+	}
 }
 
 // Len returns the number of elements in the cache.
@@ -71,55 +77,240 @@ func (c *Cache) Len() int {
 	return l
 }
 
+// shardCount returns the number of shards of a cache with size for procs CPUs: a power of two, so a
+// shard is picked with a mask. It is shardSize, more on the machines with more than
+// shardSize/shardsPerProc CPUs, and less when a shard would hold fewer than minShardCap elements.
+func shardCount(procs, size int) int {
+	n := shardSize
+	for n < procs*shardsPerProc {
+		n <<= 1
+	}
+	for n > 1 && size/n < minShardCap {
+		n >>= 1
+	}
+	return n
+}
+
+// shardCap returns the size of the i-th of the n shards of a cache with size. The remainder of size
+// divided by n goes to the first shards, so the sizes of the shards add up to size.
+func shardCap(size, n, i int) int {
+	ssize := size / n
+	if i < size%n {
+		ssize++
+	}
+	return ssize
+}
+
+// shard is a cache with random eviction. Its elements are in a hash table with open addressing, whose
+// slots point to immutable entries: Get loads the table and the slots atomically and never locks. The
+// writes are serialized by the mutex, they replace the entries and the table, never change them.
+type shard struct {
+	table atomic.Pointer[table]
+	n     int64 // the number of elements, read atomically by Len
+
+	sync.Mutex
+	size int
+	rnd  uint64 // the xorshift state picking the evicted element
+}
+
+// table is a hash table with linear probing. It always has an empty slot, which ends the probes.
+type table struct {
+	slots []atomic.Pointer[entry]
+	shift uint // 64 - log2(len(slots)), to pick the slot of a key
+	used  int  // the slots that are not empty, with an entry or a tombstone; guarded by the mutex
+}
+
+// entry is an element of the cache with its key.
+type entry struct {
+	key uint64
+	el  interface{}
+}
+
+// tombstone marks the slot of a removed entry, to keep probing past it.
+var tombstone = &entry{}
+
 // newShard returns a new shard with size.
-func newShard(size int) *shard { return &shard{items: make(map[uint64]interface{}), size: size} }
+func newShard(size int) *shard {
+	s := &shard{size: size, rnd: 0x9E3779B97F4A7C15}
+	s.table.Store(newTable(minTableSize))
+	return s
+}
+
+// newTable returns an empty table with n slots, a power of two.
+func newTable(n int) *table {
+	shift := uint(64)
+	for i := n; i > 1; i >>= 1 {
+		shift--
+	}
+	return &table{slots: make([]atomic.Pointer[entry], n), shift: shift}
+}
+
+// slot returns the first slot to probe for key. The key is multiplied by 2^64/phi, as the low bits of
+// a key have already picked the shard.
+func (t *table) slot(key uint64) int { return int((key * 0x9E3779B97F4A7C15) >> t.shift) }
 
 // Add adds element indexed by key into the cache. Any existing element is overwritten
 func (s *shard) Add(key uint64, el interface{}) {
 	s.Lock()
-	if len(s.items) >= s.size {
-		if _, ok := s.items[key]; !ok {
-			for k := range s.items {
-				delete(s.items, k)
-				break
+	defer s.Unlock()
+	if s.size == 0 {
+		return
+	}
+
+	t := s.table.Load()
+	mask := len(t.slots) - 1
+	free := -1
+	i := t.slot(key)
+	for ; ; i = (i + 1) & mask {
+		e := t.slots[i].Load()
+		if e == nil {
+			break
+		}
+		if e == tombstone {
+			if free < 0 {
+				free = i
 			}
+			continue
+		}
+		if e.key == key {
+			t.slots[i].Store(&entry{key: key, el: el})
+			return
 		}
 	}
-	s.items[key] = el
-	s.Unlock()
+
+	if s.Len() >= s.size {
+		s.evict(t)
+	}
+	if free < 0 {
+		free = i
+		t.used++
+	}
+	t.slots[free].Store(&entry{key: key, el: el})
+	atomic.AddInt64(&s.n, 1)
+
+	// Keep at least a quarter of the slots empty, so the probes are short. The table grows when the
+	// entries take half of it, otherwise the tombstones are dropped.
+	if t.used > len(t.slots)/4*3 {
+		n := len(t.slots)
+		if s.Len() > n/2 {
+			n *= 2
+		}
+		s.rehash(t, n)
+	}
 }
 
 // Remove removes the element indexed by key from the cache.
 func (s *shard) Remove(key uint64) {
 	s.Lock()
-	delete(s.items, key)
+	t := s.table.Load()
+	mask := len(t.slots) - 1
+	for i := t.slot(key); ; i = (i + 1) & mask {
+		e := t.slots[i].Load()
+		if e == nil {
+			break
+		}
+		if e != tombstone && e.key == key {
+			t.slots[i].Store(tombstone)
+			atomic.AddInt64(&s.n, -1)
+			break
+		}
+	}
 	s.Unlock()
 }
 
 // Evict removes a random element from the cache.
 func (s *shard) Evict() {
 	s.Lock()
-	for k := range s.items {
-		delete(s.items, k)
-		break
+	s.evict(s.table.Load())
+	s.Unlock()
+}
+
+// Resize changes the size of the shard, randomly evicting the elements that no longer fit.
+func (s *shard) Resize(size int) {
+	s.Lock()
+	s.size = size
+	t := s.table.Load()
+	for s.Len() > size {
+		s.evict(t)
 	}
 	s.Unlock()
 }
 
 // Get looks up the element indexed under key.
 func (s *shard) Get(key uint64) (interface{}, bool) {
-	s.RLock()
-	el, found := s.items[key]
-	s.RUnlock()
-	return el, found
+	t := s.table.Load()
+	mask := len(t.slots) - 1
+	for i := t.slot(key); ; i = (i + 1) & mask {
+		e := t.slots[i].Load()
+		if e == nil {
+			return nil, false
+		}
+		if e != tombstone && e.key == key {
+			return e.el, true
+		}
+	}
+}
+
+// Walk calls f for the elements in the shard, and returns false when f does.
+func (s *shard) Walk(f func(key uint64, el interface{}) bool) bool {
+	t := s.table.Load()
+	for i := range t.slots {
+		e := t.slots[i].Load()
+		if e == nil || e == tombstone {
+			continue
+		}
+		if !f(e.key, e.el) {
+			return false
+		}
+	}
+	return true
 }
 
 // Len returns the current length of the cache.
-func (s *shard) Len() int {
-	s.RLock()
-	l := len(s.items)
-	s.RUnlock()
-	return l
+func (s *shard) Len() int { return int(atomic.LoadInt64(&s.n)) }
+
+// evict removes a random element of t, the table of s, which must be locked.
+func (s *shard) evict(t *table) {
+	if s.Len() == 0 {
+		return
+	}
+	// xorshift64, random enough to pick the slot to start from.
+	s.rnd ^= s.rnd << 13
+	s.rnd ^= s.rnd >> 7
+	s.rnd ^= s.rnd << 17
+	mask := len(t.slots) - 1
+	for i := int(s.rnd) & mask; ; i = (i + 1) & mask {
+		if e := t.slots[i].Load(); e != nil && e != tombstone {
+			t.slots[i].Store(tombstone)
+			atomic.AddInt64(&s.n, -1)
+			return
+		}
+	}
 }
 
-const shardSize = 256
+// rehash replaces t, the table of s, which must be locked, with a table of n slots holding its
+// entries. The readers still probing t find its entries, which are shared by both tables.
+func (s *shard) rehash(t *table, n int) {
+	nt := newTable(n)
+	mask := n - 1
+	for i := range t.slots {
+		e := t.slots[i].Load()
+		if e == nil || e == tombstone {
+			continue
+		}
+		j := nt.slot(e.key)
+		for nt.slots[j].Load() != nil {
+			j = (j + 1) & mask
+		}
+		nt.slots[j].Store(e)
+		nt.used++
+	}
+	s.table.Store(nt)
+}
+
+const (
+	shardSize     = 256 // the number of shards, unless there are many CPUs or few elements
+	shardsPerProc = 8   // the shards per CPU on the machines with more than shardSize/shardsPerProc
+	minShardCap   = 4   // the least elements a shard should hold, unless the cache holds fewer
+	minTableSize  = 8   // the slots of the table of a new shard
+)
@@ -53,6 +53,47 @@ func TestCacheSharding(t *testing.T) {
 	}
 }
 
+func TestShardCount(t *testing.T) {
+	tests := []struct {
+		procs, size, shards int
+	}{
+		{1, 10000, shardSize},
+		{32, 10000, shardSize},
+		{33, 10000, 2 * shardSize},
+		{64, 10000, 2 * shardSize},
+		{96, 10000, 4 * shardSize},
+		{96, 1000, shardSize / 2},
+		{1, 8, 2},
+		{1, 1, 1},
+		{1, 0, 1},
+	}
+	for _, tc := range tests {
+		if n := shardCount(tc.procs, tc.size); n != tc.shards {
+			t.Errorf("Expected %d shards for %d CPUs and size %d, got %d", tc.shards, tc.procs, tc.size, n)
+		}
+	}
+}
+
+func TestCacheCapacity(t *testing.T) {
+	for _, size := range []int{1, 3, 10, 257, 1000, 10000} {
+		c := New(size)
+		for i := 0; i < 4*size; i++ {
+			c.Add(uint64(i), 1)
+		}
+		if l := c.Len(); l != size {
+			t.Errorf("Expected %d elements in a cache of size %d, got %d", size, size, l)
+		}
+
+		c.Resize(size / 3)
+		for i := 0; i < 4*size; i++ {
+			c.Add(uint64(i), 1)
+		}
+		if l := c.Len(); l != size/3 {
+			t.Errorf("Expected %d elements in a cache resized to %d, got %d", size/3, size/3, l)
+		}
+	}
+}
+
 func BenchmarkCache(b *testing.B) {
 	b.ReportAllocs()
 
@@ -62,3 +103,88 @@ func BenchmarkCache(b *testing.B) {
 		c.Get(1)
 	}
 }
+
+func BenchmarkCacheParallelGet(b *testing.B) {
+	const N = 10000
+	c := New(N)
+	for i := uint64(0); i < N; i++ {
+		c.Add(i, 1)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := uint64(0); pb.Next(); i++ {
+			c.Get(i * 7919 % N)
+		}
+	})
+}
+
+func BenchmarkCacheParallelMixed(b *testing.B) {
+	const N = 10000
+	c := New(N)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := uint64(0); pb.Next(); i++ {
+			k := i * 7919 % (2 * N)
+			if i%10 == 0 {
+				c.Add(k, 1)
+				continue
+			}
+			c.Get(k)
+		}
+	})
+}
+
+func TestCacheResize(t *testing.T) {
+	const N = shardSize * 8
+	c := New(N)
+	for i := 0; i < N; i++ {
+		c.Add(uint64(i), 1)
+	}
+
+	c.Resize(N / 2)
+	if l := c.Len(); l != N/2 {
+		t.Fatalf("Cache size should be %d after shrinking, got %d", N/2, l)
+	}
+	for i := 0; i < N; i++ {
+		c.Add(uint64(i), 1)
+	}
+	if l := c.Len(); l != N/2 {
+		t.Fatalf("Cache size should stay %d, got %d", N/2, l)
+	}
+
+	c.Resize(N)
+	for i := 0; i < N; i++ {
+		c.Add(uint64(i), 1)
+	}
+	if l := c.Len(); l != N {
+		t.Fatalf("Cache size should be %d after growing, got %d", N, l)
+	}
+}
+
+func TestCacheWalk(t *testing.T) {
+	c := New(shardSize * 4)
+	for i := 0; i < 10; i++ {
+		c.Add(uint64(i), i)
+	}
+
+	seen := 0
+	c.Walk(func(key uint64, el interface{}) bool {
+		if int(key) != el.(int) {
+			t.Errorf("Expected element %d under key %d, got %v", key, key, el)
+		}
+		seen++
+		return true
+	})
+	if seen != 10 {
+		t.Errorf("Expected to walk 10 elements, walked %d", seen)
+	}
+
+	seen = 0
+	c.Walk(func(key uint64, el interface{}) bool {
+		seen++
+		return seen < 3
+	})
+	if seen != 3 {
+		t.Errorf("Expected the walk to stop after 3 elements, walked %d", seen)
+	}
+}
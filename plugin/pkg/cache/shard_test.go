@@ -116,7 +116,55 @@ func TestShardEvictParallel(t *testing.T) {
 	}
 }
 
+func TestShardRemove(t *testing.T) {
+	const size = 64
+	s := newShard(size)
+
+	// Removing leaves tombstones in the table, which must neither hide the other keys nor fill it.
+	for i := uint64(0); i < 100*size; i++ {
+		s.Add(i, i)
+		if i >= size/2 {
+			s.Remove(i - size/2)
+		}
+		if el, found := s.Get(i); !found || el.(uint64) != i {
+			t.Fatalf("Failed to find inserted record %d", i)
+		}
+	}
+	if l := s.Len(); l != size/2 {
+		t.Fatalf("Shard size should %d, got %d", size/2, l)
+	}
+	if l := len(s.table.Load().slots); l > 4*size {
+		t.Fatalf("Expected at most %d slots in the table, got %d", 4*size, l)
+	}
+}
+
+func TestShardGetParallel(t *testing.T) {
+	s := newShard(shardSize)
+	done := make(chan struct{})
+	go func() {
+		for i := uint64(0); i < 16*shardSize; i++ {
+			s.Add(i, i)
+			s.Remove(i / 2)
+		}
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		for i := uint64(0); i < shardSize; i++ {
+			if el, found := s.Get(i); found && el.(uint64) != i {
+				t.Fatalf("Expected element %d under key %d, got %v", i, i, el)
+			}
+		}
+	}
+}
+
 func BenchmarkShard(b *testing.B) {
+	b.ReportAllocs()
+
 	s := newShard(shardSize)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -0,0 +1,59 @@
+// Package changes lets plugins tell that the data they serve changed, to the parts of CoreDNS that
+// follow the data, like DNS push notifications.
+//
+// A plugin calls Notify with the zone whose data changed, e.g. after reloading a zone file or when a
+// backend it watches reports a change. Notify is cheap when nothing watches.
+package changes
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type watcher struct {
+	f func(zone string)
+}
+
+var (
+	mu       sync.Mutex
+	watchers []*watcher
+	n        int32 // number of watchers, accessed atomically
+)
+
+// Notify tells the watchers that the data of zone changed. The watchers are called in the calling
+// goroutine, so they must not block.
+func Notify(zone string) {
+	if atomic.LoadInt32(&n) == 0 {
+		return
+	}
+	mu.Lock()
+	ws := watchers
+	mu.Unlock()
+	for _, w := range ws {
+		w.f(zone)
+	}
+}
+
+// Watch calls f with the zone for every Notify, until stop is called.
+func Watch(f func(zone string)) (stop func()) {
+	w := &watcher{f: f}
+	mu.Lock()
+	watchers = append(watchers[:len(watchers):len(watchers)], w)
+	atomic.StoreInt32(&n, int32(len(watchers)))
+	mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			for i := range watchers {
+				if watchers[i] == w {
+					watchers = append(watchers[:i:i], watchers[i+1:]...)
+					break
+				}
+			}
+			atomic.StoreInt32(&n, int32(len(watchers)))
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package changes
+
+import "testing"
+
+func TestWatch(t *testing.T) {
+	var got []string
+	stop := Watch(func(zone string) { got = append(got, zone) })
+	var other []string
+	stopOther := Watch(func(zone string) { other = append(other, zone) })
+
+	Notify("example.org.")
+	stop()
+	stop() // stopping twice is fine
+	Notify("example.net.")
+	stopOther()
+	Notify("example.com.")
+
+	if len(got) != 1 || got[0] != "example.org." {
+		t.Errorf("Expected a single change of example.org., got %v", got)
+	}
+	if len(other) != 2 || other[1] != "example.net." {
+		t.Errorf("Expected changes of example.org. and example.net., got %v", other)
+	}
+}
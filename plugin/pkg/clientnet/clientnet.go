@@ -0,0 +1,40 @@
+// Package clientnet groups clients by network, for the plugins that limit them per network.
+package clientnet
+
+import "net"
+
+// Parse parses a network in CIDR notation, or an address, which is the network of that address only.
+func Parse(s string) (*net.IPNet, error) {
+	_, n, err := net.ParseCIDR(s)
+	if err == nil {
+		return n, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, err
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// Contains returns true if ip is in one of the networks.
+func Contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Mask returns the network of the client ip, the addresses sharing its first v4prefix bits for an
+// IPv4 address, or its first v6prefix bits for an IPv6 one.
+func Mask(ip net.IP, v4prefix, v6prefix int) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(v4prefix, 8*net.IPv4len))
+	}
+	return ip.Mask(net.CIDRMask(v6prefix, 8*net.IPv6len))
+}
@@ -0,0 +1,76 @@
+package clientnet
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  string
+		shouldErr bool
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8", false},
+		{"10.1.2.3/8", "10.0.0.0/8", false},
+		{"192.0.2.1", "192.0.2.1/32", false},
+		{"2001:db8::/32", "2001:db8::/32", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"example.org", "", true},
+		{"10.0.0.0/33", "", true},
+	}
+	for i, tc := range tests {
+		n, err := Parse(tc.input)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected an error for %q", i, tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if n.String() != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, n)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	var networks []*net.IPNet
+	for _, s := range []string{"10.0.0.0/8", "2001:db8::1"} {
+		n, _ := Parse(s)
+		networks = append(networks, n)
+	}
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"10.1.2.3", true},
+		{"11.0.0.1", false},
+		{"2001:db8::1", true},
+		{"2001:db8::2", false},
+	}
+	for i, tc := range tests {
+		if x := Contains(networks, net.ParseIP(tc.ip)); x != tc.expected {
+			t.Errorf("Test %d: expected %t for %s, got %t", i, tc.expected, tc.ip, x)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"192.0.2.77", "192.0.2.0"},
+		{"::ffff:192.0.2.77", "192.0.2.0"},
+		{"2001:db8:1:2:3::1", "2001:db8:1::"},
+	}
+	for i, tc := range tests {
+		if x := Mask(net.ParseIP(tc.ip), 24, 48); x.String() != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, x)
+		}
+	}
+}
@@ -38,10 +38,12 @@ func NewRecorder(w dns.ResponseWriter) *Recorder {
 func (r *Recorder) WriteMsg(res *dns.Msg) error {
 	r.Rcode = res.Rcode
 	// We may get called multiple times (axfr for instance).
-	// Save the last message, but add the sizes.
-	r.Len += res.Len()
+	// Save the last message, but add the sizes. The size is taken after writing, because
+	// the writer may truncate res to fit the client's buffer.
 	r.Msg = res
-	return r.ResponseWriter.WriteMsg(res)
+	err := r.ResponseWriter.WriteMsg(res)
+	r.Len += res.Len()
+	return err
 }
 
 // Write is a wrapper that records the length of the message that gets written.
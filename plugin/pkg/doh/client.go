@@ -0,0 +1,273 @@
+package doh
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+)
+
+// Client sends queries to a DNS-over-HTTPS server. The connections to the server are pooled and
+// reused, over HTTP/2 when the server supports it, or over HTTP/3 after SetHTTP3. A Client is safe
+// for concurrent use, create one per server and keep it. For DNS-over-QUIC see the doq package.
+type Client struct {
+	url     string
+	method  string
+	retries int
+
+	transport *http.Transport
+	h3        *http3.RoundTripper // replaces transport after SetHTTP3
+	resolver  *net.Resolver       // resolves the name of the server for h3, nil for the system resolver
+	client    *http.Client
+}
+
+// Default values of a Client.
+const (
+	defaultTimeout = 5 * time.Second
+	defaultRetries = 1
+)
+
+// NewClient returns a client sending queries to the DoH server at url, like
+// "https://dns.example.org/dns-query". When url has no path, Path is used. The TLS connections are
+// configured with tc, which may be nil.
+func NewClient(url string, tc *tls.Config) (*Client, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("not an https URL: %s", url)
+	}
+	if !strings.Contains(url[len("https://"):], "/") {
+		url += Path
+	}
+	if tc == nil {
+		tc = new(tls.Config)
+	}
+	tr := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   defaultTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: defaultTimeout,
+		TLSClientConfig:     tc.Clone(),
+		MaxIdleConnsPerHost: 25,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	// With a TLS config of our own the transport only does HTTP/2 when configured for it.
+	if err := http2.ConfigureTransport(tr); err != nil {
+		return nil, err
+	}
+	return &Client{
+		url:       url,
+		method:    http.MethodPost,
+		retries:   defaultRetries,
+		transport: tr,
+		client:    &http.Client{Transport: tr, Timeout: defaultTimeout},
+	}, nil
+}
+
+// SetMethod sets the HTTP method of the queries, http.MethodPost (the default) or http.MethodGet.
+// GET queries can be cached by HTTP caches.
+func (c *Client) SetMethod(method string) error {
+	if method != http.MethodGet && method != http.MethodPost {
+		return fmt.Errorf("method not allowed: %s", method)
+	}
+	c.method = method
+	return nil
+}
+
+// SetRetries sets how many times a query is sent again when it fails before a response is
+// received, for instance because the server closed an idle connection. The default is 1.
+func (c *Client) SetRetries(n int) { c.retries = n }
+
+// SetBootstrap makes c resolve the host name of the server with the DNS servers at addrs, as
+// "host:port", tried in turn, instead of the system resolver. This is needed when the system
+// resolver is the server itself, or forwards to it.
+func (c *Client) SetBootstrap(addrs []string) {
+	var i uint32
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			n := atomic.AddUint32(&i, 1)
+			return d.DialContext(ctx, network, addrs[int(n)%len(addrs)])
+		},
+	}
+	c.transport.DialContext = (&net.Dialer{
+		Timeout:   defaultTimeout,
+		KeepAlive: 30 * time.Second,
+		Resolver:  r,
+	}).DialContext
+	c.resolver = r
+}
+
+// SetHTTP3 makes c send the queries over HTTP/3, on QUIC connections, instead of over HTTP/2 or
+// HTTP/1.1 on TCP connections. It must be called before the first query.
+func (c *Client) SetHTTP3() {
+	c.h3 = &http3.RoundTripper{TLSClientConfig: c.transport.TLSClientConfig.Clone(), Dial: c.dialQUIC}
+	c.client.Transport = c.h3
+}
+
+// dialQUIC dials the QUIC connection of an HTTP/3 request to addr, as "host:port".
+func (c *Client) dialQUIC(ctx context.Context, addr string, tc *tls.Config, qc *quic.Config) (quic.EarlyConnection, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	r := c.resolver
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	ips, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses for %s", host)
+	}
+	return quic.DialAddrEarly(ctx, net.JoinHostPort(ips[0].String(), port), tc, qc)
+}
+
+// SetTimeout sets the timeout of a query, including the retries. The default is 5s.
+func (c *Client) SetTimeout(d time.Duration) { c.client.Timeout = d }
+
+// Exchange sends m to the server and returns the response. The query is sent with ID 0, as RFC
+// 8484 recommends so it can be cached; the response gets the ID of m.
+func (c *Client) Exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	id := m.Id
+	m.Id = 0
+	buf, err := m.Pack()
+	m.Id = id
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; ; i++ {
+		r, err := c.exchange(ctx, buf)
+		if err == nil {
+			r.Id = id
+			return r, nil
+		}
+		if i >= c.retries || ctx.Err() != nil || !retry(err) {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) exchange(ctx context.Context, buf []byte) (*dns.Msg, error) {
+	var (
+		req *http.Request
+		err error
+	)
+	if c.method == http.MethodGet {
+		sep := "?"
+		if strings.Contains(c.url, "?") {
+			sep = "&"
+		}
+		req, err = http.NewRequest(http.MethodGet, c.url+sep+"dns="+base64.RawURLEncoding.EncodeToString(buf), nil)
+	} else {
+		req, err = http.NewRequest(http.MethodPost, c.url, bytes.NewReader(buf))
+		if err == nil {
+			req.Header.Set("content-type", MimeType)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("accept", MimeType)
+	// DNS queries are idempotent: this lets the transport resend a POST on a connection that the
+	// server closed while idle. The header is not sent.
+	req.Header["Idempotency-Key"] = nil
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, dns.MaxMsgSize))
+		return nil, &StatusError{Code: resp.StatusCode}
+	}
+	if ct := resp.Header.Get("content-type"); ct != "" && !strings.HasPrefix(ct, MimeType) {
+		return nil, fmt.Errorf("unexpected content type: %s", ct)
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > dns.MaxMsgSize {
+		return nil, fmt.Errorf("response larger than %d bytes", dns.MaxMsgSize)
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(b); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Check sends a query for the root NS records to the server, and returns an error when no response
+// is received. It is meant for health checks: any rcode is a response.
+func (c *Client) Check(ctx context.Context) error {
+	m := new(dns.Msg)
+	m.SetQuestion(".", dns.TypeNS)
+	_, err := c.Exchange(ctx, m)
+	return err
+}
+
+// Close closes the idle connections to the server. The client can still be used afterwards.
+func (c *Client) Close() {
+	c.transport.CloseIdleConnections()
+	if c.h3 != nil {
+		c.h3.CloseIdleConnections()
+	}
+}
+
+// StatusError is returned by Client.Exchange when the server responds with an HTTP status other than
+// 200 OK.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP status %d %s", e.Code, http.StatusText(e.Code))
+}
+
+// retry returns true when the query failing with err may be sent again. The server is busy or the
+// request did not get through; a timeout or a response that can't be parsed are final.
+func retry(err error) bool {
+	if se, ok := err.(*StatusError); ok {
+		return se.Code == http.StatusBadGateway || se.Code == http.StatusServiceUnavailable
+	}
+	if ue, ok := err.(*url.Error); ok {
+		if ue.Timeout() {
+			return false
+		}
+		err = ue.Err
+	}
+	if ne, ok := err.(net.Error); ok && ne.Temporary() {
+		return true
+	}
+	// The QUIC connection of HTTP/3 was closed by the server.
+	var aerr *quic.ApplicationError
+	if errors.As(err, &aerr) {
+		return aerr.Remote
+	}
+	// The connection was closed by the server, or the HTTP/2 stream was refused or reset.
+	s := err.Error()
+	return strings.Contains(s, "EOF") || strings.Contains(s, "connection reset") ||
+		strings.Contains(s, "GOAWAY") || strings.Contains(s, "REFUSED_STREAM")
+}
@@ -0,0 +1,260 @@
+package doh
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+)
+
+// newTestServer returns a DoH server answering with NXDOMAIN, over HTTP/2, and a TLS config
+// trusting it. The handler is called before answering and can write a response of its own.
+func newTestServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request) bool) (*httptest.Server, *tls.Config) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler != nil && handler(w, r) {
+			return
+		}
+		if r.URL.Path != Path {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		m, err := RequestToMsg(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if m.Id != 0 {
+			http.Error(w, "ID not 0", http.StatusBadRequest)
+			return
+		}
+		m.SetRcode(m, dns.RcodeNameError)
+		buf, _ := m.Pack()
+		w.Header().Set("content-type", MimeType)
+		w.Write(buf)
+	}))
+	if err := http2.ConfigureServer(srv.Config, nil); err != nil {
+		t.Fatal(err)
+	}
+	srv.TLS = srv.Config.TLSConfig
+	srv.StartTLS()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return srv, &tls.Config{RootCAs: pool}
+}
+
+func TestClientExchange(t *testing.T) {
+	var h2 int32
+	srv, tc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.ProtoMajor == 2 {
+			atomic.AddInt32(&h2, 1)
+		}
+		return false
+	})
+	defer srv.Close()
+
+	for _, method := range []string{http.MethodPost, http.MethodGet} {
+		c, err := NewClient(srv.URL, tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.SetMethod(method); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			m := new(dns.Msg)
+			m.SetQuestion("example.org.", dns.TypeA)
+			r, err := c.Exchange(context.Background(), m)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got %s", method, err)
+			}
+			if r.Id != m.Id {
+				t.Errorf("%s: expected ID %d, got %d", method, m.Id, r.Id)
+			}
+			if r.Rcode != dns.RcodeNameError {
+				t.Errorf("%s: expected NXDOMAIN, got %s", method, dns.RcodeToString[r.Rcode])
+			}
+		}
+		c.Close()
+	}
+	if x := atomic.LoadInt32(&h2); x != 6 {
+		t.Errorf("expected 6 queries over HTTP/2, got %d", x)
+	}
+}
+
+func TestClientHTTP3(t *testing.T) {
+	// The certificate of the HTTP/2 test server is used for the HTTP/3 one.
+	srv, tc := newTestServer(t, nil)
+	defer srv.Close()
+
+	var h3 int32
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &http3.Server{
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: srv.TLS.Certificates}),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ProtoMajor == 3 {
+				atomic.AddInt32(&h3, 1)
+			}
+			m, err := RequestToMsg(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			m.SetRcode(m, dns.RcodeNameError)
+			buf, _ := m.Pack()
+			w.Header().Set("content-type", MimeType)
+			w.Write(buf)
+		}),
+	}
+	go s.Serve(pc)
+	defer s.Close()
+
+	c, err := NewClient("https://"+pc.LocalAddr().String(), tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetHTTP3()
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		r, err := c.Exchange(context.Background(), m)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if r.Id != m.Id || r.Rcode != dns.RcodeNameError {
+			t.Errorf("expected NXDOMAIN with ID %d, got %s with ID %d", m.Id, dns.RcodeToString[r.Rcode], r.Id)
+		}
+	}
+	if x := atomic.LoadInt32(&h3); x != 3 {
+		t.Errorf("expected 3 queries over HTTP/3, got %d", x)
+	}
+}
+
+func TestClientBootstrap(t *testing.T) {
+	srv, tc := newTestServer(t, nil)
+	defer srv.Close()
+
+	// The bootstrap server resolves the name of the certificate of the test server.
+	var lookups int32
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bootstrap := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		atomic.AddInt32(&lookups, 1)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.Question[0].Qtype == dns.TypeA {
+			rr, _ := dns.NewRR(r.Question[0].Name + " 60 IN A 127.0.0.1")
+			m.Answer = append(m.Answer, rr)
+		}
+		w.WriteMsg(m)
+	})}
+	started := make(chan struct{})
+	bootstrap.NotifyStartedFunc = func() { close(started) }
+	go bootstrap.ActivateAndServe()
+	<-started
+	defer bootstrap.Shutdown()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	c, err := NewClient("https://example.com:"+port, tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetBootstrap([]string{pc.LocalAddr().String()})
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if _, err := c.Exchange(context.Background(), m); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if atomic.LoadInt32(&lookups) == 0 {
+		t.Error("expected the server name to be resolved by the bootstrap server")
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	var n int32
+	srv, tc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		if atomic.AddInt32(&n, 1)%2 == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	})
+	defer srv.Close()
+
+	c, _ := NewClient(srv.URL, tc)
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if _, err := c.Exchange(context.Background(), m); err != nil {
+		t.Fatalf("expected no error after a retry, got %s", err)
+	}
+
+	c.SetRetries(0)
+	_, err := c.Exchange(context.Background(), m)
+	if se, ok := err.(*StatusError); !ok || se.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503 without retries, got %v", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	srv, tc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		if r.URL.Path == "/html" {
+			w.Header().Set("content-type", "text/html")
+			w.Write([]byte("<html></html>"))
+			return true
+		}
+		return false
+	})
+	defer srv.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	c, _ := NewClient(srv.URL+"/other", tc)
+	if _, err := c.Exchange(context.Background(), m); err == nil {
+		t.Error("expected an error for a status 404")
+	}
+
+	c, _ = NewClient(srv.URL+"/html", tc)
+	if _, err := c.Exchange(context.Background(), m); err == nil {
+		t.Error("expected an error for a response that is not a DNS message")
+	}
+
+	c, _ = NewClient(srv.URL+"/slow", tc)
+	c.SetTimeout(50 * time.Millisecond)
+	if _, err := c.Exchange(context.Background(), m); err == nil {
+		t.Error("expected a timeout")
+	}
+
+	c, _ = NewClient(srv.URL, nil)
+	if err := c.Check(context.Background()); err == nil {
+		t.Error("expected an error for an untrusted certificate")
+	}
+
+	if _, err := NewClient("http://example.org", nil); err == nil {
+		t.Error("expected an error for an http URL")
+	}
+	if err := c.SetMethod(http.MethodPut); err == nil {
+		t.Error("expected an error for method PUT")
+	}
+}
@@ -3,6 +3,7 @@ package doh
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -60,6 +61,26 @@ func ResponseToMsg(resp *http.Response) (*dns.Msg, error) {
 	return toMsg(resp.Body)
 }
 
+// Errors returned by RequestToMsg, for which a server should respond with a specific HTTP status.
+var (
+	// ErrMethod is returned for a request that is not a GET or a POST: 405 Method Not Allowed.
+	ErrMethod = errors.New("method not allowed")
+	// ErrURITooLong is returned for a GET request with a 'dns' query parameter longer than the
+	// largest DNS message: 414 URI Too Long.
+	ErrURITooLong = errors.New("'dns' query parameter too long")
+)
+
+// Status returns the HTTP status a server responds with, when RequestToMsg returned err.
+func Status(err error) int {
+	switch err {
+	case ErrMethod:
+		return http.StatusMethodNotAllowed
+	case ErrURITooLong:
+		return http.StatusRequestURITooLong
+	}
+	return http.StatusBadRequest
+}
+
 // RequestToMsg converts a http.Request to a dns message.
 func RequestToMsg(req *http.Request) (*dns.Msg, error) {
 	switch req.Method {
@@ -70,7 +91,7 @@ func RequestToMsg(req *http.Request) (*dns.Msg, error) {
 		return requestToMsgPost(req)
 
 	default:
-		return nil, fmt.Errorf("method not allowed: %s", req.Method)
+		return nil, ErrMethod
 	}
 
 }
@@ -91,6 +112,9 @@ func requestToMsgGet(req *http.Request) (*dns.Msg, error) {
 	if len(b64) != 1 {
 		return nil, fmt.Errorf("multiple 'dns' query values found")
 	}
+	if len(b64[0]) > b64Enc.EncodedLen(dns.MaxMsgSize) {
+		return nil, ErrURITooLong
+	}
 	return base64ToMsg(b64[0])
 }
 
@@ -105,9 +129,10 @@ func toMsg(r io.ReadCloser) (*dns.Msg, error) {
 }
 
 func base64ToMsg(b64 string) (*dns.Msg, error) {
+	// RFC 8484 uses base64url without padding, a padded value is malformed.
 	buf, err := b64Enc.DecodeString(b64)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("malformed 'dns' query parameter: %s", err)
 	}
 
 	m := new(dns.Msg)
@@ -1,7 +1,9 @@
 package doh
 
 import (
+	"encoding/base64"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/miekg/dns"
@@ -50,3 +52,35 @@ func TestGetRequest(t *testing.T) {
 		t.Errorf("Qname expected %d, got %d", x, dns.TypeDNSKEY)
 	}
 }
+
+func TestRequestToMsgErrors(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	buf, _ := m.Pack()
+	b64 := base64.RawURLEncoding.EncodeToString(buf)
+
+	tests := []struct {
+		method string
+		query  string
+		status int
+	}{
+		{http.MethodGet, "dns=" + b64, http.StatusOK},
+		{http.MethodGet, "dns=" + base64.URLEncoding.EncodeToString(buf), http.StatusBadRequest}, // padded
+		{http.MethodGet, "dns=" + base64.StdEncoding.EncodeToString([]byte{0xfb, 0xff}), http.StatusBadRequest},
+		{http.MethodGet, "dns=" + strings.Repeat("A", 90000), http.StatusRequestURITooLong},
+		{http.MethodGet, "", http.StatusBadRequest},
+		{http.MethodGet, "dns=" + b64 + "&dns=" + b64, http.StatusBadRequest},
+		{http.MethodPut, "", http.StatusMethodNotAllowed},
+	}
+	for i, tc := range tests {
+		req, _ := http.NewRequest(tc.method, "https://example.org"+Path+"?"+tc.query, nil)
+		_, err := RequestToMsg(req)
+		status := http.StatusOK
+		if err != nil {
+			status = Status(err)
+		}
+		if status != tc.status {
+			t.Errorf("Test %d: expected status %d, got %d (%v)", i, tc.status, status, err)
+		}
+	}
+}
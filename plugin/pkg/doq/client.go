@@ -0,0 +1,190 @@
+package doq
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// Client sends queries to a DNS-over-QUIC server. The queries are multiplexed, each on its own
+// stream, on a single connection that is dialed on first use and again after it is closed. A new
+// connection resumes the TLS session of the previous one, its first queries are then sent as 0-RTT
+// data. A Client is safe for concurrent use, create one per server and keep it.
+type Client struct {
+	addr      string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	retries   int
+
+	mu   sync.Mutex
+	conn quic.Connection
+}
+
+// Default values of a Client.
+const (
+	defaultTimeout = 5 * time.Second
+	defaultRetries = 1
+)
+
+// NewClient returns a client sending queries to the DoQ server at addr, as "host:port". The TLS
+// connections are configured with tc, which may be nil.
+func NewClient(addr string, tc *tls.Config) *Client {
+	if tc == nil {
+		tc = new(tls.Config)
+	}
+	tc = tc.Clone()
+	tc.NextProtos = []string{NextProto}
+	if tc.ClientSessionCache == nil {
+		tc.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+	}
+	return &Client{addr: addr, tlsConfig: tc, timeout: defaultTimeout, retries: defaultRetries}
+}
+
+// SetTimeout sets the timeout of a query, including the retries, when its context has no deadline.
+// The default is 5s.
+func (c *Client) SetTimeout(d time.Duration) { c.timeout = d }
+
+// SetRetries sets how many times a query is sent again when it fails before a response is
+// received, for instance because the server closed an idle connection. The default is 1.
+func (c *Client) SetRetries(n int) { c.retries = n }
+
+// Exchange sends m to the server and returns the response. The query is sent with ID 0, as RFC 9250
+// requires; the response gets the ID of m.
+func (c *Client) Exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	id := m.Id
+	m.Id = 0
+	buf, err := Pack(m)
+	m.Id = id
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; ; i++ {
+		r, err := c.exchange(ctx, buf)
+		if err == nil {
+			r.Id = id
+			return r, nil
+		}
+		if i >= c.retries || ctx.Err() != nil || !retry(err) {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) exchange(ctx context.Context, buf []byte) (*dns.Msg, error) {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	str, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		c.rejected(conn, err)
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		str.SetDeadline(deadline)
+	}
+
+	if _, err := str.Write(buf); err != nil {
+		str.CancelRead(RequestCancelled)
+		c.rejected(conn, err)
+		return nil, err
+	}
+	// Closing the stream tells the server the query is complete.
+	str.Close()
+
+	r, err := ReadMsg(str)
+	if err != nil {
+		str.CancelRead(RequestCancelled)
+		c.rejected(conn, err)
+		return nil, err
+	}
+	return r, nil
+}
+
+// connection returns the connection to the server, it is dialed when there is none or it is closed.
+func (c *Client) connection(ctx context.Context) (quic.Connection, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil && c.conn.Context().Err() == nil {
+		return c.conn, nil
+	}
+	conn, err := quic.DialAddrEarly(ctx, c.addr, c.tlsConfig, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+// rejected replaces conn with the connection that follows its handshake when the server rejected its
+// 0-RTT data, see quic.EarlyConnection.
+func (c *Client) rejected(conn quic.Connection, err error) {
+	if !errors.Is(err, quic.Err0RTTRejected) {
+		return
+	}
+	ec, ok := conn.(quic.EarlyConnection)
+	if !ok {
+		return
+	}
+	next := ec.NextConnection()
+	c.mu.Lock()
+	if c.conn == conn {
+		c.conn = next
+	}
+	c.mu.Unlock()
+}
+
+// Check sends a query for the root NS records to the server, and returns an error when no response
+// is received. It is meant for health checks: any rcode is a response.
+func (c *Client) Check(ctx context.Context) error {
+	m := new(dns.Msg)
+	m.SetQuestion(".", dns.TypeNS)
+	_, err := c.Exchange(ctx, m)
+	return err
+}
+
+// Close closes the connection to the server. The client can still be used afterwards.
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.CloseWithError(NoError, "")
+		c.conn = nil
+	}
+}
+
+// retry returns true when the query failing with err may be sent again: its 0-RTT data was
+// rejected, the connection was closed or the server cancelled the query. A timeout or a response
+// that can't be parsed are final.
+func retry(err error) bool {
+	if errors.Is(err, quic.Err0RTTRejected) {
+		return true
+	}
+	var (
+		aerr  *quic.ApplicationError
+		ierr  *quic.IdleTimeoutError
+		serr  *quic.StatelessResetError
+		strer *quic.StreamError
+	)
+	switch {
+	case errors.As(err, &aerr):
+		return aerr.Remote && aerr.ErrorCode == NoError
+	case errors.As(err, &ierr), errors.As(err, &serr):
+		return true
+	case errors.As(err, &strer):
+		return strer.Remote && strer.ErrorCode == RequestCancelled
+	}
+	return false
+}
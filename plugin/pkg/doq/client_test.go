@@ -0,0 +1,170 @@
+package doq
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// testServer is a DoQ server answering with an A record. It records the connections it accepted.
+type testServer struct {
+	l *quic.EarlyListener
+
+	mu    sync.Mutex
+	conns []quic.EarlyConnection
+}
+
+func newTestServer(t *testing.T) *testServer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}, NextProtos: []string{NextProto}}
+	l, err := quic.ListenAddrEarly("127.0.0.1:0", tc, &quic.Config{Allow0RTT: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{l: l}
+	go func() {
+		for {
+			conn, err := l.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) serve(conn quic.Connection) {
+	for {
+		str, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		go func() {
+			req, err := ReadMsg(str)
+			if err != nil {
+				conn.CloseWithError(ProtocolError, err.Error())
+				return
+			}
+			m := new(dns.Msg)
+			m.SetReply(req)
+			a, _ := dns.NewRR(req.Question[0].Name + " IN A 192.0.2.1")
+			m.Answer = append(m.Answer, a)
+			buf, _ := Pack(m)
+			str.Write(buf)
+			str.Close()
+		}()
+	}
+}
+
+func (s *testServer) accepted() []quic.EarlyConnection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]quic.EarlyConnection(nil), s.conns...)
+}
+
+func TestClient(t *testing.T) {
+	s := newTestServer(t)
+	defer s.l.Close()
+
+	c := NewClient(s.l.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := new(dns.Msg)
+			m.SetQuestion("example.org.", dns.TypeA)
+			r, err := c.Exchange(context.Background(), m)
+			if err != nil {
+				t.Errorf("Expected no error, got %s", err)
+				return
+			}
+			if r.Id != m.Id {
+				t.Errorf("Expected the ID %d, got %d", m.Id, r.Id)
+			}
+			if len(r.Answer) != 1 {
+				t.Errorf("Expected 1 answer, got %v", r)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(s.accepted()); n != 1 {
+		t.Errorf("Expected the queries on 1 connection, got %d", n)
+	}
+}
+
+func TestClientResumption(t *testing.T) {
+	s := newTestServer(t)
+	defer s.l.Close()
+
+	c := NewClient(s.l.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	defer c.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := c.Check(context.Background()); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		// The next query dials a new connection.
+		c.Close()
+	}
+
+	conns := s.accepted()
+	if len(conns) != 2 {
+		t.Fatalf("Expected 2 connections, got %d", len(conns))
+	}
+	<-conns[1].HandshakeComplete()
+	if cs := conns[1].ConnectionState(); !cs.TLS.DidResume || !cs.Used0RTT {
+		t.Errorf("Expected the second connection to resume the session with 0-RTT, got resumed %t, 0-RTT %t", cs.TLS.DidResume, cs.Used0RTT)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	s := newTestServer(t)
+	defer s.l.Close()
+
+	c := NewClient(s.l.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	defer c.Close()
+
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	// The server closes the idle connection, the next query is sent on a new one.
+	s.accepted()[0].CloseWithError(NoError, "")
+	if err := c.Check(context.Background()); err != nil {
+		t.Errorf("Expected no error after the server closed the connection, got %s", err)
+	}
+	if n := len(s.accepted()); n != 2 {
+		t.Errorf("Expected 2 connections, got %d", n)
+	}
+}
@@ -0,0 +1,67 @@
+// Package doq implements the framing of DNS-over-QUIC (DoQ), RFC 9250, and a client.
+//
+// Each query is sent on its own bidirectional QUIC stream, prefixed with its length in two bytes like
+// on TCP. The Message ID of the queries and responses is 0, the streams tell them apart.
+package doq
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/miekg/dns"
+)
+
+// NextProto is the ALPN token of DoQ.
+const NextProto = "doq"
+
+// The DoQ error codes, used to close connections (as a quic.ApplicationErrorCode) and to reset streams
+// (as a quic.StreamErrorCode), see section 4.3 of RFC 9250.
+const (
+	NoError          = 0x0
+	InternalError    = 0x1
+	ProtocolError    = 0x2
+	RequestCancelled = 0x3
+	ExcessiveLoad    = 0x4
+)
+
+// ErrID is returned by ReadMsg for a message with a Message ID that is not 0.
+var ErrID = errors.New("doq: message ID is not 0")
+
+// Pack returns m packed and prefixed with its length.
+func Pack(m *dns.Msg) ([]byte, error) {
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	return Frame(buf), nil
+}
+
+// Frame returns the packed message buf prefixed with its length.
+func Frame(buf []byte) []byte {
+	b := make([]byte, 2+len(buf))
+	binary.BigEndian.PutUint16(b, uint16(len(buf)))
+	copy(b[2:], buf)
+	return b
+}
+
+// ReadMsg reads a message prefixed with its length from r. A message with a Message ID that is not 0 is
+// returned with ErrID, this is a protocol error.
+func ReadMsg(r io.Reader) (*dns.Msg, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return nil, err
+	}
+	if m.Id != 0 {
+		return m, ErrID
+	}
+	return m, nil
+}
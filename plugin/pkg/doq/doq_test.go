@@ -0,0 +1,40 @@
+package doq
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestReadMsg(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Id = 0
+
+	buf, err := Pack(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := int(buf[0])<<8 | int(buf[1]); l != len(buf)-2 {
+		t.Errorf("Expected a length prefix of %d, got %d", len(buf)-2, l)
+	}
+	r, err := ReadMsg(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if r.Question[0].Name != "example.org." {
+		t.Errorf("Expected question for example.org., got %s", r.Question[0].Name)
+	}
+
+	m.Id = 42
+	buf, _ = Pack(m)
+	if _, err := ReadMsg(bytes.NewReader(buf)); err != ErrID {
+		t.Errorf("Expected %s, got %v", ErrID, err)
+	}
+
+	// A message shorter than its length prefix.
+	if _, err := ReadMsg(bytes.NewReader(buf[:len(buf)-1])); err == nil {
+		t.Error("Expected error for a short message, got none")
+	}
+}
@@ -0,0 +1,115 @@
+// Package ede implements the Extended DNS Errors, which explain the failures to the clients, see
+// RFC 8914.
+package ede
+
+import (
+	"encoding/binary"
+
+	"github.com/miekg/dns"
+)
+
+// EDNS0EDE is the option code of the Extended DNS Errors.
+const EDNS0EDE = 15
+
+// The Extended DNS Error codes.
+const (
+	Other                       uint16 = 0
+	UnsupportedDNSKEYAlgorithm  uint16 = 1
+	UnsupportedDSDigest         uint16 = 2
+	StaleAnswer                 uint16 = 3
+	ForgedAnswer                uint16 = 4
+	DNSSECIndeterminate         uint16 = 5
+	DNSSECBogus                 uint16 = 6
+	SignatureExpired            uint16 = 7
+	SignatureNotYetValid        uint16 = 8
+	DNSKEYMissing               uint16 = 9
+	RRSIGsMissing               uint16 = 10
+	NoZoneKeyBitSet             uint16 = 11
+	NSECMissing                 uint16 = 12
+	CachedError                 uint16 = 13
+	NotReady                    uint16 = 14
+	Blocked                     uint16 = 15
+	Censored                    uint16 = 16
+	Filtered                    uint16 = 17
+	Prohibited                  uint16 = 18
+	StaleNXDomainAnswer         uint16 = 19
+	NotAuthoritative            uint16 = 20
+	NotSupported                uint16 = 21
+	NoReachableAuthority        uint16 = 22
+	NetworkError                uint16 = 23
+	InvalidData                 uint16 = 24
+	SignatureExpiredBeforeValid uint16 = 25
+)
+
+// New returns the EDNS0 option of the Extended DNS Error code, with the text, which may be empty.
+func New(code uint16, text string) *dns.EDNS0_LOCAL {
+	data := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(data, code)
+	copy(data[2:], text)
+	return &dns.EDNS0_LOCAL{Code: EDNS0EDE, Data: data}
+}
+
+// Set adds the Extended DNS Error code, with the text, to m, the answer to r. It does nothing when r
+// has no OPT record: the client doesn't understand EDNS0. The OPT record of m is copied, as it may be
+// shared with r.
+func Set(r, m *dns.Msg, code uint16, text string) {
+	o := r.IsEdns0()
+	if o == nil {
+		return
+	}
+	for i, rr := range m.Extra {
+		if mo, ok := rr.(*dns.OPT); ok {
+			mo1 := *mo
+			mo1.Option = append(append([]dns.EDNS0(nil), mo.Option...), New(code, text))
+			m.Extra[i] = &mo1
+			return
+		}
+	}
+	m.SetEdns0(o.UDPSize(), o.Do())
+	mo := m.IsEdns0()
+	mo.Option = append(mo.Option, New(code, text))
+}
+
+// Get returns the first Extended DNS Error of m, and false when m has none.
+func Get(m *dns.Msg) (code uint16, text string, ok bool) {
+	o := m.IsEdns0()
+	if o == nil {
+		return 0, "", false
+	}
+	for _, opt := range o.Option {
+		if e, ok := opt.(*dns.EDNS0_LOCAL); ok && e.Code == EDNS0EDE && len(e.Data) >= 2 {
+			return binary.BigEndian.Uint16(e.Data), string(e.Data[2:]), true
+		}
+	}
+	return 0, "", false
+}
+
+// Error is an error with the Extended DNS Error explaining it. A plugin returning an Error without
+// writing a response has the server add the Extended DNS Error to the response it writes.
+type Error struct {
+	Code uint16
+	Err  error
+}
+
+// Wrap returns err with the Extended DNS Error code.
+func Wrap(code uint16, err error) error { return &Error{Code: code, Err: err} }
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+// Unwrap returns the wrapped error.
+func (e *Error) Unwrap() error { return e.Err }
+
+// FromError returns the Error in the chain of err, and false if there is none.
+func FromError(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e, true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil, false
+		}
+		err = u.Unwrap()
+	}
+	return nil, false
+}
@@ -0,0 +1,83 @@
+package ede
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSet(t *testing.T) {
+	r := new(dns.Msg)
+	r.SetQuestion("example.org.", dns.TypeA)
+
+	// Without EDNS0 the client doesn't get an Extended DNS Error.
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeServerFailure)
+	Set(r, m, NetworkError, "")
+	if m.IsEdns0() != nil {
+		t.Errorf("Expected no OPT record, got %s", m.IsEdns0())
+	}
+
+	r.SetEdns0(1232, true)
+	m = new(dns.Msg)
+	m.SetRcode(r, dns.RcodeServerFailure)
+	Set(r, m, NetworkError, "timeout")
+
+	buf, err := m.Pack()
+	if err != nil {
+		t.Fatalf("Failed to pack the message: %s", err)
+	}
+	m1 := new(dns.Msg)
+	if err := m1.Unpack(buf); err != nil {
+		t.Fatalf("Failed to unpack the message: %s", err)
+	}
+	code, text, ok := Get(m1)
+	if !ok || code != NetworkError || text != "timeout" {
+		t.Errorf("Expected Extended DNS Error %d %q, got %d %q", NetworkError, "timeout", code, text)
+	}
+	if o := m1.IsEdns0(); o.UDPSize() != 1232 || !o.Do() {
+		t.Errorf("Expected the OPT record of the request, got %s", o)
+	}
+}
+
+func TestSetSharedOPT(t *testing.T) {
+	r := new(dns.Msg)
+	r.SetQuestion("example.org.", dns.TypeA)
+	r.SetEdns0(4096, false)
+
+	// m shares the OPT record of r, which must not change.
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Extra = append(m.Extra, r.IsEdns0())
+	Set(r, m, Blocked, "")
+
+	if len(r.IsEdns0().Option) != 0 {
+		t.Errorf("Expected the OPT record of the request not to change, got %s", r.IsEdns0())
+	}
+	if code, _, ok := Get(m); !ok || code != Blocked {
+		t.Errorf("Expected Extended DNS Error %d, got %d", Blocked, code)
+	}
+}
+
+func TestFromError(t *testing.T) {
+	base := errors.New("no healthy proxies")
+	err := Wrap(NoReachableAuthority, base)
+
+	if e, ok := FromError(err); !ok || e.Code != NoReachableAuthority {
+		t.Errorf("Expected Extended DNS Error %d, got %v", NoReachableAuthority, e)
+	}
+	if err.Error() != base.Error() {
+		t.Errorf("Expected error %q, got %q", base, err)
+	}
+	if _, ok := FromError(base); ok {
+		t.Errorf("Expected no Extended DNS Error for %s", base)
+	}
+	if _, ok := FromError(nil); ok {
+		t.Errorf("Expected no Extended DNS Error for nil")
+	}
+	if _, ok := FromError(fmt.Errorf("plugin/forward: %s", err)); ok {
+		t.Errorf("Expected no Extended DNS Error through a formatted error")
+	}
+}
@@ -0,0 +1,87 @@
+package expression
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// variables are the variables of the expressions, the attributes of the query.
+var variables = map[string]func(ctx context.Context, state *request.Request) interface{}{
+	"client_ip":   func(_ context.Context, state *request.Request) interface{} { return state.IP() },
+	"client_port": func(_ context.Context, state *request.Request) interface{} { return number(state.Port()) },
+	"server_ip":   func(_ context.Context, state *request.Request) interface{} { return state.LocalIP() },
+	"server_port": func(_ context.Context, state *request.Request) interface{} { return number(state.LocalPort()) },
+	"protocol":    func(_ context.Context, state *request.Request) interface{} { return state.Proto() },
+	"transport":   func(ctx context.Context, _ *request.Request) interface{} { return serverTransport(ctx) },
+	"name":        func(_ context.Context, state *request.Request) interface{} { return state.Name() },
+	"type":        func(_ context.Context, state *request.Request) interface{} { return state.Type() },
+	"class":       func(_ context.Context, state *request.Request) interface{} { return state.Class() },
+	"id":          func(_ context.Context, state *request.Request) interface{} { return float64(state.Req.Id) },
+	"opcode":      func(_ context.Context, state *request.Request) interface{} { return dns.OpcodeToString[state.Req.Opcode] },
+	"bufsize":     func(_ context.Context, state *request.Request) interface{} { return float64(state.Size()) },
+	"do":          func(_ context.Context, state *request.Request) interface{} { return state.Do() },
+}
+
+// function is a function of the expressions, taking args arguments.
+type function struct {
+	args int
+	f    func(ctx context.Context, args []interface{}) (interface{}, error)
+}
+
+// functions are the functions of the expressions.
+var functions = map[string]function{
+	// incidr returns true if the address is in the network, in CIDR notation.
+	"incidr": {2, func(_ context.Context, args []interface{}) (interface{}, error) {
+		addr, ok1 := args[0].(string)
+		cidr, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("incidr needs strings: %v, %v", args[0], args[1])
+		}
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address: %s", addr)
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		return n.Contains(ip), nil
+	}},
+	// metadata returns the value of the metadata label, "" when the metadata plugin is not used or
+	// the label is not set.
+	"metadata": {1, func(ctx context.Context, args []interface{}) (interface{}, error) {
+		label, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("metadata needs a string: %v", args[0])
+		}
+		if f := metadata.ValueFunc(ctx, label); f != nil {
+			return f(), nil
+		}
+		return "", nil
+	}},
+}
+
+func number(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// serverTransport returns the transport of the server of the query: dns, tls, https or grpc.
+func serverTransport(ctx context.Context) string {
+	if s, ok := ctx.Value(dnsserver.Key{}).(*dnsserver.Server); ok {
+		if i := strings.Index(s.Addr, "://"); i > 0 {
+			return s.Addr[:i]
+		}
+	}
+	return transport.DNS
+}
@@ -0,0 +1,422 @@
+// Package expression implements boolean expressions over the attributes of a query, such as
+// `incidr(client_ip, '10.0.0.0/8') && protocol == 'udp'`.
+//
+// An expression combines comparisons with && (and), || (or) and ! (not), and parentheses. The
+// operands are strings in single or double quotes, numbers, true and false, variables and function
+// calls, see the variables and functions maps. The comparison operators are ==, !=, <, <=, >, >= and
+// =~, which matches a string against a regular expression.
+package expression
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/coredns/coredns/request"
+)
+
+// Expression is a parsed expression.
+type Expression struct {
+	src  string
+	root node
+}
+
+// Parse parses the expression s. Its variables and functions must exist, and the regular
+// expressions in literal strings must compile.
+func Parse(s string) (*Expression, error) {
+	p := &parser{src: s}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEnd {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return &Expression{src: s, root: root}, nil
+}
+
+// String returns the source of e.
+func (e *Expression) String() string { return e.src }
+
+// Eval evaluates e for the query state, it is an error when e isn't a boolean.
+func (e *Expression) Eval(ctx context.Context, state *request.Request) (bool, error) {
+	v, err := e.root.eval(ctx, state)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s is not a boolean: %v", e.src, v)
+	}
+	return b, nil
+}
+
+// node is a node of the tree of an expression. Its values are strings, float64 and bools.
+type node interface {
+	eval(ctx context.Context, state *request.Request) (interface{}, error)
+}
+
+type literal struct{ v interface{} }
+
+func (n literal) eval(context.Context, *request.Request) (interface{}, error) { return n.v, nil }
+
+type variable struct{ name string }
+
+func (n variable) eval(ctx context.Context, state *request.Request) (interface{}, error) {
+	return variables[n.name](ctx, state), nil
+}
+
+type call struct {
+	name string
+	args []node
+}
+
+func (n call) eval(ctx context.Context, state *request.Request) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(ctx, state)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return functions[n.name].f(ctx, args)
+}
+
+type not struct{ x node }
+
+func (n not) eval(ctx context.Context, state *request.Request) (interface{}, error) {
+	b, err := evalBool(ctx, state, n.x)
+	return !b, err
+}
+
+// logical is && or ||, the right operand is only evaluated when needed.
+type logical struct {
+	and  bool
+	x, y node
+}
+
+func (n logical) eval(ctx context.Context, state *request.Request) (interface{}, error) {
+	b, err := evalBool(ctx, state, n.x)
+	if err != nil || b != n.and {
+		return b, err
+	}
+	return evalBool(ctx, state, n.y)
+}
+
+func evalBool(ctx context.Context, state *request.Request, n node) (bool, error) {
+	v, err := n.eval(ctx, state)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("not a boolean: %v", v)
+	}
+	return b, nil
+}
+
+type compare struct {
+	op   string
+	x, y node
+	re   *regexp.Regexp // the compiled literal of =~
+}
+
+func (n compare) eval(ctx context.Context, state *request.Request) (interface{}, error) {
+	x, err := n.x.eval(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	y, err := n.y.eval(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return x == y, nil
+	case "!=":
+		return x != y, nil
+	case "=~":
+		s, ok1 := x.(string)
+		pattern, ok2 := y.(string)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("=~ needs strings: %v =~ %v", x, y)
+		}
+		re := n.re
+		if re == nil {
+			if re, err = regexp.Compile(pattern); err != nil {
+				return nil, err
+			}
+		}
+		return re.MatchString(s), nil
+	}
+
+	var c int
+	switch x := x.(type) {
+	case float64:
+		y, ok := y.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s needs operands of the same type: %v %s %v", n.op, x, n.op, y)
+		}
+		switch {
+		case x < y:
+			c = -1
+		case x > y:
+			c = 1
+		}
+	case string:
+		y, ok := y.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s needs operands of the same type: %v %s %v", n.op, x, n.op, y)
+		}
+		c = strings.Compare(x, y)
+	default:
+		return nil, fmt.Errorf("%s needs numbers or strings: %v %s %v", n.op, x, n.op, y)
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	}
+	return c >= 0, nil
+}
+
+type tokenKind int
+
+const (
+	tokEnd tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	i      int
+}
+
+// The operators, the longest first.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "<", ">", "!", "(", ")", ","}
+
+func (p *parser) tokenize() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '\'' || c == '"':
+			j := strings.IndexByte(s[i+1:], c)
+			if j < 0 {
+				return fmt.Errorf("unterminated string at %d", i)
+			}
+			p.tokens = append(p.tokens, token{tokString, s[i+1 : i+1+j], i})
+			i += j + 2
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens, token{tokNumber, s[i:j], i})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(s) && (s[j] == '_' || s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z' || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			p.tokens = append(p.tokens, token{tokIdent, s[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			p.tokens = append(p.tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	p.tokens = append(p.tokens, token{tokEnd, "end", len(s)})
+	return nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.i] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEnd {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator op.
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (node, error) {
+	x, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		y, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		x = logical{and: false, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) and() (node, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		x = logical{and: true, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.accept("!") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokOp {
+		return x, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=", "=~":
+	default:
+		return x, nil
+	}
+	p.next()
+	y, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	n := compare{op: t.text, x: x, y: y}
+	if l, ok := y.(literal); ok && t.text == "=~" {
+		pattern, ok := l.v.(string)
+		if !ok {
+			return nil, fmt.Errorf("=~ needs a string at %d", t.pos)
+		}
+		if n.re, err = regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literal{t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", t.text, t.pos)
+		}
+		return literal{f}, nil
+	case tokIdent:
+		switch {
+		case t.text == "true":
+			return literal{true}, nil
+		case t.text == "false":
+			return literal{false}, nil
+		case p.accept("("):
+			return p.call(t)
+		}
+		if _, ok := variables[t.text]; !ok {
+			return nil, fmt.Errorf("unknown variable %q at %d", t.text, t.pos)
+		}
+		return variable{t.text}, nil
+	case tokOp:
+		if t.text == "(" {
+			x, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			if !p.accept(")") {
+				return nil, fmt.Errorf("missing ) at %d", p.peek().pos)
+			}
+			return x, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+// call parses the arguments of the function named by t, after its opening parenthesis.
+func (p *parser) call(t token) (node, error) {
+	f, ok := functions[t.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at %d", t.text, t.pos)
+	}
+	n := call{name: t.text}
+	if !p.accept(")") {
+		for {
+			a, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			n.args = append(n.args, a)
+			if p.accept(")") {
+				break
+			}
+			if !p.accept(",") {
+				return nil, fmt.Errorf("missing , or ) at %d", p.peek().pos)
+			}
+		}
+	}
+	if len(n.args) != f.args {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d at %d", t.text, f.args, len(n.args), t.pos)
+	}
+	return n, nil
+}
@@ -0,0 +1,88 @@
+package expression
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func TestEval(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeAAAA)
+	m.SetEdns0(4096, true)
+	// test.ResponseWriter has the remote address 10.240.0.1:40212, over UDP.
+	state := &request.Request{W: &test.ResponseWriter{}, Req: m}
+
+	ctx := metadata.ContextWithMetadata(context.TODO())
+	metadata.SetValueFunc(ctx, "test/label", func() string { return "value" })
+
+	tests := []struct {
+		expr     string
+		expected bool
+		evalErr  bool
+	}{
+		{`incidr(client_ip, '10.240.0.0/16')`, true, false},
+		{`incidr(client_ip, "10.0.0.0/16")`, false, false},
+		{`protocol == 'udp' && type == 'AAAA'`, true, false},
+		{`protocol == 'tcp' || type == 'A'`, false, false},
+		{`!(protocol == 'tcp')`, true, false},
+		{`name =~ '^www\.'`, true, false},
+		{`name =~ 'mail'`, false, false},
+		{`client_port > 1024 && bufsize >= 4096`, true, false},
+		{`bufsize < 512`, false, false},
+		{`do`, true, false},
+		{`do == false`, false, false},
+		{`transport == 'dns'`, true, false},
+		{`metadata('test/label') == 'value'`, true, false},
+		{`metadata('test/other') == ''`, true, false},
+		{`name > 1`, false, true},
+		{`name`, false, true},
+		{`incidr(name, '10.0.0.0/8')`, false, true},
+	}
+	for i, tc := range tests {
+		e, err := Parse(tc.expr)
+		if err != nil {
+			t.Errorf("Test %d: expected no error parsing %s, got %s", i, tc.expr, err)
+			continue
+		}
+		got, err := e.Eval(ctx, state)
+		if tc.evalErr {
+			if err == nil {
+				t.Errorf("Test %d: expected an error evaluating %s, got none", i, tc.expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error evaluating %s, got %s", i, tc.expr, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("Test %d: expected %s to be %t, got %t", i, tc.expr, tc.expected, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for i, expr := range []string{
+		``,
+		`client`,
+		`unknown(name)`,
+		`incidr(client_ip)`,
+		`name == 'www`,
+		`(name == 'www'`,
+		`name == 'a' 'b'`,
+		`name =~ '['`,
+		`name =~ 1`,
+		`name $ 'a'`,
+		`name ==`,
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Test %d: expected an error parsing %q, got none", i, expr)
+		}
+	}
+}
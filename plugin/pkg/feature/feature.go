@@ -0,0 +1,115 @@
+// Package feature implements feature gates: named switches that turn new or risky behavior on or off
+// for the whole process.
+//
+// A plugin, or the core, registers a gate at init time with its default state and checks it with
+// Enabled where the behavior is implemented. The states are set with the features directive in the
+// Corefile, the gates not mentioned there are at their default. The state of every gate is
+// exported in the coredns_feature_gate_enabled metric.
+package feature
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/coredns/coredns/plugin/metrics/vars"
+)
+
+// Gate is a feature gate.
+type Gate struct {
+	name        string
+	description string
+	def         bool
+	enabled     int32 // accessed atomically
+}
+
+var (
+	mu    sync.Mutex
+	gates = map[string]*Gate{}
+)
+
+// Register registers a gate called name that is enabled by default when def is true. It panics when
+// a gate with that name is already registered.
+func Register(name, description string, def bool) *Gate {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := gates[name]; ok {
+		panic(fmt.Sprintf("feature gate %q registered twice", name))
+	}
+	g := &Gate{name: name, description: description, def: def}
+	g.set(def)
+	gates[name] = g
+	return g
+}
+
+// Name returns the name of g.
+func (g *Gate) Name() string { return g.name }
+
+// Description returns the description of g.
+func (g *Gate) Description() string { return g.description }
+
+// Default returns true if g is enabled by default.
+func (g *Gate) Default() bool { return g.def }
+
+// Enabled returns true if g is enabled.
+func (g *Gate) Enabled() bool { return atomic.LoadInt32(&g.enabled) == 1 }
+
+func (g *Gate) set(enabled bool) {
+	v := 0.0
+	if enabled {
+		v = 1
+		atomic.StoreInt32(&g.enabled, 1)
+	} else {
+		atomic.StoreInt32(&g.enabled, 0)
+	}
+	vars.FeatureGate.WithLabelValues(g.name).Set(v)
+}
+
+// Lookup returns the gate called name, or nil when there is no such gate.
+func Lookup(name string) *Gate {
+	mu.Lock()
+	defer mu.Unlock()
+	return gates[name]
+}
+
+// Enabled returns true if the gate called name is registered and enabled.
+func Enabled(name string) bool {
+	g := Lookup(name)
+	return g != nil && g.Enabled()
+}
+
+// All returns all registered gates, sorted by name.
+func All() []*Gate {
+	mu.Lock()
+	defer mu.Unlock()
+
+	all := make([]*Gate, 0, len(gates))
+	for _, g := range gates {
+		all = append(all, g)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+	return all
+}
+
+// Set sets the state of the gates in states, all other gates are set to their default. When states
+// holds a gate that is not registered an error is returned and no gate is changed.
+func Set(states map[string]bool) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for name := range states {
+		if _, ok := gates[name]; !ok {
+			return fmt.Errorf("unknown feature gate %q", name)
+		}
+	}
+	for name, g := range gates {
+		enabled, ok := states[name]
+		if !ok {
+			enabled = g.def
+		}
+		g.set(enabled)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package feature
+
+import "testing"
+
+func TestSet(t *testing.T) {
+	off := Register("test_off", "Disabled by default.", false)
+	on := Register("test_on", "Enabled by default.", true)
+
+	if off.Enabled() || !on.Enabled() {
+		t.Fatalf("Expected the gates at their default")
+	}
+
+	if err := Set(map[string]bool{"test_off": true, "test_on": false}); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if !Enabled("test_off") || Enabled("test_on") {
+		t.Errorf("Expected test_off enabled and test_on disabled")
+	}
+
+	if err := Set(map[string]bool{"test_off": false, "nosuchgate": true}); err == nil {
+		t.Errorf("Expected error for unknown gate, got none")
+	}
+	if !off.Enabled() {
+		t.Errorf("Expected no gate changed after an error")
+	}
+
+	// Gates not given go back to their default.
+	if err := Set(nil); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if off.Enabled() || !on.Enabled() {
+		t.Errorf("Expected the gates at their default")
+	}
+
+	if Enabled("nosuchgate") {
+		t.Errorf("Expected unknown gate to be disabled")
+	}
+	if all := All(); len(all) != 2 || all[0] != off || all[1] != on {
+		t.Errorf("Expected all gates sorted by name, got %v", all)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	Register("test_twice", "", false)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic when registering a gate twice")
+		}
+	}()
+	Register("test_twice", "", false)
+}
@@ -44,6 +44,12 @@ func HostPortOrFile(s ...string) ([]string, error) {
 				ss = transport.HTTPS + "://" + net.JoinHostPort(host, transport.HTTPSPort)
 			case transport.HTTP:
 				ss = transport.HTTP + "://" + net.JoinHostPort(host, transport.HTTPPort)
+			case transport.QUIC:
+				ss = transport.QUIC + "://" + net.JoinHostPort(host, transport.QUICPort)
+			case transport.H3:
+				ss = transport.H3 + "://" + net.JoinHostPort(host, transport.H3Port)
+			case transport.DTLS:
+				ss = transport.DTLS + "://" + net.JoinHostPort(host, transport.DTLSPort)
 			}
 			servers = append(servers, ss)
 			continue
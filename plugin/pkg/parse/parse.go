@@ -2,48 +2,113 @@
 package parse
 
 import (
+	"encoding/base64"
 	"fmt"
+	"strings"
 
 	"github.com/coredns/coredns/plugin/pkg/transport"
 
 	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
 )
 
-// Transfer parses transfer statements: 'transfer [to|from] [address...]'.
-func Transfer(c *caddy.Controller, secondary bool) (tos, froms []string, err error) {
+// Key is a TSIG key, Name is fully qualified and lower cased, Secret is base64 encoded.
+type Key struct {
+	Name   string
+	Secret string
+}
+
+// Transfer parses transfer statements: 'transfer [to|from] [address...] [key NAME SECRET]'. With
+// 'to' the addresses must sign their transfer requests with the key, with 'from' we sign ours.
+func Transfer(c *caddy.Controller, secondary bool) (tos, froms []string, key *Key, err error) {
 	if !c.NextArg() {
-		return nil, nil, c.ArgErr()
+		return nil, nil, nil, c.ArgErr()
 	}
 	value := c.Val()
 	switch value {
 	case "to":
-		tos = c.RemainingArgs()
-		for i := range tos {
-			if tos[i] != "*" {
-				normalized, err := HostPort(tos[i], transport.Port)
-				if err != nil {
-					return nil, nil, err
-				}
-				tos[i] = normalized
-			}
+		tos, key, err = transferKey(c.RemainingArgs())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if err := hostPorts(tos); err != nil {
+			return nil, nil, nil, err
 		}
 
 	case "from":
 		if !secondary {
-			return nil, nil, fmt.Errorf("can't use `transfer from` when not being a secondary")
+			return nil, nil, nil, fmt.Errorf("can't use `transfer from` when not being a secondary")
+		}
+		froms, key, err = transferKey(c.RemainingArgs())
+		if err != nil {
+			return nil, nil, nil, err
 		}
-		froms = c.RemainingArgs()
 		for i := range froms {
 			if froms[i] != "*" {
 				normalized, err := HostPort(froms[i], transport.Port)
 				if err != nil {
-					return nil, nil, err
+					return nil, nil, nil, err
 				}
 				froms[i] = normalized
 			} else {
-				return nil, nil, fmt.Errorf("can't use '*' in transfer from")
+				return nil, nil, nil, fmt.Errorf("can't use '*' in transfer from")
 			}
 		}
 	}
 	return
 }
+
+// Update parses update statements: 'update address... [key NAME SECRET]'. The addresses, or any
+// address with '*', may send dynamic updates, signed with the key when one is given.
+func Update(c *caddy.Controller) (froms []string, key *Key, err error) {
+	froms, key, err = transferKey(c.RemainingArgs())
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(froms) == 0 {
+		return nil, nil, c.ArgErr()
+	}
+	if err := hostPorts(froms); err != nil {
+		return nil, nil, err
+	}
+	return froms, key, nil
+}
+
+// hostPorts normalizes the addresses in addrs to host:port, leaving "*" alone.
+func hostPorts(addrs []string) error {
+	for i := range addrs {
+		if addrs[i] == "*" {
+			continue
+		}
+		normalized, err := HostPort(addrs[i], transport.Port)
+		if err != nil {
+			return err
+		}
+		addrs[i] = normalized
+	}
+	return nil
+}
+
+// transferKey splits the trailing 'key NAME SECRET' off args.
+func transferKey(args []string) ([]string, *Key, error) {
+	i := indexOf(args, "key")
+	if i < 0 {
+		return args, nil, nil
+	}
+	if len(args) != i+3 {
+		return nil, nil, fmt.Errorf("key needs a name and a secret")
+	}
+	if _, err := base64.StdEncoding.DecodeString(args[i+2]); err != nil {
+		return nil, nil, fmt.Errorf("invalid secret of key %q: %s", args[i+1], err)
+	}
+	return args[:i], &Key{Name: strings.ToLower(dns.Fqdn(args[i+1])), Secret: args[i+2]}, nil
+}
+
+func indexOf(s []string, v string) int {
+	for i := range s {
+		if s[i] == v {
+			return i
+		}
+	}
+	return -1
+}
@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/caddyserver/caddy"
@@ -13,58 +14,79 @@ func TestTransfer(t *testing.T) {
 		secondary      bool
 		expectedTo     []string
 		expectedFrom   []string
+		expectedKey    *Key
 	}{
 		// OK transfer to
 		{
 			`to 127.0.0.1`,
-			false, false, []string{"127.0.0.1:53"}, []string{},
+			false, false, []string{"127.0.0.1:53"}, []string{}, nil,
 		},
 		// OK transfer tos
 		{
 			`to 127.0.0.1 127.0.0.2`,
-			false, false, []string{"127.0.0.1:53", "127.0.0.2:53"}, []string{},
+			false, false, []string{"127.0.0.1:53", "127.0.0.2:53"}, []string{}, nil,
 		},
 		// OK transfer from
 		{
 			`from 127.0.0.1`,
-			false, true, []string{}, []string{"127.0.0.1:53"},
+			false, true, []string{}, []string{"127.0.0.1:53"}, nil,
 		},
 		// OK transfer froms
 		{
 			`from 127.0.0.1 127.0.0.2`,
-			false, true, []string{}, []string{"127.0.0.1:53", "127.0.0.2:53"},
+			false, true, []string{}, []string{"127.0.0.1:53", "127.0.0.2:53"}, nil,
 		},
 		// OK transfer tos/froms
 		{
 			`to 127.0.0.1 127.0.0.2
 			from 127.0.0.1 127.0.0.2`,
-			false, true, []string{"127.0.0.1:53", "127.0.0.2:53"}, []string{"127.0.0.1:53", "127.0.0.2:53"},
+			false, true, []string{"127.0.0.1:53", "127.0.0.2:53"}, []string{"127.0.0.1:53", "127.0.0.2:53"}, nil,
+		},
+		// OK transfer to with key
+		{
+			`to 127.0.0.1 key Xfr.Example.org c2VjcmV0`,
+			false, false, []string{"127.0.0.1:53"}, []string{}, &Key{Name: "xfr.example.org.", Secret: "c2VjcmV0"},
+		},
+		// Bad transfer to key without secret
+		{
+			`to 127.0.0.1 key xfr.example.org.`,
+			true, false, []string{}, []string{}, nil,
+		},
+		// Bad transfer to key secret not base64
+		{
+			`to 127.0.0.1 key xfr.example.org. !secret`,
+			true, false, []string{}, []string{}, nil,
+		},
+		// OK transfer from with key
+		{
+			`from 127.0.0.1 key xfr.example.org. c2VjcmV0`,
+			false, true, []string{}, []string{"127.0.0.1:53"}, &Key{Name: "xfr.example.org.", Secret: "c2VjcmV0"},
 		},
 		// Bad transfer from, secondary false
 		{
 			`from 127.0.0.1`,
-			true, false, []string{}, []string{},
+			true, false, []string{}, []string{}, nil,
 		},
 		// Bad transfer from garbage
 		{
 			`from !@#$%^&*()`,
-			true, true, []string{}, []string{},
+			true, true, []string{}, []string{}, nil,
 		},
 		// Bad transfer from no args
 		{
 			`from`,
-			true, false, []string{}, []string{},
+			true, false, []string{}, []string{}, nil,
 		},
 		// Bad transfer from *
 		{
 			`from *`,
-			true, true, []string{}, []string{},
+			true, true, []string{}, []string{}, nil,
 		},
 	}
 
 	for i, test := range tests {
 		c := caddy.NewTestController("dns", test.inputFileRules)
-		tos, froms, err := Transfer(c, test.secondary)
+		tos, froms, key, err := Transfer(c, test.secondary)
 
 		if err == nil && test.shouldErr {
 			t.Fatalf("Test %d expected errors, but got no error %+v %+v", i, err, test)
@@ -72,6 +94,9 @@ func TestTransfer(t *testing.T) {
 			t.Fatalf("Test %d expected no errors, but got '%v'", i, err)
 		}
 
+		if test.expectedKey != nil && (key == nil || *key != *test.expectedKey) {
+			t.Fatalf("Test %d expected key %v, got %v", i, test.expectedKey, key)
+		}
 		if test.expectedTo != nil {
 			for j, got := range tos {
 				if got != test.expectedTo[j] {
@@ -90,3 +115,41 @@ func TestTransfer(t *testing.T) {
 	}
 
 }
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		froms     []string
+		key       *Key
+	}{
+		{`127.0.0.1 10.0.0.1:5353`, false, []string{"127.0.0.1:53", "10.0.0.1:5353"}, nil},
+		{`* key update.example.org c2VjcmV0`, false, []string{"*"}, &Key{Name: "update.example.org.", Secret: "c2VjcmV0"}},
+		{``, true, nil, nil},
+		{`key update.example.org. c2VjcmV0`, true, nil, nil},
+		{`127.0.0.1 key update.example.org.`, true, nil, nil},
+		{`not-an-address`, true, nil, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", "update "+tc.input)
+		c.Next()
+		froms, key, err := Update(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if strings.Join(froms, " ") != strings.Join(tc.froms, " ") {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.froms, froms)
+		}
+		if (key == nil) != (tc.key == nil) || (key != nil && *key != *tc.key) {
+			t.Errorf("Test %d: expected key %v, got %v", i, tc.key, key)
+		}
+	}
+}
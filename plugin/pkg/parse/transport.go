@@ -32,6 +32,21 @@ func Transport(s string) (trans string, addr string) {
 		s = s[len(transport.HTTP+"://"):]
 
 		return transport.HTTP, s
+
+	case strings.HasPrefix(s, transport.QUIC+"://"):
+		s = s[len(transport.QUIC+"://"):]
+
+		return transport.QUIC, s
+
+	case strings.HasPrefix(s, transport.H3+"://"):
+		s = s[len(transport.H3+"://"):]
+
+		return transport.H3, s
+
+	case strings.HasPrefix(s, transport.DTLS+"://"):
+		s = s[len(transport.DTLS+"://"):]
+
+		return transport.DTLS, s
 	}
 
 	return transport.DNS, s
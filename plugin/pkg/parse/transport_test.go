@@ -17,6 +17,9 @@ func TestTransport(t *testing.T) {
 		{"tls://example.org ", transport.TLS},
 		{"https://example.org ", transport.HTTPS},
 		{"http://example.org ", transport.HTTP},
+		{"quic://example.org ", transport.QUIC},
+		{"h3://example.org ", transport.H3},
+		{"dtls://example.org ", transport.DTLS},
 	} {
 		actual, _ := Transport(test.input)
 		if actual != test.expected {
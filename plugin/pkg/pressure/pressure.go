@@ -0,0 +1,87 @@
+// Package pressure tells the plugins how much load to shed when the memory of CoreDNS nears its
+// limit, see the shed plugin.
+//
+// The stage only goes up when something sets it: without the shed plugin it stays None and the
+// plugins behave as usual.
+package pressure
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Stage is how much load is shed, every stage also sheds what the ones before it do.
+type Stage int32
+
+const (
+	// None is the stage when there is no memory pressure.
+	None Stage = iota
+	// NoPrefetch stops the caches from prefetching.
+	NoPrefetch
+	// ShrinkCache shrinks the caches to half their capacity.
+	ShrinkCache
+	// Refuse refuses the queries of the clients with the lowest priority.
+	Refuse
+)
+
+func (s Stage) String() string {
+	switch s {
+	case None:
+		return "none"
+	case NoPrefetch:
+		return "no_prefetch"
+	case ShrinkCache:
+		return "shrink_cache"
+	case Refuse:
+		return "refuse"
+	}
+	return "unknown"
+}
+
+type watcher struct {
+	f func(Stage)
+}
+
+var (
+	mu       sync.Mutex
+	watchers []*watcher
+	stage    int32 // the current Stage, accessed atomically
+)
+
+// Current returns the current stage.
+func Current() Stage { return Stage(atomic.LoadInt32(&stage)) }
+
+// Set sets the current stage to s. When it changes, the watchers are called with s in the calling
+// goroutine, one change at a time, so they must not call Set or Watch.
+func Set(s Stage) {
+	mu.Lock()
+	defer mu.Unlock()
+	if Stage(atomic.SwapInt32(&stage, int32(s))) == s {
+		return
+	}
+	for _, w := range watchers {
+		w.f(s)
+	}
+}
+
+// Watch calls f with the stage every time it changes, until stop is called.
+func Watch(f func(Stage)) (stop func()) {
+	w := &watcher{f: f}
+	mu.Lock()
+	watchers = append(watchers[:len(watchers):len(watchers)], w)
+	mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			for i := range watchers {
+				if watchers[i] == w {
+					watchers = append(watchers[:i:i], watchers[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
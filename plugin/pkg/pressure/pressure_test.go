@@ -0,0 +1,24 @@
+package pressure
+
+import "testing"
+
+func TestWatch(t *testing.T) {
+	defer Set(None)
+
+	var got []Stage
+	stop := Watch(func(s Stage) { got = append(got, s) })
+
+	Set(NoPrefetch)
+	Set(NoPrefetch) // not a change
+	Set(Refuse)
+	if Current() != Refuse {
+		t.Errorf("Expected stage %s, got %s", Refuse, Current())
+	}
+	stop()
+	stop() // stopping twice is fine
+	Set(None)
+
+	if len(got) != 2 || got[0] != NoPrefetch || got[1] != Refuse {
+		t.Errorf("Expected the changes to %s and %s, got %v", NoPrefetch, Refuse, got)
+	}
+}
@@ -0,0 +1,199 @@
+// Package proxyproto reads the PROXY protocol header (versions 1 and 2) that load balancers put in
+// front of the TCP connections they relay, to tell the address of the client.
+//
+// See https://www.haproxy.org/download/2.0/doc/proxy-protocol.txt.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderTimeout is the time a client has to send the header.
+var HeaderTimeout = 5 * time.Second
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// v1MaxLen is the length of the longest version 1 header, CRLF included.
+const v1MaxLen = 107
+
+// Listener wraps a net.Listener, the connections from Trusted read the PROXY protocol header
+// before their data, and their RemoteAddr is the source address of the header. The header is
+// optional: a connection without one keeps its address. The connections from other addresses are
+// left alone.
+type Listener struct {
+	net.Listener
+	Trusted []*net.IPNet
+}
+
+// Accept implements the net.Listener interface. The header is read on the first call of Read or
+// RemoteAddr, not to block Accept on a slow client.
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.trusted(c.RemoteAddr()) {
+		return c, nil
+	}
+	return &Conn{Conn: c, r: bufio.NewReaderSize(c, v1MaxLen)}, nil
+}
+
+func (l *Listener) trusted(a net.Addr) bool {
+	t, ok := a.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, n := range l.Trusted {
+		if n.Contains(t.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a connection that starts with a PROXY protocol header.
+type Conn struct {
+	net.Conn
+	r *bufio.Reader
+
+	once sync.Once
+	src  net.Addr // the source address of the header, nil when there is none
+	err  error    // the error reading the header
+}
+
+// Read implements the net.Conn interface. It returns the error reading the header, if any.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+// RemoteAddr implements the net.Conn interface. It returns the source address of the header, or
+// the address of the connection without one.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.src != nil {
+		return c.src
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) readHeader() {
+	c.Conn.SetReadDeadline(time.Now().Add(HeaderTimeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	c.src, c.err = readHeader(c.r)
+	if c.err != nil {
+		c.Conn.Close()
+	}
+}
+
+// readHeader reads the header at the start of r, if any, and returns its source address. The source
+// address is nil for a connection without header, and for a header that doesn't relay a client
+// (LOCAL or UNKNOWN), like the health checks of the load balancer.
+func readHeader(r *bufio.Reader) (net.Addr, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		// Nothing to read, let the reads of the connection return the error.
+		return nil, nil
+	}
+	switch b[0] {
+	case v1Prefix[0]:
+		if b, err := r.Peek(len(v1Prefix)); err == nil && bytes.Equal(b, v1Prefix) {
+			return readV1(r)
+		}
+	case v2Signature[0]:
+		if b, err := r.Peek(len(v2Signature)); err == nil && bytes.Equal(b, v2Signature) {
+			return readV2(r)
+		}
+	}
+	return nil, nil
+}
+
+// readV1 reads a version 1 header: "PROXY TCP4 192.0.2.1 192.0.2.2 56324 53\r\n".
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		if err == bufio.ErrBufferFull {
+			return nil, errors.New("proxy protocol: header too long")
+		}
+		return nil, fmt.Errorf("proxy protocol: %s", err)
+	}
+	if len(line) > v1MaxLen || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("proxy protocol: malformed header")
+	}
+	fields := strings.Split(string(line[len(v1Prefix):len(line)-2]), " ")
+	switch fields[0] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, fmt.Errorf("proxy protocol: unknown protocol %q", fields[0])
+	}
+	if len(fields) != 5 {
+		return nil, errors.New("proxy protocol: malformed header")
+	}
+	ip := net.ParseIP(fields[1])
+	if ip == nil || (ip.To4() != nil) != (fields[0] == "TCP4") {
+		return nil, fmt.Errorf("proxy protocol: invalid source address %q", fields[1])
+	}
+	port, err := strconv.ParseUint(fields[3], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("proxy protocol: invalid source port %q", fields[3])
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readV2 reads a version 2 header, it is binary.
+func readV2(r *bufio.Reader) (net.Addr, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("proxy protocol: %s", err)
+	}
+	if hdr[12]>>4 != 2 {
+		return nil, fmt.Errorf("proxy protocol: unknown version %d", hdr[12]>>4)
+	}
+	// The addresses, followed by TLVs we don't use.
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("proxy protocol: %s", err)
+	}
+
+	switch hdr[12] & 0xF {
+	case 0: // LOCAL
+		return nil, nil
+	case 1: // PROXY
+	default:
+		return nil, fmt.Errorf("proxy protocol: unknown command %d", hdr[12]&0xF)
+	}
+
+	switch hdr[13] {
+	case 0x11: // TCP over IPv4
+		if len(body) < 12 {
+			return nil, errors.New("proxy protocol: short addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:]))}, nil
+	case 0x21: // TCP over IPv6
+		if len(body) < 36 {
+			return nil, errors.New("proxy protocol: short addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:]))}, nil
+	}
+	// UDP and UNIX sockets aren't relayed to us, and UNSPEC has no address.
+	return nil, nil
+}
@@ -0,0 +1,119 @@
+package proxyproto
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+)
+
+func v2(cmd, fam byte, addrs []byte) string {
+	b := append([]byte{}, v2Signature...)
+	b = append(b, 0x20|cmd, fam, byte(len(addrs)>>8), byte(len(addrs)))
+	return string(append(b, addrs...))
+}
+
+func TestReadHeader(t *testing.T) {
+	v4 := []byte{192, 0, 2, 1, 192, 0, 2, 2, 0xDC, 0x04, 0, 53}
+	v6 := make([]byte, 36)
+	copy(v6, net.ParseIP("2001:db8::1"))
+	copy(v6[16:], net.ParseIP("2001:db8::2"))
+	v6[32], v6[33] = 0xDC, 0x04
+
+	tests := []struct {
+		input     string
+		src       string
+		shouldErr bool
+	}{
+		{"PROXY TCP4 192.0.2.1 192.0.2.2 56324 53\r\ndata", "192.0.2.1:56324", false},
+		{"PROXY TCP6 2001:db8::1 2001:db8::2 56324 53\r\ndata", "[2001:db8::1]:56324", false},
+		{"PROXY UNKNOWN\r\ndata", "", false},
+		{"PROXY UNKNOWN ffff::1 ffff::2 1 2\r\ndata", "", false},
+		{v2(1, 0x11, v4) + "data", "192.0.2.1:56324", false},
+		{v2(1, 0x21, v6) + "data", "[2001:db8::1]:56324", false},
+		{v2(1, 0x11, append(v4, 0x04, 0, 1, 'x')) + "data", "192.0.2.1:56324", false}, // with a TLV
+		{v2(0, 0x00, nil) + "data", "", false},
+		{"data", "", false},
+		{"PROXZ", "", false},
+
+		{"PROXY TCP4 192.0.2.1 192.0.2.2 56324 53\n", "", true},
+		{"PROXY TCP4 192.0.2.1 192.0.2.2 56324\r\n", "", true},
+		{"PROXY TCP4 2001:db8::1 192.0.2.2 56324 53\r\n", "", true},
+		{"PROXY TCP4 192.0.2.1 192.0.2.2 port 53\r\n", "", true},
+		{"PROXY UDP4 192.0.2.1 192.0.2.2 56324 53\r\n", "", true},
+		{"PROXY TCP4 192.0.2.1 192.0.2.2 56324 53" + strings.Repeat(" ", 100) + "\r\n", "", true},
+		{v2(1, 0x11, v4[:8]) + "data", "", true},
+		{v2(2, 0x11, v4) + "data", "", true},
+		{v2(1, 0x11, v4)[:20], "", true},
+	}
+	for i, tc := range tests {
+		r := bufio.NewReaderSize(strings.NewReader(tc.input), v1MaxLen)
+		src, err := readHeader(r)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		got := ""
+		if src != nil {
+			got = src.String()
+		}
+		if got != tc.src {
+			t.Errorf("Test %d: expected source %q, got %q", i, tc.src, got)
+		}
+		// The data after the header is left to read.
+		rest, _ := ioutil.ReadAll(r)
+		expected := tc.input
+		if strings.HasSuffix(tc.input, "data") {
+			expected = "data"
+		}
+		if string(rest) != expected {
+			t.Errorf("Test %d: expected the data after the header, got %q", i, rest)
+		}
+	}
+}
+
+func TestListener(t *testing.T) {
+	for _, trusted := range []string{"127.0.0.0/8", "192.0.2.0/24"} {
+		_, n, _ := net.ParseCIDR(trusted)
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pl := &Listener{Listener: l, Trusted: []*net.IPNet{n}}
+
+		go func() {
+			c, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				return
+			}
+			c.Write([]byte("PROXY TCP4 198.51.100.1 192.0.2.2 56324 53\r\ndata"))
+			c.Close()
+		}()
+
+		c, err := pl.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := c.RemoteAddr().String()
+		data, _ := ioutil.ReadAll(c)
+		c.Close()
+		l.Close()
+
+		if trusted == "127.0.0.0/8" {
+			if src != "198.51.100.1:56324" || string(data) != "data" {
+				t.Errorf("Expected the header to be read from a trusted proxy, got %s %q", src, data)
+			}
+			continue
+		}
+		if src == "198.51.100.1:56324" || !strings.HasPrefix(string(data), "PROXY") {
+			t.Errorf("Expected the header to be left alone from an untrusted address, got %s %q", src, data)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// +build fuzz
+
+package sanitize
+
+import "github.com/miekg/dns"
+
+// Fuzz fuzzes the checks: they must not panic, and a query they accept must pass them again once
+// packed.
+func Fuzz(data []byte) int {
+	if err := Wire(data); err != nil {
+		return 0
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack(data); err != nil {
+		return 0
+	}
+	if err := Request(m); err != nil {
+		return 0
+	}
+	b, err := m.Pack()
+	if err != nil {
+		return 0
+	}
+	if err := Wire(b); err != nil {
+		panic("packed message fails Wire: " + err.Error())
+	}
+	return 1
+}
@@ -0,0 +1,287 @@
+// Package sanitize checks DNS messages for hostile or broken data. The server checks every query
+// with Request before the plugins see it, and every response with Response, or Wire for the
+// responses written as bytes, before it is written, so the plugins don't each have to defend
+// against them.
+package sanitize
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// MaxLabels is the largest number of labels of a name, the root label aside.
+	MaxLabels = 127
+	// MaxPointers is the largest number of compression pointers followed to read a name.
+	MaxPointers = 32
+	// MaxOptions is the largest number of EDNS0 options in a message.
+	MaxOptions = 32
+)
+
+// The errors of the checks.
+var (
+	ErrQuestion = errors.New("sanitize: not a single question")
+	ErrName     = errors.New("sanitize: invalid name")
+	ErrLabels   = errors.New("sanitize: too many labels")
+	ErrPointer  = errors.New("sanitize: invalid compression pointer")
+	ErrOPT      = errors.New("sanitize: invalid OPT record")
+	ErrOption   = errors.New("sanitize: invalid EDNS0 option")
+	ErrTrailing = errors.New("sanitize: trailing data")
+	ErrShort    = errors.New("sanitize: message too short")
+)
+
+// Request checks the query r: it has a single question, its names are valid, and its EDNS0 options
+// make sense.
+func Request(r *dns.Msg) error {
+	if len(r.Question) != 1 {
+		return ErrQuestion
+	}
+	return check(r)
+}
+
+// Response checks the response m: its names are valid, and its EDNS0 options make sense.
+func Response(m *dns.Msg) error {
+	if len(m.Question) > 1 {
+		return ErrQuestion
+	}
+	return check(m)
+}
+
+func check(m *dns.Msg) error {
+	for _, q := range m.Question {
+		if err := name(q.Name); err != nil {
+			return err
+		}
+	}
+	for _, s := range [][]dns.RR{m.Answer, m.Ns} {
+		for _, rr := range s {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				return ErrOPT
+			}
+			if err := name(rr.Header().Name); err != nil {
+				return err
+			}
+		}
+	}
+	opts := 0
+	for _, rr := range m.Extra {
+		if err := name(rr.Header().Name); err != nil {
+			return err
+		}
+		if o, ok := rr.(*dns.OPT); ok {
+			if opts++; opts > 1 || o.Hdr.Name != "." {
+				return ErrOPT
+			}
+			if err := options(o.Option); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// name checks that s is a valid name, of at most 255 octets and MaxLabels labels.
+func name(s string) error {
+	if s == "." {
+		return nil
+	}
+	labels, ok := dns.IsDomainName(s)
+	if !ok || wireLen(s) > 255 {
+		return ErrName
+	}
+	if labels > MaxLabels {
+		return ErrLabels
+	}
+	return nil
+}
+
+// wireLen returns the length of the valid name s in wire format: an escape is an octet, and a dot is
+// the length of the label before it.
+func wireLen(s string) int {
+	n := 1 // the root label
+	if !dns.IsFqdn(s) {
+		n++
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			if i+3 < len(s) && isDigit(s[i+1]) && isDigit(s[i+2]) && isDigit(s[i+3]) {
+				i += 3
+			} else {
+				i++
+			}
+		}
+		n++
+	}
+	return n
+}
+
+func isDigit(b byte) bool { return b >= '0' && b <= '9' }
+
+// options checks the EDNS0 options: there are at most MaxOptions of them, the ones that can't be
+// repeated aren't, and the data of the ones CoreDNS uses is in range.
+func options(opts []dns.EDNS0) error {
+	if len(opts) > MaxOptions {
+		return ErrOption
+	}
+	seen := map[uint16]bool{}
+	for _, o := range opts {
+		code := o.Option()
+		if code < dns.EDNS0LOCALSTART || code > dns.EDNS0LOCALEND {
+			if seen[code] {
+				return ErrOption
+			}
+			seen[code] = true
+		}
+		switch e := o.(type) {
+		case *dns.EDNS0_SUBNET:
+			max := uint8(32)
+			switch e.Family {
+			case 1:
+			case 2:
+				max = 128
+			default:
+				return ErrOption
+			}
+			if e.SourceNetmask > max || e.SourceScope > max {
+				return ErrOption
+			}
+		case *dns.EDNS0_COOKIE:
+			// A client cookie of 8 bytes, optionally followed by a server cookie of 8 to 32 bytes.
+			if n := len(e.Cookie) / 2; n != 8 && (n < 16 || n > 40) {
+				return ErrOption
+			}
+		case *dns.EDNS0_TCP_KEEPALIVE:
+			if e.Length != 0 && e.Length != 2 {
+				return ErrOption
+			}
+		}
+	}
+	return nil
+}
+
+// Wire checks the message b: its names are valid, and its compression pointers only point back, to
+// the names before them, so they can't loop. It only reads the names of the record data of the
+// types defined in RFC 1035 that hold them. Nothing follows the last record.
+func Wire(b []byte) error {
+	if len(b) < 12 {
+		return ErrShort
+	}
+	off := 12
+	var err error
+	for i := binary.BigEndian.Uint16(b[4:]); i > 0; i-- {
+		if off, err = wireName(b, off); err != nil {
+			return err
+		}
+		if off += 4; off > len(b) {
+			return ErrShort
+		}
+	}
+	records := int(binary.BigEndian.Uint16(b[6:])) + int(binary.BigEndian.Uint16(b[8:])) + int(binary.BigEndian.Uint16(b[10:]))
+	for i := 0; i < records; i++ {
+		if off, err = wireName(b, off); err != nil {
+			return err
+		}
+		if off+10 > len(b) {
+			return ErrShort
+		}
+		typ := binary.BigEndian.Uint16(b[off:])
+		rdlen := int(binary.BigEndian.Uint16(b[off+8:]))
+		off += 10
+		end := off + rdlen
+		if end > len(b) {
+			return ErrShort
+		}
+		if err := wireRdata(b[:end], off, typ); err != nil {
+			return err
+		}
+		off = end
+	}
+	if off != len(b) {
+		return ErrTrailing
+	}
+	return nil
+}
+
+// wireRdata checks the names of the record data of type typ at off, that ends with b.
+func wireRdata(b []byte, off int, typ uint16) error {
+	var err error
+	switch typ {
+	case dns.TypeNS, dns.TypeCNAME, dns.TypePTR, dns.TypeMD, dns.TypeMF, dns.TypeMB, dns.TypeMG, dns.TypeMR:
+		off, err = wireName(b, off)
+	case dns.TypeMX:
+		off, err = wireName(b, off+2)
+	case dns.TypeSOA, dns.TypeMINFO:
+		if off, err = wireName(b, off); err == nil {
+			off, err = wireName(b, off)
+		}
+		if err == nil && typ == dns.TypeSOA {
+			off += 20
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if off != len(b) {
+		return ErrShort
+	}
+	return nil
+}
+
+// wireName checks the name at off, and returns the offset following it.
+func wireName(b []byte, off int) (int, error) {
+	var (
+		next     = -1 // the offset following the name, once a pointer is followed
+		pointers = 0
+		labels   = 0
+		length   = 1 // the length of the name uncompressed, with the root label
+		start    = off
+	)
+	for {
+		if off >= len(b) {
+			return 0, ErrShort
+		}
+		c := int(b[off])
+		switch c & 0xC0 {
+		case 0x00:
+			if c == 0 {
+				if next < 0 {
+					next = off + 1
+				}
+				return next, nil
+			}
+			if off+1+c > len(b) {
+				return 0, ErrShort
+			}
+			if labels++; labels > MaxLabels {
+				return 0, ErrLabels
+			}
+			if length += 1 + c; length > 255 {
+				return 0, ErrName
+			}
+			off += 1 + c
+		case 0xC0:
+			if off+2 > len(b) {
+				return 0, ErrShort
+			}
+			ptr := int(binary.BigEndian.Uint16(b[off:]) & 0x3FFF)
+			// A pointer goes back to before the part of the name it ends, which rules out loops.
+			if ptr >= start || ptr < 12 {
+				return 0, ErrPointer
+			}
+			if pointers++; pointers > MaxPointers {
+				return 0, ErrPointer
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off, start = ptr, ptr
+		default:
+			// The extended label types are obsolete.
+			return 0, ErrName
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestRequest(t *testing.T) {
+	query := func(name string, opts ...dns.EDNS0) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		if len(opts) > 0 {
+			m.SetEdns0(4096, false)
+			o := m.IsEdns0()
+			o.Option = opts
+		}
+		return m
+	}
+	deep := strings.Repeat("a.", 128)
+
+	tests := []struct {
+		m        *dns.Msg
+		expected error
+	}{
+		{query("example.org."), nil},
+		{query("."), nil},
+		{query("example.org.", &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: []byte{192, 0, 2, 0}}), nil},
+		{query("example.org.", &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0123456789abcdef"}), nil},
+		{query("example.org.", &dns.EDNS0_LOCAL{Code: 65001}, &dns.EDNS0_LOCAL{Code: 65001}), nil},
+		{&dns.Msg{}, ErrQuestion},
+		{&dns.Msg{Question: []dns.Question{{Name: "a."}, {Name: "b."}}}, ErrQuestion},
+		{query(deep[:len(deep)-2]), nil},
+		{query(deep), ErrName}, // 128 labels, 257 octets
+		{query(strings.Repeat(strings.Repeat("a", 63)+".", 4)), ErrName}, // 257 octets
+		{query("a..example.org."), ErrName},
+		{query(strings.Repeat("a", 64) + ".example.org."), ErrName},
+		{query("example.org.", &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 33}), ErrOption},
+		{query("example.org.", &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 3}), ErrOption},
+		{query("example.org.", &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0123"}), ErrOption},
+		{query("example.org.", &dns.EDNS0_NSID{Code: dns.EDNS0NSID}, &dns.EDNS0_NSID{Code: dns.EDNS0NSID}), ErrOption},
+		{query("example.org.", &dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE, Length: 4}), ErrOption},
+	}
+	for i, tc := range tests {
+		if err := Request(tc.m); err != tc.expected {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.expected, err)
+		}
+	}
+
+	// A second OPT record, and one out of the additional section.
+	m := query("example.org.", &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+	m.Extra = append(m.Extra, m.Extra[0])
+	if err := Request(m); err != ErrOPT {
+		t.Errorf("Expected %v for two OPT records, got %v", ErrOPT, err)
+	}
+	m = query("example.org.", &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+	m.Answer, m.Extra = m.Extra, nil
+	if err := Request(m); err != ErrOPT {
+		t.Errorf("Expected %v for an OPT record in the answer, got %v", ErrOPT, err)
+	}
+}
+
+func TestResponse(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Answer = []dns.RR{test.A("example.org. 300 IN A 192.0.2.1")}
+	if err := Response(m); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: "bad..example.org.", Rrtype: dns.TypeA, Class: dns.ClassINET}})
+	if err := Response(m); err != ErrName {
+		t.Errorf("Expected %v, got %v", ErrName, err)
+	}
+}
+
+func TestWire(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeMX)
+	m.Answer = []dns.RR{
+		test.MX("example.org. 300 IN MX 10 mx.example.org."),
+		test.CNAME("www.example.org. 300 IN CNAME example.org."),
+	}
+	m.Ns = []dns.RR{test.SOA("example.org. 300 IN SOA ns.example.org. hostmaster.example.org. 1 2 3 4 5")}
+	m.SetEdns0(4096, true)
+	m.Compress = true
+	valid, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := []byte{0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	question := func(name ...byte) []byte {
+		b := append(append([]byte{}, header...), name...)
+		return append(b, 0, 1, 0, 1)
+	}
+
+	tests := []struct {
+		b        []byte
+		expected error
+	}{
+		{valid, nil},
+		{valid[:len(valid)-1], ErrShort},
+		{append(append([]byte{}, valid...), 0), ErrTrailing},
+		{header[:10], ErrShort},
+		{question(1, 'a', 0), nil},
+		{question(0xC0, 12), ErrPointer},            // points to itself
+		{question(1, 'a', 0xC0, 12), ErrPointer},    // points to its own first label
+		{question(0xC0, 14, 1, 'a', 0), ErrPointer}, // points forward
+		{question(0xC0, 2), ErrPointer},             // points into the header
+		{question(0x40, 'a', 0), ErrName},           // extended label type
+		{question(5, 'a', 0), ErrShort},
+	}
+	for i, tc := range tests {
+		if err := Wire(tc.b); err != tc.expected {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.expected, err)
+		}
+	}
+
+	// A chain of pointers going back: fine up to MaxPointers.
+	chain := func(n int) []byte {
+		b := []byte{0, 1, 0, 0, 0, byte(n + 1), 0, 0, 0, 0, 0, 0}
+		b = append(b, 1, 'a', 0, 0, 1, 0, 1)
+		for prev := 12; n > 0; n-- {
+			off := len(b)
+			b = append(b, 1, 'a', 0xC0|byte(prev>>8), byte(prev), 0, 1, 0, 1)
+			prev = off
+		}
+		return b
+	}
+	if err := Wire(chain(MaxPointers)); err != nil {
+		t.Errorf("Expected no error for %d pointers, got %v", MaxPointers, err)
+	}
+	if err := Wire(chain(MaxPointers + 1)); err != ErrPointer && err != ErrLabels {
+		t.Errorf("Expected an error for %d pointers, got %v", MaxPointers+1, err)
+	}
+}
+
+func TestWireLen(t *testing.T) {
+	label := strings.Repeat("a", 63)
+	tests := []struct {
+		name     string
+		expected int
+	}{
+		{"example.org.", 13},
+		{"example.org", 13},
+		{`ex\.ample.org.`, 14},
+		{`ex\097mple.org.`, 13},
+		{label + "." + label + "." + label + "." + label + ".", 257},
+	}
+	for i, tc := range tests {
+		if n := wireLen(tc.name); n != tc.expected {
+			t.Errorf("Test %d: expected %d, got %d", i, tc.expected, n)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Package transfer holds the transfer ACLs and the outgoing NOTIFYs shared by the plugins that
+// transfer their zones to secondaries.
+package transfer
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/rcode"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Allowed checks if the request for transferring a zone is allowed by the ACLs: its source IP must
+// match one of the addresses in to (or to holds "*"), and when keys has a TSIG key name for that
+// address the request must be signed with that key.
+func Allowed(state request.Request, to []string, keys map[string]string) bool {
+	remote := state.IP()
+	for _, t := range to {
+		if t != "*" {
+			// If remote IP matches we accept.
+			host, _, err := net.SplitHostPort(t)
+			if err != nil || host != remote {
+				continue
+			}
+		}
+		if Signed(state, keys[t]) {
+			return true
+		}
+	}
+	// TODO(miek): future matching against IP/CIDR notations
+	return false
+}
+
+// Signed returns true if the request is signed with the TSIG key name and the server verified the
+// signature. An empty name requires nothing.
+func Signed(state request.Request, name string) bool {
+	if name == "" {
+		return true
+	}
+	t := state.Req.IsTsig()
+	if t == nil || !strings.EqualFold(t.Hdr.Name, name) {
+		return false
+	}
+	return state.W.TsigStatus() == nil
+}
+
+// Sign adds a TSIG for the key name to m, the client sending m must have its secret. An empty name
+// leaves m unsigned.
+func Sign(m *dns.Msg, name string) {
+	if name == "" {
+		return
+	}
+	m.SetTsig(name, dns.HmacSHA256, 300, time.Now().Unix())
+}
+
+// Notify sends notifies for zone to the secondaries in to, skipping "*". The notify to an address
+// that has a TSIG key name in keys is signed with it, secrets holds the secrets of those keys. It
+// will try up to three times before giving up on a specific remote. We will sequentially loop
+// through to until they all have replied (or have 3 failed attempts).
+func Notify(zone string, to []string, keys, secrets map[string]string) {
+	c := &dns.Client{TsigSecret: secrets}
+
+	for _, t := range to {
+		if t == "*" {
+			continue
+		}
+		m := new(dns.Msg)
+		m.SetNotify(zone)
+		Sign(m, keys[t])
+		if err := notifyAddr(c, m, t); err != nil {
+			log.Error(err.Error())
+		} else {
+			log.Infof("Sent notify for zone %q to %q", zone, t)
+		}
+	}
+}
+
+func notifyAddr(c *dns.Client, m *dns.Msg, s string) error {
+	var err error
+
+	code := dns.RcodeServerFailure
+	for i := 0; i < 3; i++ {
+		ret, _, err := c.Exchange(m, s)
+		if err != nil {
+			continue
+		}
+		code = ret.Rcode
+		if code == dns.RcodeSuccess {
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("notify for zone %q was not accepted by %q: %q", m.Question[0].Name, s, err)
+	}
+	return fmt.Errorf("notify for zone %q was not accepted by %q: rcode was %q", m.Question[0].Name, s, rcode.ToString(code))
+}
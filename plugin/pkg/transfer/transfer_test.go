@@ -0,0 +1,106 @@
+package transfer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// tsigWriter is a test.ResponseWriter with a TSIG status.
+type tsigWriter struct {
+	test.ResponseWriter
+	status error
+}
+
+func (w *tsigWriter) TsigStatus() error { return w.status }
+
+func TestAllowed(t *testing.T) {
+	keys := map[string]string{"10.240.0.1:53": "xfr.example.org."}
+
+	tests := []struct {
+		to     []string
+		keys   map[string]string
+		key    string // the key the request is signed with
+		status error
+		ok     bool
+	}{
+		{to: []string{"10.240.0.1:53"}, ok: true},
+		{to: []string{"*"}, ok: true},
+		{to: []string{"10.240.0.2:53"}, ok: false},
+		{to: nil, ok: false},
+		{to: []string{"10.240.0.1:53"}, keys: keys, ok: false},
+		{to: []string{"10.240.0.1:53"}, keys: keys, key: "xfr.example.org.", ok: true},
+		{to: []string{"10.240.0.1:53"}, keys: keys, key: "XFR.example.org.", ok: true},
+		{to: []string{"10.240.0.1:53"}, keys: keys, key: "other.example.org.", ok: false},
+		{to: []string{"10.240.0.1:53"}, keys: keys, key: "xfr.example.org.", status: dns.ErrSig, ok: false},
+		{to: []string{"*"}, keys: map[string]string{"*": "xfr.example.org."}, ok: false},
+		{to: []string{"*"}, keys: map[string]string{"*": "xfr.example.org."}, key: "xfr.example.org.", ok: true},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetAxfr("example.org.")
+		if tc.key != "" {
+			m.SetTsig(tc.key, dns.HmacSHA256, 300, time.Now().Unix())
+		}
+		state := request.Request{W: &tsigWriter{status: tc.status}, Req: m}
+		if ok := Allowed(state, tc.to, tc.keys); ok != tc.ok {
+			t.Errorf("Test %d: expected allowed to be %t, got %t", i, tc.ok, ok)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	notified := make(chan string, 1)
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		if r.Opcode == dns.OpcodeNotify {
+			notified <- r.Question[0].Name
+		}
+	})
+	defer s.Close()
+
+	Notify("example.org.", []string{"*", s.Addr}, nil, nil)
+
+	select {
+	case zone := <-notified:
+		if zone != "example.org." {
+			t.Errorf("Expected a notify for %q, got %q", "example.org.", zone)
+		}
+	default:
+		t.Error("Expected a notify, got none")
+	}
+}
+
+func TestNotifySigned(t *testing.T) {
+	key := make(chan string, 1)
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		if tsig := r.IsTsig(); tsig != nil {
+			key <- tsig.Hdr.Name
+		}
+	})
+	defer s.Close()
+
+	keys := map[string]string{s.Addr: "xfr.example.org."}
+	secrets := map[string]string{"xfr.example.org.": "c2VjcmV0"}
+	Notify("example.org.", []string{s.Addr}, keys, secrets)
+
+	select {
+	case name := <-key:
+		if name != "xfr.example.org." {
+			t.Errorf("Expected a notify signed with %q, got %q", "xfr.example.org.", name)
+		}
+	default:
+		t.Error("Expected a signed notify, got none")
+	}
+}
@@ -6,7 +6,10 @@ const (
 	TLS   = "tls"
 	GRPC  = "grpc"
 	HTTPS = "https"
-	HTTP = "http"
+	HTTP  = "http"
+	QUIC  = "quic"
+	H3    = "h3"
+	DTLS  = "dtls"
 )
 
 // Port numbers for the various transports.
@@ -21,4 +24,10 @@ const (
 	HTTPSPort = "443"
 	// HTTPPort is the default port for DNS-over-HTTP.
 	HTTPPort = "80"
+	// QUICPort is the default port for DNS-over-QUIC.
+	QUICPort = "853"
+	// H3Port is the default port for DNS-over-HTTP/3.
+	H3Port = "443"
+	// DTLSPort is the default port for DNS-over-DTLS.
+	DTLSPort = "853"
 )
@@ -0,0 +1,176 @@
+// Package watch calls a function when a file or directory changes on disk.
+//
+// Files are watched through their parent directory, this makes it work with files that are replaced
+// instead of written to, like editors and Kubernetes do. A Kubernetes ConfigMap volume holds symlinks
+// to a "..data" symlink that is swapped to a new directory on update; a watched file is considered
+// changed when the file its symlinks resolve to changes, even when the symlink itself stays the same.
+//
+// All watches share a single fsnotify watcher. Changes are debounced, a burst of events calls the
+// function once.
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Debounce is the time we wait after an event before calling the function, events that come in
+// during this time are coalesced.
+var Debounce = 100 * time.Millisecond
+
+// File calls f when the file at path is created, removed, written to or replaced, either directly or
+// by changing one of the symlinks leading to it. It returns a function that stops the watch.
+func File(path string, f func()) (func(), error) {
+	path = filepath.Clean(path)
+	return add(&watch{dir: filepath.Dir(path), path: path, state: stat(path), f: f})
+}
+
+// Dir calls f when an entry in the directory path is created, removed, written to or renamed. Only
+// the entries directly in path are watched, not those in its subdirectories. It returns a function
+// that stops the watch.
+func Dir(path string, f func()) (func(), error) {
+	return add(&watch{dir: filepath.Clean(path), f: f})
+}
+
+// watch is a single watch on a file or, when path is empty, a directory.
+type watch struct {
+	dir  string
+	path string
+	f    func()
+
+	mu    sync.Mutex
+	state state
+	timer *time.Timer
+}
+
+// state is the state of a watched file.
+type state struct {
+	exists bool
+	real   string // path with all symlinks resolved
+	mod    time.Time
+	size   int64
+}
+
+func stat(path string) state {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return state{}
+	}
+	real, _ := filepath.EvalSymlinks(path)
+	return state{exists: true, real: real, mod: fi.ModTime(), size: fi.Size()}
+}
+
+// event is called for each event in w's directory.
+func (w *watch) event() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		return
+	}
+	w.timer = time.AfterFunc(Debounce, w.fire)
+}
+
+func (w *watch) fire() {
+	w.mu.Lock()
+	w.timer = nil
+	if w.path != "" {
+		s := stat(w.path)
+		if s == w.state {
+			w.mu.Unlock()
+			return
+		}
+		w.state = s
+	}
+	w.mu.Unlock()
+	w.f()
+}
+
+func (w *watch) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// watcher holds all watches, keyed by directory.
+var watcher struct {
+	sync.Mutex
+	w       *fsnotify.Watcher
+	watches map[string][]*watch
+}
+
+func add(w *watch) (func(), error) {
+	watcher.Lock()
+	defer watcher.Unlock()
+
+	if watcher.w == nil {
+		fw, err := fsnotify.NewWatcher()
+		if err != nil {
+			return nil, err
+		}
+		watcher.w = fw
+		watcher.watches = make(map[string][]*watch)
+		go loop(fw)
+	}
+	if len(watcher.watches[w.dir]) == 0 {
+		if err := watcher.w.Add(w.dir); err != nil {
+			return nil, err
+		}
+	}
+	watcher.watches[w.dir] = append(watcher.watches[w.dir], w)
+
+	var once sync.Once
+	return func() { once.Do(func() { remove(w) }) }, nil
+}
+
+func remove(w *watch) {
+	watcher.Lock()
+	defer watcher.Unlock()
+
+	w.stop()
+	ws := watcher.watches[w.dir]
+	for i := range ws {
+		if ws[i] == w {
+			ws = append(ws[:i], ws[i+1:]...)
+			break
+		}
+	}
+	if len(ws) > 0 {
+		watcher.watches[w.dir] = ws
+		return
+	}
+	delete(watcher.watches, w.dir)
+	watcher.w.Remove(w.dir)
+}
+
+func loop(fw *fsnotify.Watcher) {
+	for {
+		select {
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return
+			}
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			watcher.Lock()
+			ws := append([]*watch(nil), watcher.watches[filepath.Dir(ev.Name)]...)
+			watcher.Unlock()
+			for _, w := range ws {
+				w.event()
+			}
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return
+			}
+			log.Warningf("Error watching files: %s", err)
+		}
+	}
+}
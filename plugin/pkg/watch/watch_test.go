@@ -0,0 +1,112 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func init() { Debounce = 10 * time.Millisecond }
+
+func waitFor(t *testing.T, c chan struct{}, what string) {
+	select {
+	case <-c:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timeout waiting for %s", what)
+	}
+}
+
+func expectNone(t *testing.T, c chan struct{}, what string) {
+	select {
+	case <-c:
+		t.Fatalf("Unexpected %s", what)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "db.example.org")
+	ioutil.WriteFile(path, []byte("a"), 0644)
+
+	changed := make(chan struct{}, 10)
+	stop, err := File(path, func() { changed <- struct{}{} })
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "other"), []byte("a"), 0644)
+	expectNone(t, changed, "change for other file")
+
+	ioutil.WriteFile(path, []byte("ab"), 0644)
+	waitFor(t, changed, "write")
+
+	tmp := filepath.Join(dir, "tmp")
+	ioutil.WriteFile(tmp, []byte("abc"), 0644)
+	os.Rename(tmp, path)
+	waitFor(t, changed, "rename")
+
+	os.Remove(path)
+	waitFor(t, changed, "remove")
+
+	stop()
+	ioutil.WriteFile(path, []byte("a"), 0644)
+	expectNone(t, changed, "change after stop")
+}
+
+// TestFileConfigMap mimics the way a Kubernetes ConfigMap volume is updated.
+func TestFileConfigMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Corefile -> ..data/Corefile, ..data -> ..v1
+	os.Mkdir(filepath.Join(dir, "..v1"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "..v1", "Corefile"), []byte("v1"), 0644)
+	os.Symlink("..v1", filepath.Join(dir, "..data"))
+	os.Symlink("..data/Corefile", filepath.Join(dir, "Corefile"))
+
+	changed := make(chan struct{}, 10)
+	stop, err := File(filepath.Join(dir, "Corefile"), func() { changed <- struct{}{} })
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	defer stop()
+
+	// Update: write ..v2, point ..data_tmp at it and rename over ..data.
+	os.Mkdir(filepath.Join(dir, "..v2"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "..v2", "Corefile"), []byte("v2"), 0644)
+	os.Symlink("..v2", filepath.Join(dir, "..data_tmp"))
+	os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data"))
+	os.RemoveAll(filepath.Join(dir, "..v1"))
+	waitFor(t, changed, "symlink swap")
+}
+
+func TestDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	changed := make(chan struct{}, 10)
+	stop, err := Dir(dir, func() { changed <- struct{}{} })
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	defer stop()
+
+	ioutil.WriteFile(filepath.Join(dir, "db.example.org"), []byte("a"), 0644)
+	waitFor(t, changed, "create")
+	os.Remove(filepath.Join(dir, "db.example.org"))
+	waitFor(t, changed, "remove")
+}
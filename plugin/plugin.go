@@ -77,7 +77,7 @@ func NextOrFailure(name string, next Handler, ctx context.Context, w dns.Respons
 			defer child.Finish()
 			ctx = ot.ContextWithSpan(ctx, child)
 		}
-		return next.ServeDNS(ctx, w, r)
+		return ServeTimed(ctx, next, w, r)
 	}
 
 	return dns.RcodeServerFailure, Error(name, errors.New("no next plugin found"))
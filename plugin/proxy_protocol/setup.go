@@ -0,0 +1,61 @@
+// Package proxyprotocol implements a plugin that reads the client addresses from the PROXY protocol
+// header of the connections relayed by load balancers.
+package proxyprotocol
+
+import (
+	"net"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("proxy_protocol", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	nets, err := parse(c)
+	if err != nil {
+		return plugin.Error("proxy_protocol", err)
+	}
+	dnsserver.GetConfig(c).ProxyProtocol = nets
+	return nil
+}
+
+func parse(c *caddy.Controller) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return nil, c.ArgErr()
+		}
+		// A network is either in CIDR notation, or an address.
+		for _, a := range args {
+			_, n, err := net.ParseCIDR(a)
+			if err != nil {
+				ip := net.ParseIP(a)
+				if ip == nil {
+					return nil, c.Errf("invalid network: %s", a)
+				}
+				bits := 8 * net.IPv6len
+				if ip4 := ip.To4(); ip4 != nil {
+					ip, bits = ip4, 8*net.IPv4len
+				}
+				n = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+			}
+			nets = append(nets, n)
+		}
+	}
+	return nets, nil
+}
@@ -0,0 +1,50 @@
+package proxyprotocol
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  []string
+	}{
+		{`proxy_protocol 10.0.0.0/8`, false, []string{"10.0.0.0/8"}},
+		{`proxy_protocol 10.0.0.0/8 192.0.2.1 2001:db8::/32 2001:db8::1`, false,
+			[]string{"10.0.0.0/8", "192.0.2.1/32", "2001:db8::/32", "2001:db8::1/128"}},
+		{`proxy_protocol`, true, nil},
+		{`proxy_protocol 10.0.0.0/33`, true, nil},
+		{`proxy_protocol example.org`, true, nil},
+		{"proxy_protocol 10.0.0.0/8\nproxy_protocol 192.0.2.0/24", true, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		nets := dnsserver.GetConfig(c).ProxyProtocol
+		if len(nets) != len(tc.expected) {
+			t.Errorf("Test %d: expected %d networks, got %d", i, len(tc.expected), len(nets))
+			continue
+		}
+		for j, n := range nets {
+			if n.String() != tc.expected[j] {
+				t.Errorf("Test %d: expected network %s, got %s", i, tc.expected[j], n)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Package querytimeout implements a plugin that sets the deadline of the queries.
+package querytimeout
+
+import (
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("query_timeout", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	d, err := parse(c)
+	if err != nil {
+		return plugin.Error("query_timeout", err)
+	}
+	dnsserver.GetConfig(c).QueryTimeout = d
+	return nil
+}
+
+func parse(c *caddy.Controller) (time.Duration, error) {
+	var d time.Duration
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return 0, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return 0, c.ArgErr()
+		}
+		var err error
+		d, err = time.ParseDuration(args[0])
+		if err != nil {
+			return 0, c.Errf("invalid duration '%s'", args[0])
+		}
+		if d <= 0 {
+			return 0, c.Errf("duration must be positive: %s", d)
+		}
+	}
+	return d, nil
+}
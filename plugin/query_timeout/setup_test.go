@@ -0,0 +1,45 @@
+package querytimeout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  time.Duration
+	}{
+		{`query_timeout 2s`, false, 2 * time.Second},
+		{`query_timeout 10s`, false, 10 * time.Second},
+		{`query_timeout`, true, 0},
+		{`query_timeout 2s 20s`, true, 0},
+		{`query_timeout 0s`, true, 0},
+		{`query_timeout -5s`, true, 0},
+		{`query_timeout 10`, true, 0},
+		{"query_timeout 2s\nquery_timeout 20s", true, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if d := dnsserver.GetConfig(c).QueryTimeout; d != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, d)
+		}
+	}
+}
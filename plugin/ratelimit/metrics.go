@@ -0,0 +1,23 @@
+package ratelimit
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Variables declared for monitoring.
+var (
+	limitedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "ratelimit",
+		Name:      "limited_requests_total",
+		Help:      "Counter of requests over the rate limit of their client, per action.",
+	}, []string{"server", "action"})
+	limitedClients = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "ratelimit",
+		Name:      "limited_clients_total",
+		Help:      "Counter of the times a client went over its rate limit.",
+	}, []string{"server"})
+)
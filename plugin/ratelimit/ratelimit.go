@@ -0,0 +1,140 @@
+// Package ratelimit implements a plugin that limits the rate of the queries of each client.
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/clientnet"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// RateLimit is the plugin limiting the queries of each client, a network of the size of a prefix,
+// with a token bucket: a client may send rate queries per window, and burst queries at once.
+type RateLimit struct {
+	Next plugin.Handler
+
+	rate   float64       // the queries allowed per window
+	window time.Duration // the period of rate
+	burst  float64       // the capacity of the buckets
+
+	v4prefix int // the clients, by prefix length
+	v6prefix int
+	exempt   []*net.IPNet
+
+	action action
+
+	clients *cache.Cache // the buckets of the clients, by hash of their network
+	now     func() time.Time
+}
+
+// action is what is done with the queries over the limit.
+type action int
+
+const (
+	actionDrop action = iota
+	actionRefused
+	actionServfail
+)
+
+func (a action) String() string {
+	switch a {
+	case actionRefused:
+		return "refused"
+	case actionServfail:
+		return "servfail"
+	}
+	return "drop"
+}
+
+const (
+	defaultWindow     = time.Second
+	defaultV4Prefix   = 32
+	defaultV6Prefix   = 56
+	defaultMaxClients = 100000
+)
+
+// bucket is the token bucket of a client.
+type bucket struct {
+	sync.Mutex
+	tokens  float64
+	last    time.Time
+	limited bool // whether the last query was over the limit
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (rl *RateLimit) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	ip := net.ParseIP(state.IP())
+	if ip == nil || clientnet.Contains(rl.exempt, ip) {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+
+	allowed, first := rl.allow(clientnet.Mask(ip, rl.v4prefix, rl.v6prefix))
+	if allowed {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+
+	server := metrics.WithServer(ctx)
+	if first {
+		limitedClients.WithLabelValues(server).Inc()
+	}
+	limitedCount.WithLabelValues(server, rl.action.String()).Inc()
+
+	switch rl.action {
+	case actionRefused:
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+	case actionServfail:
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+	}
+	return dns.RcodeSuccess, nil
+}
+
+// Name implements the plugin.Handler interface.
+func (rl *RateLimit) Name() string { return "ratelimit" }
+
+// allow takes a token from the bucket of the client network n, and returns true if there was one.
+// first is true when the client just went over the limit, after queries within it.
+func (rl *RateLimit) allow(n net.IP) (allowed, first bool) {
+	now := rl.now()
+	key := cache.Hash(n)
+
+	var b *bucket
+	if v, ok := rl.clients.Get(key); ok {
+		b = v.(*bucket)
+	} else {
+		// A new client starts with a full bucket. Two queries racing here may both create one, the
+		// client then gets a few more tokens once, which is fine.
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.clients.Add(key, b)
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += rl.rate * float64(elapsed) / float64(rl.window)
+		if b.tokens > rl.burst {
+			b.tokens = rl.burst
+		}
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		b.limited = false
+		return true, false
+	}
+	first = !b.limited
+	b.limited = true
+	return false, first
+}
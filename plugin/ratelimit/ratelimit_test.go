@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestRateLimit(t *testing.T) {
+	c := caddy.NewTestController("dns", "ratelimit 2 {\nprefix 24 48\naction refused\nexempt 10.0.0.1\n}")
+	rl, err := parse(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	now := time.Unix(1000, 0)
+	rl.now = func() time.Time { return now }
+
+	tests := []struct {
+		elapsed  time.Duration
+		remote   string
+		expected int
+	}{
+		{0, "192.0.2.1", dns.RcodeSuccess},
+		{0, "192.0.2.2", dns.RcodeSuccess}, // same /24
+		{0, "192.0.2.3", dns.RcodeRefused},
+		{0, "198.51.100.1", dns.RcodeSuccess},
+		{0, "2001:db8::1", dns.RcodeSuccess},
+		{0, "2001:db8::2", dns.RcodeSuccess}, // same /48
+		{0, "2001:db8::3", dns.RcodeRefused},
+		{0, "2001:db8:1::1", dns.RcodeSuccess},
+		{0, "10.0.0.1", dns.RcodeSuccess},
+		{0, "10.0.0.1", dns.RcodeSuccess},
+		{0, "10.0.0.1", dns.RcodeSuccess}, // exempted
+		{500 * time.Millisecond, "192.0.2.1", dns.RcodeSuccess},
+		{0, "192.0.2.1", dns.RcodeRefused},
+		{5 * time.Second, "192.0.2.1", dns.RcodeSuccess},
+		{0, "192.0.2.1", dns.RcodeSuccess},
+		{0, "192.0.2.1", dns.RcodeRefused}, // the burst caps the tokens
+	}
+	for i, tc := range tests {
+		now = now.Add(tc.elapsed)
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		w := dnstest.NewRecorder(&remoteWriter{remote: tc.remote})
+		if _, err := rl.ServeDNS(context.Background(), w, m); err != nil {
+			t.Fatalf("Test %d: %s", i, err)
+		}
+		if w.Rcode != tc.expected {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.expected, w.Rcode)
+		}
+	}
+}
+
+func TestRateLimitDrop(t *testing.T) {
+	c := caddy.NewTestController("dns", "ratelimit 1")
+	rl, err := parse(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl.Next = test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	rl.now = func() time.Time { return time.Unix(1000, 0) }
+
+	for i, written := range []bool{true, false} {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		w := dnstest.NewRecorder(&remoteWriter{remote: "192.0.2.1"})
+		if _, err := rl.ServeDNS(context.Background(), w, m); err != nil {
+			t.Fatalf("Test %d: %s", i, err)
+		}
+		if (w.Msg != nil) != written {
+			t.Errorf("Test %d: expected a response written %t, got %t", i, written, w.Msg != nil)
+		}
+	}
+}
+
+type remoteWriter struct {
+	test.ResponseWriter
+	remote string
+}
+
+func (w *remoteWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP(w.remote), Port: 40212}
+}
@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/clientnet"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("ratelimit", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	rl, err := parse(c)
+	if err != nil {
+		return plugin.Error("ratelimit", err)
+	}
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		rl.Next = next
+		return rl
+	})
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, limitedCount, limitedClients)
+		return nil
+	})
+	return nil
+}
+
+func parse(c *caddy.Controller) (*RateLimit, error) {
+	rl := &RateLimit{
+		window:   defaultWindow,
+		v4prefix: defaultV4Prefix,
+		v6prefix: defaultV6Prefix,
+		action:   actionDrop,
+		now:      time.Now,
+	}
+	maxClients := defaultMaxClients
+	burst := 0
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return nil, c.ArgErr()
+		}
+		rate, err := strconv.Atoi(args[0])
+		if err != nil || rate <= 0 {
+			return nil, c.Errf("invalid rate: %s", args[0])
+		}
+		rl.rate = float64(rate)
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "window":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil || d <= 0 {
+					return nil, c.Errf("invalid window: %s", args[0])
+				}
+				rl.window = d
+			case "burst":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n <= 0 {
+					return nil, c.Errf("invalid burst: %s", args[0])
+				}
+				burst = n
+			case "prefix":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n <= 0 || n > 8*net.IPv4len {
+					return nil, c.Errf("invalid IPv4 prefix length: %s", args[0])
+				}
+				rl.v4prefix = n
+				if len(args) == 2 {
+					n, err := strconv.Atoi(args[1])
+					if err != nil || n <= 0 || n > 8*net.IPv6len {
+						return nil, c.Errf("invalid IPv6 prefix length: %s", args[1])
+					}
+					rl.v6prefix = n
+				}
+			case "action":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				switch args[0] {
+				case "drop":
+					rl.action = actionDrop
+				case "refused":
+					rl.action = actionRefused
+				case "servfail":
+					rl.action = actionServfail
+				default:
+					return nil, c.Errf("invalid action: %s", args[0])
+				}
+			case "max_clients":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n <= 0 {
+					return nil, c.Errf("invalid max_clients: %s", args[0])
+				}
+				maxClients = n
+			case "exempt":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					n, err := clientnet.Parse(a)
+					if err != nil {
+						return nil, c.Errf("invalid network: %s", a)
+					}
+					rl.exempt = append(rl.exempt, n)
+				}
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+
+	rl.burst = rl.rate
+	if burst > 0 {
+		rl.burst = float64(burst)
+	}
+	rl.clients = cache.New(maxClients)
+	return rl, nil
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		rate      float64
+		window    time.Duration
+		burst     float64
+		v4prefix  int
+		v6prefix  int
+		action    action
+		exempt    int
+	}{
+		{`ratelimit 100`, false, 100, time.Second, 100, 32, 56, actionDrop, 0},
+		{"ratelimit 600 {\nwindow 1m\nburst 50\n}", false, 600, time.Minute, 50, 32, 56, actionDrop, 0},
+		{"ratelimit 10 {\nprefix 24\naction refused\n}", false, 10, time.Second, 10, 24, 56, actionRefused, 0},
+		{"ratelimit 10 {\nprefix 24 48\naction servfail\nmax_clients 1000\n}", false, 10, time.Second, 10, 24, 48, actionServfail, 0},
+		{"ratelimit 10 {\nexempt 10.0.0.0/8 192.0.2.1\nexempt 2001:db8::/32\n}", false, 10, time.Second, 10, 32, 56, actionDrop, 3},
+		{`ratelimit`, true, 0, 0, 0, 0, 0, 0, 0},
+		{`ratelimit 0`, true, 0, 0, 0, 0, 0, 0, 0},
+		{`ratelimit ten`, true, 0, 0, 0, 0, 0, 0, 0},
+		{`ratelimit 10 20`, true, 0, 0, 0, 0, 0, 0, 0},
+		{"ratelimit 10 {\nwindow 0s\n}", true, 0, 0, 0, 0, 0, 0, 0},
+		{"ratelimit 10 {\nburst 0\n}", true, 0, 0, 0, 0, 0, 0, 0},
+		{"ratelimit 10 {\nprefix 33\n}", true, 0, 0, 0, 0, 0, 0, 0},
+		{"ratelimit 10 {\nprefix 24 129\n}", true, 0, 0, 0, 0, 0, 0, 0},
+		{"ratelimit 10 {\naction truncate\n}", true, 0, 0, 0, 0, 0, 0, 0},
+		{"ratelimit 10 {\nmax_clients -1\n}", true, 0, 0, 0, 0, 0, 0, 0},
+		{"ratelimit 10 {\nexempt example.org\n}", true, 0, 0, 0, 0, 0, 0, 0},
+		{"ratelimit 10 {\nrate 20\n}", true, 0, 0, 0, 0, 0, 0, 0},
+		{"ratelimit 10\nratelimit 20", true, 0, 0, 0, 0, 0, 0, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		rl, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if rl.rate != tc.rate || rl.window != tc.window || rl.burst != tc.burst {
+			t.Errorf("Test %d: expected %v per %s, burst %v, got %v per %s, burst %v", i, tc.rate, tc.window, tc.burst, rl.rate, rl.window, rl.burst)
+		}
+		if rl.v4prefix != tc.v4prefix || rl.v6prefix != tc.v6prefix {
+			t.Errorf("Test %d: expected prefixes /%d and /%d, got /%d and /%d", i, tc.v4prefix, tc.v6prefix, rl.v4prefix, rl.v6prefix)
+		}
+		if rl.action != tc.action {
+			t.Errorf("Test %d: expected action %s, got %s", i, tc.action, rl.action)
+		}
+		if len(rl.exempt) != tc.exempt {
+			t.Errorf("Test %d: expected %d exempted networks, got %d", i, tc.exempt, len(rl.exempt))
+		}
+	}
+}
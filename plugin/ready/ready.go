@@ -41,17 +41,7 @@ func (rd *ready) onStartup() error {
 	rd.done = true
 	rd.Unlock()
 
-	rd.mux.HandleFunc("/ready", func(w http.ResponseWriter, _ *http.Request) {
-		ok, todo := plugins.Ready()
-		if ok {
-			w.WriteHeader(http.StatusOK)
-			io.WriteString(w, "OK")
-			return
-		}
-		log.Infof("Still waiting on: %q", todo)
-		w.WriteHeader(http.StatusServiceUnavailable)
-		io.WriteString(w, todo)
-	})
+	rd.mux.HandleFunc("/ready", serveReady)
 
 	go func() { http.Serve(rd.ln, rd.mux) }()
 
@@ -71,3 +61,16 @@ func (rd *ready) onFinalShutdown() error {
 	rd.done = false
 	return nil
 }
+
+// serveReady responds to the readiness checks, with the plugins that are not ready yet if any.
+func serveReady(w http.ResponseWriter, _ *http.Request) {
+	ok, todo := plugins.Ready()
+	if ok {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "OK")
+		return
+	}
+	log.Infof("Still waiting on: %q", todo)
+	w.WriteHeader(http.StatusServiceUnavailable)
+	io.WriteString(w, todo)
+}
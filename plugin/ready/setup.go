@@ -2,6 +2,7 @@ package ready
 
 import (
 	"net"
+	"net/http"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
@@ -17,18 +18,28 @@ func init() {
 }
 
 func setup(c *caddy.Controller) error {
-	addr, err := parse(c)
+	addr, doh, err := parse(c)
 	if err != nil {
 		return plugin.Error("ready", err)
 	}
 	rd := &ready{Addr: addr}
 
-	uniqAddr.Set(addr, rd.onStartup)
-	c.OnStartup(func() error { uniqAddr.Set(addr, rd.onStartup); return nil })
-	c.OnRestartFailed(func() error { uniqAddr.Set(addr, rd.onStartup); return nil })
+	if doh {
+		dnsserver.GetConfig(c).AddHTTPHandler("/ready", http.HandlerFunc(serveReady))
+	}
+
+	// Without an address /ready is only served by the DNS-over-HTTPS servers.
+	if addr != "" {
+		uniqAddr.Set(addr, rd.onStartup)
+		c.OnStartup(func() error { uniqAddr.Set(addr, rd.onStartup); return nil })
+		c.OnRestartFailed(func() error { uniqAddr.Set(addr, rd.onStartup); return nil })
 
-	c.OnStartup(func() error { return uniqAddr.ForEach() })
-	c.OnRestartFailed(func() error { return uniqAddr.ForEach() })
+		c.OnStartup(func() error { return uniqAddr.ForEach() })
+		c.OnRestartFailed(func() error { return uniqAddr.ForEach() })
+
+		c.OnRestart(rd.onFinalShutdown)
+		c.OnFinalShutdown(rd.onFinalShutdown)
+	}
 
 	c.OnStartup(func() error {
 		for _, p := range dnsserver.GetConfig(c).Handlers() {
@@ -47,18 +58,16 @@ func setup(c *caddy.Controller) error {
 		return nil
 	})
 
-	c.OnRestart(rd.onFinalShutdown)
-	c.OnFinalShutdown(rd.onFinalShutdown)
-
 	return nil
 }
 
-func parse(c *caddy.Controller) (string, error) {
-	addr := ":8181"
+func parse(c *caddy.Controller) (string, bool, error) {
+	addr := ""
+	doh := false
 	i := 0
 	for c.Next() {
 		if i > 0 {
-			return "", plugin.ErrOnce
+			return "", false, plugin.ErrOnce
 		}
 		i++
 		args := c.RemainingArgs()
@@ -68,11 +77,27 @@ func parse(c *caddy.Controller) (string, error) {
 		case 1:
 			addr = args[0]
 			if _, _, e := net.SplitHostPort(addr); e != nil {
-				return "", e
+				return "", false, e
 			}
 		default:
-			return "", c.ArgErr()
+			return "", false, c.ArgErr()
 		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "doh":
+				if len(c.RemainingArgs()) != 0 {
+					return "", false, c.ArgErr()
+				}
+				doh = true
+			default:
+				return "", false, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	// The default listener, unless /ready is served by the DNS-over-HTTPS servers only.
+	if addr == "" && !doh {
+		addr = ":8181"
 	}
-	return addr, nil
+	return addr, doh, nil
 }
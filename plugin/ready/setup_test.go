@@ -8,18 +8,32 @@ import (
 
 func TestSetupReady(t *testing.T) {
 	tests := []struct {
-		input     string
-		shouldErr bool
+		input        string
+		shouldErr    bool
+		expectedAddr string
+		expectedDoH  bool
 	}{
-		{`ready`, false},
-		{`ready localhost:1234`, false},
-		{`ready localhost:1234 b`, true},
-		{`ready bla`, true},
-		{`ready bla bla`, true},
+		{`ready`, false, ":8181", false},
+		{`ready localhost:1234`, false, "localhost:1234", false},
+		{`ready {
+			doh
+		}`, false, "", true},
+		{`ready localhost:1234 {
+			doh
+		}`, false, "localhost:1234", true},
+		{`ready localhost:1234 b`, true, "", false},
+		{`ready bla`, true, "", false},
+		{`ready bla bla`, true, "", false},
+		{`ready {
+			dog
+		}`, true, "", false},
 	}
 
 	for i, test := range tests {
-		_, err := parse(caddy.NewTestController("dns", test.input))
+		addr, doh, err := parse(caddy.NewTestController("dns", test.input))
+		if err == nil && (addr != test.expectedAddr || doh != test.expectedDoH) {
+			t.Errorf("Test %d: Expected %q and doh %t, got %q and %t", i, test.expectedAddr, test.expectedDoH, addr, doh)
+		}
 
 		if test.shouldErr && err == nil {
 			t.Errorf("Test %d: Expected error but found none for input %s", i, test.input)
@@ -4,9 +4,15 @@ import (
 	"bytes"
 	"crypto/md5"
 	"encoding/json"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/coredns/coredns/core/corefile"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/watch"
+
 	"github.com/caddyserver/caddy"
 	"github.com/caddyserver/caddy/caddyfile"
 )
@@ -19,10 +25,11 @@ const (
 )
 
 type reload struct {
-	dur  time.Duration
-	u    int
-	mtx  sync.RWMutex
-	quit chan bool
+	dur     time.Duration
+	u       int
+	mtx     sync.RWMutex
+	quit    chan bool
+	restart sync.Mutex // serializes the restarts with the shutdown
 }
 
 func (r *reload) setUsage(u int) {
@@ -64,10 +71,13 @@ func hook(event caddy.EventName, info interface{}) error {
 
 	// if reload is removed from the Corefile, then the hook
 	// is still registered but setup is never called again
-	// so we need a flag to tell us not to reload
-	if r.usage() == unused {
+	// so we need a flag to tell us not to reload. The starting
+	// instance takes the flag, an instance started later
+	// doesn't reload unless its setup sets the flag again.
+	if r.usage() != used {
 		return nil
 	}
+	r.setUsage(unused)
 
 	// this should be an instance. ok to panic if not
 	instance := info.(*caddy.Instance)
@@ -79,41 +89,67 @@ func hook(event caddy.EventName, info interface{}) error {
 	md5sum := md5.Sum(parsedCorefile)
 	log.Infof("Running configuration MD5 = %x\n", md5sum)
 
+	paths := corefile.Paths(instance.Caddyfile())
+	if len(paths) > 1 {
+		log.Infof("Configuration read from %s", strings.Join(paths, ", "))
+	}
+	changed, stop := watchCorefile(paths)
+
 	go func() {
 		tick := time.NewTicker(r.interval())
+		defer tick.Stop()
+		defer stop()
 
 		for {
 			select {
 			case <-tick.C:
-				corefile, err := caddy.LoadCaddyfile(instance.Caddyfile().ServerType())
-				if err != nil {
-					continue
+			case <-changed:
+			case <-r.quit:
+				return
+			}
+
+			corefile, err := caddy.LoadCaddyfile(instance.Caddyfile().ServerType())
+			if err != nil {
+				continue
+			}
+			parsedCorefile, err := parse(corefile)
+			if err != nil {
+				log.Warningf("Corefile parse failed: %s", err)
+				continue
+			}
+			s := md5.Sum(parsedCorefile)
+			// A server block that failed to set up is retried by reloading the unchanged Corefile.
+			retry := s == md5sum && dnsserver.Failed(instance)
+			if s != md5sum || retry {
+				r.restart.Lock()
+				if !running(instance) {
+					// The instance was stopped while we were reading the Corefile, don't start a new one.
+					r.restart.Unlock()
+					return
 				}
-				parsedCorefile, err := parse(corefile)
-				if err != nil {
-					log.Warningf("Corefile parse failed: %s", err)
-					continue
+				// Let not try to restart with the same file, even though it is wrong.
+				md5sum = s
+				// now lets consider that plugin will not be reload, unless appear in next config file
+				// change status iof usage will be reset in setup if the plugin appears in config file
+				r.setUsage(maybeUsed)
+				if retry {
+					log.Infof("Corefile unchanged, retrying after a failed start of server blocks")
 				}
-				s := md5.Sum(parsedCorefile)
-				if s != md5sum {
-					// Let not try to restart with the same file, even though it is wrong.
-					md5sum = s
-					// now lets consider that plugin will not be reload, unless appear in next config file
-					// change status iof usage will be reset in setup if the plugin appears in config file
-					r.setUsage(maybeUsed)
-					_, err := instance.Restart(corefile)
-					if err != nil {
+				_, err := instance.Restart(corefile)
+				r.restart.Unlock()
+				if err != nil {
+					if retry {
+						log.Errorf("Retry after a failed start of server blocks failed: %s", err)
+					} else {
 						log.Errorf("Corefile changed but reload failed: %s", err)
-						FailedCount.Add(1)
-						continue
-					}
-					// we are done, if the plugin was not set used, then it is not.
-					if r.usage() == maybeUsed {
-						r.setUsage(unused)
 					}
-					return
+					FailedCount.Add(1)
+					continue
+				}
+				// we are done, if the plugin was not set used, then it is not.
+				if r.usage() == maybeUsed {
+					r.setUsage(unused)
 				}
-			case <-r.quit:
 				return
 			}
 		}
@@ -121,3 +157,59 @@ func hook(event caddy.EventName, info interface{}) error {
 
 	return nil
 }
+
+// running returns true if instance i has not been stopped.
+func running(i *caddy.Instance) bool {
+	for _, other := range caddy.Instances() {
+		if other == i {
+			return true
+		}
+	}
+	return false
+}
+
+// watchCorefile watches the files and directories in paths the Corefile was read from for changes,
+// this makes a reload happen right away instead of at the next tick. Paths that don't exist, like the
+// Corefile of an instance that was not read from disk, are only checked at the tick.
+func watchCorefile(paths []string) (<-chan struct{}, func()) {
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+
+	var stops []func()
+	for _, path := range paths {
+		if path == "" || path == "stdin" {
+			continue
+		}
+
+		var (
+			stop func()
+			err  error
+		)
+		fi, e := os.Stat(path)
+		switch {
+		case e != nil:
+			continue
+		case fi.IsDir():
+			stop, err = watch.Dir(path, notify)
+		case fi.Mode().IsRegular():
+			stop, err = watch.File(path, notify)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Warningf("Failed to watch %q, checking every %s: %s", path, r.interval(), err)
+			continue
+		}
+		stops = append(stops, stop)
+	}
+	return changed, func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+}
@@ -80,6 +80,9 @@ func setup(c *caddy.Controller) error {
 	// re-register on finalShutDown as the instance most-likely will be changed
 	shutOnce.Do(func() {
 		c.OnFinalShutdown(func() error {
+			// Wait for a reload in progress, it must not start new servers while we shut down.
+			r.restart.Lock()
+			r.restart.Unlock()
 			r.quit <- true
 			return nil
 		})
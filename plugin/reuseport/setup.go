@@ -0,0 +1,55 @@
+// Package reuseport implements a plugin that makes the server open more sockets for its listeners.
+package reuseport
+
+import (
+	"strconv"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("reuseport", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	n, err := parse(c)
+	if err != nil {
+		return plugin.Error("reuseport", err)
+	}
+	dnsserver.GetConfig(c).ReusePort = n
+	return nil
+}
+
+func parse(c *caddy.Controller) (int, error) {
+	n := 0
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return 0, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return 0, c.ArgErr()
+		}
+		var err error
+		n, err = strconv.Atoi(args[0])
+		if err != nil {
+			return 0, err
+		}
+		if n < 1 || n > maxSockets {
+			return 0, c.Errf("number of sockets must be between 1 and %d: %d", maxSockets, n)
+		}
+	}
+	return n, nil
+}
+
+// maxSockets is the largest number of sockets of a listener.
+const maxSockets = 256
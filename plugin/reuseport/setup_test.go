@@ -0,0 +1,44 @@
+package reuseport
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  int
+	}{
+		{`reuseport 4`, false, 4},
+		{`reuseport 1`, false, 1},
+		{`reuseport`, true, 0},
+		{`reuseport 0`, true, 0},
+		{`reuseport 257`, true, 0},
+		{`reuseport four`, true, 0},
+		{`reuseport 4 8`, true, 0},
+		{"reuseport 4\nreuseport 8", true, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if n := dnsserver.GetConfig(c).ReusePort; n != tc.expected {
+			t.Errorf("Test %d: expected %d, got %d", i, tc.expected, n)
+		}
+	}
+}
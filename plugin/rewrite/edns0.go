@@ -48,8 +48,60 @@ func setupEdns0Opt(r *dns.Msg) *dns.OPT {
 	return o
 }
 
+// unsetEdns0Option removes all options with code from the OPT record of r. It returns true if an
+// option was removed.
+func unsetEdns0Option(r *dns.Msg, code uint16) bool {
+	o := r.IsEdns0()
+	if o == nil {
+		return false
+	}
+	opts := o.Option[:0]
+	for _, s := range o.Option {
+		if s.Option() != code {
+			opts = append(opts, s)
+		}
+	}
+	removed := len(opts) != len(o.Option)
+	o.Option = opts
+	return removed
+}
+
+// setEdns0Option sets the option with code to data, following action. Options of any type are
+// matched on their code, a matching option that is not an EDNS0_LOCAL is replaced by one. It returns
+// true if o was changed.
+func setEdns0Option(o *dns.OPT, action string, code uint16, data []byte) bool {
+	for i, s := range o.Option {
+		if s.Option() != code {
+			continue
+		}
+		if action == Replace || action == Set {
+			if e, ok := s.(*dns.EDNS0_LOCAL); ok {
+				e.Data = data
+			} else {
+				o.Option[i] = &dns.EDNS0_LOCAL{Code: code, Data: data}
+			}
+			return true
+		}
+		return false
+	}
+
+	// add option if not found
+	if action == Append || action == Set {
+		o.Option = append(o.Option, &dns.EDNS0_LOCAL{Code: code, Data: data})
+		return true
+	}
+	return false
+}
+
 // Rewrite will alter the request EDNS0 NSID option
 func (rule *edns0NsidRule) Rewrite(ctx context.Context, state request.Request) Result {
+	if rule.action == Unset {
+		if unsetEdns0Option(state.Req, dns.EDNS0NSID) {
+			return RewriteDone
+		}
+		return RewriteIgnored
+	}
+
 	o := setupEdns0Opt(state.Req)
 
 	for _, s := range o.Option {
@@ -78,25 +130,17 @@ func (rule *edns0NsidRule) GetResponseRule() ResponseRule { return ResponseRule{
 
 // Rewrite will alter the request EDNS0 local options.
 func (rule *edns0LocalRule) Rewrite(ctx context.Context, state request.Request) Result {
-	o := setupEdns0Opt(state.Req)
-
-	for _, s := range o.Option {
-		if e, ok := s.(*dns.EDNS0_LOCAL); ok {
-			if rule.code == e.Code {
-				if rule.action == Replace || rule.action == Set {
-					e.Data = rule.data
-					return RewriteDone
-				}
-			}
+	if rule.action == Unset {
+		if unsetEdns0Option(state.Req, rule.code) {
+			return RewriteDone
 		}
+		return RewriteIgnored
 	}
 
-	// add option if not found
-	if rule.action == Append || rule.action == Set {
-		o.Option = append(o.Option, &dns.EDNS0_LOCAL{Code: rule.code, Data: rule.data})
+	o := setupEdns0Opt(state.Req)
+	if setEdns0Option(o, rule.action, rule.code, rule.data) {
 		return RewriteDone
 	}
-
 	return RewriteIgnored
 }
 
@@ -112,18 +156,29 @@ func newEdns0Rule(mode string, args ...string) (Rule, error) {
 		return nil, fmt.Errorf("too few arguments for an EDNS0 rule")
 	}
 
+	if strings.ToLower(args[0]) == "response" {
+		return newEdns0ResponseRule(mode, args[1:]...)
+	}
+
 	ruleType := strings.ToLower(args[0])
 	action := strings.ToLower(args[1])
 	switch action {
 	case Append:
 	case Replace:
 	case Set:
+	case Unset:
 	default:
 		return nil, fmt.Errorf("invalid action: %q", action)
 	}
 
 	switch ruleType {
 	case "local":
+		if action == Unset {
+			if len(args) != 3 {
+				return nil, fmt.Errorf("EDNS0 local unset rules require exactly one arg")
+			}
+			return newEdns0LocalRule(mode, action, args[2], "")
+		}
 		if len(args) != 4 {
 			return nil, fmt.Errorf("EDNS0 local rules require exactly three args")
 		}
@@ -138,6 +193,12 @@ func newEdns0Rule(mode string, args ...string) (Rule, error) {
 		}
 		return &edns0NsidRule{mode: mode, action: action}, nil
 	case "subnet":
+		if action == Unset {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("EDNS0 subnet unset rules do not accept args")
+			}
+			return &edns0SubnetRule{mode: mode, action: action}, nil
+		}
 		if len(args) != 4 {
 			return nil, fmt.Errorf("EDNS0 subnet rules require exactly three args")
 		}
@@ -161,8 +222,10 @@ func newEdns0LocalRule(mode, action, code, data string) (*edns0LocalRule, error)
 		}
 	}
 
-	// Add this code to the ones the server supports.
-	edns.SetSupportedOption(uint16(c))
+	// Add this code to the ones the server supports, unless we remove it.
+	if action != Unset {
+		edns.SetSupportedOption(uint16(c))
+	}
 
 	return &edns0LocalRule{mode: mode, action: action, code: uint16(c), data: decoded}, nil
 }
@@ -334,6 +397,13 @@ func (rule *edns0SubnetRule) fillEcsData(state request.Request, ecs *dns.EDNS0_S
 
 // Rewrite will alter the request EDNS0 subnet option.
 func (rule *edns0SubnetRule) Rewrite(ctx context.Context, state request.Request) Result {
+	if rule.action == Unset {
+		if unsetEdns0Option(state.Req, dns.EDNS0SUBNET) {
+			return RewriteDone
+		}
+		return RewriteIgnored
+	}
+
 	o := setupEdns0Opt(state.Req)
 
 	for _, s := range o.Option {
@@ -365,11 +435,73 @@ func (rule *edns0SubnetRule) Mode() string { return rule.mode }
 // GetResponseRule return a rule to rewrite the response with. Currently not implemented.
 func (rule *edns0SubnetRule) GetResponseRule() ResponseRule { return ResponseRule{} }
 
+// edns0ResponseRule is a rewrite rule for the EDNS0 options in the response.
+type edns0ResponseRule struct {
+	mode   string
+	action string
+	code   uint16
+	data   []byte
+}
+
+// newEdns0ResponseRule creates a rule that alters the option with the given code in the response.
+func newEdns0ResponseRule(mode string, args ...string) (*edns0ResponseRule, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("too few arguments for an EDNS0 response rule")
+	}
+	if strings.ToLower(args[0]) != "local" {
+		return nil, fmt.Errorf("EDNS0 response rules support only local options, received: %s", args[0])
+	}
+	action := strings.ToLower(args[1])
+	data := ""
+	switch action {
+	case Append, Replace, Set:
+		if len(args) != 4 {
+			return nil, fmt.Errorf("EDNS0 local rules require exactly three args")
+		}
+		data = args[3]
+	case Unset:
+		if len(args) != 3 {
+			return nil, fmt.Errorf("EDNS0 local unset rules require exactly one arg")
+		}
+	default:
+		return nil, fmt.Errorf("invalid action: %q", action)
+	}
+	if strings.HasPrefix(data, "{") && strings.HasSuffix(data, "}") {
+		return nil, fmt.Errorf("EDNS0 response rules do not support variables")
+	}
+
+	local, err := newEdns0LocalRule(mode, action, args[2], data)
+	if err != nil {
+		return nil, err
+	}
+	return &edns0ResponseRule{mode: mode, action: action, code: local.code, data: local.data}, nil
+}
+
+// Rewrite leaves the request as is, the rule is applied to the response.
+func (rule *edns0ResponseRule) Rewrite(ctx context.Context, state request.Request) Result {
+	return RewriteDone
+}
+
+// Mode returns the processing mode.
+func (rule *edns0ResponseRule) Mode() string { return rule.mode }
+
+// GetResponseRule returns the rule to rewrite the response with.
+func (rule *edns0ResponseRule) GetResponseRule() ResponseRule {
+	return ResponseRule{
+		Active: true,
+		Type:   "edns0",
+		Action: rule.action,
+		Code:   rule.code,
+		Data:   rule.data,
+	}
+}
+
 // These are all defined actions.
 const (
 	Replace = "replace"
 	Set     = "set"
 	Append  = "append"
+	Unset   = "unset"
 )
 
 // Supported local EDNS0 variables
@@ -37,10 +37,10 @@ type substringNameRule struct {
 }
 
 type regexNameRule struct {
-	NextAction  string
-	Pattern     *regexp.Regexp
-	Replacement string
-	ResponseRule
+	NextAction    string
+	Pattern       *regexp.Regexp
+	Replacement   string
+	ResponseRules []ResponseRule
 }
 
 const (
@@ -97,17 +97,10 @@ func (rule *substringNameRule) Rewrite(ctx context.Context, state request.Reques
 // Rewrite rewrites the current request when the name in the question
 // section of the request matches a regular expression.
 func (rule *regexNameRule) Rewrite(ctx context.Context, state request.Request) Result {
-	regexGroups := rule.Pattern.FindStringSubmatch(state.Name())
-	if len(regexGroups) == 0 {
+	s, ok := replaceGroups(rule.Pattern, rule.Replacement, state.Name())
+	if !ok {
 		return RewriteIgnored
 	}
-	s := rule.Replacement
-	for groupIndex, groupValue := range regexGroups {
-		groupIndexStr := "{" + strconv.Itoa(groupIndex) + "}"
-		if strings.Contains(s, groupIndexStr) {
-			s = strings.Replace(s, groupIndexStr, groupValue, -1)
-		}
-	}
 	state.Req.Question[0].Name = s
 	return RewriteDone
 }
@@ -142,11 +135,11 @@ func newNameRule(nextAction string, args ...string) (Rule, error) {
 		}
 	}
 
-	if len(args) > 3 && len(args) != 7 {
-		return nil, fmt.Errorf("response rewrites must consist only of a name rule with 3 arguments and an answer rule with 3 arguments")
+	if len(args) > 3 && (len(args)-3)%4 != 0 {
+		return nil, fmt.Errorf("response rewrites must consist only of a name rule with 3 arguments and answer rules with 3 arguments each")
 	}
 
-	if len(args) < 7 {
+	if len(args) <= 3 {
 		switch matchType {
 		case ExactMatch:
 			rewriteAnswerFromPattern, err := isValidRegexPattern(rewriteQuestionTo, rewriteQuestionFrom)
@@ -192,52 +185,52 @@ func newNameRule(nextAction string, args ...string) (Rule, error) {
 				nextAction,
 				rewriteQuestionFromPattern,
 				rewriteQuestionTo,
-				ResponseRule{
-					Type: "name",
-				},
+				nil,
 			}, nil
 		default:
 			return nil, fmt.Errorf("A name rule supports only exact, prefix, suffix, substring, and regex name matching, received: %s", matchType)
 		}
 	}
-	if len(args) == 7 {
-		if matchType == RegexMatch {
-			if args[3] != "answer" {
-				return nil, fmt.Errorf("exceeded the number of arguments for a regex name rule")
-			}
-			rewriteQuestionFromPattern, err := isValidRegexPattern(rewriteQuestionFrom, rewriteQuestionTo)
-			if err != nil {
-				return nil, err
-			}
-			rewriteAnswerField = strings.ToLower(args[4])
-			switch rewriteAnswerField {
-			case "name":
-			default:
-				return nil, fmt.Errorf("exceeded the number of arguments for a regex name rule")
-			}
-			rewriteAnswerFrom = args[5]
-			rewriteAnswerTo = args[6]
-			rewriteAnswerFromPattern, err := isValidRegexPattern(rewriteAnswerFrom, rewriteAnswerTo)
-			if err != nil {
-				return nil, err
-			}
-			rewriteQuestionTo = plugin.Name(args[2]).Normalize()
-			rewriteAnswerTo = plugin.Name(args[6]).Normalize()
-			return &regexNameRule{
-				nextAction,
-				rewriteQuestionFromPattern,
-				rewriteQuestionTo,
-				ResponseRule{
-					Active:      true,
-					Type:        "name",
-					Pattern:     rewriteAnswerFromPattern,
-					Replacement: rewriteAnswerTo,
-				},
-			}, nil
-		}
+	if matchType != RegexMatch {
 		return nil, fmt.Errorf("the rewrite of response is supported only for name regex rule")
 	}
-	return nil, fmt.Errorf("the rewrite rule is invalid: %s", args)
+	rewriteQuestionFromPattern, err := isValidRegexPattern(rewriteQuestionFrom, rewriteQuestionTo)
+	if err != nil {
+		return nil, err
+	}
+	var responseRules []ResponseRule
+	for i := 3; i < len(args); i += 4 {
+		if args[i] != "answer" {
+			return nil, fmt.Errorf("exceeded the number of arguments for a regex name rule")
+		}
+		rewriteAnswerField = strings.ToLower(args[i+1])
+		switch rewriteAnswerField {
+		case "name", "value":
+		default:
+			return nil, fmt.Errorf("an answer rule supports only name and value rewrites, received: %s", args[i+1])
+		}
+		rewriteAnswerFrom = args[i+2]
+		rewriteAnswerTo = args[i+3]
+		rewriteAnswerFromPattern, err := isValidRegexPattern(rewriteAnswerFrom, rewriteAnswerTo)
+		if err != nil {
+			return nil, err
+		}
+		if rewriteAnswerField == "name" {
+			rewriteAnswerTo = plugin.Name(rewriteAnswerTo).Normalize()
+		}
+		responseRules = append(responseRules, ResponseRule{
+			Active:      true,
+			Type:        rewriteAnswerField,
+			Pattern:     rewriteAnswerFromPattern,
+			Replacement: rewriteAnswerTo,
+		})
+	}
+	return &regexNameRule{
+		nextAction,
+		rewriteQuestionFromPattern,
+		plugin.Name(args[2]).Normalize(),
+		responseRules,
+	}, nil
 }
 
 // Mode returns the processing nextAction
@@ -259,8 +252,17 @@ func (rule *suffixNameRule) GetResponseRule() ResponseRule { return ResponseRule
 // GetResponseRule return a rule to rewrite the response with. Currently not implemented.
 func (rule *substringNameRule) GetResponseRule() ResponseRule { return ResponseRule{} }
 
-// GetResponseRule return a rule to rewrite the response with.
-func (rule *regexNameRule) GetResponseRule() ResponseRule { return rule.ResponseRule }
+// GetResponseRule return a rule to rewrite the response with. It is the first of its rules, see
+// GetResponseRules for all of them.
+func (rule *regexNameRule) GetResponseRule() ResponseRule {
+	if len(rule.ResponseRules) == 0 {
+		return ResponseRule{}
+	}
+	return rule.ResponseRules[0]
+}
+
+// GetResponseRules returns the rules to rewrite the response with.
+func (rule *regexNameRule) GetResponseRules() []ResponseRule { return rule.ResponseRules }
 
 // hasClosingDot return true if s has a closing dot at the end.
 func hasClosingDot(s string) bool {
@@ -292,3 +294,19 @@ func isValidRegexPattern(rewriteFrom, rewriteTo string) (*regexp.Regexp, error)
 	}
 	return rewriteFromPattern, nil
 }
+
+// replaceGroups matches s against pattern and returns replacement with each {N} replaced by the
+// Nth group of the match. It returns false if s does not match.
+func replaceGroups(pattern *regexp.Regexp, replacement, s string) (string, bool) {
+	regexGroups := pattern.FindStringSubmatch(s)
+	if len(regexGroups) == 0 {
+		return s, false
+	}
+	for groupIndex, groupValue := range regexGroups {
+		groupIndexStr := "{" + strconv.Itoa(groupIndex) + "}"
+		if strings.Contains(replacement, groupIndexStr) {
+			replacement = strings.Replace(replacement, groupIndexStr, groupValue, -1)
+		}
+	}
+	return replacement, true
+}
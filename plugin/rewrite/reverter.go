@@ -2,8 +2,6 @@ package rewrite
 
 import (
 	"regexp"
-	"strconv"
-	"strings"
 
 	"github.com/miekg/dns"
 )
@@ -15,6 +13,10 @@ type ResponseRule struct {
 	Pattern     *regexp.Regexp
 	Replacement string
 	TTL         uint32
+	// Action, Code and Data are used by the rules of type edns0.
+	Action string
+	Code   uint16
+	Data   []byte
 }
 
 // ResponseReverter reverses the operations done on the question section of a packet.
@@ -37,48 +39,112 @@ func NewResponseReverter(w dns.ResponseWriter, r *dns.Msg) *ResponseReverter {
 
 // WriteMsg records the status code and calls the underlying ResponseWriter's WriteMsg method.
 func (r *ResponseReverter) WriteMsg(res *dns.Msg) error {
+	if r.ResponseRewrite {
+		// The records may be kept by the plugin that wrote them, i.e. cache or file, and served again:
+		// rewrite a copy.
+		res = res.Copy()
+	}
 	res.Question[0] = r.originalQuestion
 	if r.ResponseRewrite {
+		for _, rule := range r.ResponseRules {
+			if rule.Type == "edns0" {
+				rewriteEdns0(res, rule)
+			}
+		}
 		for _, rr := range res.Answer {
-			var (
-				isNameRewritten bool
-				isTTLRewritten  bool
-				name            = rr.Header().Name
-				ttl             = rr.Header().Ttl
-			)
-			for _, rule := range r.ResponseRules {
-				if rule.Type == "" {
-					rule.Type = "name"
-				}
-				switch rule.Type {
-				case "name":
-					regexGroups := rule.Pattern.FindStringSubmatch(name)
-					if len(regexGroups) == 0 {
-						continue
-					}
-					s := rule.Replacement
-					for groupIndex, groupValue := range regexGroups {
-						groupIndexStr := "{" + strconv.Itoa(groupIndex) + "}"
-						if strings.Contains(s, groupIndexStr) {
-							s = strings.Replace(s, groupIndexStr, groupValue, -1)
-						}
-					}
-					name = s
-					isNameRewritten = true
-				case "ttl":
-					ttl = rule.TTL
-					isTTLRewritten = true
-				}
+			r.rewriteResourceRecord(rr, true)
+		}
+		for _, rr := range res.Ns {
+			r.rewriteResourceRecord(rr, false)
+		}
+		for _, rr := range res.Extra {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
 			}
-			if isNameRewritten {
-				rr.Header().Name = name
+			r.rewriteResourceRecord(rr, false)
+		}
+	}
+	return r.ResponseWriter.WriteMsg(res)
+}
+
+// rewriteResourceRecord applies the response rules to rr. TTL rules are only applied to the records
+// in the answer section.
+func (r *ResponseReverter) rewriteResourceRecord(rr dns.RR, answer bool) {
+	var (
+		isNameRewritten bool
+		isTTLRewritten  bool
+		name            = rr.Header().Name
+		ttl             = rr.Header().Ttl
+	)
+	for _, rule := range r.ResponseRules {
+		if rule.Type == "" {
+			rule.Type = "name"
+		}
+		switch rule.Type {
+		case "name":
+			s, ok := replaceGroups(rule.Pattern, rule.Replacement, name)
+			if !ok {
+				continue
 			}
-			if isTTLRewritten {
-				rr.Header().Ttl = ttl
+			name = s
+			isNameRewritten = true
+		case "value":
+			rewriteValue(rr, rule)
+		case "ttl":
+			if !answer {
+				continue
+			}
+			ttl = rule.TTL
+			isTTLRewritten = true
+		}
+	}
+	if isNameRewritten {
+		rr.Header().Name = name
+	}
+	if isTTLRewritten {
+		rr.Header().Ttl = ttl
+	}
+}
+
+// rewriteEdns0 applies rule to the OPT record of res. No OPT record is added when res has none.
+func rewriteEdns0(res *dns.Msg, rule ResponseRule) {
+	if rule.Action == Unset {
+		unsetEdns0Option(res, rule.Code)
+		return
+	}
+	if o := res.IsEdns0(); o != nil {
+		setEdns0Option(o, rule.Action, rule.Code, rule.Data)
+	}
+}
+
+// rewriteValue applies rule to the rdata of rr, if rr is of a type that holds a domain name or text.
+func rewriteValue(rr dns.RR, rule ResponseRule) {
+	name := func(s string) string {
+		if v, ok := replaceGroups(rule.Pattern, rule.Replacement, s); ok {
+			return dns.Fqdn(v)
+		}
+		return s
+	}
+	switch x := rr.(type) {
+	case *dns.CNAME:
+		x.Target = name(x.Target)
+	case *dns.DNAME:
+		x.Target = name(x.Target)
+	case *dns.NS:
+		x.Ns = name(x.Ns)
+	case *dns.PTR:
+		x.Ptr = name(x.Ptr)
+	case *dns.MX:
+		x.Mx = name(x.Mx)
+	case *dns.SRV:
+		x.Target = name(x.Target)
+	case *dns.TXT:
+		for i, s := range x.Txt {
+			if v, ok := replaceGroups(rule.Pattern, rule.Replacement, s); ok {
+				x.Txt[i] = v
 			}
 		}
 	}
-	return r.ResponseWriter.WriteMsg(res)
 }
 
 // Write is a wrapper that records the size of the message that gets written.
@@ -68,3 +68,94 @@ func doReverterTests(rules []Rule, t *testing.T) {
 		}
 	}
 }
+
+func TestResponseReverterSections(t *testing.T) {
+	r, err := newNameRule("stop", "regex", `(.*)\.example\.org`, "{1}.internal.example.org",
+		"answer", "name", `(.*)\.internal\.example\.org`, "{1}.example.org",
+		"answer", "value", `(.*)\.internal\.example\.org`, "{1}.example.org",
+		"answer", "value", `^internal:(.*)$`, "public:{1}")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	rw := Rewrite{
+		Next: plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Answer = []dns.RR{
+				test.CNAME("www.internal.example.org. 5 IN CNAME web.internal.example.org."),
+				test.A("web.internal.example.org. 5 IN A 10.0.0.1"),
+			}
+			m.Ns = []dns.RR{test.NS("internal.example.org. 5 IN NS ns1.internal.example.org.")}
+			m.Extra = []dns.RR{test.TXT(`www.internal.example.org. 5 IN TXT "internal:yes" "other"`)}
+			m.SetEdns0(4096, false)
+			w.WriteMsg(m)
+			return 0, nil
+		}),
+		Rules: []Rule{r},
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rw.ServeDNS(context.TODO(), rec, m)
+	resp := rec.Msg
+
+	if resp.Question[0].Name != "www.example.org." {
+		t.Errorf("Expected question www.example.org., got %s", resp.Question[0].Name)
+	}
+	cname := resp.Answer[0].(*dns.CNAME)
+	if cname.Hdr.Name != "www.example.org." || cname.Target != "web.example.org." {
+		t.Errorf("Expected CNAME www.example.org. -> web.example.org., got %s", cname)
+	}
+	if x := resp.Answer[1].Header().Name; x != "web.example.org." {
+		t.Errorf("Expected A record for web.example.org., got %s", x)
+	}
+	// The owner doesn't match, the target does.
+	ns := resp.Ns[0].(*dns.NS)
+	if ns.Hdr.Name != "internal.example.org." || ns.Ns != "ns1.example.org." {
+		t.Errorf("Expected NS internal.example.org. -> ns1.example.org., got %s", ns)
+	}
+	txt := resp.Extra[0].(*dns.TXT)
+	if txt.Hdr.Name != "www.example.org." || txt.Txt[0] != "public:yes" || txt.Txt[1] != "other" {
+		t.Errorf("Expected rewritten TXT record, got %s", txt)
+	}
+	if resp.IsEdns0() == nil {
+		t.Errorf("Expected OPT record to be kept")
+	}
+}
+
+func TestResponseReverterStoredRecords(t *testing.T) {
+	r, err := newNameRule("stop", "regex", `(.*)\.example\.org`, "{1}.internal.example.org",
+		"answer", "name", `(.*)\.internal\.example\.org`, "{1}.example.org",
+		"answer", "value", `^(.*)$`, "public:{1}")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	// The records are kept by the next plugin, like the cache and file plugins do, and served again.
+	stored := []dns.RR{test.TXT(`www.internal.example.org. 5 IN TXT "yes"`)}
+	rw := Rewrite{
+		Next: plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Answer = stored
+			w.WriteMsg(m)
+			return 0, nil
+		}),
+		Rules: []Rule{r},
+	}
+
+	for i := 0; i < 2; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("www.example.org.", dns.TypeTXT)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rw.ServeDNS(context.TODO(), rec, m)
+
+		txt := rec.Msg.Answer[0].(*dns.TXT)
+		if txt.Hdr.Name != "www.example.org." || txt.Txt[0] != "public:yes" {
+			t.Errorf("Test %d: expected rewritten TXT record, got %s", i, txt)
+		}
+	}
+	if x := stored[0].String(); x != test.TXT(`www.internal.example.org. 5 IN TXT "yes"`).String() {
+		t.Errorf("Expected the stored record to be unchanged, got %s", x)
+	}
+}
@@ -49,10 +49,15 @@ func (rw Rewrite) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 				state.Req.Question[0] = wr.originalQuestion
 				return dns.RcodeServerFailure, err
 			}
-			respRule := rule.GetResponseRule()
-			if respRule.Active {
-				wr.ResponseRewrite = true
-				wr.ResponseRules = append(wr.ResponseRules, respRule)
+			respRules := []ResponseRule{rule.GetResponseRule()}
+			if rr, ok := rule.(ResponseRules); ok {
+				respRules = rr.GetResponseRules()
+			}
+			for _, respRule := range respRules {
+				if respRule.Active {
+					wr.ResponseRewrite = true
+					wr.ResponseRules = append(wr.ResponseRules, respRule)
+				}
 			}
 			if rule.Mode() == Stop {
 				if rw.noRevert {
@@ -83,6 +88,13 @@ type Rule interface {
 	GetResponseRule() ResponseRule
 }
 
+// ResponseRules is implemented by the rules that rewrite the response with more than one rule. Their
+// GetResponseRules is used instead of GetResponseRule.
+type ResponseRules interface {
+	// GetResponseRules returns the rules to rewrite the response with, if any.
+	GetResponseRules() []ResponseRule
+}
+
 func newRule(args ...string) (Rule, error) {
 	if len(args) == 0 {
 		return nil, fmt.Errorf("no rule type specified for rewrite")
@@ -3,6 +3,7 @@ package rewrite
 import (
 	"bytes"
 	"context"
+	"net"
 	"reflect"
 	"testing"
 
@@ -44,6 +45,8 @@ func TestNewRule(t *testing.T) {
 		{[]string{"name", "regex", "(cdns)\\.(core)\\.(rocks)", "{2}.{1}.{3}", "answer", "ttl", "(core)\\.(cdns)\\.(rocks)", "{2}.{1}.{3}"}, true, nil},
 		{[]string{"name", "regex", "(ddns)\\.(core)\\.(rocks)", "{2}.{1}.{3}", "answer", "name", "\xecore\\.(ddns)\\.(rocks)", "{2}.{1}.{3}"}, true, nil},
 		{[]string{"name", "regex", "\xedns\\.(core)\\.(rocks)", "{2}.{1}.{3}", "answer", "name", "(core)\\.(edns)\\.(rocks)", "{2}.{1}.{3}"}, true, nil},
+		{[]string{"name", "regex", "(gdns)\\.(core)\\.(rocks)", "{2}.{1}.{3}", "answer", "name", "(core)\\.(gdns)\\.(rocks)", "{2}.{1}.{3}", "answer", "value", "(core)\\.(gdns)\\.(rocks)", "{2}.{1}.{3}"}, false, reflect.TypeOf(&regexNameRule{})},
+		{[]string{"name", "regex", "(hdns)\\.(core)\\.(rocks)", "{2}.{1}.{3}", "answer", "value", "(core)\\.(hdns)\\.(rocks)", "{2}.{1}.{3}", "answer", "name"}, true, nil},
 		{[]string{"name", "substring", "fcore.dns.rocks", "dns.fcore.rocks", "answer", "name", "(fcore)\\.(dns)\\.(rocks)", "{2}.{1}.{3}"}, true, nil},
 		{[]string{"name", "substring", "a.com", "b.com", "c.com"}, true, nil},
 		{[]string{"type"}, true, nil},
@@ -104,6 +107,17 @@ func TestNewRule(t *testing.T) {
 		{[]string{"edns0", "subnet", "set", "24", "56"}, false, reflect.TypeOf(&edns0SubnetRule{})},
 		{[]string{"edns0", "subnet", "append", "24", "56"}, false, reflect.TypeOf(&edns0SubnetRule{})},
 		{[]string{"edns0", "subnet", "replace", "24", "56"}, false, reflect.TypeOf(&edns0SubnetRule{})},
+		{[]string{"edns0", "subnet", "unset"}, false, reflect.TypeOf(&edns0SubnetRule{})},
+		{[]string{"edns0", "subnet", "unset", "24", "56"}, true, nil},
+		{[]string{"edns0", "nsid", "unset"}, false, reflect.TypeOf(&edns0NsidRule{})},
+		{[]string{"edns0", "local", "unset", "10"}, false, reflect.TypeOf(&edns0LocalRule{})},
+		{[]string{"edns0", "local", "unset", "10", "abcdef"}, true, nil},
+		{[]string{"edns0", "response", "local", "set", "0xffee", "abcdef"}, false, reflect.TypeOf(&edns0ResponseRule{})},
+		{[]string{"edns0", "response", "local", "unset", "0xffee"}, false, reflect.TypeOf(&edns0ResponseRule{})},
+		{[]string{"edns0", "response", "local", "set", "0xffee", "{qname}"}, true, nil},
+		{[]string{"edns0", "response", "local", "set", "0xffee"}, true, nil},
+		{[]string{"edns0", "response", "nsid", "unset"}, true, nil},
+		{[]string{"edns0", "response", "local", "foo", "0xffee", "abcdef"}, true, nil},
 		{[]string{"unknown-action", "name", "a.com", "b.com"}, true, nil},
 		{[]string{"stop", "name", "a.com", "b.com"}, false, reflect.TypeOf(&exactNameRule{})},
 		{[]string{"continue", "name", "a.com", "b.com"}, false, reflect.TypeOf(&exactNameRule{})},
@@ -686,3 +700,167 @@ func TestRewriteEDNS0Subnet(t *testing.T) {
 		}
 	}
 }
+
+func TestRewriteEDNS0Unset(t *testing.T) {
+	rw := Rewrite{
+		Next:     plugin.HandlerFunc(msgPrinter),
+		noRevert: true,
+	}
+
+	tests := []struct {
+		fromOpts []dns.EDNS0
+		args     []string
+		toOpts   []dns.EDNS0
+	}{
+		{
+			[]dns.EDNS0{&dns.EDNS0_LOCAL{Code: 0xffee, Data: []byte("abc")}, &dns.EDNS0_NSID{Code: dns.EDNS0NSID}},
+			[]string{"local", "unset", "0xffee"},
+			[]dns.EDNS0{&dns.EDNS0_NSID{Code: dns.EDNS0NSID}},
+		},
+		{
+			[]dns.EDNS0{&dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0011223344556677"}, &dns.EDNS0_NSID{Code: dns.EDNS0NSID}},
+			[]string{"local", "unset", "10"},
+			[]dns.EDNS0{&dns.EDNS0_NSID{Code: dns.EDNS0NSID}},
+		},
+		{
+			[]dns.EDNS0{&dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0011223344556677"}},
+			[]string{"local", "replace", "10", "0xaabb"},
+			[]dns.EDNS0{&dns.EDNS0_LOCAL{Code: dns.EDNS0COOKIE, Data: []byte{0xaa, 0xbb}}},
+		},
+		{
+			[]dns.EDNS0{&dns.EDNS0_NSID{Code: dns.EDNS0NSID}},
+			[]string{"nsid", "unset"},
+			[]dns.EDNS0{},
+		},
+		{
+			[]dns.EDNS0{&dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("10.0.0.0").To4()}},
+			[]string{"subnet", "unset"},
+			[]dns.EDNS0{},
+		},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		m.SetEdns0(4096, false)
+		o := m.IsEdns0()
+		o.Option = tc.fromOpts
+
+		r, err := newEdns0Rule("stop", tc.args...)
+		if err != nil {
+			t.Errorf("Test %d: Error creating test rule: %s", i, err)
+			continue
+		}
+		rw.Rules = []Rule{r}
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rw.ServeDNS(ctx, rec, m)
+
+		o = rec.Msg.IsEdns0()
+		if o == nil {
+			t.Errorf("Test %d: EDNS0 options not set", i)
+			continue
+		}
+		if !optsEqual(o.Option, tc.toOpts) {
+			t.Errorf("Test %d: Expected %v but got %v", i, tc.toOpts, o)
+		}
+	}
+
+	// Unset must not add an OPT record.
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	r, _ := newEdns0Rule("stop", "nsid", "unset")
+	rw.Rules = []Rule{r}
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rw.ServeDNS(ctx, rec, m)
+	if rec.Msg.IsEdns0() != nil {
+		t.Errorf("Expected no OPT record, got %v", rec.Msg.IsEdns0())
+	}
+}
+
+func TestRewriteEDNS0Response(t *testing.T) {
+	tests := []struct {
+		args   []string
+		edns0  bool
+		toOpts []dns.EDNS0
+	}{
+		{[]string{"response", "local", "set", "0xffee", "abc"}, true, []dns.EDNS0{&dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: "6e73"}, &dns.EDNS0_LOCAL{Code: 0xffee, Data: []byte("abc")}}},
+		{[]string{"response", "local", "unset", "3"}, true, []dns.EDNS0{}},
+		{[]string{"response", "local", "set", "0xffee", "abc"}, false, nil},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		r, err := newEdns0Rule("stop", tc.args...)
+		if err != nil {
+			t.Errorf("Test %d: Error creating test rule: %s", i, err)
+			continue
+		}
+		rw := Rewrite{
+			Next: plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+				m := new(dns.Msg)
+				m.SetReply(r)
+				if r.IsEdns0() != nil {
+					m.SetEdns0(4096, false)
+					o := m.IsEdns0()
+					o.Option = []dns.EDNS0{&dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: "6e73"}}
+				}
+				w.WriteMsg(m)
+				return 0, nil
+			}),
+			Rules: []Rule{r},
+		}
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		if tc.edns0 {
+			m.SetEdns0(4096, false)
+		}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rw.ServeDNS(ctx, rec, m)
+
+		o := rec.Msg.IsEdns0()
+		if !tc.edns0 {
+			if o != nil {
+				t.Errorf("Test %d: Expected no OPT record, got %v", i, o)
+			}
+			continue
+		}
+		if o == nil {
+			t.Errorf("Test %d: EDNS0 options not set", i)
+			continue
+		}
+		if !optsEqual(o.Option, tc.toOpts) {
+			t.Errorf("Test %d: Expected %v but got %v", i, tc.toOpts, o)
+		}
+	}
+}
+
+// ttlRule is a Rule that only implements GetResponseRule, as the rules of other packages may.
+type ttlRule struct{ ttl uint32 }
+
+func (rule ttlRule) Rewrite(ctx context.Context, state request.Request) Result { return RewriteDone }
+func (rule ttlRule) Mode() string                                              { return Stop }
+func (rule ttlRule) GetResponseRule() ResponseRule {
+	return ResponseRule{Active: true, Type: "ttl", TTL: rule.ttl}
+}
+
+func TestRewriteResponseRule(t *testing.T) {
+	next := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A("example.org. 3600 IN A 192.0.2.1")}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	rw := Rewrite{Next: next, Rules: []Rule{ttlRule{ttl: 15}}}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rw.ServeDNS(context.TODO(), rec, m)
+	if ttl := rec.Msg.Answer[0].Header().Ttl; ttl != 15 {
+		t.Errorf("Expected the TTL to be rewritten to 15, got %d", ttl)
+	}
+}
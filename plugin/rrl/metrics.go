@@ -0,0 +1,17 @@
+package rrl
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Variables declared for monitoring.
+var (
+	limitedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "rrl",
+		Name:      "limited_responses_total",
+		Help:      "Counter of responses over the rate limit, per kind of response and action: drop or slip.",
+	}, []string{"server", "kind", "action"})
+)
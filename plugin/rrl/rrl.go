@@ -0,0 +1,233 @@
+// Package rrl implements a plugin that limits the rate of the responses to each client, as in
+// BIND's Response Rate Limiting.
+package rrl
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/clientnet"
+	"github.com/coredns/coredns/plugin/pkg/response"
+	"github.com/coredns/coredns/request"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/miekg/dns"
+)
+
+// RRL is the plugin limiting the identical responses sent to each client network over UDP, where
+// the source address of a query can be spoofed to use us in an amplification attack. Over the limit
+// the responses are dropped, except every slip-th which is sent truncated: a legitimate client then
+// retries over TCP, where it isn't limited.
+type RRL struct {
+	Next plugin.Handler
+
+	Zones   []string
+	ignored []string
+
+	rates  [kinds]float64 // the responses allowed per second, by kind, 0 for no limit
+	window float64        // how many seconds of responses over the rate an account may owe
+	slip   int
+
+	v4prefix int
+	v6prefix int
+	exempt   []*net.IPNet
+
+	table *lru.Cache // the accounts, by key
+	now   func() time.Time
+}
+
+// kind is a kind of response, each kind has its own rate.
+type kind int
+
+const (
+	kindResponse kind = iota
+	kindNoData
+	kindNXDomain
+	kindReferral
+	kindError
+	kinds
+)
+
+var kindNames = [kinds]string{"responses", "nodata", "nxdomains", "referrals", "errors"}
+
+func (k kind) String() string { return kindNames[k] }
+
+const (
+	defaultWindow    = 15
+	defaultSlip      = 2
+	defaultV4Prefix  = 24
+	defaultV6Prefix  = 56
+	defaultTableSize = 100000
+)
+
+// key identifies an account: the identical responses to a client network.
+type key struct {
+	network string
+	kind    kind
+	qtype   uint16
+	name    string
+}
+
+// account is the balance of the responses of a key. It is refilled at the rate, up to a second of
+// responses, and may go down to window seconds of responses in debt. The responses are limited while
+// it is negative.
+type account struct {
+	sync.Mutex
+	balance float64
+	last    time.Time
+	slipped int // the limited responses since the last one slipped
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (rl *RRL) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if state.Proto() != "udp" || !rl.match(state.Name()) {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+	ip := net.ParseIP(state.IP())
+	if ip == nil || clientnet.Contains(rl.exempt, ip) {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+
+	network := string(clientnet.Mask(ip, rl.v4prefix, rl.v6prefix))
+	rw := &ResponseWriter{ResponseWriter: w, rrl: rl, network: network, server: metrics.WithServer(ctx)}
+	return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, rw, r)
+}
+
+// Name implements the plugin.Handler interface.
+func (rl *RRL) Name() string { return "rrl" }
+
+// match returns true if the responses for name are limited: it is in the zones, and not in an
+// ignored one.
+func (rl *RRL) match(name string) bool {
+	if plugin.Zones(rl.Zones).Matches(name) == "" {
+		return false
+	}
+	for _, ignore := range rl.ignored {
+		if plugin.Name(ignore).Matches(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// classify returns the key of the account of res sent to network, false when it isn't limited. Like
+// in BIND, the NXDOMAIN responses are accounted by zone, not to let an attacker escape the limit with
+// random names, the referrals by delegation, and the errors by client.
+func classify(network string, res *dns.Msg) (key, bool) {
+	q := res.Question[0]
+	k := key{network: network, qtype: q.Qtype, name: strings.ToLower(q.Name)}
+	t, _ := response.Typify(res, time.Now().UTC())
+	switch t {
+	case response.NoError:
+		k.kind = kindResponse
+	case response.NoData:
+		k.kind = kindNoData
+	case response.NameError:
+		k.kind, k.qtype = kindNXDomain, 0
+		for _, rr := range res.Ns {
+			if rr.Header().Rrtype == dns.TypeSOA {
+				k.name = strings.ToLower(rr.Header().Name)
+				break
+			}
+		}
+	case response.Delegation:
+		k.kind, k.name = kindReferral, strings.ToLower(res.Ns[0].Header().Name)
+	case response.ServerError, response.OtherError:
+		k.kind, k.qtype, k.name = kindError, 0, ""
+	default:
+		return k, false
+	}
+	return k, true
+}
+
+// debit takes a response from the account of k, and returns true if it is over the limit, and if so
+// whether it slips.
+func (rl *RRL) debit(k key) (limited, slip bool) {
+	rate := rl.rates[k.kind]
+	if rate == 0 {
+		return false, false
+	}
+	now := rl.now()
+
+	var a *account
+	if v, ok := rl.table.Get(k); ok {
+		a = v.(*account)
+	} else {
+		// Two responses racing here may both create an account, the client then gets a few more
+		// responses once, which is fine.
+		a = &account{balance: rate, last: now}
+		rl.table.Add(k, a)
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	if elapsed := now.Sub(a.last); elapsed > 0 {
+		a.balance += rate * elapsed.Seconds()
+		if a.balance > rate {
+			a.balance = rate
+		}
+		a.last = now
+	}
+	a.balance--
+	if a.balance < -rate*rl.window {
+		a.balance = -rate * rl.window
+	}
+	if a.balance >= 0 {
+		a.slipped = 0
+		return false, false
+	}
+
+	if rl.slip == 0 {
+		return true, false
+	}
+	a.slipped++
+	if a.slipped >= rl.slip {
+		a.slipped = 0
+		return true, true
+	}
+	return true, false
+}
+
+// ResponseWriter drops or truncates the responses over the limit.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	rrl     *RRL
+	network string
+	server  string
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	if len(res.Question) == 0 {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+	k, ok := classify(w.network, res)
+	if !ok {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+	limited, slip := w.rrl.debit(k)
+	if !limited {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+	if !slip {
+		limitedCount.WithLabelValues(w.server, k.kind.String(), "drop").Inc()
+		return nil
+	}
+
+	limitedCount.WithLabelValues(w.server, k.kind.String(), "slip").Inc()
+	m := new(dns.Msg)
+	m.SetReply(res)
+	m.Rcode = res.Rcode
+	m.Authoritative, m.RecursionAvailable = res.Authoritative, res.RecursionAvailable
+	m.Truncated = true
+	if opt := res.IsEdns0(); opt != nil {
+		m.SetEdns0(opt.UDPSize(), opt.Do())
+	}
+	return w.ResponseWriter.WriteMsg(m)
+}
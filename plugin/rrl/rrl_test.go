@@ -0,0 +1,123 @@
+package rrl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+// zone answers the A queries of example.org with an address, and the others with NXDOMAIN.
+func zone(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	if r.Question[0].Name == "example.org." && r.Question[0].Qtype == dns.TypeA {
+		m.Answer = append(m.Answer, test.A("example.org. 300 IN A 192.0.2.53"))
+	} else {
+		m.Rcode = dns.RcodeNameError
+		m.Ns = append(m.Ns, test.SOA("example.org. 300 IN SOA ns.example.org. admin.example.org. 1 3600 600 86400 300"))
+	}
+	w.WriteMsg(m)
+	return m.Rcode, nil
+}
+
+func TestRRL(t *testing.T) {
+	c := caddy.NewTestController("dns", "rrl example.org {\nresponses_per_second 2\nwindow 2\nslip 2\nexempt 10.0.0.1\nexcept open.example.org\n}")
+	rl, err := parse(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl.Next = test.HandlerFunc(zone)
+	now := time.Unix(1000, 0)
+	rl.now = func() time.Time { return now }
+
+	const (
+		sent = iota
+		dropped
+		truncated
+	)
+	tests := []struct {
+		elapsed  time.Duration
+		remote   string
+		tcp      bool
+		qname    string
+		expected int
+	}{
+		{0, "192.0.2.1", false, "example.org.", sent},
+		{0, "192.0.2.2", false, "example.org.", sent}, // same /24
+		{0, "192.0.2.3", false, "example.org.", dropped},
+		{0, "192.0.2.3", false, "example.org.", truncated},
+		{0, "192.0.2.3", false, "example.org.", dropped},
+		{0, "192.0.2.3", true, "example.org.", sent},       // TCP isn't limited
+		{0, "198.51.100.1", false, "example.org.", sent},   // another network
+		{0, "10.0.0.1", false, "example.org.", sent},       // exempted
+		{0, "192.0.2.3", false, "open.example.org.", sent}, // ignored zone
+		{0, "192.0.2.3", false, "example.net.", sent},      // not in the zones
+		{0, "192.0.2.1", false, "a.example.org.", sent},    // NXDOMAIN, accounted by zone
+		{0, "192.0.2.1", false, "b.example.org.", sent},
+		{0, "192.0.2.1", false, "c.example.org.", dropped},
+		{1500 * time.Millisecond, "192.0.2.1", false, "example.org.", truncated}, // still in debt
+		{2 * time.Second, "192.0.2.1", false, "example.org.", sent},
+	}
+	for i, tc := range tests {
+		now = now.Add(tc.elapsed)
+
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		w := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: tc.remote, TCP: tc.tcp})
+		if _, err := rl.ServeDNS(context.Background(), w, m); err != nil {
+			t.Fatalf("Test %d: %s", i, err)
+		}
+		got := sent
+		switch {
+		case w.Msg == nil:
+			got = dropped
+		case w.Msg.Truncated:
+			got = truncated
+			if len(w.Msg.Answer) != 0 {
+				t.Errorf("Test %d: expected no answer in a truncated response", i)
+			}
+		}
+		if got != tc.expected {
+			t.Errorf("Test %d: expected response %d, got %d", i, tc.expected, got)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		res     *dns.Msg
+		kind    kind
+		qtype   uint16
+		name    string
+		limited bool
+	}{
+		{&dns.Msg{Answer: []dns.RR{test.A("www.example.org. 300 IN A 192.0.2.53")}}, kindResponse, dns.TypeA, "www.example.org.", true},
+		{&dns.Msg{Ns: []dns.RR{test.SOA("example.org. 300 IN SOA ns.example.org. admin.example.org. 1 3600 600 86400 300")}}, kindNoData, dns.TypeA, "www.example.org.", true},
+		{&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeNameError}, Ns: []dns.RR{test.SOA("Example.org. 300 IN SOA ns.example.org. admin.example.org. 1 3600 600 86400 300")}}, kindNXDomain, 0, "example.org.", true},
+		{&dns.Msg{Ns: []dns.RR{test.NS("www.example.org. 300 IN NS ns.example.net.")}}, kindReferral, dns.TypeA, "www.example.org.", true},
+		{&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeServerFailure}}, kindError, 0, "", true},
+		{&dns.Msg{MsgHdr: dns.MsgHdr{Opcode: dns.OpcodeNotify}}, kindResponse, 0, "", false},
+	}
+	for i, tc := range tests {
+		tc.res.Response = true
+		tc.res.Question = []dns.Question{{Name: "WWW.example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET}}
+		k, limited := classify("net", tc.res)
+		if limited != tc.limited {
+			t.Errorf("Test %d: expected limited %t, got %t", i, tc.limited, limited)
+			continue
+		}
+		if !limited {
+			continue
+		}
+		if k.kind != tc.kind || k.qtype != tc.qtype || k.name != tc.name {
+			t.Errorf("Test %d: expected %s %d %q, got %s %d %q", i, tc.kind, tc.qtype, tc.name, k.kind, k.qtype, k.name)
+		}
+	}
+}
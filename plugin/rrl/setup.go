@@ -0,0 +1,196 @@
+package rrl
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/clientnet"
+
+	"github.com/caddyserver/caddy"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+func init() {
+	caddy.RegisterPlugin("rrl", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	rl, err := parse(c)
+	if err != nil {
+		return plugin.Error("rrl", err)
+	}
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		rl.Next = next
+		return rl
+	})
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, limitedCount)
+		return nil
+	})
+	return nil
+}
+
+// The properties setting the rate of each kind of response.
+var rateProperties = map[string]kind{
+	"responses_per_second": kindResponse,
+	"nodata_per_second":    kindNoData,
+	"nxdomains_per_second": kindNXDomain,
+	"referrals_per_second": kindReferral,
+	"errors_per_second":    kindError,
+}
+
+func parse(c *caddy.Controller) (*RRL, error) {
+	rl := &RRL{
+		window:   defaultWindow,
+		slip:     defaultSlip,
+		v4prefix: defaultV4Prefix,
+		v6prefix: defaultV6Prefix,
+		now:      time.Now,
+	}
+	tableSize := defaultTableSize
+	var rates [kinds]int
+	for k := range rates {
+		rates[k] = -1
+	}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		rl.Zones = c.RemainingArgs()
+		if len(rl.Zones) == 0 {
+			rl.Zones = make([]string, len(c.ServerBlockKeys))
+			copy(rl.Zones, c.ServerBlockKeys)
+		}
+		for i, str := range rl.Zones {
+			rl.Zones[i] = plugin.Host(str).Normalize()
+		}
+
+		for c.NextBlock() {
+			if k, ok := rateProperties[c.Val()]; ok {
+				n, err := parseInt(c)
+				if err != nil {
+					return nil, err
+				}
+				rates[k] = n
+				continue
+			}
+
+			switch c.Val() {
+			case "window":
+				n, err := parseInt(c)
+				if err != nil {
+					return nil, err
+				}
+				if n == 0 {
+					return nil, c.Errf("invalid window: %d", n)
+				}
+				rl.window = float64(n)
+			case "slip":
+				n, err := parseInt(c)
+				if err != nil {
+					return nil, err
+				}
+				rl.slip = n
+			case "ipv4_prefix_length":
+				n, err := parseInt(c)
+				if err != nil {
+					return nil, err
+				}
+				if n == 0 || n > 8*net.IPv4len {
+					return nil, c.Errf("invalid IPv4 prefix length: %d", n)
+				}
+				rl.v4prefix = n
+			case "ipv6_prefix_length":
+				n, err := parseInt(c)
+				if err != nil {
+					return nil, err
+				}
+				if n == 0 || n > 8*net.IPv6len {
+					return nil, c.Errf("invalid IPv6 prefix length: %d", n)
+				}
+				rl.v6prefix = n
+			case "max_table_size":
+				n, err := parseInt(c)
+				if err != nil {
+					return nil, err
+				}
+				if n == 0 {
+					return nil, c.Errf("invalid max_table_size: %d", n)
+				}
+				tableSize = n
+			case "exempt":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					n, err := clientnet.Parse(a)
+					if err != nil {
+						return nil, c.Errf("invalid network: %s", a)
+					}
+					rl.exempt = append(rl.exempt, n)
+				}
+			case "except":
+				ignore := c.RemainingArgs()
+				if len(ignore) == 0 {
+					return nil, c.ArgErr()
+				}
+				for i := range ignore {
+					ignore[i] = plugin.Host(ignore[i]).Normalize()
+				}
+				rl.ignored = append(rl.ignored, ignore...)
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+
+	// The other kinds of responses are limited at the rate of the responses, unless set.
+	if rates[kindResponse] < 0 {
+		rates[kindResponse] = 0
+	}
+	limited := false
+	for k, n := range rates {
+		if n < 0 {
+			n = rates[kindResponse]
+		}
+		rl.rates[k] = float64(n)
+		limited = limited || n > 0
+	}
+	if !limited {
+		return nil, c.Err("no rate set")
+	}
+
+	table, err := lru.New(tableSize)
+	if err != nil {
+		return nil, err
+	}
+	rl.table = table
+	return rl, nil
+}
+
+// parseInt parses the single non-negative integer argument of a property.
+func parseInt(c *caddy.Controller) (int, error) {
+	prop := c.Val()
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return 0, c.Errf("invalid %s: %s", prop, args[0])
+	}
+	return n, nil
+}
@@ -0,0 +1,65 @@
+package rrl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		zones     []string
+		ignored   []string
+		rates     [kinds]float64
+		window    float64
+		slip      int
+	}{
+		{"rrl {\nresponses_per_second 5\n}", false, []string{"example.org."}, nil, [kinds]float64{5, 5, 5, 5, 5}, 15, 2},
+		{"rrl example.org example.net {\nresponses_per_second 10\nnxdomains_per_second 2\nerrors_per_second 0\nwindow 5\nslip 0\n}", false, []string{"example.org.", "example.net."}, nil, [kinds]float64{10, 10, 2, 10, 0}, 5, 0},
+		{"rrl {\nnxdomains_per_second 2\nexcept a.example.org B.example.org\n}", false, []string{"example.org."}, []string{"a.example.org.", "b.example.org."}, [kinds]float64{0, 0, 2, 0, 0}, 15, 2},
+		{"rrl {\nresponses_per_second 5\nipv4_prefix_length 32\nipv6_prefix_length 64\nmax_table_size 1000\nexempt 10.0.0.0/8 2001:db8::1\n}", false, []string{"example.org."}, nil, [kinds]float64{5, 5, 5, 5, 5}, 15, 2},
+		{`rrl`, true, nil, nil, [kinds]float64{}, 0, 0},
+		{"rrl {\nresponses_per_second 0\n}", true, nil, nil, [kinds]float64{}, 0, 0},
+		{"rrl {\nresponses_per_second -1\n}", true, nil, nil, [kinds]float64{}, 0, 0},
+		{"rrl {\nresponses_per_second 5 10\n}", true, nil, nil, [kinds]float64{}, 0, 0},
+		{"rrl {\nresponses_per_second 5\nwindow 0\n}", true, nil, nil, [kinds]float64{}, 0, 0},
+		{"rrl {\nresponses_per_second 5\nipv4_prefix_length 33\n}", true, nil, nil, [kinds]float64{}, 0, 0},
+		{"rrl {\nresponses_per_second 5\nipv6_prefix_length 0\n}", true, nil, nil, [kinds]float64{}, 0, 0},
+		{"rrl {\nresponses_per_second 5\nmax_table_size 0\n}", true, nil, nil, [kinds]float64{}, 0, 0},
+		{"rrl {\nresponses_per_second 5\nexempt example.org\n}", true, nil, nil, [kinds]float64{}, 0, 0},
+		{"rrl {\nresponses_per_second 5\nexcept\n}", true, nil, nil, [kinds]float64{}, 0, 0},
+		{"rrl {\nqueries_per_second 5\n}", true, nil, nil, [kinds]float64{}, 0, 0},
+		{"rrl {\nresponses_per_second 5\n}\nrrl {\nresponses_per_second 5\n}", true, nil, nil, [kinds]float64{}, 0, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		c.ServerBlockKeys = []string{"example.org"}
+		rl, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(rl.Zones, tc.zones) {
+			t.Errorf("Test %d: expected zones %v, got %v", i, tc.zones, rl.Zones)
+		}
+		if !reflect.DeepEqual(rl.ignored, tc.ignored) {
+			t.Errorf("Test %d: expected ignored zones %v, got %v", i, tc.ignored, rl.ignored)
+		}
+		if rl.rates != tc.rates {
+			t.Errorf("Test %d: expected rates %v, got %v", i, tc.rates, rl.rates)
+		}
+		if rl.window != tc.window || rl.slip != tc.slip {
+			t.Errorf("Test %d: expected window %v and slip %d, got %v and %d", i, tc.window, tc.slip, rl.window, rl.slip)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package secondary
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/changes"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+
+	"github.com/miekg/dns"
+)
+
+// catalog provisions the member zones of the catalog zones as secondaries, see RFC 9432. A member
+// zone is transferred from the primaries of its catalog zone, and removed when it leaves the catalog.
+// The zones served, those of the Corefile and the members, are swapped at once when the members
+// change.
+type catalog struct {
+	static   file.Zones // the zones of the Corefile, with the catalog zones
+	catalogs []string   // the names of the catalog zones
+	upstream *upstream.Upstream
+
+	mu      sync.Mutex        // serializes the syncs
+	members map[string]string // the member zones, with their catalog zone
+	zones   atomic.Value      // file.Zones, the zones served
+
+	start   func(z *file.Zone) // starts the transfers of a new member zone
+	changed chan struct{}
+	done    chan struct{}
+	stop    func()
+}
+
+func newCatalog(static file.Zones, catalogs []string, upstr *upstream.Upstream) *catalog {
+	c := &catalog{
+		static:   static,
+		catalogs: catalogs,
+		upstream: upstr,
+		members:  make(map[string]string),
+		start:    startZone,
+		changed:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	c.zones.Store(static)
+	return c
+}
+
+// Zones returns the zones served.
+func (c *catalog) Zones() file.Zones { return c.zones.Load().(file.Zones) }
+
+// OnStartup syncs the members whenever a catalog zone is transferred.
+func (c *catalog) OnStartup() error {
+	c.stop = changes.Watch(func(zone string) {
+		for _, name := range c.catalogs {
+			if name != zone {
+				continue
+			}
+			select {
+			case c.changed <- struct{}{}:
+			default:
+			}
+		}
+	})
+	// The catalog zones may have been transferred already.
+	c.changed <- struct{}{}
+	go func() {
+		for {
+			select {
+			case <-c.changed:
+				c.sync()
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// OnShutdown stops syncing, and stops the transfers of the member zones.
+func (c *catalog) OnShutdown() error {
+	if c.stop != nil {
+		c.stop()
+	}
+	close(c.done)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	zones := c.Zones()
+	for m := range c.members {
+		zones.Z[m].StopUpdate()
+	}
+	return nil
+}
+
+// sync adds the zones that joined the catalog zones, and removes those that left.
+func (c *catalog) sync() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	want := make(map[string]string)
+	for _, name := range c.catalogs {
+		members, err := Members(c.static.Z[name])
+		if err != nil {
+			if err != errNotTransferred {
+				log.Warningf("Catalog zone %s: %s, keeping its members", name, err)
+			}
+			for m, cat := range c.members {
+				if cat == name {
+					want[m] = cat
+				}
+			}
+			continue
+		}
+		for _, m := range members {
+			if _, ok := c.static.Z[m]; ok {
+				continue // the Corefile wins
+			}
+			if cat, ok := want[m]; ok {
+				log.Warningf("Zone %s is a member of the catalog zones %s and %s, keeping the first", m, cat, name)
+				continue
+			}
+			want[m] = name
+		}
+	}
+
+	cur := c.Zones()
+	z := make(map[string]*file.Zone, len(c.static.Z)+len(want))
+	names := make([]string, len(c.static.Names), len(c.static.Names)+len(want))
+	copy(names, c.static.Names)
+	for n, zo := range c.static.Z {
+		z[n] = zo
+	}
+
+	var added []*file.Zone
+	for m, cat := range want {
+		zo, ok := cur.Z[m]
+		if !ok || c.members[m] != cat {
+			zo = c.newMember(m, cat)
+			added = append(added, zo)
+			log.Infof("Zone %s joined the catalog zone %s", m, cat)
+		}
+		z[m] = zo
+		names = append(names, m)
+	}
+	for m, cat := range c.members {
+		if want[m] != cat {
+			cur.Z[m].StopUpdate()
+			if _, ok := want[m]; !ok {
+				log.Infof("Zone %s left the catalog zone %s", m, cat)
+			}
+		}
+	}
+
+	c.members = want
+	c.zones.Store(file.Zones{Z: z, Names: names})
+	for _, zo := range added {
+		c.start(zo)
+	}
+}
+
+// newMember returns the member zone name of the catalog zone cat.
+func (c *catalog) newMember(name, cat string) *file.Zone {
+	z := file.NewZone(name, "stdin")
+	z.TransferFrom = c.static.Z[cat].TransferFrom
+	z.TransferTo = c.static.Z[cat].TransferTo
+	z.TransferKeys = c.static.Z[cat].TransferKeys
+	z.TsigSecret = c.static.Z[cat].TsigSecret
+	z.Upstream = c.upstream
+	return z
+}
+
+var errNotTransferred = errors.New("not transferred yet")
+
+// startZone transfers the zone z, and keeps it up to date.
+func startZone(z *file.Zone) {
+	go func() {
+		z.TransferIn()
+		z.Update()
+	}()
+}
+
+// Members returns the member zones of the catalog zone z, see RFC 9432 4.1. The catalog zone must
+// be of version 2.
+func Members(z *file.Zone) ([]string, error) {
+	rrs := z.All()
+	if len(rrs) == 0 || rrs[0].Header().Rrtype != dns.TypeSOA {
+		return nil, errNotTransferred
+	}
+	origin := strings.ToLower(rrs[0].Header().Name)
+
+	version := ""
+	ptrs := make(map[string][]string) // the PTR records of the member nodes, by owner
+	zones := "zones." + origin
+	for _, rr := range rrs {
+		name := strings.ToLower(rr.Header().Name)
+		switch rr := rr.(type) {
+		case *dns.TXT:
+			if name == "version."+origin && len(rr.Txt) > 0 {
+				version = rr.Txt[0]
+			}
+		case *dns.PTR:
+			// The member nodes are <unique-N>.zones.<catalog>.
+			if dns.IsSubDomain(zones, name) && dns.CountLabel(name) == dns.CountLabel(zones)+1 {
+				ptrs[name] = append(ptrs[name], strings.ToLower(dns.Fqdn(rr.Ptr)))
+			}
+		}
+	}
+	if version != "2" {
+		return nil, fmt.Errorf("unsupported catalog zone version %q", version)
+	}
+
+	owners := make([]string, 0, len(ptrs))
+	for owner := range ptrs {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	seen := make(map[string]bool)
+	var members []string
+	for _, owner := range owners {
+		if len(ptrs[owner]) != 1 {
+			log.Warningf("Catalog zone %s: member node %s has %d PTR records, ignoring it", origin, owner, len(ptrs[owner]))
+			continue
+		}
+		m := ptrs[owner][0]
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		members = append(members, m)
+	}
+	return members, nil
+}
@@ -0,0 +1,131 @@
+package secondary
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/changes"
+)
+
+const dbCatalog = `
+$ORIGIN catalog.example.
+@ 3600 IN SOA invalid. hostmaster.invalid. 1 3600 600 86400 300
+@ 3600 IN NS invalid.
+version 0 IN TXT "2"
+a.zones 0 IN PTR example.org.
+b.zones 0 IN PTR EXAMPLE.net.
+group.b.zones 0 IN TXT "edge"
+c.zones 0 IN PTR example.org.
+d.zones 0 IN PTR example.com.
+d.zones 0 IN PTR example.info.
+`
+
+func parseCatalog(t *testing.T, db string) *file.Zone {
+	z, err := file.Parse(strings.NewReader(db), "catalog.example.", "stdin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return z
+}
+
+func TestMembers(t *testing.T) {
+	members, err := Members(parseCatalog(t, dbCatalog))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	// The duplicate example.org. is listed once, d.zones with two PTR records is ignored.
+	if got := strings.Join(members, " "); got != "example.org. example.net." {
+		t.Errorf("Expected members example.org. and example.net., got %s", got)
+	}
+
+	if _, err := Members(parseCatalog(t, strings.Replace(dbCatalog, `"2"`, `"1"`, 1))); err == nil {
+		t.Errorf("Expected error for a catalog zone of version 1")
+	}
+	if _, err := Members(file.NewZone("catalog.example.", "stdin")); err != errNotTransferred {
+		t.Errorf("Expected %q, got %v", errNotTransferred, err)
+	}
+}
+
+func TestCatalogSync(t *testing.T) {
+	cz := parseCatalog(t, dbCatalog)
+	cz.TransferFrom = []string{"10.0.1.1:53"}
+	static := file.Zones{
+		Z:     map[string]*file.Zone{"catalog.example.": cz, "example.net.": file.NewZone("example.net.", "stdin")},
+		Names: []string{"catalog.example.", "example.net."},
+	}
+
+	c := newCatalog(static, []string{"catalog.example."}, nil)
+	var started []string
+	c.start = func(z *file.Zone) {
+		for n, zo := range c.Zones().Z {
+			if zo == z {
+				started = append(started, n)
+			}
+		}
+	}
+
+	c.sync()
+	// example.net. is in the Corefile, which wins.
+	if strings.Join(started, " ") != "example.org." {
+		t.Errorf("Expected example.org. to be started, got %v", started)
+	}
+	zones := c.Zones()
+	if len(zones.Names) != 3 || zones.Z["example.org."] == nil {
+		t.Fatalf("Expected the member example.org. to be served, got %v", zones.Names)
+	}
+	if from := zones.Z["example.org."].TransferFrom; len(from) != 1 || from[0] != "10.0.1.1:53" {
+		t.Errorf("Expected the member to be transferred from the primary of the catalog zone, got %v", from)
+	}
+
+	// A sync without changes keeps the member as is.
+	member := zones.Z["example.org."]
+	started = nil
+	c.sync()
+	if len(started) != 0 || c.Zones().Z["example.org."] != member {
+		t.Errorf("Expected the member to be kept, got %v started", started)
+	}
+
+	// example.org. leaves the catalog.
+	cz1 := parseCatalog(t, strings.Replace(strings.Replace(dbCatalog, "a.zones", ";", 1), "c.zones", ";", 1))
+	cz.Tree, cz.Apex = cz1.Tree, cz1.Apex
+	c.sync()
+	if zones := c.Zones(); len(zones.Names) != 2 || zones.Z["example.org."] != nil {
+		t.Errorf("Expected the member example.org. to be removed, got %v", zones.Names)
+	}
+	// Its transfers are stopped.
+	done := make(chan struct{})
+	go func() { member.Update(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected the updates of the removed member to be stopped")
+	}
+}
+
+func TestCatalogChanges(t *testing.T) {
+	cz := file.NewZone("catalog.example.", "stdin")
+	cz.TransferFrom = []string{"10.0.1.1:53"}
+	static := file.Zones{Z: map[string]*file.Zone{"catalog.example.": cz}, Names: []string{"catalog.example."}}
+
+	c := newCatalog(static, []string{"catalog.example."}, nil)
+	c.start = func(*file.Zone) {}
+	c.OnStartup()
+	defer c.OnShutdown()
+
+	// The catalog zone is transferred.
+	cz1 := parseCatalog(t, dbCatalog)
+	cz.Lock()
+	cz.Tree, cz.Apex = cz1.Tree, cz1.Apex
+	cz.Unlock()
+	changes.Notify("catalog.example.")
+
+	for i := 0; i < 100; i++ {
+		if len(c.Zones().Names) == 3 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected the members to be added after the transfer, got %v", c.Zones().Names)
+}
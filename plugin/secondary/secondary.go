@@ -1,10 +1,31 @@
 // Package secondary implements a secondary plugin.
 package secondary
 
-import "github.com/coredns/coredns/plugin/file"
+import (
+	"context"
+
+	"github.com/coredns/coredns/plugin/file"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/miekg/dns"
+)
+
+var log = clog.NewWithPlugin("secondary")
 
 // Secondary implements a secondary plugin that allows CoreDNS to retrieve (via AXFR)
 // zone information from a primary server.
 type Secondary struct {
 	file.File
+
+	catalog *catalog // nil without catalog zones
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (s Secondary) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	if s.catalog == nil {
+		return s.File.ServeDNS(ctx, w, r)
+	}
+	f := s.File
+	f.Zones = s.catalog.Zones()
+	return f.ServeDNS(ctx, w, r)
 }
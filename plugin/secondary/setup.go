@@ -1,6 +1,8 @@
 package secondary
 
 import (
+	"fmt"
+
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/file"
@@ -18,7 +20,7 @@ func init() {
 }
 
 func setup(c *caddy.Controller) error {
-	zones, err := secondaryParse(c)
+	zones, catalogs, err := secondaryParse(c)
 	if err != nil {
 		return plugin.Error("secondary", err)
 	}
@@ -39,16 +41,24 @@ func setup(c *caddy.Controller) error {
 		}
 	}
 
+	var cat *catalog
+	if len(catalogs) > 0 {
+		cat = newCatalog(zones, catalogs, upstream.New())
+		c.OnStartup(cat.OnStartup)
+		c.OnShutdown(cat.OnShutdown)
+	}
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
-		return Secondary{file.File{Next: next, Zones: zones}}
+		return Secondary{File: file.File{Next: next, Zones: zones}, catalog: cat}
 	})
 
 	return nil
 }
 
-func secondaryParse(c *caddy.Controller) (file.Zones, error) {
+func secondaryParse(c *caddy.Controller) (file.Zones, []string, error) {
 	z := make(map[string]*file.Zone)
 	names := []string{}
+	catalogs := []string{}
 	upstr := upstream.New()
 	for c.Next() {
 
@@ -69,25 +79,49 @@ func secondaryParse(c *caddy.Controller) (file.Zones, error) {
 			for c.NextBlock() {
 
 				t, f := []string{}, []string{}
+				var key *parse.Key
 				var e error
 
 				switch c.Val() {
 				case "transfer":
-					t, f, e = parse.Transfer(c, true)
+					t, f, key, e = parse.Transfer(c, true)
 					if e != nil {
-						return file.Zones{}, e
+						return file.Zones{}, nil, e
+					}
+					if key != nil {
+						if err := dnsserver.GetConfig(c).AddTsigKey(key.Name, key.Secret); err != nil {
+							return file.Zones{}, nil, err
+						}
 					}
 				case "upstream":
 					// remove soon
 					c.RemainingArgs()
+				case "catalog":
+					if len(c.RemainingArgs()) != 0 {
+						return file.Zones{}, nil, c.ArgErr()
+					}
+					catalogs = append(catalogs, origins...)
 				default:
-					return file.Zones{}, c.Errf("unknown property '%s'", c.Val())
+					return file.Zones{}, nil, c.Errf("unknown property '%s'", c.Val())
 				}
 
 				for _, origin := range origins {
 					if t != nil {
 						z[origin].TransferTo = append(z[origin].TransferTo, t...)
 					}
+					if key != nil {
+						if z[origin].TransferKeys == nil {
+							z[origin].TransferKeys = make(map[string]string)
+							z[origin].TsigSecret = make(map[string]string)
+						}
+						z[origin].TsigSecret[key.Name] = key.Secret
+						for _, to := range t {
+							z[origin].TransferKeys[to] = key.Name
+						}
+						for _, from := range f {
+							z[origin].TransferKeys[from] = key.Name
+						}
+					}
 					if f != nil {
 						z[origin].TransferFrom = append(z[origin].TransferFrom, f...)
 					}
@@ -96,5 +130,10 @@ func secondaryParse(c *caddy.Controller) (file.Zones, error) {
 			}
 		}
 	}
-	return file.Zones{Z: z, Names: names}, nil
+	for _, name := range catalogs {
+		if len(z[name].TransferFrom) == 0 {
+			return file.Zones{}, nil, fmt.Errorf("catalog zone %s has no primary to transfer from", name)
+		}
+	}
+	return file.Zones{Z: z, Names: names}, catalogs, nil
 }
@@ -41,7 +41,7 @@ func TestSecondaryParse(t *testing.T) {
 
 	for i, test := range tests {
 		c := caddy.NewTestController("dns", test.inputFileRules)
-		s, err := secondaryParse(c)
+		s, _, err := secondaryParse(c)
 
 		if err == nil && test.shouldErr {
 			t.Fatalf("Test %d expected errors, but got no error", i)
@@ -63,3 +63,44 @@ func TestSecondaryParse(t *testing.T) {
 		}
 	}
 }
+
+func TestSecondaryParseCatalog(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		catalogs  []string
+	}{
+		{`secondary catalog.example.org {
+			transfer from 127.0.0.1
+			catalog
+		}`, false, []string{"catalog.example.org."}},
+		{`secondary example.org {
+			transfer from 127.0.0.1
+		}`, false, nil},
+		{`secondary catalog.example.org {
+			catalog
+		}`, true, nil},
+		{`secondary catalog.example.org {
+			transfer from 127.0.0.1
+			catalog yes
+		}`, true, nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		_, catalogs, err := secondaryParse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(catalogs) != len(test.catalogs) || (len(catalogs) > 0 && catalogs[0] != test.catalogs[0]) {
+			t.Errorf("Test %d: expected catalog zones %v, got %v", i, test.catalogs, catalogs)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package shed
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Variables declared for monitoring.
+var (
+	heapBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "shed",
+		Name:      "heap_bytes",
+		Help:      "Gauge of the bytes of allocated heap objects, last sampled.",
+	})
+	limitBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "shed",
+		Name:      "limit_bytes",
+		Help:      "Gauge of the heap limit load is shed against.",
+	})
+	stageGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "shed",
+		Name:      "stage",
+		Help:      "Gauge of the load shedding stage: 0 none, 1 no prefetch, 2 shrunk caches, 3 refusing queries.",
+	})
+	refusedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "shed",
+		Name:      "refused_requests_total",
+		Help:      "Counter of requests refused to shed load, per client class.",
+	}, []string{"server", "class"})
+)
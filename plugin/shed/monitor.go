@@ -0,0 +1,131 @@
+package shed
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/pressure"
+)
+
+// The fractions of the limit at which the stages start. A stage ends when the usage drops to
+// hysteresis below where it starts, so it doesn't flap.
+var thresholds = [...]float64{
+	pressure.NoPrefetch:  0.70,
+	pressure.ShrinkCache: 0.80,
+	pressure.Refuse:      0.90,
+}
+
+const hysteresis = 0.05
+
+// monitor samples the heap, and sets the stage of pressure from its usage of the limit. The heap is
+// the process', so there is a single monitor for all the Sheds: it uses the smallest limit and
+// interval of them.
+type monitor struct {
+	mu    sync.Mutex
+	sheds map[*Shed]bool
+	stop  chan struct{}
+	usage uint64 // the float64 bits of the last usage, accessed atomically
+}
+
+var mon = &monitor{sheds: map[*Shed]bool{}}
+
+// add starts monitoring for s.
+func (m *monitor) add(s *Shed) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sheds[s] = true
+	if m.stop == nil {
+		m.stop = make(chan struct{})
+		go m.run(m.stop)
+	}
+}
+
+// remove stops monitoring for s. When it was the last one, the load isn't shed anymore.
+func (m *monitor) remove(s *Shed) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sheds, s)
+	if len(m.sheds) > 0 || m.stop == nil {
+		return
+	}
+	close(m.stop)
+	m.stop = nil
+	m.store(0)
+	pressure.Set(pressure.None)
+	stageGauge.Set(float64(pressure.None))
+}
+
+// config returns the smallest limit and interval of the Sheds.
+func (m *monitor) config() (limit uint64, interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for s := range m.sheds {
+		if limit == 0 || s.limit < limit {
+			limit = s.limit
+		}
+		if interval == 0 || s.interval < interval {
+			interval = s.interval
+		}
+	}
+	return limit, interval
+}
+
+func (m *monitor) run(stop chan struct{}) {
+	for {
+		limit, interval := m.config()
+		if limit > 0 {
+			m.sample(stop, limit)
+		}
+		if interval == 0 {
+			interval = defaultInterval
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// sample reads the heap usage of limit, and sets the stage, unless the monitoring is stopped.
+func (m *monitor) sample(stop chan struct{}, limit uint64) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	usage := float64(ms.HeapAlloc) / float64(limit)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != stop {
+		return
+	}
+	m.store(usage)
+	s := stageOf(usage, pressure.Current())
+	pressure.Set(s)
+
+	heapBytes.Set(float64(ms.HeapAlloc))
+	limitBytes.Set(float64(limit))
+	stageGauge.Set(float64(s))
+}
+
+func (m *monitor) store(usage float64) { atomic.StoreUint64(&m.usage, math.Float64bits(usage)) }
+
+// load returns the last usage of the limit.
+func (m *monitor) load() float64 { return math.Float64frombits(atomic.LoadUint64(&m.usage)) }
+
+// stageOf returns the stage for usage, when the current stage is cur.
+func stageOf(usage float64, cur pressure.Stage) pressure.Stage {
+	s := pressure.None
+	for st := pressure.NoPrefetch; st <= pressure.Refuse; st++ {
+		t := thresholds[st]
+		if st <= cur {
+			t -= hysteresis
+		}
+		if usage >= t {
+			s = st
+		}
+	}
+	return s
+}
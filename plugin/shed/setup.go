@@ -0,0 +1,206 @@
+package shed
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("shed", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	s, err := parse(c)
+	if err != nil {
+		return plugin.Error("shed", err)
+	}
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		s.Next = next
+		return s
+	})
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, heapBytes, limitBytes, stageGauge, refusedCount)
+		mon.add(s)
+		return nil
+	})
+	c.OnShutdown(func() error {
+		mon.remove(s)
+		return nil
+	})
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Shed, error) {
+	s := &Shed{interval: defaultInterval}
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		switch len(args) {
+		case 0:
+			limit, err := cgroupLimit()
+			if err != nil {
+				return nil, c.Errf("no memory limit: %s", err)
+			}
+			s.limit = limit
+		case 1:
+			limit, err := parseSize(args[0])
+			if err != nil {
+				return nil, c.Errf("invalid memory limit: %s", args[0])
+			}
+			s.limit = limit
+		default:
+			return nil, c.ArgErr()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "interval":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil || d <= 0 {
+					return nil, c.Errf("invalid interval: %s", args[0])
+				}
+				s.interval = d
+			case "class":
+				if err := parseClass(c, s); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+
+	seen := map[int]bool{s.def: true}
+	s.priorities = []int{s.def}
+	for _, cl := range s.classes {
+		if !seen[cl.priority] {
+			seen[cl.priority] = true
+			s.priorities = append(s.priorities, cl.priority)
+		}
+	}
+	sort.Ints(s.priorities)
+	return s, nil
+}
+
+// parseClass parses `class NAME PRIORITY [NETWORK...]`. The networks are required, except for the
+// default class, which holds the clients in no other class.
+func parseClass(c *caddy.Controller, s *Shed) error {
+	args := c.RemainingArgs()
+	if len(args) < 2 {
+		return c.ArgErr()
+	}
+	name := args[0]
+	priority, err := strconv.Atoi(args[1])
+	if err != nil {
+		return c.Errf("invalid priority: %s", args[1])
+	}
+	for _, cl := range s.classes {
+		if cl.name == name {
+			return c.Errf("duplicate class: %s", name)
+		}
+	}
+	if name == defaultClass {
+		if len(args) > 2 {
+			return c.Errf("the %s class holds the clients in no other class, it has no networks", defaultClass)
+		}
+		s.def = priority
+		return nil
+	}
+	if len(args) == 2 {
+		return c.ArgErr()
+	}
+
+	cl := class{name: name, priority: priority}
+	for _, a := range args[2:] {
+		_, n, err := net.ParseCIDR(a)
+		if err != nil {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return c.Errf("invalid network: %s", a)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			n = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+		cl.nets = append(cl.nets, n)
+	}
+	s.classes = append(s.classes, cl)
+	return nil
+}
+
+// parseSize parses a number of bytes, with an optional K, M or G suffix.
+func parseSize(s string) (uint64, error) {
+	mult := uint64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, errors.New("zero size")
+	}
+	return n * mult, nil
+}
+
+// The files holding the memory limit of the cgroup of the process, for cgroup v2 and v1.
+var cgroupLimitFiles = []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"}
+
+// cgroupLimit returns the memory limit of the cgroup of the process, as set by a container runtime.
+func cgroupLimit() (uint64, error) {
+	for _, f := range cgroupLimitFiles {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		s := strings.TrimSpace(string(b))
+		if s == "max" {
+			return 0, errors.New("the cgroup has no memory limit")
+		}
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		// Without a limit, cgroup v1 reports the largest multiple of the page size.
+		if n >= 1<<62 {
+			return 0, errors.New("the cgroup has no memory limit")
+		}
+		return n, nil
+	}
+	return 0, errors.New("no cgroup memory limit found")
+}
@@ -0,0 +1,111 @@
+package shed
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input      string
+		shouldErr  bool
+		limit      uint64
+		interval   time.Duration
+		def        int
+		classes    []string
+		priorities []int
+	}{
+		{`shed 512M`, false, 512 << 20, time.Second, 0, nil, []int{0}},
+		{`shed 1048576`, false, 1 << 20, time.Second, 0, nil, []int{0}},
+		{"shed 2G {\ninterval 100ms\n}", false, 2 << 30, 100 * time.Millisecond, 0, nil, []int{0}},
+		{"shed 1G {\nclass internal 10 10.0.0.0/8 192.0.2.1\nclass partners 5 198.51.100.0/24\nclass default 5\n}", false, 1 << 30, time.Second, 5, []string{"internal", "partners"}, []int{5, 10}},
+		{`shed`, true, 0, 0, 0, nil, nil}, // no cgroup limit in the tests
+		{`shed 0`, true, 0, 0, 0, nil, nil},
+		{`shed 1T`, true, 0, 0, 0, nil, nil},
+		{`shed 1G 2G`, true, 0, 0, 0, nil, nil},
+		{"shed 1G {\ninterval 0s\n}", true, 0, 0, 0, nil, nil},
+		{"shed 1G {\nclass internal 10\n}", true, 0, 0, 0, nil, nil},
+		{"shed 1G {\nclass internal high 10.0.0.0/8\n}", true, 0, 0, 0, nil, nil},
+		{"shed 1G {\nclass internal 10 example.org\n}", true, 0, 0, 0, nil, nil},
+		{"shed 1G {\nclass internal 10 10.0.0.0/8\nclass internal 5 192.0.2.0/24\n}", true, 0, 0, 0, nil, nil},
+		{"shed 1G {\nclass default 5 10.0.0.0/8\n}", true, 0, 0, 0, nil, nil},
+		{"shed 1G {\nlimit 2G\n}", true, 0, 0, 0, nil, nil},
+		{"shed 1G\nshed 1G", true, 0, 0, 0, nil, nil},
+	}
+
+	defer func(files []string) { cgroupLimitFiles = files }(cgroupLimitFiles)
+	cgroupLimitFiles = nil
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		s, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if s.limit != tc.limit || s.interval != tc.interval || s.def != tc.def {
+			t.Errorf("Test %d: expected limit %d, interval %s and default priority %d, got %d, %s and %d", i, tc.limit, tc.interval, tc.def, s.limit, s.interval, s.def)
+		}
+		var classes []string
+		for _, cl := range s.classes {
+			classes = append(classes, cl.name)
+		}
+		if !reflect.DeepEqual(classes, tc.classes) {
+			t.Errorf("Test %d: expected classes %v, got %v", i, tc.classes, classes)
+		}
+		if !reflect.DeepEqual(s.priorities, tc.priorities) {
+			t.Errorf("Test %d: expected priorities %v, got %v", i, tc.priorities, s.priorities)
+		}
+	}
+}
+
+func TestCgroupLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(files []string) { cgroupLimitFiles = files }(cgroupLimitFiles)
+
+	tests := []struct {
+		content   string
+		shouldErr bool
+		expected  uint64
+	}{
+		{"268435456\n", false, 256 << 20},
+		{"max\n", true, 0},
+		{"9223372036854771712\n", true, 0},
+		{"garbage\n", true, 0},
+	}
+	for i, tc := range tests {
+		f := filepath.Join(dir, "memory.max")
+		if err := ioutil.WriteFile(f, []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cgroupLimitFiles = []string{filepath.Join(dir, "missing"), f}
+		limit, err := cgroupLimit()
+		if (err != nil) != tc.shouldErr {
+			t.Errorf("Test %d: expected error %t, got %v", i, tc.shouldErr, err)
+		}
+		if limit != tc.expected {
+			t.Errorf("Test %d: expected limit %d, got %d", i, tc.expected, limit)
+		}
+	}
+
+	c := caddy.NewTestController("dns", `shed`)
+	if err := setup(c); err == nil {
+		t.Error("Expected an error without a cgroup limit")
+	}
+}
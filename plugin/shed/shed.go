@@ -0,0 +1,92 @@
+// Package shed implements a plugin that sheds load when the memory nears its limit.
+package shed
+
+import (
+	"context"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/pressure"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Shed is the plugin refusing the queries of the clients with the lowest priority when the memory is
+// under pressure. The other stages of pressure are applied by the plugins concerned, like cache.
+type Shed struct {
+	Next plugin.Handler
+
+	limit    uint64        // the heap limit, in bytes
+	interval time.Duration // how often the heap is sampled
+
+	classes    []class
+	def        int   // the priority of the clients in no class
+	priorities []int // the distinct priorities, sorted
+}
+
+// class is a class of clients, by network.
+type class struct {
+	name     string
+	priority int
+	nets     []*net.IPNet
+}
+
+const (
+	defaultInterval = time.Second
+	defaultClass    = "default"
+)
+
+// ServeDNS implements the plugin.Handler interface.
+func (s *Shed) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	if pressure.Current() < pressure.Refuse {
+		return plugin.NextOrFailure(s.Name(), s.Next, ctx, w, r)
+	}
+
+	state := request.Request{W: w, Req: r}
+	name, priority := s.classify(net.ParseIP(state.IP()))
+	if !s.refused(mon.load(), priority) {
+		return plugin.NextOrFailure(s.Name(), s.Next, ctx, w, r)
+	}
+
+	refusedCount.WithLabelValues(metrics.WithServer(ctx), name).Inc()
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeRefused)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// Name implements the plugin.Handler interface.
+func (s *Shed) Name() string { return "shed" }
+
+// classify returns the name and the priority of the class of the client ip, the first class
+// holding it.
+func (s *Shed) classify(ip net.IP) (string, int) {
+	for _, c := range s.classes {
+		for _, n := range c.nets {
+			if n.Contains(ip) {
+				return c.name, c.priority
+			}
+		}
+	}
+	return defaultClass, s.def
+}
+
+// refused returns true if the queries of the clients with priority are refused, when the heap uses
+// usage of the limit. The lowest priority is refused as soon as the Refuse stage starts, the others
+// one after the other, evenly, until only the highest one is served at the limit.
+func (s *Shed) refused(usage float64, priority int) bool {
+	n := len(s.priorities)
+	i := sort.SearchInts(s.priorities, priority)
+	switch {
+	case i >= n-1:
+		return false
+	case i == 0:
+		return true
+	}
+	start := thresholds[pressure.Refuse]
+	return usage >= start+float64(i)*(1-start)/float64(n-2)
+}
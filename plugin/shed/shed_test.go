@@ -0,0 +1,121 @@
+package shed
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/pressure"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestStageOf(t *testing.T) {
+	tests := []struct {
+		usage    float64
+		cur      pressure.Stage
+		expected pressure.Stage
+	}{
+		{0.5, pressure.None, pressure.None},
+		{0.72, pressure.None, pressure.NoPrefetch},
+		{0.85, pressure.None, pressure.ShrinkCache},
+		{0.95, pressure.NoPrefetch, pressure.Refuse},
+		{0.87, pressure.Refuse, pressure.Refuse},
+		{0.84, pressure.Refuse, pressure.ShrinkCache},
+		{0.68, pressure.NoPrefetch, pressure.NoPrefetch},
+		{0.64, pressure.ShrinkCache, pressure.None},
+	}
+	for i, tc := range tests {
+		if s := stageOf(tc.usage, tc.cur); s != tc.expected {
+			t.Errorf("Test %d: expected stage %s, got %s", i, tc.expected, s)
+		}
+	}
+}
+
+func TestRefused(t *testing.T) {
+	s := &Shed{priorities: []int{0, 5, 10, 20}}
+	tests := []struct {
+		usage    float64
+		priority int
+		expected bool
+	}{
+		{0.86, 0, true},
+		{0.9, 5, false},
+		{0.95, 5, true},
+		{0.99, 10, false},
+		{1.0, 10, true},
+		{2.0, 20, false},
+	}
+	for i, tc := range tests {
+		if refused := s.refused(tc.usage, tc.priority); refused != tc.expected {
+			t.Errorf("Test %d: expected refused %t for priority %d at %.2f, got %t", i, tc.expected, tc.priority, tc.usage, refused)
+		}
+	}
+}
+
+func TestShed(t *testing.T) {
+	c := caddy.NewTestController("dns", "shed 1G {\nclass internal 10 10.240.0.1\n}")
+	s, err := parse(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Next = test.NextHandler(dns.RcodeSuccess, nil)
+
+	defer pressure.Set(pressure.None)
+	defer mon.store(0)
+
+	tests := []struct {
+		stage    pressure.Stage
+		remote   string
+		expected int
+	}{
+		{pressure.ShrinkCache, "10.240.0.2", dns.RcodeSuccess},
+		{pressure.Refuse, "10.240.0.1", dns.RcodeSuccess},
+		{pressure.Refuse, "10.240.0.2", dns.RcodeRefused},
+	}
+	for i, tc := range tests {
+		pressure.Set(tc.stage)
+		mon.store(0.95)
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		w := dnstest.NewRecorder(&remoteWriter{remote: tc.remote})
+		if _, err := s.ServeDNS(context.Background(), w, m); err != nil {
+			t.Fatalf("Test %d: %s", i, err)
+		}
+		if w.Rcode != tc.expected {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.expected, w.Rcode)
+		}
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	defer pressure.Set(pressure.None)
+
+	// Any heap is way over a limit of a byte.
+	s := &Shed{limit: 1, interval: 10 * time.Millisecond}
+	mon.add(s)
+	for i := 0; i < 100 && pressure.Current() != pressure.Refuse; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pressure.Current() != pressure.Refuse {
+		t.Errorf("Expected stage %s, got %s", pressure.Refuse, pressure.Current())
+	}
+	mon.remove(s)
+	if pressure.Current() != pressure.None {
+		t.Errorf("Expected stage %s after the monitoring stopped, got %s", pressure.None, pressure.Current())
+	}
+}
+
+type remoteWriter struct {
+	test.ResponseWriter
+	remote string
+}
+
+func (w *remoteWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP(w.remote), Port: 40212}
+}
@@ -0,0 +1,30 @@
+package sockfilter
+
+import (
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// droppedPackets reports the packets dropped by the socket filters, it reads the counters the
+// filters keep in the kernel.
+var droppedPackets = dropCollector{prometheus.NewDesc(
+	prometheus.BuildFQName(plugin.Namespace, "sockfilter", "dropped_packets_total"),
+	"Counter of UDP packets dropped by the socket filter, by reason.",
+	[]string{"server", "reason"}, nil,
+)}
+
+type dropCollector struct {
+	desc *prometheus.Desc
+}
+
+// Describe implements the prometheus.Collector interface.
+func (d dropCollector) Describe(ch chan<- *prometheus.Desc) { ch <- d.desc }
+
+// Collect implements the prometheus.Collector interface.
+func (d dropCollector) Collect(ch chan<- prometheus.Metric) {
+	dnsserver.UDPFilterDrops(func(server, reason string, n uint64) {
+		ch <- prometheus.MustNewConstMetric(d.desc, prometheus.CounterValue, float64(n), server, reason)
+	})
+}
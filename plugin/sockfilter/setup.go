@@ -0,0 +1,84 @@
+// Package sockfilter implements a plugin that drops unwanted UDP packets in the kernel.
+package sockfilter
+
+import (
+	"net"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("sockfilter", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	f, err := parse(c)
+	if err != nil {
+		return plugin.Error("sockfilter", err)
+	}
+	dnsserver.GetConfig(c).UDPFilter = f
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, droppedPackets)
+		return nil
+	})
+	return nil
+}
+
+func parse(c *caddy.Controller) (*dnsserver.UDPFilter, error) {
+	f := &dnsserver.UDPFilter{}
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		if err := deny(c, f, c.RemainingArgs()); err != nil {
+			return nil, err
+		}
+		for c.NextBlock() {
+			switch c.Val() {
+			case "deny":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				if err := deny(c, f, args); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return f, nil
+}
+
+// deny adds the networks in args to the ones denied by f. A network is either in CIDR notation, or
+// an address.
+func deny(c *caddy.Controller, f *dnsserver.UDPFilter, args []string) error {
+	for _, a := range args {
+		_, n, err := net.ParseCIDR(a)
+		if err != nil {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return c.Errf("invalid network: %s", a)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			n = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+		f.Deny = append(f.Deny, n)
+	}
+	return nil
+}
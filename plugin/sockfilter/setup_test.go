@@ -0,0 +1,56 @@
+package sockfilter
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  []string
+	}{
+		{`sockfilter`, false, nil},
+		{`sockfilter 192.0.2.0/24 2001:db8::/32`, false, []string{"192.0.2.0/24", "2001:db8::/32"}},
+		{`sockfilter 192.0.2.1 2001:db8::1`, false, []string{"192.0.2.1/32", "2001:db8::1/128"}},
+		{"sockfilter 192.0.2.0/24 {\ndeny 198.51.100.0/24 203.0.113.0/24\ndeny 10.0.0.0/8\n}", false, []string{"192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24", "10.0.0.0/8"}},
+		{`sockfilter example.org`, true, nil},
+		{`sockfilter 192.0.2.0/33`, true, nil},
+		{"sockfilter {\ndeny\n}", true, nil},
+		{"sockfilter {\nallow 192.0.2.0/24\n}", true, nil},
+		{"sockfilter\nsockfilter", true, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		f := dnsserver.GetConfig(c).UDPFilter
+		if f == nil {
+			t.Errorf("Test %d: expected a filter", i)
+			continue
+		}
+		if len(f.Deny) != len(tc.expected) {
+			t.Errorf("Test %d: expected %d denied networks, got %v", i, len(tc.expected), f.Deny)
+			continue
+		}
+		for j, n := range f.Deny {
+			if n.String() != tc.expected[j] {
+				t.Errorf("Test %d: expected denied network %s, got %s", i, tc.expected[j], n)
+			}
+		}
+	}
+}
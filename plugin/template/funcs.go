@@ -0,0 +1,115 @@
+package template
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"math/rand"
+	"net"
+	"time"
+	gotmpl "text/template"
+)
+
+// funcMap holds the functions available in templates in addition to Go's builtin ones.
+var funcMap = gotmpl.FuncMap{
+	"random":       random,
+	"pick":         pick,
+	"cidrContains": cidrContains,
+	"cidrHost":     cidrHost,
+	"unix":         func() int64 { return now().Unix() },
+	"ttlAlign":     ttlAlign,
+	"base64":       func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"base64Decode": base64Decode,
+	"hex":          func(s string) string { return hex.EncodeToString([]byte(s)) },
+	"hexDecode":    hexDecode,
+}
+
+// now is used for the time functions, it's a variable so tests can override it.
+var now = time.Now
+
+// random returns one of items at random.
+func random(items ...string) (string, error) {
+	if len(items) == 0 {
+		return "", fmt.Errorf("random needs at least one item")
+	}
+	return items[rand.Intn(len(items))], nil
+}
+
+// pick returns one of items, selected by the hash of key. The same key always selects the same item,
+// i.e. use the client's address as key to spread clients over a set of addresses.
+func pick(key string, items ...string) (string, error) {
+	if len(items) == 0 {
+		return "", fmt.Errorf("pick needs at least one item")
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return items[h.Sum32()%uint32(len(items))], nil
+}
+
+// cidrContains returns true if ip is contained in cidr.
+func cidrContains(cidr, ip string) (bool, error) {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false, err
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false, fmt.Errorf("invalid IP address: %q", ip)
+	}
+	return n.Contains(addr), nil
+}
+
+// cidrHost returns the address at offset in cidr, i.e. cidrHost "10.0.0.0/24" 5 returns 10.0.0.5.
+// A negative offset counts back from the end of the network.
+func cidrHost(cidr string, offset int) (string, error) {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := n.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+
+	off := big.NewInt(int64(offset))
+	if offset < 0 {
+		off.Add(off, size)
+	}
+	if off.Sign() < 0 || off.Cmp(size) >= 0 {
+		return "", fmt.Errorf("offset %d out of range for %s", offset, cidr)
+	}
+
+	ip := n.IP.To4()
+	if ip == nil {
+		ip = n.IP.To16()
+	}
+	x := new(big.Int).SetBytes(ip)
+	x.Add(x, off)
+	buf := x.Bytes()
+	res := make(net.IP, len(ip))
+	copy(res[len(res)-len(buf):], buf)
+	return res.String(), nil
+}
+
+// ttlAlign returns the number of seconds until the next multiple of period seconds since the epoch,
+// this makes all caches expire the record at the same time. The minimum value returned is 1.
+func ttlAlign(period int64) (int64, error) {
+	if period <= 0 {
+		return 0, fmt.Errorf("period must be positive: %d", period)
+	}
+	ttl := period - now().Unix()%period
+	if ttl < 1 {
+		ttl = 1
+	}
+	return ttl, nil
+}
+
+func base64Decode(s string) (string, error) {
+	buf, err := base64.StdEncoding.DecodeString(s)
+	return string(buf), err
+}
+
+func hexDecode(s string) (string, error) {
+	buf, err := hex.DecodeString(s)
+	return string(buf), err
+}
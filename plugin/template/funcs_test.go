@@ -0,0 +1,63 @@
+package template
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	gotmpl "text/template"
+)
+
+func TestFuncs(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Unix(1000, 0) }
+
+	tests := []struct {
+		tmpl      string
+		expected  string
+		shouldErr bool
+	}{
+		{`{{ random "a" }}`, "a", false},
+		{`{{ random }}`, "", true},
+		{`{{ pick "192.0.2.1" "a" }}`, "a", false},
+		{`{{ if eq (pick "key" "a" "b") (pick "key" "a" "b") }}same{{ end }}`, "same", false},
+		{`{{ pick "key" }}`, "", true},
+		{`{{ cidrContains "10.0.0.0/8" "10.1.2.3" }}`, "true", false},
+		{`{{ cidrContains "10.0.0.0/8" "192.0.2.1" }}`, "false", false},
+		{`{{ cidrContains "10.0.0.0/8" "nope" }}`, "", true},
+		{`{{ cidrHost "10.0.0.0/24" 5 }}`, "10.0.0.5", false},
+		{`{{ cidrHost "10.0.0.0/24" -1 }}`, "10.0.0.255", false},
+		{`{{ cidrHost "10.0.0.0/24" 256 }}`, "", true},
+		{`{{ cidrHost "2001:db8::/64" 16 }}`, "2001:db8::10", false},
+		{`{{ unix }}`, "1000", false},
+		{`{{ ttlAlign 300 }}`, "200", false},
+		{`{{ ttlAlign 0 }}`, "", true},
+		{`{{ base64 "hello" }}`, "aGVsbG8=", false},
+		{`{{ base64Decode "aGVsbG8=" }}`, "hello", false},
+		{`{{ base64Decode "!" }}`, "", true},
+		{`{{ hex "hi" }}`, "6869", false},
+		{`{{ hexDecode "6869" }}`, "hi", false},
+		{`{{ hexDecode "zz" }}`, "", true},
+	}
+
+	for i, tc := range tests {
+		tmpl, err := gotmpl.New("test").Funcs(funcMap).Parse(tc.tmpl)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to parse template: %s", i, err)
+		}
+		buf := &bytes.Buffer{}
+		err = tmpl.Execute(buf, nil)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got %s", i, err)
+			continue
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("Test %d: Expected %q, got %q", i, tc.expected, buf.String())
+		}
+	}
+}
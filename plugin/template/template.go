@@ -48,6 +48,7 @@ type templateData struct {
 	Type     string
 	Message  *dns.Msg
 	Question *dns.Question
+	Remote   string
 	md       map[string]metadata.Func
 }
 
@@ -169,6 +170,7 @@ func (t template) match(ctx context.Context, state request.Request, zone string)
 		data.Name = state.Name()
 		data.Question = &q
 		data.Message = state.Req
+		data.Remote = state.IP()
 		if q.Qclass != dns.ClassANY {
 			data.Class = dns.ClassToString[q.Qclass]
 		} else {
@@ -0,0 +1,42 @@
+package test
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+)
+
+// Chain builds a plugin chain in the same way the server does: the first link handles the query
+// first and gets the handler built from the links after it as next. A link typically sets the Next
+// field of a plugin:
+//
+//	h := test.Chain(
+//	    func(next test.Handler) test.Handler { return &cache.Cache{Next: next, ...} },
+//	    func(next test.Handler) test.Handler { return upstream },
+//	)
+func Chain(links ...func(next Handler) Handler) Handler {
+	var h Handler
+	for i := len(links) - 1; i >= 0; i-- {
+		h = links[i](h)
+	}
+	return h
+}
+
+// Exchange sends m through h and returns the response written, the rcode and the error returned by h.
+// The response is nil if h did not write one.
+func Exchange(ctx context.Context, h Handler, m *dns.Msg) (*dns.Msg, int, error) {
+	w := &recordingWriter{ResponseWriter: &ResponseWriter{}}
+	rcode, err := h.ServeDNS(ctx, w, m)
+	return w.msg, rcode, err
+}
+
+// recordingWriter records the message written.
+type recordingWriter struct {
+	*ResponseWriter
+	msg *dns.Msg
+}
+
+func (r *recordingWriter) WriteMsg(m *dns.Msg) error {
+	r.msg = m
+	return nil
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// prefixer is a plugin that rewrites the query name by prepending a label.
+type prefixer struct{ Next Handler }
+
+func (p prefixer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	r.Question[0].Name = "www." + r.Question[0].Name
+	return p.Next.ServeDNS(ctx, w, r)
+}
+
+func (p prefixer) Name() string { return "prefixer" }
+
+func TestChainUpstream(t *testing.T) {
+	u := NewUpstream()
+	u.Answer("www.example.org.", dns.TypeA, A("www.example.org. 300 IN A 127.0.0.1"))
+	u.Rcode("www.example.net.", dns.TypeA, dns.RcodeNameError)
+
+	h := Chain(
+		func(next Handler) Handler { return prefixer{Next: next} },
+		func(next Handler) Handler { return u },
+	)
+
+	tests := []struct {
+		qname   string
+		rcode   int
+		answers int
+	}{
+		{"example.org.", dns.RcodeSuccess, 1},
+		{"example.net.", dns.RcodeNameError, 0},
+		{"example.com.", dns.RcodeServerFailure, 0},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		resp, rcode, err := Exchange(context.TODO(), h, m)
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got %s", i, err)
+			continue
+		}
+		if rcode != tc.rcode || resp.Rcode != tc.rcode {
+			t.Errorf("Test %d: Expected rcode %d, got %d", i, tc.rcode, resp.Rcode)
+		}
+		if len(resp.Answer) != tc.answers {
+			t.Errorf("Test %d: Expected %d answers, got %d", i, tc.answers, len(resp.Answer))
+		}
+	}
+
+	q := u.Queries()
+	if len(q) != len(tests) {
+		t.Fatalf("Expected %d queries, got %d", len(tests), len(q))
+	}
+	if q[0].Question[0].Name != "www.example.org." {
+		t.Errorf("Expected query for www.example.org., got %s", q[0].Question[0].Name)
+	}
+}
+
+func TestClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewClock(start)
+	c.Advance(time.Minute)
+	if x := c.Now().Sub(start); x != time.Minute {
+		t.Errorf("Expected clock to advance %s, got %s", time.Minute, x)
+	}
+	c.Set(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Expected clock to be set to %s, got %s", start, c.Now())
+	}
+}
@@ -1,2 +1,7 @@
 // Package test contains helper functions for writing plugin tests.
+//
+// Besides the record and response helpers, it has an Upstream handler with scripted responses, a
+// Clock that only moves when told to and Chain to build a plugin chain, with Exchange to send a query
+// through it. These make it possible to test a plugin together with the plugins around it without
+// starting a server.
 package test
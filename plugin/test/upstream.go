@@ -0,0 +1,106 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// Upstream is a Handler that answers queries with scripted responses. It stands in for the next
+// plugin in a chain or, via DNSHandler, for an upstream server. All queries it receives are recorded.
+// Queries without a scripted response are answered with the rcode in Default, which is SERVFAIL
+// when created with NewUpstream.
+type Upstream struct {
+	Default int
+
+	mu        sync.Mutex
+	responses map[upstreamKey]func(r *dns.Msg) *dns.Msg
+	queries   []*dns.Msg
+}
+
+type upstreamKey struct {
+	name  string
+	qtype uint16
+}
+
+// NewUpstream returns a new Upstream without any responses.
+func NewUpstream() *Upstream {
+	return &Upstream{Default: dns.RcodeServerFailure, responses: make(map[upstreamKey]func(r *dns.Msg) *dns.Msg)}
+}
+
+// Respond scripts the response for queries for name and qtype: f is called with the query and returns
+// the response. If f returns nil no response is written, as if the upstream did not reply.
+func (u *Upstream) Respond(name string, qtype uint16, f func(r *dns.Msg) *dns.Msg) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.responses[upstreamKey{strings.ToLower(dns.Fqdn(name)), qtype}] = f
+}
+
+// Answer scripts a NOERROR response with rr in the answer section for queries for name and qtype.
+func (u *Upstream) Answer(name string, qtype uint16, rr ...dns.RR) {
+	u.Respond(name, qtype, func(r *dns.Msg) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = rr
+		return m
+	})
+}
+
+// Rcode scripts an empty response with rcode for queries for name and qtype.
+func (u *Upstream) Rcode(name string, qtype uint16, rcode int) {
+	u.Respond(name, qtype, func(r *dns.Msg) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		return m
+	})
+}
+
+// Queries returns the queries received so far.
+func (u *Upstream) Queries() []*dns.Msg {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]*dns.Msg{}, u.queries...)
+}
+
+// ServeDNS implements the Handler interface.
+func (u *Upstream) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := u.response(r)
+	if m == nil {
+		return dns.RcodeServerFailure, nil
+	}
+	w.WriteMsg(m)
+	return m.Rcode, nil
+}
+
+// Name implements the Handler interface.
+func (u *Upstream) Name() string { return "upstream" }
+
+// DNSHandler returns u as a dns.HandlerFunc, so it can be served from a real server, i.e. with
+// dnstest.NewServer.
+func (u *Upstream) DNSHandler() dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		if m := u.response(r); m != nil {
+			w.WriteMsg(m)
+		}
+	}
+}
+
+func (u *Upstream) response(r *dns.Msg) *dns.Msg {
+	u.mu.Lock()
+	u.queries = append(u.queries, r.Copy())
+	var f func(r *dns.Msg) *dns.Msg
+	if len(r.Question) > 0 {
+		f = u.responses[upstreamKey{strings.ToLower(r.Question[0].Name), r.Question[0].Qtype}]
+	}
+	rcode := u.Default
+	u.mu.Unlock()
+
+	if f != nil {
+		return f(r)
+	}
+	m := new(dns.Msg)
+	m.SetRcode(r, rcode)
+	return m
+}
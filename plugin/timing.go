@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Timing is the time a plugin spent handling a query, this includes the time spent in the plugins it
+// called.
+type Timing struct {
+	Plugin   string
+	Depth    int // number of plugins that called this one
+	Duration time.Duration
+	Rcode    int
+}
+
+// Timings collects the Timing of the plugins a query passes through.
+type Timings struct {
+	mu   sync.Mutex
+	list []Timing
+}
+
+type (
+	timingsKey struct{}
+	depthKey   struct{}
+)
+
+// WithTimings returns a context that makes NextOrFailure and ServeTimed record the timing of each
+// plugin called in t.
+func WithTimings(ctx context.Context, t *Timings) context.Context {
+	return context.WithValue(ctx, timingsKey{}, t)
+}
+
+// List returns the recorded timings in the order the plugins were called.
+func (t *Timings) List() []Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Timing(nil), t.list...)
+}
+
+// ServeTimed calls h.ServeDNS. When ctx has been created with WithTimings the time spent in h is recorded.
+func ServeTimed(ctx context.Context, h Handler, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	t, ok := ctx.Value(timingsKey{}).(*Timings)
+	if !ok {
+		return h.ServeDNS(ctx, w, r)
+	}
+	depth, _ := ctx.Value(depthKey{}).(int)
+
+	t.mu.Lock()
+	i := len(t.list)
+	t.list = append(t.list, Timing{Plugin: h.Name(), Depth: depth})
+	t.mu.Unlock()
+
+	start := time.Now()
+	rcode, err := h.ServeDNS(context.WithValue(ctx, depthKey{}, depth+1), w, r)
+
+	t.mu.Lock()
+	t.list[i].Duration = time.Since(start)
+	t.list[i].Rcode = rcode
+	t.mu.Unlock()
+	return rcode, err
+}
@@ -0,0 +1,32 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServeTimed(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		return dns.RcodeNameError, nil
+	})
+
+	// Without timings in the context nothing is recorded.
+	tm := &Timings{}
+	if rcode, _ := ServeTimed(context.TODO(), h, nil, nil); rcode != dns.RcodeNameError {
+		t.Errorf("Expected rcode %d, got %d", dns.RcodeNameError, rcode)
+	}
+	if len(tm.List()) != 0 {
+		t.Errorf("Expected no timings, got %v", tm.List())
+	}
+
+	ServeTimed(WithTimings(context.TODO(), tm), h, nil, nil)
+	list := tm.List()
+	if len(list) != 1 {
+		t.Fatalf("Expected 1 timing, got %d", len(list))
+	}
+	if list[0].Plugin != "handlerfunc" || list[0].Rcode != dns.RcodeNameError || list[0].Depth != 0 {
+		t.Errorf("Expected timing for handlerfunc, got %v", list[0])
+	}
+}
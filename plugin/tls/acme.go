@@ -0,0 +1,268 @@
+package tls
+
+import (
+	"crypto"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/go-acme/lego/certcrypto"
+	"github.com/go-acme/lego/certificate"
+	"github.com/go-acme/lego/challenge/dns01"
+	"github.com/go-acme/lego/lego"
+	"github.com/go-acme/lego/registration"
+)
+
+const (
+	// acmeRenewBefore is how long before it expires a certificate is renewed.
+	acmeRenewBefore = 30 * 24 * time.Hour
+	// acmeCheck is how often the expiry of the certificate is checked.
+	acmeCheck = 12 * time.Hour
+	// acmeRetry is the wait after a failure, it keeps us below the rate limit of failed validations
+	// of Let's Encrypt (5 per hour).
+	acmeRetry = 15 * time.Minute
+)
+
+// acme obtains the certificate of domains from an ACME CA (RFC 8555), answering the DNS-01 challenges
+// itself, and renews it before it expires. The certificate and its key are stored in path, to be
+// reused after a restart.
+type acme struct {
+	domains []string
+	email   string
+	ca      string
+	path    string
+
+	cert atomic.Value // *ctls.Certificate, unset until one is obtained
+
+	mu   sync.Mutex
+	stop chan struct{}
+}
+
+// acmes are the configured acme, the server blocks (and keys of a server block) that ask for the
+// same certificate share one.
+var acmes = struct {
+	sync.Mutex
+	m map[string]*acme
+}{m: make(map[string]*acme)}
+
+// getACME returns the acme of domains, stored in path. The certificate stored by a previous run, if
+// any, is loaded.
+func getACME(domains []string, email, ca, path string) *acme {
+	a := &acme{domains: domains, email: email, ca: ca, path: path}
+
+	acmes.Lock()
+	defer acmes.Unlock()
+	if b, ok := acmes.m[a.key()]; ok {
+		return b
+	}
+	if cert, err := ctls.LoadX509KeyPair(a.certFile(), a.keyFile()); err == nil {
+		a.cert.Store(&cert)
+	}
+	acmes.m[a.key()] = a
+	return a
+}
+
+func (a *acme) key() string {
+	return strings.Join(append([]string{a.path, a.email, a.ca}, a.domains...), " ")
+}
+
+// certFile and keyFile are named after the first domain, the star of a wildcard becomes "_".
+func (a *acme) certFile() string {
+	return filepath.Join(a.path, strings.Replace(a.domains[0], "*", "_", -1)+".crt")
+}
+
+func (a *acme) keyFile() string {
+	return filepath.Join(a.path, strings.Replace(a.domains[0], "*", "_", -1)+".key")
+}
+
+func (a *acme) accountKeyFile() string { return filepath.Join(a.path, "account.key") }
+
+// GetCertificate returns the certificate, it is meant for tls.Config.GetCertificate.
+func (a *acme) GetCertificate(*ctls.ClientHelloInfo) (*ctls.Certificate, error) {
+	cert, _ := a.cert.Load().(*ctls.Certificate)
+	if cert == nil {
+		return nil, fmt.Errorf("no certificate obtained for %s yet", a.domains[0])
+	}
+	return cert, nil
+}
+
+// Start obtains the certificate when there is none, and renews it, until Stop. The DNS-01 challenges
+// are answered by the servers, which start after this.
+func (a *acme) Start() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stop != nil {
+		return nil
+	}
+	acmes.Lock()
+	acmes.m[a.key()] = a
+	acmes.Unlock()
+
+	a.stop = make(chan struct{})
+	go a.run(a.stop)
+	return nil
+}
+
+// Stop stops renewing the certificate. A restart configures it again.
+func (a *acme) Stop() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stop == nil {
+		return nil
+	}
+	acmes.Lock()
+	delete(acmes.m, a.key())
+	acmes.Unlock()
+
+	close(a.stop)
+	a.stop = nil
+	return nil
+}
+
+func (a *acme) run(stop chan struct{}) {
+	for {
+		wait := acmeCheck
+		if a.renew() {
+			if err := a.obtain(); err != nil {
+				log.Errorf("Failed to obtain the certificate of %s: %s", strings.Join(a.domains, ", "), err)
+				wait = acmeRetry
+			} else {
+				log.Infof("Obtained the certificate of %s", strings.Join(a.domains, ", "))
+			}
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-stop:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// renew returns true when there is no certificate, when it expires within acmeRenewBefore, or when it
+// is not for the configured domains.
+func (a *acme) renew() bool {
+	cert, _ := a.cert.Load().(*ctls.Certificate)
+	if cert == nil {
+		return true
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return true
+	}
+	if time.Until(leaf.NotAfter) < acmeRenewBefore {
+		return true
+	}
+
+	names := append([]string{}, leaf.DNSNames...)
+	domains := append([]string{}, a.domains...)
+	sort.Strings(names)
+	sort.Strings(domains)
+	return strings.Join(names, " ") != strings.Join(domains, " ")
+}
+
+// obtain obtains a new certificate from the CA and stores it. The account is registered on the first
+// use, agreeing to the terms of service of the CA.
+func (a *acme) obtain() error {
+	key, err := a.accountKey()
+	if err != nil {
+		return err
+	}
+	user := &acmeUser{email: a.email, key: key}
+
+	config := lego.NewConfig(user)
+	config.CADirURL = a.ca
+	config.Certificate.KeyType = certcrypto.EC256
+	client, err := lego.NewClient(config)
+	if err != nil {
+		return err
+	}
+	if err := client.Challenge.SetDNS01Provider(dnsProvider{}); err != nil {
+		return err
+	}
+	if user.reg, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true}); err != nil {
+		return err
+	}
+
+	res, err := client.Certificate.Obtain(certificate.ObtainRequest{Domains: a.domains, Bundle: true})
+	if err != nil {
+		return err
+	}
+	cert, err := ctls.X509KeyPair(res.Certificate, res.PrivateKey)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(a.keyFile(), res.PrivateKey, 0600); err != nil {
+		return err
+	}
+	if err := writeFile(a.certFile(), res.Certificate, 0644); err != nil {
+		return err
+	}
+	a.cert.Store(&cert)
+	return nil
+}
+
+// accountKey returns the key of the ACME account, generated on the first use.
+func (a *acme) accountKey() (crypto.PrivateKey, error) {
+	if b, err := ioutil.ReadFile(a.accountKeyFile()); err == nil {
+		return certcrypto.ParsePEMPrivateKey(b)
+	}
+	key, err := certcrypto.GeneratePrivateKey(certcrypto.EC256)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(a.accountKeyFile(), certcrypto.PEMEncode(key), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// writeFile writes data to name atomically, not to leave a partial certificate behind.
+func writeFile(name string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// acmeUser is the ACME account.
+type acmeUser struct {
+	email string
+	key   crypto.PrivateKey
+	reg   *registration.Resource
+}
+
+func (u *acmeUser) GetEmail() string                        { return u.email }
+func (u *acmeUser) GetRegistration() *registration.Resource { return u.reg }
+func (u *acmeUser) GetPrivateKey() crypto.PrivateKey        { return u.key }
+
+// dnsProvider answers the DNS-01 challenges with the servers of this process.
+type dnsProvider struct{}
+
+func (dnsProvider) Present(domain, token, keyAuth string) error {
+	fqdn, value := dns01.GetRecord(domain, keyAuth)
+	dnsserver.AddACMEChallenge(fqdn, value)
+	return nil
+}
+
+func (dnsProvider) CleanUp(domain, token, keyAuth string) error {
+	fqdn, value := dns01.GetRecord(domain, keyAuth)
+	dnsserver.RemoveACMEChallenge(fqdn, value)
+	return nil
+}
@@ -0,0 +1,96 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+// newCert returns a self-signed certificate for domains that expires after d.
+func newCert(t *testing.T, d time.Duration, domains ...string) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     domains,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(d),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestACMERenew(t *testing.T) {
+	a := &acme{domains: []string{"example.org", "*.example.org"}}
+	if !a.renew() {
+		t.Error("Expected a renewal without certificate")
+	}
+	if _, err := a.GetCertificate(nil); err == nil {
+		t.Error("Expected an error without certificate")
+	}
+
+	tests := []struct {
+		expire  time.Duration
+		domains []string
+		renew   bool
+	}{
+		{90 * 24 * time.Hour, []string{"*.example.org", "example.org"}, false},
+		{10 * 24 * time.Hour, []string{"*.example.org", "example.org"}, true},
+		{90 * 24 * time.Hour, []string{"example.org"}, true},
+	}
+	for i, tc := range tests {
+		cert, err := ctls.X509KeyPair(newCert(t, tc.expire, tc.domains...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.cert.Store(&cert)
+		if renew := a.renew(); renew != tc.renew {
+			t.Errorf("Test %d: Expected renewal %t, got %t", i, tc.renew, renew)
+		}
+	}
+}
+
+func TestGetACME(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certPEM, keyPEM := newCert(t, 90*24*time.Hour, "*.example.org")
+	a := &acme{domains: []string{"*.example.org"}, path: dir}
+	if err := writeFile(a.certFile(), certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(a.keyFile(), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a = getACME([]string{"*.example.org"}, "", "https://ca.example.net", dir)
+	if _, err := a.GetCertificate(nil); err != nil {
+		t.Errorf("Expected the stored certificate to be loaded, got %s", err)
+	}
+	if b := getACME([]string{"*.example.org"}, "", "https://ca.example.net", dir); b != a {
+		t.Error("Expected the same certificate to be shared")
+	}
+	if b := getACME([]string{"*.example.org"}, "", "https://other.example.net", dir); b == a {
+		t.Error("Expected the certificate of another CA not to be shared")
+	}
+}
@@ -2,12 +2,16 @@ package tls
 
 import (
 	ctls "crypto/tls"
+	"crypto/x509"
+	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/coredns/coredns/plugin/pkg/tls"
 
 	"github.com/caddyserver/caddy"
+	"github.com/go-acme/lego/lego"
 )
 
 func init() {
@@ -17,6 +21,11 @@ func init() {
 	})
 }
 
+var log = clog.NewWithPlugin("tls")
+
+// defaultReload is how often the cert and key files are checked for changes.
+const defaultReload = time.Minute
+
 func setup(c *caddy.Controller) error {
 	err := parseTLS(c)
 	if err != nil {
@@ -51,12 +60,51 @@ func parseTLS(c *caddy.Controller) error {
 
 	for c.Next() {
 		args := c.RemainingArgs()
-		if len(args) < 2 || len(args) > 3 {
+		isACME := len(args) > 0 && args[0] == "acme"
+		if len(args) < 2 || (len(args) > 3 && !isACME) {
 			return plugin.Error("tls", c.ArgErr())
 		}
 		clientAuth := ctls.NoClientCert
+		reload := defaultReload
+		email, ca, path := "", lego.LEDirectoryProduction, "acme"
+		clientCA := ""
 		for c.NextBlock() {
 			switch c.Val() {
+			case "email", "ca", "path":
+				if !isACME {
+					return c.Errf("option '%s' only applies to tls acme", c.Val())
+				}
+				acmeArgs := c.RemainingArgs()
+				if len(acmeArgs) != 1 {
+					return c.ArgErr()
+				}
+				switch c.Val() {
+				case "email":
+					email = acmeArgs[0]
+				case "ca":
+					ca = acmeArgs[0]
+				case "path":
+					path = acmeArgs[0]
+				}
+			case "client_ca":
+				caArgs := c.RemainingArgs()
+				if len(caArgs) != 1 {
+					return c.ArgErr()
+				}
+				clientCA = caArgs[0]
+			case "reload":
+				if isACME {
+					return c.Errf("option 'reload' doesn't apply to tls acme")
+				}
+				reloadArgs := c.RemainingArgs()
+				if len(reloadArgs) != 1 {
+					return c.ArgErr()
+				}
+				d, err := time.ParseDuration(reloadArgs[0])
+				if err != nil || d < 0 {
+					return c.Errf("invalid reload duration '%s'", reloadArgs[0])
+				}
+				reload = d
 			case "client_auth":
 				authTypeArgs := c.RemainingArgs()
 				if len(authTypeArgs) != 1 {
@@ -80,6 +128,29 @@ func parseTLS(c *caddy.Controller) error {
 				return c.Errf("unknown option '%s'", c.Val())
 			}
 		}
+		var clientCAs *x509.CertPool
+		if clientCA != "" {
+			cc, err := tls.NewTLSClientConfig(clientCA)
+			if err != nil {
+				return err
+			}
+			clientCAs = cc.RootCAs
+		}
+
+		if isACME {
+			a := getACME(args[1:], email, ca, path)
+			tls := &ctls.Config{GetCertificate: a.GetCertificate, ClientAuth: clientAuth, ClientCAs: clientCAs}
+			setTLSDefaults(tls)
+
+			c.OnStartup(a.Start)
+			c.OnRestart(a.Stop)
+			c.OnFinalShutdown(a.Stop)
+			c.OnRestartFailed(a.Start)
+
+			config.TLSConfig = tls
+			continue
+		}
+
 		tls, err := tls.NewTLSConfigFromArgs(args...)
 		if err != nil {
 			return err
@@ -87,9 +158,26 @@ func parseTLS(c *caddy.Controller) error {
 		tls.ClientAuth = clientAuth
 		// NewTLSConfigFromArgs only sets RootCAs, so we need to let ClientCAs refer to it.
 		tls.ClientCAs = tls.RootCAs
+		if clientCAs != nil {
+			tls.ClientCAs = clientCAs
+		}
 
 		setTLSDefaults(tls)
 
+		if reload > 0 {
+			m, err := dnsserver.NewCertManager(args[0], args[1], reload)
+			if err != nil {
+				return err
+			}
+			tls.Certificates = nil
+			tls.GetCertificate = m.GetCertificate
+
+			c.OnStartup(m.Start)
+			c.OnRestart(m.Stop)
+			c.OnFinalShutdown(m.Stop)
+			c.OnRestartFailed(m.Start)
+		}
+
 		config.TLSConfig = tls
 	}
 	return nil
@@ -24,6 +24,14 @@ func TestTLS(t *testing.T) {
 		{"tls test_cert.pem test_key.pem test_ca.pem {\nclient_auth\n}", true, "", "Wrong argument"},
 		{"tls test_cert.pem test_key.pem test_ca.pem {\nclient_auth none bogus\n}", true, "", "Wrong argument"},
 		{"tls test_cert.pem test_key.pem test_ca.pem {\nclient_auth bogus\n}", true, "", "unknown authentication type"},
+		{"tls test_cert.pem test_key.pem test_ca.pem {\nreload\n}", true, "", "Wrong argument"},
+		{"tls test_cert.pem test_key.pem test_ca.pem {\nreload -1s\n}", true, "", "invalid reload duration"},
+		{"tls test_cert.pem test_key.pem test_ca.pem {\nemail admin@example.org\n}", true, "", "only applies to tls acme"},
+		{"tls acme", true, "", "Wrong argument"},
+		{"tls acme example.org {\nreload 1m\n}", true, "", "doesn't apply to tls acme"},
+		{"tls acme example.org {\nemail\n}", true, "", "Wrong argument"},
+		{"tls test_cert.pem test_key.pem {\nclient_ca\n}", true, "", "Wrong argument"},
+		{"tls test_cert.pem test_key.pem {\nclient_ca missing.pem\n}", true, "", "error reading"},
 	}
 
 	for i, test := range tests {
@@ -80,3 +88,68 @@ func TestTLSClientAuthentication(t *testing.T) {
 		}
 	}
 }
+
+func TestTLSReload(t *testing.T) {
+	tests := []struct {
+		option         string
+		expectedReload bool
+	}{
+		{"", true},
+		{"{\nreload 30s\n}", true},
+		{"{\nreload 0\n}", false},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", "tls test_cert.pem test_key.pem test_ca.pem "+test.option)
+		if err := setup(c); err != nil {
+			t.Errorf("Test %d: TLS config is unexpectedly rejected: %v", i, err)
+			continue
+		}
+		cfg := dnsserver.GetConfig(c).TLSConfig
+		if reload := cfg.GetCertificate != nil; reload != test.expectedReload {
+			t.Errorf("Test %d: Expected certificate reload %t, got %t", i, test.expectedReload, reload)
+		}
+		if static := len(cfg.Certificates) > 0; static == test.expectedReload {
+			t.Errorf("Test %d: Expected static certificates %t, got %t", i, !test.expectedReload, static)
+		}
+	}
+}
+
+func TestTLSACME(t *testing.T) {
+	c := caddy.NewTestController("dns", `tls acme example.org www.example.org example.net {
+		email admin@example.org
+		ca https://acme-staging-v02.api.letsencrypt.org/directory
+		path /var/lib/coredns/acme
+		client_auth request
+	}`)
+	if err := setup(c); err != nil {
+		t.Fatalf("TLS config is unexpectedly rejected: %v", err)
+	}
+	cfg := dnsserver.GetConfig(c).TLSConfig
+	if cfg.GetCertificate == nil || cfg.ClientAuth != tls.RequestClientCert {
+		t.Errorf("Expected a certificate from ACME and client certificates to be requested, got %v", cfg)
+	}
+
+	a := getACME([]string{"example.org", "www.example.org", "example.net"}, "admin@example.org",
+		"https://acme-staging-v02.api.letsencrypt.org/directory", "/var/lib/coredns/acme")
+	if a.certFile() != "/var/lib/coredns/acme/example.org.crt" {
+		t.Errorf("Expected the certificate to be stored in /var/lib/coredns/acme/example.org.crt, got %s", a.certFile())
+	}
+}
+
+func TestTLSClientCA(t *testing.T) {
+	for i, input := range []string{
+		"tls test_cert.pem test_key.pem {\nclient_auth require_and_verify\nclient_ca test_ca.pem\n}",
+		"tls acme example.org {\nclient_auth require_and_verify\nclient_ca test_ca.pem\n}",
+	} {
+		c := caddy.NewTestController("dns", input)
+		if err := setup(c); err != nil {
+			t.Errorf("Test %d: TLS config is unexpectedly rejected: %v", i, err)
+			continue
+		}
+		cfg := dnsserver.GetConfig(c).TLSConfig
+		if cfg.ClientCAs == nil || cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+			t.Errorf("Test %d: Expected client certificates to be verified with test_ca.pem", i)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package tsig
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+var log = clog.NewWithPlugin("tsig")
+
+func init() {
+	caddy.RegisterPlugin("tsig", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	t, secrets, err := parseTSIG(c)
+	if err != nil {
+		return plugin.Error("tsig", err)
+	}
+
+	config := dnsserver.GetConfig(c)
+	for name, secret := range secrets {
+		if err := config.AddTsigKey(name, secret); err != nil {
+			return plugin.Error("tsig", err)
+		}
+	}
+
+	config.AddPlugin(func(next plugin.Handler) plugin.Handler {
+		t.Next = next
+		return t
+	})
+
+	return nil
+}
+
+func parseTSIG(c *caddy.Controller) (*TSIGServer, map[string]string, error) {
+	t := &TSIGServer{types: make(map[uint16]struct{})}
+	secrets := make(map[string]string)
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, nil, plugin.ErrOnce
+		}
+		i++
+
+		t.Zones = make([]string, len(c.ServerBlockKeys))
+		copy(t.Zones, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			t.Zones = args
+		}
+		for i := range t.Zones {
+			t.Zones[i] = plugin.Host(t.Zones[i]).Normalize()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "secret":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, nil, c.ArgErr()
+				}
+				if err := addSecret(secrets, args[0], args[1]); err != nil {
+					return nil, nil, c.Err(err.Error())
+				}
+			case "secrets":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, nil, c.ArgErr()
+				}
+				name := args[0]
+				if root := dnsserver.GetConfig(c).Root; !filepath.IsAbs(name) && root != "" {
+					name = filepath.Join(root, name)
+				}
+				f, err := os.Open(name)
+				if err != nil {
+					return nil, nil, err
+				}
+				err = parseKeyFile(f, secrets)
+				f.Close()
+				if err != nil {
+					return nil, nil, fmt.Errorf("key file %q: %s", name, err)
+				}
+			case "require":
+				args := c.RemainingArgs()
+				t.all, t.types = false, make(map[uint16]struct{})
+				if len(args) == 0 {
+					t.all = true
+					continue
+				}
+				for _, arg := range args {
+					switch strings.ToLower(arg) {
+					case "all":
+						t.all = true
+					case "none":
+					default:
+						qtype, ok := dns.StringToType[strings.ToUpper(arg)]
+						if !ok {
+							return nil, nil, c.Errf("unknown query type '%s'", arg)
+						}
+						t.types[qtype] = struct{}{}
+					}
+				}
+			default:
+				return nil, nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+
+	if len(secrets) == 0 {
+		return nil, nil, fmt.Errorf("no secrets defined")
+	}
+	return t, secrets, nil
+}
+
+// addSecret adds the key name with the base64 encoded secret to secrets.
+func addSecret(secrets map[string]string, name, secret string) error {
+	if _, err := base64.StdEncoding.DecodeString(secret); err != nil {
+		return fmt.Errorf("invalid secret of key %q: %s", name, err)
+	}
+	name = strings.ToLower(dns.Fqdn(name))
+	if s, ok := secrets[name]; ok && s != secret {
+		return fmt.Errorf("key %q is defined with different secrets", name)
+	}
+	secrets[name] = secret
+	return nil
+}
+
+var (
+	keyRe    = regexp.MustCompile(`key\s+"?([^"\s{]+)"?\s*{([^}]*)}\s*;`)
+	secretRe = regexp.MustCompile(`secret\s+"?([^"\s;]+)"?\s*;`)
+)
+
+// parseKeyFile adds the keys in r, in the format of a BIND key file, to secrets:
+//
+//	key "name" {
+//		algorithm hmac-sha256;
+//		secret "base64 encoded secret";
+//	};
+//
+// The algorithm is not used, the queries are verified with the algorithm in their TSIG.
+func parseKeyFile(r io.Reader, secrets map[string]string) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	keys := keyRe.FindAllStringSubmatch(string(buf), -1)
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys found")
+	}
+	for _, k := range keys {
+		s := secretRe.FindStringSubmatch(k[2])
+		if s == nil {
+			return fmt.Errorf("key %q has no secret", k[1])
+		}
+		if err := addSecret(secrets, k[1], s[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package tsig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestSetupTSIG(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		zones     []string
+		secrets   int
+		all       bool
+		types     []uint16
+	}{
+		{`tsig {
+			secret xfr.example.org. c2VjcmV0
+		}`, false, []string{"example.org."}, 1, false, nil},
+		{`tsig example.net {
+			secret xfr.example.org c2VjcmV0
+			secret other.example.org. b3RoZXI=
+			require
+		}`, false, []string{"example.net."}, 2, true, nil},
+		{`tsig {
+			secret xfr.example.org. c2VjcmV0
+			require axfr IXFR
+		}`, false, []string{"example.org."}, 1, false, []uint16{dns.TypeAXFR, dns.TypeIXFR}},
+		{`tsig {
+			secret xfr.example.org. c2VjcmV0
+			require none
+		}`, false, []string{"example.org."}, 1, false, nil},
+		// errors
+		{`tsig`, true, nil, 0, false, nil},
+		{`tsig {
+			secret xfr.example.org.
+		}`, true, nil, 0, false, nil},
+		{`tsig {
+			secret xfr.example.org. not-base64
+		}`, true, nil, 0, false, nil},
+		{`tsig {
+			secret xfr.example.org. c2VjcmV0
+			secret XFR.example.org. b3RoZXI=
+		}`, true, nil, 0, false, nil},
+		{`tsig {
+			secret xfr.example.org. c2VjcmV0
+			require BOGUS
+		}`, true, nil, 0, false, nil},
+		{`tsig {
+			secrets /does/not/exist
+		}`, true, nil, 0, false, nil},
+		{`tsig {
+			bogus
+		}`, true, nil, 0, false, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		c.ServerBlockKeys = []string{"example.org."}
+		ts, secrets, err := parseTSIG(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if strings.Join(ts.Zones, " ") != strings.Join(tc.zones, " ") {
+			t.Errorf("Test %d: expected zones %v, got %v", i, tc.zones, ts.Zones)
+		}
+		if len(secrets) != tc.secrets {
+			t.Errorf("Test %d: expected %d secrets, got %d", i, tc.secrets, len(secrets))
+		}
+		if ts.all != tc.all {
+			t.Errorf("Test %d: expected all to be %t, got %t", i, tc.all, ts.all)
+		}
+		if len(ts.types) != len(tc.types) {
+			t.Errorf("Test %d: expected %d required types, got %d", i, len(tc.types), len(ts.types))
+		}
+		for _, qtype := range tc.types {
+			if !ts.required(qtype) {
+				t.Errorf("Test %d: expected %s to be required", i, dns.TypeToString[qtype])
+			}
+		}
+	}
+}
+
+func TestParseKeyFile(t *testing.T) {
+	const keys = `key "xfr.example.org." {
+	algorithm hmac-sha256;
+	secret "c2VjcmV0";
+};
+
+key other.example.org {
+	secret b3RoZXI=;
+	algorithm hmac-sha512;
+};
+`
+	secrets := make(map[string]string)
+	if err := parseKeyFile(strings.NewReader(keys), secrets); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if secrets["xfr.example.org."] != "c2VjcmV0" || secrets["other.example.org."] != "b3RoZXI=" {
+		t.Errorf("Expected both keys, got %v", secrets)
+	}
+
+	if err := parseKeyFile(strings.NewReader(`key "x." { algorithm hmac-sha256; };`), secrets); err == nil {
+		t.Error("Expected an error for a key without a secret, got none")
+	}
+	if err := parseKeyFile(strings.NewReader(`options { };`), secrets); err == nil {
+		t.Error("Expected an error for a file without keys, got none")
+	}
+}
@@ -0,0 +1,121 @@
+// Package tsig implements a plugin that verifies the TSIG of queries, and signs the responses.
+package tsig
+
+import (
+	"context"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// TSIGServer verifies the TSIG of the queries for Zones, and refuses unsigned queries of the
+// required types.
+type TSIGServer struct {
+	Zones []string
+	all   bool                // require a TSIG for all query types
+	types map[uint16]struct{} // require a TSIG for these query types
+	Next  plugin.Handler
+}
+
+// Name implements plugin.Handler.
+func (t TSIGServer) Name() string { return "tsig" }
+
+// ServeDNS implements plugin.Handler.
+func (t TSIGServer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if z := plugin.Zones(t.Zones).Matches(state.Name()); z == "" {
+		return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
+	}
+
+	tsig := r.IsTsig()
+	if tsig == nil {
+		if t.required(state.QType()) {
+			log.Debugf("Refusing the unsigned %s query for %q", state.Type(), state.Name())
+			return dns.RcodeRefused, nil
+		}
+		return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
+	}
+
+	if err := w.TsigStatus(); err != nil {
+		log.Debugf("Failed to verify the TSIG of the query for %q with key %q: %s", state.Name(), tsig.Hdr.Name, err)
+		return writeNotAuth(w, r, tsig, err)
+	}
+
+	return plugin.NextOrFailure(t.Name(), t.Next, ctx, &signWriter{ResponseWriter: w, tsig: tsig}, r)
+}
+
+func (t TSIGServer) required(qtype uint16) bool {
+	if t.all {
+		return true
+	}
+	_, ok := t.types[qtype]
+	return ok
+}
+
+// writeNotAuth writes a NOTAUTH response for the query r that failed to verify with err. Its TSIG
+// carries the TSIG error (RFC 8945, section 5.2) and no MAC: we can't sign with a key that failed.
+func writeNotAuth(w dns.ResponseWriter, r *dns.Msg, tsig *dns.TSIG, err error) (int, error) {
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeNotAuth)
+
+	code := uint16(dns.RcodeBadSig)
+	switch err {
+	case dns.ErrSecret:
+		code = dns.RcodeBadKey
+	case dns.ErrTime:
+		code = dns.RcodeBadTime
+	}
+	t := &dns.TSIG{
+		Hdr:        dns.RR_Header{Name: tsig.Hdr.Name, Rrtype: dns.TypeTSIG, Class: dns.ClassANY},
+		Algorithm:  tsig.Algorithm,
+		TimeSigned: tsig.TimeSigned,
+		Fudge:      tsig.Fudge,
+		OrigId:     r.Id,
+		Error:      code,
+	}
+	if code == dns.RcodeBadTime {
+		// Tell the client our time, so it can sign with it.
+		t.OtherData = otherTime(time.Now())
+		t.OtherLen = uint16(len(t.OtherData) / 2)
+	}
+	m.Extra = append(m.Extra, t)
+
+	// Write the packed message, WriteMsg would try to sign it.
+	buf, err := m.Pack()
+	if err != nil {
+		return dns.RcodeServerFailure, plugin.Error("tsig", err)
+	}
+	if _, err := w.Write(buf); err != nil {
+		return dns.RcodeServerFailure, plugin.Error("tsig", err)
+	}
+	return dns.RcodeNotAuth, nil
+}
+
+// otherTime returns the 48 bit time t in hex, as the other data of a TSIG.
+func otherTime(t time.Time) string {
+	const hex = "0123456789abcdef"
+	u := uint64(t.Unix())
+	b := make([]byte, 12)
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = hex[u&0xf]
+		u >>= 4
+	}
+	return string(b)
+}
+
+// signWriter adds a TSIG to the responses, the server signs them with the key of the query.
+type signWriter struct {
+	dns.ResponseWriter
+	tsig *dns.TSIG
+}
+
+// WriteMsg implements dns.ResponseWriter.
+func (w *signWriter) WriteMsg(m *dns.Msg) error {
+	if m.IsTsig() == nil {
+		m.SetTsig(w.tsig.Hdr.Name, w.tsig.Algorithm, w.tsig.Fudge, time.Now().Unix())
+	}
+	return w.ResponseWriter.WriteMsg(m)
+}
@@ -0,0 +1,94 @@
+package tsig
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// tsigWriter is a test.ResponseWriter with a TSIG status, that records the response.
+type tsigWriter struct {
+	test.ResponseWriter
+	status error
+	msg    *dns.Msg
+}
+
+func (w *tsigWriter) TsigStatus() error { return w.status }
+
+func (w *tsigWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+
+func (w *tsigWriter) Write(buf []byte) (int, error) {
+	w.msg = new(dns.Msg)
+	return len(buf), w.msg.Unpack(buf)
+}
+
+func reply(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+func TestTSIG(t *testing.T) {
+	ts := TSIGServer{
+		Zones: []string{"example.org."},
+		types: map[uint16]struct{}{dns.TypeAXFR: {}},
+		Next:  test.HandlerFunc(reply),
+	}
+
+	tests := []struct {
+		qname  string
+		qtype  uint16
+		signed bool
+		status error
+		rcode  int
+		tsig   uint16 // the error in the TSIG of the response, when signed
+	}{
+		{qname: "example.org.", qtype: dns.TypeA, rcode: dns.RcodeSuccess},
+		{qname: "example.org.", qtype: dns.TypeAXFR, rcode: dns.RcodeRefused},
+		{qname: "example.net.", qtype: dns.TypeAXFR, rcode: dns.RcodeSuccess},
+		{qname: "example.org.", qtype: dns.TypeAXFR, signed: true, rcode: dns.RcodeSuccess},
+		{qname: "example.org.", qtype: dns.TypeA, signed: true, rcode: dns.RcodeSuccess},
+		{qname: "example.org.", qtype: dns.TypeA, signed: true, status: dns.ErrSecret, rcode: dns.RcodeNotAuth, tsig: dns.RcodeBadKey},
+		{qname: "example.org.", qtype: dns.TypeA, signed: true, status: dns.ErrTime, rcode: dns.RcodeNotAuth, tsig: dns.RcodeBadTime},
+		{qname: "example.org.", qtype: dns.TypeA, signed: true, status: dns.ErrSig, rcode: dns.RcodeNotAuth, tsig: dns.RcodeBadSig},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		if tc.signed {
+			m.SetTsig("xfr.example.org.", dns.HmacSHA256, 300, time.Now().Unix())
+		}
+		w := &tsigWriter{status: tc.status}
+		rcode, err := ts.ServeDNS(context.TODO(), w, m)
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.rcode, rcode)
+		}
+		if w.msg == nil {
+			if tc.rcode != dns.RcodeRefused {
+				t.Errorf("Test %d: expected a response, got none", i)
+			}
+			continue
+		}
+		tsig := w.msg.IsTsig()
+		if tc.signed != (tsig != nil) {
+			t.Errorf("Test %d: expected the response to be signed: %t", i, tc.signed)
+			continue
+		}
+		if tsig != nil && tsig.Error != tc.tsig {
+			t.Errorf("Test %d: expected TSIG error %d, got %d", i, tc.tsig, tsig.Error)
+		}
+	}
+}
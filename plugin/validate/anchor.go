@@ -0,0 +1,324 @@
+package validate
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// anchors are the trust anchors, by zone. They follow the key rollovers of their zones as in
+// RFC 5011, by watching the DNSKEY records of the zones when they are validated.
+type anchors struct {
+	mu    sync.Mutex
+	zones map[string]*anchor
+
+	file     string        // where the anchors are saved, when they change
+	holddown time.Duration // how long a new key is seen before it is trusted
+
+	now func() time.Time
+}
+
+// anchor is the trust anchors of a zone.
+type anchor struct {
+	ds      []*dns.DS
+	keys    []*dns.DNSKEY
+	pending map[string]*pending // the new keys not trusted yet, by keyID
+}
+
+type pending struct {
+	key   *dns.DNSKEY
+	since time.Time
+}
+
+func newAnchors() *anchors {
+	return &anchors{zones: make(map[string]*anchor), holddown: defaultHolddown, now: time.Now}
+}
+
+// add adds the DS or DNSKEY record rr as a trust anchor.
+func (a *anchors) add(rr dns.RR) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	an := a.zone(rr.Header().Name)
+	switch rr := rr.(type) {
+	case *dns.DS:
+		an.ds = append(an.ds, rr)
+	case *dns.DNSKEY:
+		an.keys = append(an.keys, rr)
+	default:
+		return fmt.Errorf("trust anchor is not a DS or DNSKEY record: %s", rr)
+	}
+	return nil
+}
+
+// zone returns the anchor of name, adding it if needed, a must be locked.
+func (a *anchors) zone(name string) *anchor {
+	name = strings.ToLower(dns.Fqdn(name))
+	an, ok := a.zones[name]
+	if !ok {
+		an = &anchor{pending: make(map[string]*pending)}
+		a.zones[name] = an
+	}
+	return an
+}
+
+// closest returns the closest zone at or above name with a trust anchor, or an empty string if
+// there is none.
+func (a *anchors) closest(name string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		if _, ok := a.zones[name[off:]]; ok {
+			return name[off:]
+		}
+	}
+	if _, ok := a.zones["."]; ok {
+		return "."
+	}
+	return ""
+}
+
+// trusted returns the keys that are trust anchors of the zone name.
+func (a *anchors) trusted(name string, keys []*dns.DNSKEY) []*dns.DNSKEY {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	an, ok := a.zones[name]
+	if !ok {
+		return nil
+	}
+	var t []*dns.DNSKEY
+	for _, k := range keys {
+		if k.Flags&dns.REVOKE == 0 && an.has(k) {
+			t = append(t, k)
+		}
+	}
+	return t
+}
+
+// observe follows the key rollovers of the zone name with s, its validated DNSKEY records. A new
+// key signing keys is trusted after it is seen for the holddown time, and a revoked key is no
+// longer trusted.
+func (a *anchors) observe(name string, s *rrset) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	an, ok := a.zones[name]
+	if !ok {
+		return
+	}
+	now := a.now()
+	changed := false
+	seen := make(map[string]bool)
+	for _, k := range dnskeys(s) {
+		if k.Flags&dns.SEP == 0 {
+			continue
+		}
+		if k.Flags&dns.REVOKE != 0 {
+			// Only the key itself revokes it.
+			if selfSigned(k, s, now) && an.remove(k) {
+				log.Infof("Trust anchor %s with key tag %d is revoked", name, k.KeyTag())
+				changed = true
+			}
+			continue
+		}
+		if an.has(k) {
+			continue
+		}
+		id := keyID(k)
+		seen[id] = true
+		p, ok := an.pending[id]
+		switch {
+		case !ok:
+			an.pending[id] = &pending{key: k, since: now}
+			log.Infof("New key of %s with key tag %d, trusted after %s", name, k.KeyTag(), a.holddown)
+			changed = true
+		case now.Sub(p.since) >= a.holddown:
+			an.keys = append(an.keys, k)
+			delete(an.pending, id)
+			log.Infof("Trust anchor %s with key tag %d is added", name, k.KeyTag())
+			changed = true
+		}
+	}
+	// The new keys that disappeared start over.
+	for id := range an.pending {
+		if !seen[id] {
+			delete(an.pending, id)
+			changed = true
+		}
+	}
+
+	if changed && a.file != "" {
+		if err := a.save(); err != nil {
+			log.Errorf("Failed to save the trust anchors to %s: %s", a.file, err)
+		}
+	}
+}
+
+// has returns true when the key k is a trust anchor of an.
+func (an *anchor) has(k *dns.DNSKEY) bool {
+	for _, t := range an.keys {
+		if t.Flags == k.Flags && t.Algorithm == k.Algorithm && t.PublicKey == k.PublicKey {
+			return true
+		}
+	}
+	for _, d := range an.ds {
+		if matches(k, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// remove removes the trust anchors of the revoked key k, and returns true if there were any.
+func (an *anchor) remove(k *dns.DNSKEY) bool {
+	k1 := *k
+	k1.Flags &^= dns.REVOKE
+
+	removed := false
+	keys := an.keys[:0]
+	for _, t := range an.keys {
+		if t.Flags == k1.Flags && t.Algorithm == k1.Algorithm && t.PublicKey == k1.PublicKey {
+			removed = true
+			continue
+		}
+		keys = append(keys, t)
+	}
+	an.keys = keys
+	ds := an.ds[:0]
+	for _, d := range an.ds {
+		if matches(&k1, d) {
+			removed = true
+			continue
+		}
+		ds = append(ds, d)
+	}
+	an.ds = ds
+	return removed
+}
+
+// save writes the anchors to a.file, replacing it at once so it is never left half written. The
+// trusted anchors are records, the pending keys are comments. a must be locked.
+func (a *anchors) save() error {
+	dir, base := filepath.Split(a.file)
+	f, err := ioutil.TempFile(dir, base)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails after the rename
+
+	names := make([]string, 0, len(a.zones))
+	for name := range a.zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := bufio.NewWriter(f)
+	for _, name := range names {
+		an := a.zones[name]
+		for _, d := range an.ds {
+			fmt.Fprintln(w, d)
+		}
+		for _, k := range an.keys {
+			fmt.Fprintln(w, k)
+		}
+		for _, p := range an.pending {
+			fmt.Fprintf(w, "%s %s %s\n", pendingPrefix, p.since.UTC().Format(time.RFC3339), p.key)
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), a.file)
+}
+
+// load adds the anchors of a.file. A missing file is not an error: there is nothing to load.
+func (a *anchors) load() error {
+	f, err := os.Open(a.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, pendingPrefix+" ") {
+			fields := strings.SplitN(strings.TrimPrefix(line, pendingPrefix+" "), " ", 2)
+			if len(fields) != 2 {
+				return fmt.Errorf("%s:%d: bad pending key", a.file, n)
+			}
+			since, err := time.Parse(time.RFC3339, fields[0])
+			if err != nil {
+				return fmt.Errorf("%s:%d: %s", a.file, n, err)
+			}
+			rr, err := dns.NewRR(fields[1])
+			if err != nil {
+				return fmt.Errorf("%s:%d: %s", a.file, n, err)
+			}
+			k, ok := rr.(*dns.DNSKEY)
+			if !ok {
+				return fmt.Errorf("%s:%d: pending key is not a DNSKEY record", a.file, n)
+			}
+			a.mu.Lock()
+			a.zone(k.Hdr.Name).pending[keyID(k)] = &pending{key: k, since: since}
+			a.mu.Unlock()
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		rr, err := dns.NewRR(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", a.file, n, err)
+		}
+		if err := a.add(rr); err != nil {
+			return fmt.Errorf("%s:%d: %s", a.file, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// selfSigned returns true when the key k signs the DNSKEY records s.
+func selfSigned(k *dns.DNSKEY, s *rrset, now time.Time) bool {
+	for _, sig := range s.sigs {
+		if sig.KeyTag == k.KeyTag() && sig.Algorithm == k.Algorithm && sig.ValidityPeriod(now) && sig.Verify(k, s.rrs) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func keyID(k *dns.DNSKEY) string {
+	return fmt.Sprintf("%d %d %s", k.Flags, k.Algorithm, k.PublicKey)
+}
+
+// rootAnchors are the trust anchors of the root zone, the keys of 2017 and 2024.
+var rootAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+const (
+	defaultHolddown = 30 * 24 * time.Hour // see RFC 5011 2.4.1
+	pendingPrefix   = "; pending"
+)
@@ -0,0 +1,106 @@
+package validate
+
+import (
+	"crypto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestAnchorsClosest(t *testing.T) {
+	a := newAnchors()
+	a.add(&dns.DS{Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeDS}})
+
+	if c := a.closest("www.example.org."); c != "example.org." {
+		t.Errorf("Expected example.org., got %q", c)
+	}
+	if c := a.closest("example.net."); c != "" {
+		t.Errorf("Expected no trust anchor, got %q", c)
+	}
+	a.add(&dns.DS{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeDS}})
+	if c := a.closest("example.net."); c != "." {
+		t.Errorf("Expected the root, got %q", c)
+	}
+}
+
+func TestAnchorsRollover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	z := &testZone{name: "."}
+	old, oldPriv := newTestKey(t, ".")
+	key, priv := newTestKey(t, ".")
+
+	a := newAnchors()
+	a.file = filepath.Join(dir, "anchors")
+	a.now = func() time.Time { return now }
+	a.add(old.ToDS(dns.SHA256))
+
+	// keys returns the DNSKEY records of the root, signed by each key.
+	keys := func(keys []*dns.DNSKEY, privs ...crypto.Signer) *rrset {
+		s := &rrset{name: ".", rtype: dns.TypeDNSKEY}
+		for _, k := range keys {
+			s.rrs = append(s.rrs, k)
+		}
+		for i, k := range keys {
+			s.sigs = append(s.sigs, z.signSet(t, s.rrs, now.Add(-time.Hour), now.Add(time.Hour), k, privs[i]))
+		}
+		return s
+	}
+
+	// The new key is pending, until it is seen for the holddown time.
+	a.observe(".", keys([]*dns.DNSKEY{old, key}, oldPriv, priv))
+	if len(a.trusted(".", []*dns.DNSKEY{key})) != 0 {
+		t.Fatalf("Expected the new key not to be trusted yet")
+	}
+	now = now.Add(a.holddown / 2)
+	a.observe(".", keys([]*dns.DNSKEY{old, key}, oldPriv, priv))
+	if len(a.trusted(".", []*dns.DNSKEY{key})) != 0 {
+		t.Fatalf("Expected the new key not to be trusted before the holddown time")
+	}
+
+	// The pending key survives a restart.
+	b := newAnchors()
+	b.file = a.file
+	if err := b.load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.zones["."].pending) != 1 || len(b.trusted(".", []*dns.DNSKEY{old})) != 1 {
+		t.Fatalf("Expected the trust anchor and a pending key after loading, got %v", b.zones["."])
+	}
+
+	now = now.Add(a.holddown)
+	a.observe(".", keys([]*dns.DNSKEY{old, key}, oldPriv, priv))
+	if len(a.trusted(".", []*dns.DNSKEY{key})) != 1 {
+		t.Fatalf("Expected the new key to be trusted after the holddown time")
+	}
+
+	// Revoking the old key removes its trust anchor, it must sign the keys itself.
+	revoked := *old
+	revoked.Flags |= dns.REVOKE
+	a.observe(".", keys([]*dns.DNSKEY{&revoked, key}, priv, priv))
+	if len(a.trusted(".", []*dns.DNSKEY{old})) != 1 {
+		t.Fatalf("Expected the old key to be trusted when another key revokes it")
+	}
+	a.observe(".", keys([]*dns.DNSKEY{&revoked, key}, oldPriv, priv))
+	if len(a.trusted(".", []*dns.DNSKEY{old})) != 0 {
+		t.Fatalf("Expected the revoked key not to be trusted")
+	}
+
+	b = newAnchors()
+	b.file = a.file
+	if err := b.load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.trusted(".", []*dns.DNSKEY{old, key})) != 1 || len(b.zones["."].pending) != 0 {
+		t.Errorf("Expected only the new key to be trusted after loading, got %v", b.zones["."])
+	}
+}
@@ -0,0 +1,286 @@
+package validate
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/ede"
+
+	"github.com/miekg/dns"
+)
+
+// The denials of existence have been verified before these functions check what they prove. They
+// return ok false when the proof makes the answer insecure, and an error when there is no proof.
+
+// nodata checks the proof in ns that name has no records of qtype. It returns the types of name when
+// the proof has them, which tell whether name is a delegation.
+func nodata(ns []dns.RR, name string, qtype uint16) (types []uint16, ok bool, err error) {
+	if n3 := nsec3s(ns); len(n3) > 0 {
+		return nodata3(n3, name, qtype)
+	}
+	nsec := nsecs(ns)
+	for _, n := range nsec {
+		if strings.EqualFold(n.Hdr.Name, name) {
+			if err := checkTypes(n.TypeBitMap, name, qtype); err != nil {
+				return nil, false, err
+			}
+			return n.TypeBitMap, true, nil
+		}
+	}
+	for _, n := range nsec {
+		if !covers(n, name) {
+			continue
+		}
+		// An empty non-terminal: the next name is below name.
+		if dns.IsSubDomain(name, n.NextDomain) {
+			return nil, true, nil
+		}
+		// Or the records of qtype don't exist at the wildcard expanded to name.
+		wc := "*." + closestEncloser(n, name)
+		for _, w := range nsec {
+			if strings.EqualFold(w.Hdr.Name, wc) && checkTypes(w.TypeBitMap, wc, qtype) == nil {
+				return nil, true, nil
+			}
+		}
+	}
+	return nil, false, failuref(ede.NSECMissing, "no NSEC proof that %s %s doesn't exist", name, dns.Type(qtype))
+}
+
+// nxdomain checks the proof in ns that name doesn't exist.
+func nxdomain(ns []dns.RR, name string) (bool, error) {
+	if n3 := nsec3s(ns); len(n3) > 0 {
+		return nxdomain3(n3, name)
+	}
+	nsec := nsecs(ns)
+	for _, n := range nsec {
+		if !covers(n, name) || dns.IsSubDomain(name, n.NextDomain) {
+			continue
+		}
+		// Nor does the wildcard that would have been expanded to name.
+		wc := "*." + closestEncloser(n, name)
+		for _, w := range nsec {
+			if covers(w, wc) {
+				return true, nil
+			}
+		}
+		return false, failuref(ede.NSECMissing, "no NSEC proof that %s doesn't exist", wc)
+	}
+	return false, failuref(ede.NSECMissing, "no NSEC proof that %s doesn't exist", name)
+}
+
+// wildcard checks the proof in ns that name, answered with a wildcard of labels labels, doesn't
+// exist itself.
+func wildcard(ns []dns.RR, name string, labels int) (bool, error) {
+	if n3 := nsec3s(ns); len(n3) > 0 {
+		if !usable(n3) {
+			return false, nil
+		}
+		// The next closer name, one label below the wildcard.
+		idx := dns.Split(name)
+		nc := name[idx[len(idx)-labels-1]:]
+		c := cover3(n3, nc)
+		if c == nil {
+			return false, failuref(ede.NSECMissing, "no NSEC3 proof that %s doesn't exist", nc)
+		}
+		return c.Flags&optOut == 0, nil
+	}
+	for _, n := range nsecs(ns) {
+		if covers(n, name) {
+			return true, nil
+		}
+	}
+	return false, failuref(ede.NSECMissing, "no NSEC proof that %s doesn't exist", name)
+}
+
+// checkTypes checks that the types of the NSEC or NSEC3 record of name deny qtype. The NSEC of a
+// delegation comes from the parent, and only denies DS, while only the parent denies DS.
+func checkTypes(types []uint16, name string, qtype uint16) error {
+	switch {
+	case hasType(types, qtype), hasType(types, dns.TypeCNAME):
+		return failuref(ede.DNSSECBogus, "NSEC of %s has %s", name, dns.Type(qtype))
+	case qtype == dns.TypeDS && hasType(types, dns.TypeSOA) && name != ".":
+		return failuref(ede.DNSSECBogus, "NSEC of %s from the child denies DS", name)
+	case qtype != dns.TypeDS && hasType(types, dns.TypeNS) && !hasType(types, dns.TypeSOA):
+		return failuref(ede.DNSSECBogus, "NSEC of the delegation %s denies %s", name, dns.Type(qtype))
+	}
+	return nil
+}
+
+func nodata3(n3 []*dns.NSEC3, name string, qtype uint16) ([]uint16, bool, error) {
+	if !usable(n3) {
+		return nil, false, nil
+	}
+	if m := match3(n3, name); m != nil {
+		if err := checkTypes(m.TypeBitMap, name, qtype); err != nil {
+			return nil, false, err
+		}
+		return m.TypeBitMap, true, nil
+	}
+	ce, cover, err := closestEncloser3(n3, name)
+	if err != nil {
+		return nil, false, err
+	}
+	// An opt-out NSEC3 covering a DS: an unsigned delegation, see RFC 5155 8.6.
+	if qtype == dns.TypeDS && cover.Flags&optOut != 0 {
+		return []uint16{dns.TypeNS}, false, nil
+	}
+	wc := "*." + ce
+	if w := match3(n3, wc); w != nil && checkTypes(w.TypeBitMap, wc, qtype) == nil {
+		return nil, true, nil
+	}
+	return nil, false, failuref(ede.NSECMissing, "no NSEC3 proof that %s %s doesn't exist", name, dns.Type(qtype))
+}
+
+func nxdomain3(n3 []*dns.NSEC3, name string) (bool, error) {
+	if !usable(n3) {
+		return false, nil
+	}
+	ce, cover, err := closestEncloser3(n3, name)
+	if err != nil {
+		return false, err
+	}
+	if cover.Flags&optOut != 0 {
+		return false, nil
+	}
+	if cover3(n3, "*."+ce) == nil {
+		return false, failuref(ede.NSECMissing, "no NSEC3 proof that *.%s doesn't exist", ce)
+	}
+	return true, nil
+}
+
+// closestEncloser3 returns the closest encloser of name, the closest ancestor of name that exists,
+// and the NSEC3 covering the next closer name, one label below it, see RFC 5155 8.3.
+func closestEncloser3(n3 []*dns.NSEC3, name string) (string, *dns.NSEC3, error) {
+	idx := append(dns.Split(name), len(name)-1) // up to the root
+	for i := 1; i < len(idx); i++ {
+		ce := name[idx[i]:]
+		if match3(n3, ce) == nil {
+			continue
+		}
+		nc := name[idx[i-1]:]
+		cover := cover3(n3, nc)
+		if cover == nil {
+			return "", nil, failuref(ede.NSECMissing, "no NSEC3 proof that %s doesn't exist", nc)
+		}
+		if ce == "." {
+			return "", cover, nil
+		}
+		return ce, cover, nil
+	}
+	return "", nil, failuref(ede.NSECMissing, "no NSEC3 closest encloser of %s", name)
+}
+
+// usable returns true when the NSEC3 records can be checked: the unknown hashes, and those with
+// too many iterations make the answer insecure, see RFC 9276.
+func usable(n3 []*dns.NSEC3) bool {
+	for _, n := range n3 {
+		if n.Hash != dns.SHA1 || n.Iterations > maxIterations {
+			return false
+		}
+	}
+	return true
+}
+
+func match3(n3 []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, n := range n3 {
+		if n.Match(name) {
+			return n
+		}
+	}
+	return nil
+}
+
+func cover3(n3 []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, n := range n3 {
+		if n.Cover(name) {
+			return n
+		}
+	}
+	return nil
+}
+
+// covers returns true when name is between the owner and the next name of n.
+func covers(n *dns.NSEC, name string) bool {
+	owner, next := n.Hdr.Name, n.NextDomain
+	if canonicalCompare(owner, name) >= 0 {
+		return false
+	}
+	if canonicalCompare(owner, next) < 0 {
+		return canonicalCompare(name, next) < 0
+	}
+	// The last NSEC of the zone, its next name is the apex.
+	return dns.IsSubDomain(next, name)
+}
+
+// closestEncloser returns the closest ancestor of name that exists, according to the NSEC n
+// covering name.
+func closestEncloser(n *dns.NSEC, name string) string {
+	common := dns.CompareDomainName(name, n.Hdr.Name)
+	if c := dns.CompareDomainName(name, n.NextDomain); c > common {
+		common = c
+	}
+	if common == 0 {
+		return "."
+	}
+	idx := dns.Split(name)
+	return strings.ToLower(name[idx[len(idx)-common]:])
+}
+
+// canonicalCompare compares the names a and b in the canonical order of RFC 4034 6.1.
+func canonicalCompare(a, b string) int {
+	la, lb := canonicalLabels(a), canonicalLabels(b)
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := bytes.Compare(la[i], lb[j]); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
+
+// canonicalLabels returns the labels of name, lower cased, in wire format.
+func canonicalLabels(name string) [][]byte {
+	buf := make([]byte, 256)
+	off, err := dns.PackDomainName(strings.ToLower(dns.Fqdn(name)), buf, 0, nil, false)
+	if err != nil {
+		return nil
+	}
+	var labels [][]byte
+	for i := 0; i < off && buf[i] != 0; i += int(buf[i]) + 1 {
+		labels = append(labels, buf[i+1:i+1+int(buf[i])])
+	}
+	return labels
+}
+
+func hasType(types []uint16, t uint16) bool {
+	for _, t1 := range types {
+		if t1 == t {
+			return true
+		}
+	}
+	return false
+}
+
+func nsecs(rrs []dns.RR) []*dns.NSEC {
+	var n []*dns.NSEC
+	for _, rr := range rrs {
+		if rr, ok := rr.(*dns.NSEC); ok {
+			n = append(n, rr)
+		}
+	}
+	return n
+}
+
+func nsec3s(rrs []dns.RR) []*dns.NSEC3 {
+	var n []*dns.NSEC3
+	for _, rr := range rrs {
+		if rr, ok := rr.(*dns.NSEC3); ok {
+			n = append(n, rr)
+		}
+	}
+	return n
+}
+
+const (
+	optOut        = 1   // the opt-out flag of NSEC3
+	maxIterations = 150 // the most NSEC3 iterations checked, see RFC 9276
+)
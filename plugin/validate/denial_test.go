@@ -0,0 +1,165 @@
+package validate
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestCanonicalCompare(t *testing.T) {
+	// The example of RFC 4034 6.1, in order.
+	names := []string{
+		"example.",
+		"a.example.",
+		"yljkjljk.a.example.",
+		"Z.a.example.",
+		"zABC.a.EXAMPLE.",
+		"z.example.",
+		"\\001.z.example.",
+		"*.z.example.",
+		"\\200.z.example.",
+	}
+	for i := 0; i < len(names)-1; i++ {
+		if c := canonicalCompare(names[i], names[i+1]); c >= 0 {
+			t.Errorf("Expected %s before %s, got %d", names[i], names[i+1], c)
+		}
+		if c := canonicalCompare(names[i+1], names[i]); c <= 0 {
+			t.Errorf("Expected %s after %s, got %d", names[i+1], names[i], c)
+		}
+	}
+	if c := canonicalCompare("Z.a.example.", "z.A.example."); c != 0 {
+		t.Errorf("Expected equal names, got %d", c)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		nsec   string
+		name   string
+		covers bool
+	}{
+		{"a.example. IN NSEC d.example. A", "b.example.", true},
+		{"a.example. IN NSEC d.example. A", "a.example.", false},
+		{"a.example. IN NSEC d.example. A", "d.example.", false},
+		{"a.example. IN NSEC d.example. A", "x.b.example.", true},
+		{"a.example. IN NSEC d.example. A", "e.example.", false},
+		// The last NSEC of the zone.
+		{"z.example. IN NSEC example. A", "zz.example.", true},
+		{"z.example. IN NSEC example. A", "a.example.", false},
+		{"z.example. IN NSEC example. A", "example.org.", false},
+	}
+	for i, tc := range tests {
+		if got := covers(test.NSEC(tc.nsec), tc.name); got != tc.covers {
+			t.Errorf("Test %d: expected covers %t, got %t", i, tc.covers, got)
+		}
+	}
+}
+
+func TestDenialNSEC(t *testing.T) {
+	ns := []dns.RR{
+		test.NSEC("example. IN NSEC a.example. SOA NS RRSIG NSEC DNSKEY"),
+		test.NSEC("a.example. IN NSEC x.b.example. A RRSIG NSEC"),
+		test.NSEC("x.b.example. IN NSEC sub.example. A RRSIG NSEC"),
+		test.NSEC("sub.example. IN NSEC example. NS RRSIG NSEC"),
+	}
+
+	if ok, err := nxdomain(ns, "aa.example."); !ok || err != nil {
+		t.Errorf("Expected a proof that aa.example. doesn't exist, got %t, %v", ok, err)
+	}
+	// b.example. is an empty non-terminal.
+	if _, err := nxdomain(ns, "b.example."); err == nil {
+		t.Errorf("Expected no proof that b.example. doesn't exist")
+	}
+	if _, ok, err := nodata(ns, "b.example.", dns.TypeA); !ok || err != nil {
+		t.Errorf("Expected a proof that b.example. A doesn't exist, got %t, %v", ok, err)
+	}
+	if _, ok, err := nodata(ns, "a.example.", dns.TypeAAAA); !ok || err != nil {
+		t.Errorf("Expected a proof that a.example. AAAA doesn't exist, got %t, %v", ok, err)
+	}
+	if _, _, err := nodata(ns, "a.example.", dns.TypeA); err == nil {
+		t.Errorf("Expected no proof that a.example. A doesn't exist")
+	}
+	// The delegation only denies DS.
+	types, ok, err := nodata(ns, "sub.example.", dns.TypeDS)
+	if !ok || err != nil || !hasType(types, dns.TypeNS) {
+		t.Errorf("Expected a proof that the delegation sub.example. has no DS, got %v, %t, %v", types, ok, err)
+	}
+	if _, _, err := nodata(ns, "sub.example.", dns.TypeA); err == nil {
+		t.Errorf("Expected no proof from the parent that sub.example. A doesn't exist")
+	}
+	// The child apex doesn't deny DS.
+	if _, _, err := nodata(ns, "example.", dns.TypeDS); err == nil {
+		t.Errorf("Expected no proof from the child that example. DS doesn't exist")
+	}
+
+	// A wildcard answer for c.example.
+	if ok, err := wildcard(ns, "c.example.", 1); !ok || err != nil {
+		t.Errorf("Expected a proof that c.example. doesn't exist, got %t, %v", ok, err)
+	}
+	if _, err := wildcard(ns, "a.example.", 1); err == nil {
+		t.Errorf("Expected no proof that a.example. doesn't exist")
+	}
+}
+
+func TestDenialNSEC3(t *testing.T) {
+	newNSEC3 := func(flags uint8, iterations uint16) []dns.RR {
+		names := []string{"example.", "www.example."}
+		hashes := make([]string, len(names))
+		for i, name := range names {
+			hashes[i] = dns.HashName(name, dns.SHA1, iterations, "")
+		}
+		types := map[string][]uint16{
+			hashes[0]: {dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeDNSKEY, dns.TypeNSEC3PARAM},
+			hashes[1]: {dns.TypeA, dns.TypeRRSIG},
+		}
+		sort.Strings(hashes)
+		var ns []dns.RR
+		for i, h := range hashes {
+			ns = append(ns, &dns.NSEC3{
+				Hdr:        dns.RR_Header{Name: h + ".example.", Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: 300},
+				Hash:       dns.SHA1,
+				Flags:      flags,
+				Iterations: iterations,
+				HashLength: 20,
+				NextDomain: hashes[(i+1)%len(hashes)],
+				TypeBitMap: types[h],
+			})
+		}
+		return ns
+	}
+
+	ns := newNSEC3(0, 0)
+	if ok, err := nxdomain(ns, "nope.example."); !ok || err != nil {
+		t.Errorf("Expected a proof that nope.example. doesn't exist, got %t, %v", ok, err)
+	}
+	if ok, err := nxdomain(ns, "a.b.nope.example."); !ok || err != nil {
+		t.Errorf("Expected a proof that a.b.nope.example. doesn't exist, got %t, %v", ok, err)
+	}
+	if _, ok, err := nodata(ns, "www.example.", dns.TypeAAAA); !ok || err != nil {
+		t.Errorf("Expected a proof that www.example. AAAA doesn't exist, got %t, %v", ok, err)
+	}
+	if _, _, err := nodata(ns, "www.example.", dns.TypeA); err == nil {
+		t.Errorf("Expected no proof that www.example. A doesn't exist")
+	}
+	if ok, err := wildcard(ns, "nope.example.", 1); !ok || err != nil {
+		t.Errorf("Expected a proof that nope.example. doesn't exist, got %t, %v", ok, err)
+	}
+
+	// Opt-out makes the denials insecure.
+	ns = newNSEC3(optOut, 0)
+	if ok, err := nxdomain(ns, "nope.example."); ok || err != nil {
+		t.Errorf("Expected an insecure proof with opt-out, got %t, %v", ok, err)
+	}
+	if types, ok, err := nodata(ns, "sub.example.", dns.TypeDS); ok || err != nil || !hasType(types, dns.TypeNS) {
+		t.Errorf("Expected an insecure delegation with opt-out, got %v, %t, %v", types, ok, err)
+	}
+
+	// So do too many iterations.
+	ns = newNSEC3(0, maxIterations+1)
+	if ok, err := nxdomain(ns, "nope.example."); ok || err != nil {
+		t.Errorf("Expected an insecure proof with %d iterations, got %t, %v", maxIterations+1, ok, err)
+	}
+}
@@ -0,0 +1,95 @@
+package validate
+
+import (
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	caddy.RegisterPlugin("validate", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	v, err := parse(c)
+	if err != nil {
+		return plugin.Error("validate", err)
+	}
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		v.Next = next
+		return v
+	})
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, results)
+		return nil
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Validate, error) {
+	a := newAnchors()
+	var zones []string
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		zones = make([]string, len(c.ServerBlockKeys))
+		copy(zones, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			zones = args
+		}
+		for i := range zones {
+			zones[i] = plugin.Host(zones[i]).Normalize()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "trust_anchor":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				rr, err := dns.NewRR(args[0])
+				if err != nil {
+					return nil, c.Errf("bad trust anchor: %s", err)
+				}
+				if err := a.add(rr); err != nil {
+					return nil, c.Err(err.Error())
+				}
+			case "trust_anchor_file":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				a.file = args[0]
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+
+	if a.file != "" {
+		if err := a.load(); err != nil {
+			return nil, err
+		}
+	}
+	if len(a.zones) == 0 {
+		for _, s := range rootAnchors {
+			rr, _ := dns.NewRR(s)
+			a.add(rr)
+		}
+	}
+	return New(zones, a), nil
+}
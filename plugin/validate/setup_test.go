@@ -0,0 +1,106 @@
+package validate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input      string
+		shouldErr  bool
+		zones      []string
+		anchors    int // number of zones with trust anchors
+		rootAnchor bool
+	}{
+		{`validate`, false, []string{}, 1, true},
+		{`validate example.org`, false, []string{"example.org."}, 1, true},
+		{`validate {
+			trust_anchor "example.org. IN DS 12345 13 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"
+		}`, false, []string{}, 1, false},
+		{`validate {
+			trust_anchor "example.org. IN A 127.0.0.1"
+		}`, true, nil, 0, false},
+		{`validate {
+			trust_anchor "example.org. IN BOGUS 1"
+		}`, true, nil, 0, false},
+		{`validate {
+			trust_anchor
+		}`, true, nil, 0, false},
+		{`validate {
+			trust_anchor_file
+		}`, true, nil, 0, false},
+		{`validate {
+			unknown
+		}`, true, nil, 0, false},
+		{`validate
+		validate`, true, nil, 0, false},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		v, err := parse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if fmt.Sprint(v.Zones) != fmt.Sprint(test.zones) {
+			t.Errorf("Test %d: expected zones %v, got %v", i, test.zones, v.Zones)
+		}
+		if len(v.anchors.zones) != test.anchors {
+			t.Errorf("Test %d: expected %d zones with trust anchors, got %d", i, test.anchors, len(v.anchors.zones))
+		}
+		if _, ok := v.anchors.zones["."]; ok != test.rootAnchor {
+			t.Errorf("Test %d: expected root trust anchor %t, got %t", i, test.rootAnchor, ok)
+		}
+	}
+}
+
+func TestSetupTrustAnchorFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "anchors")
+	content := "; the anchors of example.org.\n" +
+		"example.org. 3600 IN DS 12345 13 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D\n"
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := caddy.NewTestController("dns", `validate {
+		trust_anchor_file `+file+`
+	}`)
+	v, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if v.anchors.file != file {
+		t.Errorf("Expected trust anchor file %s, got %s", file, v.anchors.file)
+	}
+	if _, ok := v.anchors.zones["example.org."]; !ok || len(v.anchors.zones) != 1 {
+		t.Errorf("Expected only the trust anchors of example.org., got %v", v.anchors.zones)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("example.org. IN DS bad\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c = caddy.NewTestController("dns", `validate {
+		trust_anchor_file `+file+`
+	}`)
+	if _, err := parse(c); err == nil {
+		t.Errorf("Expected error for a bad trust anchor file, got none")
+	}
+}
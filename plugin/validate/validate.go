@@ -0,0 +1,181 @@
+// Package validate implements a plugin that validates the answers of the next plugin with DNSSEC,
+// see RFC 4035.
+package validate
+
+import (
+	"context"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/ede"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+	"github.com/coredns/coredns/plugin/pkg/singleflight"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var log = clog.NewWithPlugin("validate")
+
+// Validate validates the answers of the next plugin, which must be a recursive resolver such as
+// forward. The secure answers get the AD bit, the bogus ones are replaced by SERVFAIL with an
+// Extended DNS Error, see RFC 8914.
+type Validate struct {
+	Next  plugin.Handler
+	Zones []string
+
+	anchors  *anchors
+	cuts     *cache.Cache // the zone cuts found, by name
+	inflight *singleflight.Group
+
+	now func() time.Time
+}
+
+// New returns a new Validate, with the trust anchors a.
+func New(zones []string, a *anchors) *Validate {
+	return &Validate{
+		Zones:    zones,
+		anchors:  a,
+		cuts:     cache.New(defaultCap),
+		inflight: new(singleflight.Group),
+		now:      time.Now,
+	}
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (v *Validate) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	// With CD the client validates the answers itself.
+	if plugin.Zones(v.Zones).Matches(state.Name()) == "" || r.CheckingDisabled {
+		return plugin.NextOrFailure(v.Name(), v.Next, ctx, w, r)
+	}
+
+	server := metrics.WithServer(ctx)
+
+	req := r.Copy()
+	req.CheckingDisabled = true
+	setDo(req)
+	nw := nonwriter.New(w)
+	rcode, err := plugin.NextOrFailure(v.Name(), v.Next, ctx, nw, req)
+	if nw.Msg == nil {
+		return rcode, err
+	}
+	m := nw.Msg
+
+	secure, err := v.verify(ctx, w, m, state.Name(), state.QType())
+	if err != nil {
+		results.WithLabelValues(server, "bogus").Inc()
+		log.Infof("Bogus answer for %s %s: %s", state.Name(), state.Type(), err)
+
+		resp := new(dns.Msg)
+		resp.SetRcode(r, dns.RcodeServerFailure)
+		f := err.(*failure)
+		ede.Set(r, resp, f.code, f.reason)
+		w.WriteMsg(resp)
+		return dns.RcodeSuccess, nil
+	}
+
+	if secure {
+		results.WithLabelValues(server, "secure").Inc()
+	} else {
+		results.WithLabelValues(server, "insecure").Inc()
+	}
+
+	m.Id = r.Id
+	m.CheckingDisabled = false
+	// The AD bit only goes to the clients saying they understand it, see RFC 6840 5.8.
+	m.AuthenticatedData = secure && (state.Do() || r.AuthenticatedData)
+	if !state.Do() {
+		stripDNSSEC(m, state.QType())
+	}
+	if opt := m.IsEdns0(); opt != nil {
+		if r.IsEdns0() == nil {
+			removeOPT(m)
+		} else {
+			opt.SetDo(state.Do())
+		}
+	}
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// Name implements the Handler interface.
+func (v *Validate) Name() string { return "validate" }
+
+// lookup asks the next plugin for the DNSSEC records of name and qtype, without validating them.
+func (v *Validate) lookup(ctx context.Context, w dns.ResponseWriter, name string, qtype uint16) (*dns.Msg, error) {
+	m, err := v.inflight.Do(cache.Hash([]byte(dns.Type(qtype).String()+" "+name)), func() (interface{}, error) {
+		req := new(dns.Msg)
+		req.SetQuestion(name, qtype)
+		req.CheckingDisabled = true
+		req.SetEdns0(4096, true)
+
+		nw := nonwriter.New(w)
+		if _, err := plugin.NextOrFailure(v.Name(), v.Next, ctx, nw, req); err != nil {
+			return nil, err
+		}
+		if nw.Msg == nil {
+			return nil, errNoResponse
+		}
+		return nw.Msg, nil
+	})
+	if err != nil {
+		return nil, failuref(ede.NetworkError, "no %s records for %s: %s", dns.Type(qtype), name, err)
+	}
+	return m.(*dns.Msg), nil
+}
+
+// setDo sets the DO bit in the OPT record of m, adding one if needed.
+func setDo(m *dns.Msg) {
+	if opt := m.IsEdns0(); opt != nil {
+		opt.SetDo()
+		return
+	}
+	m.SetEdns0(4096, true)
+}
+
+// removeOPT removes the OPT record from m.
+func removeOPT(m *dns.Msg) {
+	extra := m.Extra[:0]
+	for _, rr := range m.Extra {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			extra = append(extra, rr)
+		}
+	}
+	m.Extra = extra
+}
+
+// stripDNSSEC removes the DNSSEC records not asked for with qtype from m, for a client that
+// didn't set DO, see RFC 4035 3.2.1.
+func stripDNSSEC(m *dns.Msg, qtype uint16) {
+	strip := func(rrs []dns.RR) []dns.RR {
+		kept := rrs[:0]
+		for _, rr := range rrs {
+			switch t := rr.Header().Rrtype; t {
+			case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+				if t != qtype {
+					continue
+				}
+			}
+			kept = append(kept, rr)
+		}
+		return kept
+	}
+	m.Answer = strip(m.Answer)
+	m.Ns = strip(m.Ns)
+	m.Extra = strip(m.Extra)
+}
+
+var results = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "validate",
+	Name:      "results_total",
+	Help:      "Counter of the validated answers, by result.",
+}, []string{"server", "result"})
+
+const defaultCap = 10000 // default capacity of the cache of the zone cuts.
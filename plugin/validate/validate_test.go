@@ -0,0 +1,369 @@
+package validate
+
+import (
+	"context"
+	"crypto"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/ede"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// testZone is a zone signed with a single key, when it has one.
+type testZone struct {
+	name string
+	key  *dns.DNSKEY
+	priv crypto.Signer
+	rrs  []dns.RR
+}
+
+func newTestZone(t *testing.T, name string, signed bool, rrs ...string) *testZone {
+	z := &testZone{name: name}
+	z.rrs = append(z.rrs, test.SOA(name+" 3600 IN SOA ns. hostmaster. 1 3600 600 86400 300"))
+	for _, s := range rrs {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		z.rrs = append(z.rrs, rr)
+	}
+	if signed {
+		z.key, z.priv = newTestKey(t, name)
+		z.rrs = append(z.rrs, z.key)
+	}
+	return z
+}
+
+func newTestKey(t *testing.T, name string) (*dns.DNSKEY, crypto.Signer) {
+	k := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := k.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k, priv.(crypto.Signer)
+}
+
+// sign adds the NSEC records and the signatures of z, valid around now. The names starting with
+// "nosig." aren't signed, those starting with "old." have expired signatures.
+func (z *testZone) sign(t *testing.T, now time.Time) {
+	types := make(map[string][]uint16)
+	for _, rr := range z.rrs {
+		name := rr.Header().Name
+		types[name] = append(types[name], rr.Header().Rrtype)
+	}
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return canonicalCompare(names[i], names[j]) < 0 })
+	for i, name := range names {
+		ts := append(types[name], dns.TypeNSEC, dns.TypeRRSIG)
+		sort.Slice(ts, func(i, j int) bool { return ts[i] < ts[j] })
+		z.rrs = append(z.rrs, &dns.NSEC{
+			Hdr:        dns.RR_Header{Name: name, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 300},
+			NextDomain: names[(i+1)%len(names)],
+			TypeBitMap: ts,
+		})
+	}
+
+	for _, s := range rrsets(z.rrs) {
+		if strings.HasPrefix(s.name, "nosig.") {
+			continue
+		}
+		inception, expiration := now.Add(-time.Hour), now.Add(24*time.Hour)
+		if strings.HasPrefix(s.name, "old.") {
+			inception, expiration = now.Add(-48*time.Hour), now.Add(-24*time.Hour)
+		}
+		z.rrs = append(z.rrs, z.signSet(t, s.rrs, inception, expiration, z.key, z.priv))
+	}
+}
+
+func (z *testZone) signSet(t *testing.T, rrs []dns.RR, inception, expiration time.Time, k *dns.DNSKEY, priv crypto.Signer) *dns.RRSIG {
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrs[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: rrs[0].Header().Ttl},
+		Algorithm:  k.Algorithm,
+		KeyTag:     k.KeyTag(),
+		SignerName: z.name,
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+	if err := sig.Sign(priv, rrs); err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// testResolver answers from its zones, like a recursive resolver asked with CD.
+type testResolver struct {
+	zones   []*testZone
+	queries int
+}
+
+func (r *testResolver) ServeDNS(ctx context.Context, w dns.ResponseWriter, req *dns.Msg) (int, error) {
+	r.queries++
+	q := req.Question[0]
+	name := strings.ToLower(q.Name)
+
+	// The closest zone, the DS records are in the parent.
+	var z *testZone
+	for _, z1 := range r.zones {
+		if !dns.IsSubDomain(z1.name, name) || (q.Qtype == dns.TypeDS && z1.name == name && name != ".") {
+			continue
+		}
+		if z == nil || dns.CountLabel(z1.name) > dns.CountLabel(z.name) {
+			z = z1
+		}
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.SetEdns0(4096, true)
+	exists := false
+	for _, rr := range z.rrs {
+		if !strings.EqualFold(rr.Header().Name, name) {
+			continue
+		}
+		exists = true
+		t := rr.Header().Rrtype
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			t = sig.TypeCovered
+		}
+		if t == q.Qtype {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+	if len(m.Answer) == 0 {
+		if !exists {
+			m.Rcode = dns.RcodeNameError
+		}
+		// The SOA, and the NSEC records proving the denial.
+		proof := map[string]bool{z.name: true}
+		for _, rr := range z.rrs {
+			if n, ok := rr.(*dns.NSEC); ok && (n.Hdr.Name == name || covers(n, name) || covers(n, "*."+z.name)) {
+				proof[n.Hdr.Name] = true
+			}
+		}
+		for _, rr := range z.rrs {
+			t := rr.Header().Rrtype
+			if sig, ok := rr.(*dns.RRSIG); ok {
+				t = sig.TypeCovered
+			}
+			if (t == dns.TypeSOA && rr.Header().Name == z.name) || (t == dns.TypeNSEC && proof[rr.Header().Name]) {
+				m.Ns = append(m.Ns, rr)
+			}
+		}
+	}
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+func (r *testResolver) Name() string { return "resolver" }
+
+// newTestValidate returns a Validate with a signed root, a signed zone example. and an insecure
+// zone insecure.
+func newTestValidate(t *testing.T) (*Validate, *testResolver) {
+	now := time.Now()
+
+	example := newTestZone(t, "example.", true,
+		"www.example. 3600 IN A 127.0.0.1",
+		"bad.example. 3600 IN A 127.0.0.1",
+		"nosig.example. 3600 IN A 127.0.0.1",
+		"old.example. 3600 IN A 127.0.0.1",
+		"evil.example. 3600 IN A 192.0.2.66",
+		"d.example. 3600 IN DNAME example.",
+	)
+	example.sign(t, now)
+	// Tamper with bad.example. after signing it.
+	for _, rr := range example.rrs {
+		if a, ok := rr.(*dns.A); ok && a.Hdr.Name == "bad.example." {
+			a.A = []byte{127, 0, 0, 2}
+		}
+	}
+
+	ds := example.key.ToDS(dns.SHA256)
+	ds.Hdr.Ttl = 3600
+	root := newTestZone(t, ".", true,
+		"example. 3600 IN NS ns.example.",
+		"insecure. 3600 IN NS ns.insecure.",
+	)
+	root.rrs = append(root.rrs, ds)
+	root.sign(t, now)
+
+	insecure := newTestZone(t, "insecure.", false, "www.insecure. 3600 IN A 127.0.0.1")
+
+	a := newAnchors()
+	rootDS := root.key.ToDS(dns.SHA256)
+	a.add(rootDS)
+
+	r := &testResolver{zones: []*testZone{root, example, insecure}}
+	v := New([]string{"."}, a)
+	v.Next = r
+	return v, r
+}
+
+func TestValidate(t *testing.T) {
+	v, _ := newTestValidate(t)
+
+	tests := []struct {
+		qname string
+		qtype uint16
+		do    bool
+		rcode int
+		ad    bool
+		ede   int // the Extended DNS Error, -1 for none
+	}{
+		{"www.example.", dns.TypeA, true, dns.RcodeSuccess, true, -1},
+		{"www.example.", dns.TypeAAAA, true, dns.RcodeSuccess, true, -1},
+		{"nope.example.", dns.TypeA, true, dns.RcodeNameError, true, -1},
+		{"example.", dns.TypeDNSKEY, true, dns.RcodeSuccess, true, -1},
+		{"www.insecure.", dns.TypeA, true, dns.RcodeSuccess, false, -1},
+		{"bad.example.", dns.TypeA, true, dns.RcodeServerFailure, false, int(ede.DNSSECBogus)},
+		{"nosig.example.", dns.TypeA, true, dns.RcodeServerFailure, false, int(ede.RRSIGsMissing)},
+		{"old.example.", dns.TypeA, true, dns.RcodeServerFailure, false, int(ede.SignatureExpired)},
+		// Without DO there is no AD, but the bogus answers fail the same.
+		{"www.example.", dns.TypeA, false, dns.RcodeSuccess, false, -1},
+		{"bad.example.", dns.TypeA, false, dns.RcodeServerFailure, false, int(ede.DNSSECBogus)},
+	}
+
+	for i, tc := range tests {
+		req := new(dns.Msg)
+		req.SetQuestion(tc.qname, tc.qtype)
+		req.SetEdns0(4096, tc.do)
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := v.ServeDNS(context.TODO(), rec, req); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		m := rec.Msg
+		if m.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[m.Rcode])
+		}
+		if m.AuthenticatedData != tc.ad {
+			t.Errorf("Test %d: expected AD %t, got %t", i, tc.ad, m.AuthenticatedData)
+		}
+		if m.Id != req.Id {
+			t.Errorf("Test %d: expected id %d, got %d", i, req.Id, m.Id)
+		}
+		if ede := extendedError(m); ede != tc.ede {
+			t.Errorf("Test %d: expected Extended DNS Error %d, got %d", i, tc.ede, ede)
+		}
+		if !tc.do {
+			for _, rr := range m.Answer {
+				if rr.Header().Rrtype == dns.TypeRRSIG {
+					t.Errorf("Test %d: expected no signatures without DO, got %s", i, rr)
+				}
+			}
+		}
+	}
+}
+
+func TestValidateCheckingDisabled(t *testing.T) {
+	v, _ := newTestValidate(t)
+
+	req := new(dns.Msg)
+	req.SetQuestion("bad.example.", dns.TypeA)
+	req.SetEdns0(4096, true)
+	req.CheckingDisabled = true
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	v.ServeDNS(context.TODO(), rec, req)
+	if rec.Msg.Rcode != dns.RcodeSuccess || rec.Msg.AuthenticatedData {
+		t.Errorf("Expected the answer as is with CD, got %s", rec.Msg)
+	}
+}
+
+func TestValidateCachesCuts(t *testing.T) {
+	v, r := newTestValidate(t)
+
+	ask := func() {
+		req := new(dns.Msg)
+		req.SetQuestion("www.example.", dns.TypeA)
+		req.SetEdns0(4096, true)
+		v.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	}
+	ask()
+	first := r.queries
+	ask()
+	// The chain of trust is cached: only the question itself is asked again.
+	if r.queries-first != 1 {
+		t.Errorf("Expected 1 query for a cached chain of trust, got %d", r.queries-first)
+	}
+
+	// Once the cuts expire they are looked up again.
+	v.now = func() time.Time { return time.Now().Add(2 * maxCut) }
+	r.queries = 0
+	ask()
+	if r.queries == 1 {
+		t.Errorf("Expected the chain of trust to be looked up again, got %d queries", r.queries)
+	}
+}
+
+func TestValidateDNAME(t *testing.T) {
+	v, r := newTestValidate(t)
+
+	// The signed DNAME of d.example., and the signed records of www.example., which it leads to, and
+	// of evil.example.
+	var dname, www []dns.RR
+	for _, rr := range r.zones[1].rrs {
+		name, rtype := rr.Header().Name, rr.Header().Rrtype
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			rtype = sig.TypeCovered
+		}
+		switch {
+		case name == "d.example." && rtype == dns.TypeDNAME:
+			dname = append(dname, rr)
+		case (name == "www.example." || name == "evil.example.") && rtype == dns.TypeA:
+			www = append(www, rr)
+		}
+	}
+
+	tests := []struct {
+		target string
+		secure bool
+		ede    int // the Extended DNS Error, -1 for none
+	}{
+		{"www.example.", true, -1},
+		// A forged CNAME next to the real DNAME.
+		{"evil.example.", false, int(ede.DNSSECBogus)},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("www.d.example.", dns.TypeA)
+		m.Answer = append(m.Answer, dname...)
+		m.Answer = append(m.Answer, test.CNAME("www.d.example. 3600 IN CNAME "+tc.target))
+		m.Answer = append(m.Answer, www...)
+
+		secure, err := v.verify(context.TODO(), &test.ResponseWriter{}, m, "www.d.example.", dns.TypeA)
+		if tc.ede >= 0 {
+			if f, ok := err.(*failure); !ok || int(f.code) != tc.ede {
+				t.Errorf("Test %d: expected Extended DNS Error %d, got %v", i, tc.ede, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+		}
+		if secure != tc.secure {
+			t.Errorf("Test %d: expected secure %t, got %t", i, tc.secure, secure)
+		}
+	}
+}
+
+func extendedError(m *dns.Msg) int {
+	code, _, ok := ede.Get(m)
+	if !ok {
+		return -1
+	}
+	return int(code)
+}
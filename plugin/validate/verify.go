@@ -0,0 +1,236 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/ede"
+
+	"github.com/miekg/dns"
+)
+
+// failure is why an answer can't be validated, with its Extended DNS Error code.
+type failure struct {
+	code   uint16
+	reason string
+}
+
+func (f *failure) Error() string { return f.reason }
+
+func failuref(code uint16, format string, a ...interface{}) error {
+	return &failure{code: code, reason: fmt.Sprintf(format, a...)}
+}
+
+var errNoResponse = errors.New("no response")
+
+// rrset is the records of a name and type, with their signatures.
+type rrset struct {
+	name  string
+	rtype uint16
+	rrs   []dns.RR
+	sigs  []*dns.RRSIG
+}
+
+// rrsets groups rrs by name and type, in the order they first appear.
+func rrsets(rrs []dns.RR) []*rrset {
+	var sets []*rrset
+	find := func(name string, rtype uint16) *rrset {
+		for _, s := range sets {
+			if s.rtype == rtype && strings.EqualFold(s.name, name) {
+				return s
+			}
+		}
+		s := &rrset{name: strings.ToLower(name), rtype: rtype}
+		sets = append(sets, s)
+		return s
+	}
+	for _, rr := range rrs {
+		switch rr := rr.(type) {
+		case *dns.OPT:
+		case *dns.RRSIG:
+			s := find(rr.Hdr.Name, rr.TypeCovered)
+			s.sigs = append(s.sigs, rr)
+		default:
+			s := find(rr.Header().Name, rr.Header().Rrtype)
+			s.rrs = append(s.rrs, rr)
+		}
+	}
+	// Signatures without records.
+	kept := sets[:0]
+	for _, s := range sets {
+		if len(s.rrs) > 0 {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// lookupSet returns the records of name and rtype in rrs.
+func lookupSet(rrs []dns.RR, name string, rtype uint16) *rrset {
+	for _, s := range rrsets(rrs) {
+		if s.rtype == rtype && strings.EqualFold(s.name, name) {
+			return s
+		}
+	}
+	return nil
+}
+
+// verifySet checks that s is signed by the keys of the secure zone z, and returns the signature
+// that verified.
+func (v *Validate) verifySet(z *zone, s *rrset) (*dns.RRSIG, error) {
+	if len(s.sigs) == 0 {
+		return nil, failuref(ede.RRSIGsMissing, "no signatures for %s %s", s.name, dns.Type(s.rtype))
+	}
+	now := v.now()
+	f := failuref(ede.DNSKEYMissing, "no key of %s for the signatures of %s %s", z.name, s.name, dns.Type(s.rtype))
+	for _, sig := range s.sigs {
+		if !strings.EqualFold(sig.SignerName, z.name) || int(sig.Labels) > dns.CountLabel(s.name) {
+			continue
+		}
+		for _, k := range z.keys {
+			if k.KeyTag() != sig.KeyTag || k.Algorithm != sig.Algorithm || k.Flags&dns.ZONE == 0 || k.Flags&dns.REVOKE != 0 {
+				continue
+			}
+			if !sig.ValidityPeriod(now) {
+				if int64(sig.Expiration)-now.Unix() < 0 {
+					f = failuref(ede.SignatureExpired, "signature of %s %s expired", s.name, dns.Type(s.rtype))
+				} else {
+					f = failuref(ede.SignatureNotYetValid, "signature of %s %s not yet valid", s.name, dns.Type(s.rtype))
+				}
+				continue
+			}
+			if err := sig.Verify(k, s.rrs); err != nil {
+				f = failuref(ede.DNSSECBogus, "bad signature of %s %s: %s", s.name, dns.Type(s.rtype), err)
+				continue
+			}
+			return sig, nil
+		}
+	}
+	return nil, f
+}
+
+// verify validates m, the answer for qname and qtype. It returns true when m is secure, false when
+// it is insecure, and an error when it is bogus.
+func (v *Validate) verify(ctx context.Context, w dns.ResponseWriter, m *dns.Msg, qname string, qtype uint16) (bool, error) {
+	secure := true
+
+	sets := rrsets(m.Answer)
+	for _, s := range sets {
+		// The CNAMEs synthesized from a DNAME are not signed, the DNAME is.
+		if s.rtype == dns.TypeCNAME && len(s.sigs) == 0 && dnameAbove(sets, s.name) {
+			if !synthesized(sets, s) {
+				return false, failuref(ede.DNSSECBogus, "unsigned CNAME %s not synthesized from a DNAME", s.name)
+			}
+			continue
+		}
+		z, err := v.zoneFor(ctx, w, s.name, s.rtype)
+		if err != nil {
+			return false, err
+		}
+		if !z.secure() {
+			secure = false
+			continue
+		}
+		sig, err := v.verifySet(z, s)
+		if err != nil {
+			return false, err
+		}
+		// A record expanded from a wildcard: the name itself must not exist, see RFC 4035 5.3.4.
+		if labels := dns.CountLabel(s.name); int(sig.Labels) < labels {
+			if err := v.verifyDenials(z, m.Ns); err != nil {
+				return false, err
+			}
+			ok, err := wildcard(m.Ns, s.name, int(sig.Labels))
+			if err != nil {
+				return false, err
+			}
+			secure = secure && ok
+		}
+	}
+
+	// Follow the CNAMEs to the name that is answered, or denied.
+	target := strings.ToLower(qname)
+	for i := 0; i < 8; i++ {
+		s := lookupSet(m.Answer, target, dns.TypeCNAME)
+		if s == nil || qtype == dns.TypeCNAME {
+			break
+		}
+		target = strings.ToLower(s.rrs[0].(*dns.CNAME).Target)
+	}
+	if lookupSet(m.Answer, target, qtype) != nil || (m.Rcode != dns.RcodeSuccess && m.Rcode != dns.RcodeNameError) {
+		return secure, nil
+	}
+
+	// A denial of existence.
+	z, err := v.zoneFor(ctx, w, target, qtype)
+	if err != nil {
+		return false, err
+	}
+	if !z.secure() {
+		return false, nil
+	}
+	if err := v.verifyDenials(z, m.Ns); err != nil {
+		return false, err
+	}
+	var ok bool
+	if m.Rcode == dns.RcodeNameError {
+		ok, err = nxdomain(m.Ns, target)
+	} else {
+		_, ok, err = nodata(m.Ns, target, qtype)
+	}
+	if err != nil {
+		return false, err
+	}
+	return secure && ok, nil
+}
+
+// verifyDenials checks that the SOA, NSEC and NSEC3 records in ns are signed by the keys of the
+// secure zone z.
+func (v *Validate) verifyDenials(z *zone, ns []dns.RR) error {
+	for _, s := range rrsets(ns) {
+		if s.rtype != dns.TypeSOA && s.rtype != dns.TypeNSEC && s.rtype != dns.TypeNSEC3 {
+			continue
+		}
+		if _, err := v.verifySet(z, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dnameAbove returns true when a DNAME in sets is above name.
+func dnameAbove(sets []*rrset, name string) bool {
+	for _, s := range sets {
+		if s.rtype == dns.TypeDNAME && !strings.EqualFold(s.name, name) && dns.IsSubDomain(s.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// synthesized returns true when the CNAME in c is the one a DNAME in sets synthesizes for its owner:
+// its target is the owner with the owner of the DNAME replaced by the target of the DNAME, see
+// RFC 6672 5.3.1.
+func synthesized(sets []*rrset, c *rrset) bool {
+	if len(c.rrs) != 1 {
+		return false
+	}
+	cname := c.rrs[0].(*dns.CNAME)
+	labels := dns.SplitDomainName(cname.Hdr.Name)
+	for _, s := range sets {
+		if s.rtype != dns.TypeDNAME || strings.EqualFold(s.name, c.name) || !dns.IsSubDomain(s.name, c.name) {
+			continue
+		}
+		for _, rr := range s.rrs {
+			d := rr.(*dns.DNAME)
+			prefix := labels[:len(labels)-dns.CountLabel(d.Hdr.Name)]
+			target := dns.Fqdn(strings.Join(append(prefix, dns.SplitDomainName(d.Target)...), "."))
+			if strings.EqualFold(cname.Target, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
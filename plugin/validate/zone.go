@@ -0,0 +1,247 @@
+package validate
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/ede"
+
+	"github.com/miekg/dns"
+)
+
+// zone is a zone and its keys. An insecure zone has no keys.
+type zone struct {
+	name string
+	keys []*dns.DNSKEY
+}
+
+func (z *zone) secure() bool { return len(z.keys) > 0 }
+
+// cut is what a lookup of the DS records of a name found, it is cached until expire.
+type cut struct {
+	zone   *zone // the zone starting at the name, nil when the name is not a zone cut
+	stop   bool  // the name doesn't exist, nor do the names below it
+	expire time.Time
+}
+
+// zoneFor returns the zone with the records of name and qtype: the DS records are in the parent
+// zone.
+func (v *Validate) zoneFor(ctx context.Context, w dns.ResponseWriter, name string, qtype uint16) (*zone, error) {
+	if qtype == dns.TypeDS && name != "." {
+		idx := dns.Split(name)
+		parent := "."
+		if len(idx) > 1 {
+			parent = name[idx[1]:]
+		}
+		return v.zoneOf(ctx, w, parent)
+	}
+	return v.zoneOf(ctx, w, name)
+}
+
+// zoneOf returns the zone of name, going down the chain of trust from the closest trust anchor.
+func (v *Validate) zoneOf(ctx context.Context, w dns.ResponseWriter, name string) (*zone, error) {
+	name = strings.ToLower(dns.Fqdn(name))
+	a := v.anchors.closest(name)
+	if a == "" {
+		return &zone{name: "."}, nil
+	}
+	z, err := v.anchorZone(ctx, w, a)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := dns.Split(name)
+	for i := dns.CountLabel(name) - dns.CountLabel(a) - 1; i >= 0; i-- {
+		// Everything below an insecure zone is insecure.
+		if !z.secure() {
+			return z, nil
+		}
+		c, err := v.cut(ctx, w, z, name[idx[i]:])
+		if err != nil {
+			return nil, err
+		}
+		if c.stop {
+			return z, nil
+		}
+		if c.zone != nil {
+			z = c.zone
+		}
+	}
+	return z, nil
+}
+
+// anchorZone returns the zone name with a trust anchor, its DNSKEY records must be signed by a
+// trusted key.
+func (v *Validate) anchorZone(ctx context.Context, w dns.ResponseWriter, name string) (*zone, error) {
+	key := cache.Hash([]byte("anchor " + name))
+	if c, ok := v.cuts.Get(key); ok && v.now().Before(c.(*cut).expire) {
+		return c.(*cut).zone, nil
+	}
+
+	m, err := v.lookup(ctx, w, name, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, err
+	}
+	s := lookupSet(m.Answer, name, dns.TypeDNSKEY)
+	if s == nil {
+		return nil, failuref(ede.DNSKEYMissing, "no DNSKEY records for the trust anchor %s", name)
+	}
+	trusted := v.anchors.trusted(name, dnskeys(s))
+	if len(trusted) == 0 {
+		return nil, failuref(ede.DNSKEYMissing, "no DNSKEY of %s matches its trust anchors", name)
+	}
+	var f error
+	for _, k := range trusted {
+		if _, f = v.verifySet(&zone{name: name, keys: []*dns.DNSKEY{k}}, s); f == nil {
+			break
+		}
+	}
+	if f != nil {
+		return nil, f
+	}
+	v.anchors.observe(name, s)
+
+	z := &zone{name: name, keys: dnskeys(s)}
+	v.cuts.Add(key, &cut{zone: z, expire: v.expire(s.rrs[0].Header().Ttl)})
+	return z, nil
+}
+
+// cut returns what is at child, a name one label below the secure zone parent.
+func (v *Validate) cut(ctx context.Context, w dns.ResponseWriter, parent *zone, child string) (*cut, error) {
+	key := cache.Hash([]byte(child))
+	if c, ok := v.cuts.Get(key); ok && v.now().Before(c.(*cut).expire) {
+		return c.(*cut), nil
+	}
+
+	m, err := v.lookup(ctx, w, child, dns.TypeDS)
+	if err != nil {
+		return nil, err
+	}
+	c, err := v.findCut(ctx, w, parent, child, m)
+	if err != nil {
+		return nil, err
+	}
+	v.cuts.Add(key, c)
+	return c, nil
+}
+
+// findCut returns the cut at child from m, the answer for its DS records.
+func (v *Validate) findCut(ctx context.Context, w dns.ResponseWriter, parent *zone, child string, m *dns.Msg) (*cut, error) {
+	ttl := uint32(maxCut / time.Second)
+	for _, rr := range m.Ns {
+		if soa, ok := rr.(*dns.SOA); ok && soa.Minttl < ttl {
+			ttl = soa.Minttl
+		}
+	}
+
+	// A secure delegation.
+	if ds := lookupSet(m.Answer, child, dns.TypeDS); ds != nil {
+		if _, err := v.verifySet(parent, ds); err != nil {
+			return nil, err
+		}
+		z, err := v.zoneKeys(ctx, w, child, ds)
+		if err != nil {
+			return nil, err
+		}
+		return &cut{zone: z, expire: v.expire(ds.rrs[0].Header().Ttl)}, nil
+	}
+
+	switch {
+	case m.Rcode == dns.RcodeNameError:
+		// The answers below child are validated with the keys of parent, which is what
+		// proves they don't exist.
+		return &cut{stop: true, expire: v.expire(ttl)}, nil
+	case lookupSet(m.Answer, child, dns.TypeCNAME) != nil:
+		return &cut{expire: v.expire(ttl)}, nil
+	}
+
+	// No DS records: an insecure delegation, or not a zone cut at all.
+	if err := v.verifyDenials(parent, m.Ns); err != nil {
+		return nil, err
+	}
+	types, ok, err := nodata(m.Ns, child, dns.TypeDS)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || hasType(types, dns.TypeNS) {
+		return &cut{zone: &zone{name: child}, expire: v.expire(ttl)}, nil
+	}
+	return &cut{expire: v.expire(ttl)}, nil
+}
+
+// zoneKeys returns the zone child, its DNSKEY records must be signed by a key matching one of the
+// DS records ds. Without a DS record this resolver supports, the zone is insecure, see RFC 4035 5.2.
+func (v *Validate) zoneKeys(ctx context.Context, w dns.ResponseWriter, child string, ds *rrset) (*zone, error) {
+	supported := false
+	for _, rr := range ds.rrs {
+		d := rr.(*dns.DS)
+		if algorithms[d.Algorithm] && digests[d.DigestType] {
+			supported = true
+		}
+	}
+	if !supported {
+		return &zone{name: child}, nil
+	}
+
+	m, err := v.lookup(ctx, w, child, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, err
+	}
+	s := lookupSet(m.Answer, child, dns.TypeDNSKEY)
+	if s == nil {
+		return nil, failuref(ede.DNSKEYMissing, "no DNSKEY records for %s", child)
+	}
+	keys := dnskeys(s)
+	f := failuref(ede.DNSKEYMissing, "no DNSKEY of %s matches its DS records", child)
+	for _, k := range keys {
+		for _, rr := range ds.rrs {
+			if !matches(k, rr.(*dns.DS)) {
+				continue
+			}
+			if _, err := v.verifySet(&zone{name: child, keys: []*dns.DNSKEY{k}}, s); err != nil {
+				f = err
+				continue
+			}
+			return &zone{name: child, keys: keys}, nil
+		}
+	}
+	return nil, f
+}
+
+// expire returns when a cut found with ttl expires, at most maxCut from now.
+func (v *Validate) expire(ttl uint32) time.Time {
+	d := time.Duration(ttl) * time.Second
+	if d > maxCut {
+		d = maxCut
+	}
+	return v.now().Add(d)
+}
+
+// matches returns true when the DS record d is the digest of the key k.
+func matches(k *dns.DNSKEY, d *dns.DS) bool {
+	if k.Algorithm != d.Algorithm || k.KeyTag() != d.KeyTag {
+		return false
+	}
+	kd := k.ToDS(d.DigestType)
+	return kd != nil && strings.EqualFold(kd.Digest, d.Digest)
+}
+
+func dnskeys(s *rrset) []*dns.DNSKEY {
+	keys := make([]*dns.DNSKEY, 0, len(s.rrs))
+	for _, rr := range s.rrs {
+		keys = append(keys, rr.(*dns.DNSKEY))
+	}
+	return keys
+}
+
+var (
+	algorithms = map[uint8]bool{
+		dns.RSASHA1: true, dns.RSASHA1NSEC3SHA1: true, dns.RSASHA256: true, dns.RSASHA512: true,
+		dns.ECDSAP256SHA256: true, dns.ECDSAP384SHA384: true, dns.ED25519: true,
+	}
+	digests = map[uint8]bool{dns.SHA1: true, dns.SHA256: true, dns.SHA384: true}
+)
+
+const maxCut = time.Hour // the longest a zone cut is cached
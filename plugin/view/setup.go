@@ -0,0 +1,67 @@
+package view
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/expression"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("view", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	v, err := parse(c)
+	if err != nil {
+		return plugin.Error("view", err)
+	}
+	config := dnsserver.GetConfig(c)
+	config.ViewName = v.name
+	config.Filters = append(config.Filters, v.Filter)
+	return nil
+}
+
+func parse(c *caddy.Controller) (*View, error) {
+	v := &View{}
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return nil, c.ArgErr()
+		}
+		v.name = args[0]
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "expr":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				e, err := expression.Parse(strings.Join(args, " "))
+				if err != nil {
+					return nil, c.Errf("invalid expression: %s", err)
+				}
+				v.exprs = append(v.exprs, e)
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	if len(v.exprs) == 0 {
+		return nil, c.Errf("view %s has no expression", v.name)
+	}
+	return v, nil
+}
@@ -0,0 +1,86 @@
+package view
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		name      string
+	}{
+		{"view internal {\n expr incidr(client_ip, '10.0.0.0/8')\n}", false, "internal"},
+		{"view tcp {\n expr protocol == 'tcp'\n expr type == 'A'\n}", false, "tcp"},
+		{`view internal`, true, ""},
+		{"view {\n expr true\n}", true, ""},
+		{"view a b {\n expr true\n}", true, ""},
+		{"view internal {\n expr\n}", true, ""},
+		{"view internal {\n expr unknown == 1\n}", true, ""},
+		{"view internal {\n expr incidr(client_ip)\n}", true, ""},
+		{"view internal {\n zone example.org\n}", true, ""},
+		{"view a {\n expr true\n}\nview b {\n expr true\n}", true, ""},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		config := dnsserver.GetConfig(c)
+		if config.ViewName != tc.name {
+			t.Errorf("Test %d: expected view %s, got %s", i, tc.name, config.ViewName)
+		}
+		if len(config.Filters) != 1 {
+			t.Errorf("Test %d: expected 1 filter, got %d", i, len(config.Filters))
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	c := caddy.NewTestController("dns", "view v {\n expr incidr(client_ip, '10.240.0.0/16')\n expr type == 'A'\n}")
+	v, err := parse(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		qtype    uint16
+		expected bool
+	}{
+		{dns.TypeA, true},
+		{dns.TypeAAAA, false},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", tc.qtype)
+		// test.ResponseWriter has the remote address 10.240.0.1.
+		state := &request.Request{W: &test.ResponseWriter{}, Req: m}
+		if got := v.Filter(context.TODO(), state); got != tc.expected {
+			t.Errorf("Test %d: expected %t, got %t", i, tc.expected, got)
+		}
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	state := &request.Request{W: &test.ResponseWriter6{}, Req: m}
+	if v.Filter(context.TODO(), state) {
+		t.Error("Expected an IPv6 client not to be in the view")
+	}
+}
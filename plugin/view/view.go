@@ -0,0 +1,35 @@
+// Package view implements a plugin that selects the queries of a server block with expressions, to
+// answer different clients differently for the same zones.
+package view
+
+import (
+	"context"
+
+	"github.com/coredns/coredns/plugin/pkg/expression"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/request"
+)
+
+var log = clog.NewWithPlugin("view")
+
+// View is a view: the queries its expressions are all true for.
+type View struct {
+	name  string
+	exprs []*expression.Expression
+}
+
+// Filter implements the dnsserver.Filter function type: it returns true if the query state is in
+// the view. An expression failing to evaluate is false.
+func (v *View) Filter(ctx context.Context, state *request.Request) bool {
+	for _, e := range v.exprs {
+		ok, err := e.Eval(ctx, state)
+		if err != nil {
+			log.Debugf("Failed to evaluate %q of view %s: %s", e, v.name, err)
+			return false
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package wasm
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/caddyserver/caddy"
+)
+
+var log = clog.NewWithPlugin("wasm")
+
+func init() {
+	caddy.RegisterPlugin("wasm", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	w, err := wasmParse(c)
+	if err != nil {
+		return plugin.Error("wasm", err)
+	}
+
+	c.OnShutdown(w.close)
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		w.Next = next
+		return w
+	})
+
+	return nil
+}
+
+func wasmParse(c *caddy.Controller) (*Wasm, error) {
+	config := dnsserver.GetConfig(c)
+
+	i := 0
+	var w *Wasm
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return nil, c.ArgErr()
+		}
+		file := args[0]
+		if !filepath.IsAbs(file) && config.Root != "" {
+			file = filepath.Join(config.Root, file)
+		}
+		zones := make([]string, len(c.ServerBlockKeys))
+		copy(zones, c.ServerBlockKeys)
+		if len(args) > 1 {
+			zones = args[1:]
+		}
+		for i := range zones {
+			zones[i] = plugin.Host(zones[i]).Normalize()
+		}
+
+		n := runtime.GOMAXPROCS(0)
+		timeout := defaultTimeout
+		for c.NextBlock() {
+			switch c.Val() {
+			case "instances":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				x, err := strconv.Atoi(c.Val())
+				if err != nil {
+					return nil, err
+				}
+				if x <= 0 {
+					return nil, c.Errf("instances must be positive: %d", x)
+				}
+				n = x
+			case "timeout":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, err
+				}
+				if d <= 0 {
+					return nil, c.Errf("timeout can't be negative or zero: %s", d)
+				}
+				timeout = d
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+
+		bin, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		w, err = newWasm(bin, n)
+		if err != nil {
+			return nil, c.Errf("failed to load %s: %s", file, err)
+		}
+		w.Zones = zones
+		w.timeout = timeout
+	}
+	return w, nil
+}
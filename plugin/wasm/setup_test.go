@@ -0,0 +1,68 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestWasmParse(t *testing.T) {
+	file, rm, err := test.TempFile(".", string(testModule(fnOnQuery, 0x80)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+		zones     []string
+	}{
+		{`wasm ` + file, false, []string{"."}},
+		{`wasm ` + file + ` example.org`, false, []string{"example.org."}},
+		{`wasm ` + file + ` {
+			instances 2
+			timeout 500ms
+		}`, false, []string{"."}},
+		// fails
+		{`wasm`, true, nil},
+		{`wasm /does/not/exist.wasm`, true, nil},
+		{`wasm ` + file + ` {
+			instances 0
+		}`, true, nil},
+		{`wasm ` + file + ` {
+			instances
+		}`, true, nil},
+		{`wasm ` + file + ` {
+			timeout 0s
+		}`, true, nil},
+		{`wasm ` + file + ` {
+			timeout soon
+		}`, true, nil},
+		{`wasm ` + file + ` {
+			unknown
+		}`, true, nil},
+		{"wasm " + file + "\nwasm " + file, true, nil},
+	}
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		c.ServerBlockKeys = []string{"."}
+		w, err := wasmParse(c)
+		if tc.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		} else if !tc.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if tc.shouldErr {
+			continue
+		}
+		if len(w.Zones) != len(tc.zones) || w.Zones[0] != tc.zones[0] {
+			t.Errorf("Test %d: Expected zones %v, got %v", i, tc.zones, w.Zones)
+		}
+		w.close()
+	}
+}
@@ -0,0 +1,234 @@
+// Package wasm implements a plugin that runs WebAssembly modules on queries and responses.
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Names of the functions a module exports.
+const (
+	fnMalloc     = "malloc"
+	fnFree       = "free"
+	fnOnQuery    = "on_query"
+	fnOnResponse = "on_response"
+)
+
+// Wasm is a plugin that hands queries and responses to a WebAssembly module.
+type Wasm struct {
+	Next  plugin.Handler
+	Zones []string
+
+	onQuery    bool
+	onResponse bool
+	timeout    time.Duration // of a call into the module
+
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	pool     chan api.Module // a nil instance is a slot whose instantiation failed
+}
+
+const defaultTimeout = time.Second
+
+// newWasm compiles the module in bin and instantiates it n times.
+func newWasm(bin []byte, n int) (*Wasm, error) {
+	ctx := context.Background()
+	// A module that runs past the deadline of a call is closed, so a looping module can't hang a query.
+	config := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+	w := &Wasm{runtime: wazero.NewRuntimeWithConfig(ctx, config), pool: make(chan api.Module, n), timeout: defaultTimeout}
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, w.runtime); err != nil {
+		w.close()
+		return nil, err
+	}
+	compiled, err := w.runtime.CompileModule(ctx, bin)
+	if err != nil {
+		w.close()
+		return nil, err
+	}
+	w.compiled = compiled
+
+	fns := compiled.ExportedFunctions()
+	for _, fn := range []string{fnMalloc, fnFree} {
+		if _, ok := fns[fn]; !ok {
+			w.close()
+			return nil, fmt.Errorf("module does not export %q", fn)
+		}
+	}
+	_, w.onQuery = fns[fnOnQuery]
+	_, w.onResponse = fns[fnOnResponse]
+	if !w.onQuery && !w.onResponse {
+		w.close()
+		return nil, fmt.Errorf("module exports neither %q nor %q", fnOnQuery, fnOnResponse)
+	}
+
+	for i := 0; i < n; i++ {
+		mod, err := w.instantiate()
+		if err != nil {
+			w.close()
+			return nil, err
+		}
+		w.pool <- mod
+	}
+	return w, nil
+}
+
+// instantiate returns a new instance of the module.
+func (w *Wasm) instantiate() (api.Module, error) {
+	// Anonymous modules can be instantiated more than once; "_initialize" is the WASI reactor entry point.
+	config := wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize")
+	return w.runtime.InstantiateModule(context.Background(), w.compiled, config)
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (w *Wasm) ServeDNS(ctx context.Context, rw dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: rw, Req: r}
+	if plugin.Zones(w.Zones).Matches(state.Name()) == "" {
+		return plugin.NextOrFailure(w.Name(), w.Next, ctx, rw, r)
+	}
+
+	if w.onQuery {
+		m, err := w.call(ctx, fnOnQuery, r)
+		if err != nil {
+			return dns.RcodeServerFailure, plugin.Error(w.Name(), err)
+		}
+		if m != nil && m.Response {
+			m.Id = r.Id
+			rw.WriteMsg(m)
+			return dns.RcodeSuccess, nil
+		}
+		if m != nil {
+			r = m
+		}
+	}
+
+	if w.onResponse {
+		rw = &ResponseWriter{ResponseWriter: rw, wasm: w, ctx: ctx}
+	}
+	return plugin.NextOrFailure(w.Name(), w.Next, ctx, rw, r)
+}
+
+// Name implements the Handler interface.
+func (w *Wasm) Name() string { return "wasm" }
+
+// call calls the function fn of one of the module instances with m in wire format. It returns the
+// message the function returned or nil if the function returned 0. The call is aborted when ctx is
+// done or the timeout has passed.
+func (w *Wasm) call(ctx context.Context, fn string, m *dns.Msg) (*dns.Msg, error) {
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	var mod api.Module
+	select {
+	case mod = <-w.pool:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no module instance available: %s", ctx.Err())
+	}
+	if mod == nil {
+		if mod, err = w.instantiate(); err != nil {
+			w.pool <- nil
+			return nil, err
+		}
+	}
+
+	ret, err := w.callModule(ctx, mod, fn, buf)
+	if err != nil {
+		// The instance is closed when the call was aborted, and may be left in any state by a trap,
+		// replace it with a new one. If that fails the slot goes back empty, so the pool keeps its
+		// size and the next call tries again.
+		mod.Close(context.Background())
+		var ierr error
+		if mod, ierr = w.instantiate(); ierr != nil {
+			log.Errorf("Failed to replace a module instance: %s", ierr)
+			mod = nil
+		}
+	}
+	w.pool <- mod
+	return ret, err
+}
+
+// callModule calls fn of mod with the message in buf. The memory malloc returned for buf, and the
+// memory of the message fn returned, are freed afterwards. On error mod is not to be used anymore, so
+// nothing is freed then.
+func (w *Wasm) callModule(ctx context.Context, mod api.Module, fn string, buf []byte) (*dns.Msg, error) {
+	res, err := mod.ExportedFunction(fnMalloc).Call(ctx, uint64(len(buf)))
+	if err != nil {
+		return nil, err
+	}
+	in := uint32(res[0])
+	if !mod.Memory().Write(in, buf) {
+		return nil, fmt.Errorf("%s returned out of range pointer %d", fnMalloc, in)
+	}
+
+	res, err = mod.ExportedFunction(fn).Call(ctx, uint64(in), uint64(len(buf)))
+	if err != nil {
+		return nil, err
+	}
+
+	free := mod.ExportedFunction(fnFree)
+	var ret *dns.Msg
+	if res[0] != 0 {
+		ptr, size := uint32(res[0]>>32), uint32(res[0])
+		out, ok := mod.Memory().Read(ptr, size)
+		if !ok {
+			return nil, fmt.Errorf("%s returned out of range message at %d with length %d", fn, ptr, size)
+		}
+		ret = new(dns.Msg)
+		if err := ret.Unpack(out); err != nil {
+			return nil, err
+		}
+		if ptr != in {
+			if _, err := free.Call(ctx, uint64(ptr)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := free.Call(ctx, uint64(in)); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// close closes the runtime, and with it all module instances.
+func (w *Wasm) close() error { return w.runtime.Close(context.Background()) }
+
+// ResponseWriter hands the response to the module's on_response function before writing it.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	wasm *Wasm
+	ctx  context.Context
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (r *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	m, err := r.wasm.call(r.ctx, fnOnResponse, res)
+	if err != nil {
+		log.Errorf("Failed to call %s: %s", fnOnResponse, err)
+		return r.ResponseWriter.WriteMsg(res)
+	}
+	if m == nil {
+		return r.ResponseWriter.WriteMsg(res)
+	}
+	m.Id = res.Id
+	return r.ResponseWriter.WriteMsg(m)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (r *ResponseWriter) Write(buf []byte) (int, error) {
+	log.Warning("ResponseWriter called with Write, not running on_response")
+	return r.ResponseWriter.Write(buf)
+}
@@ -0,0 +1,301 @@
+package wasm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// testModule returns a WebAssembly module that exports memory, malloc, free and a function named fn.
+// Malloc always returns offset 1024, free counts its calls in the 32 bits at offset 0, fn ORs mask
+// into the third byte of the message (QR, Opcode, AA, TC and RD) and returns the message.
+func testModule(fn string, mask int) []byte {
+	body := []byte{0x00} // no locals
+	body = append(body, or(2, mask)...)
+	return module(fn, append(body, ret...))
+}
+
+// rcodeModule returns a module like testModule, but fn makes the message a response with rcode.
+func rcodeModule(fn string, rcode int) []byte {
+	body := []byte{0x00} // no locals
+	body = append(body, or(2, 0x80)...)
+	body = append(body, or(3, rcode)...)
+	return module(fn, append(body, ret...))
+}
+
+// or returns the instructions ORing mask into the byte at offset of the message.
+func or(offset byte, mask int) []byte {
+	sleb := func(v int) []byte {
+		if v >= 64 {
+			return []byte{byte(v&0x7f | 0x80), byte(v >> 7)}
+		}
+		return []byte{byte(v)}
+	}
+
+	code := []byte{0x20, 0x00, 0x20, 0x00, // local.get 0, local.get 0
+		0x2d, 0x00, offset, // i32.load8_u offset
+		0x41} // i32.const mask
+	code = append(code, sleb(mask)...)
+	return append(code, 0x72, // i32.or
+		0x3a, 0x00, offset) // i32.store8 offset
+}
+
+// ret ends a function, returning the message as ptr<<32 | len.
+var ret = []byte{0x20, 0x00, 0xad, 0x42, 0x20, 0x86, // (i64) ptr << 32
+	0x20, 0x01, 0xad, 0x84, // | (i64) len
+	0x0b} // end
+
+// loopModule returns a module like testModule, but fn loops forever.
+func loopModule(fn string) []byte {
+	return module(fn, []byte{0x00, // no locals
+		0x03, 0x40, 0x0c, 0x00, 0x0b, // loop, br 0, end
+		0x42, 0x00, // i64.const 0
+		0x0b}) // end
+}
+
+// module returns a module with memory, malloc, free and fn with body.
+func module(fn string, body []byte) []byte {
+	section := func(id byte, content ...byte) []byte {
+		return append([]byte{id, byte(len(content))}, content...)
+	}
+	name := func(s string) []byte { return append([]byte{byte(len(s))}, s...) }
+
+	malloc := []byte{0x00, 0x41, 0x80, 0x08, 0x0b} // i32.const 1024
+	free := []byte{0x00,
+		0x41, 0x00, 0x41, 0x00, 0x28, 0x02, 0x00, // i32.const 0, i32.load (i32.const 0)
+		0x41, 0x01, 0x6a, // i32.const 1, i32.add
+		0x36, 0x02, 0x00, // i32.store
+		0x0b} // end
+
+	exports := []byte{0x04}
+	exports = append(exports, append(name("memory"), 0x02, 0x00)...)
+	exports = append(exports, append(name("malloc"), 0x00, 0x00)...)
+	exports = append(exports, append(name(fn), 0x00, 0x01)...)
+	exports = append(exports, append(name("free"), 0x00, 0x02)...)
+
+	code := []byte{0x03, byte(len(malloc))}
+	code = append(code, malloc...)
+	code = append(code, byte(len(body)))
+	code = append(code, body...)
+	code = append(code, byte(len(free)))
+	code = append(code, free...)
+
+	bin := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	bin = append(bin, section(0x01, 0x03, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e, 0x60, 0x01, 0x7f, 0x00)...) // types
+	bin = append(bin, section(0x03, 0x03, 0x00, 0x01, 0x02)...)                                                                         // functions
+	bin = append(bin, section(0x05, 0x01, 0x00, 0x01)...)                                                                               // memory
+	bin = append(bin, section(0x07, exports...)...)
+	bin = append(bin, section(0x0a, code...)...)
+	return bin
+}
+
+func TestWasmOnQuery(t *testing.T) {
+	w, err := newWasm(testModule(fnOnQuery, 0x80), 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	defer w.close()
+	w.Zones = []string{"example.org."}
+	w.Next = test.ErrorHandler()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := w.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if rec.Msg == nil || !rec.Msg.Response {
+		t.Fatalf("Expected response written by module, got %v", rec.Msg)
+	}
+	if rec.Msg.Id != m.Id {
+		t.Errorf("Expected id %d, got %d", m.Id, rec.Msg.Id)
+	}
+	if n := frees(t, w); n != 1 {
+		t.Errorf("Expected the message to be freed once, got %d calls to free", n)
+	}
+
+	// Not in zone, goes to the next plugin.
+	m.SetQuestion("example.net.", dns.TypeA)
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := w.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if rec.Msg.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected SERVFAIL from the next plugin, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+}
+
+func TestWasmOnResponse(t *testing.T) {
+	w, err := newWasm(testModule(fnOnResponse, 0x04), 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	defer w.close()
+	w.Zones = []string{"."}
+	w.Next = test.ErrorHandler()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := w.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if !rec.Msg.Authoritative {
+		t.Errorf("Expected the module to set the AA bit in the response")
+	}
+}
+
+// frees returns the number of calls to free of the module instances of w.
+func frees(t *testing.T, w *Wasm) uint32 {
+	n := uint32(0)
+	for i := 0; i < cap(w.pool); i++ {
+		mod := <-w.pool
+		x, _ := mod.Memory().ReadUint32Le(0)
+		n += x
+		defer func() { w.pool <- mod }()
+	}
+	return n
+}
+
+func TestWasmOnQueryServerFailure(t *testing.T) {
+	w, err := newWasm(rcodeModule(fnOnQuery, dns.RcodeServerFailure), 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	defer w.close()
+	w.Zones = []string{"."}
+	w.Next = test.ErrorHandler()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rw := &writeCounter{ResponseWriter: &test.ResponseWriter{}}
+	rcode, err := w.ServeDNS(context.TODO(), rw, m)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	// The server writes a response of its own for the rcodes not written to the client.
+	if !plugin.ClientWrite(rcode) {
+		rw.writes++
+	}
+	if rw.writes != 1 {
+		t.Errorf("Expected the response to be written once, got %d writes", rw.writes)
+	}
+	if rw.rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected the SERVFAIL of the module, got %s", dns.RcodeToString[rw.rcode])
+	}
+}
+
+// writeCounter counts the messages written to it.
+type writeCounter struct {
+	dns.ResponseWriter
+	writes int
+	rcode  int // of the last message
+}
+
+func (w *writeCounter) WriteMsg(m *dns.Msg) error {
+	w.writes++
+	w.rcode = m.Rcode
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+func TestWasmTimeout(t *testing.T) {
+	w, err := newWasm(loopModule(fnOnQuery), 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	defer w.close()
+	w.Zones = []string{"."}
+	w.Next = test.ErrorHandler()
+	w.timeout = 50 * time.Millisecond
+
+	// The looping call is aborted, and the instance replaced, so each query gets an answer.
+	for i := 0; i < 2; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rcode, err := w.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+		if err == nil || rcode != dns.RcodeServerFailure {
+			t.Errorf("Test %d: expected SERVFAIL with an error, got %s", i, dns.RcodeToString[rcode])
+		}
+	}
+	if len(w.pool) != 1 {
+		t.Errorf("Expected the instance to be replaced")
+	}
+
+	// The deadline of the query aborts the call too.
+	w.timeout = time.Hour
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := w.call(ctx, fnOnQuery, new(dns.Msg)); err == nil {
+		t.Errorf("Expected an error for a call past the deadline")
+	}
+}
+
+func TestWasmInstantiateFailure(t *testing.T) {
+	w, err := newWasm(loopModule(fnOnQuery), 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	defer w.close()
+	w.timeout = 50 * time.Millisecond
+
+	// Without the compiled module the aborted instances can't be replaced, their slots stay in the pool.
+	w.compiled.Close(context.TODO())
+	for i := 0; i < 4; i++ {
+		if _, err := w.call(context.TODO(), fnOnQuery, new(dns.Msg)); err == nil {
+			t.Errorf("Test %d: expected an error", i)
+		}
+	}
+	if len(w.pool) != 2 {
+		t.Fatalf("Expected 2 slots in the pool, got %d", len(w.pool))
+	}
+
+	// Once instantiating works again, the empty slots are filled by the calls.
+	w.compiled, err = w.runtime.CompileModule(context.TODO(), testModule(fnOnQuery, 0x80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		m, err := w.call(context.TODO(), fnOnQuery, new(dns.Msg))
+		if err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if !m.Response {
+			t.Errorf("Test %d: expected the message returned by the module", i)
+		}
+	}
+	if len(w.pool) != 2 {
+		t.Errorf("Expected 2 instances in the pool, got %d", len(w.pool))
+	}
+}
+
+func TestWasmPoolDeadline(t *testing.T) {
+	w, err := newWasm(testModule(fnOnQuery, 0x80), 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	defer w.close()
+
+	// With all instances busy, the call gives up at the deadline.
+	mod := <-w.pool
+	defer func() { w.pool <- mod }()
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := w.call(ctx, fnOnQuery, new(dns.Msg)); err == nil {
+		t.Errorf("Expected an error without an instance available")
+	}
+}
+
+func TestNewWasmInvalid(t *testing.T) {
+	if _, err := newWasm([]byte("not wasm"), 1); err == nil {
+		t.Errorf("Expected error for invalid module, got none")
+	}
+	if _, err := newWasm(testModule("other", 0x80), 1); err == nil {
+		t.Errorf("Expected error for module without on_query and on_response, got none")
+	}
+}
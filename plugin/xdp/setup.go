@@ -0,0 +1,49 @@
+// Package xdp implements a plugin that reads the UDP queries from a network interface with AF_XDP
+// sockets.
+package xdp
+
+import (
+	"net"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("xdp", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	iface, err := parse(c)
+	if err != nil {
+		return plugin.Error("xdp", err)
+	}
+	dnsserver.GetConfig(c).XDPInterface = iface
+	return nil
+}
+
+func parse(c *caddy.Controller) (string, error) {
+	iface := ""
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return "", plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return "", c.ArgErr()
+		}
+		if _, err := net.InterfaceByName(args[0]); err != nil {
+			return "", c.Errf("interface %s: %s", args[0], err)
+		}
+		iface = args[0]
+	}
+	return iface, nil
+}
@@ -0,0 +1,48 @@
+package xdp
+
+import (
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	if err != nil || len(ifaces) == 0 {
+		t.Skip("No network interfaces")
+	}
+	iface := ifaces[0].Name
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  string
+	}{
+		{`xdp ` + iface, false, iface},
+		{`xdp`, true, ""},
+		{`xdp ` + iface + ` ` + iface, true, ""},
+		{`xdp nosuchinterface0`, true, ""},
+		{"xdp " + iface + "\nxdp " + iface, true, ""},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if got := dnsserver.GetConfig(c).XDPInterface; got != tc.expected {
+			t.Errorf("Test %d: expected interface %s, got %s", i, tc.expected, got)
+		}
+	}
+}
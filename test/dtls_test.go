@@ -0,0 +1,64 @@
+package test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pion/dtls/v2"
+)
+
+func TestDTLS(t *testing.T) {
+	corefile := `dtls://.:0 {
+		tls ../plugin/tls/test_cert.pem ../plugin/tls/test_key.pem
+		whoami
+}
+`
+	d, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer d.Stop()
+
+	addr, err := net.ResolveUDPAddr("udp", udp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := dtls.DialWithContext(ctx, "udp", addr, &dtls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	defer conn.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("whoami.example.org.", dns.TypeA)
+	buf, _ := m.Pack()
+	if _, err := conn.Write(buf); err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp := make([]byte, dns.MaxMsgSize)
+	n, err := conn.Read(resp)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(resp[:n]); err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected success but got %d", r.Rcode)
+	}
+	if len(r.Extra) != 2 {
+		t.Fatalf("Expected 2 RRs in additional section, but got %d", len(r.Extra))
+	}
+	// DTLS carries datagrams, the plugins see the query as a UDP one.
+	if srv := r.Extra[1].Header().Name; !strings.HasPrefix(srv, "_udp.") {
+		t.Errorf("Expected the SRV record of a UDP query, got %s", srv)
+	}
+}
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestFileUpdateTSIG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-update")
+	if err != nil {
+		t.Fatalf("Failed to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "db.example.org")
+	if err := ioutil.WriteFile(name, []byte(exampleOrg), 0644); err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+
+	const secret = "c2VjcmV0c2VjcmV0c2VjcmV0"
+	corefile := `example.org:0 {
+		file ` + name + ` {
+			update 127.0.0.1 ::1 key update.example.org. ` + secret + `
+		}
+	}`
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	c := &dns.Client{TsigSecret: map[string]string{"update.example.org.": secret}}
+
+	// An unsigned update is refused.
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Insert([]dns.RR{test.A("new.example.org. 300 IN A 127.0.0.9")})
+	r, _, err := c.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected a response, got %s", err)
+	}
+	if r.Rcode != dns.RcodeRefused {
+		t.Errorf("Expected REFUSED, got rcode %d", r.Rcode)
+	}
+
+	// A signed one is applied, and gets a signed response.
+	m.SetTsig("update.example.org.", dns.HmacSHA256, 300, time.Now().Unix())
+	r, _, err = c.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected a verified response, got %s", err)
+	}
+	if r.Rcode != dns.RcodeSuccess || r.IsTsig() == nil {
+		t.Errorf("Expected a signed NOERROR response, got rcode %d", r.Rcode)
+	}
+
+	m = new(dns.Msg)
+	m.SetQuestion("new.example.org.", dns.TypeA)
+	r, err = dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected a response, got %s", err)
+	}
+	if len(r.Answer) != 1 {
+		t.Errorf("Expected the added record, got %d answers", len(r.Answer))
+	}
+}
@@ -99,3 +99,60 @@ func TestLargeAXFR(t *testing.T) {
 		t.Fatalf("Expected a AAAA answer, but it wasn't: type %d", resp.Answer[len(resp.Answer)-1].Header().Rrtype)
 	}
 }
+
+func TestTSIGAXFR(t *testing.T) {
+	name, rm, err := test.TempFile(".", `example.com. IN SOA . . 1 60 60 60 60
+example.com. IN NS ns.example.
+a.example.com. IN A 127.0.0.1
+`)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+
+	const secret = "c2VjcmV0c2VjcmV0c2VjcmV0"
+	corefile := `example.com:0 {
+       file ` + name + ` {
+           transfer to 127.0.0.1 ::1 key xfr.example.com. ` + secret + `
+       }
+}
+`
+	i, _, tcp, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	// A transfer signed with the key succeeds, and the replies are signed too.
+	m := new(dns.Msg)
+	m.SetAxfr("example.com.")
+	m.SetTsig("xfr.example.com.", dns.HmacSHA256, 300, time.Now().Unix())
+	tr := &dns.Transfer{TsigSecret: map[string]string{"xfr.example.com.": secret}}
+	ch, err := tr.In(m, tcp)
+	if err != nil {
+		t.Fatalf("Expected to start the transfer, but didn't: %s", err)
+	}
+	nrr := 0
+	for env := range ch {
+		if env.Error != nil {
+			t.Fatalf("Expected a signed transfer, got %s", env.Error)
+		}
+		nrr += len(env.RR)
+	}
+	if nrr != 4 {
+		t.Errorf("Got an unexpected number of RRs: %d", nrr)
+	}
+
+	// Without the key it is refused.
+	m = new(dns.Msg)
+	m.SetAxfr("example.com.")
+	ch, err = new(dns.Transfer).In(m, tcp)
+	if err != nil {
+		t.Fatalf("Expected to start the transfer, but didn't: %s", err)
+	}
+	for env := range ch {
+		if env.Error == nil {
+			t.Errorf("Expected an unsigned transfer to fail, got %d RRs", len(env.RR))
+		}
+	}
+}
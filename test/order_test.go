@@ -0,0 +1,53 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestOrder(t *testing.T) {
+	t.Parallel()
+	// By default rewrite comes before template, the template would then see the rewritten A query.
+	corefile := `example.org:0 {
+       order template before rewrite
+       rewrite type MX A
+       template IN MX example.org {
+           answer "{{ .Name }} 60 IN MX 10 mx.example.org."
+       }
+}`
+
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeMX)
+	r, err := dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if len(r.Answer) == 0 {
+		t.Fatalf("Expected at least one RR in the answer section, got none")
+	}
+	if r.Answer[0].Header().Rrtype != dns.TypeMX {
+		t.Errorf("Expected MX record, got: %s", r.Answer[0])
+	}
+}
+
+func TestOrderUnusedPlugin(t *testing.T) {
+	t.Parallel()
+	corefile := `example.org:0 {
+       order cache before rewrite
+       rewrite type MX A
+       whoami
+}`
+
+	i, err := CoreDNSServer(corefile)
+	if err == nil {
+		i.Stop()
+		t.Fatalf("Expected error for ordering a plugin that is not used, got none")
+	}
+}
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	// The upstream drops all queries.
+	up, err := CoreDNSServer(`example.org:0 {
+		erratic {
+			drop 1
+		}
+	}`)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer up.Stop()
+	upAddr, _ := CoreDNSServerPorts(up, 0)
+	if upAddr == "" {
+		t.Fatalf("Could not get UDP listening port")
+	}
+
+	i, udp, _, err := CoreDNSServerAndPorts(`example.org:0 {
+		query_timeout 300ms
+		forward . ` + upAddr + `
+	}`)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	c := &dns.Client{Timeout: 5 * time.Second}
+	start := time.Now()
+	r, _, err := c.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected a response, got %s", err)
+	}
+	if r.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected SERVFAIL, got %s", dns.RcodeToString[r.Rcode])
+	}
+	// Without query_timeout forward waits for 5 seconds.
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Expected forward to give up after the query timeout, took %s", d)
+	}
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/doq"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestQUIC(t *testing.T) {
+	corefile := `quic://.:0 {
+		tls ../plugin/tls/test_cert.pem ../plugin/tls/test_key.pem
+		whoami
+}
+`
+	q, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer q.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := quic.DialAddr(ctx, udp, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{doq.NextProto}}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	defer conn.CloseWithError(doq.NoError, "")
+
+	str, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	m := new(dns.Msg)
+	m.SetQuestion("whoami.example.org.", dns.TypeA)
+	m.Id = 0
+	buf, _ := doq.Pack(m)
+	str.Write(buf)
+	str.Close()
+
+	d, err := doq.ReadMsg(str)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	if d.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected success but got %d", d.Rcode)
+	}
+	if len(d.Extra) != 2 {
+		t.Fatalf("Expected 2 RRs in additional section, but got %d", len(d.Extra))
+	}
+	// QUIC streams are reliable like TCP, the plugins see the query as a TCP one.
+	if srv := d.Extra[1].Header().Name; !strings.HasPrefix(srv, "_tcp.") {
+		t.Errorf("Expected the SRV record of a TCP query, got %s", srv)
+	}
+}
+
+func TestH3(t *testing.T) {
+	corefile := `h3://.:0 {
+		tls ../plugin/tls/test_cert.pem ../plugin/tls/test_key.pem
+		whoami
+}
+`
+	h, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer h.Stop()
+
+	rt := &http3.RoundTripper{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	defer rt.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("whoami.example.org.", dns.TypeA)
+	req, err := doh.NewRequest(http.MethodPost, udp, m)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	d, err := doh.ResponseToMsg(resp)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	if d.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected success but got %d", d.Rcode)
+	}
+	if len(d.Extra) != 2 {
+		t.Errorf("Expected 2 RRs in additional section, but got %d", len(d.Extra))
+	}
+}
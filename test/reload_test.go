@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/caddyserver/caddy"
 	"github.com/miekg/dns"
 )
 
@@ -333,3 +335,53 @@ func TestMetricsAvailableAfterReloadAndFailedReload(t *testing.T) {
 }
 
 const inUse = "address already in use"
+
+func TestReloadWatchWorkingDir(t *testing.T) {
+	// The Corefile of the test input is "Corefile", which doesn't exist in the working directory:
+	// a write there must not trigger a reload, not while serving and not while stopping.
+	corefile := `.:0 {
+	reload 2s 1s
+	whoami
+}`
+	before := caddy.Instances()
+	c, err := CoreDNSServer(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+
+	write := func() {
+		f, err := ioutil.TempFile(".", "reload-test")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer os.Remove(f.Name())
+		f.WriteString("example.org {\n}\n")
+		f.Close()
+	}
+
+	write()
+	time.Sleep(500 * time.Millisecond)
+	if !running(c) {
+		t.Fatal("Expected the instance not to be reloaded after a write in the working directory")
+	}
+	udp, _ := CoreDNSServerPorts(c, 0)
+	send(t, udp)
+
+	go write()
+	c.Stop()
+	time.Sleep(500 * time.Millisecond)
+	if n := len(caddy.Instances()) - len(before); n != 0 {
+		t.Errorf("Expected no new instances after Stop, got %d", n)
+	}
+}
+
+// running returns true if instance i has not been stopped.
+func running(i *caddy.Instance) bool {
+	for _, other := range caddy.Instances() {
+		if other == i {
+			return true
+		}
+	}
+	return false
+}
@@ -83,3 +83,52 @@ func TestSecondaryZoneTransfer(t *testing.T) {
 		t.Fatalf("Expected answer section")
 	}
 }
+
+func TestSecondaryZoneTransferTSIG(t *testing.T) {
+	name, rm, err := test.TempFile(".", exampleOrg)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+
+	const secret = "c2VjcmV0c2VjcmV0c2VjcmV0"
+	corefile := `example.org:0 {
+       file ` + name + ` {
+	       transfer to 127.0.0.1 ::1 key xfr.example.org. ` + secret + `
+       }
+}
+`
+	i, _, tcp, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	// The secondary signs its requests, the primary refuses unsigned ones.
+	corefile = `example.org:0 {
+		secondary {
+			transfer from ` + tcp + ` key xfr.example.org. ` + secret + `
+		}
+}
+`
+	i1, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i1.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeSOA)
+
+	var r *dns.Msg
+	for i := 0; i < 20; i++ {
+		r, err = dns.Exchange(m, udp)
+		if err == nil && len(r.Answer) != 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil || len(r.Answer) == 0 {
+		t.Fatalf("Expected answer section")
+	}
+}
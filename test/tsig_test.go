@@ -0,0 +1,65 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestTSIG(t *testing.T) {
+	const secret = "c2VjcmV0c2VjcmV0c2VjcmV0"
+	corefile := `example.org:0 {
+		tsig {
+			secret query.example.org. ` + secret + `
+			require all
+		}
+		whoami
+	}`
+
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	c := &dns.Client{TsigSecret: map[string]string{"query.example.org.": secret, "unknown.example.org.": secret}}
+
+	// A signed query gets a signed response, the client verifies it.
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.SetTsig("query.example.org.", dns.HmacSHA256, 300, time.Now().Unix())
+	r, _, err := c.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected a verified response, got %s", err)
+	}
+	if r.Rcode != dns.RcodeSuccess || r.IsTsig() == nil {
+		t.Errorf("Expected a signed NOERROR response, got rcode %d", r.Rcode)
+	}
+
+	// An unsigned query is refused.
+	m = new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	r, _, err = c.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected a response, got %s", err)
+	}
+	if r.Rcode != dns.RcodeRefused {
+		t.Errorf("Expected REFUSED, got rcode %d", r.Rcode)
+	}
+
+	// A query signed with an unknown key gets NOTAUTH with BADKEY, unsigned.
+	m = new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.SetTsig("unknown.example.org.", dns.HmacSHA256, 300, time.Now().Unix())
+	r, _, _ = c.Exchange(m, udp)
+	if r == nil {
+		t.Fatal("Expected a response, got none")
+	}
+	if r.Rcode != dns.RcodeNotAuth {
+		t.Errorf("Expected NOTAUTH, got rcode %d", r.Rcode)
+	}
+	if tsig := r.IsTsig(); tsig == nil || tsig.Error != dns.RcodeBadKey {
+		t.Errorf("Expected a TSIG with BADKEY, got %v", tsig)
+	}
+}